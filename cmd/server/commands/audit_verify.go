@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// VerifyAuditOptions defines the options for verifying the audit chains
+type VerifyAuditOptions struct {
+	PublicKeyFile string `doc:"Public key that checkpoints are verified with" json:"public_key"`
+	Checkpoint    bool   `doc:"Sign a checkpoint of each chain that verifies" json:"checkpoint"`
+}
+
+// AuditChainReport describes the verification of one audit chain
+type AuditChainReport struct {
+	Chain       string
+	Entries     int64                  // entries verified before the first break
+	HeadHash    []byte                 // hash of the last verified entry
+	Checkpoints int                    // signed checkpoints of the chain
+	Unchained   int64                  // entries written before the chain existed
+	Break       error                  // first break of the chain; nil if it verified
+	Checkpoint  *auditchain.Checkpoint // checkpoint signed after verification
+}
+
+// VerifyAudit recomputes every audit chain from its first entry and checks it
+// against its signed checkpoints, reporting the first break of each chain.
+// signer is only needed to sign checkpoints.
+func VerifyAudit(ctx context.Context, db bob.Executor, signer *auditchain.Signer, opts *VerifyAuditOptions) ([]AuditChainReport, error) {
+	if opts.Checkpoint && signer == nil {
+		return nil, fmt.Errorf("signing checkpoints requires the checkpoint key")
+	}
+	publicKey, err := auditchain.LoadPublicKey(opts.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	chainDAO := dao.NewAuditChainDAO(db, signer, 0)
+	reports := make([]AuditChainReport, 0, len(dao.AuditChains))
+	for _, chain := range dao.AuditChains {
+		report, err := verifyAuditChain(ctx, chainDAO, publicKey, chain)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Checkpoint && report.Break == nil && report.Entries > 0 {
+			report.Checkpoint, err = chainDAO.Checkpoint(ctx, chain, report.Entries, report.HeadHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		log.Info().
+			Str("chain", chain).
+			Int64("entries", report.Entries).
+			Bool("intact", report.Break == nil).
+			Msg("Verified audit chain")
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// verifyAuditChain verifies one chain. Errors reading the chain are returned;
+// a chain that does not verify is reported.
+func verifyAuditChain(ctx context.Context, chainDAO *dao.AuditChainDAO, publicKey ed25519.PublicKey, chain string) (*AuditChainReport, error) {
+	head, err := chainDAO.GetHead(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("audit chain %s does not exist", chain)
+	}
+
+	checkpoints, err := chainDAO.ListCheckpoints(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditChainReport{Chain: chain, Checkpoints: len(checkpoints), Unchained: head.UnchainedRows}
+	for i := range checkpoints {
+		if err := auditchain.VerifyCheckpoint(publicKey, &checkpoints[i]); err != nil {
+			report.Break = err
+			return report, nil
+		}
+	}
+
+	// Entries written outside the chain were inserted around it
+	unchained, err := chainDAO.CountUnchainedEntries(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	if unchained != head.UnchainedRows {
+		report.Break = fmt.Errorf("%w: %d entries are outside the chain but %d were written before it existed", auditchain.ErrBroken, unchained, head.UnchainedRows)
+		return report, nil
+	}
+
+	verifier := auditchain.NewVerifier(chain, checkpoints)
+	err = chainDAO.WalkChain(ctx, chain, func(id string, link auditchain.Link, fields []auditchain.Field) error {
+		return verifier.Next(id, link, fields...)
+	})
+	if err == nil {
+		err = verifier.Finish(head.Seq, head.HeadHash)
+	}
+	report.Entries, report.HeadHash = verifier.Head()
+	if errors.Is(err, auditchain.ErrBroken) {
+		report.Break = err
+		return report, nil
+	}
+	return report, err
+}
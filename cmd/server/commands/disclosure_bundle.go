@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"crypto/ecdh"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// ExportDisclosureOptions defines the options for exporting a disclosure bundle
+type ExportDisclosureOptions struct {
+	ReportID         string `doc:"Compliance report whose bundle is exported" json:"report_id"`
+	Output           string `doc:"File the bundle is written to (default: a name derived from the report and bundle in the current directory)" json:"output"`
+	RecipientKeyFile string `doc:"PEM X25519 public key of the recipient; the bundle is encrypted to it when set" json:"recipient_key"`
+}
+
+// ExportDisclosure writes the signed disclosure bundle of a compliance case
+// in progress or completed to a file. The export is recorded in the history
+// of the case.
+func ExportDisclosure(ctx context.Context, db bob.Executor, signer *disclosure.Signer, opts *ExportDisclosureOptions) (*disclosure.Bundle, string, error) {
+	reportID, err := uuid.FromString(opts.ReportID)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid report ID %q", opts.ReportID)
+	}
+	report, err := dao.NewComplianceReportDAO(db).GetReport(ctx, reportID)
+	if err != nil {
+		return nil, "", err
+	}
+	if report == nil {
+		return nil, "", fmt.Errorf("compliance report %s does not exist", opts.ReportID)
+	}
+	if report.Status != dao.ComplianceReportInProgress && report.Status != dao.ComplianceReportCompleted {
+		return nil, "", fmt.Errorf("compliance report is %s; only cases in progress or completed can be exported", report.Status)
+	}
+
+	var recipient *ecdh.PublicKey
+	if opts.RecipientKeyFile != "" {
+		recipient, err = disclosure.LoadRecipientKey(opts.RecipientKeyFile)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// The audit trail is only read, so no chain writer is needed
+	bundle, err := disclosure.NewExporter(db, nil, signer).Export(ctx, report, recipient, sql.Null[int64]{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = disclosure.FileName(bundle)
+	}
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(bundle.Data); err != nil {
+		return nil, "", fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	log.Info().
+		Str("report_id", opts.ReportID).
+		Str("bundle_id", bundle.Manifest.BundleID).
+		Int("files", len(bundle.Manifest.Files)).
+		Bool("encrypted", bundle.Encrypted).
+		Msg("Exported disclosure bundle")
+	return bundle, output, nil
+}
+
+// VerifyDisclosureOptions defines the options for verifying a received disclosure bundle
+type VerifyDisclosureOptions struct {
+	BundleFile              string `doc:"Bundle to verify" json:"bundle"`
+	PublicKeyFile           string `doc:"Public key of the platform that signed the bundle" json:"public_key"`
+	RecipientPrivateKeyFile string `doc:"PEM X25519 private key the bundle was encrypted to; required for encrypted bundles" json:"recipient_private_key"`
+	ExtractDir              string `doc:"Directory the verified files are written to" json:"extract"`
+}
+
+// VerifyDisclosure decrypts a bundle if needed, checks the signature of its
+// manifest and the hash of every file, and optionally extracts the verified
+// files. A bundle that does not verify is an error wrapping
+// disclosure.ErrInvalidBundle.
+func VerifyDisclosure(opts *VerifyDisclosureOptions) (*disclosure.Manifest, error) {
+	publicKey, err := disclosure.LoadPublicKey(opts.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(opts.BundleFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	if disclosure.IsEncrypted(data) {
+		if opts.RecipientPrivateKeyFile == "" {
+			return nil, fmt.Errorf("bundle is encrypted; its recipient's private key is required")
+		}
+		recipient, err := disclosure.LoadRecipientPrivateKey(opts.RecipientPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = disclosure.Decrypt(recipient, data); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, files, err := disclosure.Open(publicKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ExtractDir != "" {
+		for _, file := range files {
+			path := filepath.Join(opts.ExtractDir, filepath.FromSlash(file.Path))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return nil, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+			}
+			if err := os.WriteFile(path, file.Data, 0600); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", file.Path, err)
+			}
+		}
+	}
+	return manifest, nil
+}
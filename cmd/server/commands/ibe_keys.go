@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,12 +31,16 @@ type IBEKeyOptions struct {
 	Scopes         string `doc:"Comma-separated list of scopes to generate keys for" json:"scopes"`
 	NonInteractive bool   `doc:"Non-interactive mode" json:"non_interactive"`
 	KeySize        int    `doc:"Key size in bytes (default 32, i.e., 256 bits)" json:"key_size" default:"32"`
+	KeyProvider    string `doc:"Key provider the domain masters are written to: dir, env, keystore or kms" json:"key_provider" default:"dir"`
+	KeystorePath   string `doc:"Keystore file of the keystore and kms providers (default: IBE_KEYSTORE_PATH)" json:"keystore_path"`
+	KMSURL         string `doc:"KMS of the kms provider (default: IBE_KMS_URL)" json:"kms_url"`
+	KMSKeyID       string `doc:"KMS key wrapping the domain masters (default: IBE_KMS_KEY_ID)" json:"kms_key_id"`
 }
 
 // GenerateIBEKeys generates IBE keys for the enhanced architecture
 func GenerateIBEKeys(opts *IBEKeyOptions) error {
-	// Load configuration (for future use)
-	_, err := config.Load()
+	// Load configuration for the key provider settings
+	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -61,8 +68,12 @@ func GenerateIBEKeys(opts *IBEKeyOptions) error {
 		}
 	}
 
-	// Generate domain keys
-	if err := generateDomainKeys(ibeSystem, opts.OutputDir); err != nil {
+	// Write the domain masters to the key provider
+	provider, err := newKeyProvider(&cfg.IBE, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open key provider: %w", err)
+	}
+	if err := generateDomainKeys(ibeSystem, provider); err != nil {
 		return fmt.Errorf("failed to generate domain keys: %w", err)
 	}
 
@@ -138,26 +149,62 @@ func generateMasterKey(ibeSystem *ibe.IBESystem, outputDir string) error {
 
 	log.Info().
 		Str("master_key_path", masterKeyPath).
-		Str("master_key_hash", hex.EncodeToString(ibeSystem.GetMasterSecret())).
 		Msg("Master key generated and saved")
 
 	return nil
 }
 
-// generateDomainKeys generates and saves domain-specific master keys
-func generateDomainKeys(ibeSystem *ibe.IBESystem, outputDir string) error {
-	domainKeysDir := filepath.Join(outputDir, "domains")
-	if err := os.MkdirAll(domainKeysDir, 0755); err != nil {
-		return fmt.Errorf("failed to create domain keys directory: %w", err)
+// newKeyProvider returns the key provider the domain masters are written to.
+// The dir provider writes to the domains subdirectory of the output directory;
+// the others take their settings from the options or the configuration.
+func newKeyProvider(cfg *config.IBEConfig, opts *IBEKeyOptions) (ibe.KeyProvider, error) {
+	if opts.KeyProvider == "" || opts.KeyProvider == ibe.KeyProviderDir {
+		return ibe.NewDirProvider(filepath.Join(opts.OutputDir, "domains"), opts.KeyVersion), nil
+	}
+
+	providerConfig := *cfg
+	providerConfig.KeyProvider = opts.KeyProvider
+	providerConfig.KeyVersion = opts.KeyVersion
+	if opts.KeystorePath != "" {
+		providerConfig.KeystorePath = opts.KeystorePath
+	}
+	if opts.KMSURL != "" {
+		providerConfig.KMSURL = opts.KMSURL
+	}
+	if opts.KMSKeyID != "" {
+		providerConfig.KMSKeyID = opts.KMSKeyID
 	}
+	return ibe.NewKeyProviderFromConfig(&providerConfig)
+}
 
-	// Save all domain masters
-	if err := ibeSystem.SaveDomainMastersToDir(domainKeysDir); err != nil {
+// generateDomainKeys saves the domain-specific master keys to a key provider.
+// A provider that already holds the key version must hold the same masters.
+func generateDomainKeys(ibeSystem *ibe.IBESystem, provider ibe.KeyProvider) error {
+	ctx := context.Background()
+	version := ibeSystem.GetKeyVersion()
+	domainMasters := ibeSystem.GetDomainMasters()
+
+	err := provider.Save(ctx, version, domainMasters)
+	if errors.Is(err, ibe.ErrKeyVersionExists) {
+		existing, loadErr := provider.Load(ctx, version)
+		if loadErr != nil {
+			return fmt.Errorf("failed to load existing key version %d: %w", version, loadErr)
+		}
+		for domain, master := range domainMasters {
+			if !bytes.Equal(existing[domain], master) {
+				return fmt.Errorf("%s already holds different domain masters for key version %d; use another key version or load them with --domain-keys-dir", provider.Name(), version)
+			}
+		}
+		log.Info().Str("key_provider", provider.Name()).Int("key_version", version).Msg("Domain keys already saved")
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to save domain keys: %w", err)
 	}
 
 	log.Info().
-		Str("domain_keys_dir", domainKeysDir).
+		Str("key_provider", provider.Name()).
+		Int("key_version", version).
 		Msg("Domain keys generated and saved")
 
 	return nil
@@ -199,7 +246,6 @@ func generateRoleKeys(ibeSystem *ibe.IBESystem, roles, scopes []string, timeWind
 					Str("scope", scope).
 					Str("time_window", timeWindowStr).
 					Str("key_path", keyPath).
-					Str("key_hash", hex.EncodeToString(roleKey)).
 					Msg("Role key generated and saved")
 			}
 		}
@@ -261,7 +307,6 @@ func generateTestKeys(ibeSystem *ibe.IBESystem, outputDir string) error {
 				Str("role", role).
 				Str("scope", scope).
 				Str("test_key_path", testKeyPath).
-				Str("test_key_hash", hex.EncodeToString(testKey)).
 				Msg("Test role key generated")
 		}
 	}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/ibe/rotation"
+	"github.com/stephenafamo/bob"
+)
+
+// IBERotationOptions defines the options for IBE master-key rotation
+type IBERotationOptions struct {
+	Resume     bool `doc:"Only continue a rotation in progress and retire old versions; do not start a new rotation" json:"resume"`
+	StatusOnly bool `doc:"Only report the key versions and rotations" json:"status"`
+	BatchSize  int  `doc:"Identity mappings re-encrypted per batch" json:"batch_size" default:"500"`
+}
+
+// IBERotation describes the outcome of a rotation run
+type IBERotation struct {
+	Started  int                // Key version started by this run; zero if none
+	Progress *rotation.Progress // Re-encryption done by this run; nil if none was in progress
+	Retired  []int              // Key versions retired by this run
+	Status   *rotation.Status
+}
+
+// RotateIBEKeys starts a rotation of the IBE domain masters unless one is
+// already in progress, re-encrypts identity mappings under the new key
+// version and retires previous versions whose grace period is over. Every
+// step is resumable; running it again continues where it stopped.
+func RotateIBEKeys(ctx context.Context, db bob.Executor, cfg *config.IBEConfig, opts *IBERotationOptions) (*IBERotation, error) {
+	provider, err := ibe.NewKeyProviderFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open IBE key provider: %w", err)
+	}
+	rotator := rotation.NewRotator(db, cfg, provider)
+	rotator.SetBatchSize(opts.BatchSize)
+	result := &IBERotation{}
+
+	if !opts.StatusOnly {
+		if !opts.Resume {
+			started, err := rotator.Start(ctx)
+			if err != nil && !errors.Is(err, rotation.ErrRotationInProgress) {
+				return nil, fmt.Errorf("failed to start rotation: %w", err)
+			}
+			if started != nil {
+				result.Started = started.KeyVersion
+			}
+		}
+
+		progress, err := rotator.Resume(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt identity mappings: %w", err)
+		}
+		result.Progress = progress
+
+		retired, err := rotator.Retire(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retire key versions: %w", err)
+		}
+		result.Retired = retired
+	}
+
+	status, err := rotator.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rotation status: %w", err)
+	}
+	result.Status = status
+	return result, nil
+}
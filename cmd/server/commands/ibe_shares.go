@@ -0,0 +1,293 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/rs/zerolog/log"
+)
+
+// IBEShareSplitOptions defines the options for splitting an IBE domain master
+// into Shamir shares
+type IBEShareSplitOptions struct {
+	Domain     string   `doc:"Domain whose master is split (admin_correlation_v1 or legal_correlation_v1)" json:"domain"`
+	Shares     int      `doc:"Number of shares" json:"shares" default:"5"`
+	Threshold  int      `doc:"Number of shares needed to reconstruct the master" json:"threshold" default:"3"`
+	OutputDir  string   `doc:"Directory the shares are written to (default: IBE_MASTER_SHARES_DIR)" json:"output_dir"`
+	KeyVersion int      `doc:"Key version whose master is split (default: the newest)" json:"key_version"`
+	KeepMaster bool     `doc:"Keep the master file after splitting; not allowed for the legal domain" json:"keep_master"`
+	FromShares []string `doc:"Re-split the master reconstructed from these share files instead of the master file" json:"from_shares"`
+}
+
+// IBEShareSplit describes the shares written by a split
+type IBEShareSplit struct {
+	Domain        string
+	KeyVersion    int
+	Threshold     int
+	Check         string   // Check value of the master
+	Paths         []string // Share files, in share order
+	Encrypted     int      // Number of shares encrypted with a passphrase
+	MasterRemoved string   // Key provider the master was removed from; empty if kept
+}
+
+// IBEShareUnlockOptions defines the options for reconstructing an IBE domain
+// master from shares
+type IBEShareUnlockOptions struct {
+	Domain     string   `doc:"Domain whose master is reconstructed" json:"domain"`
+	KeyVersion int      `doc:"Key version of the master (default: the newest)" json:"key_version"`
+	SharesDir  string   `doc:"Directory holding the shares (default: IBE_MASTER_SHARES_DIR)" json:"shares_dir"`
+	Shares     []string `doc:"Share files to use instead of the shares directory" json:"shares"`
+}
+
+// IBEShareUnlock describes a reconstructed domain master
+type IBEShareUnlock struct {
+	Domain     string
+	KeyVersion int
+	SharesUsed int
+	Threshold  int
+	Check      string // Check value of the master
+}
+
+// SplitIBEMaster splits the master of a domain into k-of-n shares and, unless
+// it is kept, removes the master from the key provider once the written
+// shares have been verified. The master of the legal correlation domain is always removed, so
+// that no single operator can unlock it. Shares for which passphrase returns
+// a non-empty passphrase are encrypted with it; sharePassphrase decrypts the
+// shares a re-split starts from.
+func SplitIBEMaster(ctx context.Context, cfg *config.IBEConfig, provider ibe.KeyProvider, opts *IBEShareSplitOptions, passphrase, sharePassphrase ibe.PassphraseFunc) (*IBEShareSplit, error) {
+	if !ibe.IsSplittableDomain(opts.Domain) {
+		return nil, fmt.Errorf("%w: %q (splittable domains: %v)", ibe.ErrDomainNotSplittable, opts.Domain, ibe.SplittableDomains())
+	}
+	if opts.Domain == ibe.DOMAIN_LEGAL_CORRELATION && opts.KeepMaster {
+		return nil, fmt.Errorf("the master of %s cannot be kept after it is split", opts.Domain)
+	}
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = cfg.SharesDir
+	}
+	if outputDir == "" {
+		return nil, fmt.Errorf("an output directory or IBE_MASTER_SHARES_DIR is required")
+	}
+	keyVersion, err := resolveKeyVersion(ctx, provider, opts.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := ibe.FindShares(outputDir, opts.Domain, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("%s already holds shares of %s key version %d; move them away before splitting again", outputDir, opts.Domain, keyVersion)
+	}
+
+	// The master comes from the key provider, or from a quorum of the current
+	// shares when they are handed to new holders
+	var master []byte
+	if len(opts.FromShares) > 0 {
+		current, err := readShares(opts.FromShares, opts.Domain, keyVersion, sharePassphrase)
+		if err != nil {
+			return nil, err
+		}
+		if master, err = ibe.CombineDomainMaster(current); err != nil {
+			return nil, err
+		}
+	} else {
+		domainMasters, err := provider.Load(ctx, keyVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load domain masters of key version %d: %w", keyVersion, err)
+		}
+		for domain, domainMaster := range domainMasters {
+			if domain != opts.Domain {
+				clear(domainMaster)
+			}
+		}
+		if master = domainMasters[opts.Domain]; master == nil {
+			return nil, fmt.Errorf("%s has no master for %s in key version %d; reconstruct it from its shares with --from-share", provider.Name(), opts.Domain, keyVersion)
+		}
+	}
+	defer clear(master)
+
+	shares, err := ibe.SplitDomainMaster(opts.Domain, keyVersion, master, opts.Shares, opts.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	// Any threshold of the shares must reconstruct the master before the
+	// master file may go
+	for _, quorum := range [][]*ibe.MasterShare{shares[:opts.Threshold], shares[len(shares)-opts.Threshold:]} {
+		combined, err := ibe.CombineDomainMaster(quorum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify shares: %w", err)
+		}
+		clear(combined)
+	}
+
+	result := &IBEShareSplit{
+		Domain:     opts.Domain,
+		KeyVersion: keyVersion,
+		Threshold:  opts.Threshold,
+		Check:      shares[0].Check,
+	}
+	for _, share := range shares {
+		if passphrase != nil {
+			secret, err := passphrase(share)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get passphrase for %s: %w", share.Label(), err)
+			}
+			if secret != "" {
+				if err := share.Encrypt(secret); err != nil {
+					return nil, fmt.Errorf("failed to encrypt %s: %w", share.Label(), err)
+				}
+				result.Encrypted++
+			}
+		}
+	}
+
+	for _, share := range shares {
+		path, err := ibe.WriteShare(outputDir, share)
+		if err != nil {
+			removeShares(result.Paths)
+			return nil, err
+		}
+		result.Paths = append(result.Paths, path)
+		if err := verifyShareFile(path, share); err != nil {
+			removeShares(result.Paths)
+			return nil, err
+		}
+	}
+
+	if !opts.KeepMaster && len(opts.FromShares) == 0 {
+		if err := provider.RemoveDomain(ctx, keyVersion, opts.Domain); err != nil {
+			return nil, fmt.Errorf("shares written, but failed to remove the domain master from %s: %w", provider.Name(), err)
+		}
+		result.MasterRemoved = provider.Name()
+	}
+
+	log.Info().
+		Str("domain", opts.Domain).
+		Int("key_version", keyVersion).
+		Int("shares", opts.Shares).
+		Int("threshold", opts.Threshold).
+		Str("output_dir", outputDir).
+		Msg("Split IBE domain master into shares")
+	return result, nil
+}
+
+// UnlockIBEMaster reconstructs the master of a domain from its shares in
+// memory and verifies it against the check value of the shares. Nothing is
+// written; it lets share holders confirm that a quorum can unlock the domain.
+func UnlockIBEMaster(ctx context.Context, cfg *config.IBEConfig, provider ibe.KeyProvider, opts *IBEShareUnlockOptions, passphrase ibe.PassphraseFunc) (*IBEShareUnlock, error) {
+	if !ibe.IsSplittableDomain(opts.Domain) {
+		return nil, fmt.Errorf("%w: %q (splittable domains: %v)", ibe.ErrDomainNotSplittable, opts.Domain, ibe.SplittableDomains())
+	}
+	keyVersion, err := resolveKeyVersion(ctx, provider, opts.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := opts.Shares
+	if len(paths) == 0 {
+		sharesDir := opts.SharesDir
+		if sharesDir == "" {
+			sharesDir = cfg.SharesDir
+		}
+		if sharesDir == "" {
+			return nil, fmt.Errorf("share files, a shares directory or IBE_MASTER_SHARES_DIR is required")
+		}
+		if paths, err = ibe.FindShares(sharesDir, opts.Domain, keyVersion); err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no shares of %s key version %d in %s", opts.Domain, keyVersion, sharesDir)
+		}
+	}
+
+	shares, err := readShares(paths, opts.Domain, keyVersion, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := ibe.CombineDomainMaster(shares)
+	if err != nil {
+		return nil, err
+	}
+	clear(master)
+
+	return &IBEShareUnlock{
+		Domain:     opts.Domain,
+		KeyVersion: keyVersion,
+		SharesUsed: len(shares),
+		Threshold:  shares[0].Threshold,
+		Check:      shares[0].Check,
+	}, nil
+}
+
+// resolveKeyVersion returns the key version to use, defaulting to the newest
+// one of the key provider
+func resolveKeyVersion(ctx context.Context, provider ibe.KeyProvider, keyVersion int) (int, error) {
+	if keyVersion != 0 {
+		return keyVersion, nil
+	}
+	versions, err := provider.Versions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, fmt.Errorf("no IBE key versions in %s", provider.Name())
+	}
+	return versions[len(versions)-1], nil
+}
+
+// readShares reads and decrypts share files of a domain master
+func readShares(paths []string, domain string, keyVersion int, passphrase ibe.PassphraseFunc) ([]*ibe.MasterShare, error) {
+	var shares []*ibe.MasterShare
+	for _, path := range paths {
+		share, err := ibe.ReadShare(path)
+		if err != nil {
+			return nil, err
+		}
+		if share.Domain != domain || share.KeyVersion != keyVersion {
+			return nil, fmt.Errorf("%s is a share of %s key version %d, not %s key version %d", path, share.Domain, share.KeyVersion, domain, keyVersion)
+		}
+		if share.Encrypted != nil {
+			if passphrase == nil {
+				return nil, fmt.Errorf("%w: %s", ibe.ErrShareEncrypted, path)
+			}
+			secret, err := passphrase(share)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get passphrase for %s: %w", share.Label(), err)
+			}
+			if err := share.Decrypt(secret); err != nil {
+				return nil, err
+			}
+		}
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+// verifyShareFile checks that a written share file reads back as the share
+func verifyShareFile(path string, share *ibe.MasterShare) error {
+	written, err := ibe.ReadShare(path)
+	if err != nil {
+		return err
+	}
+	if written.Index != share.Index || written.Check != share.Check || written.Share != share.Share ||
+		(written.Encrypted == nil) != (share.Encrypted == nil) ||
+		(written.Encrypted != nil && *written.Encrypted != *share.Encrypted) {
+		return fmt.Errorf("share file %s does not match %s", path, share.Label())
+	}
+	return nil
+}
+
+// removeShares deletes share files of a failed split
+func removeShares(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Error().Err(err).Str("path", path).Msg("Failed to remove share file")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/rs/zerolog/log"
+)
+
+// JWTKeyOptions defines the options for JWT signing key rotation
+type JWTKeyOptions struct {
+	KeysDir    string        `doc:"Directory holding the JWT signing key set" json:"keys_dir"`
+	Algorithm  string        `doc:"Signing algorithm for the new key (EdDSA or ES256)" json:"algorithm" default:"EdDSA"`
+	ActivateIn time.Duration `doc:"How long the new key is published before it starts signing" json:"activate_in" default:"1h"`
+	Overlap    time.Duration `doc:"How long previous keys keep verifying after the new key activates" json:"overlap" default:"168h"`
+}
+
+// JWTKeyRotation describes the outcome of a rotation
+type JWTKeyRotation struct {
+	NewKey  *jwtkeys.Key
+	Retired []*jwtkeys.Key // keys whose retirement was scheduled by this rotation
+	Pruned  []*jwtkeys.Key // keys past retirement that were deleted
+}
+
+// RotateJWTKeys adds a new signing key to the key set on disk, scheduling the
+// retirement of the current keys and deleting keys that have already retired.
+// If no key set exists yet, one is created with a key that is active immediately.
+func RotateJWTKeys(opts *JWTKeyOptions) (*JWTKeyRotation, error) {
+	if opts.ActivateIn < 0 || opts.Overlap < 0 {
+		return nil, fmt.Errorf("activation delay and overlap must not be negative")
+	}
+
+	now := time.Now().UTC()
+
+	signingKeys, err := jwtkeys.LoadKeySet(opts.KeysDir)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info().Str("keys_dir", opts.KeysDir).Msg("No JWT key set found, creating one")
+
+		signingKeys, err = jwtkeys.GenerateKeySet(opts.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key set: %w", err)
+		}
+		if err := signingKeys.Save(opts.KeysDir); err != nil {
+			return nil, err
+		}
+		return &JWTKeyRotation{NewKey: signingKeys.Keys()[0]}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key set: %w", err)
+	}
+
+	rotation := &JWTKeyRotation{Pruned: signingKeys.Prune(now)}
+	for _, key := range signingKeys.Keys() {
+		if key.RetiresAt.IsZero() {
+			rotation.Retired = append(rotation.Retired, key)
+		}
+	}
+
+	rotation.NewKey, err = signingKeys.Rotate(opts.Algorithm, opts.ActivateIn, opts.Overlap, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate key set: %w", err)
+	}
+
+	// Write the new manifest before deleting pruned key files so that a
+	// failure never leaves the manifest pointing at missing keys
+	if err := signingKeys.Save(opts.KeysDir); err != nil {
+		return nil, err
+	}
+	for _, key := range rotation.Pruned {
+		if err := jwtkeys.RemoveKeyFile(opts.KeysDir, key.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info().
+		Str("kid", rotation.NewKey.ID).
+		Str("algorithm", rotation.NewKey.Algorithm).
+		Time("activates_at", rotation.NewKey.ActivatesAt).
+		Int("retiring", len(rotation.Retired)).
+		Int("pruned", len(rotation.Pruned)).
+		Msg("Rotated JWT signing keys")
+	return rotation, nil
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/rs/zerolog/log"
+)
+
+// KMSStandInOptions defines the options for the local KMS stand-in
+type KMSStandInOptions struct {
+	Listen  string `doc:"Address to listen on: host:port or unix:///path/to/socket" json:"listen"`
+	KeyFile string `doc:"File holding the hex-encoded wrapping key; created if missing" json:"key_file"`
+	KeyID   string `doc:"ID of the wrapping key" json:"key_id"`
+	Token   string `doc:"Bearer token clients must send (optional)" json:"token"`
+}
+
+// ServeKMSStandIn serves a local stand-in for a KMS, wrapping and unwrapping
+// keys for the kms key provider, until ctx is done. It lets the kms provider
+// be used and tested without a real KMS.
+func ServeKMSStandIn(ctx context.Context, opts *KMSStandInOptions) error {
+	key, err := loadStandInKey(opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	standIn, err := ibe.NewKMSStandIn(map[string][]byte{opts.KeyID: key}, opts.Token)
+	clear(key)
+	if err != nil {
+		return err
+	}
+
+	listener, err := listenStandIn(opts.Listen)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: standIn, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("listen", opts.Listen).Str("key_id", opts.KeyID).Msg("KMS stand-in listening")
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("KMS stand-in failed: %w", err)
+	}
+	return nil
+}
+
+// loadStandInKey reads the wrapping key of the stand-in, generating it on
+// first use
+func loadStandInKey(path string) ([]byte, error) {
+	key, err := ibe.LoadMasterSecretFromFile(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate wrapping key: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wrapping key file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to write wrapping key file: %w", err)
+	}
+
+	log.Warn().Str("key_file", path).Msg("Generated a new KMS stand-in wrapping key")
+	return key, nil
+}
+
+// listenStandIn listens on a TCP address or a unix socket
+func listenStandIn(address string) (net.Listener, error) {
+	socket, ok := strings.CutPrefix(address, "unix://")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	// Remove the socket of a previous run
+	if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return listener, nil
+}
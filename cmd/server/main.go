@@ -2,13 +2,13 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"syscall"
 	"time"
@@ -17,11 +17,17 @@ import (
 	"github.com/matt0x6f/hashpost/cmd/server/commands"
 	"github.com/matt0x6f/hashpost/internal/api"
 	"github.com/matt0x6f/hashpost/internal/api/logger"
+	"github.com/matt0x6f/hashpost/internal/auditchain"
 	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/database"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/ibe/rotation"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/matt0x6f/hashpost/internal/netprivacy"
+	"github.com/matt0x6f/hashpost/internal/password"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/stephenafamo/bob/types"
@@ -58,10 +64,6 @@ func main() {
 	// Initialize the logger with the configured level
 	logger.InitWithLevel(cfg.Logging.Level)
 
-	// Initialize IBE system and identity mapping DAO
-	ibeSystem := ibe.NewIBESystemFromEnv()
-	log.Info().Str("ibe_master_key", hex.EncodeToString(ibeSystem.GetMasterSecret())).Str("ibe_salt", ibeSystem.GetSalt()).Int("ibe_key_version", ibeSystem.GetKeyVersion()).Msg("IBE system configuration (CLI/server startup)")
-
 	// Create the CLI
 	cli := humacli.New(func(hooks humacli.Hooks, opts *Options) {
 		// Create the API server with all middleware and routes
@@ -155,9 +157,173 @@ func main() {
 	generateIBEKeysCmd.Flags().String("scopes", "", "Comma-separated list of scopes to generate keys for")
 	generateIBEKeysCmd.Flags().Bool("non-interactive", false, "Non-interactive mode")
 	generateIBEKeysCmd.Flags().Int("key-size", 32, "Key size in bytes (default 32, i.e., 256 bits)")
+	generateIBEKeysCmd.Flags().String("key-provider", ibe.KeyProviderDir, "Key provider the domain masters are written to (dir, env, keystore or kms)")
+	generateIBEKeysCmd.Flags().String("keystore-path", "", "Keystore file of the keystore and kms providers (default: IBE_KEYSTORE_PATH)")
+	generateIBEKeysCmd.Flags().String("kms-url", "", "KMS of the kms provider: http(s):// URL or unix:///path/to/socket (default: IBE_KMS_URL)")
+	generateIBEKeysCmd.Flags().String("kms-key-id", "", "KMS key wrapping the domain masters (default: IBE_KMS_KEY_ID)")
 
 	cli.Root().AddCommand(generateIBEKeysCmd)
 
+	// Add rotate-jwt-keys subcommand
+	rotateJWTKeysCmd := &cobra.Command{
+		Use:   "rotate-jwt-keys",
+		Short: "Generate and rotate JWT signing keys",
+		Long:  "Add a new JWT signing key to the key set on disk, schedule the retirement of the current keys and delete retired keys. Creates the key set if it does not exist.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			rotateJWTKeys(options)
+		}),
+	}
+
+	// Add flags for rotate-jwt-keys command
+	rotateJWTKeysCmd.Flags().String("keys-dir", "", "Directory holding the JWT signing key set (default: JWT_KEYS_DIR)")
+	rotateJWTKeysCmd.Flags().String("alg", "EdDSA", "Signing algorithm for the new key (EdDSA or ES256)")
+	rotateJWTKeysCmd.Flags().Duration("activate-in", time.Hour, "How long the new key is published before it starts signing")
+	rotateJWTKeysCmd.Flags().Duration("overlap", 7*24*time.Hour, "How long previous keys keep verifying after the new key activates")
+
+	cli.Root().AddCommand(rotateJWTKeysCmd)
+
+	// Add rotate-ibe-keys subcommand
+	rotateIBEKeysCmd := &cobra.Command{
+		Use:   "rotate-ibe-keys",
+		Short: "Rotate the IBE domain master keys",
+		Long:  "Generate new IBE domain masters under the next key version, re-encrypt identity mappings under it in resumable batches and retire previous versions once their grace period is over. Run it again to continue an interrupted rotation. Progress is recorded in the system event log.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			rotateIBEKeys(options)
+		}),
+	}
+
+	// Add flags for rotate-ibe-keys command
+	rotateIBEKeysCmd.Flags().Bool("resume", false, "Only continue a rotation in progress and retire old versions")
+	rotateIBEKeysCmd.Flags().Bool("status", false, "Only report the key versions and rotations")
+	rotateIBEKeysCmd.Flags().Int("batch-size", rotation.DefaultBatchSize, "Identity mappings re-encrypted per batch")
+
+	cli.Root().AddCommand(rotateIBEKeysCmd)
+
+	// Add split-ibe-master subcommand
+	splitIBEMasterCmd := &cobra.Command{
+		Use:   "split-ibe-master",
+		Short: "Split an IBE domain master into Shamir shares",
+		Long:  "Split the master of the admin or legal correlation domain into k-of-n Shamir shares, each optionally encrypted with a passphrase of its holder, and remove the master file once the shares are verified. The legal domain master is always removed.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			splitIBEMaster(options)
+		}),
+	}
+
+	// Add flags for split-ibe-master command
+	splitIBEMasterCmd.Flags().String("domain", ibe.DOMAIN_LEGAL_CORRELATION, "Domain whose master is split (admin_correlation_v1 or legal_correlation_v1)")
+	splitIBEMasterCmd.Flags().Int("shares", 5, "Number of shares")
+	splitIBEMasterCmd.Flags().Int("threshold", 3, "Number of shares needed to reconstruct the master")
+	splitIBEMasterCmd.Flags().String("output-dir", "", "Directory the shares are written to (default: IBE_MASTER_SHARES_DIR)")
+	splitIBEMasterCmd.Flags().Int("key-version", 0, "Key version whose master is split (default: the newest)")
+	splitIBEMasterCmd.Flags().Bool("encrypt", false, "Prompt for a passphrase for each share")
+	splitIBEMasterCmd.Flags().Bool("keep-master", false, "Keep the master file after splitting (not allowed for the legal domain)")
+	splitIBEMasterCmd.Flags().StringSlice("from-share", nil, "Re-split the master reconstructed from these share files (repeatable)")
+
+	cli.Root().AddCommand(splitIBEMasterCmd)
+
+	// Add unlock-ibe-master subcommand
+	unlockIBEMasterCmd := &cobra.Command{
+		Use:   "unlock-ibe-master",
+		Short: "Reconstruct an IBE domain master from its shares",
+		Long:  "Reconstruct the master of a split IBE domain in memory from a quorum of its shares and verify it. Nothing is written to disk; servers unlock split domains at startup from IBE_MASTER_SHARES_DIR.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			unlockIBEMaster(options)
+		}),
+	}
+
+	// Add flags for unlock-ibe-master command
+	unlockIBEMasterCmd.Flags().String("domain", ibe.DOMAIN_LEGAL_CORRELATION, "Domain whose master is reconstructed")
+	unlockIBEMasterCmd.Flags().Int("key-version", 0, "Key version of the master (default: the newest)")
+	unlockIBEMasterCmd.Flags().String("shares-dir", "", "Directory holding the shares (default: IBE_MASTER_SHARES_DIR)")
+	unlockIBEMasterCmd.Flags().StringSlice("share", nil, "Share file to use instead of the shares directory (repeatable)")
+
+	cli.Root().AddCommand(unlockIBEMasterCmd)
+
+	// Add kms-standin subcommand
+	kmsStandInCmd := &cobra.Command{
+		Use:   "kms-standin",
+		Short: "Run a local KMS stand-in for the kms key provider",
+		Long:  "Serve a local stand-in for a key management service over HTTP or a unix socket. It wraps and unwraps IBE domain masters for IBE_KEY_PROVIDER=kms with a key kept in a file, for development and offline testing.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			kmsStandIn(options)
+		}),
+	}
+
+	// Add flags for kms-standin command
+	kmsStandInCmd.Flags().String("listen", "", "Address to listen on: host:port or unix:///path/to/socket (default: IBE_KMS_URL)")
+	kmsStandInCmd.Flags().String("key-file", "./keys/kms-standin.key", "File holding the wrapping key; created if missing")
+	kmsStandInCmd.Flags().String("key-id", "", "ID of the wrapping key (default: IBE_KMS_KEY_ID)")
+
+	cli.Root().AddCommand(kmsStandInCmd)
+
+	// Add unlock-login subcommand
+	unlockLoginCmd := &cobra.Command{
+		Use:   "unlock-login",
+		Short: "Lift a login lockout",
+		Long:  "Clear the failed login counters of an account or a client address, lifting any lockout or backoff on it. The unlock is recorded in the system event log.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			unlockLogin(options)
+		}),
+	}
+
+	// Add flags for unlock-login command
+	unlockLoginCmd.Flags().String("email", "", "Email address of the locked account")
+	unlockLoginCmd.Flags().String("ip", "", "Blocked client IP address")
+	unlockLoginCmd.Flags().String("reason", "", "Why the lockout is lifted")
+
+	cli.Root().AddCommand(unlockLoginCmd)
+
+	// Add verify-audit subcommand
+	verifyAuditCmd := &cobra.Command{
+		Use:   "verify-audit",
+		Short: "Verify the hash-chained audit logs",
+		Long:  "Recompute the correlation and key usage audit chains from their first entry, check them against their signed checkpoints and report the first break of each. Exits with status 1 if a chain is broken.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			verifyAudit(options)
+		}),
+	}
+
+	// Add flags for verify-audit command
+	verifyAuditCmd.Flags().String("public-key", "", "Public key checkpoints are verified with (default: AUDIT_CHECKPOINT_KEY_FILE with a .pub suffix)")
+	verifyAuditCmd.Flags().Bool("checkpoint", false, "Sign a checkpoint of each chain that verifies")
+
+	cli.Root().AddCommand(verifyAuditCmd)
+
+	// Add export-disclosure subcommand
+	exportDisclosureCmd := &cobra.Command{
+		Use:   "export-disclosure",
+		Short: "Export the disclosure bundle of a compliance case",
+		Long:  "Write a signed archive of a compliance case in progress or completed: the case, its linked correlations with their results and audit entries, and the posts and comments of the correlated pseudonyms. The archive can be encrypted to the recipient's X25519 public key. The export is recorded in the history of the case.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			exportDisclosure(options)
+		}),
+	}
+
+	// Add flags for export-disclosure command
+	exportDisclosureCmd.Flags().String("report-id", "", "Compliance report whose bundle is exported")
+	exportDisclosureCmd.Flags().String("output", "", "File the bundle is written to (default: derived from the report and bundle IDs)")
+	exportDisclosureCmd.Flags().String("recipient-key", "", "PEM X25519 public key of the recipient to encrypt the bundle to")
+
+	cli.Root().AddCommand(exportDisclosureCmd)
+
+	// Add verify-disclosure subcommand
+	verifyDisclosureCmd := &cobra.Command{
+		Use:   "verify-disclosure",
+		Short: "Verify a received disclosure bundle",
+		Long:  "Decrypt a disclosure bundle if it is encrypted, check the platform's signature on its manifest and the hash of every file, and optionally extract the verified files. Exits with status 1 if the bundle does not verify. Needs no database.",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *Options) {
+			verifyDisclosure(options)
+		}),
+	}
+
+	// Add flags for verify-disclosure command
+	verifyDisclosureCmd.Flags().String("bundle", "", "Bundle to verify")
+	verifyDisclosureCmd.Flags().String("public-key", "", "Public key of the platform (default: AUDIT_DISCLOSURE_KEY_FILE with a .pub suffix)")
+	verifyDisclosureCmd.Flags().String("recipient-private-key", "", "PEM X25519 private key the bundle was encrypted to")
+	verifyDisclosureCmd.Flags().String("extract", "", "Directory the verified files are written to")
+
+	cli.Root().AddCommand(verifyDisclosureCmd)
+
 	// Add openapi subcommand
 	cli.Root().AddCommand(&cobra.Command{
 		Use:   "openapi",
@@ -221,7 +387,7 @@ func createAdminUser(opts *Options) {
 
 	if existingUser != nil {
 		// User exists - update them with admin role and capabilities
-		log.Info().Str("email", input.Email).Msg("User already exists, updating with admin role")
+		log.Info().Int64("user_id", existingUser.UserID).Msg("User already exists, updating with admin role")
 
 		// Hash the password if provided
 		var passwordHash string
@@ -289,7 +455,7 @@ func createAdminUser(opts *Options) {
 		log.Info().Int64("user_id", user.UserID).Msg("User updated with admin role")
 	} else {
 		// User doesn't exist - create new user
-		log.Info().Str("email", input.Email).Msg("Creating new admin user")
+		log.Info().Msg("Creating new admin user")
 
 		// Hash the password
 		passwordHash := hashPassword(input.Password)
@@ -396,7 +562,6 @@ func createAdminUser(opts *Options) {
 
 	log.Info().
 		Int64("user_id", user.UserID).
-		Str("email", input.Email).
 		Str("admin_username", adminUsername).
 		Str("role", input.AdminRole).
 		Bool("mfa_enabled", input.MFAEnabled).
@@ -420,6 +585,75 @@ func createAdminUser(opts *Options) {
 	if input.AdminScope != "" {
 		fmt.Printf("   Admin Scope: %s\n", input.AdminScope)
 	}
+
+	// Provision a TOTP authenticator so the admin can complete the MFA login step
+	alreadyEnrolled := user.MfaEnabled.Valid && user.MfaEnabled.V && user.MfaSecret.Valid && user.MfaSecret.V != ""
+	if input.MFAEnabled && !alreadyEnrolled {
+		mfaSecrets, err := loadMFASecretBox(cfg.Security.MFAKeyFile)
+		if err != nil {
+			log.Fatal().Err(err).Str("key_file", cfg.Security.MFAKeyFile).Msg("Failed to load MFA secret key")
+		}
+		enrollAdminMFA(ctx, dao.NewMFADAO(db), mfaSecrets, user.UserID, input.Email, cfg.Security.MFAIssuer)
+	}
+}
+
+// enrollAdminMFA generates a TOTP secret and recovery codes for a new admin
+// and prints them once so they can be added to an authenticator app
+func enrollAdminMFA(ctx context.Context, mfaDAO *dao.MFADAO, mfaSecrets *mfa.SecretBox, userID int64, email, issuer string) {
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate TOTP secret")
+	}
+	sealed, err := mfaSecrets.Seal(userID, secret)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to encrypt TOTP secret")
+	}
+	if err := mfaDAO.SetPendingSecret(ctx, userID, sealed); err != nil {
+		log.Fatal().Err(err).Msg("Failed to store TOTP secret")
+	}
+	if err := mfaDAO.EnableMFA(ctx, userID); err != nil {
+		log.Fatal().Err(err).Msg("Failed to enable MFA")
+	}
+
+	codes, err := mfa.GenerateRecoveryCodes(mfa.RecoveryCodeCount)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate recovery codes")
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = mfa.HashRecoveryCode(code)
+	}
+	if err := mfaDAO.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		log.Fatal().Err(err).Msg("Failed to store recovery codes")
+	}
+
+	fmt.Println()
+	fmt.Println("🔐 MFA enrollment (shown only once):")
+	fmt.Printf("   TOTP Secret: %s\n", secret)
+	fmt.Printf("   otpauth URI: %s\n", mfa.ProvisioningURI(issuer, email, secret))
+	fmt.Println("   Recovery Codes:")
+	for _, code := range codes {
+		fmt.Printf("     %s\n", code)
+	}
+}
+
+// loadMFASecretBox loads the key TOTP secrets are encrypted with. A missing
+// key is generated, as the server would on its first start.
+func loadMFASecretBox(path string) (*mfa.SecretBox, error) {
+	box, err := mfa.LoadSecretBox(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return box, err
+	}
+
+	box, err = mfa.GenerateSecretBox()
+	if err != nil {
+		return nil, err
+	}
+	if err := box.Save(path); err != nil {
+		return nil, err
+	}
+	log.Warn().Str("key_file", path).Msg("Generated a new MFA secret key; back it up, as TOTP secrets cannot be decrypted without it")
+	return box, nil
 }
 
 // getAdminCreateInput prompts for admin user creation input
@@ -581,10 +815,13 @@ func validateAdminInput(input *AdminCreateInput) error {
 	return nil
 }
 
-// hashPassword hashes a password using SHA-256
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
+// hashPassword hashes a password using argon2id
+func hashPassword(plaintext string) string {
+	hash, err := password.Hash(plaintext)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to hash password")
+	}
+	return hash
 }
 
 // getCapabilitiesForRole returns the capabilities for a given admin role
@@ -749,8 +986,12 @@ func setupRoles(opts *Options) {
 			// Create the role key
 			expiresAt := time.Now().AddDate(1, 0, 0) // Expire in 1 year
 			keyData := ibeSystem.GenerateTestRoleKey(adminRole.roleName, scope)
+			if keyData == nil {
+				log.Warn().Str("role", adminRole.roleName).Str("scope", scope).Msg("IBE domain of the role is locked; unlock it from its shares to create this key")
+				continue
+			}
 
-			_, err = roleKeyDAO.CreateRoleKey(ctx, adminRole.roleName, scope, keyData, capabilities, expiresAt, creatorUserID)
+			_, err = roleKeyDAO.CreateRoleKeyVersion(ctx, adminRole.roleName, scope, keyData, int32(ibeSystem.GetKeyVersion()), capabilities, expiresAt, creatorUserID)
 			if err != nil {
 				log.Error().Str("role", adminRole.roleName).Str("scope", scope).Err(err).Msg("Failed to create role key")
 				continue
@@ -795,6 +1036,10 @@ func generateIBEKeys(opts *Options) {
 	cmd.Flags().String("scopes", "", "")
 	cmd.Flags().Bool("non-interactive", false, "")
 	cmd.Flags().Int("key-size", 32, "Key size in bytes (default 32, i.e., 256 bits)")
+	cmd.Flags().String("key-provider", ibe.KeyProviderDir, "")
+	cmd.Flags().String("keystore-path", "", "")
+	cmd.Flags().String("kms-url", "", "")
+	cmd.Flags().String("kms-key-id", "", "")
 
 	// Parse flags from os.Args
 	cmd.ParseFlags(os.Args[1:])
@@ -811,6 +1056,10 @@ func generateIBEKeys(opts *Options) {
 	scopes, _ := cmd.Flags().GetString("scopes")
 	nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
 	keySize, _ := cmd.Flags().GetInt("key-size")
+	keyProvider, _ := cmd.Flags().GetString("key-provider")
+	keystorePath, _ := cmd.Flags().GetString("keystore-path")
+	kmsURL, _ := cmd.Flags().GetString("kms-url")
+	kmsKeyID, _ := cmd.Flags().GetString("kms-key-id")
 
 	// Create IBE key options
 	ibeOptions := &commands.IBEKeyOptions{
@@ -825,6 +1074,10 @@ func generateIBEKeys(opts *Options) {
 		Scopes:         scopes,
 		NonInteractive: nonInteractive,
 		KeySize:        keySize,
+		KeyProvider:    keyProvider,
+		KeystorePath:   keystorePath,
+		KMSURL:         kmsURL,
+		KMSKeyID:       kmsKeyID,
 	}
 
 	// Generate IBE keys
@@ -836,6 +1089,7 @@ func generateIBEKeys(opts *Options) {
 	fmt.Printf("   Output directory: %s\n", outputDir)
 	fmt.Printf("   Key version: %d\n", keyVersion)
 	fmt.Printf("   Salt: %s\n", salt)
+	fmt.Printf("   Key provider: %s\n", keyProvider)
 	if generateNew {
 		fmt.Println("   Generated new domain keys")
 	}
@@ -843,3 +1097,517 @@ func generateIBEKeys(opts *Options) {
 		fmt.Printf("   Used existing domain keys: %s\n", domainKeysDir)
 	}
 }
+
+// rotateJWTKeys generates a new JWT signing key and rotates the key set on disk
+func rotateJWTKeys(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("keys-dir", "", "")
+	cmd.Flags().String("alg", "EdDSA", "")
+	cmd.Flags().Duration("activate-in", time.Hour, "")
+	cmd.Flags().Duration("overlap", 7*24*time.Hour, "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	keysDir, _ := cmd.Flags().GetString("keys-dir")
+	algorithm, _ := cmd.Flags().GetString("alg")
+	activateIn, _ := cmd.Flags().GetDuration("activate-in")
+	overlap, _ := cmd.Flags().GetDuration("overlap")
+
+	if keysDir == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load configuration")
+		}
+		keysDir = cfg.JWT.KeysDir
+	}
+
+	rotation, err := commands.RotateJWTKeys(&commands.JWTKeyOptions{
+		KeysDir:    keysDir,
+		Algorithm:  algorithm,
+		ActivateIn: activateIn,
+		Overlap:    overlap,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to rotate JWT signing keys")
+	}
+
+	fmt.Println("✅ JWT signing keys rotated successfully!")
+	fmt.Printf("   Keys directory: %s\n", keysDir)
+	fmt.Printf("   New key: %s (%s)\n", rotation.NewKey.ID, rotation.NewKey.Algorithm)
+	fmt.Printf("   Starts signing: %s\n", rotation.NewKey.ActivatesAt.Format(time.RFC3339))
+	for _, key := range rotation.Retired {
+		fmt.Printf("   Retiring: %s at %s\n", key.ID, key.RetiresAt.Format(time.RFC3339))
+	}
+	for _, key := range rotation.Pruned {
+		fmt.Printf("   Deleted retired key: %s\n", key.ID)
+	}
+	fmt.Println("   Restart the servers to publish the new key.")
+}
+
+// rotateIBEKeys rotates the IBE domain masters and reports the rotation status
+func rotateIBEKeys(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().Bool("resume", false, "")
+	cmd.Flags().Bool("status", false, "")
+	cmd.Flags().Int("batch-size", rotation.DefaultBatchSize, "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	resume, _ := cmd.Flags().GetBool("resume")
+	statusOnly, _ := cmd.Flags().GetBool("status")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	result, err := commands.RotateIBEKeys(context.Background(), db, &cfg.IBE, &commands.IBERotationOptions{
+		Resume:     resume,
+		StatusOnly: statusOnly,
+		BatchSize:  batchSize,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to rotate IBE keys")
+	}
+
+	if result.Started != 0 {
+		fmt.Printf("✅ Started rotation to key version %d\n", result.Started)
+	}
+	if result.Progress != nil {
+		fmt.Printf("   Re-encrypted %d identity mappings under key version %d\n", result.Progress.Reencrypted, result.Progress.KeyVersion)
+		if result.Progress.Failed > 0 {
+			fmt.Printf("   ⚠️  %d identity mappings could not be decrypted by any active role key\n", result.Progress.Failed)
+		}
+		if result.Progress.Complete {
+			fmt.Printf("   Re-encryption complete; grace period of %s started\n", cfg.IBE.KeyRotation.GracePeriod)
+		}
+	}
+	for _, version := range result.Retired {
+		fmt.Printf("   Retired key version %d\n", version)
+	}
+
+	status := result.Status
+	fmt.Printf("IBE key versions (%s)\n", status.Provider)
+	fmt.Printf("   Current version: %d\n", status.CurrentVersion)
+	for _, version := range status.Versions {
+		fmt.Printf("   Version %d: %d identity mappings\n", version, status.Mappings[int32(version)])
+	}
+	for _, rot := range status.Rotations {
+		fmt.Printf("   Rotation %d -> %d: %s, %d re-encrypted, %d failed, started %s\n",
+			rot.PreviousVersion, rot.KeyVersion, rot.Status, rot.MappingsReencrypted, rot.MappingsFailed, rot.StartedAt.Format(time.RFC3339))
+	}
+	if cfg.IBE.KeyRotation.Enabled {
+		fmt.Printf("   Scheduled rotation: every %s, grace period %s\n", cfg.IBE.KeyRotation.Interval, cfg.IBE.KeyRotation.GracePeriod)
+	}
+	if result.Started != 0 {
+		fmt.Println("   Restart the servers so that role keys for new users are derived from the new version.")
+	}
+}
+
+// splitIBEMaster splits an IBE domain master into Shamir shares
+func splitIBEMaster(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("domain", ibe.DOMAIN_LEGAL_CORRELATION, "")
+	cmd.Flags().Int("shares", 5, "")
+	cmd.Flags().Int("threshold", 3, "")
+	cmd.Flags().String("output-dir", "", "")
+	cmd.Flags().Int("key-version", 0, "")
+	cmd.Flags().Bool("encrypt", false, "")
+	cmd.Flags().Bool("keep-master", false, "")
+	cmd.Flags().StringSlice("from-share", nil, "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	domain, _ := cmd.Flags().GetString("domain")
+	shares, _ := cmd.Flags().GetInt("shares")
+	threshold, _ := cmd.Flags().GetInt("threshold")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	keyVersion, _ := cmd.Flags().GetInt("key-version")
+	encrypt, _ := cmd.Flags().GetBool("encrypt")
+	keepMaster, _ := cmd.Flags().GetBool("keep-master")
+	fromShares, _ := cmd.Flags().GetStringSlice("from-share")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	keyProvider, err := ibe.NewKeyProviderFromConfig(&cfg.IBE)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open IBE key provider")
+	}
+
+	var passphrase ibe.PassphraseFunc
+	if encrypt {
+		passphrase = newSharePassphrase
+	}
+	split, err := commands.SplitIBEMaster(context.Background(), &cfg.IBE, keyProvider, &commands.IBEShareSplitOptions{
+		Domain:     domain,
+		Shares:     shares,
+		Threshold:  threshold,
+		OutputDir:  outputDir,
+		KeyVersion: keyVersion,
+		KeepMaster: keepMaster,
+		FromShares: fromShares,
+	}, passphrase, sharePassphrase)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to split IBE domain master")
+	}
+
+	fmt.Printf("✅ Split %s key version %d into %d shares, %d needed to unlock it\n", split.Domain, split.KeyVersion, len(split.Paths), split.Threshold)
+	fmt.Printf("   Check value: %s\n", split.Check)
+	for _, path := range split.Paths {
+		fmt.Printf("   %s\n", path)
+	}
+	if split.Encrypted > 0 {
+		fmt.Printf("   %d shares are encrypted with a passphrase\n", split.Encrypted)
+	}
+	if split.MasterRemoved != "" {
+		fmt.Printf("   Removed the domain master from %s\n", split.MasterRemoved)
+	}
+	fmt.Println("   Hand each share to a different holder and remove it from this host.")
+}
+
+// unlockIBEMaster reconstructs an IBE domain master from its shares and
+// verifies it
+func unlockIBEMaster(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("domain", ibe.DOMAIN_LEGAL_CORRELATION, "")
+	cmd.Flags().Int("key-version", 0, "")
+	cmd.Flags().String("shares-dir", "", "")
+	cmd.Flags().StringSlice("share", nil, "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	domain, _ := cmd.Flags().GetString("domain")
+	keyVersion, _ := cmd.Flags().GetInt("key-version")
+	sharesDir, _ := cmd.Flags().GetString("shares-dir")
+	shares, _ := cmd.Flags().GetStringSlice("share")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	keyProvider, err := ibe.NewKeyProviderFromConfig(&cfg.IBE)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open IBE key provider")
+	}
+
+	unlock, err := commands.UnlockIBEMaster(context.Background(), &cfg.IBE, keyProvider, &commands.IBEShareUnlockOptions{
+		Domain:     domain,
+		KeyVersion: keyVersion,
+		SharesDir:  sharesDir,
+		Shares:     shares,
+	}, sharePassphrase)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to unlock IBE domain master")
+	}
+
+	fmt.Printf("✅ Reconstructed %s key version %d from %d shares (threshold %d)\n", unlock.Domain, unlock.KeyVersion, unlock.SharesUsed, unlock.Threshold)
+	fmt.Printf("   Check value: %s\n", unlock.Check)
+}
+
+// kmsStandIn serves a local KMS stand-in until interrupted
+func kmsStandIn(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("listen", "", "")
+	cmd.Flags().String("key-file", "./keys/kms-standin.key", "")
+	cmd.Flags().String("key-id", "", "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	listen, _ := cmd.Flags().GetString("listen")
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	keyID, _ := cmd.Flags().GetString("key-id")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	if listen == "" {
+		listen = strings.TrimPrefix(strings.TrimPrefix(cfg.IBE.KMSURL, "http://"), "https://")
+	}
+	if keyID == "" {
+		keyID = cfg.IBE.KMSKeyID
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("✅ KMS stand-in serving key %q on %s (Ctrl+C to stop)\n", keyID, listen)
+	if err := commands.ServeKMSStandIn(ctx, &commands.KMSStandInOptions{
+		Listen:  listen,
+		KeyFile: keyFile,
+		KeyID:   keyID,
+		Token:   cfg.IBE.KMSToken,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("KMS stand-in failed")
+	}
+}
+
+// newSharePassphrase asks for the passphrase of a new share twice. An empty
+// passphrase leaves the share unencrypted.
+func newSharePassphrase(share *ibe.MasterShare) (string, error) {
+	passphrase := getPasswordInput(fmt.Sprintf("Passphrase for %s (empty for none): ", share.Label()))
+	if passphrase == "" {
+		return "", nil
+	}
+	if getPasswordInput("Confirm passphrase: ") != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// sharePassphrase asks for the passphrase of an encrypted share
+func sharePassphrase(share *ibe.MasterShare) (string, error) {
+	return getPasswordInput(fmt.Sprintf("Passphrase for %s: ", share.Label())), nil
+}
+
+// unlockLogin lifts a login lockout on an account or a client address
+func unlockLogin(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("email", "", "")
+	cmd.Flags().String("ip", "", "")
+	cmd.Flags().String("reason", "", "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	email, _ := cmd.Flags().GetString("email")
+	ip, _ := cmd.Flags().GetString("ip")
+	reason, _ := cmd.Flags().GetString("reason")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	hasher, err := netprivacy.New(&cfg.Privacy, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create address hasher")
+	}
+
+	unlock, err := commands.UnlockLogin(context.Background(), db, hasher, &commands.UnlockLoginOptions{
+		Email:     email,
+		IPAddress: ip,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to unlock login")
+	}
+
+	fmt.Println("✅ Failed logins cleared!")
+	if email != "" {
+		fmt.Printf("   Account %s: was locked: %t\n", email, unlock.AccountWasLocked)
+	}
+	if ip != "" {
+		fmt.Printf("   Address %s: was blocked: %t\n", ip, unlock.IPWasBlocked)
+	}
+}
+
+// verifyAudit verifies the hash-chained audit logs and reports the first
+// break of each chain
+func verifyAudit(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("public-key", "", "")
+	cmd.Flags().Bool("checkpoint", false, "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	publicKeyFile, _ := cmd.Flags().GetString("public-key")
+	checkpoint, _ := cmd.Flags().GetBool("checkpoint")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	if publicKeyFile == "" {
+		publicKeyFile = auditchain.PublicKeyPath(cfg.Audit.CheckpointKeyFile)
+	}
+
+	var signer *auditchain.Signer
+	if checkpoint {
+		signer, err = auditchain.LoadSigner(cfg.Audit.CheckpointKeyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load audit checkpoint key")
+		}
+	}
+
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	reports, err := commands.VerifyAudit(context.Background(), db, signer, &commands.VerifyAuditOptions{
+		PublicKeyFile: publicKeyFile,
+		Checkpoint:    checkpoint,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to verify audit chains")
+	}
+
+	broken := false
+	for _, report := range reports {
+		if report.Break != nil {
+			broken = true
+			fmt.Printf("❌ %s: %v\n", report.Chain, report.Break)
+			fmt.Printf("   Entries verified before the break: %d\n", report.Entries)
+			continue
+		}
+		fmt.Printf("✅ %s: %d entries intact\n", report.Chain, report.Entries)
+		fmt.Printf("   Head: %x\n", report.HeadHash)
+		fmt.Printf("   Signed checkpoints verified: %d\n", report.Checkpoints)
+		if report.Unchained > 0 {
+			fmt.Printf("   Entries written before the chain existed (not covered): %d\n", report.Unchained)
+		}
+		if report.Checkpoint != nil {
+			fmt.Printf("   New checkpoint at entry %d signed by key %s\n", report.Checkpoint.Seq, report.Checkpoint.KeyID)
+		}
+	}
+	if broken {
+		os.Exit(1)
+	}
+}
+
+// exportDisclosure writes the signed disclosure bundle of a compliance case
+func exportDisclosure(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("report-id", "", "")
+	cmd.Flags().String("output", "", "")
+	cmd.Flags().String("recipient-key", "", "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	reportID, _ := cmd.Flags().GetString("report-id")
+	output, _ := cmd.Flags().GetString("output")
+	recipientKey, _ := cmd.Flags().GetString("recipient-key")
+
+	if reportID == "" {
+		log.Fatal().Msg("--report-id is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	signer, err := disclosure.LoadSigner(cfg.Audit.DisclosureKeyFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load disclosure signing key")
+	}
+
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	bundle, path, err := commands.ExportDisclosure(context.Background(), db, signer, &commands.ExportDisclosureOptions{
+		ReportID:         reportID,
+		Output:           output,
+		RecipientKeyFile: recipientKey,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to export disclosure bundle")
+	}
+
+	fmt.Println("✅ Disclosure bundle exported!")
+	fmt.Printf("   File: %s\n", path)
+	fmt.Printf("   Bundle: %s\n", bundle.Manifest.BundleID)
+	fmt.Printf("   Files: %d, pseudonyms: %d\n", len(bundle.Manifest.Files), len(bundle.Manifest.Pseudonyms))
+	fmt.Printf("   Manifest SHA-256: %x\n", bundle.ManifestSHA256)
+	fmt.Printf("   Signed by key: %s\n", bundle.Manifest.KeyID)
+	if bundle.Encrypted {
+		fmt.Println("   Encrypted to the recipient's key")
+	}
+}
+
+// verifyDisclosure checks a received disclosure bundle
+func verifyDisclosure(opts *Options) {
+	// Parse command line flags
+	cmd := cobra.Command{}
+	cmd.Flags().String("bundle", "", "")
+	cmd.Flags().String("public-key", "", "")
+	cmd.Flags().String("recipient-private-key", "", "")
+	cmd.Flags().String("extract", "", "")
+
+	// Parse flags from os.Args
+	cmd.ParseFlags(os.Args[1:])
+
+	// Get flag values
+	bundleFile, _ := cmd.Flags().GetString("bundle")
+	publicKeyFile, _ := cmd.Flags().GetString("public-key")
+	recipientPrivateKey, _ := cmd.Flags().GetString("recipient-private-key")
+	extractDir, _ := cmd.Flags().GetString("extract")
+
+	if bundleFile == "" {
+		log.Fatal().Msg("--bundle is required")
+	}
+	if publicKeyFile == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load configuration")
+		}
+		publicKeyFile = disclosure.PublicKeyPath(cfg.Audit.DisclosureKeyFile)
+	}
+
+	manifest, err := commands.VerifyDisclosure(&commands.VerifyDisclosureOptions{
+		BundleFile:              bundleFile,
+		PublicKeyFile:           publicKeyFile,
+		RecipientPrivateKeyFile: recipientPrivateKey,
+		ExtractDir:              extractDir,
+	})
+	if errors.Is(err, disclosure.ErrInvalidBundle) {
+		fmt.Printf("❌ %s: %v\n", bundleFile, err)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to verify disclosure bundle")
+	}
+
+	fmt.Printf("✅ %s: signature and %d files verified\n", bundleFile, len(manifest.Files))
+	fmt.Printf("   Bundle: %s for compliance report %s\n", manifest.BundleID, manifest.ReportID)
+	fmt.Printf("   Created: %s, signed by key %s\n", manifest.CreatedAt.Format(time.RFC3339), manifest.KeyID)
+	fmt.Printf("   Pseudonyms: %s\n", strings.Join(manifest.Pseudonyms, ", "))
+	if extractDir != "" {
+		fmt.Printf("   Files extracted to %s\n", extractDir)
+	}
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// apiKeyPrefix starts every generated API key so that leaked keys are easy to
+// recognize in logs and secret scanners
+const apiKeyPrefix = "hp_"
+
+// APIKeyHandler handles self-service API key management. Keys act as one of
+// the caller's pseudonyms and are limited to the scopes they were granted.
+type APIKeyHandler struct {
+	apiKeyDAO          *dao.APIKeyDAO
+	securePseudonymDAO *dao.SecurePseudonymDAO
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(db bob.Executor, securePseudonymDAO *dao.SecurePseudonymDAO) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyDAO:          dao.NewAPIKeyDAO(db),
+		securePseudonymDAO: securePseudonymDAO,
+	}
+}
+
+// CreateAPIKey handles creating an API key for one of the caller's pseudonyms
+func (h *APIKeyHandler) CreateAPIKey(ctx context.Context, input *models.APIKeyCreateInput) (*models.APIKeySecretResponse, error) {
+	log.Info().
+		Str("endpoint", "api-keys").
+		Str("component", "api_key_handler").
+		Msg("Create API key requested")
+
+	userCtx, err := requireSessionUser(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range input.Body.Scopes {
+		if !middleware.IsAPIKeyScope(scope) {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+
+	if err := h.requirePseudonymOwner(ctx, userCtx, input.Body.PseudonymID); err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if input.Body.ExpiresInDays > 0 {
+		expiry := time.Now().Add(time.Duration(input.Body.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &expiry
+	}
+
+	rawKey, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := &dao.APIKeyPermissions{
+		Roles:        userCtx.Roles,
+		Capabilities: apiKeyCapabilities(userCtx.Capabilities),
+		Scopes:       dedupeScopes(input.Body.Scopes),
+	}
+
+	apiKey, err := h.apiKeyDAO.CreateAPIKey(ctx, input.Body.Name, rawKey, input.Body.PseudonymID, permissions, expiresAt)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to create API key")
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	info, err := newAPIKeyInfo(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("user_id", userCtx.UserID).
+		Int64("key_id", apiKey.KeyID).
+		Strs("scopes", permissions.Scopes).
+		Msg("API key created")
+
+	return models.NewAPIKeySecretResponse(http.StatusCreated, info, rawKey), nil
+}
+
+// ListAPIKeys handles listing the API keys of the caller's pseudonyms
+func (h *APIKeyHandler) ListAPIKeys(ctx context.Context, input *models.APIKeyListInput) (*models.APIKeyListResponse, error) {
+	log.Info().
+		Str("endpoint", "api-keys").
+		Str("component", "api_key_handler").
+		Msg("List API keys requested")
+
+	userCtx, err := requireSessionUser(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	var pseudonymIDs []string
+	if input.PseudonymID != "" {
+		if err := h.requirePseudonymOwner(ctx, userCtx, input.PseudonymID); err != nil {
+			return nil, err
+		}
+		pseudonymIDs = []string{input.PseudonymID}
+	} else {
+		pseudonyms, err := h.securePseudonymDAO.GetPseudonymsByUserID(ctx, userCtx.UserID, "user", "self_correlation")
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int64("user_id", userCtx.UserID).
+				Msg("Failed to get user pseudonyms")
+			return nil, fmt.Errorf("failed to get pseudonyms: %w", err)
+		}
+		for _, pseudonym := range pseudonyms {
+			pseudonymIDs = append(pseudonymIDs, pseudonym.PseudonymID)
+		}
+	}
+
+	keys := make([]models.APIKeyInfo, 0)
+	for _, pseudonymID := range pseudonymIDs {
+		apiKeys, err := h.apiKeyDAO.GetAPIKeysByPseudonymID(ctx, pseudonymID)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int64("user_id", userCtx.UserID).
+				Msg("Failed to list API keys")
+			return nil, fmt.Errorf("failed to list API keys: %w", err)
+		}
+		for _, apiKey := range apiKeys {
+			info, err := newAPIKeyInfo(apiKey)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, info)
+		}
+	}
+
+	return models.NewAPIKeyListResponse(keys), nil
+}
+
+// RotateAPIKey handles replacing the secret of one of the caller's API keys
+func (h *APIKeyHandler) RotateAPIKey(ctx context.Context, input *models.APIKeyIDInput) (*models.APIKeySecretResponse, error) {
+	log.Info().
+		Str("endpoint", "api-keys/{key_id}/rotate").
+		Str("component", "api_key_handler").
+		Int64("key_id", input.KeyID).
+		Msg("Rotate API key requested")
+
+	userCtx, err := requireSessionUser(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := h.getOwnedAPIKey(ctx, userCtx, input.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !apiKey.IsActive.Valid || !apiKey.IsActive.V {
+		return nil, huma.Error409Conflict("revoked API keys cannot be rotated")
+	}
+
+	rawKey, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.apiKeyDAO.RotateAPIKey(ctx, apiKey.KeyID, rawKey); err != nil {
+		log.Error().
+			Err(err).
+			Int64("key_id", apiKey.KeyID).
+			Msg("Failed to rotate API key")
+		return nil, fmt.Errorf("failed to rotate API key: %w", err)
+	}
+
+	info, err := newAPIKeyInfo(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("user_id", userCtx.UserID).
+		Int64("key_id", apiKey.KeyID).
+		Msg("API key rotated")
+
+	return models.NewAPIKeySecretResponse(http.StatusOK, info, rawKey), nil
+}
+
+// RevokeAPIKey handles revoking one of the caller's API keys. Revoked keys
+// stop working immediately and stay listed as inactive.
+func (h *APIKeyHandler) RevokeAPIKey(ctx context.Context, input *models.APIKeyIDInput) (*models.APIKeyRevokeResponse, error) {
+	log.Info().
+		Str("endpoint", "api-keys/{key_id}").
+		Str("component", "api_key_handler").
+		Int64("key_id", input.KeyID).
+		Msg("Revoke API key requested")
+
+	userCtx, err := requireSessionUser(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := h.getOwnedAPIKey(ctx, userCtx, input.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.apiKeyDAO.DeactivateAPIKey(ctx, apiKey.KeyID); err != nil {
+		log.Error().
+			Err(err).
+			Int64("key_id", apiKey.KeyID).
+			Msg("Failed to revoke API key")
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", userCtx.UserID).
+		Int64("key_id", apiKey.KeyID).
+		Msg("API key revoked")
+
+	return models.NewAPIKeyRevokeResponse(apiKey.KeyID), nil
+}
+
+// requireSessionUser authenticates the caller and refuses API keys, so that a
+// key can never mint, rotate or revoke keys
+func requireSessionUser(authInput *middleware.AuthInput) (*middleware.UserContext, error) {
+	userCtx, err := middleware.ExtractUserFromHumaInput(authInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+	if userCtx.TokenType != "jwt" || userCtx.UserID == 0 {
+		return nil, huma.Error403Forbidden("API keys can only be managed from a signed-in session")
+	}
+	return userCtx, nil
+}
+
+// requirePseudonymOwner checks that a pseudonym belongs to the caller. Other
+// users' pseudonyms are reported as not found so that ownership is not revealed.
+func (h *APIKeyHandler) requirePseudonymOwner(ctx context.Context, userCtx *middleware.UserContext, pseudonymID string) error {
+	owns, err := h.securePseudonymDAO.VerifyPseudonymOwnership(ctx, pseudonymID, userCtx.UserID, "user", "self_correlation")
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to verify pseudonym ownership")
+		return fmt.Errorf("failed to verify pseudonym ownership: %w", err)
+	}
+	if !owns {
+		return huma.Error404NotFound("pseudonym not found")
+	}
+	return nil
+}
+
+// getOwnedAPIKey returns an API key of one of the caller's pseudonyms
+func (h *APIKeyHandler) getOwnedAPIKey(ctx context.Context, userCtx *middleware.UserContext, keyID int64) (*dbmodels.APIKey, error) {
+	apiKey, err := h.apiKeyDAO.GetAPIKeyByID(ctx, keyID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("key_id", keyID).
+			Msg("Failed to get API key")
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if apiKey == nil || !apiKey.PseudonymID.Valid {
+		return nil, huma.Error404NotFound("API key not found")
+	}
+
+	owns, err := h.securePseudonymDAO.VerifyPseudonymOwnership(ctx, apiKey.PseudonymID.V, userCtx.UserID, "user", "self_correlation")
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to verify pseudonym ownership")
+		return nil, fmt.Errorf("failed to verify pseudonym ownership: %w", err)
+	}
+	if !owns {
+		return nil, huma.Error404NotFound("API key not found")
+	}
+	return apiKey, nil
+}
+
+// generateAPIKey returns a new random API key
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// apiKeyCapabilities returns the capabilities a new key inherits from its
+// creator. Correlation is never available to API keys.
+func apiKeyCapabilities(capabilities []string) []string {
+	inherited := make([]string, 0, len(capabilities))
+	for _, capability := range capabilities {
+		isCorrelation := false
+		for _, correlation := range correlationCapabilities {
+			if capability == correlation {
+				isCorrelation = true
+			}
+		}
+		if !isCorrelation {
+			inherited = append(inherited, capability)
+		}
+	}
+	return inherited
+}
+
+// dedupeScopes removes repeated scopes, keeping the first occurrence
+func dedupeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
+
+// newAPIKeyInfo describes an API key for a response
+func newAPIKeyInfo(apiKey *dbmodels.APIKey) (models.APIKeyInfo, error) {
+	permissions, err := dao.ParseAPIKeyPermissions(apiKey)
+	if err != nil {
+		return models.APIKeyInfo{}, err
+	}
+
+	info := models.APIKeyInfo{
+		KeyID:       apiKey.KeyID,
+		Name:        apiKey.KeyName,
+		PseudonymID: apiKey.PseudonymID.V,
+		Scopes:      permissions.Scopes,
+		Active:      apiKey.IsActive.Valid && apiKey.IsActive.V,
+	}
+	if info.Scopes == nil {
+		info.Scopes = []string{}
+	}
+	if apiKey.CreatedAt.Valid {
+		info.CreatedAt = apiKey.CreatedAt.V.UTC().Format(time.RFC3339)
+	}
+	if apiKey.ExpiresAt.Valid {
+		info.ExpiresAt = apiKey.ExpiresAt.V.UTC().Format(time.RFC3339)
+	}
+	if apiKey.LastUsedAt.Valid {
+		info.LastUsedAt = apiKey.LastUsedAt.V.UTC().Format(time.RFC3339)
+	}
+	return info, nil
+}
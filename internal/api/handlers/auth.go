@@ -3,21 +3,25 @@ package handlers
 import (
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/api/models"
 	"github.com/matt0x6f/hashpost/internal/api/validation"
 	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/matt0x6f/hashpost/internal/password"
 	"github.com/rs/zerolog/log"
 	"github.com/stephenafamo/bob"
 )
@@ -29,11 +33,24 @@ type AuthHandler struct {
 	userDAO            *dao.UserDAO
 	securePseudonymDAO *dao.SecurePseudonymDAO
 	identityMappingDAO *dao.IdentityMappingDAO
+	mfaDAO             *dao.MFADAO
+	refreshTokenDAO    *dao.RefreshTokenDAO
+	tokenRevocationDAO *dao.TokenRevocationDAO
+	sessionDAO         *dao.SessionDAO
+	emailTokenDAO      *dao.EmailTokenDAO
+	loginFailureDAO    *dao.LoginFailureDAO
+	systemEventDAO     *dao.SystemEventDAO
 	ibeSystem          *ibe.IBESystem
+	signingKeys        *jwtkeys.KeySet
+	mfaSecrets         *mfa.SecretBox
+	mailer             mailer.Mailer
+
+	// lastFailurePurge is the Unix time stale login failures were last purged
+	lastFailurePurge atomic.Int64
 }
 
 // NewAuthHandler creates a new authentication handler
-func NewAuthHandler(cfg *config.Config, db bob.Executor, rawDB *sql.DB) *AuthHandler {
+func NewAuthHandler(cfg *config.Config, db bob.Executor, rawDB *sql.DB, signingKeys *jwtkeys.KeySet, mfaSecrets *mfa.SecretBox, mail mailer.Mailer) *AuthHandler {
 	userDAO := dao.NewUserDAO(db)
 	ibeSystem := ibe.NewIBESystem()
 	identityMappingDAO := dao.NewIdentityMappingDAO(db)
@@ -47,12 +64,22 @@ func NewAuthHandler(cfg *config.Config, db bob.Executor, rawDB *sql.DB) *AuthHan
 		userDAO:            userDAO,
 		securePseudonymDAO: securePseudonymDAO,
 		identityMappingDAO: identityMappingDAO,
+		mfaDAO:             dao.NewMFADAO(db),
+		refreshTokenDAO:    dao.NewRefreshTokenDAO(db),
+		tokenRevocationDAO: dao.NewTokenRevocationDAO(db),
+		sessionDAO:         dao.NewSessionDAO(db),
+		emailTokenDAO:      dao.NewEmailTokenDAO(db),
+		loginFailureDAO:    dao.NewLoginFailureDAO(db),
+		systemEventDAO:     dao.NewSystemEventDAO(db),
 		ibeSystem:          ibeSystem,
+		signingKeys:        signingKeys,
+		mfaSecrets:         mfaSecrets,
+		mailer:             mail,
 	}
 }
 
 // NewAuthHandlerWithIBE creates a new authentication handler with a specific IBE system
-func NewAuthHandlerWithIBE(cfg *config.Config, db bob.Executor, rawDB *sql.DB, ibeSystem *ibe.IBESystem) *AuthHandler {
+func NewAuthHandlerWithIBE(cfg *config.Config, db bob.Executor, rawDB *sql.DB, ibeSystem *ibe.IBESystem, signingKeys *jwtkeys.KeySet, mfaSecrets *mfa.SecretBox, mail mailer.Mailer) *AuthHandler {
 	userDAO := dao.NewUserDAO(db)
 	identityMappingDAO := dao.NewIdentityMappingDAO(db)
 	roleKeyDAO := dao.NewRoleKeyDAO(db)
@@ -65,7 +92,17 @@ func NewAuthHandlerWithIBE(cfg *config.Config, db bob.Executor, rawDB *sql.DB, i
 		userDAO:            userDAO,
 		securePseudonymDAO: securePseudonymDAO,
 		identityMappingDAO: identityMappingDAO,
+		mfaDAO:             dao.NewMFADAO(db),
+		refreshTokenDAO:    dao.NewRefreshTokenDAO(db),
+		tokenRevocationDAO: dao.NewTokenRevocationDAO(db),
+		sessionDAO:         dao.NewSessionDAO(db),
+		emailTokenDAO:      dao.NewEmailTokenDAO(db),
+		loginFailureDAO:    dao.NewLoginFailureDAO(db),
+		systemEventDAO:     dao.NewSystemEventDAO(db),
 		ibeSystem:          ibeSystem,
+		signingKeys:        signingKeys,
+		mfaSecrets:         mfaSecrets,
+		mailer:             mail,
 	}
 }
 
@@ -93,20 +130,25 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 	existingUser, err := h.userDAO.GetUserByEmail(ctx, input.Body.Email)
 	if err == nil && existingUser != nil {
 		log.Warn().
-			Str("email", input.Body.Email).
+			Int64("user_id", existingUser.UserID).
 			Msg("User registration failed - email already exists")
 		return nil, fmt.Errorf("user with email %s already exists", input.Body.Email)
 	}
 
 	// Hash password
-	hashedPassword := h.hashPassword(input.Body.Password)
+	hashedPassword, err := password.Hash(input.Body.Password)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to hash password")
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
 
 	// Create user
 	user, err := h.userDAO.CreateUser(ctx, input.Body.Email, hashedPassword)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Str("email", input.Body.Email).
 			Msg("Failed to create user")
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -132,6 +174,15 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 		return nil, fmt.Errorf("failed to create pseudonym: %w", err)
 	}
 
+	// Ask the user to prove control of the address. Registration still
+	// succeeds if the mail cannot be sent; the user can request a new link.
+	if err := h.sendVerificationEmail(ctx, user.UserID, user.Email); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", user.UserID).
+			Msg("Failed to send verification email")
+	}
+
 	// Get user roles and capabilities from database
 	roles := []string{"user"}                                                                  // Default role
 	capabilities := []string{"create_content", "vote", "message", "report", "create_subforum"} // Default capabilities
@@ -163,13 +214,23 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 		Email:             user.Email,
 		Roles:             roles,
 		Capabilities:      capabilities,
-		MFAEnabled:        false, // TODO: Implement MFA
+		MFAEnabled:        false, // New accounts enroll in MFA separately
+		EmailVerified:     false,
 		ActivePseudonymID: pseudonym.PseudonymID,
 		DisplayName:       pseudonym.DisplayName,
 	}
 
+	// Start a login session; the tokens below are tied to it
+	if err := h.startSession(ctx, userCtx); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", user.UserID).
+			Msg("Failed to start session")
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
 	// Generate JWT tokens
-	accessToken, err := middleware.GenerateJWT(userCtx, h.config.JWT.Secret, h.config.JWT.Expiration)
+	accessToken, err := middleware.GenerateJWT(userCtx, h.signingKeys, h.config.JWT.Expiration)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -178,8 +239,8 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	// Generate refresh token (longer expiration)
-	refreshToken, err := middleware.GenerateJWT(userCtx, h.config.JWT.Secret, 7*24*time.Hour) // 7 days
+	// Generate refresh token for the session
+	refreshToken, err := h.issueRefreshToken(ctx, userCtx)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -190,7 +251,6 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 
 	log.Info().
 		Int64("user_id", user.UserID).
-		Str("email", input.Body.Email).
 		Str("pseudonym_id", pseudonym.PseudonymID).
 		Msg("User registered successfully")
 
@@ -203,6 +263,7 @@ func (h *AuthHandler) RegisterUser(ctx context.Context, input *models.UserRegist
 		pseudonym.DisplayName,
 		accessToken,
 		refreshToken,
+		false,
 	), nil
 }
 
@@ -222,26 +283,24 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 		return nil, huma.Error422UnprocessableEntity("password is required")
 	}
 
-	// Debug: Log the input to see what we're receiving
-	log.Debug().
-		Str("input_email", input.Body.Email).
-		Str("input_password_length", fmt.Sprintf("%d", len(input.Body.Password))).
-		Msg("Login input received")
+	// Refuse attempts while earlier failures block the address or the client
+	if err := h.checkLoginThrottle(ctx, input.Body.Email); err != nil {
+		return nil, err
+	}
 
 	// Find the user by email
 	user, err := h.userDAO.GetUserByEmail(ctx, input.Body.Email)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Str("email", input.Body.Email).
 			Msg("Failed to find user by email")
 		return nil, fmt.Errorf("failed to find user by email: %w", err)
 	}
 
 	if user == nil {
 		log.Warn().
-			Str("email", input.Body.Email).
 			Msg("User not found")
+		h.recordLoginFailure(ctx, input.Body.Email, nil)
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
@@ -261,16 +320,44 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 		return nil, fmt.Errorf("account suspended")
 	}
 
-	// Verify password (in a real app, you'd use bcrypt.CompareHashAndPassword)
-	if !h.verifyPassword(input.Body.Password, user.PasswordHash) {
+	// Verify password
+	valid, err := password.Verify(input.Body.Password, user.PasswordHash)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", user.UserID).
+			Msg("Failed to verify password")
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	if !valid {
 		log.Warn().
 			Int64("user_id", user.UserID).
 			Msg("Invalid password")
+		h.recordLoginFailure(ctx, input.Body.Email, user)
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
+	// Upgrade legacy or outdated password hashes now that we have the plaintext
+	if password.NeedsRehash(user.PasswordHash) {
+		h.rehashPassword(ctx, user.UserID, input.Body.Password)
+	}
+
+	// Accounts with MFA enabled must complete a second step before receiving tokens
+	if user.MfaEnabled.Valid && user.MfaEnabled.V {
+		return h.issueMFAChallenge(user.UserID)
+	}
+
+	return h.completeLogin(ctx, user, time.Time{})
+}
+
+// completeLogin issues tokens for a user whose credentials (and second factor,
+// if enabled) have been verified. mfaVerifiedAt is zero when no MFA challenge
+// was completed as part of this login.
+func (h *AuthHandler) completeLogin(ctx context.Context, user *dbmodels.User, mfaVerifiedAt time.Time) (*models.UserLoginResponse, error) {
+	h.clearLoginFailures(ctx, user.Email)
+
 	// Update last active timestamp
-	err = h.userDAO.UpdateLastActive(ctx, user.UserID)
+	err := h.userDAO.UpdateLastActive(ctx, user.UserID)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -390,19 +477,39 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 		Bool("is_default", defaultPseudonym.IsDefault).
 		Msg("Using default pseudonym as active pseudonym")
 
+	emailVerified, err := h.userDAO.IsEmailVerified(ctx, user.UserID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", user.UserID).
+			Msg("Failed to check email verification")
+		return nil, fmt.Errorf("failed to check email verification: %w", err)
+	}
+
 	// Create user context for JWT generation
 	userCtx := &middleware.UserContext{
 		UserID:            user.UserID,
 		Email:             user.Email,
 		Roles:             roles,
 		Capabilities:      capabilities,
-		MFAEnabled:        false, // TODO: Implement MFA
+		MFAEnabled:        user.MfaEnabled.Valid && user.MfaEnabled.V,
+		MFAVerifiedAt:     mfaVerifiedAt,
+		EmailVerified:     emailVerified,
 		ActivePseudonymID: activePseudonymID,
 		DisplayName:       displayName,
 	}
 
+	// Start a login session; the tokens below are tied to it
+	if err := h.startSession(ctx, userCtx); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", user.UserID).
+			Msg("Failed to start session")
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
 	// Generate JWT tokens
-	accessToken, err := middleware.GenerateJWT(userCtx, h.config.JWT.Secret, h.config.JWT.Expiration)
+	accessToken, err := middleware.GenerateJWT(userCtx, h.signingKeys, h.config.JWT.Expiration)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -411,8 +518,8 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	// Generate refresh token (longer expiration)
-	refreshToken, err := middleware.GenerateJWT(userCtx, h.config.JWT.Secret, 7*24*time.Hour) // 7 days
+	// Generate refresh token for the session
+	refreshToken, err := h.issueRefreshToken(ctx, userCtx)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -426,7 +533,6 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 	// with header:"Set-Cookie" tags that Huma automatically processes
 	log.Info().
 		Int64("user_id", user.UserID).
-		Str("email", input.Body.Email).
 		Bool("jwt_development", h.config.JWT.Development).
 		Msg("User logged in successfully - creating response with cookies")
 
@@ -442,6 +548,7 @@ func (h *AuthHandler) LoginUser(ctx context.Context, input *models.UserLoginInpu
 		pseudonymInfos,
 		h.config.JWT.Development,
 	)
+	response.Body.EmailVerified = emailVerified
 
 	log.Info().
 		Msg("Created login response with cookies")
@@ -456,21 +563,19 @@ func (h *AuthHandler) LogoutUser(ctx context.Context, input *models.UserLogoutIn
 		Str("component", "auth_handler").
 		Msg("Processing user logout request")
 
-	// TODO: Implement token blacklisting for logout
-	// For now, validate the refresh token if provided (for future blacklisting)
+	// Revoke the refresh token family so neither this token nor any token
+	// rotated from it can be used again
 	if input.Body.RefreshToken != "" {
-		claims, err := h.validateJWT(input.Body.RefreshToken)
-		if err != nil {
-			log.Warn().
+		h.revokeRefreshFamily(ctx, input.Body.RefreshToken, dao.RefreshRevokeReasonLogout)
+	}
+
+	// Revoke the access token too, if the request carries one
+	if userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput); err == nil && userCtx.TokenID != "" {
+		if err := h.tokenRevocationDAO.RevokeToken(ctx, userCtx.TokenID, userCtx.UserID, userCtx.TokenExpiresAt, dao.TokenRevokeReasonLogout); err != nil {
+			log.Error().
 				Err(err).
-				Msg("Invalid refresh token provided during logout")
-			// Don't return error - still clear cookies even if token is invalid
-		} else {
-			log.Info().
-				Int64("user_id", claims.UserID).
-				Str("email", claims.Email).
-				Msg("Valid refresh token provided during logout - ready for blacklisting")
-			// TODO: Add token to blacklist (Redis/database)
+				Int64("user_id", userCtx.UserID).
+				Msg("Failed to revoke access token during logout")
 		}
 	}
 
@@ -479,29 +584,36 @@ func (h *AuthHandler) LogoutUser(ctx context.Context, input *models.UserLogoutIn
 	return models.NewUserLogoutResponse(h.config.JWT.Development), nil
 }
 
-// validateJWT validates and parses a JWT token
-func (h *AuthHandler) validateJWT(tokenString string) (*middleware.JWTClaims, error) {
-	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &middleware.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(h.config.JWT.Secret), nil
-	})
+// LogoutAllSessions revokes every access and refresh token issued to the current user
+func (h *AuthHandler) LogoutAllSessions(ctx context.Context, input *models.UserLogoutAllInput) (*models.UserLogoutResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/logout/all").
+		Str("component", "auth_handler").
+		Msg("Processing logout from all sessions request")
 
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JWT: %w", err)
+		return nil, huma.Error401Unauthorized("Authentication required")
 	}
 
-	if claims, ok := token.Claims.(*middleware.JWTClaims); ok && token.Valid {
-		return claims, nil
+	if err := h.tokenRevocationDAO.RevokeUserTokens(ctx, userCtx.UserID, dao.RefreshRevokeReasonLogoutAll); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to revoke user tokens")
+		return nil, fmt.Errorf("failed to log out sessions: %w", err)
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	log.Info().
+		Int64("user_id", userCtx.UserID).
+		Msg("User logged out of all sessions - clearing cookies")
+
+	return models.NewUserLogoutResponse(h.config.JWT.Development), nil
 }
 
-// RefreshToken handles token refresh
+// RefreshToken handles token refresh. Refresh tokens are single-use: each
+// refresh rotates the token, and presenting an already rotated token revokes
+// its whole family since it means the token was copied.
 func (h *AuthHandler) RefreshToken(ctx context.Context, input *models.RefreshTokenInput) (*models.TokenRefreshResponse, error) {
 	log.Info().
 		Str("endpoint", "auth/refresh").
@@ -509,12 +621,65 @@ func (h *AuthHandler) RefreshToken(ctx context.Context, input *models.RefreshTok
 		Msg("Processing token refresh request")
 
 	// Validate the refresh token
-	claims, err := h.validateJWT(input.Body.RefreshToken)
+	claims, err := middleware.ParseRefreshToken(input.Body.RefreshToken, h.signingKeys)
 	if err != nil {
 		log.Warn().
 			Err(err).
 			Msg("Invalid refresh token provided")
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+
+	record, err := h.refreshTokenDAO.GetTokenByHash(ctx, middleware.HashRefreshToken(input.Body.RefreshToken))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", claims.UserID).
+			Msg("Failed to look up refresh token")
+		return nil, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+	if record == nil || record.UserID != claims.UserID {
+		log.Warn().
+			Int64("user_id", claims.UserID).
+			Msg("Unknown refresh token provided")
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+
+	if record.FamilyRevokedAt.Valid {
+		log.Warn().
+			Int64("user_id", record.UserID).
+			Msg("Refresh token from a revoked family provided")
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+
+	// Claim the token. Losing this race to another request is treated the
+	// same as presenting a token that was already rotated.
+	claimed := false
+	if !record.UsedAt.Valid {
+		claimed, err = h.refreshTokenDAO.MarkTokenUsed(ctx, record.TokenID)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int64("user_id", record.UserID).
+				Msg("Failed to rotate refresh token")
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+	}
+	if !claimed {
+		log.Warn().
+			Int64("user_id", record.UserID).
+			Str("reason", dao.RefreshRevokeReasonReuse).
+			Msg("Refresh token reuse detected - revoking token family")
+		if _, err := h.tokenRevocationDAO.RevokeSession(ctx, record.UserID, record.FamilyID, dao.RefreshRevokeReasonReuse); err != nil {
+			log.Error().
+				Err(err).
+				Int64("user_id", record.UserID).
+				Msg("Failed to revoke refresh token family")
+		}
+		return nil, huma.Error401Unauthorized("invalid refresh token")
 	}
 
 	// Create user context from the refresh token claims
@@ -524,12 +689,36 @@ func (h *AuthHandler) RefreshToken(ctx context.Context, input *models.RefreshTok
 		Roles:             claims.Roles,
 		Capabilities:      claims.Capabilities,
 		MFAEnabled:        claims.MFAEnabled,
+		EmailVerified:     claims.EmailVerified,
 		ActivePseudonymID: claims.ActivePseudonymID,
 		DisplayName:       claims.DisplayName,
+		SessionID:         record.FamilyID,
+	}
+	if claims.MFAVerifiedAt > 0 {
+		userCtx.MFAVerifiedAt = time.Unix(claims.MFAVerifiedAt, 0)
+	}
+
+	// Pick up a verification completed since the session started
+	if !userCtx.EmailVerified {
+		verified, err := h.userDAO.IsEmailVerified(ctx, userCtx.UserID)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Int64("user_id", userCtx.UserID).
+				Msg("Failed to check email verification")
+		}
+		userCtx.EmailVerified = verified
+	}
+
+	if err := h.sessionDAO.TouchSession(ctx, record.FamilyID); err != nil {
+		log.Warn().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to update session last seen")
 	}
 
 	// Generate new access token
-	newAccessToken, err := middleware.GenerateJWT(userCtx, h.config.JWT.Secret, h.config.JWT.Expiration)
+	newAccessToken, err := middleware.GenerateJWT(userCtx, h.signingKeys, h.config.JWT.Expiration)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -538,28 +727,56 @@ func (h *AuthHandler) RefreshToken(ctx context.Context, input *models.RefreshTok
 		return nil, fmt.Errorf("failed to generate new access token: %w", err)
 	}
 
+	// Issue the replacement refresh token in the same session
+	newRefreshToken, err := h.issueRefreshToken(ctx, userCtx)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userCtx.UserID).
+			Msg("Failed to generate new refresh token")
+		return nil, fmt.Errorf("failed to generate new refresh token: %w", err)
+	}
+
 	log.Info().
 		Int64("user_id", userCtx.UserID).
 		Msg("Token refreshed successfully")
 
-	// Return new token response with cookie
-	return models.NewTokenRefreshResponse(newAccessToken, int(h.config.JWT.Expiration.Seconds()), h.config.JWT.Development), nil
+	// Return new token response with cookies
+	return models.NewRotatedTokenResponse(
+		newAccessToken,
+		newRefreshToken,
+		int(h.config.JWT.Expiration.Seconds()),
+		int(middleware.RefreshTokenExpiration.Seconds()),
+		h.config.JWT.Development,
+	), nil
 }
 
-// hashPassword hashes a password using SHA-256
-func (h *AuthHandler) hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
-}
+// rehashPassword replaces a user's stored password hash with one using the
+// current algorithm and parameters. Failures are logged but never fail the login.
+func (h *AuthHandler) rehashPassword(ctx context.Context, userID int64, plaintext string) {
+	newHash, err := password.Hash(plaintext)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to rehash password")
+		return
+	}
+
+	if err := h.userDAO.UpdateUser(ctx, userID, &dbmodels.UserSetter{PasswordHash: &newHash}); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to store upgraded password hash")
+		return
+	}
 
-// verifyPassword verifies a password against a SHA-256 hash
-func (h *AuthHandler) verifyPassword(password, hash string) bool {
-	// Hash the provided password and compare with stored hash
-	passwordHash := h.hashPassword(password)
-	return passwordHash == hash
+	log.Info().
+		Int64("user_id", userID).
+		Msg("Upgraded password hash")
 }
 
-// generateSessionToken generates a random session token
+// generateSessionToken generates a random session token, used as a session ID
 func (h *AuthHandler) generateSessionToken() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -585,7 +802,6 @@ func (h *AuthHandler) GetCurrentUserSession(ctx context.Context, input *middlewa
 	userID := int(userCtx.UserID)
 	log.Info().
 		Int("user_id", userID).
-		Str("email", userCtx.Email).
 		Msg("Getting current user session data")
 
 	// Get user from database to ensure they still exist and are active
@@ -701,7 +917,6 @@ func (h *AuthHandler) GetCurrentUserSession(ctx context.Context, input *middlewa
 
 	log.Info().
 		Int("user_id", userID).
-		Str("email", userCtx.Email).
 		Str("active_pseudonym_id", activePseudonymID).
 		Msg("Current user session data retrieved successfully")
 
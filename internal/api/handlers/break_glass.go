@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/correlation"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/rs/zerolog/log"
+)
+
+// BreakGlassCorrelation handles emergency identity correlations, made without
+// prior approval when a reason code such as a threat of violence cannot wait.
+// The requester is granted short-lived access to their role's correlation
+// key for the correlation, the reviewers are notified, and the use must be signed off by a reviewer
+// before the review window ends or the requester's account is flagged.
+func (h *CorrelationHandler) BreakGlassCorrelation(ctx context.Context, input *models.BreakGlassCorrelationInput) (*models.BreakGlassCorrelationResponse, error) {
+	// Extract admin from context (from admin JWT token)
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	adminID := userCtx.UserID
+
+	log.Info().
+		Str("endpoint", "admin/correlation/break-glass").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("requested_pseudonym", input.Body.RequestedPseudonym).
+		Str("justification", input.Body.Justification).
+		Str("reason_code", input.Body.ReasonCode).
+		Str("incident_id", input.Body.IncidentID).
+		Msg("Break-glass identity correlation requested")
+
+	if err := requireIdentityCorrelation(userCtx); err != nil {
+		return nil, err
+	}
+	role, ok := h.breakGlassRole(userCtx)
+	if !ok {
+		log.Warn().
+			Int64("admin_id", adminID).
+			Strs("break_glass_roles", h.breakGlassPolicy.Roles).
+			Msg("User lacks a break-glass role")
+		return nil, huma.Error403Forbidden("insufficient permissions: a break-glass role is required")
+	}
+	// Break-glass skips the approval and the quotas, not the role definitions
+	grant, err := h.policy.AuthorizeIdentity(ctx, []string{role}, correlation.ScopePlatform, input.Body.RequestedPseudonym)
+	if err != nil {
+		return nil, policyError(err, adminID)
+	}
+
+	flagged, err := h.breakGlassReviewDAO.IsFlagged(ctx, adminID)
+	if err != nil {
+		log.Error().Err(err).Int64("admin_id", adminID).Msg("Failed to check break-glass flags")
+		return nil, fmt.Errorf("failed to check break-glass flags: %w", err)
+	}
+	if flagged {
+		log.Warn().
+			Int64("admin_id", adminID).
+			Msg("Flagged user attempted a break-glass correlation")
+		return nil, huma.Error403Forbidden("account is flagged for an overdue or rejected break-glass correlation")
+	}
+
+	// Check if pseudonym exists
+	pseudonym, err := h.securePseudonymDAO.GetPseudonymByID(ctx, input.Body.RequestedPseudonym)
+	if err != nil {
+		log.Error().Err(err).Str("pseudonym_id", input.Body.RequestedPseudonym).Msg("Failed to get pseudonym from database")
+		return nil, fmt.Errorf("failed to get pseudonym: %w", err)
+	}
+	if pseudonym == nil {
+		log.Warn().
+			Str("requested_pseudonym", input.Body.RequestedPseudonym).
+			Msg("Pseudonym not found")
+		return nil, huma.Error404NotFound("pseudonym not found")
+	}
+
+	identityMapping, err := h.identityMappingDAO.GetRoleIdentityMapping(ctx, pseudonym.PseudonymID, grant.Role, dao.KeyScopeCorrelation)
+	if err != nil {
+		log.Error().Err(err).Str("requested_pseudonym", pseudonym.PseudonymID).Msg("Failed to get identity mapping")
+		return nil, fmt.Errorf("failed to get identity mapping: %w", err)
+	}
+	if identityMapping == nil {
+		log.Warn().
+			Str("requested_pseudonym", pseudonym.PseudonymID).
+			Str("role", grant.Role).
+			Msg("Identity mapping not found")
+		return nil, fmt.Errorf("identity mapping not found for pseudonym")
+	}
+
+	review, err := h.breakGlassReviewDAO.CreateReview(ctx, &dao.BreakGlassReview{
+		RequesterID:        adminID,
+		RequesterRole:      grant.Role,
+		RequestedPseudonym: pseudonym.PseudonymID,
+		ReasonCode:         input.Body.ReasonCode,
+		Justification:      input.Body.Justification,
+		IncidentID:         input.Body.IncidentID,
+	}, time.Now().Add(h.breakGlassPolicy.ReviewWindow))
+	if err != nil {
+		log.Error().Err(err).Int64("admin_id", adminID).Msg("Failed to create break-glass review")
+		return nil, fmt.Errorf("failed to create break-glass review: %w", err)
+	}
+	// The reviewers are notified of every use, including those that fail
+	defer h.breakGlass.Opened(ctx, review)
+
+	roleKey, err := h.roleKeyService.GrantTemporaryKey(ctx, grant.Role, correlation.BreakGlassKeyScope, identityMapping.KeyVersion, []string{"correlate_identities"}, h.breakGlassPolicy.KeyTTL, adminID)
+	if err != nil {
+		log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to grant break-glass role key")
+		return nil, fmt.Errorf("failed to grant break-glass role key: %w", err)
+	}
+	if err := h.breakGlassReviewDAO.SetKey(ctx, review.ReviewID, roleKey.KeyID); err != nil {
+		log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to record break-glass role key")
+		return nil, err
+	}
+	review.KeyID = sql.Null[uuid.UUID]{V: roleKey.KeyID, Valid: true}
+
+	// Decrypt the identity mapping with the granted key, which is not used
+	// again; it expires on its own should the revocation fail
+	decryptedMapping, decryptErr := h.decryptIdentityWithKeys(ctx, adminID, grant.Role, pseudonym.PseudonymID, []*dbmodels.RoleKey{roleKey})
+	if err := h.roleKeyService.RevokeKey(ctx, roleKey.KeyID); err != nil {
+		log.Error().Err(err).Str("key_id", roleKey.KeyID.String()).Time("expires_at", roleKey.ExpiresAt).Msg("Failed to revoke break-glass role key")
+	}
+
+	var results []models.CorrelationResult
+	if decryptErr == nil {
+		results, err = h.relatedIdentities(ctx, grant, pseudonym.PseudonymID, decryptedMapping.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Every use is audited, including those that fail to decrypt
+	auditID, err := h.auditBreakGlass(ctx, review, userCtx.Email, input.Body.RequestedFingerprint, results)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.breakGlassReviewDAO.SetAuditEntry(ctx, review.ReviewID, auditID); err != nil {
+		log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to link break-glass review to its audit entry")
+		return nil, err
+	}
+	review.AuditID = sql.Null[uuid.UUID]{V: auditID, Valid: true}
+
+	if decryptErr != nil {
+		return nil, decryptErr
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/break-glass").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("review_id", review.ReviewID.String()).
+		Int("results_count", len(results)).
+		Str("audit_id", auditID.String()).
+		Time("review_due_at", review.ReviewDueAt).
+		Msg("Break-glass identity correlation completed")
+
+	return models.NewBreakGlassCorrelationResponse(grant.Scope, grant.TimeWindow, results, auditID.String(), newBreakGlassReview(review)), nil
+}
+
+// ListBreakGlassReviews handles listing break-glass correlations for review
+func (h *CorrelationHandler) ListBreakGlassReviews(ctx context.Context, input *models.BreakGlassReviewListInput) (*models.BreakGlassReviewListResponse, error) {
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/break-glass/reviews").
+		Str("component", "handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("status", input.Status).
+		Msg("Break-glass review list requested")
+
+	if _, err := h.requireBreakGlassReviewer(userCtx); err != nil {
+		return nil, err
+	}
+	if _, err := h.breakGlass.CheckOverdue(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to check overdue break-glass reviews")
+		return nil, fmt.Errorf("failed to check overdue break-glass reviews: %w", err)
+	}
+
+	reviews, err := h.breakGlassReviewDAO.ListReviews(ctx, input.Status)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list break-glass reviews")
+		return nil, fmt.Errorf("failed to list break-glass reviews: %w", err)
+	}
+
+	list := make([]models.BreakGlassReview, 0, len(reviews))
+	for i := range reviews {
+		list = append(list, newBreakGlassReview(&reviews[i]))
+	}
+	return models.NewBreakGlassReviewListResponse(list), nil
+}
+
+// SignOffBreakGlassReview handles signing off a break-glass correlation
+func (h *CorrelationHandler) SignOffBreakGlassReview(ctx context.Context, input *models.BreakGlassReviewInput) (*models.BreakGlassReviewResponse, error) {
+	return h.reviewBreakGlass(ctx, input, dao.BreakGlassReviewSignedOff)
+}
+
+// RejectBreakGlassReview handles rejecting a break-glass correlation, which
+// flags the requester's account
+func (h *CorrelationHandler) RejectBreakGlassReview(ctx context.Context, input *models.BreakGlassReviewInput) (*models.BreakGlassReviewResponse, error) {
+	return h.reviewBreakGlass(ctx, input, dao.BreakGlassReviewRejected)
+}
+
+// reviewBreakGlass signs off or rejects a pending or overdue break-glass
+// correlation. The reviewer must hold a reviewer role and must not be the
+// requester.
+func (h *CorrelationHandler) reviewBreakGlass(ctx context.Context, input *models.BreakGlassReviewInput, decision string) (*models.BreakGlassReviewResponse, error) {
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	adminID := userCtx.UserID
+
+	log.Info().
+		Str("endpoint", "admin/correlation/break-glass/reviews/review").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("review_id", input.ReviewID).
+		Str("decision", decision).
+		Msg("Break-glass review requested")
+
+	reviewerRole, err := h.requireBreakGlassReviewer(userCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewID, err := uuid.FromString(input.ReviewID)
+	if err != nil {
+		return nil, huma.Error404NotFound("break-glass review not found")
+	}
+	review, err := h.breakGlassReviewDAO.GetReview(ctx, reviewID)
+	if err != nil {
+		log.Error().Err(err).Str("review_id", input.ReviewID).Msg("Failed to get break-glass review")
+		return nil, fmt.Errorf("failed to get break-glass review: %w", err)
+	}
+	if review == nil {
+		return nil, huma.Error404NotFound("break-glass review not found")
+	}
+	if review.RequesterID == adminID {
+		log.Warn().
+			Int64("admin_id", adminID).
+			Str("review_id", input.ReviewID).
+			Msg("Requester attempted to review their own break-glass correlation")
+		return nil, huma.Error403Forbidden("break-glass correlations must be reviewed by a different admin")
+	}
+	if review.Status != dao.BreakGlassReviewPending && review.Status != dao.BreakGlassReviewOverdue {
+		return nil, huma.Error409Conflict(fmt.Sprintf("break-glass review is %s", review.Status))
+	}
+
+	var reviewed *dao.BreakGlassReview
+	if decision == dao.BreakGlassReviewSignedOff {
+		reviewed, err = h.breakGlassReviewDAO.SignOffReview(ctx, reviewID, adminID, reviewerRole, input.Body.Comment)
+	} else {
+		reviewed, err = h.breakGlassReviewDAO.RejectReview(ctx, reviewID, adminID, reviewerRole, input.Body.Comment)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("review_id", input.ReviewID).Msg("Failed to review break-glass correlation")
+		return nil, fmt.Errorf("failed to review break-glass correlation: %w", err)
+	}
+	if reviewed == nil {
+		return nil, huma.Error409Conflict("break-glass review is no longer open")
+	}
+	if reviewed.Status == dao.BreakGlassReviewRejected {
+		h.breakGlass.Flagged(ctx, reviewed)
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/break-glass/reviews/review").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Int64("requester_id", reviewed.RequesterID).
+		Str("review_id", input.ReviewID).
+		Str("status", reviewed.Status).
+		Msg("Break-glass correlation reviewed")
+
+	return models.NewBreakGlassReviewResponse(newBreakGlassReview(reviewed)), nil
+}
+
+// breakGlassRole returns the first of a user's roles that may break the glass
+func (h *CorrelationHandler) breakGlassRole(userCtx *middleware.UserContext) (string, bool) {
+	for _, role := range h.breakGlassPolicy.Roles {
+		if userCtx.HasRole(role) {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// requireBreakGlassReviewer checks that a user may review break-glass
+// correlations, which requires a recent MFA step-up, and returns the
+// reviewer role they review in
+func (h *CorrelationHandler) requireBreakGlassReviewer(userCtx *middleware.UserContext) (string, error) {
+	for _, role := range h.breakGlassPolicy.ReviewerRoles {
+		if !userCtx.HasRole(role) {
+			continue
+		}
+		if err := middleware.EnforceMFA(userCtx, "legal_compliance"); err != nil {
+			log.Warn().
+				Int64("admin_id", userCtx.UserID).
+				Msg("Break-glass review requires MFA step-up")
+			return "", huma.Error403Forbidden(middleware.ErrMFARequired.Message)
+		}
+		return role, nil
+	}
+	log.Warn().
+		Int64("admin_id", userCtx.UserID).
+		Strs("reviewer_roles", h.breakGlassPolicy.ReviewerRoles).
+		Msg("User lacks a break-glass reviewer role")
+	return "", huma.Error403Forbidden("insufficient permissions: a break-glass reviewer role is required")
+}
+
+// auditBreakGlass writes a break-glass correlation to the correlation audit
+// trail, marked by its request source, with its results if it completed
+func (h *CorrelationHandler) auditBreakGlass(ctx context.Context, review *dao.BreakGlassReview, username, requestedFingerprint string, results []models.CorrelationResult) (uuid.UUID, error) {
+	entry := &dao.CorrelationAuditEntry{
+		UserID:             review.RequesterID,
+		PseudonymID:        review.RequestedPseudonym,
+		AdminUsername:      username,
+		RoleUsed:           review.RequesterRole,
+		RequestedPseudonym: review.RequestedPseudonym,
+		Justification:      review.Justification,
+		CorrelationType:    "identity",
+		LegalBasis:         sql.Null[string]{V: review.ReasonCode, Valid: true},
+		IncidentID:         sql.Null[string]{V: review.IncidentID, Valid: true},
+		RequestSource:      sql.Null[string]{V: dao.RequestSourceBreakGlass, Valid: true},
+		AuditClient:        auditClient(ctx),
+	}
+	if requestedFingerprint != "" {
+		entry.RequestedFingerprint = sql.Null[string]{V: requestedFingerprint, Valid: true}
+	}
+	if results != nil {
+		// Serialize correlation results for audit
+		correlationResultJSON, err := json.Marshal(results)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to marshal correlation results")
+			return uuid.Nil, fmt.Errorf("failed to serialize correlation results: %w", err)
+		}
+		entry.CorrelationResult.Scan(correlationResultJSON)
+	}
+
+	if err := h.correlationAuditDAO.RecordEntry(ctx, entry); err != nil {
+		log.Error().Err(err).
+			Str("review_id", review.ReviewID.String()).
+			Msg("Failed to create correlation audit record")
+		return uuid.Nil, fmt.Errorf("failed to create audit record: %w", err)
+	}
+	return entry.AuditID, nil
+}
+
+// newBreakGlassReview converts a break-glass review for the API
+func newBreakGlassReview(review *dao.BreakGlassReview) models.BreakGlassReview {
+	result := models.BreakGlassReview{
+		ReviewID:           review.ReviewID.String(),
+		Status:             review.Status,
+		RequestedPseudonym: review.RequestedPseudonym,
+		ReasonCode:         review.ReasonCode,
+		Justification:      review.Justification,
+		IncidentID:         review.IncidentID,
+		RequesterID:        review.RequesterID,
+		RequesterRole:      review.RequesterRole,
+		ReviewerRole:       review.ReviewerRole.V,
+		ReviewComment:      review.ReviewComment.V,
+		CreatedAt:          review.CreatedAt.Format(time.RFC3339),
+		ReviewDueAt:        review.ReviewDueAt.Format(time.RFC3339),
+	}
+	if review.AuditID.Valid {
+		result.AuditID = review.AuditID.V.String()
+	}
+	if review.ReviewerID.Valid {
+		result.ReviewerID = &review.ReviewerID.V
+	}
+	if review.ReviewedAt.Valid {
+		result.ReviewedAt = review.ReviewedAt.V.Format(time.RFC3339)
+	}
+	if review.FlaggedAt.Valid {
+		result.FlaggedAt = review.FlaggedAt.V.Format(time.RFC3339)
+	}
+	return result
+}
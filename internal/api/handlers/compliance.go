@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdh"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// ComplianceHandler handles the cases of the legal team: court orders,
+// subpoenas and similar legal requests, and the correlations made for them
+type ComplianceHandler struct {
+	complianceReportDAO   *dao.ComplianceReportDAO
+	correlationRequestDAO *dao.CorrelationRequestDAO
+	correlationAuditDAO   *dao.CorrelationAuditDAO
+	exporter              *disclosure.Exporter
+}
+
+// NewComplianceHandler creates a new compliance handler. Disclosure bundles
+// are signed with disclosureSigner.
+func NewComplianceHandler(db bob.Executor, auditChainDAO *dao.AuditChainDAO, disclosureSigner *disclosure.Signer) *ComplianceHandler {
+	return &ComplianceHandler{
+		complianceReportDAO:   dao.NewComplianceReportDAO(db),
+		correlationRequestDAO: dao.NewCorrelationRequestDAO(db),
+		correlationAuditDAO:   dao.NewCorrelationAuditDAO(db, auditChainDAO),
+		exporter:              disclosure.NewExporter(db, auditChainDAO, disclosureSigner),
+	}
+}
+
+// CreateComplianceReport handles opening a compliance case
+func (h *ComplianceHandler) CreateComplianceReport(ctx context.Context, input *models.ComplianceReportCreateInput) (*models.ComplianceReportResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/compliance/reports").
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_type", input.Body.ReportType).
+		Msg("Compliance report creation requested")
+
+	requestDate, err := time.Parse(time.DateOnly, input.Body.RequestDate)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("request_date must be a date such as 2025-07-01")
+	}
+	report := &dao.ComplianceReport{
+		ReportType:          input.Body.ReportType,
+		RequestingAuthority: nullString(input.Body.RequestingAuthority),
+		RequestID:           nullString(input.Body.RequestID),
+		RequestDate:         requestDate,
+		ScopeDescription:    input.Body.ScopeDescription,
+		LegalBasis:          nullString(input.Body.LegalBasis),
+		CreatedBy:           sql.Null[int64]{V: userCtx.UserID, Valid: true},
+		Notes:               nullString(input.Body.Notes),
+		GagOrder:            input.Body.GagOrder,
+	}
+	if input.Body.DueDate != "" {
+		dueDate, err := time.Parse(time.DateOnly, input.Body.DueDate)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("due_date must be a date such as 2025-07-15")
+		}
+		if dueDate.Before(requestDate) {
+			return nil, huma.Error422UnprocessableEntity("due_date must not be before request_date")
+		}
+		report.DueDate = sql.Null[time.Time]{V: dueDate, Valid: true}
+	}
+	if input.Body.AssignedUserID != 0 {
+		if err := h.checkAssignee(ctx, input.Body.AssignedUserID); err != nil {
+			return nil, err
+		}
+		report.AssignedUserID = sql.Null[int64]{V: input.Body.AssignedUserID, Valid: true}
+	}
+
+	created, err := h.complianceReportDAO.CreateReport(ctx, report)
+	if err != nil {
+		log.Error().Err(err).Int64("admin_id", userCtx.UserID).Msg("Failed to create compliance report")
+		return nil, fmt.Errorf("failed to create compliance report: %w", err)
+	}
+
+	if err := h.recordEvent(ctx, created, userCtx.UserID, dao.ComplianceEventOpened, ""); err != nil {
+		return nil, err
+	}
+	if created.AssignedUserID.Valid {
+		if err := h.recordEvent(ctx, created, userCtx.UserID, dao.ComplianceEventAssigned, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info().
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", created.ReportID.String()).
+		Str("report_type", created.ReportType).
+		Msg("Compliance report opened")
+
+	return models.NewComplianceReportResponse(http.StatusCreated, newComplianceReport(created, time.Now())), nil
+}
+
+// ListComplianceReports handles listing compliance cases
+func (h *ComplianceHandler) ListComplianceReports(ctx context.Context, input *models.ComplianceReportListInput) (*models.ComplianceReportListResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/compliance/reports").
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("status", input.Status).
+		Bool("overdue", input.Overdue).
+		Msg("List compliance reports requested")
+
+	stored, err := h.complianceReportDAO.ListReports(ctx, dao.ComplianceReportFilter{
+		Status:         input.Status,
+		AssignedUserID: input.AssignedUserID,
+		Overdue:        input.Overdue,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list compliance reports")
+		return nil, fmt.Errorf("failed to list compliance reports: %w", err)
+	}
+
+	now := time.Now()
+	reports := make([]models.ComplianceReport, 0, len(stored))
+	for i := range stored {
+		reports = append(reports, newComplianceReport(&stored[i], now))
+	}
+	return models.NewComplianceReportListResponse(reports), nil
+}
+
+// GetComplianceReport handles getting a compliance case with its
+// correlations and history
+func (h *ComplianceHandler) GetComplianceReport(ctx context.Context, input *models.ComplianceReportIDInput) (*models.ComplianceReportDetailResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/compliance/reports/get").
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", input.ReportID).
+		Msg("Compliance report requested")
+
+	report, err := h.getReport(ctx, input.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	return h.detailResponse(ctx, report)
+}
+
+// AssignComplianceReport handles assigning an open compliance case to a
+// member of staff
+func (h *ComplianceHandler) AssignComplianceReport(ctx context.Context, input *models.ComplianceReportAssignInput) (*models.ComplianceReportResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.getReport(ctx, input.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if !report.IsOpen() {
+		return nil, huma.Error409Conflict(fmt.Sprintf("compliance report is %s", report.Status))
+	}
+	if err := h.checkAssignee(ctx, input.Body.AssignedUserID); err != nil {
+		return nil, err
+	}
+
+	assigned, err := h.complianceReportDAO.AssignReport(ctx, report.ReportID, input.Body.AssignedUserID)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", input.ReportID).Msg("Failed to assign compliance report")
+		return nil, fmt.Errorf("failed to assign compliance report: %w", err)
+	}
+	if assigned == nil {
+		return nil, huma.Error409Conflict("compliance report was closed meanwhile")
+	}
+
+	if err := h.recordEvent(ctx, assigned, userCtx.UserID, dao.ComplianceEventAssigned, input.Body.Note); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", input.ReportID).
+		Int64("assigned_user_id", input.Body.AssignedUserID).
+		Msg("Compliance report assigned")
+
+	return models.NewComplianceReportResponse(http.StatusOK, newComplianceReport(assigned, time.Now())), nil
+}
+
+// SetComplianceReportStatus handles moving a compliance case to another
+// status. Pending cases move to in_progress or rejected, cases in progress
+// to completed or rejected; closed cases do not change.
+func (h *ComplianceHandler) SetComplianceReportStatus(ctx context.Context, input *models.ComplianceReportStatusInput) (*models.ComplianceReportResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.getReport(ctx, input.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if !dao.CanTransitionComplianceReport(report.Status, input.Body.Status) {
+		return nil, huma.Error409Conflict(fmt.Sprintf("compliance report cannot move from %s to %s", report.Status, input.Body.Status))
+	}
+
+	updated, err := h.complianceReportDAO.SetStatus(ctx, report.ReportID, report.Status, input.Body.Status)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", input.ReportID).Msg("Failed to set compliance report status")
+		return nil, fmt.Errorf("failed to set compliance report status: %w", err)
+	}
+	if updated == nil {
+		return nil, huma.Error409Conflict("compliance report status changed meanwhile")
+	}
+
+	if err := h.recordEvent(ctx, updated, userCtx.UserID, dao.ComplianceEventStatusChanged, input.Body.Note); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", input.ReportID).
+		Str("previous_status", report.Status).
+		Str("status", updated.Status).
+		Msg("Compliance report status changed")
+
+	return models.NewComplianceReportResponse(http.StatusOK, newComplianceReport(updated, time.Now())), nil
+}
+
+// AttachComplianceCorrelation handles linking an executed identity
+// correlation request to an open compliance case
+func (h *ComplianceHandler) AttachComplianceCorrelation(ctx context.Context, input *models.ComplianceCorrelationAttachInput) (*models.ComplianceReportDetailResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.getReport(ctx, input.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if !report.IsOpen() {
+		return nil, huma.Error409Conflict(fmt.Sprintf("compliance report is %s", report.Status))
+	}
+
+	requestID, err := uuid.FromString(input.Body.RequestID)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("correlation request not found")
+	}
+	request, err := h.correlationRequestDAO.GetRequest(ctx, requestID)
+	if err != nil {
+		log.Error().Err(err).Str("request_id", input.Body.RequestID).Msg("Failed to get correlation request")
+		return nil, fmt.Errorf("failed to get correlation request: %w", err)
+	}
+	if request == nil {
+		return nil, huma.Error422UnprocessableEntity("correlation request not found")
+	}
+	if request.Status != dao.CorrelationRequestExecuted {
+		return nil, huma.Error409Conflict(fmt.Sprintf("correlation request is %s; only executed requests can be attached", request.Status))
+	}
+
+	auditID, err := h.executionAuditID(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := input.Body.CorrelationScope
+	if scope == "" {
+		scope = request.Scope
+	}
+	if err := attachCorrelation(ctx, h.complianceReportDAO, report.ReportID, auditID, scope, userCtx.UserID); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", input.ReportID).
+		Str("request_id", input.Body.RequestID).
+		Str("audit_id", auditID.String()).
+		Msg("Correlation attached to compliance report")
+
+	return h.detailResponse(ctx, report)
+}
+
+// ExportComplianceBundle handles exporting the signed disclosure bundle of a
+// compliance case in progress or completed
+func (h *ComplianceHandler) ExportComplianceBundle(ctx context.Context, input *models.ComplianceBundleInput) (*models.ComplianceBundleResponse, error) {
+	userCtx, err := requireLegalCompliance(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.getReport(ctx, input.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != dao.ComplianceReportInProgress && report.Status != dao.ComplianceReportCompleted {
+		return nil, huma.Error409Conflict(fmt.Sprintf("compliance report is %s; only cases in progress or completed can be exported", report.Status))
+	}
+
+	var recipient *ecdh.PublicKey
+	if input.Body.RecipientPublicKey != "" {
+		recipient, err = disclosure.ParseRecipientKey([]byte(input.Body.RecipientPublicKey))
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("recipient_public_key must be a PEM X25519 public key")
+		}
+	}
+
+	bundle, err := h.exporter.Export(ctx, report, recipient, sql.Null[int64]{V: userCtx.UserID, Valid: true})
+	if errors.Is(err, disclosure.ErrNoCorrelations) {
+		return nil, huma.Error409Conflict("compliance report has no linked correlations")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("report_id", input.ReportID).Msg("Failed to export disclosure bundle")
+		return nil, fmt.Errorf("failed to export disclosure bundle: %w", err)
+	}
+
+	log.Info().
+		Str("component", "compliance_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("report_id", input.ReportID).
+		Str("bundle_id", bundle.Manifest.BundleID).
+		Int("files", len(bundle.Manifest.Files)).
+		Bool("encrypted", bundle.Encrypted).
+		Msg("Disclosure bundle exported")
+
+	return models.NewComplianceBundleResponse(disclosure.FileName(bundle), bundle.Manifest.BundleID,
+		hex.EncodeToString(bundle.ManifestSHA256), bundle.Encrypted, bundle.Data), nil
+}
+
+// attachCorrelation links a correlation audit entry to a report and records
+// the link in the history of the report. Linking an entry twice changes
+// nothing.
+func attachCorrelation(ctx context.Context, complianceReportDAO *dao.ComplianceReportDAO, reportID, auditID uuid.UUID, scope string, actorID int64) error {
+	attached, err := complianceReportDAO.AttachCorrelation(ctx, reportID, auditID, scope)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", reportID.String()).Str("audit_id", auditID.String()).Msg("Failed to attach correlation to compliance report")
+		return fmt.Errorf("failed to attach correlation: %w", err)
+	}
+	if !attached {
+		return nil
+	}
+
+	if err := complianceReportDAO.RecordEvent(ctx, &dao.ComplianceReportEvent{
+		ReportID:  reportID,
+		ActorID:   sql.Null[int64]{V: actorID, Valid: true},
+		EventType: dao.ComplianceEventCorrelationAttached,
+		AuditID:   sql.Null[uuid.UUID]{V: auditID, Valid: true},
+	}); err != nil {
+		log.Error().Err(err).Str("report_id", reportID.String()).Msg("Failed to record compliance report history")
+		return fmt.Errorf("failed to record compliance report history: %w", err)
+	}
+	return nil
+}
+
+// executionAuditID returns the audit entry recording the execution of a
+// correlation request, which holds its results
+func (h *ComplianceHandler) executionAuditID(ctx context.Context, request *dao.CorrelationRequest) (uuid.UUID, error) {
+	entries, err := h.correlationAuditDAO.ListRequestEntries(ctx, request.RequestID)
+	if err != nil {
+		log.Error().Err(err).Str("request_id", request.RequestID.String()).Msg("Failed to list correlation request audit entries")
+		return uuid.Nil, fmt.Errorf("failed to list correlation request audit entries: %w", err)
+	}
+	for i := range entries {
+		if entries[i].RequestStatus.V == dao.CorrelationRequestExecuted {
+			return entries[i].AuditID, nil
+		}
+	}
+	return uuid.Nil, huma.Error409Conflict("correlation request has no recorded execution")
+}
+
+// checkAssignee checks that a case can be assigned to a user
+func (h *ComplianceHandler) checkAssignee(ctx context.Context, userID int64) error {
+	canHandle, err := h.complianceReportDAO.CanHandleReports(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("assigned_user_id", userID).Msg("Failed to check compliance report assignee")
+		return fmt.Errorf("failed to check assignee: %w", err)
+	}
+	if !canHandle {
+		return huma.Error422UnprocessableEntity("assigned_user_id must be an active user with the legal_compliance capability")
+	}
+	return nil
+}
+
+// getReport loads a report
+func (h *ComplianceHandler) getReport(ctx context.Context, id string) (*dao.ComplianceReport, error) {
+	reportID, err := uuid.FromString(id)
+	if err != nil {
+		return nil, huma.Error404NotFound("compliance report not found")
+	}
+
+	report, err := h.complianceReportDAO.GetReport(ctx, reportID)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", id).Msg("Failed to get compliance report")
+		return nil, fmt.Errorf("failed to get compliance report: %w", err)
+	}
+	if report == nil {
+		return nil, huma.Error404NotFound("compliance report not found")
+	}
+	return report, nil
+}
+
+// recordEvent records a change to a report, with the status and assignee it
+// left the report in, in the history of the report
+func (h *ComplianceHandler) recordEvent(ctx context.Context, report *dao.ComplianceReport, actorID int64, eventType, note string) error {
+	err := h.complianceReportDAO.RecordEvent(ctx, &dao.ComplianceReportEvent{
+		ReportID:       report.ReportID,
+		ActorID:        sql.Null[int64]{V: actorID, Valid: true},
+		EventType:      eventType,
+		Status:         sql.Null[string]{V: report.Status, Valid: true},
+		AssignedUserID: report.AssignedUserID,
+		Note:           nullString(note),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("report_id", report.ReportID.String()).Msg("Failed to record compliance report history")
+		return fmt.Errorf("failed to record compliance report history: %w", err)
+	}
+	return nil
+}
+
+// detailResponse loads the correlations and history of a report
+func (h *ComplianceHandler) detailResponse(ctx context.Context, report *dao.ComplianceReport) (*models.ComplianceReportDetailResponse, error) {
+	correlations, err := h.complianceReportDAO.ListCorrelations(ctx, report.ReportID)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", report.ReportID.String()).Msg("Failed to list compliance report correlations")
+		return nil, fmt.Errorf("failed to list compliance report correlations: %w", err)
+	}
+	events, err := h.complianceReportDAO.ListEvents(ctx, report.ReportID)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", report.ReportID.String()).Msg("Failed to list compliance report history")
+		return nil, fmt.Errorf("failed to list compliance report history: %w", err)
+	}
+
+	detail := models.ComplianceReportDetail{
+		ComplianceReport: newComplianceReport(report, time.Now()),
+		Correlations:     make([]models.ComplianceCorrelation, 0, len(correlations)),
+		History:          make([]models.ComplianceReportEvent, 0, len(events)),
+	}
+	for i := range correlations {
+		detail.Correlations = append(detail.Correlations, newComplianceCorrelation(&correlations[i]))
+	}
+	for i := range events {
+		detail.History = append(detail.History, newComplianceReportEvent(&events[i]))
+	}
+	return models.NewComplianceReportDetailResponse(detail), nil
+}
+
+// requireLegalCompliance checks that the caller is on the legal team and
+// passed a recent MFA step-up
+func requireLegalCompliance(authInput *middleware.AuthInput) (*middleware.UserContext, error) {
+	userCtx, err := requireDisclosureAdmin(authInput)
+	if err != nil {
+		return nil, err
+	}
+	if err := middleware.EnforceMFA(userCtx, "legal_compliance"); err != nil {
+		log.Warn().
+			Int64("admin_id", userCtx.UserID).
+			Msg("Compliance case management requires MFA step-up")
+		return nil, huma.Error403Forbidden(middleware.ErrMFARequired.Message)
+	}
+	return userCtx, nil
+}
+
+// nullString converts an optional string, empty when unset
+func nullString(s string) sql.Null[string] {
+	return sql.Null[string]{V: s, Valid: s != ""}
+}
+
+// newComplianceReport converts a report for the API
+func newComplianceReport(report *dao.ComplianceReport, now time.Time) models.ComplianceReport {
+	result := models.ComplianceReport{
+		ReportID:            report.ReportID.String(),
+		ReportType:          report.ReportType,
+		RequestingAuthority: report.RequestingAuthority.V,
+		RequestID:           report.RequestID.V,
+		RequestDate:         report.RequestDate.Format(time.DateOnly),
+		Status:              report.Status,
+		Overdue:             report.Overdue(now),
+		ScopeDescription:    report.ScopeDescription,
+		LegalBasis:          report.LegalBasis.V,
+		Notes:               report.Notes.V,
+		GagOrder:            report.GagOrder,
+		CreatedAt:           report.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:           report.UpdatedAt.Format(time.RFC3339),
+	}
+	if report.DueDate.Valid {
+		result.DueDate = report.DueDate.V.Format(time.DateOnly)
+	}
+	if report.AssignedUserID.Valid {
+		result.AssignedUserID = &report.AssignedUserID.V
+	}
+	if report.CreatedBy.Valid {
+		result.CreatedBy = &report.CreatedBy.V
+	}
+	if report.CompletedAt.Valid {
+		result.CompletedAt = report.CompletedAt.V.Format(time.RFC3339)
+	}
+	return result
+}
+
+// newComplianceCorrelation converts a correlation linked to a report for the API
+func newComplianceCorrelation(correlation *dao.ComplianceCorrelation) models.ComplianceCorrelation {
+	result := models.ComplianceCorrelation{
+		AuditID:            correlation.AuditID.String(),
+		CorrelationType:    correlation.CorrelationType,
+		CorrelationScope:   correlation.CorrelationScope,
+		RequestedPseudonym: correlation.RequestedPseudonym,
+		CorrelatedBy:       correlation.CorrelatedBy,
+		RoleUsed:           correlation.RoleUsed,
+		LinkedAt:           correlation.LinkedAt.Format(time.RFC3339),
+	}
+	if correlation.RequestID.Valid {
+		result.RequestID = correlation.RequestID.V.String()
+	}
+	if correlation.CorrelatedAt.Valid {
+		result.CorrelatedAt = correlation.CorrelatedAt.V.Format(time.RFC3339)
+	}
+	return result
+}
+
+// newComplianceReportEvent converts an entry of the history of a report for the API
+func newComplianceReportEvent(event *dao.ComplianceReportEvent) models.ComplianceReportEvent {
+	result := models.ComplianceReportEvent{
+		EventType: event.EventType,
+		Status:    event.Status.V,
+		Note:      event.Note.V,
+		CreatedAt: event.CreatedAt.Format(time.RFC3339),
+	}
+	if event.ActorID.Valid {
+		result.ActorID = &event.ActorID.V
+	}
+	if event.AssignedUserID.Valid {
+		result.AssignedUserID = &event.AssignedUserID.V
+	}
+	if event.AuditID.Valid {
+		result.AuditID = event.AuditID.V.String()
+	}
+	return result
+}
@@ -183,6 +183,9 @@ func (h *ContentHandler) CreatePost(ctx context.Context, input *models.PostCreat
 		log.Warn().Err(err).Msg("User context not available for post creation")
 		return nil, huma.Error401Unauthorized("Authentication required")
 	}
+	if err := middleware.EnforceVerifiedEmail(ctx, userCtx, "create_post"); err != nil {
+		return nil, verifiedEmailError(err)
+	}
 
 	pseudonymID := userCtx.ActivePseudonymID
 	displayName := userCtx.DisplayName
@@ -337,6 +340,9 @@ func (h *ContentHandler) VoteOnPost(ctx context.Context, input *models.PostVoteI
 		log.Warn().Err(err).Msg("User context not available for voting")
 		return nil, huma.Error401Unauthorized("Authentication required")
 	}
+	if err := middleware.EnforceVerifiedEmail(ctx, userCtx, "vote"); err != nil {
+		return nil, verifiedEmailError(err)
+	}
 
 	pseudonymID := userCtx.ActivePseudonymID
 
@@ -435,6 +441,9 @@ func (h *ContentHandler) CreateComment(ctx context.Context, input *models.Commen
 		log.Warn().Err(err).Msg("User context not available for comment creation")
 		return nil, huma.Error401Unauthorized("Authentication required")
 	}
+	if err := middleware.EnforceVerifiedEmail(ctx, userCtx, "create_comment"); err != nil {
+		return nil, verifiedEmailError(err)
+	}
 
 	pseudonymID := userCtx.ActivePseudonymID
 	displayName := userCtx.DisplayName
@@ -536,6 +545,9 @@ func (h *ContentHandler) VoteOnComment(ctx context.Context, input *models.Commen
 		log.Warn().Err(err).Msg("User context not available for voting")
 		return nil, huma.Error401Unauthorized("Authentication required")
 	}
+	if err := middleware.EnforceVerifiedEmail(ctx, userCtx, "vote"); err != nil {
+		return nil, verifiedEmailError(err)
+	}
 
 	pseudonymID := userCtx.ActivePseudonymID
 
@@ -5,16 +5,22 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"net/http"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
 	"github.com/gofrs/uuid/v5"
 	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/api/services"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/correlation"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/mailer"
 	"github.com/rs/zerolog/log"
 	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/types"
@@ -22,25 +28,48 @@ import (
 
 // CorrelationHandler handles administrative correlation requests
 type CorrelationHandler struct {
-	db                 bob.Executor
-	ibeSystem          *ibe.IBESystem
-	securePseudonymDAO *dao.SecurePseudonymDAO
-	identityMappingDAO *dao.IdentityMappingDAO
-	postDAO            *dao.PostDAO
-	commentDAO         *dao.CommentDAO
-	subforumDAO        *dao.SubforumDAO
+	db                    bob.Executor
+	approvalPolicy        *config.CorrelationApprovalPolicy
+	policy                *correlation.Policy
+	ibeSystem             *ibe.IBESystem
+	securePseudonymDAO    *dao.SecurePseudonymDAO
+	identityMappingDAO    *dao.IdentityMappingDAO
+	postDAO               *dao.PostDAO
+	commentDAO            *dao.CommentDAO
+	subforumDAO           *dao.SubforumDAO
+	roleKeyDAO            *dao.RoleKeyDAO
+	correlationRequestDAO *dao.CorrelationRequestDAO
+	correlationAuditDAO   *dao.CorrelationAuditDAO
+	keyUsageAuditDAO      *dao.KeyUsageAuditDAO
+	complianceReportDAO   *dao.ComplianceReportDAO
+	breakGlassPolicy      *config.BreakGlassPolicy
+	breakGlassReviewDAO   *dao.BreakGlassReviewDAO
+	breakGlass            *correlation.BreakGlass
+	roleKeyService        *services.RoleKeyService
 }
 
 // NewCorrelationHandler creates a new correlation handler
-func NewCorrelationHandler(db bob.Executor, ibeSystem *ibe.IBESystem, securePseudonymDAO *dao.SecurePseudonymDAO, identityMappingDAO *dao.IdentityMappingDAO, postDAO *dao.PostDAO, commentDAO *dao.CommentDAO, subforumDAO *dao.SubforumDAO) *CorrelationHandler {
+func NewCorrelationHandler(cfg *config.Config, db bob.Executor, auditChainDAO *dao.AuditChainDAO, ibeSystem *ibe.IBESystem, securePseudonymDAO *dao.SecurePseudonymDAO, identityMappingDAO *dao.IdentityMappingDAO, postDAO *dao.PostDAO, commentDAO *dao.CommentDAO, subforumDAO *dao.SubforumDAO, mail mailer.Mailer) *CorrelationHandler {
+	roleKeyDAO := dao.NewRoleKeyDAO(db)
 	return &CorrelationHandler{
-		db:                 db,
-		ibeSystem:          ibeSystem,
-		securePseudonymDAO: securePseudonymDAO,
-		identityMappingDAO: identityMappingDAO,
-		postDAO:            postDAO,
-		commentDAO:         commentDAO,
-		subforumDAO:        subforumDAO,
+		db:                    db,
+		approvalPolicy:        &cfg.Security.CorrelationApproval,
+		policy:                correlation.NewPolicy(db),
+		ibeSystem:             ibeSystem,
+		securePseudonymDAO:    securePseudonymDAO,
+		identityMappingDAO:    identityMappingDAO,
+		postDAO:               postDAO,
+		commentDAO:            commentDAO,
+		subforumDAO:           subforumDAO,
+		roleKeyDAO:            roleKeyDAO,
+		correlationRequestDAO: dao.NewCorrelationRequestDAO(db),
+		correlationAuditDAO:   dao.NewCorrelationAuditDAO(db, auditChainDAO),
+		keyUsageAuditDAO:      dao.NewKeyUsageAuditDAO(db, auditChainDAO),
+		complianceReportDAO:   dao.NewComplianceReportDAO(db),
+		breakGlassPolicy:      &cfg.Security.BreakGlass,
+		breakGlassReviewDAO:   dao.NewBreakGlassReviewDAO(db),
+		breakGlass:            correlation.NewBreakGlass(db, correlation.NewMailNotifier(db, mail, cfg.Security.BreakGlass.ReviewerRoles)),
+		roleKeyService:        services.NewRoleKeyService(roleKeyDAO, dao.NewUserDAO(db), ibeSystem),
 	}
 }
 
@@ -86,60 +115,46 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 		return nil, fmt.Errorf("pseudonym not found")
 	}
 
-	// Generate correlation ID
-	correlationID := uuid.Must(uuid.NewV4()).String()
-
-	// Perform IBE correlation
-	// Get the identity mapping for the requested pseudonym
-	identityMapping, err := h.identityMappingDAO.GetIdentityMappingByPseudonymID(ctx, input.Body.RequestedPseudonym)
+	// The role definitions decide the role, subforum and time window the
+	// correlation is performed under
+	grant, err := h.policy.AuthorizeFingerprint(ctx, adminID, userCtx.Roles, int32(input.Body.SubforumID), pseudonym.PseudonymID)
 	if err != nil {
-		log.Error().Err(err).
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
-			Msg("Failed to get identity mapping")
-		return nil, fmt.Errorf("failed to get identity mapping: %w", err)
+		return nil, policyError(err, adminID)
 	}
-	if identityMapping == nil {
-		log.Warn().
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
-			Msg("Identity mapping not found")
-		return nil, fmt.Errorf("identity mapping not found for pseudonym")
+	if err := h.policy.CheckQuota(ctx, adminID, grant.Role); err != nil {
+		return nil, policyError(err, adminID)
 	}
 
-	// Generate admin key for decryption based on user's role
-	adminKey := h.ibeSystem.GenerateRoleKey("moderator", "subforum_correlation", time.Now().AddDate(0, 1, 0))
+	// Generate correlation ID
+	correlationID := uuid.Must(uuid.NewV4()).String()
 
-	// Decrypt the identity mapping to get the fingerprint
-	decryptedMapping, _, err := h.ibeSystem.DecryptIdentity(identityMapping.EncryptedRealIdentity, adminKey)
+	// Perform IBE correlation: decrypt the identity mapping of the requested
+	// pseudonym to get the fingerprint
+	decryptedMapping, err := h.decryptIdentity(ctx, adminID, grant, pseudonym.PseudonymID)
 	if err != nil {
-		log.Error().Err(err).
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
-			Msg("Failed to decrypt identity mapping")
-		return nil, fmt.Errorf("failed to decrypt identity mapping: %w", err)
+		return nil, err
 	}
-
-	// Parse the decrypted mapping to extract fingerprint
-	// Format should be "fingerprint:pseudonymID"
-	mappingParts := strings.Split(decryptedMapping, ":")
-	if len(mappingParts) != 2 {
-		log.Error().
-			Str("decrypted_mapping", decryptedMapping).
-			Msg("Invalid decrypted mapping format")
-		return nil, fmt.Errorf("invalid decrypted mapping format")
-	}
-	fingerprint := mappingParts[0]
+	fingerprint := decryptedMapping.Fingerprint
 
 	// Find all pseudonyms that share the same fingerprint
 	relatedMappings, err := h.identityMappingDAO.GetIdentityMappingsByFingerprint(ctx, fingerprint)
 	if err != nil {
 		log.Error().Err(err).
-			Str("fingerprint", fingerprint).
+			Str("requested_pseudonym", input.Body.RequestedPseudonym).
 			Msg("Failed to get related identity mappings")
 		return nil, fmt.Errorf("failed to get related identity mappings: %w", err)
 	}
 
-	// Build correlation results
+	// Build correlation results. A pseudonym has several identity mappings,
+	// one per role key.
 	results := make([]models.CorrelationResult, 0, len(relatedMappings))
+	seen := make(map[string]bool, len(relatedMappings))
 	for _, mapping := range relatedMappings {
+		if seen[mapping.PseudonymID] {
+			continue
+		}
+		seen[mapping.PseudonymID] = true
+
 		// Get pseudonym details
 		pseudonym, err := h.securePseudonymDAO.GetPseudonymByID(ctx, mapping.PseudonymID)
 		if err != nil {
@@ -153,6 +168,17 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 			continue
 		}
 
+		// Subforum-specific roles only see the pseudonyms active in their
+		// subforum, and roles with a limited time window the recently active ones
+		active, err := h.policy.Active(ctx, grant, mapping.PseudonymID)
+		if err != nil {
+			log.Error().Err(err).Str("pseudonym_id", mapping.PseudonymID).Msg("Failed to check pseudonym activity")
+			return nil, fmt.Errorf("failed to check pseudonym activity: %w", err)
+		}
+		if !active {
+			continue
+		}
+
 		// Get actual post/comment counts for the specific subforum
 		subforumID := int32(input.Body.SubforumID)
 		postsInSubforum, err := h.postDAO.CountPostsByPseudonymInSubforum(ctx, mapping.PseudonymID, subforumID)
@@ -186,9 +212,6 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 		results = append(results, result)
 	}
 
-	// Create audit record
-	auditID := uuid.Must(uuid.NewV4())
-
 	// Serialize correlation results for audit
 	correlationResultJSON, err := json.Marshal(results)
 	if err != nil {
@@ -205,9 +228,6 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 	correlationResult := sql.Null[types.JSON[json.RawMessage]]{}
 	correlationResult.Scan(correlationResultJSON)
 
-	timestamp := sql.Null[time.Time]{}
-	timestamp.Scan(time.Now())
-
 	incidentID := sql.Null[string]{}
 	if input.Body.IncidentID != "" {
 		incidentID.Scan(input.Body.IncidentID)
@@ -217,38 +237,37 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 	requestSource.Scan("manual")
 
 	// Create correlation audit record
-	auditRecord := &dbmodels.CorrelationAuditSetter{
-		AuditID:              &auditID,
-		UserID:               &adminID,
-		PseudonymID:          &pseudonym.PseudonymID,
-		AdminUsername:        &userCtx.Email,
-		RoleUsed:             &[]string{"moderator"}[0],
-		RequestedPseudonym:   &input.Body.RequestedPseudonym,
-		RequestedFingerprint: &requestedFingerprint,
-		Justification:        &input.Body.Justification,
-		CorrelationType:      &[]string{"fingerprint"}[0],
-		CorrelationResult:    &correlationResult,
-		Timestamp:            &timestamp,
-		IncidentID:           &incidentID,
-		RequestSource:        &requestSource,
+	auditRecord := &dao.CorrelationAuditEntry{
+		UserID:               adminID,
+		PseudonymID:          pseudonym.PseudonymID,
+		AdminUsername:        userCtx.Email,
+		RoleUsed:             grant.Role,
+		RequestedPseudonym:   input.Body.RequestedPseudonym,
+		RequestedFingerprint: requestedFingerprint,
+		Justification:        input.Body.Justification,
+		CorrelationType:      "fingerprint",
+		CorrelationResult:    correlationResult,
+		IncidentID:           incidentID,
+		RequestSource:        requestSource,
+		AuditClient:          auditClient(ctx),
 	}
 
 	// Store audit record in database
-	_, err = dbmodels.CorrelationAudits.Insert(auditRecord).One(ctx, h.db)
-	if err != nil {
-		log.Error().Err(err).
-			Str("audit_id", auditID.String()).
-			Msg("Failed to create correlation audit record")
+	if err := h.correlationAuditDAO.RecordEntry(ctx, auditRecord); err != nil {
+		log.Error().Err(err).Msg("Failed to create correlation audit record")
 		return nil, fmt.Errorf("failed to create audit record: %w", err)
 	}
+	auditID := auditRecord.AuditID
 
-	response := models.NewFingerprintCorrelationResponse(correlationID, results, auditID.String())
+	response := models.NewFingerprintCorrelationResponse(correlationID, grant.Scope, grant.TimeWindow, results, auditID.String())
 
 	log.Info().
 		Str("endpoint", "admin/correlation/fingerprint").
 		Str("component", "handler").
 		Int64("admin_id", adminID).
 		Str("correlation_id", correlationID).
+		Str("role", grant.Role).
+		Str("scope", grant.Scope).
 		Int("results_count", len(results)).
 		Str("audit_id", auditID.String()).
 		Msg("Fingerprint correlation completed")
@@ -256,8 +275,10 @@ func (h *CorrelationHandler) RequestFingerprintCorrelation(ctx context.Context,
 	return response, nil
 }
 
-// RequestIdentityCorrelation handles identity-based correlation for platform-wide investigations
-func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, input *models.IdentityCorrelationInput) (*models.IdentityCorrelationResponse, error) {
+// RequestIdentityCorrelation handles requests for identity-based correlation
+// for platform-wide investigations. The request is recorded as pending; a
+// different admin must approve it before it can be executed.
+func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, input *models.IdentityCorrelationInput) (*models.CorrelationRequestResponse, error) {
 	// Extract admin from context (from admin JWT token)
 	userCtx, err := middleware.ExtractUserFromContext(ctx)
 	if err != nil {
@@ -278,12 +299,15 @@ func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, inp
 		Str("scope", input.Body.Scope).
 		Msg("Identity correlation requested")
 
-	// Validate admin permissions for platform-wide correlation
-	if !userCtx.HasCapability("correlate_identities") {
-		log.Warn().
-			Int64("admin_id", adminID).
-			Msg("User lacks correlate_identities capability")
-		return nil, fmt.Errorf("insufficient permissions: correlate_identities capability required")
+	if err := requireIdentityCorrelation(userCtx); err != nil {
+		return nil, err
+	}
+	grant, err := h.policy.AuthorizeIdentity(ctx, userCtx.Roles, input.Body.Scope, input.Body.RequestedPseudonym)
+	if err != nil {
+		return nil, policyError(err, adminID)
+	}
+	if err := h.policy.CheckQuota(ctx, adminID, grant.Role); err != nil {
+		return nil, policyError(err, adminID)
 	}
 
 	// Check if pseudonym exists
@@ -299,60 +323,352 @@ func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, inp
 		return nil, fmt.Errorf("pseudonym not found")
 	}
 
-	// Generate correlation ID
-	correlationID := uuid.Must(uuid.NewV4()).String()
+	requestedFingerprint := sql.Null[string]{}
+	if input.Body.RequestedFingerprint != "" {
+		requestedFingerprint.Scan(input.Body.RequestedFingerprint)
+	}
+
+	complianceReportID, err := h.openComplianceReportID(ctx, input.Body.ComplianceReportID)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := h.correlationRequestDAO.CreateRequest(ctx, &dao.CorrelationRequest{
+		CorrelationType:      "identity",
+		RequesterID:          adminID,
+		RequesterUsername:    userCtx.Email,
+		RequesterRole:        grant.Role,
+		RequestedPseudonym:   pseudonym.PseudonymID,
+		RequestedFingerprint: requestedFingerprint,
+		Scope:                input.Body.Scope,
+		Justification:        input.Body.Justification,
+		LegalBasis:           input.Body.LegalBasis,
+		IncidentID:           input.Body.IncidentID,
+		ComplianceReportID:   complianceReportID,
+	}, time.Now().Add(h.approvalPolicy.RequestTTL))
+	if err != nil {
+		log.Error().Err(err).Int64("admin_id", adminID).Msg("Failed to create correlation request")
+		return nil, fmt.Errorf("failed to create correlation request: %w", err)
+	}
+
+	if _, err := h.auditCorrelationRequest(ctx, request, adminID, userCtx.Email, request.RequesterRole, "manual", nil); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/identity").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("request_id", request.RequestID.String()).
+		Time("expires_at", request.ExpiresAt).
+		Msg("Identity correlation request awaiting approval")
+
+	return models.NewCorrelationRequestResponse(http.StatusAccepted, newCorrelationRequest(request)), nil
+}
+
+// ListCorrelationRequests handles listing identity correlation requests for review
+func (h *CorrelationHandler) ListCorrelationRequests(ctx context.Context, input *models.CorrelationRequestListInput) (*models.CorrelationRequestListResponse, error) {
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/requests").
+		Str("component", "handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("status", input.Status).
+		Msg("List correlation requests requested")
+
+	if !userCtx.HasCapability("correlate_identities") {
+		return nil, huma.Error403Forbidden("insufficient permissions: correlate_identities capability required")
+	}
+
+	if err := h.expireCorrelationRequests(ctx); err != nil {
+		return nil, err
+	}
+
+	requests, err := h.correlationRequestDAO.ListRequests(ctx, input.Status)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list correlation requests")
+		return nil, fmt.Errorf("failed to list correlation requests: %w", err)
+	}
+
+	list := make([]models.CorrelationRequest, 0, len(requests))
+	for i := range requests {
+		list = append(list, newCorrelationRequest(&requests[i]))
+	}
+	return models.NewCorrelationRequestListResponse(list), nil
+}
+
+// ApproveCorrelationRequest handles approving a pending identity correlation request
+func (h *CorrelationHandler) ApproveCorrelationRequest(ctx context.Context, input *models.CorrelationReviewInput) (*models.CorrelationRequestResponse, error) {
+	return h.reviewCorrelationRequest(ctx, input, dao.CorrelationRequestApproved)
+}
+
+// RejectCorrelationRequest handles rejecting a pending identity correlation request
+func (h *CorrelationHandler) RejectCorrelationRequest(ctx context.Context, input *models.CorrelationReviewInput) (*models.CorrelationRequestResponse, error) {
+	return h.reviewCorrelationRequest(ctx, input, dao.CorrelationRequestRejected)
+}
+
+// reviewCorrelationRequest approves or rejects a pending request. The
+// reviewer must hold an approver role and must not be the requester.
+func (h *CorrelationHandler) reviewCorrelationRequest(ctx context.Context, input *models.CorrelationReviewInput, decision string) (*models.CorrelationRequestResponse, error) {
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	adminID := userCtx.UserID
+
+	log.Info().
+		Str("endpoint", "admin/correlation/requests/review").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("request_id", input.RequestID).
+		Str("decision", decision).
+		Msg("Correlation request review requested")
 
-	// Perform IBE identity correlation
-	// Get the identity mapping for the requested pseudonym
-	identityMapping, err := h.identityMappingDAO.GetIdentityMappingByPseudonymID(ctx, input.Body.RequestedPseudonym)
+	if err := requireIdentityCorrelation(userCtx); err != nil {
+		return nil, err
+	}
+	reviewerRole, ok := h.approverRole(userCtx)
+	if !ok {
+		log.Warn().
+			Int64("admin_id", adminID).
+			Strs("approver_roles", h.approvalPolicy.ApproverRoles).
+			Msg("User lacks a correlation approver role")
+		return nil, huma.Error403Forbidden("insufficient permissions: a correlation approver role is required")
+	}
+
+	request, err := h.getCorrelationRequest(ctx, input.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterID == adminID {
+		log.Warn().
+			Int64("admin_id", adminID).
+			Str("request_id", input.RequestID).
+			Msg("Requester attempted to review their own correlation request")
+		return nil, huma.Error403Forbidden("correlation requests must be reviewed by a different admin")
+	}
+	if request.Status != dao.CorrelationRequestPending {
+		return nil, huma.Error409Conflict(fmt.Sprintf("correlation request is %s", request.Status))
+	}
+
+	var reviewed *dao.CorrelationRequest
+	if decision == dao.CorrelationRequestApproved {
+		reviewed, err = h.correlationRequestDAO.ApproveRequest(ctx, request.RequestID, adminID, reviewerRole, input.Body.Comment, time.Now().Add(h.approvalPolicy.ApprovalTTL))
+	} else {
+		reviewed, err = h.correlationRequestDAO.RejectRequest(ctx, request.RequestID, adminID, reviewerRole, input.Body.Comment)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("request_id", input.RequestID).Msg("Failed to review correlation request")
+		return nil, fmt.Errorf("failed to review correlation request: %w", err)
+	}
+	if reviewed == nil {
+		return nil, huma.Error409Conflict("correlation request is no longer pending")
+	}
+
+	if _, err := h.auditCorrelationRequest(ctx, reviewed, adminID, userCtx.Email, reviewerRole, "manual", nil); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/correlation/requests/review").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Int64("requester_id", reviewed.RequesterID).
+		Str("request_id", input.RequestID).
+		Str("status", reviewed.Status).
+		Msg("Correlation request reviewed")
+
+	return models.NewCorrelationRequestResponse(http.StatusOK, newCorrelationRequest(reviewed)), nil
+}
+
+// ExecuteIdentityCorrelation handles executing an approved identity
+// correlation request. Only the requester can execute it, once, before the
+// approval expires; only then is the decryption key derived.
+func (h *CorrelationHandler) ExecuteIdentityCorrelation(ctx context.Context, input *models.CorrelationRequestIDInput) (*models.IdentityCorrelationResponse, error) {
+	// Extract admin from context (from admin JWT token)
+	userCtx, err := middleware.ExtractUserFromContext(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to extract user from context")
+		return nil, fmt.Errorf("authentication required: %w", err)
+	}
+
+	adminID := userCtx.UserID
+
+	log.Info().
+		Str("endpoint", "admin/correlation/requests/execute").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Str("request_id", input.RequestID).
+		Msg("Identity correlation execution requested")
+
+	if err := requireIdentityCorrelation(userCtx); err != nil {
+		return nil, err
+	}
+
+	request, err := h.getCorrelationRequest(ctx, input.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterID != adminID {
+		return nil, huma.Error403Forbidden("only the requester can execute a correlation request")
+	}
+	if request.Status != dao.CorrelationRequestApproved {
+		return nil, huma.Error409Conflict(fmt.Sprintf("correlation request is %s", request.Status))
+	}
+	// The requester's roles may have changed since the request
+	grant, err := h.policy.AuthorizeIdentity(ctx, userCtx.Roles, request.Scope, request.RequestedPseudonym)
+	if err != nil {
+		return nil, policyError(err, adminID)
+	}
+	// Quotas count executions, so they are checked again before this one
+	if err := h.policy.CheckQuota(ctx, adminID, grant.Role); err != nil {
+		return nil, policyError(err, adminID)
+	}
+
+	// Claim the approval before deriving any key, so it is used only once
+	request, err = h.correlationRequestDAO.ExecuteRequest(ctx, request.RequestID, adminID)
+	if err != nil {
+		log.Error().Err(err).Str("request_id", input.RequestID).Msg("Failed to execute correlation request")
+		return nil, fmt.Errorf("failed to execute correlation request: %w", err)
+	}
+	if request == nil {
+		return nil, huma.Error409Conflict("correlation request is no longer approved")
+	}
+
+	results, err := h.correlateIdentity(ctx, request, grant)
+	if err != nil {
+		return nil, err
+	}
+
+	auditID, err := h.auditCorrelationRequest(ctx, request, adminID, userCtx.Email, grant.Role, "manual", results)
+	if err != nil {
+		return nil, err
+	}
+
+	// The correlation was performed and audited; a failed link to its
+	// compliance report is logged and can be made again by the legal team
+	if request.ComplianceReportID.Valid {
+		_ = attachCorrelation(ctx, h.complianceReportDAO, request.ComplianceReportID.V, auditID, request.Scope, adminID)
+	}
+
+	response := models.NewIdentityCorrelationResponse(request.RequestID.String(), grant.Scope, grant.TimeWindow, results, auditID.String())
+
+	log.Info().
+		Str("endpoint", "admin/correlation/requests/execute").
+		Str("component", "handler").
+		Int64("admin_id", adminID).
+		Int64("reviewer_id", request.ReviewerID.V).
+		Str("request_id", request.RequestID.String()).
+		Int("results_count", len(results)).
+		Str("audit_id", auditID.String()).
+		Msg("Identity correlation completed")
+
+	return response, nil
+}
+
+// correlateIdentity decrypts the identity behind the requested pseudonym and
+// finds every pseudonym of the same user (platform-wide correlation)
+func (h *CorrelationHandler) correlateIdentity(ctx context.Context, request *dao.CorrelationRequest, grant *correlation.Grant) ([]models.CorrelationResult, error) {
+	// Perform IBE identity correlation: decrypt the identity mapping of the
+	// requested pseudonym to get the fingerprint
+	decryptedMapping, err := h.decryptIdentity(ctx, request.RequesterID, grant, request.RequestedPseudonym)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.relatedIdentities(ctx, grant, request.RequestedPseudonym, decryptedMapping.Fingerprint)
+}
+
+// decryptIdentity decrypts the identity mapping of a pseudonym that was
+// encrypted for the role of a grant, with the role's stored correlation key
+func (h *CorrelationHandler) decryptIdentity(ctx context.Context, userID int64, grant *correlation.Grant, pseudonymID string) (*ibe.IdentityMapping, error) {
+	roleKeys, err := h.roleKeyDAO.GetRoleKeyVersions(ctx, grant.Role, dao.KeyScopeCorrelation)
 	if err != nil {
 		log.Error().Err(err).
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
+			Str("role", grant.Role).
+			Msg("No correlation key for role")
+		return nil, fmt.Errorf("no correlation key for role %s: %w", grant.Role, err)
+	}
+	return h.decryptIdentityWithKeys(ctx, userID, grant.Role, pseudonymID, roleKeys)
+}
+
+// decryptIdentityWithKeys decrypts the identity mapping of a pseudonym that
+// was encrypted for a role, with the role key among roleKeys of the mapping's
+// key version, and records the decryption in the key usage audit trail
+func (h *CorrelationHandler) decryptIdentityWithKeys(ctx context.Context, userID int64, role string, pseudonymID string, roleKeys []*dbmodels.RoleKey) (*ibe.IdentityMapping, error) {
+	identityMapping, err := h.identityMappingDAO.GetRoleIdentityMapping(ctx, pseudonymID, role, dao.KeyScopeCorrelation)
+	if err != nil {
+		log.Error().Err(err).
+			Str("requested_pseudonym", pseudonymID).
 			Msg("Failed to get identity mapping")
 		return nil, fmt.Errorf("failed to get identity mapping: %w", err)
 	}
 	if identityMapping == nil {
 		log.Warn().
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
+			Str("requested_pseudonym", pseudonymID).
+			Str("role", role).
 			Msg("Identity mapping not found")
 		return nil, fmt.Errorf("identity mapping not found for pseudonym")
 	}
 
-	// Generate admin key for decryption based on user's role
-	adminKey := h.ibeSystem.GenerateRoleKey("site_admin", "full_correlation", time.Now().AddDate(0, 1, 0))
+	var roleKey *dbmodels.RoleKey
+	for _, key := range roleKeys {
+		if key.KeyVersion == identityMapping.KeyVersion {
+			roleKey = key
+			break
+		}
+	}
+	if roleKey == nil {
+		log.Error().
+			Str("requested_pseudonym", pseudonymID).
+			Str("role", role).
+			Int32("key_version", identityMapping.KeyVersion).
+			Msg("No correlation key of the identity mapping's key version")
+		return nil, fmt.Errorf("no correlation key of key version %d for role %s", identityMapping.KeyVersion, role)
+	}
 
-	// Decrypt the identity mapping to get the fingerprint
-	decryptedMapping, _, err := h.ibeSystem.DecryptIdentity(identityMapping.EncryptedRealIdentity, adminKey)
+	decryptedMapping, err := h.ibeSystem.DecryptIdentity(identityMapping.EncryptedRealIdentity, identityMapping.PseudonymID, int(identityMapping.KeyVersion), roleKey.KeyData)
+	if auditErr := h.recordKeyUsage(ctx, roleKey.KeyID, userID, pseudonymID, err); auditErr != nil {
+		return nil, auditErr
+	}
 	if err != nil {
 		log.Error().Err(err).
-			Str("requested_pseudonym", input.Body.RequestedPseudonym).
+			Str("requested_pseudonym", pseudonymID).
 			Msg("Failed to decrypt identity mapping")
 		return nil, fmt.Errorf("failed to decrypt identity mapping: %w", err)
 	}
+	return decryptedMapping, nil
+}
 
-	// Parse the decrypted mapping to extract fingerprint
-	// Format should be "fingerprint:pseudonymID"
-	mappingParts := strings.Split(decryptedMapping, ":")
-	if len(mappingParts) != 2 {
-		log.Error().
-			Str("decrypted_mapping", decryptedMapping).
-			Msg("Invalid decrypted mapping format")
-		return nil, fmt.Errorf("invalid decrypted mapping format")
-	}
-	fingerprint := mappingParts[0]
-
+// relatedIdentities finds every pseudonym within the reach of a grant with a
+// fingerprint, decrypted from the identity mapping of the requested pseudonym
+func (h *CorrelationHandler) relatedIdentities(ctx context.Context, grant *correlation.Grant, requestedPseudonym, fingerprint string) ([]models.CorrelationResult, error) {
 	// Find all pseudonyms that share the same fingerprint (platform-wide correlation)
 	relatedMappings, err := h.identityMappingDAO.GetIdentityMappingsByFingerprint(ctx, fingerprint)
 	if err != nil {
 		log.Error().Err(err).
-			Str("fingerprint", fingerprint).
+			Str("requested_pseudonym", requestedPseudonym).
 			Msg("Failed to get related identity mappings")
 		return nil, fmt.Errorf("failed to get related identity mappings: %w", err)
 	}
 
-	// Build correlation results
+	// Build correlation results. A pseudonym has several identity mappings,
+	// one per role key.
 	results := make([]models.CorrelationResult, 0, len(relatedMappings))
+	seen := make(map[string]bool, len(relatedMappings))
 	for _, mapping := range relatedMappings {
+		if seen[mapping.PseudonymID] {
+			continue
+		}
+		seen[mapping.PseudonymID] = true
+
 		// Get pseudonym details
 		pseudonym, err := h.securePseudonymDAO.GetPseudonymByID(ctx, mapping.PseudonymID)
 		if err != nil {
@@ -366,6 +682,16 @@ func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, inp
 			continue
 		}
 
+		// Roles with a limited time window only see recently active pseudonyms
+		active, err := h.policy.Active(ctx, grant, mapping.PseudonymID)
+		if err != nil {
+			log.Error().Err(err).Str("pseudonym_id", mapping.PseudonymID).Msg("Failed to check pseudonym activity")
+			return nil, fmt.Errorf("failed to check pseudonym activity: %w", err)
+		}
+		if !active {
+			continue
+		}
+
 		// Get actual post/comment counts and subforum activity
 		totalPosts, err := h.postDAO.CountPostsByPseudonym(ctx, mapping.PseudonymID)
 		if err != nil {
@@ -452,80 +778,227 @@ func (h *CorrelationHandler) RequestIdentityCorrelation(ctx context.Context, inp
 		results = append(results, result)
 	}
 
-	// Create audit record
-	auditID := uuid.Must(uuid.NewV4())
+	return results, nil
+}
 
-	// Serialize correlation results for audit
-	correlationResultJSON, err := json.Marshal(results)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to marshal correlation results")
-		return nil, fmt.Errorf("failed to serialize correlation results: %w", err)
+// requireIdentityCorrelation checks that a user may take part in identity
+// correlation, which requires a recent MFA step-up
+func requireIdentityCorrelation(userCtx *middleware.UserContext) error {
+	if !userCtx.HasCapability("correlate_identities") {
+		log.Warn().
+			Int64("admin_id", userCtx.UserID).
+			Msg("User lacks correlate_identities capability")
+		return huma.Error403Forbidden("insufficient permissions: correlate_identities capability required")
+	}
+	if err := middleware.EnforceMFA(userCtx, "correlate_identities"); err != nil {
+		log.Warn().
+			Int64("admin_id", userCtx.UserID).
+			Msg("Identity correlation requires MFA step-up")
+		return huma.Error403Forbidden(middleware.ErrMFARequired.Message)
 	}
+	return nil
+}
 
-	// Create SQL null types
-	requestedFingerprint := sql.Null[string]{}
-	if input.Body.RequestedFingerprint != "" {
-		requestedFingerprint.Scan(input.Body.RequestedFingerprint)
+// policyError turns a correlation policy failure into a response error
+func policyError(err error, adminID int64) error {
+	if errors.Is(err, correlation.ErrNotAllowed) {
+		log.Warn().Err(err).Int64("admin_id", adminID).Msg("Correlation denied by role policy")
+		return huma.Error403Forbidden(err.Error())
+	}
+	if errors.Is(err, correlation.ErrQuotaExceeded) {
+		return huma.Error429TooManyRequests(err.Error())
 	}
+	log.Error().Err(err).Int64("admin_id", adminID).Msg("Failed to check correlation policy")
+	return fmt.Errorf("failed to check correlation policy: %w", err)
+}
 
-	correlationResult := sql.Null[types.JSON[json.RawMessage]]{}
-	correlationResult.Scan(correlationResultJSON)
+// approverRole returns the first of a user's roles that may review
+// correlation requests
+func (h *CorrelationHandler) approverRole(userCtx *middleware.UserContext) (string, bool) {
+	for _, role := range h.approvalPolicy.ApproverRoles {
+		if userCtx.HasRole(role) {
+			return role, true
+		}
+	}
+	return "", false
+}
 
-	timestamp := sql.Null[time.Time]{}
-	timestamp.Scan(time.Now())
+// openComplianceReportID checks that a correlation request names an open
+// compliance report, if it names one
+func (h *CorrelationHandler) openComplianceReportID(ctx context.Context, id string) (sql.Null[uuid.UUID], error) {
+	if id == "" {
+		return sql.Null[uuid.UUID]{}, nil
+	}
 
-	legalBasis := sql.Null[string]{}
-	if input.Body.LegalBasis != "" {
-		legalBasis.Scan(input.Body.LegalBasis)
+	reportID, err := uuid.FromString(id)
+	if err != nil {
+		return sql.Null[uuid.UUID]{}, huma.Error422UnprocessableEntity("compliance report not found")
 	}
+	report, err := h.complianceReportDAO.GetReport(ctx, reportID)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", id).Msg("Failed to get compliance report")
+		return sql.Null[uuid.UUID]{}, fmt.Errorf("failed to get compliance report: %w", err)
+	}
+	if report == nil {
+		return sql.Null[uuid.UUID]{}, huma.Error422UnprocessableEntity("compliance report not found")
+	}
+	if !report.IsOpen() {
+		return sql.Null[uuid.UUID]{}, huma.Error409Conflict(fmt.Sprintf("compliance report is %s", report.Status))
+	}
+	return sql.Null[uuid.UUID]{V: reportID, Valid: true}, nil
+}
 
-	incidentID := sql.Null[string]{}
-	if input.Body.IncidentID != "" {
-		incidentID.Scan(input.Body.IncidentID)
+// requesterRole returns the admin role a user requests correlation in
+func requesterRole(userCtx *middleware.UserContext) string {
+	for _, role := range userCtx.Roles {
+		if role != "user" {
+			return role
+		}
 	}
+	return "user"
+}
 
-	requestSource := sql.Null[string]{}
-	requestSource.Scan("manual")
+// getCorrelationRequest loads a request after expiring overdue ones
+func (h *CorrelationHandler) getCorrelationRequest(ctx context.Context, id string) (*dao.CorrelationRequest, error) {
+	requestID, err := uuid.FromString(id)
+	if err != nil {
+		return nil, huma.Error404NotFound("correlation request not found")
+	}
+	if err := h.expireCorrelationRequests(ctx); err != nil {
+		return nil, err
+	}
 
-	// Create correlation audit record
-	auditRecord := &dbmodels.CorrelationAuditSetter{
-		AuditID:              &auditID,
-		UserID:               &adminID,
-		PseudonymID:          &pseudonym.PseudonymID,
-		AdminUsername:        &userCtx.Email,
-		RoleUsed:             &[]string{"site_admin"}[0],
-		RequestedPseudonym:   &input.Body.RequestedPseudonym,
-		RequestedFingerprint: &requestedFingerprint,
-		Justification:        &input.Body.Justification,
-		CorrelationType:      &[]string{"identity"}[0],
-		CorrelationResult:    &correlationResult,
-		Timestamp:            &timestamp,
-		LegalBasis:           &legalBasis,
-		IncidentID:           &incidentID,
-		RequestSource:        &requestSource,
+	request, err := h.correlationRequestDAO.GetRequest(ctx, requestID)
+	if err != nil {
+		log.Error().Err(err).Str("request_id", id).Msg("Failed to get correlation request")
+		return nil, fmt.Errorf("failed to get correlation request: %w", err)
+	}
+	if request == nil {
+		return nil, huma.Error404NotFound("correlation request not found")
 	}
+	return request, nil
+}
 
-	// Store audit record in database
-	_, err = dbmodels.CorrelationAudits.Insert(auditRecord).One(ctx, h.db)
+// expireCorrelationRequests expires requests past their review or execution
+// deadline and audits each expiry
+func (h *CorrelationHandler) expireCorrelationRequests(ctx context.Context) error {
+	expired, err := h.correlationRequestDAO.ExpireRequests(ctx)
 	if err != nil {
+		log.Error().Err(err).Msg("Failed to expire correlation requests")
+		return fmt.Errorf("failed to expire correlation requests: %w", err)
+	}
+	for i := range expired {
+		request := &expired[i]
+		if _, err := h.auditCorrelationRequest(ctx, request, request.RequesterID, request.RequesterUsername, request.RequesterRole, "automated", nil); err != nil {
+			return err
+		}
+		log.Info().
+			Str("request_id", request.RequestID.String()).
+			Int64("requester_id", request.RequesterID).
+			Msg("Correlation request expired")
+	}
+	return nil
+}
+
+// auditCorrelationRequest writes the current status of a request to the
+// correlation audit trail, with the results of an executed request
+func (h *CorrelationHandler) auditCorrelationRequest(ctx context.Context, request *dao.CorrelationRequest, userID int64, username, role, source string, results []models.CorrelationResult) (uuid.UUID, error) {
+	entry := &dao.CorrelationAuditEntry{
+		UserID:               userID,
+		PseudonymID:          request.RequestedPseudonym,
+		AdminUsername:        username,
+		RoleUsed:             role,
+		RequestedPseudonym:   request.RequestedPseudonym,
+		RequestedFingerprint: request.RequestedFingerprint,
+		Justification:        request.Justification,
+		CorrelationType:      request.CorrelationType,
+		LegalBasis:           sql.Null[string]{V: request.LegalBasis, Valid: true},
+		IncidentID:           sql.Null[string]{V: request.IncidentID, Valid: true},
+		RequestSource:        sql.Null[string]{V: source, Valid: true},
+		RequestID:            sql.Null[uuid.UUID]{V: request.RequestID, Valid: true},
+		RequestStatus:        sql.Null[string]{V: request.Status, Valid: true},
+		AuditClient:          auditClient(ctx),
+	}
+
+	if results != nil {
+		// Serialize correlation results for audit
+		correlationResultJSON, err := json.Marshal(results)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to marshal correlation results")
+			return uuid.Nil, fmt.Errorf("failed to serialize correlation results: %w", err)
+		}
+		entry.CorrelationResult.Scan(correlationResultJSON)
+	}
+
+	if err := h.correlationAuditDAO.RecordEntry(ctx, entry); err != nil {
 		log.Error().Err(err).
-			Str("audit_id", auditID.String()).
+			Str("request_id", request.RequestID.String()).
+			Str("status", request.Status).
 			Msg("Failed to create correlation audit record")
-		return nil, fmt.Errorf("failed to create audit record: %w", err)
+		return uuid.Nil, fmt.Errorf("failed to create audit record: %w", err)
 	}
+	return entry.AuditID, nil
+}
 
-	response := models.NewIdentityCorrelationResponse(correlationID, results, auditID.String())
+// recordKeyUsage records a decryption with a role key in the key usage audit
+// trail. decryptErr is the outcome of the decryption.
+func (h *CorrelationHandler) recordKeyUsage(ctx context.Context, keyID uuid.UUID, userID int64, pseudonymID string, decryptErr error) error {
+	entry := &dao.KeyUsageAuditEntry{
+		KeyID:           keyID,
+		UserID:          userID,
+		OperationType:   dao.KeyUsageCorrelation,
+		TargetPseudonym: sql.Null[string]{V: pseudonymID, Valid: true},
+		Success:         decryptErr == nil,
+		AuditClient:     auditClient(ctx),
+	}
+	if decryptErr != nil {
+		entry.ErrorMessage = sql.Null[string]{V: decryptErr.Error(), Valid: true}
+	}
+	if err := h.keyUsageAuditDAO.RecordEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Str("key_id", keyID.String()).Msg("Failed to record key usage")
+		return fmt.Errorf("failed to record key usage: %w", err)
+	}
+	return nil
+}
 
-	log.Info().
-		Str("endpoint", "admin/correlation/identity").
-		Str("component", "handler").
-		Int64("admin_id", adminID).
-		Str("correlation_id", correlationID).
-		Int("results_count", len(results)).
-		Str("audit_id", auditID.String()).
-		Msg("Identity correlation completed")
+// auditClient describes the client of a request for the audit trails.
+// Requests made outside the API, such as expiries, have no client.
+func auditClient(ctx context.Context) dao.AuditClient {
+	client := middleware.ClientInfoFromContext(ctx)
+	return dao.NewAuditClient(client.IPHash(), client.IPPrefix, client.UserAgentFamily)
+}
 
-	return response, nil
+// newCorrelationRequest converts a correlation request for the API
+func newCorrelationRequest(request *dao.CorrelationRequest) models.CorrelationRequest {
+	result := models.CorrelationRequest{
+		RequestID:          request.RequestID.String(),
+		CorrelationType:    request.CorrelationType,
+		Status:             request.Status,
+		RequestedPseudonym: request.RequestedPseudonym,
+		Scope:              request.Scope,
+		Justification:      request.Justification,
+		LegalBasis:         request.LegalBasis,
+		IncidentID:         request.IncidentID,
+		RequesterID:        request.RequesterID,
+		RequesterRole:      request.RequesterRole,
+		ReviewerRole:       request.ReviewerRole.V,
+		ReviewComment:      request.ReviewComment.V,
+		CreatedAt:          request.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:          request.ExpiresAt.Format(time.RFC3339),
+	}
+	if request.ReviewerID.Valid {
+		result.ReviewerID = &request.ReviewerID.V
+	}
+	if request.ReviewedAt.Valid {
+		result.ReviewedAt = request.ReviewedAt.V.Format(time.RFC3339)
+	}
+	if request.ExecutedAt.Valid {
+		result.ExecutedAt = request.ExecutedAt.V.Format(time.RFC3339)
+	}
+	if request.ComplianceReportID.Valid {
+		result.ComplianceReportID = request.ComplianceReportID.V.String()
+	}
+	return result
 }
 
 // GetCorrelationHistory handles getting correlation request history
@@ -554,26 +1027,13 @@ func (h *CorrelationHandler) GetCorrelationHistory(ctx context.Context, input *m
 		return nil, fmt.Errorf("insufficient permissions: view_correlation_history capability required")
 	}
 
-	// Get correlation history from database with basic pagination
-	// Note: The CorrelationAudits table uses a ViewQuery type which has different methods
-	// than regular SelectQuery. For now, we'll use a simple approach.
-	auditRecords, err := dbmodels.CorrelationAudits.Query().All(ctx, h.db)
+	// Get correlation history from database, filtered by correlation type
+	auditRecords, err := h.correlationAuditDAO.ListEntries(ctx, input.CorrelationType)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get correlation history")
 		return nil, fmt.Errorf("failed to get correlation history: %w", err)
 	}
 
-	// Apply correlation type filter if specified
-	if input.CorrelationType != "" {
-		filteredRecords := make(dbmodels.CorrelationAuditSlice, 0)
-		for _, record := range auditRecords {
-			if record.CorrelationType == input.CorrelationType {
-				filteredRecords = append(filteredRecords, record)
-			}
-		}
-		auditRecords = filteredRecords
-	}
-
 	// Apply pagination
 	limit := input.Limit
 	if limit <= 0 {
@@ -592,7 +1052,7 @@ func (h *CorrelationHandler) GetCorrelationHistory(ctx context.Context, input *m
 
 	// Apply pagination manually
 	if offset >= len(auditRecords) {
-		auditRecords = dbmodels.CorrelationAuditSlice{}
+		auditRecords = nil
 	} else {
 		end := offset + limit
 		if end > len(auditRecords) {
@@ -622,10 +1082,14 @@ func (h *CorrelationHandler) GetCorrelationHistory(ctx context.Context, input *m
 			CorrelationType:    record.CorrelationType,
 			RequestedPseudonym: record.RequestedPseudonym,
 			Justification:      record.Justification,
-			Status:             "completed", // All stored records are completed
+			Status:             "completed", // Correlations performed without a request
 			Timestamp:          record.Timestamp.V.Format(time.RFC3339),
 			ResultsCount:       resultsCount,
 		}
+		if record.RequestID.Valid {
+			correlation.RequestID = record.RequestID.V.String()
+			correlation.Status = record.RequestStatus.V
+		}
 		correlations = append(correlations, correlation)
 	}
 
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// disclosureEmbargoAuditType is the correlation audit type of changes to the
+// embargo of a disclosure notice
+const disclosureEmbargoAuditType = "disclosure_embargo"
+
+// DisclosureHandler handles disclosure notices, which tell the owner of a
+// pseudonym that it was correlated once the notice's embargo has passed
+type DisclosureHandler struct {
+	embargo             time.Duration
+	disclosureNoticeDAO *dao.DisclosureNoticeDAO
+	correlationAuditDAO *dao.CorrelationAuditDAO
+	securePseudonymDAO  *dao.SecurePseudonymDAO
+}
+
+// NewDisclosureHandler creates a new disclosure handler. Embargo changes are
+// recorded in the correlation audit chain through auditChainDAO.
+func NewDisclosureHandler(cfg *config.Config, db bob.Executor, auditChainDAO *dao.AuditChainDAO, securePseudonymDAO *dao.SecurePseudonymDAO) *DisclosureHandler {
+	return &DisclosureHandler{
+		embargo:             cfg.Security.DisclosureEmbargo,
+		disclosureNoticeDAO: dao.NewDisclosureNoticeDAO(db),
+		correlationAuditDAO: dao.NewCorrelationAuditDAO(db, auditChainDAO),
+		securePseudonymDAO:  securePseudonymDAO,
+	}
+}
+
+// ListDisclosureNotices handles listing the disclosure notices of one of the
+// current user's pseudonyms. Notices whose embargo has passed and that no
+// gag order withholds are delivered by this call.
+func (h *DisclosureHandler) ListDisclosureNotices(ctx context.Context, input *models.DisclosureNoticeListInput) (*models.DisclosureNoticeListResponse, error) {
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+
+	log.Info().
+		Str("endpoint", "pseudonyms/disclosure-notices").
+		Str("component", "disclosure_handler").
+		Int64("user_id", userCtx.UserID).
+		Msg("List disclosure notices requested")
+
+	ownsPseudonym, err := h.securePseudonymDAO.VerifyPseudonymOwnership(ctx, input.PseudonymID, userCtx.UserID, "user", "self_correlation")
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userCtx.UserID).Msg("Failed to verify pseudonym ownership")
+		return nil, fmt.Errorf("failed to verify ownership: %w", err)
+	}
+	if !ownsPseudonym {
+		return nil, huma.Error404NotFound("pseudonym not found")
+	}
+
+	if err := h.createNotices(ctx); err != nil {
+		return nil, err
+	}
+	delivered, err := h.disclosureNoticeDAO.DeliverNotices(ctx, input.PseudonymID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to deliver disclosure notices")
+		return nil, fmt.Errorf("failed to deliver disclosure notices: %w", err)
+	}
+
+	notices := make([]models.DisclosureNotice, 0, len(delivered))
+	for i := range delivered {
+		notices = append(notices, newDisclosureNotice(&delivered[i]))
+	}
+	return models.NewDisclosureNoticeListResponse(notices), nil
+}
+
+// AdminListDisclosureNotices handles listing disclosure notices and their
+// embargoes
+func (h *DisclosureHandler) AdminListDisclosureNotices(ctx context.Context, input *models.AdminDisclosureNoticeListInput) (*models.AdminDisclosureNoticeListResponse, error) {
+	userCtx, err := requireDisclosureAdmin(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("endpoint", "admin/disclosure-notices").
+		Str("component", "disclosure_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("status", input.Status).
+		Msg("List disclosure notices requested")
+
+	if err := h.createNotices(ctx); err != nil {
+		return nil, err
+	}
+	stored, err := h.disclosureNoticeDAO.ListNotices(ctx, input.PseudonymID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list disclosure notices")
+		return nil, fmt.Errorf("failed to list disclosure notices: %w", err)
+	}
+
+	now := time.Now()
+	notices := make([]models.AdminDisclosureNotice, 0, len(stored))
+	for i := range stored {
+		if input.Status != "" && stored[i].Status(now) != input.Status {
+			continue
+		}
+		notices = append(notices, newAdminDisclosureNotice(&stored[i], now))
+	}
+	return models.NewAdminDisclosureNoticeListResponse(notices), nil
+}
+
+// ExtendDisclosureEmbargo handles moving the embargo of an undelivered
+// notice to a later time
+func (h *DisclosureHandler) ExtendDisclosureEmbargo(ctx context.Context, input *models.DisclosureEmbargoExtendInput) (*models.AdminDisclosureNoticeResponse, error) {
+	userCtx, err := requireDisclosureAdmin(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	notice, err := h.getUndeliveredNotice(ctx, input.NoticeID)
+	if err != nil {
+		return nil, err
+	}
+	if !input.Body.EmbargoUntil.After(notice.EmbargoUntil) {
+		return nil, huma.Error422UnprocessableEntity("embargo_until must be later than the current embargo")
+	}
+
+	return h.setEmbargo(ctx, userCtx, notice, "extend", input.Body.EmbargoUntil, input.Body.Justification)
+}
+
+// LiftDisclosureEmbargo handles ending the embargo of an undelivered notice
+// now. A gag order still withholds the notice.
+func (h *DisclosureHandler) LiftDisclosureEmbargo(ctx context.Context, input *models.DisclosureEmbargoLiftInput) (*models.AdminDisclosureNoticeResponse, error) {
+	userCtx, err := requireDisclosureAdmin(&input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	notice, err := h.getUndeliveredNotice(ctx, input.NoticeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !notice.EmbargoUntil.After(now) {
+		return nil, huma.Error409Conflict("the embargo of the notice has already passed")
+	}
+
+	return h.setEmbargo(ctx, userCtx, notice, "lift", now, input.Body.Justification)
+}
+
+// setEmbargo moves the embargo of a notice and audits the change
+func (h *DisclosureHandler) setEmbargo(ctx context.Context, userCtx *middleware.UserContext, notice *dao.DisclosureNotice, action string, embargoUntil time.Time, justification string) (*models.AdminDisclosureNoticeResponse, error) {
+	updated, err := h.disclosureNoticeDAO.SetEmbargo(ctx, notice.NoticeID, embargoUntil)
+	if err != nil {
+		log.Error().Err(err).Str("notice_id", notice.NoticeID.String()).Msg("Failed to set disclosure embargo")
+		return nil, fmt.Errorf("failed to set disclosure embargo: %w", err)
+	}
+	if updated == nil {
+		return nil, huma.Error409Conflict("disclosure notice was already delivered")
+	}
+
+	if err := h.auditEmbargoChange(ctx, userCtx, notice, updated, action, justification); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("component", "disclosure_handler").
+		Int64("admin_id", userCtx.UserID).
+		Str("notice_id", notice.NoticeID.String()).
+		Str("action", action).
+		Time("embargo_until", updated.EmbargoUntil).
+		Msg("Disclosure embargo changed")
+
+	return models.NewAdminDisclosureNoticeResponse(newAdminDisclosureNotice(updated, time.Now())), nil
+}
+
+// auditEmbargoChange records a change to the embargo of a notice in the
+// correlation audit trail
+func (h *DisclosureHandler) auditEmbargoChange(ctx context.Context, userCtx *middleware.UserContext, previous, updated *dao.DisclosureNotice, action, justification string) error {
+	change, err := json.Marshal(map[string]string{
+		"notice_id":              updated.NoticeID.String(),
+		"audit_id":               updated.AuditID.String(),
+		"action":                 action,
+		"previous_embargo_until": previous.EmbargoUntil.UTC().Format(time.RFC3339),
+		"embargo_until":          updated.EmbargoUntil.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize embargo change: %w", err)
+	}
+
+	entry := &dao.CorrelationAuditEntry{
+		UserID:             userCtx.UserID,
+		PseudonymID:        updated.PseudonymID,
+		AdminUsername:      userCtx.Email,
+		RoleUsed:           requesterRole(userCtx),
+		RequestedPseudonym: updated.PseudonymID,
+		Justification:      justification,
+		CorrelationType:    disclosureEmbargoAuditType,
+		RequestSource:      sql.Null[string]{V: "manual", Valid: true},
+		AuditClient:        auditClient(ctx),
+	}
+	entry.CorrelationResult.Scan(change)
+
+	if err := h.correlationAuditDAO.RecordEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Str("notice_id", updated.NoticeID.String()).Msg("Failed to audit disclosure embargo change")
+		return fmt.Errorf("failed to create audit record: %w", err)
+	}
+	return nil
+}
+
+// getUndeliveredNotice loads a notice whose embargo can still change
+func (h *DisclosureHandler) getUndeliveredNotice(ctx context.Context, id string) (*dao.DisclosureNotice, error) {
+	noticeID, err := uuid.FromString(id)
+	if err != nil {
+		return nil, huma.Error404NotFound("disclosure notice not found")
+	}
+
+	notice, err := h.disclosureNoticeDAO.GetNotice(ctx, noticeID)
+	if err != nil {
+		log.Error().Err(err).Str("notice_id", id).Msg("Failed to get disclosure notice")
+		return nil, fmt.Errorf("failed to get disclosure notice: %w", err)
+	}
+	if notice == nil {
+		return nil, huma.Error404NotFound("disclosure notice not found")
+	}
+	if notice.DeliveredAt.Valid {
+		return nil, huma.Error409Conflict("disclosure notice was already delivered")
+	}
+	return notice, nil
+}
+
+// createNotices creates the notices of correlations performed since the last call
+func (h *DisclosureHandler) createNotices(ctx context.Context) error {
+	created, err := h.disclosureNoticeDAO.CreateNotices(ctx, h.embargo)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create disclosure notices")
+		return fmt.Errorf("failed to create disclosure notices: %w", err)
+	}
+	if created > 0 {
+		log.Info().Int64("created", created).Msg("Created disclosure notices")
+	}
+	return nil
+}
+
+// requireDisclosureAdmin checks that the caller may manage disclosure embargoes
+func requireDisclosureAdmin(authInput *middleware.AuthInput) (*middleware.UserContext, error) {
+	userCtx, err := middleware.ExtractUserFromHumaInput(authInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+	if !userCtx.HasCapability("legal_compliance") {
+		log.Warn().
+			Int64("admin_id", userCtx.UserID).
+			Msg("User lacks legal_compliance capability")
+		return nil, huma.Error403Forbidden("insufficient permissions: legal_compliance capability required")
+	}
+	return userCtx, nil
+}
+
+// newDisclosureNotice converts a notice for the owner of its pseudonym
+func newDisclosureNotice(notice *dao.DisclosureNotice) models.DisclosureNotice {
+	return models.DisclosureNotice{
+		NoticeID:           notice.NoticeID.String(),
+		PseudonymID:        notice.PseudonymID,
+		CorrelatedOn:       notice.CorrelatedAt.UTC().Format(time.DateOnly),
+		RoleType:           notice.RoleUsed,
+		LegalBasisCategory: notice.LegalBasisCategory(),
+	}
+}
+
+// newAdminDisclosureNotice converts a notice and its embargo for admins
+func newAdminDisclosureNotice(notice *dao.DisclosureNotice, now time.Time) models.AdminDisclosureNotice {
+	result := models.AdminDisclosureNotice{
+		DisclosureNotice: newDisclosureNotice(notice),
+		AuditID:          notice.AuditID.String(),
+		CorrelationType:  notice.CorrelationType,
+		Status:           notice.Status(now),
+		EmbargoUntil:     notice.EmbargoUntil.Format(time.RFC3339),
+	}
+	if notice.DeliveredAt.Valid {
+		result.DeliveredAt = notice.DeliveredAt.V.Format(time.RFC3339)
+	}
+	return result
+}
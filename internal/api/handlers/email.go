@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/api/validation"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/password"
+	"github.com/rs/zerolog/log"
+)
+
+// passwordResetRequestedMessage is returned whether or not the address
+// belongs to an account, so the endpoint cannot be used to discover accounts
+const passwordResetRequestedMessage = "If the address belongs to an account, a password reset link has been sent to it."
+
+// VerifyEmail handles the link mailed to prove control of an email address
+func (h *AuthHandler) VerifyEmail(ctx context.Context, input *models.EmailVerificationInput) (*models.EmailVerificationResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/email/verify").
+		Str("component", "auth_handler").
+		Msg("Processing email verification request")
+
+	record, err := h.consumeEmailToken(ctx, input.Body.Token, middleware.TokenUseEmailVerification, dao.EmailTokenPurposeVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	verified, err := h.userDAO.MarkEmailVerified(ctx, record.UserID, record.Email)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", record.UserID).Msg("Failed to mark email verified")
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+	if !verified {
+		log.Warn().Int64("user_id", record.UserID).Msg("Verification link is for an address no longer on the account")
+		return nil, huma.Error400BadRequest("link is invalid or has expired")
+	}
+
+	if err := h.emailTokenDAO.InvalidateUserTokens(ctx, record.UserID, dao.EmailTokenPurposeVerification); err != nil {
+		log.Warn().Err(err).Int64("user_id", record.UserID).Msg("Failed to invalidate remaining verification links")
+	}
+
+	log.Info().
+		Int64("user_id", record.UserID).
+		Msg("Email address verified")
+
+	return models.NewEmailVerificationResponse(record.Email, true), nil
+}
+
+// ResendVerificationEmail mails a new verification link to the current user,
+// invalidating any earlier link
+func (h *AuthHandler) ResendVerificationEmail(ctx context.Context, input *models.EmailVerificationResendInput) (*models.EmailAcceptedResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/email/verification").
+		Str("component", "auth_handler").
+		Msg("Processing verification email resend request")
+
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+	if userCtx.TokenType != "jwt" {
+		return nil, huma.Error403Forbidden("Email verification requires an interactive session")
+	}
+
+	user, err := h.userDAO.GetUserByID(ctx, userCtx.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userCtx.UserID).Msg("Failed to get user from database")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, huma.Error404NotFound("User not found")
+	}
+
+	verified, err := h.userDAO.IsEmailVerified(ctx, user.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to check email verification")
+		return nil, fmt.Errorf("failed to check email verification: %w", err)
+	}
+	if verified {
+		return nil, huma.Error409Conflict("email address is already verified")
+	}
+
+	if err := h.emailTokenDAO.InvalidateUserTokens(ctx, user.UserID, dao.EmailTokenPurposeVerification); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to invalidate earlier verification links")
+		return nil, fmt.Errorf("failed to send verification email: %w", err)
+	}
+	if err := h.sendVerificationEmail(ctx, user.UserID, user.Email); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to send verification email")
+		return nil, fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return models.NewEmailAcceptedResponse("A verification link has been sent to " + user.Email + "."), nil
+}
+
+// RequestPasswordReset mails a password reset link if the address belongs to
+// an active account. The response is the same either way.
+func (h *AuthHandler) RequestPasswordReset(ctx context.Context, input *models.PasswordResetRequestInput) (*models.EmailAcceptedResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/password/forgot").
+		Str("component", "auth_handler").
+		Msg("Processing password reset request")
+
+	if err := validation.ValidateEmail(input.Body.Email); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	user, err := h.userDAO.GetUserByEmail(ctx, input.Body.Email)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find user by email")
+		return nil, fmt.Errorf("failed to process password reset request: %w", err)
+	}
+	if user == nil || !accountUsable(user) {
+		log.Info().Msg("Password reset requested for an unknown or unusable account")
+		return models.NewEmailAcceptedResponse(passwordResetRequestedMessage), nil
+	}
+
+	// Only the newest reset link is valid
+	if err := h.emailTokenDAO.InvalidateUserTokens(ctx, user.UserID, dao.EmailTokenPurposePasswordReset); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to invalidate earlier reset links")
+		return models.NewEmailAcceptedResponse(passwordResetRequestedMessage), nil
+	}
+
+	token, err := h.issueEmailToken(ctx, user.UserID, user.Email, middleware.TokenUsePasswordReset, dao.EmailTokenPurposePasswordReset, h.config.Security.PasswordResetTTL)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to issue password reset token")
+		return models.NewEmailAcceptedResponse(passwordResetRequestedMessage), nil
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your HashPost password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your HashPost account.\n\n"+
+			"To choose a new password, open this link within %s:\n\n%s\n\n"+
+			"Resetting your password signs you out everywhere. If you did not ask for this, you can ignore this email; your password has not changed.\n",
+			formatTTL(h.config.Security.PasswordResetTTL), h.emailLink("/reset-password", token)),
+	}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to send password reset email")
+		return models.NewEmailAcceptedResponse(passwordResetRequestedMessage), nil
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("Password reset link sent")
+
+	return models.NewEmailAcceptedResponse(passwordResetRequestedMessage), nil
+}
+
+// ResetPassword sets a new password using a mailed reset token and signs the
+// user out of every session
+func (h *AuthHandler) ResetPassword(ctx context.Context, input *models.PasswordResetInput) (*models.PasswordResetResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/password/reset").
+		Str("component", "auth_handler").
+		Msg("Processing password reset")
+
+	// Validate before consuming the token so a rejected password can be retried
+	if err := validation.ValidatePassword(input.Body.NewPassword, h.config.Security.PasswordValidation); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	record, err := h.consumeEmailToken(ctx, input.Body.Token, middleware.TokenUsePasswordReset, dao.EmailTokenPurposePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.userDAO.GetUserByID(ctx, record.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", record.UserID).Msg("Failed to get user from database")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.Email != record.Email || !accountUsable(user) {
+		log.Warn().Int64("user_id", record.UserID).Msg("Password reset token no longer matches the account")
+		return nil, huma.Error400BadRequest("link is invalid or has expired")
+	}
+
+	hashedPassword, err := password.Hash(input.Body.NewPassword)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hash password")
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := h.userDAO.UpdateUser(ctx, user.UserID, &dbmodels.UserSetter{PasswordHash: &hashedPassword}); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to store new password")
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	// Whoever knew the old password must not stay signed in
+	if err := h.tokenRevocationDAO.RevokeUserTokens(ctx, user.UserID, dao.TokenRevokeReasonPasswordReset); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to revoke tokens after password reset")
+		return nil, fmt.Errorf("failed to sign out sessions: %w", err)
+	}
+
+	if err := h.emailTokenDAO.InvalidateUserTokens(ctx, user.UserID, dao.EmailTokenPurposePasswordReset); err != nil {
+		log.Warn().Err(err).Int64("user_id", user.UserID).Msg("Failed to invalidate remaining reset links")
+	}
+
+	// Following the link proves control of the address
+	if _, err := h.userDAO.MarkEmailVerified(ctx, user.UserID, record.Email); err != nil {
+		log.Warn().Err(err).Int64("user_id", user.UserID).Msg("Failed to mark email verified after password reset")
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("Password reset - all sessions revoked")
+
+	return models.NewPasswordResetResponse(), nil
+}
+
+// sendVerificationEmail mails a verification link for the given address
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, userID int64, email string) error {
+	token, err := h.issueEmailToken(ctx, userID, email, middleware.TokenUseEmailVerification, dao.EmailTokenPurposeVerification, h.config.Security.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your HashPost email address",
+		Body: fmt.Sprintf("Welcome to HashPost.\n\n"+
+			"To confirm that this is your email address, open this link within %s:\n\n%s\n\n"+
+			"If you did not create an account, you can ignore this email.\n",
+			formatTTL(h.config.Security.EmailVerificationTTL), h.emailLink("/verify-email", token)),
+	})
+}
+
+// issueEmailToken generates a token to mail to a user and stores its hash
+func (h *AuthHandler) issueEmailToken(ctx context.Context, userID int64, email, tokenUse, purpose string, ttl time.Duration) (string, error) {
+	token, err := middleware.GenerateEmailToken(userID, email, tokenUse, h.signingKeys, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	if err := h.emailTokenDAO.StoreToken(ctx, userID, purpose, email, middleware.HashEmailToken(token), expiresAt); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeEmailToken checks a mailed token and marks it used. Every failure is
+// reported the same way so the response does not reveal why a link was rejected.
+func (h *AuthHandler) consumeEmailToken(ctx context.Context, token, tokenUse, purpose string) (*dao.EmailTokenRecord, error) {
+	invalid := huma.Error400BadRequest("link is invalid or has expired")
+
+	claims, err := middleware.ParseEmailToken(token, tokenUse, h.signingKeys)
+	if err != nil {
+		log.Warn().Err(err).Str("token_use", tokenUse).Msg("Invalid email token")
+		return nil, invalid
+	}
+
+	record, err := h.emailTokenDAO.ConsumeToken(ctx, purpose, middleware.HashEmailToken(token))
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", claims.UserID).Msg("Failed to consume email token")
+		return nil, fmt.Errorf("failed to check link: %w", err)
+	}
+	if record == nil || record.UserID != claims.UserID || record.Email != claims.Email {
+		log.Warn().Int64("user_id", claims.UserID).Str("token_use", tokenUse).Msg("Unknown, used or expired email token")
+		return nil, invalid
+	}
+
+	return record, nil
+}
+
+// emailLink builds a link to the web client that carries a mailed token
+func (h *AuthHandler) emailLink(path, token string) string {
+	return strings.TrimRight(h.config.Mail.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// verifiedEmailError converts a failed email verification check into an API error
+func verifiedEmailError(err error) error {
+	if errors.Is(err, middleware.ErrEmailNotVerified) {
+		return huma.Error403Forbidden(middleware.ErrEmailNotVerified.Message)
+	}
+	return fmt.Errorf("failed to check email verification: %w", err)
+}
+
+// accountUsable reports whether an account may sign in
+func accountUsable(user *dbmodels.User) bool {
+	if !user.IsActive.Valid || !user.IsActive.V {
+		return false
+	}
+	return !user.IsSuspended.Valid || !user.IsSuspended.V
+}
+
+// formatTTL renders a link lifetime for an email, e.g. "48 hours"
+func formatTTL(ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		if ttl == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", int(ttl/time.Hour))
+	}
+	return fmt.Sprintf("%d minutes", int(ttl/time.Minute))
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/rs/zerolog/log"
+)
+
+// jwksMaxAge is how long, in seconds, verifiers may cache the key set. Key
+// rotation publishes new keys ahead of activation by more than this.
+const jwksMaxAge = 300
+
+// JWKSHandler publishes the public keys that verify issued tokens
+type JWKSHandler struct {
+	signingKeys *jwtkeys.KeySet
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(signingKeys *jwtkeys.KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		signingKeys: signingKeys,
+	}
+}
+
+// GetJWKS handles requests for the JSON Web Key Set
+func (h *JWKSHandler) GetJWKS(ctx context.Context, input *models.JWKSInput) (*models.JWKSResponse, error) {
+	keys := h.signingKeys.JWKS(time.Now())
+
+	log.Debug().
+		Str("endpoint", ".well-known/jwks.json").
+		Str("component", "jwks_handler").
+		Int("keys", len(keys.Keys)).
+		Msg("JWKS requested")
+
+	return models.NewJWKSResponse(keys, jwksMaxAge), nil
+}
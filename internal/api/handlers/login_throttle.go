@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/lockout"
+	"github.com/rs/zerolog/log"
+)
+
+// LoginLockoutEventType is the system event recorded when failed logins lock
+// out an account or a client address
+const LoginLockoutEventType = "login_lockout"
+
+// loginFailurePurgeInterval is how often stale failure counters are removed
+const loginFailurePurgeInterval = time.Hour
+
+// correlationCapabilities are the capabilities that put an account under the
+// stricter admin lockout policy
+var correlationCapabilities = []string{"correlate_identities", "correlate_fingerprints"}
+
+// loginSubject is a key failed logins are counted under
+type loginSubject struct {
+	scope   string
+	subject string
+}
+
+// loginSubjects returns the account and client network keys a login attempt
+// is counted under
+func loginSubjects(ctx context.Context, email string) []loginSubject {
+	subjects := []loginSubject{{scope: dao.LoginFailureScopeAccount, subject: lockout.AccountSubject(email)}}
+	if ipHash := middleware.ClientInfoFromContext(ctx).IPHash(); ipHash != "" {
+		subjects = append(subjects, loginSubject{scope: dao.LoginFailureScopeIP, subject: lockout.IPSubject(ipHash)})
+	}
+	return subjects
+}
+
+// blockingSubjects returns the keys whose blocks apply to a login attempt:
+// the ones it is counted under, and the client network's keys from earlier
+// days of the matching window, so that a block outlives the daily rotation of
+// the address hashing key
+func blockingSubjects(ctx context.Context, email string) []loginSubject {
+	subjects := loginSubjects(ctx, email)
+	ipHashes := middleware.ClientInfoFromContext(ctx).IPHashes
+	for i := 1; i < len(ipHashes); i++ {
+		subjects = append(subjects, loginSubject{scope: dao.LoginFailureScopeIP, subject: lockout.IPSubject(ipHashes[i])})
+	}
+	return subjects
+}
+
+// checkLoginThrottle refuses a login attempt while the address or the client
+// is blocked by earlier failures. The answer is the same whether or not the
+// address belongs to an account.
+func (h *AuthHandler) checkLoginThrottle(ctx context.Context, email string) error {
+	var blockedUntil time.Time
+	for _, s := range blockingSubjects(ctx, email) {
+		until, err := h.loginFailureDAO.BlockedUntil(ctx, s.scope, s.subject)
+		if err != nil {
+			log.Error().Err(err).Str("scope", s.scope).Msg("Failed to check login throttle")
+			return fmt.Errorf("failed to check login throttle: %w", err)
+		}
+		if until.After(blockedUntil) {
+			blockedUntil = until
+		}
+	}
+
+	if blockedUntil.IsZero() {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(time.Until(blockedUntil).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	log.Warn().
+		Int("retry_after_seconds", retryAfter).
+		Msg("Login attempt refused after repeated failures")
+	return huma.ErrorWithHeaders(
+		huma.Error429TooManyRequests("too many failed login attempts; try again later"),
+		http.Header{"Retry-After": {strconv.Itoa(retryAfter)}},
+	)
+}
+
+// recordLoginFailure counts a failed login against the address and the
+// client, and blocks further attempts as the lockout policies require. user
+// is nil if the address does not belong to an account. Failures to record are
+// logged rather than returned so the caller's error reaches the client.
+func (h *AuthHandler) recordLoginFailure(ctx context.Context, email string, user *dbmodels.User) {
+	for _, s := range loginSubjects(ctx, email) {
+		policy := &h.config.Security.IPLockout
+		if s.scope == dao.LoginFailureScopeAccount {
+			policy = h.accountLockoutPolicy(user)
+		}
+
+		failures, err := h.loginFailureDAO.RecordFailure(ctx, s.scope, s.subject, policy.FailureWindow)
+		if err != nil {
+			log.Error().Err(err).Str("scope", s.scope).Msg("Failed to record login failure")
+			continue
+		}
+
+		block := lockout.ForFailures(policy, failures)
+		if block.Duration == 0 {
+			continue
+		}
+
+		until := time.Now().Add(block.Duration)
+		if err := h.loginFailureDAO.Block(ctx, s.scope, s.subject, until); err != nil {
+			log.Error().Err(err).Str("scope", s.scope).Msg("Failed to block login attempts")
+			continue
+		}
+
+		if block.Locked {
+			h.recordLockoutEvent(ctx, s, user, failures, until)
+		}
+	}
+
+	h.maybePurgeLoginFailures()
+}
+
+// clearLoginFailures forgets the failures against an address after a
+// successful login. Failures from the client address are kept so that one
+// valid account cannot be used to keep guessing at others.
+func (h *AuthHandler) clearLoginFailures(ctx context.Context, email string) {
+	if _, err := h.loginFailureDAO.Clear(ctx, dao.LoginFailureScopeAccount, lockout.AccountSubject(email)); err != nil {
+		log.Error().Err(err).Msg("Failed to clear login failures")
+	}
+}
+
+// accountLockoutPolicy returns the lockout policy for an account. Accounts
+// that can correlate identities are the most valuable to an attacker, so they
+// get the stricter admin policy.
+func (h *AuthHandler) accountLockoutPolicy(user *dbmodels.User) *config.LoginLockoutPolicy {
+	if user != nil && user.Capabilities.Valid {
+		var capabilities []string
+		if err := json.Unmarshal(user.Capabilities.V.Val, &capabilities); err == nil {
+			for _, capability := range capabilities {
+				for _, correlation := range correlationCapabilities {
+					if capability == correlation {
+						return &h.config.Security.AdminLockout
+					}
+				}
+			}
+		}
+	}
+	return &h.config.Security.AccountLockout
+}
+
+// recordLockoutEvent records a lockout in the system event log
+func (h *AuthHandler) recordLockoutEvent(ctx context.Context, s loginSubject, user *dbmodels.User, failures int, until time.Time) {
+	message := "Account locked after repeated failed logins"
+	if s.scope == dao.LoginFailureScopeIP {
+		message = "Client address blocked after repeated failed logins"
+	}
+
+	data := map[string]interface{}{
+		"scope":         s.scope,
+		"subject":       s.subject,
+		"failure_count": failures,
+		"locked_until":  until.UTC(),
+	}
+	if user != nil && s.scope == dao.LoginFailureScopeAccount {
+		data["user_id"] = user.UserID
+	}
+
+	log.Warn().
+		Str("scope", s.scope).
+		Int("failure_count", failures).
+		Time("locked_until", until).
+		Msg(message)
+
+	if err := h.systemEventDAO.RecordEvent(ctx, LoginLockoutEventType, dao.SystemEventSeverityWarning, message, "auth_handler", data); err != nil {
+		log.Error().Err(err).Msg("Failed to record login lockout event")
+	}
+}
+
+// maybePurgeLoginFailures removes stale failure counters in the background, at
+// most once per purge interval
+func (h *AuthHandler) maybePurgeLoginFailures() {
+	now := time.Now().Unix()
+	last := h.lastFailurePurge.Load()
+	if now-last < int64(loginFailurePurgeInterval.Seconds()) || !h.lastFailurePurge.CompareAndSwap(last, now) {
+		return
+	}
+
+	// Counters only matter within the longest failure window
+	maxAge := h.config.Security.IPLockout.FailureWindow
+	for _, policy := range []config.LoginLockoutPolicy{h.config.Security.AccountLockout, h.config.Security.AdminLockout} {
+		if policy.FailureWindow > maxAge {
+			maxAge = policy.FailureWindow
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		purged, err := h.loginFailureDAO.PurgeStale(ctx, maxAge)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to purge stale login failures")
+			return
+		}
+		log.Debug().Int64("purged", purged).Msg("Purged stale login failures")
+	}()
+}
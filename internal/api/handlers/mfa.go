@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/rs/zerolog/log"
+)
+
+// mfaChallengeTTL is how long a user has to complete the MFA step of login
+const mfaChallengeTTL = 5 * time.Minute
+
+// issueMFAChallenge returns a login response asking for the second factor
+func (h *AuthHandler) issueMFAChallenge(userID int64) (*models.UserLoginResponse, error) {
+	mfaToken, err := middleware.GenerateMFAChallengeToken(userID, h.signingKeys, mfaChallengeTTL)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to generate MFA challenge token")
+		return nil, fmt.Errorf("failed to generate MFA challenge: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", userID).
+		Msg("Password verified - MFA challenge issued")
+
+	return models.NewMFAChallengeResponse(mfaToken, int(mfaChallengeTTL.Seconds())), nil
+}
+
+// CompleteMFALogin handles the second step of login for users with MFA enabled
+func (h *AuthHandler) CompleteMFALogin(ctx context.Context, input *models.MFALoginInput) (*models.UserLoginResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/login/mfa").
+		Str("component", "auth_handler").
+		Msg("Processing MFA login step")
+
+	userID, err := middleware.ParseMFAChallengeToken(input.Body.MFAToken, h.signingKeys)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid MFA challenge token")
+		return nil, huma.Error401Unauthorized("MFA challenge expired or invalid; please log in again")
+	}
+
+	user, err := h.userDAO.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("Failed to get user from database")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, huma.Error401Unauthorized("invalid credentials")
+	}
+
+	// Re-check account state; it may have changed since the password step
+	if !user.IsActive.Valid || !user.IsActive.V {
+		return nil, fmt.Errorf("account inactive")
+	}
+	if user.IsSuspended.Valid && user.IsSuspended.V {
+		return nil, fmt.Errorf("account suspended")
+	}
+
+	if err := h.verifyThrottledSecondFactor(ctx, user, input.Body.MFACodeBody); err != nil {
+		return nil, err
+	}
+
+	return h.completeLogin(ctx, user, time.Now())
+}
+
+// StartMFAEnrollment generates a new TOTP secret for the current user
+func (h *AuthHandler) StartMFAEnrollment(ctx context.Context, input *models.MFAEnrollmentInput) (*models.MFAEnrollmentResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/mfa/enroll").
+		Str("component", "auth_handler").
+		Msg("Processing MFA enrollment request")
+
+	_, user, err := h.loadInteractiveUser(ctx, &input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.MfaEnabled.Valid && user.MfaEnabled.V {
+		return nil, huma.Error409Conflict("MFA is already enabled; disable it before enrolling a new authenticator")
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to generate TOTP secret")
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	sealed, err := h.mfaSecrets.Seal(user.UserID, secret)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to encrypt TOTP secret")
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+	if err := h.mfaDAO.SetPendingSecret(ctx, user.UserID, sealed); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to store pending TOTP secret")
+		return nil, fmt.Errorf("failed to start MFA enrollment: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("MFA enrollment started")
+
+	uri := mfa.ProvisioningURI(h.config.Security.MFAIssuer, user.Email, secret)
+	return models.NewMFAEnrollmentResponse(secret, uri, mfa.Digits, int(mfa.Period.Seconds())), nil
+}
+
+// ConfirmMFAEnrollment verifies the first TOTP code, enables MFA and issues recovery codes
+func (h *AuthHandler) ConfirmMFAEnrollment(ctx context.Context, input *models.MFAConfirmInput) (*models.MFARecoveryCodesResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/mfa/enroll/confirm").
+		Str("component", "auth_handler").
+		Msg("Processing MFA enrollment confirmation")
+
+	_, user, err := h.loadInteractiveUser(ctx, &input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.MfaEnabled.Valid && user.MfaEnabled.V {
+		return nil, huma.Error409Conflict("MFA is already enabled")
+	}
+	if !user.MfaSecret.Valid || user.MfaSecret.V == "" {
+		return nil, huma.Error400BadRequest("no MFA enrollment in progress")
+	}
+
+	if err := h.verifyTOTP(ctx, user, input.Body.Code); err != nil {
+		return nil, err
+	}
+
+	if err := h.mfaDAO.EnableMFA(ctx, user.UserID); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to enable MFA")
+		return nil, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+
+	codes, err := h.issueRecoveryCodes(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("MFA enabled")
+
+	return models.NewMFARecoveryCodesResponse(codes), nil
+}
+
+// StepUpMFA verifies a second factor and returns an access token carrying a fresh MFA claim
+func (h *AuthHandler) StepUpMFA(ctx context.Context, input *models.MFAStepUpInput) (*models.TokenRefreshResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/mfa/step-up").
+		Str("component", "auth_handler").
+		Msg("Processing MFA step-up request")
+
+	userCtx, user, err := h.loadInteractiveUser(ctx, &input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.verifyThrottledSecondFactor(ctx, user, input.Body); err != nil {
+		return nil, err
+	}
+
+	steppedUp := *userCtx
+	steppedUp.MFAEnabled = true
+	steppedUp.MFAVerifiedAt = time.Now()
+
+	accessToken, err := middleware.GenerateJWT(&steppedUp, h.signingKeys, h.config.JWT.Expiration)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to generate stepped-up access token")
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("MFA step-up completed")
+
+	return models.NewTokenRefreshResponse(accessToken, int(h.config.JWT.Expiration.Seconds()), h.config.JWT.Development), nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after verifying a second factor
+func (h *AuthHandler) RegenerateRecoveryCodes(ctx context.Context, input *models.MFARegenerateRecoveryCodesInput) (*models.MFARecoveryCodesResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/mfa/recovery-codes").
+		Str("component", "auth_handler").
+		Msg("Processing recovery code regeneration request")
+
+	_, user, err := h.loadInteractiveUser(ctx, &input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.verifyThrottledSecondFactor(ctx, user, input.Body); err != nil {
+		return nil, err
+	}
+
+	codes, err := h.issueRecoveryCodes(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewMFARecoveryCodesResponse(codes), nil
+}
+
+// DisableMFA turns off MFA after verifying a second factor
+func (h *AuthHandler) DisableMFA(ctx context.Context, input *models.MFADisableInput) (*models.MFADisableResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/mfa/disable").
+		Str("component", "auth_handler").
+		Msg("Processing MFA disable request")
+
+	_, user, err := h.loadInteractiveUser(ctx, &input.AuthInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.verifyThrottledSecondFactor(ctx, user, input.Body); err != nil {
+		return nil, err
+	}
+
+	if err := h.mfaDAO.DisableMFA(ctx, user.UserID); err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to disable MFA")
+		return nil, fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", user.UserID).
+		Msg("MFA disabled")
+
+	return models.NewMFADisableResponse(), nil
+}
+
+// verifyThrottledSecondFactor verifies a second factor under the login
+// lockout. Wrong codes count towards the same lockout as wrong passwords, so
+// a stolen session cannot be used to guess codes either.
+func (h *AuthHandler) verifyThrottledSecondFactor(ctx context.Context, user *dbmodels.User, body models.MFACodeBody) error {
+	if err := h.checkLoginThrottle(ctx, user.Email); err != nil {
+		return err
+	}
+	if err := h.verifySecondFactor(ctx, user, body); err != nil {
+		var statusErr huma.StatusError
+		if errors.As(err, &statusErr) && statusErr.GetStatus() == http.StatusUnauthorized {
+			h.recordLoginFailure(ctx, user.Email, user)
+		}
+		return err
+	}
+	return nil
+}
+
+// loadInteractiveUser authenticates the request and loads the user record.
+// MFA management is only available to JWT sessions, not API keys.
+func (h *AuthHandler) loadInteractiveUser(ctx context.Context, input *middleware.AuthInput) (*middleware.UserContext, *dbmodels.User, error) {
+	userCtx, err := middleware.ExtractUserFromHumaInput(input)
+	if err != nil {
+		return nil, nil, huma.Error401Unauthorized("Authentication required")
+	}
+	if userCtx.TokenType != "jwt" {
+		return nil, nil, huma.Error403Forbidden("MFA management requires an interactive session")
+	}
+
+	user, err := h.userDAO.GetUserByID(ctx, userCtx.UserID)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userCtx.UserID).Msg("Failed to get user from database")
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, nil, huma.Error404NotFound("User not found")
+	}
+
+	return userCtx, user, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, user *dbmodels.User, factor models.MFACodeBody) error {
+	if !user.MfaEnabled.Valid || !user.MfaEnabled.V {
+		return huma.Error400BadRequest("MFA is not enabled for this account")
+	}
+
+	if factor.Code != "" {
+		return h.verifyTOTP(ctx, user, factor.Code)
+	}
+
+	if factor.RecoveryCode != "" {
+		consumed, err := h.mfaDAO.ConsumeRecoveryCode(ctx, user.UserID, mfa.HashRecoveryCode(factor.RecoveryCode))
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to consume recovery code")
+			return fmt.Errorf("failed to verify recovery code: %w", err)
+		}
+		if !consumed {
+			log.Warn().Int64("user_id", user.UserID).Msg("Invalid or already used recovery code")
+			return huma.Error401Unauthorized("invalid MFA code")
+		}
+
+		remaining, err := h.mfaDAO.CountRemainingRecoveryCodes(ctx, user.UserID)
+		if err == nil {
+			log.Info().
+				Int64("user_id", user.UserID).
+				Int64("remaining_recovery_codes", remaining).
+				Msg("Recovery code used")
+		}
+		return nil
+	}
+
+	return huma.Error422UnprocessableEntity("code or recovery_code is required")
+}
+
+// verifyTOTP validates a TOTP code against the user's secret and rejects replays
+func (h *AuthHandler) verifyTOTP(ctx context.Context, user *dbmodels.User, code string) error {
+	if !user.MfaSecret.Valid || user.MfaSecret.V == "" {
+		return huma.Error400BadRequest("no TOTP secret configured")
+	}
+
+	secret, err := h.mfaSecrets.Open(user.UserID, user.MfaSecret.V)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to decrypt TOTP secret")
+		return fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok := mfa.Validate(secret, code, time.Now())
+	if !ok {
+		log.Warn().Int64("user_id", user.UserID).Msg("Invalid TOTP code")
+		return huma.Error401Unauthorized("invalid MFA code")
+	}
+
+	fresh, err := h.mfaDAO.RecordTOTPStep(ctx, user.UserID, step)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.UserID).Msg("Failed to record TOTP step")
+		return fmt.Errorf("failed to verify MFA code: %w", err)
+	}
+	if !fresh {
+		log.Warn().Int64("user_id", user.UserID).Msg("Replayed TOTP code rejected")
+		return huma.Error401Unauthorized("invalid MFA code")
+	}
+
+	// Secrets stored before they were encrypted are encrypted on their next use
+	if !mfa.IsSealed(user.MfaSecret.V) {
+		h.sealLegacySecret(ctx, user.UserID, secret)
+	}
+
+	return nil
+}
+
+// sealLegacySecret encrypts a TOTP secret stored in plaintext. A failure is
+// logged and retried on the next use.
+func (h *AuthHandler) sealLegacySecret(ctx context.Context, userID int64, secret string) {
+	sealed, err := h.mfaSecrets.Seal(userID, secret)
+	if err == nil {
+		err = h.mfaDAO.UpdateSecret(ctx, userID, sealed)
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("Failed to encrypt legacy TOTP secret")
+		return
+	}
+	log.Info().Int64("user_id", userID).Msg("Encrypted legacy TOTP secret")
+}
+
+// issueRecoveryCodes generates and stores a new set of recovery codes, returning the plaintext
+func (h *AuthHandler) issueRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes, err := mfa.GenerateRecoveryCodes(mfa.RecoveryCodeCount)
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("Failed to generate recovery codes")
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = mfa.HashRecoveryCode(code)
+	}
+
+	if err := h.mfaDAO.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("Failed to store recovery codes")
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
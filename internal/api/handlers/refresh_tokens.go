@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// startSession creates a login session for the user and records it on the
+// user context, so tokens issued from it carry the session ID. The session is
+// also the refresh token family continued by every rotation.
+func (h *AuthHandler) startSession(ctx context.Context, userCtx *middleware.UserContext) error {
+	sessionID, err := h.generateSessionToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	client := middleware.ClientInfoFromContext(ctx)
+	if err := h.sessionDAO.CreateSession(ctx, sessionID, userCtx.UserID, client.UserAgentFamily, client.IPPrefix); err != nil {
+		return err
+	}
+
+	userCtx.SessionID = sessionID
+	return nil
+}
+
+// issueRefreshToken generates a refresh token for the user's session and
+// records its hash in the session's refresh token family
+func (h *AuthHandler) issueRefreshToken(ctx context.Context, userCtx *middleware.UserContext) (string, error) {
+	refreshToken, err := middleware.GenerateRefreshToken(userCtx, h.signingKeys, middleware.RefreshTokenExpiration)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	expiresAt := time.Now().UTC().Add(middleware.RefreshTokenExpiration)
+	if err := h.refreshTokenDAO.StoreToken(ctx, userCtx.SessionID, userCtx.UserID, middleware.HashRefreshToken(refreshToken), expiresAt); err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// revokeRefreshFamily revokes the session a refresh token belongs to. Unknown
+// or malformed tokens are ignored so that logout always succeeds.
+func (h *AuthHandler) revokeRefreshFamily(ctx context.Context, refreshToken, reason string) {
+	claims, err := middleware.ParseRefreshToken(refreshToken, h.signingKeys)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Invalid refresh token provided during logout")
+		return
+	}
+
+	record, err := h.refreshTokenDAO.GetTokenByHash(ctx, middleware.HashRefreshToken(refreshToken))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", claims.UserID).
+			Msg("Failed to look up refresh token during logout")
+		return
+	}
+	if record == nil {
+		log.Warn().
+			Int64("user_id", claims.UserID).
+			Msg("Unknown refresh token provided during logout")
+		return
+	}
+
+	if _, err := h.tokenRevocationDAO.RevokeSession(ctx, record.UserID, record.FamilyID, reason); err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", record.UserID).
+			Msg("Failed to revoke refresh token family")
+		return
+	}
+
+	log.Info().
+		Int64("user_id", record.UserID).
+		Str("reason", reason).
+		Msg("Revoked refresh token family")
+}
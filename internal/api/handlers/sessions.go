@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// SessionHandler handles login session management requests. Sessions belong
+// to the real user and are never tied to, or reported with, a pseudonym.
+type SessionHandler struct {
+	sessionDAO         *dao.SessionDAO
+	tokenRevocationDAO *dao.TokenRevocationDAO
+	userDAO            *dao.UserDAO
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(db bob.Executor) *SessionHandler {
+	return &SessionHandler{
+		sessionDAO:         dao.NewSessionDAO(db),
+		tokenRevocationDAO: dao.NewTokenRevocationDAO(db),
+		userDAO:            dao.NewUserDAO(db),
+	}
+}
+
+// ListSessions handles listing the current user's active sessions
+func (h *SessionHandler) ListSessions(ctx context.Context, input *models.SessionListInput) (*models.SessionListResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/sessions").
+		Str("component", "session_handler").
+		Msg("List sessions requested")
+
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+
+	sessions, err := h.listSessions(ctx, userCtx.UserID, userCtx.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSessionListResponse(userCtx.UserID, sessions), nil
+}
+
+// RevokeSession handles revoking one of the current user's sessions
+func (h *SessionHandler) RevokeSession(ctx context.Context, input *models.SessionRevokeInput) (*models.SessionRevokeResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/sessions/{session_id}").
+		Str("component", "session_handler").
+		Msg("Revoke session requested")
+
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+
+	return h.revokeSession(ctx, userCtx.UserID, input.SessionID, dao.TokenRevokeReasonSessionRevoked)
+}
+
+// RevokeAllSessions handles revoking the current user's sessions. The session
+// making the request is kept unless include_current is set.
+func (h *SessionHandler) RevokeAllSessions(ctx context.Context, input *models.SessionRevokeAllInput) (*models.SessionRevokeResponse, error) {
+	log.Info().
+		Str("endpoint", "auth/sessions").
+		Str("component", "session_handler").
+		Bool("include_current", input.IncludeCurrent).
+		Msg("Revoke all sessions requested")
+
+	userCtx, err := middleware.ExtractUserFromHumaInput(&input.AuthInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+
+	keepSessionID := userCtx.SessionID
+	if input.IncludeCurrent {
+		keepSessionID = ""
+	}
+
+	return h.revokeSessions(ctx, userCtx.UserID, keepSessionID, dao.TokenRevokeReasonSessionRevoked)
+}
+
+// AdminListSessions handles listing another user's active sessions
+func (h *SessionHandler) AdminListSessions(ctx context.Context, input *models.AdminSessionListInput) (*models.SessionListResponse, error) {
+	log.Info().
+		Str("endpoint", "admin/users/{user_id}/sessions").
+		Str("component", "session_handler").
+		Int64("target_user_id", input.UserID).
+		Msg("Admin list sessions requested")
+
+	adminCtx, err := h.requireSessionAdmin(ctx, &input.AuthInput, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := h.listSessions(ctx, input.UserID, adminCtx.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSessionListResponse(input.UserID, sessions), nil
+}
+
+// AdminRevokeSession handles revoking one of another user's sessions
+func (h *SessionHandler) AdminRevokeSession(ctx context.Context, input *models.AdminSessionRevokeInput) (*models.SessionRevokeResponse, error) {
+	log.Info().
+		Str("endpoint", "admin/users/{user_id}/sessions/{session_id}").
+		Str("component", "session_handler").
+		Int64("target_user_id", input.UserID).
+		Msg("Admin revoke session requested")
+
+	adminCtx, err := h.requireSessionAdmin(ctx, &input.AuthInput, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("admin_user_id", adminCtx.UserID).
+		Int64("target_user_id", input.UserID).
+		Msg("Admin revoking user session")
+
+	return h.revokeSession(ctx, input.UserID, input.SessionID, dao.TokenRevokeReasonAdminRevoked)
+}
+
+// AdminRevokeAllSessions handles revoking every session of another user
+func (h *SessionHandler) AdminRevokeAllSessions(ctx context.Context, input *models.AdminSessionRevokeAllInput) (*models.SessionRevokeResponse, error) {
+	log.Info().
+		Str("endpoint", "admin/users/{user_id}/sessions").
+		Str("component", "session_handler").
+		Int64("target_user_id", input.UserID).
+		Msg("Admin revoke all sessions requested")
+
+	adminCtx, err := h.requireSessionAdmin(ctx, &input.AuthInput, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("admin_user_id", adminCtx.UserID).
+		Int64("target_user_id", input.UserID).
+		Msg("Admin revoking all user sessions")
+
+	return h.revokeSessions(ctx, input.UserID, "", dao.TokenRevokeReasonAdminRevoked)
+}
+
+// requireSessionAdmin checks that the caller may manage other users' sessions
+// and that the target user exists
+func (h *SessionHandler) requireSessionAdmin(ctx context.Context, authInput *middleware.AuthInput, targetUserID int64) (*middleware.UserContext, error) {
+	userCtx, err := middleware.ExtractUserFromHumaInput(authInput)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication required")
+	}
+
+	if !userCtx.HasCapability("system_admin") && !userCtx.HasCapability("user_management") {
+		log.Warn().
+			Int64("user_id", userCtx.UserID).
+			Msg("User lacks permission to manage sessions")
+		return nil, huma.Error403Forbidden("insufficient permissions to manage sessions")
+	}
+
+	user, err := h.userDAO.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("target_user_id", targetUserID).
+			Msg("Failed to look up user")
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil {
+		return nil, huma.Error404NotFound("user not found")
+	}
+
+	return userCtx, nil
+}
+
+// listSessions returns a user's active sessions, flagging currentSessionID
+func (h *SessionHandler) listSessions(ctx context.Context, userID int64, currentSessionID string) ([]models.SessionInfo, error) {
+	sessions, err := h.sessionDAO.ListActiveSessions(ctx, userID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to list sessions")
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	infos := make([]models.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, models.SessionInfo{
+			SessionID:       session.SessionID,
+			UserAgentFamily: session.UserAgentFamily.V,
+			IPPrefix:        session.IPPrefix.V,
+			CreatedAt:       session.CreatedAt.UTC().Format(time.RFC3339),
+			LastSeenAt:      session.LastSeenAt.UTC().Format(time.RFC3339),
+			Current:         currentSessionID != "" && session.SessionID == currentSessionID,
+		})
+	}
+
+	return infos, nil
+}
+
+// revokeSession revokes a single session, returning 404 if the user has no such active session
+func (h *SessionHandler) revokeSession(ctx context.Context, userID int64, sessionID, reason string) (*models.SessionRevokeResponse, error) {
+	revoked, err := h.tokenRevocationDAO.RevokeSession(ctx, userID, sessionID, reason)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to revoke session")
+		return nil, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if !revoked {
+		return nil, huma.Error404NotFound("session not found")
+	}
+
+	log.Info().
+		Int64("user_id", userID).
+		Str("reason", reason).
+		Msg("Session revoked")
+
+	return models.NewSessionRevokeResponse(1), nil
+}
+
+// revokeSessions revokes all of a user's sessions except keepSessionID
+func (h *SessionHandler) revokeSessions(ctx context.Context, userID int64, keepSessionID, reason string) (*models.SessionRevokeResponse, error) {
+	revoked, err := h.tokenRevocationDAO.RevokeOtherSessions(ctx, userID, keepSessionID, reason)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", userID).
+			Msg("Failed to revoke sessions")
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	log.Info().
+		Int64("user_id", userID).
+		Int("revoked_sessions", revoked).
+		Str("reason", reason).
+		Msg("Sessions revoked")
+
+	return models.NewSessionRevokeResponse(revoked), nil
+}
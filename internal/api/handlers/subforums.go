@@ -533,6 +533,9 @@ func (h *SubforumHandler) CreateSubforum(ctx context.Context, input *models.Subf
 		log.Warn().Msg("Authentication required for subforum creation")
 		return nil, huma.Error401Unauthorized("authentication required")
 	}
+	if err := middleware.EnforceVerifiedEmail(ctx, userCtx, "create_subforum"); err != nil {
+		return nil, verifiedEmailError(err)
+	}
 
 	// Check capability
 	if !userCtx.HasCapability("create_subforum") {
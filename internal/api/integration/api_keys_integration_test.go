@@ -0,0 +1,157 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestAPIKeys_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("apikeys"), "TestPassword123!", []string{"user"})
+	otherUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("apikeys_other"), "TestPassword123!", []string{"user"})
+
+	var login, otherLogin models.UserLoginResponseBody
+	suite.ParseResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password), &login)
+	suite.ParseResponse(t, suite.LoginUser(t, server, otherUser.Email, otherUser.Password), &otherLogin)
+
+	createBody := map[string]interface{}{
+		"name":            "Digest bot",
+		"pseudonym_id":    testUser.PseudonymID,
+		"scopes":          []string{"subforums:read", "posts:read"},
+		"expires_in_days": 30,
+	}
+	resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/api-keys", login.AccessToken, createBody)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating an API key, got %d", resp.StatusCode)
+	}
+	var created models.APIKeySecretResponseBody
+	suite.ParseResponse(t, resp, &created)
+	if !strings.HasPrefix(created.Key, "hp_") || created.ExpiresAt == "" || len(created.Scopes) != 2 {
+		t.Fatalf("Unexpected created key: %+v", created)
+	}
+	keyPath := fmt.Sprintf("/api-keys/%d", created.KeyID)
+
+	t.Run("ScopesAreEnforced", func(t *testing.T) {
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/subforums", created.Key, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200 for a granted scope, got %d", resp.StatusCode)
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/messages", created.Key, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a scope the key lacks, got %d", resp.StatusCode)
+		}
+
+		// Keys cannot manage keys or call unscoped operations
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/api-keys", created.Key, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 listing keys with an API key, got %d", resp.StatusCode)
+		}
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/sessions", created.Key, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for an unscoped operation, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("CreateIsValidated", func(t *testing.T) {
+		body := map[string]interface{}{"name": "Bad", "pseudonym_id": testUser.PseudonymID, "scopes": []string{"admin:all"}}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/api-keys", login.AccessToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422 for an unknown scope, got %d", resp.StatusCode)
+		}
+
+		body = map[string]interface{}{"name": "Theft", "pseudonym_id": testUser.PseudonymID, "scopes": []string{"posts:read"}}
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/api-keys", otherLogin.AccessToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user's pseudonym, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ListShowsKeysWithoutSecrets", func(t *testing.T) {
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/api-keys", login.AccessToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing keys, got %d", resp.StatusCode)
+		}
+		var list models.APIKeyListResponseBody
+		suite.ParseResponse(t, resp, &list)
+		if len(list.Keys) != 1 || list.Keys[0].KeyID != created.KeyID || !list.Keys[0].Active {
+			t.Fatalf("Unexpected key list: %+v", list.Keys)
+		}
+		if list.Keys[0].LastUsedAt == "" {
+			t.Error("Expected the key's last use to be reported")
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/api-keys", otherLogin.AccessToken, nil)
+		var otherList models.APIKeyListResponseBody
+		suite.ParseResponse(t, resp, &otherList)
+		if len(otherList.Keys) != 0 {
+			t.Errorf("Expected another user to see no keys, got %d", len(otherList.Keys))
+		}
+	})
+
+	t.Run("RotateReplacesTheSecret", func(t *testing.T) {
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", keyPath+"/rotate", otherLogin.AccessToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 rotating another user's key, got %d", resp.StatusCode)
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", keyPath+"/rotate", login.AccessToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 rotating a key, got %d", resp.StatusCode)
+		}
+		var rotated models.APIKeySecretResponseBody
+		suite.ParseResponse(t, resp, &rotated)
+		if rotated.KeyID != created.KeyID || rotated.Key == created.Key {
+			t.Fatalf("Expected a new secret for the same key, got %+v", rotated)
+		}
+
+		if _, _, err := suite.APIKeyDAO.ValidateAPIKey(ctx, created.Key); err == nil {
+			t.Error("Expected the previous secret to stop working")
+		}
+		if _, _, err := suite.APIKeyDAO.ValidateAPIKey(ctx, rotated.Key); err != nil {
+			t.Errorf("Expected the new secret to work: %v", err)
+		}
+		created.Key = rotated.Key
+	})
+
+	t.Run("RevokeDisablesTheKey", func(t *testing.T) {
+		resp := suite.MakeAuthenticatedRequest(t, server, "DELETE", keyPath, login.AccessToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 revoking a key, got %d", resp.StatusCode)
+		}
+
+		if _, _, err := suite.APIKeyDAO.ValidateAPIKey(ctx, created.Key); err == nil {
+			t.Error("Expected a revoked key to stop working")
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", keyPath+"/rotate", login.AccessToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 rotating a revoked key, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/correlation"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestBreakGlassCorrelation_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.BreakGlass.Roles = []string{"trust_safety"}
+	suite.Config.Security.BreakGlass.ReviewerRoles = []string{"legal_team"}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	reviewer := createCorrelationAdmin(t, suite, "break_glass_reviewer", "legal_team")
+	target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("break_glass_target"), "TestPassword123!", []string{"user"})
+	reviewerToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, reviewer.Email, reviewer.Password))
+
+	breakGlass := func(t *testing.T, token, reasonCode string) *http.Response {
+		t.Helper()
+		body := map[string]interface{}{
+			"requested_pseudonym": target.PseudonymID,
+			"reason_code":         reasonCode,
+			"justification":       "Credible threat of violence against a named person",
+			"incident_id":         "threat_case_42",
+		}
+		return suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/break-glass", token, body)
+	}
+
+	// review returns the only break-glass review of a requester
+	review := func(t *testing.T, requester *testutil.TestUser) models.BreakGlassReview {
+		t.Helper()
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/correlation/break-glass/reviews", reviewerToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing break-glass reviews, got %d", resp.StatusCode)
+		}
+		var list models.BreakGlassReviewListResponseBody
+		suite.ParseResponse(t, resp, &list)
+		var found []models.BreakGlassReview
+		for _, review := range list.Reviews {
+			if review.RequesterID == requester.UserID {
+				found = append(found, review)
+			}
+		}
+		if len(found) != 1 {
+			t.Fatalf("Expected 1 break-glass review of the requester, got %d", len(found))
+		}
+		return found[0]
+	}
+
+	decide := func(t *testing.T, token, reviewID, decision string) *http.Response {
+		t.Helper()
+		path := fmt.Sprintf("/admin/correlation/break-glass/reviews/%s/%s", reviewID, decision)
+		return suite.MakeAuthenticatedRequest(t, server, "POST", path, token, map[string]interface{}{"comment": "Reviewed"})
+	}
+
+	t.Run("RequiresReasonCodeAndRole", func(t *testing.T) {
+		requester := createCorrelationAdmin(t, suite, "break_glass_unreasoned", "trust_safety")
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, requester.Email, requester.Password))
+
+		resp := breakGlass(t, token, "curiosity")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422 without an emergency reason code, got %d", resp.StatusCode)
+		}
+
+		// Reviewers correlate identities, but may not break the glass
+		resp = breakGlass(t, reviewerToken, correlation.ReasonThreatOfViolence)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 without a break-glass role, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("UseIsAuditedAndSignedOff", func(t *testing.T) {
+		requester := createCorrelationAdmin(t, suite, "break_glass_requester", "trust_safety")
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, requester.Email, requester.Password))
+
+		resp := breakGlass(t, token, correlation.ReasonThreatOfViolence)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 breaking the glass, got %d", resp.StatusCode)
+		}
+		var correlated models.BreakGlassCorrelationResponseBody
+		suite.ParseResponse(t, resp, &correlated)
+		if !hasCorrelationResult(correlated.Results, target.PseudonymID) {
+			t.Errorf("Expected the results to contain the target pseudonym, got %+v", correlated.Results)
+		}
+		if correlated.Review.Status != dao.BreakGlassReviewPending || correlated.Review.AuditID != correlated.AuditID {
+			t.Errorf("Expected a pending review of the audit entry, got %+v", correlated.Review)
+		}
+
+		opened := review(t, requester)
+		if opened.Status != dao.BreakGlassReviewPending || opened.ReasonCode != correlation.ReasonThreatOfViolence || opened.AuditID != correlated.AuditID {
+			t.Fatalf("Unexpected break-glass review: %+v", opened)
+		}
+
+		// The audit entry is awaiting review
+		var source, legalBasis, status string
+		if err := suite.DB.QueryRowContext(ctx,
+			"SELECT a.request_source, a.legal_basis, r.status FROM correlation_audit a JOIN break_glass_reviews r ON r.audit_id = a.audit_id WHERE a.audit_id = $1",
+			correlated.AuditID).Scan(&source, &legalBasis, &status); err != nil {
+			t.Fatalf("Failed to query correlation audit: %v", err)
+		}
+		if source != dao.RequestSourceBreakGlass || legalBasis != correlation.ReasonThreatOfViolence || status != dao.BreakGlassReviewPending {
+			t.Errorf("Expected a break-glass audit entry pending review, got source %q, legal basis %q and review status %q", source, legalBasis, status)
+		}
+
+		// The short-lived key was granted for this use only
+		var scope string
+		var active sql.Null[bool]
+		if err := suite.DB.QueryRowContext(ctx,
+			"SELECT k.scope, k.is_active FROM role_keys k JOIN break_glass_reviews r ON r.key_id = k.key_id WHERE r.review_id = $1",
+			opened.ReviewID).Scan(&scope, &active); err != nil {
+			t.Fatalf("Failed to query break-glass role key: %v", err)
+		}
+		if scope != correlation.BreakGlassKeyScope || active.V {
+			t.Errorf("Expected an inactive break-glass role key, got scope %q and active %v", scope, active.V)
+		}
+
+		msg, ok := suite.Mailer.LastMessageTo(reviewer.Email)
+		if !ok || !strings.HasPrefix(msg.Subject, "HashPost break-glass correlation") {
+			t.Errorf("Expected reviewers to be emailed the break-glass correlation, got %+v", msg)
+		}
+
+		resp = decide(t, token, opened.ReviewID, "sign-off")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 signing off an own break-glass correlation, got %d", resp.StatusCode)
+		}
+		resp = decide(t, reviewerToken, opened.ReviewID, "sign-off")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 signing off the break-glass correlation, got %d", resp.StatusCode)
+		}
+		var signedOff models.BreakGlassReview
+		suite.ParseResponse(t, resp, &signedOff)
+		if signedOff.Status != dao.BreakGlassReviewSignedOff || signedOff.ReviewerID == nil || *signedOff.ReviewerID != reviewer.UserID {
+			t.Errorf("Unexpected signed off review: %+v", signedOff)
+		}
+		resp = decide(t, reviewerToken, opened.ReviewID, "reject")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 reviewing a signed off correlation, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("OverdueUseFlagsAccount", func(t *testing.T) {
+		requester := createCorrelationAdmin(t, suite, "break_glass_overdue", "trust_safety")
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, requester.Email, requester.Password))
+
+		resp := breakGlass(t, token, correlation.ReasonSelfHarm)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 breaking the glass, got %d", resp.StatusCode)
+		}
+
+		// Nobody reviewed the use in time
+		if _, err := suite.DB.ExecContext(ctx,
+			"UPDATE break_glass_reviews SET review_due_at = NOW() - INTERVAL '1 minute' WHERE requester_id = $1",
+			requester.UserID); err != nil {
+			t.Fatalf("Failed to pass the review deadline: %v", err)
+		}
+		overdue := review(t, requester)
+		if overdue.Status != dao.BreakGlassReviewOverdue || overdue.FlaggedAt == "" {
+			t.Fatalf("Expected the review to be overdue and flagged, got %+v", overdue)
+		}
+
+		var events int
+		if err := suite.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM system_events WHERE event_type = $1 AND event_data->>'review_id' = $2",
+			correlation.BreakGlassFlaggedEventType, overdue.ReviewID).Scan(&events); err != nil {
+			t.Fatalf("Failed to query system events: %v", err)
+		}
+		if events != 1 {
+			t.Errorf("Expected 1 flagged event, got %d", events)
+		}
+
+		resp = breakGlass(t, token, correlation.ReasonSelfHarm)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 breaking the glass while flagged, got %d", resp.StatusCode)
+		}
+
+		// Signing off the overdue use lifts the flag
+		resp = decide(t, reviewerToken, overdue.ReviewID, "sign-off")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 signing off the overdue correlation, got %d", resp.StatusCode)
+		}
+		resp = breakGlass(t, token, correlation.ReasonSelfHarm)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200 once the flag is lifted, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// hasCorrelationResult reports whether correlation results contain a pseudonym
+func hasCorrelationResult(results []models.CorrelationResult, pseudonymID string) bool {
+	for _, result := range results {
+		if result.PseudonymID == pseudonymID {
+			return true
+		}
+	}
+	return false
+}
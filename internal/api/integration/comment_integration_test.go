@@ -157,7 +157,7 @@ func TestCommentWorkflow(t *testing.T) {
 			Roles:             testUser.Roles,
 			Capabilities:      testUser.Capabilities,
 		}
-		jwt, err := middleware.GenerateJWT(userCtx, suite.Config.JWT.Secret, 24*time.Hour)
+		jwt, err := middleware.GenerateJWT(userCtx, suite.SigningKeys, 24*time.Hour)
 		require.NoError(t, err)
 		commentInput.AuthInput.AccessToken = jwt
 
@@ -0,0 +1,392 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/compliance"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestComplianceCases_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.CorrelationApproval.ApproverRoles = []string{"legal_team"}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	legal := createCorrelationAdmin(t, suite, "compliance_legal", "legal_team")
+	grantCapability(t, suite, legal, "legal_compliance")
+	counsel := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("compliance_counsel"), "TestPassword123!", []string{"user", "legal_team"})
+	grantCapability(t, suite, counsel, "legal_compliance")
+	requester := createCorrelationAdmin(t, suite, "compliance_requester", "trust_safety")
+	target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("compliance_target"), "TestPassword123!", []string{"user"})
+
+	legalToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, legal.Email, legal.Password))
+	requesterToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, requester.Email, requester.Password))
+
+	openCase := func(t *testing.T, dueDate string) models.ComplianceReport {
+		t.Helper()
+		body := map[string]interface{}{
+			"report_type":          "court_order",
+			"requesting_authority": "District Court for the Northern District",
+			"request_id":           fmt.Sprintf("2025-CV-%d", time.Now().UnixNano()),
+			"request_date":         time.Now().AddDate(0, 0, -30).Format(time.DateOnly),
+			"due_date":             dueDate,
+			"scope_description":    "Account records of the pseudonym named in the order",
+			"assigned_user_id":     legal.UserID,
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/compliance/reports", legalToken, body)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201 opening a compliance case, got %d", resp.StatusCode)
+		}
+		var report models.ComplianceReport
+		suite.ParseResponse(t, resp, &report)
+		if report.Status != "pending" || report.AssignedUserID == nil || *report.AssignedUserID != legal.UserID {
+			t.Fatalf("Unexpected compliance case: %+v", report)
+		}
+		return report
+	}
+
+	getCase := func(t *testing.T, reportID string) models.ComplianceReportDetail {
+		t.Helper()
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/compliance/reports/"+reportID, legalToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 getting the compliance case, got %d", resp.StatusCode)
+		}
+		var detail models.ComplianceReportDetail
+		suite.ParseResponse(t, resp, &detail)
+		return detail
+	}
+
+	setStatus := func(t *testing.T, reportID, status string) *http.Response {
+		t.Helper()
+		path := fmt.Sprintf("/admin/compliance/reports/%s/status", reportID)
+		return suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{"status": status})
+	}
+
+	// correlateFor requests, approves and executes an identity correlation
+	// of the target, naming a case if reportID is set
+	correlateFor := func(t *testing.T, reportID string) models.CorrelationRequest {
+		t.Helper()
+		body := map[string]interface{}{
+			"requested_pseudonym":   target.PseudonymID,
+			"requested_fingerprint": "",
+			"justification":         "Records requested by court order",
+			"legal_basis":           "Court order 2025-117",
+			"incident_id":           "court_order_117",
+			"scope":                 "platform_wide",
+		}
+		if reportID != "" {
+			body["compliance_report_id"] = reportID
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", requesterToken, body)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected status 202 requesting correlation, got %d", resp.StatusCode)
+		}
+		var request models.CorrelationRequest
+		suite.ParseResponse(t, resp, &request)
+		if request.ComplianceReportID != reportID {
+			t.Fatalf("Expected request for compliance case %q, got %q", reportID, request.ComplianceReportID)
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/requests/"+request.RequestID+"/approve", legalToken,
+			map[string]interface{}{"comment": "Covered by the order"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 approving the request, got %d", resp.StatusCode)
+		}
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/requests/"+request.RequestID+"/execute", requesterToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 executing the request, got %d", resp.StatusCode)
+		}
+		return request
+	}
+
+	t.Run("CaseLifecycle", func(t *testing.T) {
+		report := openCase(t, time.Now().AddDate(0, 0, 14).Format(time.DateOnly))
+
+		// Closed cases cannot be reached from pending
+		resp := setStatus(t, report.ReportID, "completed")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 completing a pending case, got %d", resp.StatusCode)
+		}
+
+		// Cases are assigned to the legal team only
+		path := fmt.Sprintf("/admin/compliance/reports/%s/assign", report.ReportID)
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{"assigned_user_id": target.UserID})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422 assigning to a user without legal_compliance, got %d", resp.StatusCode)
+		}
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken,
+			map[string]interface{}{"assigned_user_id": counsel.UserID, "note": "Counsel handles court orders"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 assigning the case, got %d", resp.StatusCode)
+		}
+		var assigned models.ComplianceReport
+		suite.ParseResponse(t, resp, &assigned)
+		if assigned.AssignedUserID == nil || *assigned.AssignedUserID != counsel.UserID {
+			t.Errorf("Expected case to be assigned to counsel, got %+v", assigned.AssignedUserID)
+		}
+
+		for _, status := range []string{"in_progress", "completed"} {
+			resp = setStatus(t, report.ReportID, status)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status 200 moving the case to %s, got %d", status, resp.StatusCode)
+			}
+			var updated models.ComplianceReport
+			suite.ParseResponse(t, resp, &updated)
+			if updated.Status != status {
+				t.Errorf("Expected case to be %s, got %s", status, updated.Status)
+			}
+		}
+
+		// Closed cases do not change
+		resp = setStatus(t, report.ReportID, "rejected")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 rejecting a completed case, got %d", resp.StatusCode)
+		}
+
+		detail := getCase(t, report.ReportID)
+		if detail.CompletedAt == "" {
+			t.Error("Expected completed case to carry its completion time")
+		}
+		var events []string
+		for _, event := range detail.History {
+			events = append(events, event.EventType)
+		}
+		expected := []string{"opened", "assigned", "assigned", "status_changed", "status_changed"}
+		if fmt.Sprint(events) != fmt.Sprint(expected) {
+			t.Errorf("Expected history %v, got %v", expected, events)
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/compliance/reports?status=completed", legalToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing cases, got %d", resp.StatusCode)
+		}
+		var list models.ComplianceReportListResponseBody
+		suite.ParseResponse(t, resp, &list)
+		found := false
+		for _, listed := range list.Reports {
+			found = found || listed.ReportID == report.ReportID
+		}
+		if !found {
+			t.Error("Expected the completed case to be listed")
+		}
+	})
+
+	t.Run("LegalComplianceRequired", func(t *testing.T) {
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/compliance/reports", requesterToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 listing cases without legal_compliance, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("CorrelationsAreLinked", func(t *testing.T) {
+		report := openCase(t, time.Now().AddDate(0, 0, 14).Format(time.DateOnly))
+
+		// A correlation requested for the case is linked once executed
+		linked := correlateFor(t, report.ReportID)
+		detail := getCase(t, report.ReportID)
+		if len(detail.Correlations) != 1 || detail.Correlations[0].RequestID != linked.RequestID {
+			t.Fatalf("Expected the executed correlation to be linked, got %+v", detail.Correlations)
+		}
+		if detail.Correlations[0].RequestedPseudonym != target.PseudonymID || detail.Correlations[0].CorrelatedBy != requester.UserID {
+			t.Errorf("Unexpected linked correlation: %+v", detail.Correlations[0])
+		}
+
+		// Other correlations are attached afterwards
+		other := correlateFor(t, "")
+		path := fmt.Sprintf("/admin/compliance/reports/%s/correlations", report.ReportID)
+		for i := 0; i < 2; i++ {
+			resp := suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken,
+				map[string]interface{}{"request_id": other.RequestID, "correlation_scope": "Pseudonyms named in the order"})
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status 200 attaching a correlation, got %d", resp.StatusCode)
+			}
+			suite.ParseResponse(t, resp, &detail)
+		}
+		if len(detail.Correlations) != 2 {
+			t.Errorf("Expected two linked correlations after attaching one twice, got %d", len(detail.Correlations))
+		}
+
+		// Correlations that never ran cannot be attached
+		body := map[string]interface{}{
+			"requested_pseudonym":   target.PseudonymID,
+			"requested_fingerprint": "",
+			"justification":         "Records requested by court order",
+			"legal_basis":           "Court order 2025-117",
+			"incident_id":           "court_order_117",
+			"scope":                 "platform_wide",
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", requesterToken, body)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected status 202 requesting correlation, got %d", resp.StatusCode)
+		}
+		var pending models.CorrelationRequest
+		suite.ParseResponse(t, resp, &pending)
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{"request_id": pending.RequestID})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 attaching a pending correlation, got %d", resp.StatusCode)
+		}
+
+		// Closed cases take no new correlations
+		resp = setStatus(t, report.ReportID, "rejected")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 rejecting the case, got %d", resp.StatusCode)
+		}
+		body["compliance_report_id"] = report.ReportID
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", requesterToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 requesting correlation for a rejected case, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("DisclosureBundle", func(t *testing.T) {
+		report := openCase(t, time.Now().AddDate(0, 0, 14).Format(time.DateOnly))
+		correlateFor(t, report.ReportID)
+		path := fmt.Sprintf("/admin/compliance/reports/%s/bundle", report.ReportID)
+
+		// Pending cases are not disclosed
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 exporting a pending case, got %d", resp.StatusCode)
+		}
+		resp = setStatus(t, report.ReportID, "in_progress")
+		resp.Body.Close()
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{})
+		archive, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 exporting the bundle, got %d: %s", resp.StatusCode, archive)
+		}
+		manifest, files, err := disclosure.Open(suite.DisclosureSigner.PublicKey(), archive)
+		if err != nil {
+			t.Fatalf("Expected the bundle to verify, got %v", err)
+		}
+		if manifest.ReportID != report.ReportID || resp.Header.Get("X-Bundle-ID") != manifest.BundleID {
+			t.Errorf("Unexpected manifest: %+v", manifest)
+		}
+		paths := map[string]bool{}
+		for _, file := range files {
+			paths[file.Path] = true
+		}
+		for _, expected := range []string{"case.json", "correlations.json", "audit/correlation_audit.json", "content/" + target.PseudonymID + "/posts.json"} {
+			if !paths[expected] {
+				t.Errorf("Expected %s in the bundle, got %v", expected, paths)
+			}
+		}
+
+		// Encrypted bundles are only readable with the recipient's key
+		recipient, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate recipient key: %v", err)
+		}
+		publicKey, _ := x509.MarshalPKIXPublicKey(recipient.PublicKey())
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, map[string]interface{}{
+			"recipient_public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+		})
+		encrypted, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !disclosure.IsEncrypted(encrypted) {
+			t.Fatalf("Expected an encrypted bundle, got status %d", resp.StatusCode)
+		}
+		decrypted, err := disclosure.Decrypt(recipient, encrypted)
+		if err != nil {
+			t.Fatalf("Failed to decrypt bundle: %v", err)
+		}
+		if _, _, err := disclosure.Open(suite.DisclosureSigner.PublicKey(), decrypted); err != nil {
+			t.Errorf("Expected the decrypted bundle to verify, got %v", err)
+		}
+
+		exports := 0
+		for _, event := range getCase(t, report.ReportID).History {
+			if event.EventType == "bundle_exported" {
+				exports++
+			}
+		}
+		if exports != 2 {
+			t.Errorf("Expected both exports in the history of the case, got %d", exports)
+		}
+	})
+
+	t.Run("OverdueAlert", func(t *testing.T) {
+		report := openCase(t, time.Now().AddDate(0, 0, -2).Format(time.DateOnly))
+		if !report.Overdue {
+			t.Fatal("Expected a case past its due date to be overdue")
+		}
+
+		monitor := compliance.NewOverdueMonitor(suite.DB)
+		alerted := func() bool {
+			reports, err := monitor.Check(ctx)
+			if err != nil {
+				t.Fatalf("Overdue check failed: %v", err)
+			}
+			for _, overdue := range reports {
+				if overdue.ReportID.String() == report.ReportID {
+					return true
+				}
+			}
+			return false
+		}
+		if !alerted() {
+			t.Fatal("Expected an alert for the overdue case")
+		}
+		if alerted() {
+			t.Error("Expected the alert for an overdue case to be raised once")
+		}
+
+		var events int
+		err := suite.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM system_events WHERE event_type = $1 AND event_data->>'report_id' = $2",
+			compliance.OverdueEventType, report.ReportID).Scan(&events)
+		if err != nil {
+			t.Fatalf("Failed to query system events: %v", err)
+		}
+		if events != 1 {
+			t.Errorf("Expected one overdue system event, got %d", events)
+		}
+
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/compliance/reports?overdue=true", legalToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing overdue cases, got %d", resp.StatusCode)
+		}
+		var list models.ComplianceReportListResponseBody
+		suite.ParseResponse(t, resp, &list)
+		for _, listed := range list.Reports {
+			if !listed.Overdue {
+				t.Errorf("Expected only overdue cases, got %+v", listed)
+			}
+		}
+		if history := getCase(t, report.ReportID).History; history[len(history)-1].EventType != "overdue" {
+			t.Errorf("Expected the alert in the history of the case, got %+v", history)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/correlation"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestCorrelationQuotasAndAnomalies_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	auditDAO := dao.NewCorrelationAuditDAO(suite.DB, suite.AuditChainDAO)
+	settingDAO := dao.NewSystemSettingDAO(suite.DB)
+
+	// record records a completed fingerprint correlation of a pseudonym by an admin
+	record := func(t *testing.T, admin *testutil.TestUser, pseudonymID string, at time.Time) {
+		t.Helper()
+		entry := &dao.CorrelationAuditEntry{
+			UserID:             admin.UserID,
+			PseudonymID:        admin.PseudonymID,
+			AdminUsername:      admin.Email,
+			RoleUsed:           "trust_safety",
+			RequestedPseudonym: pseudonymID,
+			Justification:      "Investigation of ban evasion",
+			CorrelationType:    "fingerprint",
+			Timestamp:          sql.Null[time.Time]{V: at, Valid: true},
+		}
+		if err := auditDAO.RecordEntry(ctx, entry); err != nil {
+			t.Fatalf("Failed to record correlation: %v", err)
+		}
+	}
+
+	t.Run("QuotaRefusesFurtherCorrelations", func(t *testing.T) {
+		investigator := createCorrelationAdmin(t, suite, "quota_investigator", "trust_safety")
+		grantCapability(t, suite, investigator, "correlate_fingerprints")
+		target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("quota_target"), "TestPassword123!", []string{"user"})
+		subforum := suite.CreateTestSubforum(t, fmt.Sprintf("quota-%d", time.Now().UnixNano()), "Quota subforum", investigator.UserID, false)
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, investigator.Email, investigator.Password))
+
+		// Limit the investigator to one correlation a day
+		setting, err := settingDAO.GetSetting(ctx, correlation.QuotaSetting)
+		if err != nil || setting == nil {
+			t.Fatalf("Failed to get quota setting: %v", err)
+		}
+		quotas, err := correlation.ParseQuotas(setting.SettingValue)
+		if err != nil {
+			t.Fatalf("Failed to parse quota setting: %v", err)
+		}
+		quotas.Users = map[string]correlation.QuotaLimits{strconv.FormatInt(investigator.UserID, 10): {Daily: 1}}
+		value, _ := json.Marshal(quotas)
+		if _, err := suite.DB.ExecContext(ctx, "UPDATE system_settings SET setting_value = $1 WHERE setting_key = $2", string(value), correlation.QuotaSetting); err != nil {
+			t.Fatalf("Failed to set quota: %v", err)
+		}
+		defer suite.DB.ExecContext(ctx, "UPDATE system_settings SET setting_value = $1 WHERE setting_key = $2", setting.SettingValue, correlation.QuotaSetting)
+
+		correlate := func() *http.Response {
+			body := map[string]interface{}{
+				"requested_pseudonym":   target.PseudonymID,
+				"requested_fingerprint": "",
+				"justification":         "Investigation of ban evasion",
+				"subforum_id":           subforum.SubforumID,
+				"incident_id":           "ban_evasion_123",
+			}
+			return suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/fingerprint", token, body)
+		}
+
+		resp := correlate()
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("Expected the first correlation to be within quota, got %d", resp.StatusCode)
+		}
+
+		// Correlations before the last day do not count
+		record(t, investigator, target.PseudonymID, time.Now().Add(-25*time.Hour))
+		record(t, investigator, target.PseudonymID, time.Now().Add(-time.Minute))
+		resp = correlate()
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429 once the daily quota is used, got %d", resp.StatusCode)
+		}
+
+		var events int
+		if err := suite.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM system_events WHERE event_type = $1 AND (event_data->>'user_id')::bigint = $2",
+			correlation.QuotaEventType, investigator.UserID).Scan(&events); err != nil {
+			t.Fatalf("Failed to query system events: %v", err)
+		}
+		if events != 1 {
+			t.Errorf("Expected 1 quota event, got %d", events)
+		}
+	})
+
+	t.Run("DetectorAlertsSupervisors", func(t *testing.T) {
+		supervisor := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("anomaly_supervisor"), "TestPassword123!", []string{"user", "platform_admin"})
+		investigator := createCorrelationAdmin(t, suite, "anomaly_investigator", "trust_safety")
+		target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("anomaly_target"), "TestPassword123!", []string{"user"})
+
+		// Only the correlations of this test are checked
+		if _, err := suite.DB.ExecContext(ctx,
+			"UPDATE system_settings SET setting_value = (SELECT COALESCE(MAX(chain_seq), 0) FROM correlation_audit)::text WHERE setting_key = $1",
+			correlation.CursorSetting); err != nil {
+			t.Fatalf("Failed to reset anomaly cursor: %v", err)
+		}
+
+		detector, err := correlation.NewDetector(suite.DB, &config.CorrelationAnomalyPolicy{
+			Burst:        config.RateLimit{Requests: 3, Period: time.Hour},
+			Repeat:       config.RateLimit{Requests: 2, Period: 7 * 24 * time.Hour},
+			WorkdayStart: 0,
+			WorkdayEnd:   24,
+			WorkingDays:  []string{"mon", "tue", "wed", "thu", "fri"},
+			Timezone:     "UTC",
+		}, correlation.NewMailNotifier(suite.DB, suite.Mailer, nil))
+		if err != nil {
+			t.Fatalf("Failed to create detector: %v", err)
+		}
+
+		// A Saturday, outside working days
+		saturday := time.Date(2025, 7, 5, 3, 0, 0, 0, time.UTC)
+		record(t, investigator, target.PseudonymID, saturday)
+		record(t, investigator, target.PseudonymID, saturday.Add(time.Minute))
+		record(t, investigator, supervisor.PseudonymID, saturday.Add(2*time.Minute))
+
+		alerts, err := detector.Check(ctx)
+		if err != nil {
+			t.Fatalf("Anomaly check failed: %v", err)
+		}
+		kinds := make(map[string]int)
+		for _, alert := range alerts {
+			if alert.UserID == investigator.UserID {
+				kinds[alert.Kind]++
+			}
+		}
+		if kinds[correlation.AnomalyOffHours] != 3 || kinds[correlation.AnomalyBurst] != 1 || kinds[correlation.AnomalyRepeatLookup] != 1 {
+			t.Errorf("Expected 3 off hours, 1 burst and 1 repeat lookup alerts, got %v", kinds)
+		}
+
+		msg, ok := suite.Mailer.LastMessageTo(supervisor.Email)
+		if !ok || !strings.HasPrefix(msg.Subject, "HashPost correlation alert") {
+			t.Errorf("Expected platform admins to be emailed alerts, got %+v", msg)
+		}
+
+		var events int
+		if err := suite.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM system_events WHERE event_type = $1 AND (event_data->>'user_id')::bigint = $2",
+			correlation.AnomalyEventType, investigator.UserID).Scan(&events); err != nil {
+			t.Fatalf("Failed to query system events: %v", err)
+		}
+		if events != 5 {
+			t.Errorf("Expected 5 anomaly events, got %d", events)
+		}
+
+		// Checked correlations are not alerted again
+		alerts, err = detector.Check(ctx)
+		if err != nil {
+			t.Fatalf("Anomaly check failed: %v", err)
+		}
+		for _, alert := range alerts {
+			if alert.UserID == investigator.UserID {
+				t.Errorf("Expected no new alerts, got %+v", alert)
+			}
+		}
+	})
+}
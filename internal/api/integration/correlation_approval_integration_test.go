@@ -0,0 +1,238 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+	"github.com/stephenafamo/bob/types"
+)
+
+func TestCorrelationApproval_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.CorrelationApproval.ApproverRoles = []string{"legal_team"}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	requester := createCorrelationAdmin(t, suite, "correlation_requester", "trust_safety")
+	approver := createCorrelationAdmin(t, suite, "correlation_approver", "legal_team")
+	bystander := createCorrelationAdmin(t, suite, "correlation_bystander", "trust_safety")
+	target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("correlation_target"), "TestPassword123!", []string{"user"})
+
+	requesterToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, requester.Email, requester.Password))
+	approverToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, approver.Email, approver.Password))
+	bystanderToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, bystander.Email, bystander.Password))
+
+	requestCorrelation := func(t *testing.T) models.CorrelationRequest {
+		t.Helper()
+		body := map[string]interface{}{
+			"requested_pseudonym":   target.PseudonymID,
+			"requested_fingerprint": "",
+			"justification":         "Investigation of reported harassment across subforums",
+			"legal_basis":           "Court order 2024-117",
+			"incident_id":           "harassment_case_123",
+			"scope":                 "platform_wide",
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", requesterToken, body)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected status 202 requesting correlation, got %d", resp.StatusCode)
+		}
+		var request models.CorrelationRequest
+		suite.ParseResponse(t, resp, &request)
+		if request.Status != "pending" || request.RequesterID != requester.UserID {
+			t.Fatalf("Unexpected correlation request: %+v", request)
+		}
+		return request
+	}
+
+	review := func(t *testing.T, token, requestID, decision string) *http.Response {
+		t.Helper()
+		path := fmt.Sprintf("/admin/correlation/requests/%s/%s", requestID, decision)
+		return suite.MakeAuthenticatedRequest(t, server, "POST", path, token, map[string]interface{}{"comment": "Reviewed"})
+	}
+
+	execute := func(t *testing.T, token, requestID string) *http.Response {
+		t.Helper()
+		path := fmt.Sprintf("/admin/correlation/requests/%s/execute", requestID)
+		return suite.MakeAuthenticatedRequest(t, server, "POST", path, token, nil)
+	}
+
+	t.Run("ApprovalIsRequired", func(t *testing.T) {
+		request := requestCorrelation(t)
+
+		resp := execute(t, requesterToken, request.RequestID)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 executing a pending request, got %d", resp.StatusCode)
+		}
+
+		// The requester cannot approve their own request
+		resp = review(t, requesterToken, request.RequestID, "approve")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a requester without an approver role, got %d", resp.StatusCode)
+		}
+
+		// Nor can an admin without an approver role
+		resp = review(t, bystanderToken, request.RequestID, "approve")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a reviewer without an approver role, got %d", resp.StatusCode)
+		}
+
+		resp = review(t, approverToken, request.RequestID, "approve")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 approving the request, got %d", resp.StatusCode)
+		}
+		var approved models.CorrelationRequest
+		suite.ParseResponse(t, resp, &approved)
+		if approved.Status != "approved" || approved.ReviewerID == nil || *approved.ReviewerID != approver.UserID {
+			t.Fatalf("Unexpected approved request: %+v", approved)
+		}
+
+		// A request is reviewed only once
+		resp = review(t, approverToken, request.RequestID, "reject")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 reviewing an approved request, got %d", resp.StatusCode)
+		}
+
+		// Only the requester executes the approval, and only once
+		resp = execute(t, approverToken, request.RequestID)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 executing another admin's request, got %d", resp.StatusCode)
+		}
+		resp = execute(t, requesterToken, request.RequestID)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 executing the approved request, got %d", resp.StatusCode)
+		}
+		var executed models.IdentityCorrelationResponseBody
+		suite.ParseResponse(t, resp, &executed)
+		if !hasCorrelationResult(executed.Results, target.PseudonymID) {
+			t.Errorf("Expected the results to contain the approved pseudonym, got %+v", executed.Results)
+		}
+		resp = execute(t, requesterToken, request.RequestID)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 executing a request twice, got %d", resp.StatusCode)
+		}
+
+		statuses := requestAuditStatuses(t, suite, request.RequestID)
+		expected := []string{"pending", "approved", "executed"}
+		if fmt.Sprint(statuses) != fmt.Sprint(expected) {
+			t.Errorf("Expected audit trail %v, got %v", expected, statuses)
+		}
+	})
+
+	t.Run("RejectedRequestsCannotExecute", func(t *testing.T) {
+		request := requestCorrelation(t)
+
+		resp := review(t, approverToken, request.RequestID, "reject")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 rejecting the request, got %d", resp.StatusCode)
+		}
+		resp = execute(t, requesterToken, request.RequestID)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 executing a rejected request, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ApprovalsExpire", func(t *testing.T) {
+		request := requestCorrelation(t)
+
+		resp := review(t, approverToken, request.RequestID, "approve")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 approving the request, got %d", resp.StatusCode)
+		}
+		if _, err := suite.DB.ExecContext(ctx,
+			"UPDATE correlation_requests SET expires_at = NOW() - INTERVAL '1 minute' WHERE request_id = $1",
+			request.RequestID); err != nil {
+			t.Fatalf("Failed to expire the approval: %v", err)
+		}
+
+		resp = execute(t, requesterToken, request.RequestID)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 executing an expired approval, got %d", resp.StatusCode)
+		}
+
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/correlation/requests?status=expired", approverToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing requests, got %d", resp.StatusCode)
+		}
+		var list models.CorrelationRequestListResponseBody
+		suite.ParseResponse(t, resp, &list)
+		found := false
+		for _, listed := range list.Requests {
+			found = found || listed.RequestID == request.RequestID
+		}
+		if !found {
+			t.Error("Expected the expired request to be listed as expired")
+		}
+
+		statuses := requestAuditStatuses(t, suite, request.RequestID)
+		expected := []string{"pending", "approved", "expired"}
+		if fmt.Sprint(statuses) != fmt.Sprint(expected) {
+			t.Errorf("Expected audit trail %v, got %v", expected, statuses)
+		}
+	})
+}
+
+// createCorrelationAdmin creates an admin allowed to take part in identity correlation
+func createCorrelationAdmin(t *testing.T, suite *testutil.IntegrationTestSuite, prefix, role string) *testutil.TestUser {
+	t.Helper()
+	user := suite.CreateTestUser(t, testutil.GenerateUniqueEmail(prefix), "TestPassword123!", []string{"user", role})
+	grantCapability(t, suite, user, "correlate_identities")
+	return user
+}
+
+// grantCapability adds a capability to a user; it applies from the user's next login
+func grantCapability(t *testing.T, suite *testutil.IntegrationTestSuite, user *testutil.TestUser, capability string) {
+	t.Helper()
+	user.Capabilities = append(user.Capabilities, capability)
+	capabilities, _ := json.Marshal(user.Capabilities)
+	capabilitiesNull := sql.Null[types.JSON[json.RawMessage]]{}
+	capabilitiesNull.Scan(capabilities)
+	if err := suite.UserDAO.UpdateUser(context.Background(), user.UserID, &dbmodels.UserSetter{Capabilities: &capabilitiesNull}); err != nil {
+		t.Fatalf("Failed to grant %s: %v", capability, err)
+	}
+}
+
+// requestAuditStatuses returns the request statuses in the audit trail of a request, oldest first
+func requestAuditStatuses(t *testing.T, suite *testutil.IntegrationTestSuite, requestID string) []string {
+	t.Helper()
+	rows, err := suite.DB.QueryContext(context.Background(),
+		"SELECT request_status FROM correlation_audit WHERE request_id = $1 ORDER BY timestamp", requestID)
+	if err != nil {
+		t.Fatalf("Failed to query correlation audit: %v", err)
+	}
+	defer rows.Close()
+
+	var statuses []string
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			t.Fatalf("Failed to scan correlation audit: %v", err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
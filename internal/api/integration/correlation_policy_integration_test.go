@@ -0,0 +1,185 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestCorrelationPolicy_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	moderator := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("policy_moderator"), "TestPassword123!", []string{"user", "moderator"})
+	grantCapability(t, suite, moderator, "correlate_fingerprints")
+	investigator := createCorrelationAdmin(t, suite, "policy_investigator", "trust_safety")
+	grantCapability(t, suite, investigator, "correlate_fingerprints")
+	active := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("policy_active"), "TestPassword123!", []string{"user"})
+	stale := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("policy_stale"), "TestPassword123!", []string{"user"})
+	quiet := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("policy_quiet"), "TestPassword123!", []string{"user"})
+
+	suffix := time.Now().UnixNano()
+	moderated := suite.CreateTestSubforum(t, fmt.Sprintf("moderated-%d", suffix), "Moderated subforum", moderator.UserID, false)
+	other := suite.CreateTestSubforum(t, fmt.Sprintf("other-%d", suffix), "Subforum of other moderators", moderator.UserID, false)
+	if _, err := suite.DB.ExecContext(ctx,
+		"INSERT INTO subforum_moderators (subforum_id, user_id, pseudonym_id, role) VALUES ($1, $2, $3, 'moderator')",
+		moderated.SubforumID, moderator.UserID, moderator.PseudonymID); err != nil {
+		t.Fatalf("Failed to add moderator: %v", err)
+	}
+
+	suite.CreateTestPost(t, "Recent post", "Posted this week", moderated.SubforumID, active.UserID, active.PseudonymID)
+	suite.CreateTestPost(t, "Post elsewhere", "Posted in another subforum", other.SubforumID, active.UserID, active.PseudonymID)
+	old := suite.CreateTestPost(t, "Old post", "Posted long ago", moderated.SubforumID, stale.UserID, stale.PseudonymID)
+	if _, err := suite.DB.ExecContext(ctx, "UPDATE posts SET created_at = NOW() - INTERVAL '45 days' WHERE post_id = $1", old.PostID); err != nil {
+		t.Fatalf("Failed to age post: %v", err)
+	}
+
+	moderatorToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, moderator.Email, moderator.Password))
+	investigatorToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, investigator.Email, investigator.Password))
+
+	correlate := func(t *testing.T, token, pseudonymID string, subforumID int64) *http.Response {
+		t.Helper()
+		body := map[string]interface{}{
+			"requested_pseudonym":   pseudonymID,
+			"requested_fingerprint": "",
+			"justification":         "Investigation of ban evasion",
+			"subforum_id":           subforumID,
+			"incident_id":           "ban_evasion_123",
+		}
+		return suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/fingerprint", token, body)
+	}
+
+	t.Run("ModeratorsStayInTheirSubforums", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			pseudonymID string
+			subforumID  int64
+			allowed     bool
+		}{
+			{"ActiveInModeratedSubforum", active.PseudonymID, moderated.SubforumID, true},
+			{"SubforumNotModerated", active.PseudonymID, other.SubforumID, false},
+			{"ActivityOutsideTimeWindow", stale.PseudonymID, moderated.SubforumID, false},
+			{"NoActivity", quiet.PseudonymID, moderated.SubforumID, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := correlate(t, moderatorToken, tt.pseudonymID, tt.subforumID)
+				resp.Body.Close()
+				if tt.allowed && resp.StatusCode == http.StatusForbidden {
+					t.Errorf("Expected the correlation to be allowed, got %d", resp.StatusCode)
+				}
+				if !tt.allowed && resp.StatusCode != http.StatusForbidden {
+					t.Errorf("Expected status 403, got %d", resp.StatusCode)
+				}
+			})
+		}
+	})
+
+	t.Run("OldPseudonymWithRecentActivity", func(t *testing.T) {
+		// The time window bounds activity, not when the pseudonym and its
+		// identity mappings were created
+		veteran := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("policy_veteran"), "TestPassword123!", []string{"user"})
+		backdatePseudonym(t, suite, veteran, 400*24*time.Hour)
+		suite.CreateTestPost(t, "Recent post", "Posted today by an old pseudonym", moderated.SubforumID, veteran.UserID, veteran.PseudonymID)
+
+		resp := correlate(t, moderatorToken, veteran.PseudonymID, moderated.SubforumID)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			t.Fatalf("Expected status 200 correlating an old pseudonym with recent activity, got %d", resp.StatusCode)
+		}
+		var correlated models.FingerprintCorrelationResponseBody
+		suite.ParseResponse(t, resp, &correlated)
+		if correlated.TimeWindow != "30_days" {
+			t.Errorf("Expected the 30 day window of moderators, got %q", correlated.TimeWindow)
+		}
+		if !hasCorrelationResult(correlated.Results, veteran.PseudonymID) {
+			t.Errorf("Expected the results to contain the old pseudonym, got %+v", correlated.Results)
+		}
+	})
+
+	t.Run("PlatformWideRolesAreNotLimitedToSubforums", func(t *testing.T) {
+		resp := correlate(t, investigatorToken, quiet.PseudonymID, other.SubforumID)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusForbidden {
+			t.Errorf("Expected a platform-wide role to correlate any pseudonym, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("IdentityCorrelationIsPlatformWide", func(t *testing.T) {
+		body := map[string]interface{}{
+			"requested_pseudonym":   active.PseudonymID,
+			"requested_fingerprint": "",
+			"justification":         "Investigation of ban evasion",
+			"legal_basis":           "Platform Terms of Service",
+			"incident_id":           "ban_evasion_123",
+			"scope":                 "subforum_specific",
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", investigatorToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a subforum-specific identity correlation, got %d", resp.StatusCode)
+		}
+
+		// A capability alone does not make a moderator an identity correlator
+		grantCapability(t, suite, moderator, "correlate_identities")
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, moderator.Email, moderator.Password))
+		body["scope"] = "platform_wide"
+		resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/admin/correlation/identity", token, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a role without identity access, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// backdatePseudonym makes a user's pseudonym look created some time ago: its
+// identity mappings are encrypted again in the key epoch of that day
+func backdatePseudonym(t *testing.T, suite *testutil.IntegrationTestSuite, user *testutil.TestUser, age time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	createdAt := time.Now().Add(-age)
+	epoch := ibe.EpochAt(createdAt, ibe.EpochDuration)
+
+	mappings, err := suite.IdentityMappingDAO.GetIdentityMappingsByPseudonymID(ctx, user.PseudonymID)
+	if err != nil || len(mappings) == 0 {
+		t.Fatalf("Failed to get identity mappings: %v", err)
+	}
+	for _, mapping := range mappings {
+		roleKeys, err := suite.RoleKeyDAO.GetRoleKeyVersions(ctx, mapping.RoleName, mapping.KeyScope)
+		if err != nil {
+			t.Fatalf("Failed to get role keys of %s: %v", mapping.RoleName, err)
+		}
+		for _, roleKey := range roleKeys {
+			if roleKey.KeyVersion != mapping.KeyVersion {
+				continue
+			}
+			encrypted, err := suite.IBESystem.EncryptIdentityWithRoleKey(user.Email, user.PseudonymID, mapping.RoleName, int(mapping.KeyVersion), epoch, roleKey.KeyData)
+			if err != nil {
+				t.Fatalf("Failed to encrypt identity mapping: %v", err)
+			}
+			if _, err := suite.DB.ExecContext(ctx,
+				"UPDATE identity_mappings SET encrypted_real_identity = $1, created_at = $2 WHERE mapping_id = $3",
+				encrypted, createdAt, mapping.MappingID); err != nil {
+				t.Fatalf("Failed to backdate identity mapping: %v", err)
+			}
+		}
+	}
+	if _, err := suite.DB.ExecContext(ctx, "UPDATE pseudonyms SET created_at = $1 WHERE pseudonym_id = $2", createdAt, user.PseudonymID); err != nil {
+		t.Fatalf("Failed to backdate pseudonym: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+	"github.com/stephenafamo/bob/types"
+)
+
+func TestDisclosureNotices_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.DisclosureEmbargo = time.Hour
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	legal := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("disclosure_legal"), "TestPassword123!", []string{"user", "legal_team"})
+	grantCapability(t, suite, legal, "legal_compliance")
+	moderator := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("disclosure_moderator"), "TestPassword123!", []string{"user", "moderator"})
+
+	legalToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, legal.Email, legal.Password))
+	auditDAO := dao.NewCorrelationAuditDAO(suite.DB, suite.AuditChainDAO)
+
+	// correlate records a fingerprint correlation of a new user's pseudonym
+	// that also found another pseudonym. It returns the user, their token and
+	// the other pseudonym.
+	correlate := func(t *testing.T, prefix string) (*testutil.TestUser, string, string) {
+		t.Helper()
+		target := suite.CreateTestUser(t, testutil.GenerateUniqueEmail(prefix), "TestPassword123!", []string{"user"})
+		linked := fmt.Sprintf("linked_%d", time.Now().UnixNano())
+		results, _ := json.Marshal([]models.CorrelationResult{
+			{PseudonymID: target.PseudonymID, DisplayName: "target"},
+			{PseudonymID: linked, DisplayName: "linked"},
+		})
+		entry := &dao.CorrelationAuditEntry{
+			UserID:             moderator.UserID,
+			PseudonymID:        target.PseudonymID,
+			AdminUsername:      moderator.Email,
+			RoleUsed:           "moderator",
+			RequestedPseudonym: target.PseudonymID,
+			Justification:      "Investigation of ban evasion",
+			CorrelationType:    "fingerprint",
+		}
+		entry.CorrelationResult.Scan(results)
+		if err := auditDAO.RecordEntry(ctx, entry); err != nil {
+			t.Fatalf("Failed to record correlation: %v", err)
+		}
+
+		token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, target.Email, target.Password))
+		return target, token, linked
+	}
+
+	// ownNotices lists the notices of a pseudonym as its owner and returns
+	// them with the raw response
+	ownNotices := func(t *testing.T, token, pseudonymID string) ([]models.DisclosureNotice, string) {
+		t.Helper()
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/pseudonyms/"+pseudonymID+"/disclosure-notices", token, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing disclosure notices, got %d", resp.StatusCode)
+		}
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		var body models.DisclosureNoticeListResponseBody
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body.Notices, string(raw)
+	}
+
+	// adminNotice returns the only notice of a pseudonym as admins see it
+	adminNotice := func(t *testing.T, pseudonymID string) models.AdminDisclosureNotice {
+		t.Helper()
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/disclosure-notices?pseudonym_id="+pseudonymID, legalToken, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 listing disclosure notices as admin, got %d", resp.StatusCode)
+		}
+		var body models.AdminDisclosureNoticeListResponseBody
+		suite.ParseResponse(t, resp, &body)
+		if len(body.Notices) != 1 {
+			t.Fatalf("Expected one notice for the pseudonym, got %d", len(body.Notices))
+		}
+		return body.Notices[0]
+	}
+
+	changeEmbargo := func(t *testing.T, noticeID, action string, body map[string]interface{}) *http.Response {
+		t.Helper()
+		path := fmt.Sprintf("/admin/disclosure-notices/%s/%s", noticeID, action)
+		return suite.MakeAuthenticatedRequest(t, server, "POST", path, legalToken, body)
+	}
+
+	t.Run("NoticeAfterEmbargo", func(t *testing.T) {
+		target, targetToken, linked := correlate(t, "disclosure_target")
+
+		if notices, _ := ownNotices(t, targetToken, target.PseudonymID); len(notices) != 0 {
+			t.Fatalf("Expected no notices during the embargo, got %d", len(notices))
+		}
+		notice := adminNotice(t, target.PseudonymID)
+		if notice.Status != "embargoed" {
+			t.Fatalf("Expected notice to be embargoed, got %s", notice.Status)
+		}
+
+		// Only the legal team manages embargoes
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/admin/disclosure-notices", targetToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403 listing notices without legal_compliance, got %d", resp.StatusCode)
+		}
+
+		// An extension must move the embargo later
+		resp = changeEmbargo(t, notice.NoticeID, "extend", map[string]interface{}{
+			"embargo_until": time.Now().Format(time.RFC3339),
+			"justification": "Disclosure would compromise the investigation",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422 moving the embargo earlier, got %d", resp.StatusCode)
+		}
+
+		resp = changeEmbargo(t, notice.NoticeID, "extend", map[string]interface{}{
+			"embargo_until": time.Now().Add(48 * time.Hour).Format(time.RFC3339),
+			"justification": "Disclosure would compromise the investigation",
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 extending the embargo, got %d", resp.StatusCode)
+		}
+		var extended models.AdminDisclosureNotice
+		suite.ParseResponse(t, resp, &extended)
+		if extended.Status != "embargoed" {
+			t.Errorf("Expected extended notice to be embargoed, got %s", extended.Status)
+		}
+
+		resp = changeEmbargo(t, notice.NoticeID, "lift", map[string]interface{}{"justification": "Investigation closed"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 lifting the embargo, got %d", resp.StatusCode)
+		}
+		var lifted models.AdminDisclosureNotice
+		suite.ParseResponse(t, resp, &lifted)
+		if lifted.Status != "available" {
+			t.Errorf("Expected lifted notice to be available, got %s", lifted.Status)
+		}
+
+		notices, raw := ownNotices(t, targetToken, target.PseudonymID)
+		if len(notices) != 1 {
+			t.Fatalf("Expected one notice after the embargo was lifted, got %d", len(notices))
+		}
+		if notices[0].RoleType != "moderator" || notices[0].LegalBasisCategory != "moderation" {
+			t.Errorf("Unexpected notice: %+v", notices[0])
+		}
+		if _, err := time.Parse(time.DateOnly, notices[0].CorrelatedOn); err != nil {
+			t.Errorf("Expected notice to carry the date of the correlation, got %q", notices[0].CorrelatedOn)
+		}
+		if strings.Contains(raw, linked) {
+			t.Error("Notice must not reveal the other pseudonyms found by the correlation")
+		}
+
+		// A delivered notice keeps its embargo
+		resp = changeEmbargo(t, notice.NoticeID, "extend", map[string]interface{}{
+			"embargo_until": time.Now().Add(96 * time.Hour).Format(time.RFC3339),
+			"justification": "Disclosure would compromise the investigation",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 extending a delivered notice, got %d", resp.StatusCode)
+		}
+
+		// Every change is in the correlation audit trail
+		if actions := embargoAuditActions(t, suite, notice.NoticeID); strings.Join(actions, ",") != "extend,lift" {
+			t.Errorf("Expected audited embargo changes [extend lift], got %v", actions)
+		}
+	})
+
+	t.Run("GagOrderWithholdsNotice", func(t *testing.T) {
+		target, targetToken, _ := correlate(t, "disclosure_gagged")
+		notice := adminNotice(t, target.PseudonymID)
+
+		var reportID string
+		err := suite.DB.QueryRowContext(ctx, `INSERT INTO compliance_reports (report_type, request_date, scope_description, gag_order)
+			VALUES ('court_order', CURRENT_DATE, 'Disclosure notice test', TRUE) RETURNING report_id`).Scan(&reportID)
+		if err != nil {
+			t.Fatalf("Failed to create compliance report: %v", err)
+		}
+		_, err = suite.DB.ExecContext(ctx, `INSERT INTO compliance_correlations (report_id, audit_id, correlation_scope) VALUES ($1, $2, 'test')`,
+			reportID, notice.AuditID)
+		if err != nil {
+			t.Fatalf("Failed to link compliance report: %v", err)
+		}
+		defer func() {
+			suite.DB.ExecContext(ctx, "DELETE FROM compliance_correlations WHERE report_id = $1", reportID)
+			suite.DB.ExecContext(ctx, "DELETE FROM compliance_reports WHERE report_id = $1", reportID)
+		}()
+
+		resp := changeEmbargo(t, notice.NoticeID, "lift", map[string]interface{}{"justification": "Investigation closed"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 lifting the embargo, got %d", resp.StatusCode)
+		}
+		var lifted models.AdminDisclosureNotice
+		suite.ParseResponse(t, resp, &lifted)
+		if lifted.Status != "gagged" || lifted.LegalBasisCategory != "court_order" {
+			t.Errorf("Expected gagged court order notice, got %+v", lifted)
+		}
+		if notices, _ := ownNotices(t, targetToken, target.PseudonymID); len(notices) != 0 {
+			t.Fatalf("Expected the gag order to withhold the notice, got %d", len(notices))
+		}
+
+		// The notice is delivered once the gag order ends
+		if _, err := suite.DB.ExecContext(ctx, "UPDATE compliance_reports SET gag_order = FALSE WHERE report_id = $1", reportID); err != nil {
+			t.Fatalf("Failed to end gag order: %v", err)
+		}
+		notices, _ := ownNotices(t, targetToken, target.PseudonymID)
+		if len(notices) != 1 || notices[0].LegalBasisCategory != "court_order" {
+			t.Errorf("Expected one court order notice after the gag order ended, got %+v", notices)
+		}
+	})
+
+	t.Run("OnlyOwnerSeesNotices", func(t *testing.T) {
+		target, _, _ := correlate(t, "disclosure_owner")
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/pseudonyms/"+target.PseudonymID+"/disclosure-notices", legalToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 listing another user's notices, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// embargoAuditActions returns the audited embargo changes of a notice, oldest first
+func embargoAuditActions(t *testing.T, suite *testutil.IntegrationTestSuite, noticeID string) []string {
+	t.Helper()
+	rows, err := suite.DB.QueryContext(context.Background(),
+		`SELECT correlation_result FROM correlation_audit
+		WHERE correlation_type = 'disclosure_embargo' AND correlation_result->>'notice_id' = $1 ORDER BY chain_seq`, noticeID)
+	if err != nil {
+		t.Fatalf("Failed to query correlation audit: %v", err)
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var result sql.Null[types.JSON[json.RawMessage]]
+		if err := rows.Scan(&result); err != nil {
+			t.Fatalf("Failed to scan correlation audit: %v", err)
+		}
+		var change map[string]string
+		if err := json.Unmarshal(result.V.Val, &change); err != nil {
+			t.Fatalf("Failed to decode embargo change: %v", err)
+		}
+		actions = append(actions, change["action"])
+	}
+	return actions
+}
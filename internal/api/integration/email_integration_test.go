@@ -0,0 +1,174 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+var mailedTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// mailedToken returns the token from the link in the last email sent to an address
+func mailedToken(t *testing.T, suite *testutil.IntegrationTestSuite, email string) string {
+	msg, ok := suite.Mailer.LastMessageTo(email)
+	if !ok {
+		t.Fatalf("Expected an email to %s", email)
+	}
+	match := mailedTokenPattern.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("Expected a link with a token in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("Failed to unescape token: %v", err)
+	}
+	return token
+}
+
+func TestEmailVerification_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	owner := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("email_owner"), "TestPassword123!", []string{"user"})
+	subforum := suite.CreateTestSubforum(t, "email-verification", "Email verification tests", owner.UserID, false)
+
+	email := testutil.GenerateUniqueEmail("email_verify")
+	password := "TestPassword123!"
+	resp := suite.MakeRequest(t, server, "POST", "/auth/register", models.UserRegistrationBody{
+		Email:       email,
+		Password:    password,
+		DisplayName: "UnverifiedUser",
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for registration, got %d", resp.StatusCode)
+	}
+	var registration models.UserRegistrationResponseBody
+	suite.ParseResponse(t, resp, &registration)
+	if registration.EmailVerified {
+		t.Error("Expected new account to be unverified")
+	}
+
+	token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, email, password))
+	postData := models.PostCreateBody{Title: "Hello", Content: "First post", PostType: "text"}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/subforums/"+subforum.Name+"/posts", token, postData)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for posting before verification, got %d", resp.StatusCode)
+	}
+
+	verificationToken := mailedToken(t, suite, email)
+	resp = suite.MakeRequest(t, server, "POST", "/auth/email/verify", models.EmailVerificationBody{Token: verificationToken})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for verification, got %d", resp.StatusCode)
+	}
+	var verification models.EmailVerificationResponseBody
+	suite.ParseResponse(t, resp, &verification)
+	if !verification.EmailVerified || verification.Email != email {
+		t.Errorf("Unexpected verification response: %+v", verification)
+	}
+
+	// The link works once
+	resp = suite.MakeRequest(t, server, "POST", "/auth/email/verify", models.EmailVerificationBody{Token: verificationToken})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for reused verification link, got %d", resp.StatusCode)
+	}
+
+	// The access token issued before verification now passes the check
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/subforums/"+subforum.Name+"/posts", token, postData)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for posting after verification, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/email/verification", token, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 when resending to a verified address, got %d", resp.StatusCode)
+	}
+}
+
+func TestPasswordReset_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("password_reset"), "TestPassword123!", []string{"user"})
+	oldToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password))
+
+	// Unknown and known addresses get the same answer
+	unknown := suite.MakeRequest(t, server, "POST", "/auth/password/forgot", models.PasswordResetRequestBody{Email: "nobody@example.com"})
+	var unknownBody models.EmailAcceptedResponseBody
+	suite.ParseResponse(t, unknown, &unknownBody)
+	known := suite.MakeRequest(t, server, "POST", "/auth/password/forgot", models.PasswordResetRequestBody{Email: testUser.Email})
+	var knownBody models.EmailAcceptedResponseBody
+	suite.ParseResponse(t, known, &knownBody)
+	if unknown.StatusCode != http.StatusAccepted || known.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status 202 for both requests, got %d and %d", unknown.StatusCode, known.StatusCode)
+	}
+	if unknownBody.Message != knownBody.Message {
+		t.Errorf("Expected identical responses, got %q and %q", unknownBody.Message, knownBody.Message)
+	}
+	if _, ok := suite.Mailer.LastMessageTo("nobody@example.com"); ok {
+		t.Error("Expected no email for an unknown address")
+	}
+
+	resetToken := mailedToken(t, suite, testUser.Email)
+
+	resp := suite.MakeRequest(t, server, "POST", "/auth/password/reset", models.PasswordResetBody{Token: resetToken, NewPassword: "short"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a weak password, got %d", resp.StatusCode)
+	}
+
+	newPassword := "NewTestPassword456!"
+	resp = suite.MakeRequest(t, server, "POST", "/auth/password/reset", models.PasswordResetBody{Token: resetToken, NewPassword: newPassword})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for password reset, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeRequest(t, server, "POST", "/auth/password/reset", models.PasswordResetBody{Token: resetToken, NewPassword: "AnotherPassword789!"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for reused reset link, got %d", resp.StatusCode)
+	}
+
+	// Sessions from before the reset are revoked
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/users/profile", oldToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a token issued before the reset, got %d", resp.StatusCode)
+	}
+
+	// Revocation has second granularity; log in again in a later second
+	time.Sleep(time.Second)
+
+	resp = suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("Expected the old password to be rejected")
+	}
+	resp = suite.LoginUser(t, server, testUser.Email, newPassword)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for the new password, got %d", resp.StatusCode)
+	}
+}
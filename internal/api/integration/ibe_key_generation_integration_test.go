@@ -351,18 +351,18 @@ func TestIBEKeyGeneration_IntegrationWithDatabase(t *testing.T) {
 		// Debug: print encrypted mapping
 		t.Logf("Encrypted mapping: %s", encryptedMapping)
 
-		decryptedRealIdentity, decryptedPseudonym, err := ibeSystem.DecryptIdentity(encryptedMapping, adminKey)
+		decrypted, err := ibeSystem.DecryptIdentity(encryptedMapping, pseudonymID, ibeSystem.GetKeyVersion(), adminKey)
 		require.NoError(t, err, "Should decrypt identity mapping")
 		// Debug: print decrypted values
-		t.Logf("Decrypted real identity: %s", decryptedRealIdentity)
-		t.Logf("Decrypted pseudonym: %s", decryptedPseudonym)
+		t.Logf("Decrypted mapping: %+v", decrypted)
 
 		// Expect the fingerprint, not the email
 		expectedFingerprint := ibeSystem.GenerateFingerprint(realIdentity)
-		expectedMapping := expectedFingerprint + ":" + pseudonymID
-		assert.Equal(t, expectedMapping, decryptedRealIdentity, "Decrypted real identity should be the fingerprint mapping")
-		// The decrypted pseudonym is not used in this implementation
-		// Optionally, assert that it's empty
-		assert.Empty(t, decryptedPseudonym, "Decrypted pseudonym should be empty (not used)")
+		assert.Equal(t, expectedFingerprint, decrypted.Fingerprint, "Decrypted mapping should hold the fingerprint")
+		assert.Equal(t, pseudonymID, decrypted.PseudonymID, "Decrypted mapping should belong to the pseudonym")
+
+		// The mapping is bound to its pseudonym
+		_, err = ibeSystem.DecryptIdentity(encryptedMapping, "other_pseudonym", ibeSystem.GetKeyVersion(), adminKey)
+		assert.Error(t, err, "Mapping should not decrypt for another pseudonym")
 	})
 }
@@ -0,0 +1,52 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestJWKS_VerifiesIssuedTokens_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	resp := suite.MakeRequest(t, server, "GET", "/.well-known/jwks.json", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for JWKS, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Cache-Control") == "" {
+		t.Error("Expected JWKS response to be cacheable")
+	}
+	var jwks jwtkeys.JWKS
+	suite.ParseResponse(t, resp, &jwks)
+	if len(jwks.Keys) == 0 {
+		t.Fatal("Expected at least one published key")
+	}
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("jwks"), "TestPassword123!", []string{"user"})
+	token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password))
+
+	// Verify the access token the way an edge service would, using only the JWKS
+	_, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, jwk := range jwks.Keys {
+			if jwk.KeyID == kid {
+				return jwk.PublicKey()
+			}
+		}
+		return nil, jwtkeys.ErrUnknownKey
+	}, jwt.WithValidMethods([]string{jwtkeys.AlgEdDSA, jwtkeys.AlgES256}))
+	if err != nil {
+		t.Errorf("Expected access token to verify against the JWKS: %v", err)
+	}
+}
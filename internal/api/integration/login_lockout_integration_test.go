@@ -0,0 +1,89 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/cmd/server/commands"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestLoginLockout_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.AccountLockout = config.LoginLockoutPolicy{
+		FreeFailures:    3,
+		MaxFailures:     3,
+		LockoutDuration: time.Hour,
+		FailureWindow:   time.Hour,
+	}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("lockout"), "TestPassword123!", []string{"user"})
+
+	for i := 0; i < 3; i++ {
+		resp := suite.LoginUser(t, server, testUser.Email, "WrongPassword123!")
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("Expected failure %d to be rejected as invalid credentials, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	// The correct password no longer works while the account is locked
+	resp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 for a locked account, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	// Unknown addresses are throttled the same way, so lockouts do not reveal accounts
+	unknown := testutil.GenerateUniqueEmail("lockout_unknown")
+	for i := 0; i < 3; i++ {
+		resp := suite.LoginUser(t, server, unknown, "WrongPassword123!")
+		resp.Body.Close()
+	}
+	resp = suite.LoginUser(t, server, unknown, "WrongPassword123!")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 for a throttled unknown address, got %d", resp.StatusCode)
+	}
+
+	ctx := context.Background()
+	var events int
+	err := suite.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM system_events WHERE event_type = 'login_lockout' AND (event_data->>'user_id')::bigint = $1",
+		testUser.UserID).Scan(&events)
+	if err != nil {
+		t.Fatalf("Failed to query system events: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("Expected 1 lockout event for the account, got %d", events)
+	}
+
+	unlock, err := commands.UnlockLogin(ctx, suite.DB, suite.Hasher, &commands.UnlockLoginOptions{Email: testUser.Email, Reason: "integration test"})
+	if err != nil {
+		t.Fatalf("Failed to unlock login: %v", err)
+	}
+	if !unlock.AccountWasLocked {
+		t.Error("Expected the account to have been locked")
+	}
+
+	resp = suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 after unlocking, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,221 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestMFA_EnrollmentAndLogin_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("mfa_enroll"), "TestPassword123!", []string{"user"})
+	loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	token := suite.ExtractTokenFromResponse(t, loginResp)
+
+	// Start enrollment
+	resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll", token, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for enrollment, got %d", resp.StatusCode)
+	}
+	var enrollment models.MFAEnrollmentResponseBody
+	suite.ParseResponse(t, resp, &enrollment)
+	if enrollment.Secret == "" || enrollment.OTPAuthURI == "" {
+		t.Fatal("Expected secret and otpauth URI in enrollment response")
+	}
+
+	// The secret is stored encrypted
+	var stored string
+	if err := suite.DB.QueryRowContext(context.Background(),
+		"SELECT mfa_secret FROM users WHERE user_id = $1", testUser.UserID).Scan(&stored); err != nil {
+		t.Fatalf("Failed to query MFA secret: %v", err)
+	}
+	if !mfa.IsSealed(stored) || strings.Contains(stored, enrollment.Secret) {
+		t.Errorf("Expected the TOTP secret to be stored encrypted, got %q", stored)
+	}
+
+	// A wrong code does not enable MFA
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll/confirm", token, models.MFAConfirmBody{Code: "000000"})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for wrong confirmation code, got %d", resp.StatusCode)
+	}
+
+	// Confirm with the current code
+	now := time.Now()
+	code, err := mfa.GenerateCode(enrollment.Secret, now)
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll/confirm", token, models.MFAConfirmBody{Code: code})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for confirmation, got %d", resp.StatusCode)
+	}
+	var confirmed models.MFARecoveryCodesResponseBody
+	suite.ParseResponse(t, resp, &confirmed)
+	if len(confirmed.RecoveryCodes) != mfa.RecoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", mfa.RecoveryCodeCount, len(confirmed.RecoveryCodes))
+	}
+
+	// Password login now returns an MFA challenge instead of tokens
+	resp = suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for password step, got %d", resp.StatusCode)
+	}
+	var challenge models.UserLoginResponseBody
+	suite.ParseResponse(t, resp, &challenge)
+	if !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Fatal("Expected MFA challenge in login response")
+	}
+	if challenge.AccessToken != "" {
+		t.Error("Expected no access token before the MFA step")
+	}
+
+	// Replaying the enrollment code is rejected
+	resp = suite.MakeRequest(t, server, "POST", "/auth/login/mfa", models.MFALoginBody{MFAToken: challenge.MFAToken, MFACodeBody: models.MFACodeBody{Code: code}})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for replayed code, got %d", resp.StatusCode)
+	}
+
+	// A recovery code completes login, but only once
+	recoveryCode := confirmed.RecoveryCodes[0]
+	resp = suite.MakeRequest(t, server, "POST", "/auth/login/mfa", models.MFALoginBody{MFAToken: challenge.MFAToken, MFACodeBody: models.MFACodeBody{RecoveryCode: recoveryCode}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for MFA login with recovery code, got %d", resp.StatusCode)
+	}
+	mfaToken := suite.ExtractTokenFromResponse(t, resp)
+	if mfaToken == "" {
+		t.Fatal("Expected access token after MFA step")
+	}
+
+	resp = suite.MakeRequest(t, server, "POST", "/auth/login/mfa", models.MFALoginBody{MFAToken: challenge.MFAToken, MFACodeBody: models.MFACodeBody{RecoveryCode: recoveryCode}})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for reused recovery code, got %d", resp.StatusCode)
+	}
+
+	// Step-up with the next time step's code
+	nextCode, err := mfa.GenerateCode(enrollment.Secret, now.Add(mfa.Period))
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/step-up", mfaToken, models.MFACodeBody{Code: nextCode})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for step-up, got %d", resp.StatusCode)
+	}
+	if stepUpToken := suite.ExtractTokenFromResponse(t, resp); stepUpToken == "" {
+		t.Error("Expected access token from step-up")
+	}
+}
+
+func TestMFA_ChallengeTokenIsNotAnAccessToken_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("mfa_challenge"), "TestPassword123!", []string{"user"})
+	loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	token := suite.ExtractTokenFromResponse(t, loginResp)
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll", token, nil)
+	var enrollment models.MFAEnrollmentResponseBody
+	suite.ParseResponse(t, resp, &enrollment)
+	code, err := mfa.GenerateCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll/confirm", token, models.MFAConfirmBody{Code: code})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for confirmation, got %d", resp.StatusCode)
+	}
+
+	resp = suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	var challenge models.UserLoginResponseBody
+	suite.ParseResponse(t, resp, &challenge)
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", challenge.MFAToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 when using a challenge token for /auth/me, got %d", resp.StatusCode)
+	}
+}
+
+func TestMFA_StepUpLockout_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.Security.AccountLockout = config.LoginLockoutPolicy{
+		FreeFailures:    3,
+		MaxFailures:     3,
+		LockoutDuration: time.Hour,
+		FailureWindow:   time.Hour,
+	}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("mfa_step_up_lockout"), "TestPassword123!", []string{"user"})
+	token := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password))
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll", token, nil)
+	var enrollment models.MFAEnrollmentResponseBody
+	suite.ParseResponse(t, resp, &enrollment)
+	now := time.Now()
+	code, err := mfa.GenerateCode(enrollment.Secret, now)
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/enroll/confirm", token, models.MFAConfirmBody{Code: code})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for confirmation, got %d", resp.StatusCode)
+	}
+
+	// Wrong step-up codes count towards the account lockout
+	for i := 0; i < 3; i++ {
+		resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/step-up", token, models.MFACodeBody{Code: "000000"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Expected wrong code %d to be rejected with status 401, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	// The correct code no longer works while the account is locked
+	nextCode, err := mfa.GenerateCode(enrollment.Secret, now.Add(mfa.Period))
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/step-up", token, models.MFACodeBody{Code: nextCode})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 for step-up on a locked account, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	// Disabling MFA is refused the same way
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/mfa/disable", token, models.MFACodeBody{Code: nextCode})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 disabling MFA on a locked account, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestRateLimit_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	suite.Config.RateLimit.Enabled = true
+	suite.Config.RateLimit.Backend = "postgres"
+	suite.Config.RateLimit.KeySecret = "integration-test-secret"
+	suite.Config.RateLimit.Read = config.RateLimitBudgets{
+		Anonymous: config.RateLimit{Requests: 2, Period: time.Hour},
+		User:      config.RateLimit{Requests: 3, Period: time.Hour},
+		APIKey:    config.RateLimit{Requests: 3, Period: time.Hour},
+	}
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	t.Run("AnonymousClientsAreLimitedByAddress", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp := suite.MakeRequest(t, server, "GET", "/hello", nil)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusTooManyRequests {
+				t.Fatalf("Expected request %d to be allowed", i+1)
+			}
+			if remaining := resp.Header.Get("RateLimit-Remaining"); remaining != strconv.Itoa(1-i) {
+				t.Errorf("Expected RateLimit-Remaining %d, got %q", 1-i, remaining)
+			}
+			if resp.Header.Get("RateLimit-Limit") != "2" || resp.Header.Get("RateLimit-Policy") != "2;w=3600" {
+				t.Errorf("Unexpected rate limit headers: %v", resp.Header)
+			}
+		}
+
+		resp := suite.MakeRequest(t, server, "GET", "/hello", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429, got %d", resp.StatusCode)
+		}
+		if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retryAfter < 1 {
+			t.Errorf("Expected a Retry-After in seconds, got %q", resp.Header.Get("Retry-After"))
+		}
+
+		// Health checks are never limited
+		resp = suite.MakeRequest(t, server, "GET", "/health", nil)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Error("Expected health checks to be exempt")
+		}
+	})
+
+	t.Run("UsersAndAPIKeysHaveTheirOwnBudgets", func(t *testing.T) {
+		testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("ratelimit"), "TestPassword123!", []string{"user"})
+		loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+		accessToken := suite.ExtractTokenFromResponse(t, loginResp)
+		loginResp.Body.Close()
+
+		// The address is exhausted, but signed-in users are counted on their own
+		for i := 0; i < 3; i++ {
+			resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/hello", accessToken, nil)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusTooManyRequests {
+				t.Fatalf("Expected request %d to be allowed", i+1)
+			}
+		}
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/hello", accessToken, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429 once the user's budget is used, got %d", resp.StatusCode)
+		}
+
+		// API keys are counted per owning user, apart from the user's sessions
+		suite.CreateTestAPIKey(t, testUser.UserID, testUser.PseudonymID, map[string]interface{}{})
+		apiKey := "test_api_key_" + strconv.FormatInt(testUser.UserID, 10) + "_" + testUser.PseudonymID
+		resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/hello", apiKey, nil)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Error("Expected an API key request to be allowed")
+		}
+	})
+
+	t.Run("APIKeysOfAUserShareABudget", func(t *testing.T) {
+		testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("ratelimit_keys"), "TestPassword123!", []string{"user"})
+		second := suite.CreateTestPseudonym(t, testUser.UserID, "ratelimit_second")
+		apiKey := func(pseudonymID string) string {
+			suite.CreateTestAPIKey(t, testUser.UserID, pseudonymID, map[string]interface{}{})
+			return "test_api_key_" + strconv.FormatInt(testUser.UserID, 10) + "_" + pseudonymID
+		}
+		firstKey := apiKey(testUser.PseudonymID)
+		secondKey := apiKey(second.PseudonymID)
+
+		for i := 0; i < 3; i++ {
+			resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/hello", firstKey, nil)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusTooManyRequests {
+				t.Fatalf("Expected request %d to be allowed", i+1)
+			}
+		}
+
+		// A key on another pseudonym of the same user draws on the same budget
+		resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/hello", secondKey, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429 for a key on another pseudonym of the user, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func loginForRefreshToken(t *testing.T, suite *testutil.IntegrationTestSuite, server *httptest.Server, email, password string) models.UserLoginResponseBody {
+	resp := suite.LoginUser(t, server, email, password)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for login, got %d", resp.StatusCode)
+	}
+	var login models.UserLoginResponseBody
+	suite.ParseResponse(t, resp, &login)
+	if login.RefreshToken == "" {
+		t.Fatal("Expected refresh token in login response")
+	}
+	return login
+}
+
+func refreshWith(t *testing.T, suite *testutil.IntegrationTestSuite, server *httptest.Server, refreshToken string) (*http.Response, models.TokenRefreshResponseBody) {
+	resp := suite.MakeRequest(t, server, "POST", "/auth/refresh", models.RefreshTokenBody{RefreshToken: refreshToken})
+	var body models.TokenRefreshResponseBody
+	if resp.StatusCode == http.StatusOK {
+		suite.ParseResponse(t, resp, &body)
+	}
+	return resp, body
+}
+
+func TestRefreshToken_RotationAndReuse_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("refresh_rotation"), "TestPassword123!", []string{"user"})
+	login := loginForRefreshToken(t, suite, server, testUser.Email, testUser.Password)
+
+	// A refresh token cannot be used as an access token
+	resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", login.RefreshToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 when using a refresh token for /auth/me, got %d", resp.StatusCode)
+	}
+
+	// First refresh rotates the token
+	resp, rotated := refreshWith(t, suite, server, login.RefreshToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for refresh, got %d", resp.StatusCode)
+	}
+	if rotated.AccessToken == "" || rotated.RefreshToken == "" {
+		t.Fatal("Expected new access and refresh tokens")
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Fatal("Expected refresh token to be rotated")
+	}
+
+	// Replaying the original token is rejected and revokes the family
+	resp, _ = refreshWith(t, suite, server, login.RefreshToken)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for reused refresh token, got %d", resp.StatusCode)
+	}
+
+	// The rotated token was in the same family, so it no longer works either
+	resp, _ = refreshWith(t, suite, server, rotated.RefreshToken)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for token from a revoked family, got %d", resp.StatusCode)
+	}
+}
+
+func TestRefreshToken_Logout_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("refresh_logout"), "TestPassword123!", []string{"user"})
+	first := loginForRefreshToken(t, suite, server, testUser.Email, testUser.Password)
+	second := loginForRefreshToken(t, suite, server, testUser.Email, testUser.Password)
+
+	// Logout revokes only the current session's family
+	resp := suite.MakeRequest(t, server, "POST", "/auth/logout", models.UserLogoutBody{RefreshToken: first.RefreshToken})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for logout, got %d", resp.StatusCode)
+	}
+	resp, _ = refreshWith(t, suite, server, first.RefreshToken)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for logged out refresh token, got %d", resp.StatusCode)
+	}
+	resp, rotated := refreshWith(t, suite, server, second.RefreshToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected other session to keep working, got %d", resp.StatusCode)
+	}
+
+	// Logging out everywhere revokes the remaining sessions
+	third := loginForRefreshToken(t, suite, server, testUser.Email, testUser.Password)
+	resp = suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/logout/all", third.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for logout from all sessions, got %d", resp.StatusCode)
+	}
+	for _, token := range []string{rotated.RefreshToken, third.RefreshToken} {
+		resp, _ = refreshWith(t, suite, server, token)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 after logging out everywhere, got %d", resp.StatusCode)
+		}
+	}
+}
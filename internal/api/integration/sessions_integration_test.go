@@ -0,0 +1,162 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestSessions_ListAndRevoke_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("sessions_list"), "TestPassword123!", []string{"user"})
+
+	var first, second models.UserLoginResponseBody
+	suite.ParseResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password), &first)
+	suite.ParseResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password), &second)
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/sessions", first.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 listing sessions, got %d", resp.StatusCode)
+	}
+	var list models.SessionListResponseBody
+	suite.ParseResponse(t, resp, &list)
+
+	if len(list.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(list.Sessions))
+	}
+	var otherSessionID string
+	currentCount := 0
+	for _, session := range list.Sessions {
+		if session.Current {
+			currentCount++
+		} else {
+			otherSessionID = session.SessionID
+		}
+		if session.UserAgentFamily != "Go" {
+			t.Errorf("Expected session to record the browser family of the Go client, got %q", session.UserAgentFamily)
+		}
+	}
+	if currentCount != 1 {
+		t.Errorf("Expected exactly one current session, got %d", currentCount)
+	}
+
+	// Revoking the second login's session invalidates its access token
+	resp = suite.MakeAuthenticatedRequest(t, server, "DELETE", "/auth/sessions/"+otherSessionID, first.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 revoking session, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", second.AccessToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for revoked session's access token, got %d", resp.StatusCode)
+	}
+	resp = suite.MakeRequest(t, server, "POST", "/auth/refresh", models.RefreshTokenBody{RefreshToken: second.RefreshToken})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for revoked session's refresh token, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", first.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for the remaining session, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "DELETE", "/auth/sessions/"+otherSessionID, first.AccessToken, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking an already revoked session, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessions_RevokeOthers_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("sessions_others"), "TestPassword123!", []string{"user"})
+
+	var current, other models.UserLoginResponseBody
+	suite.ParseResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password), &other)
+	suite.ParseResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password), &current)
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "DELETE", "/auth/sessions", current.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 revoking other sessions, got %d", resp.StatusCode)
+	}
+	var revoke models.SessionRevokeResponseBody
+	suite.ParseResponse(t, resp, &revoke)
+	if revoke.RevokedSessions != 1 {
+		t.Errorf("Expected 1 revoked session, got %d", revoke.RevokedSessions)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", other.AccessToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for other session, got %d", resp.StatusCode)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", current.AccessToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for current session, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessions_AdminKill_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	admin := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("sessions_admin"), "AdminPassword123!", []string{"platform_admin"})
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("sessions_target"), "TestPassword123!", []string{"user"})
+
+	adminToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, admin.Email, admin.Password))
+	userToken := suite.ExtractTokenFromResponse(t, suite.LoginUser(t, server, testUser.Email, testUser.Password))
+
+	// Regular users cannot manage other users' sessions
+	path := fmt.Sprintf("/admin/users/%d/sessions", admin.UserID)
+	resp := suite.MakeAuthenticatedRequest(t, server, "GET", path, userToken, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", resp.StatusCode)
+	}
+
+	path = fmt.Sprintf("/admin/users/%d/sessions", testUser.UserID)
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", path, adminToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 listing user sessions, got %d", resp.StatusCode)
+	}
+	var list models.SessionListResponseBody
+	suite.ParseResponse(t, resp, &list)
+	if list.UserID != testUser.UserID || len(list.Sessions) != 1 {
+		t.Fatalf("Expected 1 session for user %d, got %d for user %d", testUser.UserID, len(list.Sessions), list.UserID)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "DELETE", path, adminToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 killing user sessions, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", userToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after admin revoked sessions, got %d", resp.StatusCode)
+	}
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", adminToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected admin session to be unaffected, got %d", resp.StatusCode)
+	}
+}
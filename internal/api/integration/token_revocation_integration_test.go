@@ -0,0 +1,88 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/api/models"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+)
+
+func TestTokenRevocation_SuspendUser_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("revoke_suspend"), "TestPassword123!", []string{"user"})
+	loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	token := suite.ExtractTokenFromResponse(t, loginResp)
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", token, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 before suspension, got %d", resp.StatusCode)
+	}
+
+	if err := suite.UserDAO.SuspendUser(context.Background(), testUser.UserID, "integration test", nil); err != nil {
+		t.Fatalf("Failed to suspend user: %v", err)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", token, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after suspension, got %d", resp.StatusCode)
+	}
+}
+
+func TestTokenRevocation_RemoveRole_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("revoke_role"), "TestPassword123!", []string{"user", "moderator"})
+	loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	token := suite.ExtractTokenFromResponse(t, loginResp)
+
+	if err := suite.UserDAO.RemoveRole(context.Background(), testUser.UserID, "moderator"); err != nil {
+		t.Fatalf("Failed to remove role: %v", err)
+	}
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", token, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after role removal, got %d", resp.StatusCode)
+	}
+}
+
+func TestTokenRevocation_Logout_Integration(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+	server := suite.CreateTestServer()
+	defer server.Close()
+
+	testUser := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("revoke_logout"), "TestPassword123!", []string{"user"})
+	loginResp := suite.LoginUser(t, server, testUser.Email, testUser.Password)
+	var login models.UserLoginResponseBody
+	suite.ParseResponse(t, loginResp, &login)
+
+	resp := suite.MakeAuthenticatedRequest(t, server, "POST", "/auth/logout", login.AccessToken, models.UserLogoutBody{RefreshToken: login.RefreshToken})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for logout, got %d", resp.StatusCode)
+	}
+
+	resp = suite.MakeAuthenticatedRequest(t, server, "GET", "/auth/me", login.AccessToken, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for access token after logout, got %d", resp.StatusCode)
+	}
+}
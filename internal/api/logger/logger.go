@@ -29,8 +29,8 @@ func InitWithLevel(level string) {
 		TimeFormat: time.RFC3339,
 	}
 
-	// Set the global logger
-	log.Logger = log.Output(consoleWriter)
+	// Set the global logger, redacting sensitive fields before they reach the output
+	log.Logger = log.Output(NewRedactingWriter(consoleWriter))
 }
 
 // parseLogLevel converts a string log level to zerolog level
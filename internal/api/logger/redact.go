@@ -0,0 +1,278 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// Redaction is how the value of a sensitive field is rewritten
+type Redaction int
+
+const (
+	// Mask replaces the value with RedactedValue
+	Mask Redaction = iota
+	// Hash replaces the value with a keyed hash, so that log lines about the
+	// same value can still be matched without revealing it
+	Hash
+)
+
+// RedactedValue replaces masked values
+const RedactedValue = "[REDACTED]"
+
+// hashedValuePrefix marks hashed values
+const hashedValuePrefix = "hmac:"
+
+// sensitiveFields maps lower-case field names to their redaction. Names
+// match log fields at any depth, whatever their case.
+var (
+	sensitiveFieldsMu sync.RWMutex
+	sensitiveFields   = map[string]Redaction{
+		// Real identities and what they are derived into
+		"email":                   Hash,
+		"input_email":             Hash,
+		"real_identity":           Hash,
+		"fingerprint":             Hash,
+		"requested_fingerprint":   Hash,
+		"encrypted_real_identity": Mask,
+		"decrypted_mapping":       Mask,
+
+		// IBE key material
+		"master_key":     Mask,
+		"master_secret":  Mask,
+		"domain_master":  Mask,
+		"ibe_master_key": Mask,
+		"salt":           Mask,
+		"salt_hex":       Mask,
+		"ibe_salt":       Mask,
+		"combined_hex":   Mask,
+		"key_data":       Mask,
+		"key_hash":       Mask,
+		"test_key_hash":  Mask,
+		"share":          Mask,
+		"passphrase":     Mask,
+
+		// Credentials
+		"password":      Mask,
+		"secret":        Mask,
+		"totp_secret":   Mask,
+		"recovery_code": Mask,
+		"token":         Mask,
+		"access_token":  Mask,
+		"accesstoken":   Mask,
+		"refresh_token": Mask,
+		"api_key":       Mask,
+		"authorization": Mask,
+		"auth_header":   Mask,
+		"cookie":        Mask,
+	}
+)
+
+// RegisterSensitiveField adds a field name to the registry, or changes the
+// redaction of one already in it
+func RegisterSensitiveField(name string, redaction Redaction) {
+	sensitiveFieldsMu.Lock()
+	defer sensitiveFieldsMu.Unlock()
+	sensitiveFields[strings.ToLower(name)] = redaction
+}
+
+// SensitiveField returns the redaction of a field name, and whether the
+// name is in the registry
+func SensitiveField(name string) (Redaction, bool) {
+	sensitiveFieldsMu.RLock()
+	defer sensitiveFieldsMu.RUnlock()
+	redaction, ok := sensitiveFields[strings.ToLower(name)]
+	return redaction, ok
+}
+
+// strict makes a sensitive field in a log event panic instead of being
+// redacted
+var strict atomic.Bool
+
+// SetStrict turns strict redaction on or off. Tests turn it on so that code
+// logging a sensitive field fails instead of relying on the redaction.
+func SetStrict(on bool) {
+	strict.Store(on)
+}
+
+// RedactingWriter rewrites the sensitive fields of JSON log events before
+// passing them on. Zerolog hooks can add fields to an event but cannot see
+// the ones already on it, so redaction is done by the writer every event
+// passes through.
+type RedactingWriter struct {
+	out     io.Writer
+	hashKey []byte
+}
+
+// NewRedactingWriter creates a writer that redacts events written to out.
+// Hashed values are keyed with a random key of this process, so they match
+// within one run of the server only.
+func NewRedactingWriter(out io.Writer) *RedactingWriter {
+	hashKey := make([]byte, 32)
+	if _, err := rand.Read(hashKey); err != nil {
+		panic(fmt.Sprintf("failed to generate log redaction key: %v", err))
+	}
+	return &RedactingWriter{out: out, hashKey: hashKey}
+}
+
+// Write redacts one JSON event. Anything that is not a JSON object is passed
+// on unchanged.
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+	if !w.mayBeSensitive(p) {
+		return w.out.Write(p)
+	}
+
+	redacted, err := w.redact(p)
+	if err != nil {
+		return w.out.Write(p)
+	}
+	if _, err := w.out.Write(redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel redacts one JSON event of a level
+func (w *RedactingWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	return w.Write(p)
+}
+
+// mayBeSensitive reports whether an event names a registered field, so that
+// events that do not are passed on without being decoded
+func (w *RedactingWriter) mayBeSensitive(p []byte) bool {
+	lower := bytes.ToLower(p)
+	sensitiveFieldsMu.RLock()
+	defer sensitiveFieldsMu.RUnlock()
+	for name := range sensitiveFields {
+		if bytes.Contains(lower, []byte(`"`+name+`"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact re-encodes an event with its sensitive fields rewritten, keeping
+// the order of the fields
+func (w *RedactingWriter) redact(p []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := w.redactValue(dec, &buf); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data after log event")
+	}
+	if bytes.HasSuffix(p, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// redactValue copies the next JSON value from dec to buf, rewriting the
+// sensitive fields of objects
+func (w *RedactingWriter) redactValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return writeJSON(buf, token)
+	}
+
+	switch delim {
+	case '{':
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyToken.(string)
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+
+			redaction, sensitive := SensitiveField(key)
+			if !sensitive {
+				if err := w.redactValue(dec, buf); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			if strict.Load() {
+				panic(fmt.Sprintf("sensitive field %q reached the log output", key))
+			}
+			if err := writeJSON(buf, w.redacted(redaction, value)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case '[':
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := w.redactValue(dec, buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	// Consume the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// redacted returns the replacement of a sensitive value
+func (w *RedactingWriter) redacted(redaction Redaction, value json.RawMessage) string {
+	if redaction != Hash {
+		return RedactedValue
+	}
+
+	// Hash strings by their content so the hash does not depend on escaping
+	data := []byte(value)
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		data = []byte(s)
+	}
+	mac := hmac.New(sha256.New, w.hashKey)
+	mac.Write(data)
+	return hashedValuePrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// writeJSON encodes a value to buf without escaping HTML characters
+func writeJSON(buf *bytes.Buffer, value any) error {
+	var encoded bytes.Buffer
+	enc := json.NewEncoder(&encoded)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+	return nil
+}
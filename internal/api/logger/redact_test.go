@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestLogger returns a logger that writes redacted events to buf
+func newTestLogger(buf *bytes.Buffer) zerolog.Logger {
+	return zerolog.New(NewRedactingWriter(buf))
+}
+
+func TestRedactingWriter_MasksAndHashes(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+
+	log.Info().
+		Str("email", "alice@example.com").
+		Str("salt_hex", "deadbeef").
+		Int64("user_id", 42).
+		Dict("request", zerolog.Dict().Str("Authorization", "Bearer abc")).
+		Msg("Login")
+
+	out := buf.String()
+	for _, secret := range []string{"alice@example.com", "deadbeef", "Bearer abc"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted, got %s", secret, out)
+		}
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Expected redacted output to be JSON: %v", err)
+	}
+	if event["salt_hex"] != RedactedValue {
+		t.Errorf("Expected the salt to be masked, got %v", event["salt_hex"])
+	}
+	if email, _ := event["email"].(string); !strings.HasPrefix(email, hashedValuePrefix) {
+		t.Errorf("Expected the email address to be hashed, got %v", event["email"])
+	}
+	if event["user_id"] != float64(42) || event["message"] != "Login" {
+		t.Errorf("Expected other fields to be kept, got %s", out)
+	}
+	if request, _ := event["request"].(map[string]any); request["Authorization"] != RedactedValue {
+		t.Errorf("Expected nested fields to be redacted whatever their case, got %v", event["request"])
+	}
+}
+
+func TestRedactingWriter_HashesMatch(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+
+	log.Info().Str("email", "alice@example.com").Msg("first")
+	log.Info().Str("email", "alice@example.com").Msg("second")
+	log.Info().Str("email", "bob@example.com").Msg("third")
+
+	var hashes []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected redacted output to be JSON: %v", err)
+		}
+		hashes = append(hashes, event["email"].(string))
+	}
+	if hashes[0] != hashes[1] {
+		t.Error("Expected the same value to hash alike")
+	}
+	if hashes[0] == hashes[2] {
+		t.Error("Expected different values to hash differently")
+	}
+}
+
+func TestRedactingWriter_PassesOtherEventsUnchanged(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+
+	log.Info().Str("token_type", "jwt").Str("path", "/a?b=<c>").Float64("ratio", 0.5).Msg("Request")
+
+	var plain bytes.Buffer
+	plainLog := zerolog.New(&plain)
+	plainLog.Info().Str("token_type", "jwt").Str("path", "/a?b=<c>").Float64("ratio", 0.5).Msg("Request")
+	if buf.String() != plain.String() {
+		t.Errorf("Expected %s, got %s", plain.String(), buf.String())
+	}
+}
+
+func TestRedactingWriter_KeepsFieldOrder(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+
+	log.Info().Str("zeta", "1").Str("password", "hunter2").Str("alpha", "2").Msg("Ordered")
+
+	want := `{"level":"info","zeta":"1","password":"` + RedactedValue + `","alpha":"2","message":"Ordered"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestRegisterSensitiveField(t *testing.T) {
+	RegisterSensitiveField("Legal_Case_Number", Mask)
+	defer func() {
+		sensitiveFieldsMu.Lock()
+		delete(sensitiveFields, "legal_case_number")
+		sensitiveFieldsMu.Unlock()
+	}()
+
+	if redaction, ok := SensitiveField("legal_case_number"); !ok || redaction != Mask {
+		t.Error("Expected registered fields to be found whatever their case")
+	}
+
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+	log.Info().Str("legal_case_number", "2025-CV-1").Msg("Case")
+	if strings.Contains(buf.String(), "2025-CV-1") {
+		t.Errorf("Expected a registered field to be redacted, got %s", buf.String())
+	}
+}
+
+func TestStrictMode_Panics(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	var buf bytes.Buffer
+	log := newTestLogger(&buf)
+
+	// Events without sensitive fields are written as usual
+	log.Info().Int64("user_id", 42).Msg("Fine")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a sensitive field to panic in strict mode")
+		}
+	}()
+	log.Info().Str("real_identity", "alice@example.com").Msg("Leak")
+}
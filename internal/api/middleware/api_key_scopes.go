@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyScopeMetadataKey is the operation metadata key naming the scope an
+// API key needs to call the operation. Operations without it cannot be called
+// with an API key.
+const APIKeyScopeMetadataKey = "api_key_scope"
+
+// API key scopes
+const (
+	ScopePostsRead       = "posts:read"
+	ScopePostsWrite      = "posts:write"
+	ScopeVotesWrite      = "votes:write"
+	ScopeSubforumsRead   = "subforums:read"
+	ScopeSubforumsWrite  = "subforums:write"
+	ScopeMessagesRead    = "messages:read"
+	ScopeMessagesWrite   = "messages:write"
+	ScopeProfilesRead    = "profiles:read"
+	ScopeProfilesWrite   = "profiles:write"
+	ScopeReportsWrite    = "reports:write"
+	ScopeModerationRead  = "moderation:read"
+	ScopeModerationWrite = "moderation:write"
+
+	// ScopePublic marks operations any API key may call, such as health checks
+	ScopePublic = "public"
+)
+
+// APIKeyScopes are the scopes that can be granted to an API key
+var APIKeyScopes = []string{
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeVotesWrite,
+	ScopeSubforumsRead,
+	ScopeSubforumsWrite,
+	ScopeMessagesRead,
+	ScopeMessagesWrite,
+	ScopeProfilesRead,
+	ScopeProfilesWrite,
+	ScopeReportsWrite,
+	ScopeModerationRead,
+	ScopeModerationWrite,
+}
+
+// IsAPIKeyScope reports whether scope can be granted to an API key
+func IsAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks if an API key was granted a scope
+func (uc *UserContext) HasScope(scope string) bool {
+	for _, s := range uc.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScopeMiddleware refuses API key requests to operations outside the
+// key's scopes. Operations that declare no scope, such as account, session and
+// correlation management, are refused to every API key. JWT requests are not
+// affected. It must run after AuthenticateUserHuma.
+func APIKeyScopeMiddleware(api huma.API) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		userCtx, ok := ctx.Context().Value(UserContextKeyValue).(*UserContext)
+		if !ok || userCtx == nil || userCtx.TokenType != "api_token" {
+			next(ctx)
+			return
+		}
+
+		var scope string
+		if op := ctx.Operation(); op != nil {
+			scope, _ = op.Metadata[APIKeyScopeMetadataKey].(string)
+		}
+
+		switch {
+		case scope == ScopePublic || (scope != "" && userCtx.HasScope(scope)):
+			next(ctx)
+		case scope == "":
+			log.Warn().
+				Str("pseudonym_id", userCtx.ActivePseudonymID).
+				Str("path", ctx.URL().Path).
+				Msg("API key used for an operation that does not accept API keys")
+			huma.WriteErr(api, ctx, http.StatusForbidden, "this operation cannot be called with an API key")
+		default:
+			log.Warn().
+				Str("pseudonym_id", userCtx.ActivePseudonymID).
+				Str("scope", scope).
+				Str("path", ctx.URL().Path).
+				Msg("API key lacks the scope for an operation")
+			huma.WriteErr(api, ctx, http.StatusForbidden, "API key lacks the "+scope+" scope")
+		}
+	}
+}
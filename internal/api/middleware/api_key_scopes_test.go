@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+func TestIsAPIKeyScope(t *testing.T) {
+	if !IsAPIKeyScope(ScopePostsWrite) {
+		t.Error("Expected posts:write to be grantable")
+	}
+	if IsAPIKeyScope(ScopePublic) {
+		t.Error("Expected the public marker not to be grantable")
+	}
+	if IsAPIKeyScope("admin:everything") {
+		t.Error("Expected unknown scopes not to be grantable")
+	}
+}
+
+func TestAPIKeyScopeMiddleware(t *testing.T) {
+	_, api := humatest.New(t)
+
+	// Stand in for AuthenticateUserHuma with a fixed caller
+	var caller *UserContext
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		if caller != nil {
+			ctx = huma.WithValue(ctx, UserContextKeyValue, caller)
+		}
+		next(ctx)
+	})
+	api.UseMiddleware(APIKeyScopeMiddleware(api))
+
+	register := func(path, scope string) {
+		op := huma.Operation{Method: http.MethodGet, Path: path}
+		if scope != "" {
+			op.Metadata = map[string]any{APIKeyScopeMetadataKey: scope}
+		}
+		huma.Register(api, op, func(ctx context.Context, input *struct{}) (*struct{}, error) {
+			return nil, nil
+		})
+	}
+	register("/posts", ScopePostsRead)
+	register("/messages", ScopeMessagesRead)
+	register("/health", ScopePublic)
+	register("/sessions", "")
+
+	apiKey := &UserContext{TokenType: "api_token", ActivePseudonymID: "p1", Scopes: []string{ScopePostsRead}}
+	session := &UserContext{TokenType: "jwt", UserID: 1}
+
+	tests := []struct {
+		name   string
+		caller *UserContext
+		path   string
+		want   int
+	}{
+		{"key with scope", apiKey, "/posts", http.StatusNoContent},
+		{"key without scope", apiKey, "/messages", http.StatusForbidden},
+		{"key on public operation", apiKey, "/health", http.StatusNoContent},
+		{"key on unscoped operation", apiKey, "/sessions", http.StatusForbidden},
+		{"session on unscoped operation", session, "/sessions", http.StatusNoContent},
+		{"anonymous", nil, "/messages", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		caller = tt.caller
+		if resp := api.Get(tt.path); resp.Code != tt.want {
+			t.Errorf("%s: GET %s returned %d, want %d", tt.name, tt.path, resp.Code, tt.want)
+		}
+	}
+}
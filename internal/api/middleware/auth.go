@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
 	"github.com/rs/zerolog/log"
 )
 
@@ -47,8 +49,15 @@ type JWTClaims struct {
 	Roles             []string `json:"roles"`
 	Capabilities      []string `json:"capabilities"`
 	MFAEnabled        bool     `json:"mfa_enabled"`
+	EmailVerified     bool     `json:"email_verified,omitempty"`
 	ActivePseudonymID string   `json:"active_pseudonym_id"`
 	DisplayName       string   `json:"display_name"`
+	// MFAVerifiedAt is the Unix time of the last completed MFA challenge (step-up claim)
+	MFAVerifiedAt int64 `json:"mfa_verified_at,omitempty"`
+	// TokenUse distinguishes special-purpose tokens; empty for access tokens
+	TokenUse string `json:"token_use,omitempty"`
+	// SessionID ties the token to the login session it was issued for
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -59,11 +68,25 @@ type UserContext struct {
 	Roles        []string `json:"roles"`
 	Capabilities []string `json:"capabilities"`
 	MFAEnabled   bool     `json:"mfa_enabled"`
+	// MFAVerifiedAt is when the user last completed an MFA challenge, zero if never
+	MFAVerifiedAt time.Time `json:"mfa_verified_at,omitempty"`
+	// EmailVerified is whether the user's email was verified when the token was issued
+	EmailVerified bool `json:"email_verified"`
 	// Pseudonym information for the current session
 	ActivePseudonymID string `json:"active_pseudonym_id"`
 	DisplayName       string `json:"display_name"`
 	// Token type for tracking
 	TokenType string `json:"token_type"` // "jwt" or "api_token"
+	// TokenID and TokenExpiresAt identify the JWT for revocation; empty for API tokens
+	TokenID        string    `json:"token_id,omitempty"`
+	TokenExpiresAt time.Time `json:"token_expires_at,omitempty"`
+	// SessionID is the login session the token belongs to; empty for API tokens
+	SessionID string `json:"session_id,omitempty"`
+	// Scopes are the operations an API key may call; empty for JWTs
+	Scopes []string `json:"scopes,omitempty"`
+	// OwnerID is the user owning the pseudonym an API key acts for; zero for
+	// JWTs. It only counts the key's requests and never leaves the server.
+	OwnerID int64 `json:"-"`
 }
 
 // HasCapability checks if the user has a specific capability
@@ -110,6 +133,30 @@ func (uc *UserContext) RequiresMFA(action string) bool {
 	return mfaRequiredActions[action]
 }
 
+// newUserContextFromClaims builds a user context from validated JWT claims
+func newUserContextFromClaims(claims *JWTClaims) *UserContext {
+	userContext := &UserContext{
+		UserID:            claims.UserID,
+		Email:             claims.Email,
+		Roles:             claims.Roles,
+		Capabilities:      claims.Capabilities,
+		MFAEnabled:        claims.MFAEnabled,
+		EmailVerified:     claims.EmailVerified,
+		ActivePseudonymID: claims.ActivePseudonymID,
+		DisplayName:       claims.DisplayName,
+		TokenType:         "jwt",
+		TokenID:           claims.ID,
+		SessionID:         claims.SessionID,
+	}
+	if claims.MFAVerifiedAt > 0 {
+		userContext.MFAVerifiedAt = time.Unix(claims.MFAVerifiedAt, 0)
+	}
+	if claims.ExpiresAt != nil {
+		userContext.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+	return userContext
+}
+
 // ExtractUserFromContext extracts user context from the request context
 func ExtractUserFromContext(ctx context.Context) (*UserContext, error) {
 	userCtx, ok := ctx.Value(UserContextKeyValue).(*UserContext)
@@ -132,42 +179,35 @@ func SetUserContext(ctx context.Context, userCtx *UserContext) context.Context {
 
 // AuthMiddleware handles authentication and authorization
 type AuthMiddleware struct {
-	jwtSecret      []byte
+	signingKeys    *jwtkeys.KeySet
 	apiKeyDAO      *dao.APIKeyDAO
 	jwtConfig      *config.JWTConfig
 	securityConfig *config.SecurityConfig
+	revocationDAO  *dao.TokenRevocationDAO
+	userDAO        *dao.UserDAO
+	lastPurge      atomic.Int64
 }
 
 // NewAuthMiddleware creates a new authentication middleware
-func NewAuthMiddleware(jwtSecret string, apiKeyDAO *dao.APIKeyDAO, jwtConfig *config.JWTConfig, securityConfig *config.SecurityConfig) *AuthMiddleware {
+func NewAuthMiddleware(signingKeys *jwtkeys.KeySet, apiKeyDAO *dao.APIKeyDAO, jwtConfig *config.JWTConfig, securityConfig *config.SecurityConfig) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret:      []byte(jwtSecret),
+		signingKeys:    signingKeys,
 		apiKeyDAO:      apiKeyDAO,
 		jwtConfig:      jwtConfig,
 		securityConfig: securityConfig,
 	}
 }
 
-// validateAndParseJWT validates and parses a JWT token
+// validateAndParseJWT validates and parses a JWT token, rejecting revoked tokens
 func (m *AuthMiddleware) validateAndParseJWT(tokenString string) (*JWTClaims, error) {
-	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return m.jwtSecret, nil
-	})
-
+	claims, err := validateAndParseJWT(tokenString, m.signingKeys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JWT: %w", err)
+		return nil, err
 	}
-
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	if err := m.checkRevocation(claims); err != nil {
+		return nil, err
 	}
-
-	return nil, ErrInvalidToken
+	return claims, nil
 }
 
 // validateAPIToken validates a static API token
@@ -182,6 +222,13 @@ func (m *AuthMiddleware) validateAPIToken(tokenString string) (*UserContext, err
 		return nil, fmt.Errorf("invalid API token: %w", err)
 	}
 
+	// Requests are counted against the owner, so keys on several pseudonyms
+	// of a user share one budget
+	ownerID, err := m.apiKeyDAO.GetAPIKeyOwner(context.Background(), pseudonymID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API token: %w", err)
+	}
+
 	// Create user context from API key permissions
 	userContext := &UserContext{
 		UserID:            0,  // API keys don't have a specific user ID
@@ -192,6 +239,8 @@ func (m *AuthMiddleware) validateAPIToken(tokenString string) (*UserContext, err
 		ActivePseudonymID: pseudonymID, // Set the pseudonym ID from the API key
 		DisplayName:       "",          // Will be loaded from pseudonym if needed
 		TokenType:         "api_token",
+		Scopes:            permissions.Scopes,
+		OwnerID:           ownerID,
 	}
 
 	return userContext, nil
@@ -222,18 +271,7 @@ func (m *AuthMiddleware) extractTokenFromRequest(r *http.Request) (*UserContext,
 		}
 
 		// Extract user context from JWT claims
-		userContext := &UserContext{
-			UserID:            claims.UserID,
-			Email:             claims.Email,
-			Roles:             claims.Roles,
-			Capabilities:      claims.Capabilities,
-			MFAEnabled:        claims.MFAEnabled,
-			ActivePseudonymID: claims.ActivePseudonymID,
-			DisplayName:       claims.DisplayName,
-			TokenType:         "jwt",
-		}
-
-		return userContext, nil
+		return newUserContextFromClaims(claims), nil
 	}
 
 	// No valid token found
@@ -265,8 +303,8 @@ func ExtractUserFromAuthenticatedInput(input *AuthenticatedInput) (*UserContext,
 // extractTokenFromHumaInput extracts token from either header or cookie using Huma input struct
 func (m *AuthMiddleware) extractTokenFromHumaInput(input *AuthInput) (*UserContext, error) {
 	log.Debug().
-		Str("Authorization", input.Authorization).
-		Str("AccessToken", input.AccessToken).
+		Bool("has_authorization", input.Authorization != "").
+		Bool("has_access_token", input.AccessToken != "").
 		Msg("Extracting token from Huma input")
 	// First, try to extract from Authorization header (for JWT tokens)
 	if input.Authorization != "" {
@@ -279,17 +317,7 @@ func (m *AuthMiddleware) extractTokenFromHumaInput(input *AuthInput) (*UserConte
 				claims, err := m.validateAndParseJWT(token)
 				if err == nil {
 					// JWT validation succeeded
-					userContext := &UserContext{
-						UserID:            claims.UserID,
-						Email:             claims.Email,
-						Roles:             claims.Roles,
-						Capabilities:      claims.Capabilities,
-						MFAEnabled:        claims.MFAEnabled,
-						ActivePseudonymID: claims.ActivePseudonymID,
-						DisplayName:       claims.DisplayName,
-						TokenType:         "jwt",
-					}
-					return userContext, nil
+					return newUserContextFromClaims(claims), nil
 				}
 
 				// JWT validation failed, try API token validation
@@ -314,18 +342,7 @@ func (m *AuthMiddleware) extractTokenFromHumaInput(input *AuthInput) (*UserConte
 		}
 
 		// Extract user context from JWT claims
-		userContext := &UserContext{
-			UserID:            claims.UserID,
-			Email:             claims.Email,
-			Roles:             claims.Roles,
-			Capabilities:      claims.Capabilities,
-			MFAEnabled:        claims.MFAEnabled,
-			ActivePseudonymID: claims.ActivePseudonymID,
-			DisplayName:       claims.DisplayName,
-			TokenType:         "jwt",
-		}
-
-		return userContext, nil
+		return newUserContextFromClaims(claims), nil
 	}
 
 	// No valid token found
@@ -350,7 +367,6 @@ func (m *AuthMiddleware) AuthenticateUser(next http.Handler) http.Handler {
 
 		log.Debug().
 			Int64("user_id", userCtx.UserID).
-			Str("email", userCtx.Email).
 			Str("token_type", userCtx.TokenType).
 			Str("path", r.URL.Path).
 			Msg("User authenticated")
@@ -367,11 +383,14 @@ func AuthenticateUserHuma(ctx huma.Context, next func(huma.Context)) {
 
 	// Try to extract authorization header
 	if authHeader := ctx.Header("Authorization"); authHeader != "" {
-		log.Debug().Str("auth_header", authHeader).Msg("Received Authorization header")
+		log.Debug().Msg("Received Authorization header")
 		input.Authorization = authHeader
 	}
 
-	log.Debug().Str("input.Authorization", input.Authorization).Msg("AuthInput before extraction")
+	// Fall back to the access token cookie set for browser clients
+	if cookie, err := huma.ReadCookie(ctx, AccessTokenCookie); err == nil {
+		input.AccessToken = cookie.Value
+	}
 
 	var userCtx *UserContext
 
@@ -383,7 +402,7 @@ func AuthenticateUserHuma(ctx huma.Context, next func(huma.Context)) {
 		return
 	}
 
-	// Extract user context from input (header only for middleware)
+	// Extract user context from input (header first, then cookie)
 	userCtx, _ = authMiddleware.extractTokenFromHumaInput(&input)
 
 	if userCtx == nil {
@@ -393,11 +412,10 @@ func AuthenticateUserHuma(ctx huma.Context, next func(huma.Context)) {
 	}
 
 	// Add user context to request context
-	SetUserContext(ctx.Context(), userCtx)
+	ctx = huma.WithValue(ctx, UserContextKeyValue, userCtx)
 
 	log.Debug().
 		Int64("user_id", userCtx.UserID).
-		Str("email", userCtx.Email).
 		Str("token_type", userCtx.TokenType).
 		Str("path", ctx.URL().Path).
 		Msg("User authenticated")
@@ -475,22 +493,20 @@ func ExtractUserFromHumaInput(input *AuthInput) (*UserContext, error) {
 //     return &MyResponse{}, nil
 // }
 
-// validateAndParseJWT validates and parses a JWT token
-func validateAndParseJWT(tokenString string, jwtSecret []byte) (*JWTClaims, error) {
-	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
-
+// validateAndParseJWT validates and parses a JWT token against the published signing keys
+func validateAndParseJWT(tokenString string, signingKeys *jwtkeys.KeySet) (*JWTClaims, error) {
+	// Parse the token; the key set resolves the key from the kid header and
+	// only accepts the algorithm that key was created for
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, signingKeys.Keyfunc, jwt.WithValidMethods(signingKeys.Algorithms()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JWT: %w", err)
 	}
 
 	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		// Special-purpose tokens (e.g. MFA challenges) are never valid for authentication
+		if claims.TokenUse != "" {
+			return nil, ErrInvalidToken
+		}
 		return claims, nil
 	}
 
@@ -572,8 +588,7 @@ func (m *AuthMiddleware) RequireMFA(action string) func(http.Handler) http.Handl
 				return
 			}
 
-			if userCtx.RequiresMFA(action) {
-				// TODO: Validate MFA token if required
+			if err := m.CheckMFA(userCtx, action); err != nil {
 				log.Warn().
 					Int64("user_id", userCtx.UserID).
 					Str("action", action).
@@ -631,25 +646,35 @@ func (m *AuthMiddleware) ExtractUserFromToken(r *http.Request) (*UserContext, er
 	return m.extractTokenFromRequest(r)
 }
 
-// GenerateJWT generates a new JWT token for a user
-func GenerateJWT(userCtx *UserContext, jwtSecret string, expiration time.Duration) (string, error) {
+// GenerateJWT generates a new JWT token for a user, signed with the active signing key
+func GenerateJWT(userCtx *UserContext, signingKeys *jwtkeys.KeySet, expiration time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
 	claims := &JWTClaims{
 		UserID:            userCtx.UserID,
 		Email:             userCtx.Email,
 		Roles:             userCtx.Roles,
 		Capabilities:      userCtx.Capabilities,
 		MFAEnabled:        userCtx.MFAEnabled,
+		EmailVerified:     userCtx.EmailVerified,
 		ActivePseudonymID: userCtx.ActivePseudonymID,
 		DisplayName:       userCtx.DisplayName,
+		SessionID:         userCtx.SessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if !userCtx.MFAVerifiedAt.IsZero() {
+		claims.MFAVerifiedAt = userCtx.MFAVerifiedAt.Unix()
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	return signingKeys.Sign(claims)
 }
 
 // SetJWTCookies sets JWT tokens as HTTP-only cookies
@@ -817,6 +842,8 @@ var (
 	ErrInvalidToken      = &AuthError{Code: "INVALID_TOKEN", Message: "Invalid or expired token"}
 	ErrInsufficientPerms = &AuthError{Code: "INSUFFICIENT_PERMISSIONS", Message: "Insufficient permissions for this operation"}
 	ErrMFARequired       = &AuthError{Code: "MFA_REQUIRED", Message: "Multi-factor authentication required for this operation"}
+	ErrTokenRevoked      = &AuthError{Code: "TOKEN_REVOKED", Message: "Token has been revoked"}
+	ErrEmailNotVerified  = &AuthError{Code: "EMAIL_NOT_VERIFIED", Message: "Verify your email address to perform this operation"}
 )
 
 // AuthError represents authentication/authorization errors
@@ -10,14 +10,13 @@ import (
 
 func TestDualAuthentication_HeaderAPIToken(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	// Test API token in header
@@ -49,21 +48,19 @@ func TestDualAuthentication_CookieJWT(t *testing.T) {
 		DisplayName:       "Test User",
 	}
 
-	jwtSecret := "test-jwt-secret"
-	token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+	token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 	if err != nil {
 		t.Fatalf("Failed to generate JWT: %v", err)
 	}
 
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	// Test cookie-based JWT
@@ -110,21 +107,19 @@ func TestDualAuthentication_Priority(t *testing.T) {
 		DisplayName:       "Test User",
 	}
 
-	jwtSecret := "test-jwt-secret"
-	token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+	token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 	if err != nil {
 		t.Fatalf("Failed to generate JWT: %v", err)
 	}
 
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	// Test that header takes priority over cookie
@@ -151,14 +146,13 @@ func TestDualAuthentication_Priority(t *testing.T) {
 
 func TestDualAuthentication_NoAuth(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	// Test no authentication
@@ -177,14 +171,13 @@ func TestDualAuthentication_NoAuth(t *testing.T) {
 
 func TestDualAuthentication_InvalidJWT(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	// Test invalid JWT token
@@ -219,16 +212,15 @@ func TestCookieManagement(t *testing.T) {
 		DisplayName:       "Test User",
 	}
 
-	jwtSecret := "test-jwt-secret"
 	expiration := 24 * time.Hour
 
 	// Generate JWT tokens
-	accessToken, err := GenerateJWT(userCtx, jwtSecret, expiration)
+	accessToken, err := GenerateJWT(userCtx, testSigningKeys, expiration)
 	if err != nil {
 		t.Fatalf("Failed to generate access token: %v", err)
 	}
 
-	refreshToken, err := GenerateJWT(userCtx, jwtSecret, 7*24*time.Hour)
+	refreshToken, err := GenerateJWT(userCtx, testSigningKeys, 7*24*time.Hour)
 	if err != nil {
 		t.Fatalf("Failed to generate refresh token: %v", err)
 	}
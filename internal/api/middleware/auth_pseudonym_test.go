@@ -115,8 +115,7 @@ func TestGenerateJWT_PseudonymContext(t *testing.T) {
 			TokenType:         "jwt",
 		}
 
-		jwtSecret := "test-jwt-secret"
-		token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+		token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 		if err != nil {
 			t.Fatalf("Failed to generate JWT: %v", err)
 		}
@@ -128,14 +127,13 @@ func TestGenerateJWT_PseudonymContext(t *testing.T) {
 
 		// Parse and verify the token contains pseudonym context
 		jwtConfig := &config.JWTConfig{
-			Secret:      "test-jwt-secret",
 			Expiration:  24 * time.Hour,
 			Development: true,
 		}
 		securityConfig := &config.SecurityConfig{
 			EnableMFA: false,
 		}
-		authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+		authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 		// Create a mock request with the JWT token
 		req, err := http.NewRequest("GET", "/test", nil)
@@ -181,8 +179,7 @@ func TestGenerateJWT_PseudonymContext(t *testing.T) {
 			TokenType:         "jwt",
 		}
 
-		jwtSecret := "test-jwt-secret"
-		token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+		token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 		if err != nil {
 			t.Fatalf("Failed to generate JWT: %v", err)
 		}
@@ -194,14 +191,13 @@ func TestGenerateJWT_PseudonymContext(t *testing.T) {
 
 		// Parse and verify the token handles empty pseudonym context
 		jwtConfig := &config.JWTConfig{
-			Secret:      "test-jwt-secret",
 			Expiration:  24 * time.Hour,
 			Development: true,
 		}
 		securityConfig := &config.SecurityConfig{
 			EnableMFA: false,
 		}
-		authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+		authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 		// Create a mock request with the JWT token
 		req, err := http.NewRequest("GET", "/test", nil)
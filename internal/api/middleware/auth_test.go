@@ -6,8 +6,20 @@ import (
 	"time"
 
 	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
 )
 
+// testSigningKeys signs and verifies tokens in the middleware tests
+var testSigningKeys = newTestSigningKeys()
+
+func newTestSigningKeys() *jwtkeys.KeySet {
+	signingKeys, err := jwtkeys.GenerateKeySet(jwtkeys.AlgEdDSA)
+	if err != nil {
+		panic(err)
+	}
+	return signingKeys
+}
+
 func TestGenerateJWT(t *testing.T) {
 	userCtx := &UserContext{
 		UserID:            123,
@@ -19,8 +31,7 @@ func TestGenerateJWT(t *testing.T) {
 		DisplayName:       "Test User",
 	}
 
-	jwtSecret := "test-jwt-secret"
-	token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+	token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 	if err != nil {
 		t.Fatalf("Failed to generate JWT: %v", err)
 	}
@@ -30,14 +41,13 @@ func TestGenerateJWT(t *testing.T) {
 
 	// Verify token can be parsed
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 	// Test cookie-based JWT
 	req, err := http.NewRequest("GET", "/web/test", nil)
@@ -74,14 +84,13 @@ func TestGenerateJWT(t *testing.T) {
 
 func TestExtractUserFromToken_HeaderAPI(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 	// Test API token in header
 	req, err := http.NewRequest("GET", "/api/test", nil)
@@ -111,21 +120,19 @@ func TestExtractUserFromToken_CookieJWT(t *testing.T) {
 		DisplayName:       "Test User",
 	}
 
-	jwtSecret := "test-jwt-secret"
-	token, err := GenerateJWT(userCtx, jwtSecret, 24*time.Hour)
+	token, err := GenerateJWT(userCtx, testSigningKeys, 24*time.Hour)
 	if err != nil {
 		t.Fatalf("Failed to generate JWT: %v", err)
 	}
 
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware(jwtSecret, nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 	// Test cookie-based JWT
 	req, err := http.NewRequest("GET", "/web/test", nil)
@@ -162,14 +169,13 @@ func TestExtractUserFromToken_CookieJWT(t *testing.T) {
 
 func TestExtractUserFromToken_NoAuth(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 	// Test no authentication
 	req, err := http.NewRequest("GET", "/public/test", nil)
@@ -187,14 +193,13 @@ func TestExtractUserFromToken_NoAuth(t *testing.T) {
 
 func TestExtractUserFromToken_InvalidJWT(t *testing.T) {
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false,
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 
 	// Test invalid JWT token
 	req, err := http.NewRequest("GET", "/web/test", nil)
@@ -273,14 +278,13 @@ func TestUserContext_HasRole(t *testing.T) {
 func TestUserContext_RequiresMFA(t *testing.T) {
 	// Set up global auth middleware with MFA enabled for testing
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: true, // Enable MFA for this test
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	userCtx := &UserContext{
@@ -312,14 +316,13 @@ func TestUserContext_RequiresMFA(t *testing.T) {
 func TestUserContext_RequiresMFA_WithCorrelationCapabilities(t *testing.T) {
 	// Set up global auth middleware with MFA enabled for testing
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: true, // Enable MFA for this test
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	userCtx := &UserContext{
@@ -349,14 +352,13 @@ func TestUserContext_RequiresMFA_WithCorrelationCapabilities(t *testing.T) {
 func TestUserContext_RequiresMFA_Disabled(t *testing.T) {
 	// Set up global auth middleware with MFA disabled for testing
 	jwtConfig := &config.JWTConfig{
-		Secret:      "test-jwt-secret",
 		Expiration:  24 * time.Hour,
 		Development: true,
 	}
 	securityConfig := &config.SecurityConfig{
 		EnableMFA: false, // Disable MFA for this test
 	}
-	authMiddleware := NewAuthMiddleware("test-jwt-secret", nil, jwtConfig, securityConfig)
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
 	SetGlobalAuthMiddleware(authMiddleware)
 
 	userCtx := &UserContext{
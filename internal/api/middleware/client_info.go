@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"net"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/netprivacy"
+	"github.com/rs/zerolog/log"
+)
+
+// ClientInfoContextKey is the context key for request client information
+type ClientInfoContextKey string
+
+// ClientInfoKeyValue is the key used to store client information in request context
+const ClientInfoKeyValue ClientInfoContextKey = "client_info"
+
+// ClientInfo describes the client that sent a request in the forms that may
+// be stored. The raw address and user agent are not kept.
+type ClientInfo struct {
+	IPHashes        []string // Keyed hashes of the client's network under each key of the matching window, current day first
+	IPPrefix        string   // The client's /24 or /48 network
+	UserAgentFamily string   // Browser family of the client's user agent
+	// ProcessHash is the hash of the client's network under the process
+	// key. It is stable across days and only kept in memory.
+	ProcessHash string
+}
+
+// IPHash returns the hash of the client's network under the current day's
+// key, or an empty string if it could not be hashed
+func (c ClientInfo) IPHash() string {
+	if len(c.IPHashes) == 0 {
+		return ""
+	}
+	return c.IPHashes[0]
+}
+
+// ClientInfoMiddleware reduces the client's IP address and user agent with
+// hasher and records the result in the request context so handlers can read
+// it with ClientInfoFromContext
+func ClientInfoMiddleware(hasher *netprivacy.Hasher) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		ip := remoteIP(ctx.RemoteAddr())
+		info := ClientInfo{
+			IPPrefix:        netprivacy.CoarsePrefix(ip),
+			UserAgentFamily: netprivacy.BrowserFamily(ctx.Header("User-Agent")),
+		}
+		if ip != "" {
+			hashes, err := hasher.Hashes(ctx.Context(), ip)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to hash client address")
+			}
+			info.IPHashes = hashes
+			info.ProcessHash = hasher.ProcessHash(ip)
+		}
+		next(huma.WithValue(ctx, ClientInfoKeyValue, info))
+	}
+}
+
+// ClientInfoFromContext returns the client information for a request, or an
+// empty ClientInfo if the middleware did not run
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	if info, ok := ctx.Value(ClientInfoKeyValue).(ClientInfo); ok {
+		return info
+	}
+	return ClientInfo{}
+}
+
+// remoteIP strips the port from a RemoteAddr value
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import "testing"
+
+func TestRemoteIP(t *testing.T) {
+	if got := remoteIP("203.0.113.57:54321"); got != "203.0.113.57" {
+		t.Errorf("Expected host without port, got %q", got)
+	}
+	if got := remoteIP("[2001:db8::1]:443"); got != "2001:db8::1" {
+		t.Errorf("Expected IPv6 host without port, got %q", got)
+	}
+	if got := remoteIP("203.0.113.57"); got != "203.0.113.57" {
+		t.Errorf("Expected address without port to be returned unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func TestEmailToken_Purpose(t *testing.T) {
+	token, err := GenerateEmailToken(42, "user@example.com", TokenUsePasswordReset, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate email token: %v", err)
+	}
+
+	claims, err := ParseEmailToken(token, TokenUsePasswordReset, testSigningKeys)
+	if err != nil {
+		t.Fatalf("Failed to parse email token: %v", err)
+	}
+	if claims.UserID != 42 || claims.Email != "user@example.com" || claims.ID == "" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := ParseEmailToken(token, TokenUseEmailVerification, testSigningKeys); err == nil {
+		t.Error("Expected password reset token to be rejected as a verification token")
+	}
+
+	authMiddleware := newMFATestMiddleware(false)
+	if _, err := authMiddleware.validateAndParseJWT(token); err == nil {
+		t.Error("Expected email token to be rejected as an access token")
+	}
+}
+
+func TestCheckEmailVerified(t *testing.T) {
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, &config.JWTConfig{Expiration: time.Hour}, &config.SecurityConfig{
+		UnverifiedRestrictedActions: []string{"create_post"},
+	})
+
+	tests := []struct {
+		name     string
+		action   string
+		userCtx  *UserContext
+		expected error
+	}{
+		{"unverified user cannot post", "create_post", &UserContext{UserID: 1, TokenType: "jwt"}, ErrEmailNotVerified},
+		{"verified user can post", "create_post", &UserContext{UserID: 1, TokenType: "jwt", EmailVerified: true}, nil},
+		{"unverified user can do unrestricted actions", "update_profile", &UserContext{UserID: 1, TokenType: "jwt"}, nil},
+		{"API tokens are not restricted", "create_post", &UserContext{TokenType: "api_token"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authMiddleware.CheckEmailVerified(context.Background(), tt.userCtx, tt.action)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
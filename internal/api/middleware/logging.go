@@ -7,17 +7,20 @@ import (
 	"github.com/matt0x6f/hashpost/internal/api/logger"
 )
 
-// LoggingMiddleware provides structured request logging for all operations
+// LoggingMiddleware provides structured request logging for all operations.
+// Clients are logged in the reduced forms recorded by ClientInfoMiddleware,
+// which must run first.
 func LoggingMiddleware(ctx huma.Context, next func(huma.Context)) {
 	start := time.Now()
 	log := logger.GetRequestLogger()
+	client := ClientInfoFromContext(ctx.Context())
 
 	// Log the incoming request
 	log.Info().
 		Str("method", ctx.Method()).
 		Str("path", ctx.URL().Path).
-		Str("remote_addr", ctx.RemoteAddr()).
-		Str("user_agent", ctx.Header("User-Agent")).
+		Str("ip_prefix", client.IPPrefix).
+		Str("user_agent_family", client.UserAgentFamily).
 		Msg("Request started")
 
 	// Call the next middleware/handler
@@ -30,6 +33,6 @@ func LoggingMiddleware(ctx huma.Context, next func(huma.Context)) {
 		Str("path", ctx.URL().Path).
 		Int("status", ctx.Status()).
 		Dur("duration", duration).
-		Str("remote_addr", ctx.RemoteAddr()).
+		Str("ip_prefix", client.IPPrefix).
 		Msg("Request completed")
 }
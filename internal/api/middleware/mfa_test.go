@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func newMFATestMiddleware(enableMFA bool) *AuthMiddleware {
+	jwtConfig := &config.JWTConfig{
+		Expiration:  24 * time.Hour,
+		Development: true,
+	}
+	securityConfig := &config.SecurityConfig{
+		EnableMFA:       enableMFA,
+		MFAStepUpWindow: 15 * time.Minute,
+	}
+	authMiddleware := NewAuthMiddleware(testSigningKeys, nil, jwtConfig, securityConfig)
+	SetGlobalAuthMiddleware(authMiddleware)
+	return authMiddleware
+}
+
+func TestRequireMFA_StepUpClaim(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(true)
+
+	tests := []struct {
+		name          string
+		action        string
+		mfaVerifiedAt time.Time
+		expected      int
+	}{
+		{"identity correlation without MFA is rejected", "correlate_identities", time.Time{}, http.StatusForbidden},
+		{"identity correlation with recent MFA passes", "correlate_identities", time.Now().Add(-time.Minute), http.StatusOK},
+		{"identity correlation with stale MFA is rejected", "correlate_identities", time.Now().Add(-time.Hour), http.StatusForbidden},
+		{"legal compliance without MFA is rejected", "legal_compliance", time.Time{}, http.StatusForbidden},
+		{"legal compliance with recent MFA passes", "legal_compliance", time.Now(), http.StatusOK},
+		{"ordinary action does not need MFA", "create_content", time.Time{}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userCtx := &UserContext{
+				UserID:        1,
+				Capabilities:  []string{"correlate_identities"},
+				MFAVerifiedAt: tt.mfaVerifiedAt,
+				TokenType:     "jwt",
+			}
+
+			handler := authMiddleware.RequireMFA(tt.action)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/admin/correlate", nil)
+			req = req.WithContext(SetUserContext(req.Context(), userCtx))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireMFA_DisabledGlobally(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(false)
+
+	userCtx := &UserContext{UserID: 1, Capabilities: []string{"correlate_identities"}}
+	if err := authMiddleware.CheckMFA(userCtx, "correlate_identities"); err != nil {
+		t.Errorf("Expected no MFA requirement when disabled globally, got %v", err)
+	}
+}
+
+func TestGenerateJWT_CarriesMFAClaim(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(true)
+
+	verifiedAt := time.Now().Add(-2 * time.Minute).Truncate(time.Second)
+	token, err := GenerateJWT(&UserContext{UserID: 42, MFAEnabled: true, MFAVerifiedAt: verifiedAt}, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+
+	userCtx, err := authMiddleware.extractTokenFromHumaInput(&AuthInput{Authorization: "Bearer " + token})
+	if err != nil {
+		t.Fatalf("Failed to extract user: %v", err)
+	}
+	if !userCtx.MFAVerifiedAt.Equal(verifiedAt) {
+		t.Errorf("Expected MFAVerifiedAt %v, got %v", verifiedAt, userCtx.MFAVerifiedAt)
+	}
+	if err := authMiddleware.CheckMFA(userCtx, "correlate_identities"); err != nil {
+		t.Errorf("Expected recent MFA claim to satisfy check, got %v", err)
+	}
+}
+
+func TestMFAChallengeToken(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(true)
+
+	token, err := GenerateMFAChallengeToken(42, testSigningKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate challenge token: %v", err)
+	}
+
+	userID, err := ParseMFAChallengeToken(token, testSigningKeys)
+	if err != nil {
+		t.Fatalf("Failed to parse challenge token: %v", err)
+	}
+	if userID != 42 {
+		t.Errorf("Expected user ID 42, got %d", userID)
+	}
+
+	// A challenge token must not authenticate requests
+	if _, err := authMiddleware.validateAndParseJWT(token); err == nil {
+		t.Error("Expected challenge token to be rejected as an access token")
+	}
+
+	// An access token must not be accepted as a challenge token
+	accessToken, err := GenerateJWT(&UserContext{UserID: 42}, testSigningKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+	if _, err := ParseMFAChallengeToken(accessToken, testSigningKeys); err == nil {
+		t.Error("Expected access token to be rejected as a challenge token")
+	}
+}
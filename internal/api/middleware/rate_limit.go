@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+// RateLimitMiddleware limits requests per client with token buckets. Signed-in
+// users are counted across all of their pseudonyms, API keys per pseudonym, and
+// anonymous clients per address. Every limited response carries the
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers; refused requests get 429 with Retry-After. It must run after
+// AuthenticateUserHuma and ClientInfoMiddleware.
+func RateLimitMiddleware(api huma.API, limiter *ratelimit.Limiter) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		class := rateLimitClass(ctx)
+		if class == ratelimit.ClassExempt {
+			next(ctx)
+			return
+		}
+
+		principal := rateLimitPrincipal(ctx)
+		result, err := limiter.Allow(ctx.Context(), class, principal)
+		if err != nil {
+			// An unavailable store must not take the API down with it
+			log.Error().Err(err).Str("class", class).Msg("Failed to check rate limit")
+			next(ctx)
+			return
+		}
+
+		if result.Limit.Requests > 0 {
+			ctx.SetHeader("RateLimit-Limit", strconv.Itoa(result.Limit.Requests))
+			ctx.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			ctx.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			ctx.SetHeader("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit.Requests, ceilSeconds(result.Limit.Period)))
+		}
+
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			log.Warn().
+				Str("class", class).
+				Str("client_kind", principal.Kind).
+				Str("path", ctx.URL().Path).
+				Msg("Request refused by rate limit")
+			ctx.SetHeader("Retry-After", strconv.Itoa(retryAfter))
+			huma.WriteErr(api, ctx, http.StatusTooManyRequests, "rate limit exceeded; try again later")
+			return
+		}
+
+		next(ctx)
+	}
+}
+
+// rateLimitClass returns the limit class of the requested operation
+func rateLimitClass(ctx huma.Context) string {
+	if op := ctx.Operation(); op != nil {
+		if class, ok := op.Metadata[ratelimit.ClassMetadataKey].(string); ok && class != "" {
+			return class
+		}
+	}
+	switch ctx.Method() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ratelimit.ClassRead
+	default:
+		return ratelimit.ClassWrite
+	}
+}
+
+// rateLimitPrincipal returns the client a request is counted against
+func rateLimitPrincipal(ctx huma.Context) ratelimit.Principal {
+	if userCtx, ok := ctx.Context().Value(UserContextKeyValue).(*UserContext); ok && userCtx != nil {
+		if userCtx.TokenType == "api_token" && userCtx.OwnerID != 0 {
+			return ratelimit.APIKeyPrincipal(userCtx.OwnerID)
+		}
+		if userCtx.UserID != 0 {
+			return ratelimit.UserPrincipal(userCtx.UserID)
+		}
+	}
+	// The daily IP hash changes at midnight UTC, which would reset every
+	// anonymous budget; the process hash does not
+	client := ClientInfoFromContext(ctx.Context())
+	if client.ProcessHash != "" {
+		return ratelimit.AnonymousPrincipal(client.ProcessHash)
+	}
+	// Without a hash, count the client against its coarse network
+	return ratelimit.AnonymousPrincipal(client.IPPrefix)
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+)
+
+// TokenUseRefresh marks refresh tokens so they cannot be used as access tokens
+const TokenUseRefresh = "refresh"
+
+// RefreshTokenExpiration is the lifetime of a single refresh token. Rotation
+// issues a new token with a fresh expiration on every refresh.
+const RefreshTokenExpiration = 7 * 24 * time.Hour
+
+// GenerateRefreshToken issues a refresh token carrying the user's claims. Each
+// token gets a random ID so that tokens issued in the same second still differ.
+func GenerateRefreshToken(userCtx *UserContext, signingKeys *jwtkeys.KeySet, expiration time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &JWTClaims{
+		UserID:            userCtx.UserID,
+		Email:             userCtx.Email,
+		Roles:             userCtx.Roles,
+		Capabilities:      userCtx.Capabilities,
+		MFAEnabled:        userCtx.MFAEnabled,
+		EmailVerified:     userCtx.EmailVerified,
+		ActivePseudonymID: userCtx.ActivePseudonymID,
+		DisplayName:       userCtx.DisplayName,
+		TokenUse:          TokenUseRefresh,
+		SessionID:         userCtx.SessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if !userCtx.MFAVerifiedAt.IsZero() {
+		claims.MFAVerifiedAt = userCtx.MFAVerifiedAt.Unix()
+	}
+
+	return signingKeys.Sign(claims)
+}
+
+// ParseRefreshToken validates the signature and expiry of a refresh token and
+// returns its claims. Whether the token is still usable is decided by the
+// refresh token store.
+func ParseRefreshToken(tokenString string, signingKeys *jwtkeys.KeySet) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, signingKeys.Keyfunc, jwt.WithValidMethods(signingKeys.Algorithms()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse refresh token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid || claims.TokenUse != TokenUseRefresh {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// HashRefreshToken returns the hex SHA-256 hash under which a refresh token is stored
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshToken_RoundTrip(t *testing.T) {
+	userCtx := &UserContext{
+		UserID:            42,
+		Email:             "user@example.com",
+		Roles:             []string{"user"},
+		ActivePseudonymID: "pseudonym_123",
+		MFAVerifiedAt:     time.Now().Add(-time.Minute).Truncate(time.Second),
+	}
+
+	token, err := GenerateRefreshToken(userCtx, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+
+	claims, err := ParseRefreshToken(token, testSigningKeys)
+	if err != nil {
+		t.Fatalf("Failed to parse refresh token: %v", err)
+	}
+	if claims.UserID != 42 || claims.ActivePseudonymID != "pseudonym_123" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+	if claims.MFAVerifiedAt != userCtx.MFAVerifiedAt.Unix() {
+		t.Errorf("Expected MFA claim %d, got %d", userCtx.MFAVerifiedAt.Unix(), claims.MFAVerifiedAt)
+	}
+	if claims.ID == "" {
+		t.Error("Expected refresh token to carry a token ID")
+	}
+
+	if _, err := ParseRefreshToken(token, newTestSigningKeys()); err == nil {
+		t.Error("Expected refresh token signed with another key to be rejected")
+	}
+}
+
+func TestRefreshToken_Unique(t *testing.T) {
+	userCtx := &UserContext{UserID: 42}
+
+	first, err := GenerateRefreshToken(userCtx, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+	second, err := GenerateRefreshToken(userCtx, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+
+	if HashRefreshToken(first) == HashRefreshToken(second) {
+		t.Error("Expected refresh tokens issued together to have different hashes")
+	}
+}
+
+func TestRefreshToken_NotInterchangeableWithAccessToken(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(false)
+	userCtx := &UserContext{UserID: 42}
+
+	refreshToken, err := GenerateRefreshToken(userCtx, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+	if _, err := authMiddleware.validateAndParseJWT(refreshToken); err == nil {
+		t.Error("Expected refresh token to be rejected as an access token")
+	}
+
+	accessToken, err := GenerateJWT(userCtx, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+	if _, err := ParseRefreshToken(accessToken, testSigningKeys); err == nil {
+		t.Error("Expected access token to be rejected as a refresh token")
+	}
+}
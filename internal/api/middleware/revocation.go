@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+)
+
+// revocationPurgeInterval is how often expired revocations are removed from the database
+const revocationPurgeInterval = time.Hour
+
+// newTokenID returns a random jti for a new token
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// SetTokenRevocationDAO enables revocation checks for access tokens. Without
+// it, any correctly signed and unexpired token is accepted.
+func (m *AuthMiddleware) SetTokenRevocationDAO(revocationDAO *dao.TokenRevocationDAO) {
+	m.revocationDAO = revocationDAO
+}
+
+// checkRevocation rejects tokens that were revoked individually, through their
+// session, or by a revocation cutoff for their user. Lookup failures reject the token.
+func (m *AuthMiddleware) checkRevocation(claims *JWTClaims) error {
+	if m.revocationDAO == nil {
+		return nil
+	}
+
+	m.maybePurgeRevocations()
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := m.revocationDAO.IsTokenRevoked(context.Background(), claims.ID, claims.SessionID, claims.UserID, issuedAt)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int64("user_id", claims.UserID).
+			Msg("Failed to check token revocation")
+		return ErrInvalidToken
+	}
+	if revoked {
+		log.Debug().
+			Int64("user_id", claims.UserID).
+			Str("jti", claims.ID).
+			Msg("Rejected revoked token")
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// maybePurgeRevocations removes expired revocations in the background, at most
+// once per purge interval. Revocations only need to outlive the tokens they
+// reject, so the access token lifetime bounds how long cutoffs are kept.
+func (m *AuthMiddleware) maybePurgeRevocations() {
+	now := time.Now().Unix()
+	last := m.lastPurge.Load()
+	if now-last < int64(revocationPurgeInterval.Seconds()) || !m.lastPurge.CompareAndSwap(last, now) {
+		return
+	}
+
+	maxTokenLifetime := 24 * time.Hour
+	if m.jwtConfig != nil && m.jwtConfig.Expiration > 0 {
+		maxTokenLifetime = m.jwtConfig.Expiration
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		purged, err := m.revocationDAO.PurgeExpired(ctx, maxTokenLifetime)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to purge expired token revocations")
+			return
+		}
+		log.Debug().Int64("purged", purged).Msg("Purged expired token revocations")
+	}()
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateJWT_CarriesTokenID(t *testing.T) {
+	authMiddleware := newMFATestMiddleware(false)
+
+	first, err := GenerateJWT(&UserContext{UserID: 42}, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+	second, err := GenerateJWT(&UserContext{UserID: 42}, testSigningKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+
+	firstCtx, err := authMiddleware.extractTokenFromHumaInput(&AuthInput{Authorization: "Bearer " + first})
+	if err != nil {
+		t.Fatalf("Failed to extract user: %v", err)
+	}
+	secondCtx, err := authMiddleware.extractTokenFromHumaInput(&AuthInput{Authorization: "Bearer " + second})
+	if err != nil {
+		t.Fatalf("Failed to extract user: %v", err)
+	}
+
+	if firstCtx.TokenID == "" {
+		t.Error("Expected access token to carry a jti")
+	}
+	if firstCtx.TokenID == secondCtx.TokenID {
+		t.Error("Expected each access token to have a unique jti")
+	}
+	if firstCtx.TokenExpiresAt.IsZero() || time.Until(firstCtx.TokenExpiresAt) > time.Hour {
+		t.Errorf("Unexpected token expiry %v", firstCtx.TokenExpiresAt)
+	}
+}
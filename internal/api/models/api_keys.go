@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// APIKeyInfo describes an API key without its secret
+type APIKeyInfo struct {
+	KeyID       int64    `json:"key_id" example:"42"`
+	Name        string   `json:"name" example:"Digest bot"`
+	PseudonymID string   `json:"pseudonym_id" example:"a1b2c3..."`
+	Scopes      []string `json:"scopes" example:"posts:read,posts:write"`
+	Active      bool     `json:"active" example:"true"`
+	CreatedAt   string   `json:"created_at,omitempty" example:"2024-01-01T12:00:00Z"`
+	ExpiresAt   string   `json:"expires_at,omitempty" example:"2024-04-01T12:00:00Z"`
+	LastUsedAt  string   `json:"last_used_at,omitempty" example:"2024-01-02T08:30:00Z"`
+}
+
+// APIKeyCreateInput represents a request to create an API key
+type APIKeyCreateInput struct {
+	middleware.AuthInput
+	Body struct {
+		Name          string   `json:"name" minLength:"1" maxLength:"100" example:"Digest bot" doc:"Name to recognize the key by"`
+		PseudonymID   string   `json:"pseudonym_id" minLength:"1" example:"a1b2c3..." doc:"Pseudonym the key acts as; must belong to the caller"`
+		Scopes        []string `json:"scopes" minItems:"1" example:"posts:read,posts:write" doc:"Operations the key may call"`
+		ExpiresInDays int      `json:"expires_in_days,omitempty" minimum:"0" maximum:"365" example:"90" doc:"Days until the key expires; 0 for no expiry"`
+	}
+}
+
+// APIKeyListInput represents a request to list the current user's API keys
+type APIKeyListInput struct {
+	middleware.AuthInput
+	PseudonymID string `query:"pseudonym_id" example:"a1b2c3..." doc:"Only list keys of this pseudonym"`
+}
+
+// APIKeyIDInput represents a request on one of the current user's API keys
+type APIKeyIDInput struct {
+	middleware.AuthInput
+	KeyID int64 `path:"key_id" example:"42" doc:"API key ID"`
+}
+
+// APIKeySecretResponseBody represents the body of a response that carries a
+// key's secret. The secret is only ever returned here.
+type APIKeySecretResponseBody struct {
+	APIKeyInfo
+	Key string `json:"key" example:"hp_Zm9vYmFy..." doc:"The API key; it is shown only once"`
+}
+
+// APIKeySecretResponse represents a response carrying a new or rotated key
+type APIKeySecretResponse struct {
+	Status int                      `json:"-" example:"201"`
+	Body   APIKeySecretResponseBody `json:"body"`
+}
+
+// APIKeyListResponseBody represents the body of an API key list response
+type APIKeyListResponseBody struct {
+	Keys []APIKeyInfo `json:"keys"`
+}
+
+// APIKeyListResponse represents an API key list response
+type APIKeyListResponse struct {
+	Status int                    `json:"-" example:"200"`
+	Body   APIKeyListResponseBody `json:"body"`
+}
+
+// APIKeyRevokeResponseBody represents the body of an API key revocation response
+type APIKeyRevokeResponseBody struct {
+	KeyID   int64  `json:"key_id" example:"42"`
+	Message string `json:"message" example:"API key revoked"`
+}
+
+// APIKeyRevokeResponse represents an API key revocation response
+type APIKeyRevokeResponse struct {
+	Status int                      `json:"-" example:"200"`
+	Body   APIKeyRevokeResponseBody `json:"body"`
+}
+
+// NewAPIKeySecretResponse creates a response carrying a key's secret
+func NewAPIKeySecretResponse(status int, info APIKeyInfo, key string) *APIKeySecretResponse {
+	return &APIKeySecretResponse{
+		Status: status,
+		Body: APIKeySecretResponseBody{
+			APIKeyInfo: info,
+			Key:        key,
+		},
+	}
+}
+
+// NewAPIKeyListResponse creates a new API key list response
+func NewAPIKeyListResponse(keys []APIKeyInfo) *APIKeyListResponse {
+	return &APIKeyListResponse{
+		Status: 200,
+		Body: APIKeyListResponseBody{
+			Keys: keys,
+		},
+	}
+}
+
+// NewAPIKeyRevokeResponse creates a new API key revocation response
+func NewAPIKeyRevokeResponse(keyID int64) *APIKeyRevokeResponse {
+	return &APIKeyRevokeResponse{
+		Status: 200,
+		Body: APIKeyRevokeResponseBody{
+			KeyID:   keyID,
+			Message: "API key revoked",
+		},
+	}
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"net/http"
 	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 )
 
 // UserRegistrationBody represents the body of user registration request/response
@@ -59,9 +61,15 @@ type UserLogoutBody struct {
 
 // UserLogoutInput represents user logout request
 type UserLogoutInput struct {
+	middleware.AuthInput
 	Body UserLogoutBody `json:"body"`
 }
 
+// UserLogoutAllInput represents a request to log out of every session
+type UserLogoutAllInput struct {
+	middleware.AuthInput
+}
+
 // UserInfo represents user information in responses
 type UserInfo struct {
 	UserID       int      `json:"user_id" example:"123"`
@@ -107,6 +115,8 @@ type UserRegistrationResponseBody struct {
 	AccessToken  string   `json:"access_token" example:"jwt_token_here"`
 	RefreshToken string   `json:"refresh_token" example:"refresh_token_here"`
 	ExpiresIn    int      `json:"expires_in" example:"3600"`
+	// A verification link is mailed at registration
+	EmailVerified bool `json:"email_verified" example:"false"`
 }
 
 // UserRegistrationResponse represents user registration response
@@ -117,14 +127,15 @@ type UserRegistrationResponse struct {
 
 // UserLoginResponseBody represents the body of user login response
 type UserLoginResponseBody struct {
-	UserID       int      `json:"user_id" example:"123"`
-	Email        string   `json:"email" example:"user@example.com"`
-	CreatedAt    string   `json:"created_at" example:"2024-01-01T12:00:00Z"`
-	LastActiveAt string   `json:"last_active_at" example:"2024-01-01T18:00:00Z"`
-	IsActive     bool     `json:"is_active" example:"true"`
-	IsSuspended  bool     `json:"is_suspended" example:"false"`
-	Roles        []string `json:"roles" example:"[\"user\"]"`
-	Capabilities []string `json:"capabilities" example:"[\"create_content\",\"vote\",\"message\",\"report\"]"`
+	UserID        int      `json:"user_id" example:"123"`
+	Email         string   `json:"email" example:"user@example.com"`
+	CreatedAt     string   `json:"created_at" example:"2024-01-01T12:00:00Z"`
+	LastActiveAt  string   `json:"last_active_at" example:"2024-01-01T18:00:00Z"`
+	IsActive      bool     `json:"is_active" example:"true"`
+	IsSuspended   bool     `json:"is_suspended" example:"false"`
+	Roles         []string `json:"roles" example:"[\"user\"]"`
+	Capabilities  []string `json:"capabilities" example:"[\"create_content\",\"vote\",\"message\",\"report\"]"`
+	EmailVerified bool     `json:"email_verified" example:"true"`
 	// JWT tokens (also available in cookies)
 	AccessToken  string `json:"access_token" example:"jwt_access_token_here"`
 	RefreshToken string `json:"refresh_token" example:"jwt_refresh_token_here"`
@@ -132,12 +143,17 @@ type UserLoginResponseBody struct {
 	ActivePseudonymID string          `json:"active_pseudonym_id" example:"pseudonym_123"`
 	DisplayName       string          `json:"display_name" example:"User123"`
 	Pseudonyms        []PseudonymInfo `json:"pseudonyms"`
+	// MFA challenge, set instead of tokens when the account has MFA enabled
+	MFARequired  bool   `json:"mfa_required,omitempty" example:"false"`
+	MFAToken     string `json:"mfa_token,omitempty" example:"mfa_challenge_token_here"`
+	MFAExpiresIn int    `json:"mfa_expires_in,omitempty" example:"300"`
 }
 
 // TokenRefreshResponseBody represents the body of token refresh response
 type TokenRefreshResponseBody struct {
-	AccessToken string `json:"access_token" example:"new_jwt_token_here"`
-	ExpiresIn   int    `json:"expires_in" example:"3600"`
+	AccessToken  string `json:"access_token" example:"new_jwt_token_here"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"new_refresh_token_here" doc:"Replacement refresh token; the one presented is no longer valid"`
+	ExpiresIn    int    `json:"expires_in" example:"3600"`
 }
 
 // UserLoginResponse represents a successful user login response
@@ -185,25 +201,26 @@ type CurrentUserSessionResponse struct {
 }
 
 // NewUserRegistrationResponse creates a new user registration response
-func NewUserRegistrationResponse(userID int, email string, roles, capabilities []string, pseudonymID, displayName string, accessToken, refreshToken string) *UserRegistrationResponse {
+func NewUserRegistrationResponse(userID int, email string, roles, capabilities []string, pseudonymID, displayName string, accessToken, refreshToken string, emailVerified bool) *UserRegistrationResponse {
 	now := time.Now().UTC().Format(time.RFC3339)
 	return &UserRegistrationResponse{
 		Status: 200,
 		Body: UserRegistrationResponseBody{
-			UserID:       userID,
-			Email:        email,
-			CreatedAt:    now,
-			LastActiveAt: now,
-			IsActive:     true,
-			IsSuspended:  false,
-			Roles:        roles,
-			Capabilities: capabilities,
-			PseudonymID:  pseudonymID,
-			DisplayName:  displayName,
-			KarmaScore:   0,
-			AccessToken:  accessToken,
-			RefreshToken: refreshToken,
-			ExpiresIn:    3600,
+			UserID:        userID,
+			Email:         email,
+			CreatedAt:     now,
+			LastActiveAt:  now,
+			IsActive:      true,
+			IsSuspended:   false,
+			Roles:         roles,
+			Capabilities:  capabilities,
+			PseudonymID:   pseudonymID,
+			DisplayName:   displayName,
+			KarmaScore:    0,
+			AccessToken:   accessToken,
+			RefreshToken:  refreshToken,
+			ExpiresIn:     3600,
+			EmailVerified: emailVerified,
 		},
 	}
 }
@@ -254,6 +271,18 @@ func NewUserLoginResponse(accessToken, refreshToken string, userID int, email st
 	}
 }
 
+// NewMFAChallengeResponse creates a login response asking the client to complete the MFA step
+func NewMFAChallengeResponse(mfaToken string, expiresIn int) *UserLoginResponse {
+	return &UserLoginResponse{
+		Status: 200,
+		Body: UserLoginResponseBody{
+			MFARequired:  true,
+			MFAToken:     mfaToken,
+			MFAExpiresIn: expiresIn,
+		},
+	}
+}
+
 // NewTokenRefreshResponse creates a new token refresh response
 func NewTokenRefreshResponse(accessToken string, expiresIn int, isDevelopment bool) *TokenRefreshResponse {
 	// Create cookie for the new access token
@@ -278,6 +307,27 @@ func NewTokenRefreshResponse(accessToken string, expiresIn int, isDevelopment bo
 	}
 }
 
+// NewRotatedTokenResponse creates a token refresh response that also carries
+// the replacement refresh token issued by rotation
+func NewRotatedTokenResponse(accessToken, refreshToken string, expiresIn, refreshExpiresIn int, isDevelopment bool) *TokenRefreshResponse {
+	response := NewTokenRefreshResponse(accessToken, expiresIn, isDevelopment)
+	response.Body.RefreshToken = refreshToken
+
+	refreshCookie := http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/",
+		Domain:   "", // Empty domain means current domain
+		HttpOnly: true,
+		Secure:   !isDevelopment, // Secure only in production
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(time.Duration(refreshExpiresIn) * time.Second),
+	}
+	response.Cookies = append(response.Cookies, refreshCookie)
+
+	return response
+}
+
 // NewCurrentUserSessionResponse creates a new current user session response
 func NewCurrentUserSessionResponse(userID int, email string, roles, capabilities []string, activePseudonymID, displayName string, pseudonyms []PseudonymInfo) *CurrentUserSessionResponse {
 	return &CurrentUserSessionResponse{
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// ComplianceReportCreateInput represents a request to open a compliance case
+type ComplianceReportCreateInput struct {
+	middleware.AuthInput
+	Body struct {
+		ReportType          string `json:"report_type" enum:"court_order,subpoena,law_enforcement,internal_audit" example:"court_order" doc:"Kind of legal request"`
+		RequestingAuthority string `json:"requesting_authority,omitempty" maxLength:"255" example:"District Court for the Northern District" doc:"Authority the request comes from"`
+		RequestID           string `json:"request_id,omitempty" maxLength:"100" example:"2025-CV-0117" doc:"Reference of the request at the authority, such as a docket number"`
+		RequestDate         string `json:"request_date" format:"date" example:"2025-07-01" doc:"Date the request was received"`
+		DueDate             string `json:"due_date,omitempty" format:"date" example:"2025-07-15" doc:"Date the request must be answered by"`
+		ScopeDescription    string `json:"scope_description" minLength:"10" maxLength:"5000" example:"Account records of the pseudonym abc123def456" doc:"What the request covers"`
+		LegalBasis          string `json:"legal_basis,omitempty" maxLength:"5000" example:"18 U.S.C. 2703(d)" doc:"Legal basis of the request"`
+		AssignedUserID      int64  `json:"assigned_user_id,omitempty" example:"7" doc:"Member of staff handling the case; must have the legal_compliance capability"`
+		Notes               string `json:"notes,omitempty" maxLength:"5000" example:"Received by registered mail" doc:"Notes on the case"`
+		GagOrder            bool   `json:"gag_order,omitempty" example:"false" doc:"Whether the request forbids telling the affected users; withholds the disclosure notices of linked correlations"`
+	}
+}
+
+// ComplianceReportListInput represents a request to list compliance cases
+type ComplianceReportListInput struct {
+	middleware.AuthInput
+	Status         string `query:"status" enum:"pending,in_progress,completed,rejected" example:"pending" doc:"Only list cases with this status"`
+	AssignedUserID int64  `query:"assigned_user_id" example:"7" doc:"Only list cases assigned to this user"`
+	Overdue        bool   `query:"overdue" example:"true" doc:"Only list open cases past their due date"`
+}
+
+// ComplianceReportIDInput represents a request on a compliance case
+type ComplianceReportIDInput struct {
+	middleware.AuthInput
+	ReportID string `path:"report_id" example:"uuid_here" doc:"Compliance report ID"`
+}
+
+// ComplianceReportAssignInput represents a request to assign a compliance case
+type ComplianceReportAssignInput struct {
+	middleware.AuthInput
+	ReportID string `path:"report_id" example:"uuid_here" doc:"Compliance report ID"`
+	Body     struct {
+		AssignedUserID int64  `json:"assigned_user_id" example:"7" doc:"Member of staff handling the case; must have the legal_compliance capability"`
+		Note           string `json:"note,omitempty" maxLength:"1000" example:"Reassigned while on leave" doc:"Recorded in the history of the case"`
+	}
+}
+
+// ComplianceReportStatusInput represents a request to move a compliance case to another status
+type ComplianceReportStatusInput struct {
+	middleware.AuthInput
+	ReportID string `path:"report_id" example:"uuid_here" doc:"Compliance report ID"`
+	Body     struct {
+		Status string `json:"status" enum:"in_progress,completed,rejected" example:"in_progress" doc:"New status: pending cases move to in_progress or rejected, cases in progress to completed or rejected"`
+		Note   string `json:"note,omitempty" maxLength:"1000" example:"Records produced to the court" doc:"Recorded in the history of the case"`
+	}
+}
+
+// ComplianceCorrelationAttachInput represents a request to link an executed identity correlation to a compliance case
+type ComplianceCorrelationAttachInput struct {
+	middleware.AuthInput
+	ReportID string `path:"report_id" example:"uuid_here" doc:"Compliance report ID"`
+	Body     struct {
+		RequestID        string `json:"request_id" example:"uuid_here" doc:"Executed identity correlation request"`
+		CorrelationScope string `json:"correlation_scope,omitempty" maxLength:"1000" example:"Pseudonyms of the subscriber named in the order" doc:"What the correlation covered for the case; defaults to the scope of the request"`
+	}
+}
+
+// ComplianceBundleInput represents a request to export the disclosure bundle of a compliance case
+type ComplianceBundleInput struct {
+	middleware.AuthInput
+	ReportID string `path:"report_id" example:"uuid_here" doc:"Compliance report ID"`
+	Body     struct {
+		RecipientPublicKey string `json:"recipient_public_key,omitempty" maxLength:"2000" example:"-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VuAyEA...\n-----END PUBLIC KEY-----\n" doc:"PEM X25519 public key of the recipient; the bundle is encrypted to it when set"`
+	}
+}
+
+// ComplianceReport represents a compliance case
+type ComplianceReport struct {
+	ReportID            string `json:"report_id" example:"uuid_here"`
+	ReportType          string `json:"report_type" example:"court_order" doc:"court_order, subpoena, law_enforcement or internal_audit"`
+	RequestingAuthority string `json:"requesting_authority,omitempty" example:"District Court for the Northern District"`
+	RequestID           string `json:"request_id,omitempty" example:"2025-CV-0117"`
+	RequestDate         string `json:"request_date" example:"2025-07-01"`
+	DueDate             string `json:"due_date,omitempty" example:"2025-07-15"`
+	Status              string `json:"status" example:"pending" doc:"pending, in_progress, completed or rejected"`
+	Overdue             bool   `json:"overdue" example:"false" doc:"Whether the case is open after its due date"`
+	ScopeDescription    string `json:"scope_description" example:"Account records of the pseudonym abc123def456"`
+	LegalBasis          string `json:"legal_basis,omitempty" example:"18 U.S.C. 2703(d)"`
+	AssignedUserID      *int64 `json:"assigned_user_id,omitempty" example:"7"`
+	CreatedBy           *int64 `json:"created_by,omitempty" example:"7"`
+	Notes               string `json:"notes,omitempty" example:"Received by registered mail"`
+	GagOrder            bool   `json:"gag_order" example:"false"`
+	CreatedAt           string `json:"created_at" example:"2025-07-01T09:00:00Z"`
+	UpdatedAt           string `json:"updated_at" example:"2025-07-02T09:00:00Z"`
+	CompletedAt         string `json:"completed_at,omitempty" example:"2025-07-10T16:00:00Z" doc:"When the case was completed or rejected"`
+}
+
+// ComplianceCorrelation represents a correlation linked to a compliance case
+type ComplianceCorrelation struct {
+	AuditID            string `json:"audit_id" example:"audit_uuid_here" doc:"Correlation audit entry of the correlation"`
+	RequestID          string `json:"request_id,omitempty" example:"uuid_here" doc:"Identity correlation request, if the correlation was made through one"`
+	CorrelationType    string `json:"correlation_type" example:"identity"`
+	CorrelationScope   string `json:"correlation_scope" example:"platform_wide"`
+	RequestedPseudonym string `json:"requested_pseudonym" example:"abc123def456..."`
+	CorrelatedBy       int64  `json:"correlated_by" example:"12" doc:"Admin who performed the correlation"`
+	RoleUsed           string `json:"role_used" example:"site_admin"`
+	CorrelatedAt       string `json:"correlated_at,omitempty" example:"2025-07-03T10:00:00Z"`
+	LinkedAt           string `json:"linked_at" example:"2025-07-03T10:00:00Z"`
+}
+
+// ComplianceReportEvent represents an entry of the history of a compliance case
+type ComplianceReportEvent struct {
+	EventType      string `json:"event_type" example:"status_changed" doc:"opened, assigned, status_changed, correlation_attached or overdue"`
+	ActorID        *int64 `json:"actor_id,omitempty" example:"7" doc:"User who made the change; absent for alerts raised by the server"`
+	Status         string `json:"status,omitempty" example:"in_progress" doc:"Status of the case after the event"`
+	AssignedUserID *int64 `json:"assigned_user_id,omitempty" example:"7"`
+	AuditID        string `json:"audit_id,omitempty" example:"audit_uuid_here" doc:"Correlation that was linked"`
+	Note           string `json:"note,omitempty" example:"Records produced to the court"`
+	CreatedAt      string `json:"created_at" example:"2025-07-02T09:00:00Z"`
+}
+
+// ComplianceReportDetail represents a compliance case with its correlations and history
+type ComplianceReportDetail struct {
+	ComplianceReport
+	Correlations []ComplianceCorrelation `json:"correlations"`
+	History      []ComplianceReportEvent `json:"history"`
+}
+
+// ComplianceReportListResponseBody represents the body of a compliance case list response
+type ComplianceReportListResponseBody struct {
+	Reports []ComplianceReport `json:"reports"`
+}
+
+// ComplianceReportResponse represents a response carrying a compliance case
+type ComplianceReportResponse struct {
+	Status int              `json:"-" example:"200"`
+	Body   ComplianceReport `json:"body"`
+}
+
+// ComplianceReportDetailResponse represents a response carrying a compliance case with its correlations and history
+type ComplianceReportDetailResponse struct {
+	Status int                    `json:"-" example:"200"`
+	Body   ComplianceReportDetail `json:"body"`
+}
+
+// ComplianceReportListResponse represents a compliance case list response
+type ComplianceReportListResponse struct {
+	Status int                              `json:"-" example:"200"`
+	Body   ComplianceReportListResponseBody `json:"body"`
+}
+
+// ComplianceBundleResponse represents a disclosure bundle download
+type ComplianceBundleResponse struct {
+	Status             int    `json:"-" example:"200"`
+	ContentType        string `header:"Content-Type"`
+	ContentDisposition string `header:"Content-Disposition"`
+	BundleID           string `header:"X-Bundle-ID" doc:"ID of the bundle, as recorded in the history of the case"`
+	ManifestSHA256     string `header:"X-Manifest-SHA256" doc:"SHA-256 hash of the signed manifest, as recorded in the history of the case"`
+	Body               []byte
+}
+
+// NewComplianceBundleResponse creates a disclosure bundle download
+func NewComplianceBundleResponse(filename, bundleID, manifestSHA256 string, encrypted bool, data []byte) *ComplianceBundleResponse {
+	contentType := "application/gzip"
+	if encrypted {
+		contentType = "application/octet-stream"
+	}
+	return &ComplianceBundleResponse{
+		Status:             200,
+		ContentType:        contentType,
+		ContentDisposition: fmt.Sprintf("attachment; filename=%q", filename),
+		BundleID:           bundleID,
+		ManifestSHA256:     manifestSHA256,
+		Body:               data,
+	}
+}
+
+// NewComplianceReportResponse creates a response carrying a compliance case
+func NewComplianceReportResponse(status int, report ComplianceReport) *ComplianceReportResponse {
+	return &ComplianceReportResponse{
+		Status: status,
+		Body:   report,
+	}
+}
+
+// NewComplianceReportDetailResponse creates a response carrying a compliance case with its correlations and history
+func NewComplianceReportDetailResponse(detail ComplianceReportDetail) *ComplianceReportDetailResponse {
+	return &ComplianceReportDetailResponse{
+		Status: 200,
+		Body:   detail,
+	}
+}
+
+// NewComplianceReportListResponse creates a new compliance case list response
+func NewComplianceReportListResponse(reports []ComplianceReport) *ComplianceReportListResponse {
+	return &ComplianceReportListResponse{
+		Status: 200,
+		Body: ComplianceReportListResponseBody{
+			Reports: reports,
+		},
+	}
+}
@@ -22,6 +22,7 @@ type IdentityCorrelationInputBody struct {
 	LegalBasis           string `json:"legal_basis" example:"Platform Terms of Service" required:"true"`
 	IncidentID           string `json:"incident_id" example:"harassment_case_123" required:"true"`
 	Scope                string `json:"scope" example:"platform_wide" required:"true"`
+	ComplianceReportID   string `json:"compliance_report_id,omitempty" example:"uuid_here" doc:"Open compliance case the correlation is made for; the correlation is linked to it once executed"`
 }
 
 // IdentityCorrelationInput represents identity correlation request (for OpenAPI schema only)
@@ -29,6 +30,24 @@ type IdentityCorrelationInput struct {
 	Body IdentityCorrelationInputBody `json:"body"`
 }
 
+// CorrelationRequestListInput represents a request to list identity correlation requests
+type CorrelationRequestListInput struct {
+	Status string `query:"status" enum:"pending,approved,rejected,executed,expired" example:"pending" doc:"Only list requests with this status"`
+}
+
+// CorrelationRequestIDInput represents a request on an identity correlation request
+type CorrelationRequestIDInput struct {
+	RequestID string `path:"request_id" example:"uuid_here" doc:"Correlation request ID"`
+}
+
+// CorrelationReviewInput represents the approval or rejection of an identity correlation request
+type CorrelationReviewInput struct {
+	RequestID string `path:"request_id" example:"uuid_here" doc:"Correlation request ID"`
+	Body      struct {
+		Comment string `json:"comment,omitempty" maxLength:"1000" example:"Legal basis verified against the court order" doc:"Reason for the decision"`
+	}
+}
+
 // CorrelationHistoryInput represents correlation history request parameters
 type CorrelationHistoryInput struct {
 	CorrelationType string `query:"correlation_type" example:"fingerprint"` // "fingerprint", "identity"
@@ -58,6 +77,29 @@ type Correlation struct {
 	Status             string `json:"status" example:"completed"`
 	Timestamp          string `json:"timestamp" example:"2024-01-01T16:00:00Z"`
 	ResultsCount       int    `json:"results_count" example:"2"`
+	RequestID          string `json:"request_id,omitempty" example:"uuid_here"`
+}
+
+// CorrelationRequest represents an identity correlation request and its review
+type CorrelationRequest struct {
+	RequestID          string `json:"request_id" example:"uuid_here"`
+	CorrelationType    string `json:"correlation_type" example:"identity"`
+	Status             string `json:"status" example:"pending" doc:"pending, approved, rejected, executed or expired"`
+	RequestedPseudonym string `json:"requested_pseudonym" example:"abc123def456..."`
+	Scope              string `json:"scope" example:"platform_wide"`
+	Justification      string `json:"justification" example:"Investigation of reported harassment across subforums"`
+	LegalBasis         string `json:"legal_basis" example:"Platform Terms of Service"`
+	IncidentID         string `json:"incident_id" example:"harassment_case_123"`
+	RequesterID        int64  `json:"requester_id" example:"12"`
+	RequesterRole      string `json:"requester_role" example:"trust_safety"`
+	ReviewerID         *int64 `json:"reviewer_id,omitempty" example:"7"`
+	ReviewerRole       string `json:"reviewer_role,omitempty" example:"legal_team"`
+	ReviewComment      string `json:"review_comment,omitempty" example:"Legal basis verified against the court order"`
+	CreatedAt          string `json:"created_at" example:"2024-01-01T16:00:00Z"`
+	ExpiresAt          string `json:"expires_at" example:"2024-01-04T16:00:00Z" doc:"Review deadline while pending, execution deadline once approved"`
+	ReviewedAt         string `json:"reviewed_at,omitempty" example:"2024-01-02T09:00:00Z"`
+	ExecutedAt         string `json:"executed_at,omitempty" example:"2024-01-02T09:30:00Z"`
+	ComplianceReportID string `json:"compliance_report_id,omitempty" example:"uuid_here" doc:"Compliance case the correlation is made for"`
 }
 
 // FingerprintCorrelationResponseBody represents the body of fingerprint correlation response
@@ -82,6 +124,11 @@ type IdentityCorrelationResponseBody struct {
 	AuditID         string              `json:"audit_id" example:"audit_uuid_here"`
 }
 
+// CorrelationRequestListResponseBody represents the body of a correlation request list response
+type CorrelationRequestListResponseBody struct {
+	Requests []CorrelationRequest `json:"requests"`
+}
+
 // CorrelationHistoryResponseBody represents the body of correlation history response
 type CorrelationHistoryResponseBody struct {
 	Correlations []Correlation `json:"correlations"`
@@ -100,6 +147,18 @@ type IdentityCorrelationResponse struct {
 	Body   IdentityCorrelationResponseBody `json:"body"`
 }
 
+// CorrelationRequestResponse represents a response carrying a correlation request
+type CorrelationRequestResponse struct {
+	Status int                `json:"-" example:"202"`
+	Body   CorrelationRequest `json:"body"`
+}
+
+// CorrelationRequestListResponse represents a correlation request list response
+type CorrelationRequestListResponse struct {
+	Status int                                `json:"-" example:"200"`
+	Body   CorrelationRequestListResponseBody `json:"body"`
+}
+
 // CorrelationHistoryResponse represents correlation history response
 type CorrelationHistoryResponse struct {
 	Status int                            `json:"-" example:"200"`
@@ -107,14 +166,14 @@ type CorrelationHistoryResponse struct {
 }
 
 // NewFingerprintCorrelationResponse creates a new fingerprint correlation response
-func NewFingerprintCorrelationResponse(correlationID string, results []CorrelationResult, auditID string) *FingerprintCorrelationResponse {
+func NewFingerprintCorrelationResponse(correlationID, scope, timeWindow string, results []CorrelationResult, auditID string) *FingerprintCorrelationResponse {
 	return &FingerprintCorrelationResponse{
 		Status: 200,
 		Body: FingerprintCorrelationResponseBody{
 			CorrelationID:   correlationID,
 			CorrelationType: "fingerprint",
-			Scope:           "subforum_specific",
-			TimeWindow:      "30_days",
+			Scope:           scope,
+			TimeWindow:      timeWindow,
 			Status:          "completed",
 			Results:         results,
 			AuditID:         auditID,
@@ -123,14 +182,14 @@ func NewFingerprintCorrelationResponse(correlationID string, results []Correlati
 }
 
 // NewIdentityCorrelationResponse creates a new identity correlation response
-func NewIdentityCorrelationResponse(correlationID string, results []CorrelationResult, auditID string) *IdentityCorrelationResponse {
+func NewIdentityCorrelationResponse(correlationID, scope, timeWindow string, results []CorrelationResult, auditID string) *IdentityCorrelationResponse {
 	return &IdentityCorrelationResponse{
 		Status: 200,
 		Body: IdentityCorrelationResponseBody{
 			CorrelationID:   correlationID,
 			CorrelationType: "identity",
-			Scope:           "platform_wide",
-			TimeWindow:      "unlimited",
+			Scope:           scope,
+			TimeWindow:      timeWindow,
 			Status:          "completed",
 			Results:         results,
 			AuditID:         auditID,
@@ -138,6 +197,24 @@ func NewIdentityCorrelationResponse(correlationID string, results []CorrelationR
 	}
 }
 
+// NewCorrelationRequestResponse creates a response carrying a correlation request
+func NewCorrelationRequestResponse(status int, request CorrelationRequest) *CorrelationRequestResponse {
+	return &CorrelationRequestResponse{
+		Status: status,
+		Body:   request,
+	}
+}
+
+// NewCorrelationRequestListResponse creates a new correlation request list response
+func NewCorrelationRequestListResponse(requests []CorrelationRequest) *CorrelationRequestListResponse {
+	return &CorrelationRequestListResponse{
+		Status: 200,
+		Body: CorrelationRequestListResponseBody{
+			Requests: requests,
+		},
+	}
+}
+
 // NewCorrelationHistoryResponse creates a new correlation history response
 func NewCorrelationHistoryResponse(correlations []Correlation, page, limit, total int) *CorrelationHistoryResponse {
 	pages := (total + limit - 1) / limit // Ceiling division
@@ -155,3 +232,120 @@ func NewCorrelationHistoryResponse(correlations []Correlation, page, limit, tota
 		},
 	}
 }
+
+// BreakGlassCorrelationInputBody represents the body of an emergency identity correlation
+type BreakGlassCorrelationInputBody struct {
+	RequestedPseudonym   string `json:"requested_pseudonym" example:"abc123def456..." required:"true"`
+	RequestedFingerprint string `json:"requested_fingerprint,omitempty" example:"a1b2c3d4e5f6..."`
+	ReasonCode           string `json:"reason_code" enum:"threat_of_violence,self_harm,child_safety,terrorism,law_enforcement_emergency" example:"threat_of_violence" required:"true" doc:"Emergency that cannot wait for approval"`
+	Justification        string `json:"justification" minLength:"1" example:"Credible threat of violence against a named person" required:"true"`
+	IncidentID           string `json:"incident_id" minLength:"1" example:"threat_case_42" required:"true"`
+}
+
+// BreakGlassCorrelationInput represents an emergency identity correlation request (for OpenAPI schema only)
+type BreakGlassCorrelationInput struct {
+	Body BreakGlassCorrelationInputBody `json:"body"`
+}
+
+// BreakGlassReviewListInput represents a request to list break-glass reviews
+type BreakGlassReviewListInput struct {
+	Status string `query:"status" enum:"pending,signed_off,rejected,overdue" example:"pending" doc:"Only list reviews with this status"`
+}
+
+// BreakGlassReviewInput represents the sign-off or rejection of a break-glass correlation
+type BreakGlassReviewInput struct {
+	ReviewID string `path:"review_id" example:"uuid_here" doc:"Break-glass review ID"`
+	Body     struct {
+		Comment string `json:"comment,omitempty" maxLength:"1000" example:"Threat confirmed by the police report" doc:"Reason for the decision"`
+	}
+}
+
+// BreakGlassReview represents an emergency identity correlation and its post-hoc review
+type BreakGlassReview struct {
+	ReviewID           string `json:"review_id" example:"uuid_here"`
+	Status             string `json:"status" example:"pending" doc:"pending, signed_off, rejected or overdue"`
+	RequestedPseudonym string `json:"requested_pseudonym" example:"abc123def456..."`
+	ReasonCode         string `json:"reason_code" example:"threat_of_violence"`
+	Justification      string `json:"justification" example:"Credible threat of violence against a named person"`
+	IncidentID         string `json:"incident_id" example:"threat_case_42"`
+	RequesterID        int64  `json:"requester_id" example:"12"`
+	RequesterRole      string `json:"requester_role" example:"trust_safety"`
+	AuditID            string `json:"audit_id,omitempty" example:"audit_uuid_here"`
+	ReviewerID         *int64 `json:"reviewer_id,omitempty" example:"7"`
+	ReviewerRole       string `json:"reviewer_role,omitempty" example:"legal_team"`
+	ReviewComment      string `json:"review_comment,omitempty" example:"Threat confirmed by the police report"`
+	CreatedAt          string `json:"created_at" example:"2024-01-01T16:00:00Z"`
+	ReviewDueAt        string `json:"review_due_at" example:"2024-01-02T16:00:00Z" doc:"Deadline of the review; the requester is flagged after it"`
+	ReviewedAt         string `json:"reviewed_at,omitempty" example:"2024-01-02T09:00:00Z"`
+	FlaggedAt          string `json:"flagged_at,omitempty" example:"2024-01-02T16:00:00Z" doc:"When the use flagged the requester's account"`
+}
+
+// BreakGlassCorrelationResponseBody represents the body of an emergency identity correlation response
+type BreakGlassCorrelationResponseBody struct {
+	CorrelationID   string              `json:"correlation_id" example:"uuid_here"`
+	CorrelationType string              `json:"correlation_type" example:"identity"`
+	Scope           string              `json:"scope" example:"platform_wide"`
+	TimeWindow      string              `json:"time_window" example:"unlimited"`
+	Status          string              `json:"status" example:"completed"`
+	Results         []CorrelationResult `json:"results"`
+	AuditID         string              `json:"audit_id" example:"audit_uuid_here"`
+	Review          BreakGlassReview    `json:"review"`
+}
+
+// BreakGlassReviewListResponseBody represents the body of a break-glass review list response
+type BreakGlassReviewListResponseBody struct {
+	Reviews []BreakGlassReview `json:"reviews"`
+}
+
+// BreakGlassCorrelationResponse represents an emergency identity correlation response
+type BreakGlassCorrelationResponse struct {
+	Status int                               `json:"-" example:"200"`
+	Body   BreakGlassCorrelationResponseBody `json:"body"`
+}
+
+// BreakGlassReviewResponse represents a response carrying a break-glass review
+type BreakGlassReviewResponse struct {
+	Status int              `json:"-" example:"200"`
+	Body   BreakGlassReview `json:"body"`
+}
+
+// BreakGlassReviewListResponse represents a break-glass review list response
+type BreakGlassReviewListResponse struct {
+	Status int                              `json:"-" example:"200"`
+	Body   BreakGlassReviewListResponseBody `json:"body"`
+}
+
+// NewBreakGlassCorrelationResponse creates a new emergency identity correlation response
+func NewBreakGlassCorrelationResponse(scope, timeWindow string, results []CorrelationResult, auditID string, review BreakGlassReview) *BreakGlassCorrelationResponse {
+	return &BreakGlassCorrelationResponse{
+		Status: 200,
+		Body: BreakGlassCorrelationResponseBody{
+			CorrelationID:   review.ReviewID,
+			CorrelationType: "identity",
+			Scope:           scope,
+			TimeWindow:      timeWindow,
+			Status:          "completed",
+			Results:         results,
+			AuditID:         auditID,
+			Review:          review,
+		},
+	}
+}
+
+// NewBreakGlassReviewResponse creates a response carrying a break-glass review
+func NewBreakGlassReviewResponse(review BreakGlassReview) *BreakGlassReviewResponse {
+	return &BreakGlassReviewResponse{
+		Status: 200,
+		Body:   review,
+	}
+}
+
+// NewBreakGlassReviewListResponse creates a new break-glass review list response
+func NewBreakGlassReviewListResponse(reviews []BreakGlassReview) *BreakGlassReviewListResponse {
+	return &BreakGlassReviewListResponse{
+		Status: 200,
+		Body: BreakGlassReviewListResponseBody{
+			Reviews: reviews,
+		},
+	}
+}
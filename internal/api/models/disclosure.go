@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// DisclosureNoticeListInput represents a request for the disclosure notices of one of the current user's pseudonyms
+type DisclosureNoticeListInput struct {
+	middleware.AuthInput
+	PseudonymID string `path:"pseudonym_id" example:"abc123def456..." doc:"Pseudonym ID"`
+}
+
+// AdminDisclosureNoticeListInput represents an admin request to list disclosure notices
+type AdminDisclosureNoticeListInput struct {
+	middleware.AuthInput
+	PseudonymID string `query:"pseudonym_id" example:"abc123def456..." doc:"Only list notices of this pseudonym"`
+	Status      string `query:"status" enum:"embargoed,gagged,available,delivered" example:"embargoed" doc:"Only list notices with this status"`
+}
+
+// DisclosureEmbargoExtendInput represents an admin request to extend the embargo of a disclosure notice
+type DisclosureEmbargoExtendInput struct {
+	middleware.AuthInput
+	NoticeID string `path:"notice_id" example:"uuid_here" doc:"Disclosure notice ID"`
+	Body     struct {
+		EmbargoUntil  time.Time `json:"embargo_until" example:"2025-01-01T00:00:00Z" doc:"New end of the embargo; must be later than the current one"`
+		Justification string    `json:"justification" minLength:"10" maxLength:"1000" example:"Disclosure would compromise the ongoing investigation" doc:"Reason for the extension"`
+	}
+}
+
+// DisclosureEmbargoLiftInput represents an admin request to lift the embargo of a disclosure notice
+type DisclosureEmbargoLiftInput struct {
+	middleware.AuthInput
+	NoticeID string `path:"notice_id" example:"uuid_here" doc:"Disclosure notice ID"`
+	Body     struct {
+		Justification string `json:"justification" minLength:"10" maxLength:"1000" example:"Investigation closed" doc:"Reason for lifting the embargo"`
+	}
+}
+
+// DisclosureNotice tells the owner of a pseudonym that it was correlated
+type DisclosureNotice struct {
+	NoticeID           string `json:"notice_id" example:"uuid_here"`
+	PseudonymID        string `json:"pseudonym_id" example:"abc123def456..." doc:"The correlated pseudonym"`
+	CorrelatedOn       string `json:"correlated_on" example:"2024-01-01" doc:"Date of the correlation"`
+	RoleType           string `json:"role_type" example:"moderator" doc:"Type of role that performed the correlation"`
+	LegalBasisCategory string `json:"legal_basis_category" example:"moderation" doc:"moderation, platform_investigation, or the type of the compliance request: court_order, subpoena, law_enforcement or internal_audit"`
+}
+
+// AdminDisclosureNotice represents a disclosure notice and its embargo for admins
+type AdminDisclosureNotice struct {
+	DisclosureNotice
+	AuditID         string `json:"audit_id" example:"audit_uuid_here" doc:"Correlation audit entry the notice is about"`
+	CorrelationType string `json:"correlation_type" example:"fingerprint"`
+	Status          string `json:"status" example:"embargoed" doc:"embargoed, gagged, available or delivered"`
+	EmbargoUntil    string `json:"embargo_until" example:"2024-03-31T16:00:00Z"`
+	DeliveredAt     string `json:"delivered_at,omitempty" example:"2024-04-02T08:00:00Z"`
+}
+
+// DisclosureNoticeListResponseBody represents the body of a disclosure notice list response
+type DisclosureNoticeListResponseBody struct {
+	Notices []DisclosureNotice `json:"notices"`
+}
+
+// AdminDisclosureNoticeListResponseBody represents the body of an admin disclosure notice list response
+type AdminDisclosureNoticeListResponseBody struct {
+	Notices []AdminDisclosureNotice `json:"notices"`
+}
+
+// DisclosureNoticeListResponse represents a disclosure notice list response
+type DisclosureNoticeListResponse struct {
+	Status int                              `json:"-" example:"200"`
+	Body   DisclosureNoticeListResponseBody `json:"body"`
+}
+
+// AdminDisclosureNoticeListResponse represents an admin disclosure notice list response
+type AdminDisclosureNoticeListResponse struct {
+	Status int                                   `json:"-" example:"200"`
+	Body   AdminDisclosureNoticeListResponseBody `json:"body"`
+}
+
+// AdminDisclosureNoticeResponse represents a response carrying a disclosure notice
+type AdminDisclosureNoticeResponse struct {
+	Status int                   `json:"-" example:"200"`
+	Body   AdminDisclosureNotice `json:"body"`
+}
+
+// NewDisclosureNoticeListResponse creates a new disclosure notice list response
+func NewDisclosureNoticeListResponse(notices []DisclosureNotice) *DisclosureNoticeListResponse {
+	return &DisclosureNoticeListResponse{
+		Status: 200,
+		Body: DisclosureNoticeListResponseBody{
+			Notices: notices,
+		},
+	}
+}
+
+// NewAdminDisclosureNoticeListResponse creates a new admin disclosure notice list response
+func NewAdminDisclosureNoticeListResponse(notices []AdminDisclosureNotice) *AdminDisclosureNoticeListResponse {
+	return &AdminDisclosureNoticeListResponse{
+		Status: 200,
+		Body: AdminDisclosureNoticeListResponseBody{
+			Notices: notices,
+		},
+	}
+}
+
+// NewAdminDisclosureNoticeResponse creates a response carrying a disclosure notice
+func NewAdminDisclosureNoticeResponse(notice AdminDisclosureNotice) *AdminDisclosureNoticeResponse {
+	return &AdminDisclosureNoticeResponse{
+		Status: 200,
+		Body:   notice,
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// EmailVerificationBody carries the token from an email verification link
+type EmailVerificationBody struct {
+	Token string `json:"token" example:"verification_token_here" doc:"Token from the verification link"`
+}
+
+// EmailVerificationInput represents a request to verify an email address
+type EmailVerificationInput struct {
+	Body EmailVerificationBody `json:"body"`
+}
+
+// EmailVerificationResendInput represents a request to mail a new verification link
+type EmailVerificationResendInput struct {
+	middleware.AuthInput
+}
+
+// EmailVerificationResponseBody reports the verification state of an address
+type EmailVerificationResponseBody struct {
+	Email         string `json:"email" example:"user@example.com"`
+	EmailVerified bool   `json:"email_verified" example:"true"`
+}
+
+// EmailVerificationResponse represents the response to verifying an email address
+type EmailVerificationResponse struct {
+	Status int                           `json:"-" example:"200"`
+	Body   EmailVerificationResponseBody `json:"body"`
+}
+
+// PasswordResetRequestBody identifies the account to send a reset link to
+type PasswordResetRequestBody struct {
+	Email string `json:"email" example:"user@example.com"`
+}
+
+// PasswordResetRequestInput represents a request for a password reset link
+type PasswordResetRequestInput struct {
+	Body PasswordResetRequestBody `json:"body"`
+}
+
+// PasswordResetBody carries the token from a reset link and the new password
+type PasswordResetBody struct {
+	Token       string `json:"token" example:"reset_token_here" doc:"Token from the password reset link"`
+	NewPassword string `json:"new_password" example:"new_secure_password"`
+}
+
+// PasswordResetInput represents a request to set a new password with a reset token
+type PasswordResetInput struct {
+	Body PasswordResetBody `json:"body"`
+}
+
+// EmailAcceptedResponseBody acknowledges a request that may send an email
+type EmailAcceptedResponseBody struct {
+	Message string `json:"message" example:"If the address belongs to an account, a link has been sent to it."`
+}
+
+// EmailAcceptedResponse represents the response to a request that may send an email
+type EmailAcceptedResponse struct {
+	Status int                       `json:"-" example:"202"`
+	Body   EmailAcceptedResponseBody `json:"body"`
+}
+
+// PasswordResetResponseBody confirms a password change
+type PasswordResetResponseBody struct {
+	Message string `json:"message" example:"Password updated. Sign in with your new password."`
+}
+
+// PasswordResetResponse represents the response to resetting a password
+type PasswordResetResponse struct {
+	Status int                       `json:"-" example:"200"`
+	Body   PasswordResetResponseBody `json:"body"`
+}
+
+// NewEmailVerificationResponse creates a new email verification response
+func NewEmailVerificationResponse(email string, verified bool) *EmailVerificationResponse {
+	return &EmailVerificationResponse{
+		Status: 200,
+		Body: EmailVerificationResponseBody{
+			Email:         email,
+			EmailVerified: verified,
+		},
+	}
+}
+
+// NewEmailAcceptedResponse creates a new response for a request that may send an email
+func NewEmailAcceptedResponse(message string) *EmailAcceptedResponse {
+	return &EmailAcceptedResponse{
+		Status: 202,
+		Body: EmailAcceptedResponseBody{
+			Message: message,
+		},
+	}
+}
+
+// NewPasswordResetResponse creates a new password reset response
+func NewPasswordResetResponse() *PasswordResetResponse {
+	return &PasswordResetResponse{
+		Status: 200,
+		Body: PasswordResetResponseBody{
+			Message: "Password updated. Sign in with your new password.",
+		},
+	}
+}
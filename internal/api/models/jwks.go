@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+)
+
+// JWKSInput represents the JWKS request (empty for GET)
+type JWKSInput struct{}
+
+// JWKSResponse represents the published token verification keys
+type JWKSResponse struct {
+	Status       int          `json:"-" example:"200"`
+	CacheControl string       `header:"Cache-Control"`
+	Body         jwtkeys.JWKS `json:"body"`
+}
+
+// NewJWKSResponse creates a new JWKS response that verifiers may cache for maxAge seconds
+func NewJWKSResponse(keys jwtkeys.JWKS, maxAge int) *JWKSResponse {
+	return &JWKSResponse{
+		Status:       200,
+		CacheControl: fmt.Sprintf("public, max-age=%d", maxAge),
+		Body:         keys,
+	}
+}
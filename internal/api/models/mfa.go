@@ -0,0 +1,129 @@
+package models
+
+import (
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// MFACodeBody carries a second factor: either a TOTP code or a recovery code
+type MFACodeBody struct {
+	Code         string `json:"code,omitempty" example:"123456" doc:"Current code from the authenticator app"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"abcde-fghjk" doc:"Single-use recovery code, used instead of code"`
+}
+
+// MFAEnrollmentInput represents a request to start TOTP enrollment
+type MFAEnrollmentInput struct {
+	middleware.AuthInput
+}
+
+// MFAEnrollmentResponseBody contains the pending TOTP secret
+type MFAEnrollmentResponseBody struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP" doc:"Base32 TOTP secret for manual entry"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/HashPost:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=HashPost" doc:"Provisioning URI, typically rendered as a QR code"`
+	Digits     int    `json:"digits" example:"6"`
+	Period     int    `json:"period" example:"30"`
+}
+
+// MFAEnrollmentResponse represents the response to starting TOTP enrollment
+type MFAEnrollmentResponse struct {
+	Status int                       `json:"-" example:"200"`
+	Body   MFAEnrollmentResponseBody `json:"body"`
+}
+
+// MFAConfirmBody carries the first code from the authenticator app
+type MFAConfirmBody struct {
+	Code string `json:"code" example:"123456"`
+}
+
+// MFAConfirmInput represents a request to confirm TOTP enrollment
+type MFAConfirmInput struct {
+	middleware.AuthInput
+	Body MFAConfirmBody `json:"body"`
+}
+
+// MFARecoveryCodesResponseBody contains freshly issued recovery codes
+type MFARecoveryCodesResponseBody struct {
+	MFAEnabled    bool     `json:"mfa_enabled" example:"true"`
+	RecoveryCodes []string `json:"recovery_codes" doc:"Single-use recovery codes. They are shown only once."`
+}
+
+// MFARecoveryCodesResponse represents a response that issues recovery codes
+type MFARecoveryCodesResponse struct {
+	Status int                          `json:"-" example:"200"`
+	Body   MFARecoveryCodesResponseBody `json:"body"`
+}
+
+// MFALoginBody represents the second step of login for users with MFA enabled
+type MFALoginBody struct {
+	MFAToken string `json:"mfa_token" example:"mfa_challenge_token_here" doc:"Token returned by the password step of login"`
+	MFACodeBody
+}
+
+// MFALoginInput represents the second step of login
+type MFALoginInput struct {
+	Body MFALoginBody `json:"body"`
+}
+
+// MFAStepUpInput represents a request to prove MFA for a sensitive action
+type MFAStepUpInput struct {
+	middleware.AuthInput
+	Body MFACodeBody `json:"body"`
+}
+
+// MFARegenerateRecoveryCodesInput represents a request to replace recovery codes
+type MFARegenerateRecoveryCodesInput struct {
+	middleware.AuthInput
+	Body MFACodeBody `json:"body"`
+}
+
+// MFADisableInput represents a request to turn off MFA
+type MFADisableInput struct {
+	middleware.AuthInput
+	Body MFACodeBody `json:"body"`
+}
+
+// MFADisableResponseBody represents the body of the disable MFA response
+type MFADisableResponseBody struct {
+	MFAEnabled bool   `json:"mfa_enabled" example:"false"`
+	Message    string `json:"message" example:"Multi-factor authentication disabled"`
+}
+
+// MFADisableResponse represents the response to disabling MFA
+type MFADisableResponse struct {
+	Status int                    `json:"-" example:"200"`
+	Body   MFADisableResponseBody `json:"body"`
+}
+
+// NewMFAEnrollmentResponse creates a new MFA enrollment response
+func NewMFAEnrollmentResponse(secret, uri string, digits, period int) *MFAEnrollmentResponse {
+	return &MFAEnrollmentResponse{
+		Status: 200,
+		Body: MFAEnrollmentResponseBody{
+			Secret:     secret,
+			OTPAuthURI: uri,
+			Digits:     digits,
+			Period:     period,
+		},
+	}
+}
+
+// NewMFARecoveryCodesResponse creates a new response carrying recovery codes
+func NewMFARecoveryCodesResponse(codes []string) *MFARecoveryCodesResponse {
+	return &MFARecoveryCodesResponse{
+		Status: 200,
+		Body: MFARecoveryCodesResponseBody{
+			MFAEnabled:    true,
+			RecoveryCodes: codes,
+		},
+	}
+}
+
+// NewMFADisableResponse creates a new disable MFA response
+func NewMFADisableResponse() *MFADisableResponse {
+	return &MFADisableResponse{
+		Status: 200,
+		Body: MFADisableResponseBody{
+			MFAEnabled: false,
+			Message:    "Multi-factor authentication disabled",
+		},
+	}
+}
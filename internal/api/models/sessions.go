@@ -0,0 +1,97 @@
+package models
+
+import (
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+)
+
+// SessionInfo describes one of a user's login sessions
+type SessionInfo struct {
+	SessionID       string `json:"session_id" example:"3f9a1c..."`
+	UserAgentFamily string `json:"user_agent_family,omitempty" example:"Firefox" doc:"Browser family of the client that logged in"`
+	IPPrefix        string `json:"ip_prefix,omitempty" example:"203.0.113.0/24"`
+	CreatedAt       string `json:"created_at" example:"2024-01-01T12:00:00Z"`
+	LastSeenAt      string `json:"last_seen_at" example:"2024-01-01T18:00:00Z"`
+	Current         bool   `json:"current" example:"true"`
+}
+
+// SessionListInput represents a request to list the current user's sessions
+type SessionListInput struct {
+	middleware.AuthInput
+}
+
+// SessionRevokeInput represents a request to revoke one of the current user's sessions
+type SessionRevokeInput struct {
+	middleware.AuthInput
+	SessionID string `path:"session_id" example:"3f9a1c..." doc:"Session ID"`
+}
+
+// SessionRevokeAllInput represents a request to revoke the current user's sessions
+type SessionRevokeAllInput struct {
+	middleware.AuthInput
+	IncludeCurrent bool `query:"include_current" example:"false" doc:"Also revoke the session making the request"`
+}
+
+// AdminSessionListInput represents an admin request to list a user's sessions
+type AdminSessionListInput struct {
+	middleware.AuthInput
+	UserID int64 `path:"user_id" example:"123" doc:"User ID"`
+}
+
+// AdminSessionRevokeInput represents an admin request to revoke one of a user's sessions
+type AdminSessionRevokeInput struct {
+	middleware.AuthInput
+	UserID    int64  `path:"user_id" example:"123" doc:"User ID"`
+	SessionID string `path:"session_id" example:"3f9a1c..." doc:"Session ID"`
+}
+
+// AdminSessionRevokeAllInput represents an admin request to revoke all of a user's sessions
+type AdminSessionRevokeAllInput struct {
+	middleware.AuthInput
+	UserID int64 `path:"user_id" example:"123" doc:"User ID"`
+}
+
+// SessionListResponseBody represents the body of a session list response
+type SessionListResponseBody struct {
+	UserID   int64         `json:"user_id" example:"123"`
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// SessionListResponse represents a session list response
+type SessionListResponse struct {
+	Status int                     `json:"-" example:"200"`
+	Body   SessionListResponseBody `json:"body"`
+}
+
+// SessionRevokeResponseBody represents the body of a session revocation response
+type SessionRevokeResponseBody struct {
+	RevokedSessions int    `json:"revoked_sessions" example:"1"`
+	Message         string `json:"message" example:"Sessions revoked"`
+}
+
+// SessionRevokeResponse represents a session revocation response
+type SessionRevokeResponse struct {
+	Status int                       `json:"-" example:"200"`
+	Body   SessionRevokeResponseBody `json:"body"`
+}
+
+// NewSessionListResponse creates a new session list response
+func NewSessionListResponse(userID int64, sessions []SessionInfo) *SessionListResponse {
+	return &SessionListResponse{
+		Status: 200,
+		Body: SessionListResponseBody{
+			UserID:   userID,
+			Sessions: sessions,
+		},
+	}
+}
+
+// NewSessionRevokeResponse creates a new session revocation response
+func NewSessionRevokeResponse(revoked int) *SessionRevokeResponse {
+	return &SessionRevokeResponse{
+		Status: 200,
+		Body: SessionRevokeResponseBody{
+			RevokedSessions: revoked,
+			Message:         "Sessions revoked",
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/stephenafamo/bob"
+)
+
+// RegisterAPIKeyRoutes registers self-service API key management routes
+func RegisterAPIKeyRoutes(api huma.API, db bob.Executor, securePseudonymDAO *dao.SecurePseudonymDAO) {
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, securePseudonymDAO)
+
+	// Create an API key
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-api-key",
+		Method:        http.MethodPost,
+		Path:          "/api-keys",
+		Summary:       "Create an API key",
+		Description:   "Creates an API key that acts as one of the authenticated user's pseudonyms, limited to the given scopes. The key is returned only in this response.",
+		Tags:          []string{"API Keys"},
+		Security:      []map[string][]string{{"jwt": {}}},
+		DefaultStatus: http.StatusCreated,
+	}, apiKeyHandler.CreateAPIKey)
+
+	// List API keys
+	huma.Register(api, huma.Operation{
+		OperationID: "list-api-keys",
+		Method:      http.MethodGet,
+		Path:        "/api-keys",
+		Summary:     "List API keys",
+		Description: "Lists the API keys of the authenticated user's pseudonyms with their scopes, expiry and last use",
+		Tags:        []string{"API Keys"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, apiKeyHandler.ListAPIKeys)
+
+	// Rotate an API key
+	huma.Register(api, huma.Operation{
+		OperationID: "rotate-api-key",
+		Method:      http.MethodPost,
+		Path:        "/api-keys/{key_id}/rotate",
+		Summary:     "Rotate an API key",
+		Description: "Replaces the secret of an API key, keeping its name, pseudonym, scopes and expiry. The previous secret stops working immediately.",
+		Tags:        []string{"API Keys"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, apiKeyHandler.RotateAPIKey)
+
+	// Revoke an API key
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-api-key",
+		Method:      http.MethodDelete,
+		Path:        "/api-keys/{key_id}",
+		Summary:     "Revoke an API key",
+		Description: "Revokes an API key. It stops working immediately and remains listed as inactive.",
+		Tags:        []string{"API Keys"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, apiKeyHandler.RevokeAPIKey)
+}
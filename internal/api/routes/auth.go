@@ -8,12 +8,15 @@ import (
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
 	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/mfa"
 	"github.com/stephenafamo/bob"
 )
 
 // RegisterAuthRoutes registers authentication-related routes
-func RegisterAuthRoutes(api huma.API, cfg *config.Config, db bob.Executor, rawDB *sql.DB, ibeSystem *ibe.IBESystem) {
-	authHandler := handlers.NewAuthHandlerWithIBE(cfg, db, rawDB, ibeSystem)
+func RegisterAuthRoutes(api huma.API, cfg *config.Config, db bob.Executor, rawDB *sql.DB, ibeSystem *ibe.IBESystem, signingKeys *jwtkeys.KeySet, mfaSecrets *mfa.SecretBox, mail mailer.Mailer) {
+	authHandler := handlers.NewAuthHandlerWithIBE(cfg, db, rawDB, ibeSystem, signingKeys, mfaSecrets, mail)
 
 	// User registration
 	huma.Register(api, huma.Operation{
@@ -37,6 +40,16 @@ func RegisterAuthRoutes(api huma.API, cfg *config.Config, db bob.Executor, rawDB
 		Tags:        []string{"Authentication"},
 	}, authHandler.LoginUser)
 
+	// Second step of login for accounts with MFA enabled
+	huma.Register(api, huma.Operation{
+		OperationID: "login-user-mfa",
+		Method:      http.MethodPost,
+		Path:        "/auth/login/mfa",
+		Summary:     "Complete login with a second factor",
+		Description: "Exchanges the mfa_token returned by /auth/login and a TOTP or recovery code for access tokens. The resulting tokens carry a fresh MFA claim.",
+		Tags:        []string{"Authentication"},
+	}, authHandler.CompleteMFALogin)
+
 	// User logout
 	// Note: The client should clear cookies based on the logout response
 	huma.Register(api, huma.Operation{
@@ -44,18 +57,29 @@ func RegisterAuthRoutes(api huma.API, cfg *config.Config, db bob.Executor, rawDB
 		Method:      http.MethodPost,
 		Path:        "/auth/logout",
 		Summary:     "Logout a user",
-		Description: "Invalidates the user's refresh token. The client should clear any stored tokens and cookies.",
+		Description: "Revokes the refresh token family of the given refresh token and, if the request is authenticated, the access token. The client should clear any stored tokens and cookies.",
 		Tags:        []string{"Authentication"},
 	}, authHandler.LogoutUser)
 
+	// Log out of every session
+	huma.Register(api, huma.Operation{
+		OperationID: "logout-user-all",
+		Method:      http.MethodPost,
+		Path:        "/auth/logout/all",
+		Summary:     "Logout from all sessions",
+		Description: "Revokes every access and refresh token issued to the current user, signing out all devices.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.LogoutAllSessions)
+
 	// Token refresh
-	// Note: The client should update the access token cookie based on the response
+	// Note: The client should update both token cookies based on the response
 	huma.Register(api, huma.Operation{
 		OperationID: "refresh-token",
 		Method:      http.MethodPost,
 		Path:        "/auth/refresh",
 		Summary:     "Refresh an expired access token",
-		Description: "Refreshes an expired access token using a valid refresh token. The client should update the access token cookie with the new token.",
+		Description: "Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; reusing one revokes every token rotated from the same login. The client should update both cookies with the new tokens.",
 		Tags:        []string{"Authentication"},
 	}, authHandler.RefreshToken)
 
@@ -69,4 +93,97 @@ func RegisterAuthRoutes(api huma.API, cfg *config.Config, db bob.Executor, rawDB
 		Tags:        []string{"Authentication"},
 		Security:    []map[string][]string{{"jwt": {}}},
 	}, authHandler.GetCurrentUserSession)
+
+	// Email verification
+	huma.Register(api, huma.Operation{
+		OperationID: "verify-email",
+		Method:      http.MethodPost,
+		Path:        "/auth/email/verify",
+		Summary:     "Verify an email address",
+		Description: "Consumes the token from an email verification link. Each link can be used once and expires after SECURITY_EMAIL_VERIFICATION_TTL.",
+		Tags:        []string{"Authentication"},
+	}, authHandler.VerifyEmail)
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "resend-verification-email",
+		Method:        http.MethodPost,
+		Path:          "/auth/email/verification",
+		Summary:       "Resend the verification email",
+		Description:   "Mails a new verification link to the current user's address. Earlier links stop working.",
+		Tags:          []string{"Authentication"},
+		DefaultStatus: http.StatusAccepted,
+		Security:      []map[string][]string{{"jwt": {}}},
+	}, authHandler.ResendVerificationEmail)
+
+	// Password reset
+	huma.Register(api, huma.Operation{
+		OperationID:   "request-password-reset",
+		Method:        http.MethodPost,
+		Path:          "/auth/password/forgot",
+		Summary:       "Request a password reset link",
+		Description:   "Mails a single-use password reset link if the address belongs to an active account. The response is the same whether or not it does.",
+		Tags:          []string{"Authentication"},
+		DefaultStatus: http.StatusAccepted,
+	}, authHandler.RequestPasswordReset)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "reset-password",
+		Method:      http.MethodPost,
+		Path:        "/auth/password/reset",
+		Summary:     "Reset a password",
+		Description: "Sets a new password using the token from a reset link, then revokes every access and refresh token issued to the user. MFA is still required at the next login if it is enabled.",
+		Tags:        []string{"Authentication"},
+	}, authHandler.ResetPassword)
+
+	// MFA enrollment
+	huma.Register(api, huma.Operation{
+		OperationID: "start-mfa-enrollment",
+		Method:      http.MethodPost,
+		Path:        "/auth/mfa/enroll",
+		Summary:     "Start TOTP enrollment",
+		Description: "Generates a new TOTP secret and otpauth URI for the current user. MFA is not enabled until the first code is confirmed.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.StartMFAEnrollment)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "confirm-mfa-enrollment",
+		Method:      http.MethodPost,
+		Path:        "/auth/mfa/enroll/confirm",
+		Summary:     "Confirm TOTP enrollment",
+		Description: "Verifies the first code from the authenticator app, enables MFA and returns single-use recovery codes. Recovery codes are shown only once.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.ConfirmMFAEnrollment)
+
+	// MFA step-up for sensitive actions
+	huma.Register(api, huma.Operation{
+		OperationID: "step-up-mfa",
+		Method:      http.MethodPost,
+		Path:        "/auth/mfa/step-up",
+		Summary:     "Prove MFA for a sensitive action",
+		Description: "Verifies a TOTP or recovery code and returns a new access token with a fresh MFA claim, required for actions such as identity correlation.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.StepUpMFA)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "regenerate-mfa-recovery-codes",
+		Method:      http.MethodPost,
+		Path:        "/auth/mfa/recovery-codes",
+		Summary:     "Regenerate recovery codes",
+		Description: "Replaces all recovery codes after verifying a second factor. Previously issued codes stop working.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.RegenerateRecoveryCodes)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "disable-mfa",
+		Method:      http.MethodPost,
+		Path:        "/auth/mfa/disable",
+		Summary:     "Disable MFA",
+		Description: "Turns off multi-factor authentication after verifying a second factor.",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, authHandler.DisableMFA)
 }
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
+	"github.com/stephenafamo/bob"
+)
+
+// RegisterComplianceRoutes registers the compliance case routes of the legal team
+func RegisterComplianceRoutes(api huma.API, db bob.Executor, auditChainDAO *dao.AuditChainDAO, disclosureSigner *disclosure.Signer) {
+	complianceHandler := handlers.NewComplianceHandler(db, auditChainDAO, disclosureSigner)
+
+	// Open a compliance case
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-compliance-report",
+		Method:        http.MethodPost,
+		Path:          "/admin/compliance/reports",
+		Summary:       "Open a compliance case",
+		Description:   "Opens a pending case for a court order, subpoena or other legal request, with the requesting authority, its reference and due date (requires legal_compliance capability)",
+		Tags:          []string{"Administration", "Compliance"},
+		Security:      []map[string][]string{{"jwt": {}}},
+		DefaultStatus: http.StatusCreated,
+	}, complianceHandler.CreateComplianceReport)
+
+	// List compliance cases
+	huma.Register(api, huma.Operation{
+		OperationID: "list-compliance-reports",
+		Method:      http.MethodGet,
+		Path:        "/admin/compliance/reports",
+		Summary:     "List compliance cases",
+		Description: "Lists compliance cases by due date, optionally only those with a status, assigned to a user or overdue (requires legal_compliance capability)",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.ListComplianceReports)
+
+	// Get a compliance case
+	huma.Register(api, huma.Operation{
+		OperationID: "get-compliance-report",
+		Method:      http.MethodGet,
+		Path:        "/admin/compliance/reports/{report_id}",
+		Summary:     "Get a compliance case",
+		Description: "Gets a compliance case with the correlations linked to it and its history (requires legal_compliance capability)",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.GetComplianceReport)
+
+	// Assign a compliance case
+	huma.Register(api, huma.Operation{
+		OperationID: "assign-compliance-report",
+		Method:      http.MethodPost,
+		Path:        "/admin/compliance/reports/{report_id}/assign",
+		Summary:     "Assign a compliance case",
+		Description: "Assigns an open compliance case to an active user with the legal_compliance capability (requires legal_compliance capability)",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.AssignComplianceReport)
+
+	// Move a compliance case to another status
+	huma.Register(api, huma.Operation{
+		OperationID: "set-compliance-report-status",
+		Method:      http.MethodPost,
+		Path:        "/admin/compliance/reports/{report_id}/status",
+		Summary:     "Change the status of a compliance case",
+		Description: "Moves a pending case to in_progress or rejected, or a case in progress to completed or rejected (requires legal_compliance capability)",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.SetComplianceReportStatus)
+
+	// Link an executed identity correlation to a compliance case
+	huma.Register(api, huma.Operation{
+		OperationID: "attach-compliance-correlation",
+		Method:      http.MethodPost,
+		Path:        "/admin/compliance/reports/{report_id}/correlations",
+		Summary:     "Link a correlation to a compliance case",
+		Description: "Links an executed identity correlation request to an open compliance case. A gag order on the case then withholds the disclosure notice of the correlation (requires legal_compliance capability).",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.AttachComplianceCorrelation)
+
+	// Export the disclosure bundle of a compliance case
+	huma.Register(api, huma.Operation{
+		OperationID: "export-compliance-bundle",
+		Method:      http.MethodPost,
+		Path:        "/admin/compliance/reports/{report_id}/bundle",
+		Summary:     "Export the disclosure bundle of a compliance case",
+		Description: "Produces a signed archive of the case, its linked correlations with their results and audit entries, and the posts and comments of the correlated pseudonyms, optionally encrypted to the recipient's X25519 key. Only cases in progress or completed can be exported (requires legal_compliance capability).",
+		Tags:        []string{"Administration", "Compliance"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, complianceHandler.ExportComplianceBundle)
+}
@@ -6,8 +6,10 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 	"github.com/stephenafamo/bob"
 )
 
@@ -23,6 +25,7 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Get posts from a subforum",
 		Description: "Retrieves a paginated list of posts from a specific subforum with optional sorting",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePostsRead},
 	}, contentHandler.GetPosts)
 
 	// Create post in subforum
@@ -33,6 +36,7 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Create a new post",
 		Description: "Creates a new post in the specified subforum",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePostsWrite},
 	}, contentHandler.CreatePost)
 
 	// Get post details
@@ -43,6 +47,7 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Get detailed information about a specific post",
 		Description: "Retrieves detailed information about a post including comments",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePostsRead},
 	}, contentHandler.GetPostDetails)
 
 	// Vote on post
@@ -53,6 +58,7 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Vote on a post",
 		Description: "Votes on a post (upvote, downvote, or remove vote)",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassVote, middleware.APIKeyScopeMetadataKey: middleware.ScopeVotesWrite},
 	}, contentHandler.VoteOnPost)
 
 	// Create comment on post
@@ -63,6 +69,7 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Create a comment on a post",
 		Description: "Creates a comment on a post, optionally as a reply to another comment",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePostsWrite},
 	}, contentHandler.CreateComment)
 
 	// Vote on comment
@@ -73,5 +80,6 @@ func RegisterContentRoutes(api huma.API, db bob.Executor, rawDB *sql.DB, ibeSyst
 		Summary:     "Vote on a comment",
 		Description: "Votes on a comment (upvote, downvote, or remove vote)",
 		Tags:        []string{"Content"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassVote, middleware.APIKeyScopeMetadataKey: middleware.ScopeVotesWrite},
 	}, contentHandler.VoteOnComment)
 }
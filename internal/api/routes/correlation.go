@@ -5,14 +5,17 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 	"github.com/stephenafamo/bob"
 )
 
 // RegisterCorrelationRoutes registers administrative correlation routes
-func RegisterCorrelationRoutes(api huma.API, db bob.Executor, ibeSystem *ibe.IBESystem, securePseudonymDAO *dao.SecurePseudonymDAO, identityMappingDAO *dao.IdentityMappingDAO, postDAO *dao.PostDAO, commentDAO *dao.CommentDAO, subforumDAO *dao.SubforumDAO) {
-	correlationHandler := handlers.NewCorrelationHandler(db, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO)
+func RegisterCorrelationRoutes(api huma.API, cfg *config.Config, db bob.Executor, auditChainDAO *dao.AuditChainDAO, ibeSystem *ibe.IBESystem, securePseudonymDAO *dao.SecurePseudonymDAO, identityMappingDAO *dao.IdentityMappingDAO, postDAO *dao.PostDAO, commentDAO *dao.CommentDAO, subforumDAO *dao.SubforumDAO, mail mailer.Mailer) {
+	correlationHandler := handlers.NewCorrelationHandler(cfg, db, auditChainDAO, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO, mail)
 
 	// Request fingerprint correlation (moderators)
 	huma.Register(api, huma.Operation{
@@ -22,17 +25,102 @@ func RegisterCorrelationRoutes(api huma.API, db bob.Executor, ibeSystem *ibe.IBE
 		Summary:     "Request fingerprint-based correlation for moderation",
 		Description: "Request fingerprint-based correlation for moderation purposes (moderators only)",
 		Tags:        []string{"Administration", "Correlation"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassCorrelation},
 	}, correlationHandler.RequestFingerprintCorrelation)
 
 	// Request identity correlation (admins)
 	huma.Register(api, huma.Operation{
-		OperationID: "request-identity-correlation",
+		OperationID:   "request-identity-correlation",
+		Method:        http.MethodPost,
+		Path:          "/admin/correlation/identity",
+		Summary:       "Request identity-based correlation for platform-wide investigations",
+		Description:   "Request identity-based correlation for platform-wide investigations (admins only). The request is pending until a different admin with an approver role approves it.",
+		Tags:          []string{"Administration", "Correlation"},
+		DefaultStatus: http.StatusAccepted,
+		Metadata:      map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassCorrelation},
+	}, correlationHandler.RequestIdentityCorrelation)
+
+	// List identity correlation requests
+	huma.Register(api, huma.Operation{
+		OperationID: "list-correlation-requests",
+		Method:      http.MethodGet,
+		Path:        "/admin/correlation/requests",
+		Summary:     "List identity correlation requests",
+		Description: "List identity correlation requests for review, newest first",
+		Tags:        []string{"Administration", "Correlation"},
+	}, correlationHandler.ListCorrelationRequests)
+
+	// Approve an identity correlation request
+	huma.Register(api, huma.Operation{
+		OperationID: "approve-correlation-request",
 		Method:      http.MethodPost,
-		Path:        "/admin/correlation/identity",
-		Summary:     "Request identity-based correlation for platform-wide investigations",
-		Description: "Request identity-based correlation for platform-wide investigations (admins only)",
+		Path:        "/admin/correlation/requests/{request_id}/approve",
+		Summary:     "Approve an identity correlation request",
+		Description: "Approve a pending identity correlation request made by a different admin (approver roles only)",
 		Tags:        []string{"Administration", "Correlation"},
-	}, correlationHandler.RequestIdentityCorrelation)
+	}, correlationHandler.ApproveCorrelationRequest)
+
+	// Reject an identity correlation request
+	huma.Register(api, huma.Operation{
+		OperationID: "reject-correlation-request",
+		Method:      http.MethodPost,
+		Path:        "/admin/correlation/requests/{request_id}/reject",
+		Summary:     "Reject an identity correlation request",
+		Description: "Reject a pending identity correlation request made by a different admin (approver roles only)",
+		Tags:        []string{"Administration", "Correlation"},
+	}, correlationHandler.RejectCorrelationRequest)
+
+	// Execute an approved identity correlation request
+	huma.Register(api, huma.Operation{
+		OperationID: "execute-correlation-request",
+		Method:      http.MethodPost,
+		Path:        "/admin/correlation/requests/{request_id}/execute",
+		Summary:     "Execute an approved identity correlation request",
+		Description: "Perform an approved identity correlation. Only the requester can execute a request, once, before its approval expires.",
+		Tags:        []string{"Administration", "Correlation"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassCorrelation},
+	}, correlationHandler.ExecuteIdentityCorrelation)
+
+	// Break the glass: emergency identity correlation without prior approval
+	huma.Register(api, huma.Operation{
+		OperationID: "break-glass-correlation",
+		Method:      http.MethodPost,
+		Path:        "/admin/correlation/break-glass",
+		Summary:     "Perform an emergency identity correlation",
+		Description: "Perform an identity correlation without prior approval for an emergency reason code (break-glass roles only). The reviewers are notified, and a different admin must sign off the correlation before the review window ends or the requester's account is flagged.",
+		Tags:        []string{"Administration", "Correlation"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassCorrelation},
+	}, correlationHandler.BreakGlassCorrelation)
+
+	// List break-glass reviews
+	huma.Register(api, huma.Operation{
+		OperationID: "list-break-glass-reviews",
+		Method:      http.MethodGet,
+		Path:        "/admin/correlation/break-glass/reviews",
+		Summary:     "List break-glass correlation reviews",
+		Description: "List emergency identity correlations for review, newest first (reviewer roles only)",
+		Tags:        []string{"Administration", "Correlation"},
+	}, correlationHandler.ListBreakGlassReviews)
+
+	// Sign off a break-glass correlation
+	huma.Register(api, huma.Operation{
+		OperationID: "sign-off-break-glass-review",
+		Method:      http.MethodPost,
+		Path:        "/admin/correlation/break-glass/reviews/{review_id}/sign-off",
+		Summary:     "Sign off a break-glass correlation",
+		Description: "Sign off a pending or overdue emergency identity correlation made by a different admin (reviewer roles only). Signing off an overdue correlation lifts its flag.",
+		Tags:        []string{"Administration", "Correlation"},
+	}, correlationHandler.SignOffBreakGlassReview)
+
+	// Reject a break-glass correlation
+	huma.Register(api, huma.Operation{
+		OperationID: "reject-break-glass-review",
+		Method:      http.MethodPost,
+		Path:        "/admin/correlation/break-glass/reviews/{review_id}/reject",
+		Summary:     "Reject a break-glass correlation",
+		Description: "Reject a pending or overdue emergency identity correlation made by a different admin, flagging the requester's account (reviewer roles only)",
+		Tags:        []string{"Administration", "Correlation"},
+	}, correlationHandler.RejectBreakGlassReview)
 
 	// Get correlation history
 	huma.Register(api, huma.Operation{
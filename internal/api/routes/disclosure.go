@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/stephenafamo/bob"
+)
+
+// RegisterDisclosureRoutes registers correlation disclosure notice routes
+func RegisterDisclosureRoutes(api huma.API, cfg *config.Config, db bob.Executor, auditChainDAO *dao.AuditChainDAO, securePseudonymDAO *dao.SecurePseudonymDAO) {
+	disclosureHandler := handlers.NewDisclosureHandler(cfg, db, auditChainDAO, securePseudonymDAO)
+
+	// List the disclosure notices of one of the current user's pseudonyms
+	huma.Register(api, huma.Operation{
+		OperationID: "list-disclosure-notices",
+		Method:      http.MethodGet,
+		Path:        "/pseudonyms/{pseudonym_id}/disclosure-notices",
+		Summary:     "List correlation disclosure notices",
+		Description: "Lists notices that one of the authenticated user's pseudonyms was correlated, with the date, role type and legal basis category. Notices appear once their embargo has passed, unless a gag order withholds them.",
+		Tags:        []string{"Pseudonyms"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, disclosureHandler.ListDisclosureNotices)
+
+	// List disclosure notices and their embargoes (legal team)
+	huma.Register(api, huma.Operation{
+		OperationID: "admin-list-disclosure-notices",
+		Method:      http.MethodGet,
+		Path:        "/admin/disclosure-notices",
+		Summary:     "List correlation disclosure notices",
+		Description: "Lists disclosure notices with their embargo and status (requires legal_compliance capability)",
+		Tags:        []string{"Administration", "Correlation"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, disclosureHandler.AdminListDisclosureNotices)
+
+	// Extend the embargo of a disclosure notice (legal team)
+	huma.Register(api, huma.Operation{
+		OperationID: "extend-disclosure-embargo",
+		Method:      http.MethodPost,
+		Path:        "/admin/disclosure-notices/{notice_id}/extend",
+		Summary:     "Extend the embargo of a disclosure notice",
+		Description: "Moves the embargo of an undelivered disclosure notice to a later time. The change is recorded in the correlation audit trail (requires legal_compliance capability).",
+		Tags:        []string{"Administration", "Correlation"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, disclosureHandler.ExtendDisclosureEmbargo)
+
+	// Lift the embargo of a disclosure notice (legal team)
+	huma.Register(api, huma.Operation{
+		OperationID: "lift-disclosure-embargo",
+		Method:      http.MethodPost,
+		Path:        "/admin/disclosure-notices/{notice_id}/lift",
+		Summary:     "Lift the embargo of a disclosure notice",
+		Description: "Ends the embargo of an undelivered disclosure notice now; a gag order still withholds it. The change is recorded in the correlation audit trail (requires legal_compliance capability).",
+		Tags:        []string{"Administration", "Correlation"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, disclosureHandler.LiftDisclosureEmbargo)
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 )
 
 // RegisterHealthRoutes registers health-related routes
@@ -15,5 +17,6 @@ func RegisterHealthRoutes(api huma.API) {
 		Path:        "/health",
 		Summary:     "Health check endpoint",
 		Description: "Returns the health status of the API",
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassExempt, middleware.APIKeyScopeMetadataKey: middleware.ScopePublic},
 	}, handlers.HealthHandler)
 }
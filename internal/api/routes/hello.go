@@ -5,6 +5,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 )
 
 // RegisterHelloRoutes registers hello-related routes
@@ -15,5 +16,6 @@ func RegisterHelloRoutes(api huma.API) {
 		Path:        "/hello",
 		Summary:     "Hello world endpoint",
 		Description: "Returns a simple hello world message",
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePublic},
 	}, handlers.HelloHandler)
 }
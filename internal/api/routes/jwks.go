@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+)
+
+// RegisterJWKSRoutes registers the token verification key routes
+func RegisterJWKSRoutes(api huma.API, signingKeys *jwtkeys.KeySet) {
+	jwksHandler := handlers.NewJWKSHandler(signingKeys)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-jwks",
+		Method:      http.MethodGet,
+		Path:        "/.well-known/jwks.json",
+		Summary:     "Get token verification keys",
+		Description: "Returns the JSON Web Key Set with the public keys that verify access tokens. Tokens name their key in the kid header.",
+		Tags:        []string{"Authentication"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePublic},
+	}, jwksHandler.GetJWKS)
+}
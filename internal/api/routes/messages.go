@@ -5,6 +5,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 )
 
 // RegisterMessagesRoutes registers direct message routes
@@ -19,6 +20,7 @@ func RegisterMessagesRoutes(api huma.API) {
 		Summary:     "Send a direct message to another user",
 		Description: "Send a direct message to another user",
 		Tags:        []string{"Messages"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeMessagesWrite},
 	}, messagesHandler.SendDirectMessage)
 
 	// Get direct messages
@@ -29,5 +31,6 @@ func RegisterMessagesRoutes(api huma.API) {
 		Summary:     "Get direct messages for the current user",
 		Description: "Get direct messages for the current user",
 		Tags:        []string{"Messages"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeMessagesRead},
 	}, messagesHandler.GetDirectMessages)
 }
@@ -5,6 +5,8 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 )
 
 // RegisterModerationRoutes registers moderation-related routes
@@ -19,6 +21,7 @@ func RegisterModerationRoutes(api huma.API) {
 		Summary:     "Report content or users",
 		Description: "Report content or users for moderation review",
 		Tags:        []string{"Moderation"},
+		Metadata:    map[string]any{ratelimit.ClassMetadataKey: ratelimit.ClassReport, middleware.APIKeyScopeMetadataKey: middleware.ScopeReportsWrite},
 	}, moderationHandler.ReportContent)
 
 	// Get reports (moderators only)
@@ -29,6 +32,7 @@ func RegisterModerationRoutes(api huma.API) {
 		Summary:     "Get reports for moderation review",
 		Description: "Get reports for moderation review (moderators only)",
 		Tags:        []string{"Moderation"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeModerationRead},
 	}, moderationHandler.GetReports)
 
 	// Remove content (moderators only)
@@ -39,6 +43,7 @@ func RegisterModerationRoutes(api huma.API) {
 		Summary:     "Remove content as a moderator",
 		Description: "Remove content as a moderator (moderators only)",
 		Tags:        []string{"Moderation"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeModerationWrite},
 	}, moderationHandler.RemoveContent)
 
 	// Ban user (moderators only)
@@ -49,6 +54,7 @@ func RegisterModerationRoutes(api huma.API) {
 		Summary:     "Ban a user from a subforum",
 		Description: "Ban a user from a subforum (moderators only)",
 		Tags:        []string{"Moderation"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeModerationWrite},
 	}, moderationHandler.BanUser)
 
 	// Get moderation history (moderators only)
@@ -59,5 +65,6 @@ func RegisterModerationRoutes(api huma.API) {
 		Summary:     "Get moderation action history",
 		Description: "Get moderation action history for the authenticated moderator",
 		Tags:        []string{"Moderation"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeModerationRead},
 	}, moderationHandler.GetModerationHistory)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 )
 
 // RegisterSearchRoutes registers search routes
@@ -19,6 +20,7 @@ func RegisterSearchRoutes(api huma.API) {
 		Summary:     "Search for posts across all subforums",
 		Description: "Search for posts across all subforums with various filters",
 		Tags:        []string{"Search"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopePostsRead},
 	}, searchHandler.SearchPosts)
 
 	// Search users
@@ -29,5 +31,6 @@ func RegisterSearchRoutes(api huma.API) {
 		Summary:     "Search for users by display name",
 		Description: "Search for users by display name",
 		Tags:        []string{"Search"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeProfilesRead},
 	}, searchHandler.SearchUsers)
 }
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/stephenafamo/bob"
+)
+
+// RegisterSessionRoutes registers login session management routes
+func RegisterSessionRoutes(api huma.API, db bob.Executor) {
+	sessionHandler := handlers.NewSessionHandler(db)
+
+	// List the current user's sessions
+	huma.Register(api, huma.Operation{
+		OperationID: "list-sessions",
+		Method:      http.MethodGet,
+		Path:        "/auth/sessions",
+		Summary:     "List active sessions",
+		Description: "Lists the authenticated user's active login sessions with user agent, coarse IP prefix, creation and last seen times",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.ListSessions)
+
+	// Revoke one of the current user's sessions
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-session",
+		Method:      http.MethodDelete,
+		Path:        "/auth/sessions/{session_id}",
+		Summary:     "Revoke a session",
+		Description: "Revokes one of the authenticated user's sessions, invalidating its refresh and access tokens",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.RevokeSession)
+
+	// Revoke the current user's sessions
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-all-sessions",
+		Method:      http.MethodDelete,
+		Path:        "/auth/sessions",
+		Summary:     "Revoke all sessions",
+		Description: "Revokes all of the authenticated user's other sessions. Set include_current=true to also revoke the session making the request",
+		Tags:        []string{"Authentication"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.RevokeAllSessions)
+
+	// List a user's sessions (admins)
+	huma.Register(api, huma.Operation{
+		OperationID: "admin-list-user-sessions",
+		Method:      http.MethodGet,
+		Path:        "/admin/users/{user_id}/sessions",
+		Summary:     "List a user's sessions",
+		Description: "Lists a user's active login sessions (requires system_admin or user_management capability)",
+		Tags:        []string{"Administration"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.AdminListSessions)
+
+	// Revoke one of a user's sessions (admins)
+	huma.Register(api, huma.Operation{
+		OperationID: "admin-revoke-user-session",
+		Method:      http.MethodDelete,
+		Path:        "/admin/users/{user_id}/sessions/{session_id}",
+		Summary:     "Revoke a user's session",
+		Description: "Revokes one of a user's sessions (requires system_admin or user_management capability)",
+		Tags:        []string{"Administration"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.AdminRevokeSession)
+
+	// Revoke all of a user's sessions (admins)
+	huma.Register(api, huma.Operation{
+		OperationID: "admin-revoke-user-sessions",
+		Method:      http.MethodDelete,
+		Path:        "/admin/users/{user_id}/sessions",
+		Summary:     "Revoke all of a user's sessions",
+		Description: "Revokes every session of a user (requires system_admin or user_management capability)",
+		Tags:        []string{"Administration"},
+		Security:    []map[string][]string{{"jwt": {}}},
+	}, sessionHandler.AdminRevokeAllSessions)
+}
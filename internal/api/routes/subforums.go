@@ -5,6 +5,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/stephenafamo/bob"
 )
 
@@ -20,6 +21,7 @@ func RegisterSubforumRoutes(api huma.API, db bob.Executor) {
 		Summary:     "Get a list of subforums",
 		Description: "Retrieves a paginated list of subforums with optional sorting. Supports query parameters: page (default: 1), limit (default: 25), sort (options: name, subscribers, posts, created_at)",
 		Tags:        []string{"Subforums"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeSubforumsRead},
 	}, subforumHandler.GetSubforums)
 
 	// Get subforum details
@@ -30,6 +32,7 @@ func RegisterSubforumRoutes(api huma.API, db bob.Executor) {
 		Summary:     "Get detailed information about a specific subforum",
 		Description: "Retrieves detailed information about a subforum including moderators and subscription status. Requires authentication for private subforums.",
 		Tags:        []string{"Subforums"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeSubforumsRead},
 	}, subforumHandler.GetSubforumDetails)
 
 	// Subscribe to subforum
@@ -40,6 +43,7 @@ func RegisterSubforumRoutes(api huma.API, db bob.Executor) {
 		Summary:     "Subscribe to a subforum",
 		Description: "Subscribes the authenticated user to a subforum. Requires authentication.",
 		Tags:        []string{"Subforums"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeSubforumsWrite},
 	}, subforumHandler.SubscribeToSubforum)
 
 	// Unsubscribe from subforum
@@ -50,6 +54,7 @@ func RegisterSubforumRoutes(api huma.API, db bob.Executor) {
 		Summary:     "Unsubscribe from a subforum",
 		Description: "Unsubscribes the authenticated user from a subforum. Requires authentication.",
 		Tags:        []string{"Subforums"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeSubforumsWrite},
 	}, subforumHandler.UnsubscribeFromSubforum)
 
 	// Create subforum
@@ -60,5 +65,6 @@ func RegisterSubforumRoutes(api huma.API, db bob.Executor) {
 		Summary:     "Create a new subforum",
 		Description: "Creates a new subforum. Requires authentication and the create_subforum capability.",
 		Tags:        []string{"Subforums"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeSubforumsWrite},
 	}, subforumHandler.CreateSubforum)
 }
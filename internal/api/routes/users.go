@@ -5,6 +5,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/matt0x6f/hashpost/internal/api/handlers"
+	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	"github.com/matt0x6f/hashpost/internal/ibe"
 )
@@ -21,6 +22,7 @@ func RegisterUserRoutes(api huma.API, userDAO *dao.UserDAO, securePseudonymDAO *
 		Summary:     "Get a pseudonym's public profile",
 		Description: "Retrieves public profile information for a pseudonym by pseudonym ID",
 		Tags:        []string{"Pseudonyms"},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeProfilesRead},
 	}, userHandler.GetPseudonymProfile)
 
 	// Update pseudonym profile
@@ -32,6 +34,7 @@ func RegisterUserRoutes(api huma.API, userDAO *dao.UserDAO, securePseudonymDAO *
 		Description: "Updates the authenticated user's pseudonym profile information",
 		Tags:        []string{"Pseudonyms"},
 		Security:    []map[string][]string{{"jwt": {}}},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeProfilesWrite},
 	}, userHandler.UpdatePseudonymProfile)
 
 	// Create new pseudonym
@@ -87,6 +90,7 @@ func RegisterUserRoutes(api huma.API, userDAO *dao.UserDAO, securePseudonymDAO *
 		Description: "Blocks a user by pseudonym ID. Optionally blocks all personas of the same user.",
 		Tags:        []string{"Users"},
 		Security:    []map[string][]string{{"jwt": {}}},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeProfilesWrite},
 	}, userHandler.BlockUser)
 
 	// Unblock pseudonym/user
@@ -98,5 +102,6 @@ func RegisterUserRoutes(api huma.API, userDAO *dao.UserDAO, securePseudonymDAO *
 		Description: "Unblocks a previously blocked user by pseudonym ID",
 		Tags:        []string{"Users"},
 		Security:    []map[string][]string{{"jwt": {}}},
+		Metadata:    map[string]any{middleware.APIKeyScopeMetadataKey: middleware.ScopeProfilesWrite},
 	}, userHandler.UnblockUser)
 }
@@ -1,19 +1,34 @@
 package api
 
 import (
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humago"
 	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/api/routes"
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/matt0x6f/hashpost/internal/compliance"
 	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/correlation"
 	"github.com/matt0x6f/hashpost/internal/database"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/ibe/rotation"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/matt0x6f/hashpost/internal/netprivacy"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/term"
 )
 
 // Server represents the API server
@@ -41,14 +56,87 @@ func NewServer() *Server {
 	// Get the raw *sql.DB from bob.DB
 	rawDB := db.DB
 
-	// Create IBE system from configuration
-	ibeSystem, err := ibe.NewIBESystemFromConfig(cfg.IBE.MasterKeyPath, cfg.IBE.KeyVersion, cfg.IBE.Salt)
+	// Create IBE system from the configured key provider
+	keyProvider, err := ibe.NewKeyProviderFromConfig(&cfg.IBE)
+	if err != nil {
+		log.Fatal().Err(err).Str("key_provider", cfg.IBE.KeyProvider).Msg("Failed to open IBE key provider")
+	}
+	ibeSystem, err := ibe.NewIBESystemFromProvider(context.Background(), keyProvider, cfg.IBE.Salt)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create IBE system from configuration")
 	}
 
-	// After loading IBE system
-	log.Info().Str("ibe_master_key", hex.EncodeToString(ibeSystem.GetMasterSecret())).Str("ibe_salt", ibeSystem.GetSalt()).Int("ibe_key_version", ibeSystem.GetKeyVersion()).Msg("IBE system configuration (server startup)")
+	// Reconstruct the masters of domains split into shares. Encrypted shares
+	// need the passphrases of their holders at the terminal.
+	if cfg.IBE.SharesDir != "" {
+		unlocked, err := ibeSystem.UnlockDomainMasters(cfg.IBE.SharesDir, promptSharePassphrase)
+		if err != nil {
+			log.Fatal().Err(err).Str("shares_dir", cfg.IBE.SharesDir).Msg("Failed to unlock IBE domain masters from shares")
+		}
+		for _, domain := range unlocked {
+			log.Info().Str("domain", domain).Msg("Unlocked IBE domain master from shares")
+		}
+	}
+	for _, domain := range ibeSystem.LockedDomains() {
+		log.Warn().Str("domain", domain).Msg("IBE domain is locked; correlation in this domain is unavailable")
+	}
+
+	log.Info().Str("ibe_key_provider", keyProvider.Name()).Int("ibe_key_version", ibeSystem.GetKeyVersion()).Msg("IBE system configuration (server startup)")
+
+	// Rotate the IBE domain masters on schedule, continuing interrupted
+	// re-encryption and retiring old versions after their grace period
+	if cfg.IBE.KeyRotation.Enabled {
+		go rotation.NewRotator(db, &cfg.IBE, keyProvider).Schedule(context.Background(), time.Hour)
+		log.Info().Dur("interval", cfg.IBE.KeyRotation.Interval).Dur("grace_period", cfg.IBE.KeyRotation.GracePeriod).Msg("Scheduled IBE key rotation enabled")
+	}
+
+	// Alert the legal team to open compliance cases past their due date
+	go compliance.NewOverdueMonitor(db).Schedule(context.Background(), time.Hour)
+
+	// Load the JWT signing keys
+	signingKeys, err := loadSigningKeys(&cfg.JWT)
+	if err != nil {
+		log.Fatal().Err(err).Str("keys_dir", cfg.JWT.KeysDir).Msg("Failed to load JWT signing keys")
+	}
+
+	// Load the key that signs checkpoints of the audit chains
+	checkpointSigner, err := loadCheckpointSigner(&cfg.Audit)
+	if err != nil {
+		log.Fatal().Err(err).Str("key_file", cfg.Audit.CheckpointKeyFile).Msg("Failed to load audit checkpoint key")
+	}
+	log.Info().Str("key_id", checkpointSigner.KeyID()).Int("checkpoint_interval", cfg.Audit.CheckpointInterval).Msg("Audit checkpoint key loaded")
+
+	// Load the key that signs legal disclosure bundles
+	disclosureSigner, err := loadDisclosureSigner(&cfg.Audit)
+	if err != nil {
+		log.Fatal().Err(err).Str("key_file", cfg.Audit.DisclosureKeyFile).Msg("Failed to load disclosure signing key")
+	}
+	log.Info().Str("key_id", disclosureSigner.KeyID()).Msg("Disclosure signing key loaded")
+
+	// Load the key TOTP secrets are encrypted with
+	mfaSecrets, err := loadMFASecretBox(&cfg.Security)
+	if err != nil {
+		log.Fatal().Err(err).Str("key_file", cfg.Security.MFAKeyFile).Msg("Failed to load MFA secret key")
+	}
+
+	// Create the mailer for verification and password reset links
+	mail, err := mailer.New(&cfg.Mail)
+	if err != nil {
+		log.Fatal().Err(err).Str("driver", cfg.Mail.Driver).Msg("Failed to create mailer")
+	}
+
+	// Alert platform admins to unusual correlation activity
+	notifier := correlation.NewMailNotifier(db, mail, cfg.Security.BreakGlass.ReviewerRoles)
+	if anomaly := &cfg.Security.CorrelationAnomaly; anomaly.Interval > 0 {
+		detector, err := correlation.NewDetector(db, anomaly, notifier)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create correlation anomaly detector")
+		}
+		go detector.Schedule(context.Background(), anomaly.Interval)
+	}
+
+	// Flag the accounts behind break-glass correlations not reviewed in time
+	go correlation.NewBreakGlass(db, notifier).Schedule(context.Background(), 5*time.Minute)
 
 	// Create DAOs
 	userDAO := dao.NewUserDAO(db)
@@ -61,9 +149,12 @@ func NewServer() *Server {
 	userPreferencesDAO := dao.NewUserPreferencesDAO(db)
 	apiKeyDAO := dao.NewAPIKeyDAO(db)
 	subforumDAO := dao.NewSubforumDAO(db)
+	auditChainDAO := dao.NewAuditChainDAO(db, checkpointSigner, cfg.Audit.CheckpointInterval)
 
 	// Create auth middleware with configuration
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.Secret, apiKeyDAO, &cfg.JWT, &cfg.Security)
+	authMiddleware := middleware.NewAuthMiddleware(signingKeys, apiKeyDAO, &cfg.JWT, &cfg.Security)
+	authMiddleware.SetTokenRevocationDAO(dao.NewTokenRevocationDAO(db))
+	authMiddleware.SetUserDAO(userDAO)
 
 	// Set the global auth middleware for Huma functions
 	middleware.SetGlobalAuthMiddleware(authMiddleware)
@@ -77,28 +168,53 @@ func NewServer() *Server {
 	// Create a new Huma API with humago adapter
 	api := humago.New(mux, config)
 
+	// Reduce client addresses and user agents to the forms that may be stored
+	hasher, err := netprivacy.New(&cfg.Privacy, db)
+	if err != nil {
+		log.Fatal().Err(err).Str("backend", cfg.Privacy.Backend).Msg("Failed to create address hasher")
+	}
+
 	// Add router-agnostic middleware
+	api.UseMiddleware(middleware.ClientInfoMiddleware(hasher))
 	api.UseMiddleware(middleware.LoggingMiddleware)
 	api.UseMiddleware(middleware.CORSMiddleware(&cfg.CORS))
 
 	// Add authentication middleware to extract user context
 	api.UseMiddleware(middleware.AuthenticateUserHuma)
 
+	// Restrict API keys to the operations their scopes allow
+	api.UseMiddleware(middleware.APIKeyScopeMiddleware(api))
+
+	// Add rate limiting, which counts requests against the authenticated client
+	if cfg.RateLimit.Enabled {
+		limiter, err := ratelimit.New(&cfg.RateLimit, db)
+		if err != nil {
+			log.Fatal().Err(err).Str("backend", cfg.RateLimit.Backend).Msg("Failed to create rate limiter")
+		}
+		api.UseMiddleware(middleware.RateLimitMiddleware(api, limiter))
+		log.Info().Str("backend", cfg.RateLimit.Backend).Msg("Rate limiting enabled")
+	}
+
 	// Note: Authentication middleware is applied per-route as needed
 	// Public routes (like register, login) don't require authentication
-	log.Info().Str("jwt_secret_length", fmt.Sprintf("%d", len(cfg.JWT.Secret))).Msg("JWT configuration loaded")
+	log.Info().Int("jwt_signing_keys", len(signingKeys.Keys())).Msg("JWT configuration loaded")
 
 	// Register routes
 	routes.RegisterHealthRoutes(api)
 	routes.RegisterHelloRoutes(api)
-	routes.RegisterAuthRoutes(api, cfg, db, rawDB, ibeSystem)
+	routes.RegisterAuthRoutes(api, cfg, db, rawDB, ibeSystem, signingKeys, mfaSecrets, mail)
+	routes.RegisterJWKSRoutes(api, signingKeys)
+	routes.RegisterSessionRoutes(api, db)
+	routes.RegisterAPIKeyRoutes(api, db, securePseudonymDAO)
 	routes.RegisterUserRoutes(api, userDAO, securePseudonymDAO, userPreferencesDAO, userBlocksDAO, postDAO, commentDAO, ibeSystem)
 	routes.RegisterSubforumRoutes(api, db)
 	routes.RegisterMessagesRoutes(api)
 	routes.RegisterSearchRoutes(api)
 	routes.RegisterModerationRoutes(api)
 	routes.RegisterContentRoutes(api, db, rawDB, ibeSystem, identityMappingDAO, userDAO)
-	routes.RegisterCorrelationRoutes(api, db, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO)
+	routes.RegisterCorrelationRoutes(api, cfg, db, auditChainDAO, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO, mail)
+	routes.RegisterDisclosureRoutes(api, cfg, db, auditChainDAO, securePseudonymDAO)
+	routes.RegisterComplianceRoutes(api, db, auditChainDAO, disclosureSigner)
 
 	return &Server{
 		API:       api,
@@ -118,3 +234,118 @@ func (s *Server) GetHandler() http.Handler {
 	// Apply CORS middleware first, then router middleware
 	return middleware.CORSMiddlewareHTTP(&s.AppConfig.CORS)(middleware.NewRouterMiddleware(s.Mux))
 }
+
+// loadSigningKeys loads the JWT signing key set. In development, a missing key
+// set is generated and saved so that tokens survive restarts; elsewhere it
+// must be created with the rotate-jwt-keys command.
+func loadSigningKeys(cfg *config.JWTConfig) (*jwtkeys.KeySet, error) {
+	signingKeys, err := jwtkeys.LoadKeySet(cfg.KeysDir)
+	if err == nil {
+		return signingKeys, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !cfg.Development {
+		return nil, err
+	}
+
+	signingKeys, err = jwtkeys.GenerateKeySet(cfg.SigningAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate development signing keys: %w", err)
+	}
+	if err := signingKeys.Save(cfg.KeysDir); err != nil {
+		return nil, err
+	}
+
+	log.Warn().
+		Str("keys_dir", cfg.KeysDir).
+		Str("algorithm", cfg.SigningAlgorithm).
+		Msg("Generated development JWT signing keys")
+	return signingKeys, nil
+}
+
+// loadCheckpointSigner loads the key that signs audit checkpoints. A missing
+// key is generated, with its public key saved next to it for auditors.
+func loadCheckpointSigner(cfg *config.AuditConfig) (*auditchain.Signer, error) {
+	signer, err := auditchain.LoadSigner(cfg.CheckpointKeyFile)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return signer, err
+	}
+
+	signer, err = auditchain.GenerateSigner()
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.Save(cfg.CheckpointKeyFile); err != nil {
+		return nil, err
+	}
+
+	log.Warn().
+		Str("key_file", cfg.CheckpointKeyFile).
+		Str("public_key_file", auditchain.PublicKeyPath(cfg.CheckpointKeyFile)).
+		Str("key_id", signer.KeyID()).
+		Msg("Generated a new audit checkpoint key; give its public key to auditors")
+	return signer, nil
+}
+
+// loadDisclosureSigner loads the key that signs legal disclosure bundles. A
+// missing key is generated, with its public key saved next to it for
+// recipients.
+func loadDisclosureSigner(cfg *config.AuditConfig) (*disclosure.Signer, error) {
+	signer, err := disclosure.LoadSigner(cfg.DisclosureKeyFile)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return signer, err
+	}
+
+	signer, err = disclosure.GenerateSigner()
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.Save(cfg.DisclosureKeyFile); err != nil {
+		return nil, err
+	}
+
+	log.Warn().
+		Str("key_file", cfg.DisclosureKeyFile).
+		Str("public_key_file", disclosure.PublicKeyPath(cfg.DisclosureKeyFile)).
+		Str("key_id", signer.KeyID()).
+		Msg("Generated a new disclosure signing key; publish its public key to recipients of disclosure bundles")
+	return signer, nil
+}
+
+// loadMFASecretBox loads the key TOTP secrets are encrypted with at rest. A
+// missing key is generated.
+func loadMFASecretBox(cfg *config.SecurityConfig) (*mfa.SecretBox, error) {
+	box, err := mfa.LoadSecretBox(cfg.MFAKeyFile)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return box, err
+	}
+
+	box, err = mfa.GenerateSecretBox()
+	if err != nil {
+		return nil, err
+	}
+	if err := box.Save(cfg.MFAKeyFile); err != nil {
+		return nil, err
+	}
+
+	log.Warn().
+		Str("key_file", cfg.MFAKeyFile).
+		Msg("Generated a new MFA secret key; back it up, as TOTP secrets cannot be decrypted without it")
+	return box, nil
+}
+
+// promptSharePassphrase asks the holder of an encrypted master share for its
+// passphrase. Encrypted shares can only be unlocked from a terminal.
+func promptSharePassphrase(share *ibe.MasterShare) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("share is encrypted and stdin is not a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", share.Label())
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimSpace(string(passphrase)), nil
+}
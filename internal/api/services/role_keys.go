@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	"github.com/matt0x6f/hashpost/internal/database/models"
 	"github.com/matt0x6f/hashpost/internal/ibe"
@@ -52,9 +53,12 @@ func (s *RoleKeyService) GetKeyForOperation(ctx context.Context, roleName, scope
 func (s *RoleKeyService) GenerateAndStoreKey(ctx context.Context, roleName, scope string, capabilities []string, expiresAt time.Time, createdBy int64) error {
 	// Generate IBE key for the role and scope
 	ibeKey := s.ibeSystem.GenerateRoleKey(roleName, scope, expiresAt)
+	if ibeKey == nil {
+		return fmt.Errorf("failed to derive IBE key for role=%s scope=%s: domain master unavailable", roleName, scope)
+	}
 
 	// Store the key in the database
-	_, err := s.roleKeyDAO.CreateRoleKey(ctx, roleName, scope, ibeKey, capabilities, expiresAt, createdBy)
+	_, err := s.roleKeyDAO.CreateRoleKeyVersion(ctx, roleName, scope, ibeKey, int32(s.ibeSystem.GetKeyVersion()), capabilities, expiresAt, createdBy)
 	if err != nil {
 		return fmt.Errorf("failed to store role key: %w", err)
 	}
@@ -62,6 +66,39 @@ func (s *RoleKeyService) GenerateAndStoreKey(ctx context.Context, roleName, scop
 	return nil
 }
 
+// GrantTemporaryKey grants time-limited access to the correlation key of a
+// role and key version, which the role's identity mappings are encrypted
+// under. The key material is copied into a key of scope that expires after
+// ttl, or with the correlation key if that is sooner, and the copy is
+// returned. Revoke it with RevokeKey once it has been used.
+func (s *RoleKeyService) GrantTemporaryKey(ctx context.Context, roleName, scope string, keyVersion int32, capabilities []string, ttl time.Duration, createdBy int64) (*models.RoleKey, error) {
+	correlationKeys, err := s.roleKeyDAO.GetRoleKeyVersions(ctx, roleName, dao.KeyScopeCorrelation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get correlation key for role=%s: %w", roleName, err)
+	}
+	var correlationKey *models.RoleKey
+	for _, key := range correlationKeys {
+		if key.KeyVersion == keyVersion {
+			correlationKey = key
+			break
+		}
+	}
+	if correlationKey == nil {
+		return nil, fmt.Errorf("no correlation key of key version %d for role=%s", keyVersion, roleName)
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	if correlationKey.ExpiresAt.Before(expiresAt) {
+		expiresAt = correlationKey.ExpiresAt
+	}
+	roleKey, err := s.roleKeyDAO.CreateRoleKeyVersion(ctx, roleName, scope, correlationKey.KeyData, keyVersion, capabilities, expiresAt, createdBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store role key: %w", err)
+	}
+
+	return roleKey, nil
+}
+
 // Helper to extract roles from user.Roles
 func extractUserRoles(user *models.User) []string {
 	var roles []string
@@ -149,6 +186,11 @@ func (s *RoleKeyService) DeactivateKey(ctx context.Context, keyID string) error
 	return s.roleKeyDAO.DeactivateRoleKey(ctx, keyID)
 }
 
+// RevokeKey deactivates a role key and destroys its key material
+func (s *RoleKeyService) RevokeKey(ctx context.Context, keyID uuid.UUID) error {
+	return s.roleKeyDAO.DestroyRoleKey(ctx, keyID)
+}
+
 // GetKeyCapabilities returns the capabilities of a specific role key
 func (s *RoleKeyService) GetKeyCapabilities(ctx context.Context, roleName, scope string) ([]string, error) {
 	roleKey, err := s.roleKeyDAO.GetRoleKey(ctx, roleName, scope)
@@ -0,0 +1,188 @@
+// Package auditchain makes audit logs tamper-evident. Every entry is hashed
+// together with its position and the hash of the entry before it, so
+// changing, removing or reordering an entry breaks every later link. Signed
+// checkpoints of the chain head let auditors detect a chain that was rebuilt
+// or cut short by someone with write access to the database.
+package auditchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+)
+
+// HashSize is the size of entry hashes
+const HashSize = sha256.Size
+
+// entryDomain separates entry hashes from hashes of other data
+const entryDomain = "hashpost-audit-entry-v1"
+
+// ErrBroken is returned when a chain does not verify
+var ErrBroken = errors.New("audit chain is broken")
+
+// Genesis returns the hash the first entry of every chain links to
+func Genesis() []byte {
+	return make([]byte, HashSize)
+}
+
+// Field is a named column of an audit entry, in the canonical form it is
+// hashed in
+type Field struct {
+	name  string
+	value []byte
+	null  bool
+}
+
+// String returns a text field
+func String(name, value string) Field {
+	return Field{name: name, value: []byte(value)}
+}
+
+// Int returns an integer field
+func Int(name string, value int64) Field {
+	return Field{name: name, value: []byte(strconv.FormatInt(value, 10))}
+}
+
+// Bool returns a boolean field
+func Bool(name string, value bool) Field {
+	return Field{name: name, value: []byte(strconv.FormatBool(value))}
+}
+
+// Time returns a timestamp field. Timestamps are hashed in UTC at
+// microsecond precision, the precision PostgreSQL stores.
+func Time(name string, value time.Time) Field {
+	return Field{name: name, value: []byte(value.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano))}
+}
+
+// JSON returns a JSON field. The document is hashed in a canonical form, so
+// that it verifies after a JSONB column reorders keys or whitespace.
+func JSON(name string, value []byte) Field {
+	return Field{name: name, value: canonicalJSON(value)}
+}
+
+// Null returns a field holding NULL
+func Null(name string) Field {
+	return Field{name: name, null: true}
+}
+
+// canonicalJSON re-encodes a JSON document with sorted keys and no
+// insignificant whitespace. Invalid documents are returned unchanged.
+func canonicalJSON(value []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return value
+	}
+	canonical, err := json.Marshal(document)
+	if err != nil {
+		return value
+	}
+	return canonical
+}
+
+// Hash returns the hash of the entry at position seq of a chain, linked to
+// the hash of the entry before it
+func Hash(chain string, seq int64, prev []byte, fields ...Field) []byte {
+	h := sha256.New()
+	writeBytes(h, []byte(entryDomain))
+	writeBytes(h, []byte(chain))
+	binary.Write(h, binary.BigEndian, seq)
+	writeBytes(h, prev)
+	for _, field := range fields {
+		writeBytes(h, []byte(field.name))
+		if field.null {
+			h.Write([]byte{0})
+			continue
+		}
+		h.Write([]byte{1})
+		writeBytes(h, field.value)
+	}
+	return h.Sum(nil)
+}
+
+// writeBytes writes length-prefixed data, so that no two different entries
+// hash the same input
+func writeBytes(h hash.Hash, data []byte) {
+	binary.Write(h, binary.BigEndian, uint32(len(data)))
+	h.Write(data)
+}
+
+// Link is the position of an entry in its chain, as stored with the entry
+type Link struct {
+	Seq      int64
+	PrevHash []byte
+	Hash     []byte
+}
+
+// Verifier recomputes a chain entry by entry, in sequence order, and
+// reports the first entry that does not verify
+type Verifier struct {
+	chain       string
+	seq         int64
+	head        []byte
+	checkpoints map[int64][]byte
+}
+
+// NewVerifier creates a verifier for a chain. Every checkpoint must have
+// been verified with VerifyCheckpoint.
+func NewVerifier(chain string, checkpoints []Checkpoint) *Verifier {
+	v := &Verifier{
+		chain:       chain,
+		head:        Genesis(),
+		checkpoints: make(map[int64][]byte, len(checkpoints)),
+	}
+	for _, checkpoint := range checkpoints {
+		v.checkpoints[checkpoint.Seq] = checkpoint.Hash
+	}
+	return v
+}
+
+// Next verifies the next entry of the chain. id identifies the entry in
+// errors.
+func (v *Verifier) Next(id string, link Link, fields ...Field) error {
+	if link.Seq != v.seq+1 {
+		return fmt.Errorf("%w: entry %d (%s) follows entry %d; entries are missing or out of order", ErrBroken, link.Seq, id, v.seq)
+	}
+	if !bytes.Equal(link.PrevHash, v.head) {
+		return fmt.Errorf("%w: entry %d (%s) does not link to entry %d", ErrBroken, link.Seq, id, v.seq)
+	}
+
+	hash := Hash(v.chain, link.Seq, v.head, fields...)
+	if !bytes.Equal(hash, link.Hash) {
+		return fmt.Errorf("%w: entry %d (%s) was modified", ErrBroken, link.Seq, id)
+	}
+	if checkpoint, ok := v.checkpoints[link.Seq]; ok && !bytes.Equal(checkpoint, hash) {
+		return fmt.Errorf("%w: entry %d (%s) does not match its signed checkpoint", ErrBroken, link.Seq, id)
+	}
+
+	v.seq, v.head = link.Seq, hash
+	return nil
+}
+
+// Finish checks that the verified entries end at the recorded head of the
+// chain and cover every checkpoint, which detects entries removed from the
+// end of the chain
+func (v *Verifier) Finish(headSeq int64, headHash []byte) error {
+	if v.seq != headSeq || !bytes.Equal(v.head, headHash) {
+		return fmt.Errorf("%w: the chain ends at entry %d but its recorded head is entry %d", ErrBroken, v.seq, headSeq)
+	}
+	for seq := range v.checkpoints {
+		if seq > v.seq {
+			return fmt.Errorf("%w: a signed checkpoint covers entry %d but the chain ends at entry %d", ErrBroken, seq, v.seq)
+		}
+	}
+	return nil
+}
+
+// Head returns the position and hash of the last verified entry
+func (v *Verifier) Head() (int64, []byte) {
+	return v.seq, v.head
+}
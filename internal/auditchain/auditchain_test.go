@@ -0,0 +1,219 @@
+package auditchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testEntry is an entry as stored, with its link
+type testEntry struct {
+	id     string
+	link   Link
+	fields []Field
+}
+
+// buildChain appends n entries to a chain the way a writer does
+func buildChain(chain string, n int) []testEntry {
+	entries := make([]testEntry, 0, n)
+	head := Genesis()
+	for seq := int64(1); seq <= int64(n); seq++ {
+		fields := []Field{
+			String("id", fmt.Sprintf("entry-%d", seq)),
+			Int("user_id", seq*10),
+			Bool("success", seq%2 == 0),
+			Time("timestamp", time.Date(2025, 7, 1, 12, 0, int(seq), 0, time.UTC)),
+			JSON("result", []byte(`{"b": 1, "a": [true, null]}`)),
+			Null("comment"),
+		}
+		hash := Hash(chain, seq, head, fields...)
+		entries = append(entries, testEntry{
+			id:     fmt.Sprintf("entry-%d", seq),
+			link:   Link{Seq: seq, PrevHash: head, Hash: hash},
+			fields: fields,
+		})
+		head = hash
+	}
+	return entries
+}
+
+// verify runs a verifier over entries and returns the first break
+func verify(chain string, entries []testEntry, checkpoints []Checkpoint, headSeq int64, headHash []byte) error {
+	verifier := NewVerifier(chain, checkpoints)
+	for _, entry := range entries {
+		if err := verifier.Next(entry.id, entry.link, entry.fields...); err != nil {
+			return err
+		}
+	}
+	return verifier.Finish(headSeq, headHash)
+}
+
+func TestVerifier_IntactChain(t *testing.T) {
+	entries := buildChain("test", 5)
+	last := entries[len(entries)-1].link
+
+	if err := verify("test", entries, nil, last.Seq, last.Hash); err != nil {
+		t.Fatalf("Expected intact chain to verify, got %v", err)
+	}
+	if err := verify("other", entries, nil, last.Seq, last.Hash); !errors.Is(err, ErrBroken) {
+		t.Errorf("Expected entries to be bound to their chain, got %v", err)
+	}
+	if err := verify("test", nil, nil, 0, Genesis()); err != nil {
+		t.Errorf("Expected empty chain to verify, got %v", err)
+	}
+}
+
+func TestVerifier_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]testEntry) []testEntry
+	}{
+		{"modified field", func(entries []testEntry) []testEntry {
+			entries[2].fields[1] = Int("user_id", 999)
+			return entries
+		}},
+		{"field set to null", func(entries []testEntry) []testEntry {
+			entries[2].fields[0] = Null("id")
+			return entries
+		}},
+		{"deleted entry", func(entries []testEntry) []testEntry {
+			return append(entries[:2], entries[3:]...)
+		}},
+		{"reordered entries", func(entries []testEntry) []testEntry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}},
+		{"rehashed entry", func(entries []testEntry) []testEntry {
+			// Recomputing the hash of a modified entry breaks the link of the next one
+			entries[2].fields[1] = Int("user_id", 999)
+			entries[2].link.Hash = Hash("test", 3, entries[2].link.PrevHash, entries[2].fields...)
+			return entries
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := buildChain("test", 5)
+			last := entries[len(entries)-1].link
+			err := verify("test", tt.tamper(entries), nil, last.Seq, last.Hash)
+			if !errors.Is(err, ErrBroken) {
+				t.Errorf("Expected ErrBroken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifier_DetectsTruncation(t *testing.T) {
+	signer, err := GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	entries := buildChain("test", 5)
+	checkpoint := signer.Sign("test", 4, entries[3].link.Hash, time.Now())
+
+	// Cutting entries from the end and moving the head back goes unnoticed
+	// by the links alone, but not by a checkpoint past the new end
+	truncated := entries[:3]
+	last := truncated[len(truncated)-1].link
+	if err := verify("test", truncated, nil, last.Seq, last.Hash); err != nil {
+		t.Fatalf("Expected truncated chain without checkpoints to verify, got %v", err)
+	}
+	if err := verify("test", truncated, []Checkpoint{*checkpoint}, last.Seq, last.Hash); !errors.Is(err, ErrBroken) {
+		t.Errorf("Expected checkpoint to detect truncation, got %v", err)
+	}
+
+	// Cutting entries without moving the head back is detected by the head
+	head := entries[4].link
+	if err := verify("test", truncated, nil, head.Seq, head.Hash); !errors.Is(err, ErrBroken) {
+		t.Errorf("Expected head to detect truncation, got %v", err)
+	}
+
+	// A chain rebuilt from a modified entry does not match its checkpoint
+	rebuilt := buildChain("test", 5)
+	rebuilt[3].fields[1] = Int("user_id", 999)
+	head = rebuilt[3].link
+	rebuilt[3].link.Hash = Hash("test", 4, head.PrevHash, rebuilt[3].fields...)
+	if err := verify("test", rebuilt[:4], []Checkpoint{*checkpoint}, 4, rebuilt[3].link.Hash); !errors.Is(err, ErrBroken) {
+		t.Errorf("Expected checkpoint to detect a rebuilt chain, got %v", err)
+	}
+}
+
+func TestJSON_Canonical(t *testing.T) {
+	a := JSON("result", []byte(`{"b": 1, "a": [true, null], "c": {"y": "x", "x": 1.50}}`))
+	b := JSON("result", []byte(`{"a":[true,null],"b":1,"c":{"x":1.50,"y":"x"}}`))
+	if !bytes.Equal(a.value, b.value) {
+		t.Errorf("Expected equivalent documents to hash the same: %s != %s", a.value, b.value)
+	}
+
+	c := JSON("result", []byte(`{"a":[true,null],"b":2,"c":{"x":1.50,"y":"x"}}`))
+	if bytes.Equal(a.value, c.value) {
+		t.Error("Expected different documents to hash differently")
+	}
+}
+
+func TestTime_Precision(t *testing.T) {
+	local := time.Date(2025, 7, 1, 14, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	stored := time.Date(2025, 7, 1, 12, 0, 0, 123456000, time.UTC)
+	if !bytes.Equal(Time("ts", local).value, Time("ts", stored).value) {
+		t.Error("Expected a timestamp to hash the same after a round trip through the database")
+	}
+}
+
+func TestCheckpoint_SignAndVerify(t *testing.T) {
+	signer, err := GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	other, err := GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+
+	checkpoint := signer.Sign("test", 42, bytes.Repeat([]byte{7}, HashSize), time.Now())
+	if err := VerifyCheckpoint(signer.PublicKey(), checkpoint); err != nil {
+		t.Fatalf("Expected checkpoint to verify, got %v", err)
+	}
+	if err := VerifyCheckpoint(other.PublicKey(), checkpoint); !errors.Is(err, ErrBadCheckpoint) {
+		t.Errorf("Expected checkpoint of another key to be rejected, got %v", err)
+	}
+
+	tampered := *checkpoint
+	tampered.Seq = 41
+	if err := VerifyCheckpoint(signer.PublicKey(), &tampered); !errors.Is(err, ErrBadCheckpoint) {
+		t.Errorf("Expected modified checkpoint to be rejected, got %v", err)
+	}
+}
+
+func TestSigner_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.pem")
+	signer, err := GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	if err := signer.Save(path); err != nil {
+		t.Fatalf("Failed to save signer: %v", err)
+	}
+	if err := signer.Save(path); err == nil {
+		t.Error("Expected an existing key not to be overwritten")
+	}
+
+	loaded, err := LoadSigner(path)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	if loaded.KeyID() != signer.KeyID() {
+		t.Errorf("Expected key ID %s, got %s", signer.KeyID(), loaded.KeyID())
+	}
+
+	public, err := LoadPublicKey(PublicKeyPath(path))
+	if err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+	checkpoint := loaded.Sign("test", 1, Genesis(), time.Now())
+	if err := VerifyCheckpoint(public, checkpoint); err != nil {
+		t.Errorf("Expected checkpoint to verify with the saved public key, got %v", err)
+	}
+}
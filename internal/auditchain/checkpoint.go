@@ -0,0 +1,190 @@
+package auditchain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpointDomain separates checkpoint signatures from other signatures
+const checkpointDomain = "hashpost-audit-checkpoint-v1"
+
+// ErrBadCheckpoint is returned when a checkpoint signature does not verify
+var ErrBadCheckpoint = errors.New("invalid audit checkpoint")
+
+// Checkpoint is a signed statement that a chain had a given hash at a given
+// entry. Once published, the entries up to it cannot be changed without the
+// checkpoint key.
+type Checkpoint struct {
+	Chain     string
+	Seq       int64
+	Hash      []byte
+	CreatedAt time.Time
+	KeyID     string
+	Signature []byte
+}
+
+// payload returns the signed bytes of a checkpoint
+func (c *Checkpoint) payload() []byte {
+	h := sha256.New()
+	writeBytes(h, []byte(checkpointDomain))
+	writeBytes(h, []byte(c.Chain))
+	binary.Write(h, binary.BigEndian, c.Seq)
+	writeBytes(h, c.Hash)
+	binary.Write(h, binary.BigEndian, c.CreatedAt.UnixMicro())
+	return h.Sum(nil)
+}
+
+// Signer signs checkpoints with an Ed25519 key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// GenerateSigner creates a signer with a new key
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate checkpoint key: %w", err)
+	}
+	return newSigner(key), nil
+}
+
+func newSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		key:   key,
+		keyID: KeyID(key.Public().(ed25519.PublicKey)),
+	}
+}
+
+// LoadSigner reads a PKCS #8 PEM checkpoint key. The returned error wraps
+// os.ErrNotExist if the file does not exist.
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no PKCS #8 private key in %s", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint key %s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint key %s is not an Ed25519 key", path)
+	}
+	return newSigner(key), nil
+}
+
+// Save writes the private key to path, readable only by the owner, and the
+// public key auditors verify checkpoints with to path.pub. An existing key
+// is never overwritten.
+func (s *Signer) Save(path string) error {
+	private, err := x509.MarshalPKCS8PrivateKey(s.key)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint key: %w", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(s.PublicKey())
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint public key: %w", err)
+	}
+
+	if err := writePEM(path, "PRIVATE KEY", private, 0600); err != nil {
+		return err
+	}
+	return writePEM(PublicKeyPath(path), "PUBLIC KEY", public, 0644)
+}
+
+// writePEM writes a PEM block to a new file
+func writePEM(path, blockType string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: data}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// PublicKey returns the public key checkpoints are verified with
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID returns the ID of the signer's key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign signs a checkpoint of a chain at an entry
+func (s *Signer) Sign(chain string, seq int64, hash []byte, now time.Time) *Checkpoint {
+	checkpoint := &Checkpoint{
+		Chain:     chain,
+		Seq:       seq,
+		Hash:      hash,
+		CreatedAt: now.UTC().Truncate(time.Microsecond),
+		KeyID:     s.keyID,
+	}
+	checkpoint.Signature = ed25519.Sign(s.key, checkpoint.payload())
+	return checkpoint
+}
+
+// PublicKeyPath returns the path of the public key saved with a checkpoint key
+func PublicKeyPath(path string) string {
+	return path + ".pub"
+}
+
+// LoadPublicKey reads a PEM checkpoint public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint public key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no public key in %s", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint public key %s: %w", path, err)
+	}
+	public, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint public key %s is not an Ed25519 key", path)
+	}
+	return public, nil
+}
+
+// KeyID returns the ID of a checkpoint public key: the first 8 bytes of its
+// SHA-256 hash, in hex
+func KeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// VerifyCheckpoint checks that a checkpoint was signed by the key
+func VerifyCheckpoint(public ed25519.PublicKey, checkpoint *Checkpoint) error {
+	if checkpoint.KeyID != KeyID(public) {
+		return fmt.Errorf("%w: checkpoint at entry %d of %s was signed by key %s", ErrBadCheckpoint, checkpoint.Seq, checkpoint.Chain, checkpoint.KeyID)
+	}
+	if !ed25519.Verify(public, checkpoint.payload(), checkpoint.Signature) {
+		return fmt.Errorf("%w: bad signature on checkpoint at entry %d of %s", ErrBadCheckpoint, checkpoint.Seq, checkpoint.Chain)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package compliance watches the cases of the legal team for missed
+// deadlines.
+package compliance
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// OverdueEventType is the system event recorded when an open compliance
+// report passes its due date
+const OverdueEventType = "compliance_report_overdue"
+
+// eventComponent is the source component of the system events recorded here
+const eventComponent = "compliance"
+
+// OverdueMonitor raises an alert for every open compliance report that
+// passes its due date
+type OverdueMonitor struct {
+	complianceReportDAO *dao.ComplianceReportDAO
+	systemEventDAO      *dao.SystemEventDAO
+}
+
+// NewOverdueMonitor creates an overdue monitor
+func NewOverdueMonitor(db bob.Executor) *OverdueMonitor {
+	return &OverdueMonitor{
+		complianceReportDAO: dao.NewComplianceReportDAO(db),
+		systemEventDAO:      dao.NewSystemEventDAO(db),
+	}
+}
+
+// Check raises the alerts of reports that became overdue since the last
+// check and returns the reports. An alert is a warning in the log and the
+// system event log, and an entry in the history of the report.
+func (m *OverdueMonitor) Check(ctx context.Context) ([]dao.ComplianceReport, error) {
+	overdue, err := m.complianceReportDAO.ClaimOverdueReports(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range overdue {
+		report := &overdue[i]
+		log.Warn().
+			Str("report_id", report.ReportID.String()).
+			Str("report_type", report.ReportType).
+			Str("status", report.Status).
+			Time("due_date", report.DueDate.V).
+			Int64("assigned_user_id", report.AssignedUserID.V).
+			Msg("Compliance report is overdue")
+
+		data := map[string]interface{}{
+			"report_id":   report.ReportID.String(),
+			"report_type": report.ReportType,
+			"status":      report.Status,
+			"due_date":    report.DueDate.V.Format(time.DateOnly),
+		}
+		if report.AssignedUserID.Valid {
+			data["assigned_user_id"] = report.AssignedUserID.V
+		}
+		if err := m.systemEventDAO.RecordEvent(ctx, OverdueEventType, dao.SystemEventSeverityWarning,
+			"Compliance report is past its due date", eventComponent, data); err != nil {
+			log.Error().Err(err).Str("report_id", report.ReportID.String()).Msg("Failed to record compliance overdue event")
+		}
+
+		if err := m.complianceReportDAO.RecordEvent(ctx, &dao.ComplianceReportEvent{
+			ReportID:  report.ReportID,
+			EventType: dao.ComplianceEventOverdue,
+			Status:    sql.Null[string]{V: report.Status, Valid: true},
+		}); err != nil {
+			log.Error().Err(err).Str("report_id", report.ReportID.String()).Msg("Failed to record compliance report history")
+		}
+	}
+	return overdue, nil
+}
+
+// Schedule runs Check every interval until ctx is done
+func (m *OverdueMonitor) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Check(ctx); err != nil {
+			log.Error().Err(err).Msg("Compliance overdue check failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
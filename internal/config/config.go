@@ -11,13 +11,17 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	Logging  LoggingConfig
-	IBE      IBEConfig
-	JWT      JWTConfig
-	Security SecurityConfig
-	CORS     CORSConfig
+	Database  DatabaseConfig
+	Server    ServerConfig
+	Logging   LoggingConfig
+	IBE       IBEConfig
+	JWT       JWTConfig
+	Security  SecurityConfig
+	CORS      CORSConfig
+	Mail      MailConfig
+	RateLimit RateLimitConfig
+	Audit     AuditConfig
+	Privacy   PrivacyConfig
 }
 
 // DatabaseConfig holds database connection configuration
@@ -55,7 +59,16 @@ type IBEConfig struct {
 	MasterKeyPath string // Path to master key file (for persistence)
 	KeyVersion    int    // Current key version
 	Salt          string // Salt for fingerprint generation (defaults to "fingerprint_salt_v1")
-	KeyRotation   struct {
+	SharesDir     string // Directory of Shamir shares of locked domain masters, unlocked at startup (optional)
+
+	KeyProvider          string // Where domain masters are kept: dir, env, keystore or kms
+	KeystorePath         string // Keystore file of the keystore and kms providers
+	KeystorePassphraseFD int    // File descriptor the keystore passphrase is read from; prompted for on a terminal
+	KMSURL               string // KMS of the kms provider: http(s):// URL or unix:///path/to/socket
+	KMSKeyID             string // KMS key wrapping the domain masters
+	KMSToken             string // Bearer token for the KMS (optional)
+
+	KeyRotation struct {
 		Enabled     bool
 		Interval    time.Duration
 		GracePeriod time.Duration
@@ -64,9 +77,10 @@ type IBEConfig struct {
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret      string
-	Expiration  time.Duration
-	Development bool // Controls cookie security settings
+	KeysDir          string // Directory holding the signing key set
+	SigningAlgorithm string // Algorithm for keys generated in development: EdDSA or ES256
+	Expiration       time.Duration
+	Development      bool // Controls cookie security settings and development key generation
 }
 
 // CORSConfig holds CORS configuration
@@ -78,14 +92,142 @@ type CORSConfig struct {
 	MaxAge           int
 }
 
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	Driver       string // smtp, file or memory
+	From         string // Sender address, e.g. "HashPost <no-reply@example.com>"
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string // Directory the file driver writes messages to
+	LinkBaseURL  string // Base URL of the web client that handles links in emails
+}
+
+// RateLimitConfig holds request rate limiting configuration
+type RateLimitConfig struct {
+	Enabled   bool
+	Backend   string // memory for a single node, postgres for limits shared between nodes
+	KeySecret string // HMAC key for bucket keys; must be shared by nodes using the postgres backend
+
+	// Budgets per limit class
+	Read        RateLimitBudgets
+	Write       RateLimitBudgets
+	Vote        RateLimitBudgets
+	Report      RateLimitBudgets
+	Correlation RateLimitBudgets
+}
+
+// RateLimitBudgets holds the limits of one class for each kind of client
+type RateLimitBudgets struct {
+	Anonymous RateLimit // Per client address
+	User      RateLimit // Per user, across all of their pseudonyms
+	APIKey    RateLimit // Per API key pseudonym
+}
+
+// RateLimit allows Requests per Period, refilled continuously. A client may
+// burst up to Requests at once. A zero Requests means no limit.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
 // SecurityConfig holds security-related configuration
 type SecurityConfig struct {
-	EnableMFA bool // Controls whether MFA requirements are enforced
+	EnableMFA       bool          // Controls whether MFA requirements are enforced
+	MFAIssuer       string        // Issuer name shown in authenticator apps
+	MFAStepUpWindow time.Duration // How long a completed MFA challenge satisfies sensitive actions
+	MFAKeyFile      string        // Key TOTP secrets are encrypted with at rest; losing it disables every authenticator
+
+	EmailVerificationTTL time.Duration // How long an email verification link is valid
+	PasswordResetTTL     time.Duration // How long a password reset link is valid
+	// UnverifiedRestrictedActions are the actions an account cannot take until its email is verified
+	UnverifiedRestrictedActions []string
+
+	// Login brute-force protection
+	AccountLockout LoginLockoutPolicy // Failed logins against a single account
+	AdminLockout   LoginLockoutPolicy // Failed logins against accounts with correlation capabilities
+	IPLockout      LoginLockoutPolicy // Failed logins from a single client address
+
+	// Dual control of identity correlation
+	CorrelationApproval CorrelationApprovalPolicy
+	// DisclosureEmbargo is how long after a correlation its pseudonym is told about it
+	DisclosureEmbargo time.Duration
+	// Detection of unusual correlation activity
+	CorrelationAnomaly CorrelationAnomalyPolicy
+	// Emergency identity correlation without prior approval
+	BreakGlass BreakGlassPolicy
 
 	// Password validation settings
 	PasswordValidation PasswordValidationConfig
 }
 
+// LoginLockoutPolicy controls how failed logins slow down and then lock out
+// further attempts. Once FreeFailures is exceeded, each failure blocks new
+// attempts for BaseDelay, doubling with every further failure up to MaxDelay.
+// Reaching MaxFailures locks attempts out for LockoutDuration, again doubling
+// with every further failure up to MaxLockout.
+type LoginLockoutPolicy struct {
+	FreeFailures    int           // Failures allowed before attempts are delayed
+	BaseDelay       time.Duration // Delay after the first failure past FreeFailures
+	MaxDelay        time.Duration // Upper bound for the delay
+	MaxFailures     int           // Failures that lock attempts out
+	LockoutDuration time.Duration // Length of the first lockout
+	MaxLockout      time.Duration // Upper bound for a lockout
+	FailureWindow   time.Duration // Failures are forgotten after this long without another
+}
+
+// CorrelationApprovalPolicy controls the review of identity correlation
+// requests. A pending request must be approved by a different user holding
+// one of ApproverRoles within RequestTTL, and the approved request must be
+// executed within ApprovalTTL.
+type CorrelationApprovalPolicy struct {
+	ApproverRoles []string      // Roles whose holders may approve or reject a request
+	RequestTTL    time.Duration // How long a request waits for review
+	ApprovalTTL   time.Duration // How long an approval can be executed
+}
+
+// CorrelationAnomalyPolicy controls the detection of unusual correlation
+// activity. Completed correlations are checked every Interval. A person
+// completing Burst.Requests correlations within Burst.Period, looking up the
+// same pseudonym Repeat.Requests times within Repeat.Period, or correlating
+// outside working hours raises an alert to platform admins.
+type CorrelationAnomalyPolicy struct {
+	Interval     time.Duration // How often new correlations are checked (0 disables detection)
+	Burst        RateLimit     // Correlations by one person that make a burst
+	Repeat       RateLimit     // Lookups of one pseudonym by one person that make a repeat lookup
+	WorkdayStart int           // Hour working hours start
+	WorkdayEnd   int           // Hour working hours end
+	WorkingDays  []string      // Weekdays with working hours, such as mon
+	Timezone     string        // IANA time zone of working hours
+}
+
+// BreakGlassPolicy controls emergency identity correlation. A holder of one
+// of Roles may correlate an identity without prior approval under a role key
+// that expires after KeyTTL. A different admin holding one of ReviewerRoles
+// must sign the use off within ReviewWindow, or the account that used it is
+// flagged.
+type BreakGlassPolicy struct {
+	Roles         []string      // Roles whose holders may break the glass
+	KeyTTL        time.Duration // Lifetime of the emergency role key
+	ReviewerRoles []string      // Roles whose holders review break-glass uses; they are notified of each use
+	ReviewWindow  time.Duration // How long a use waits for sign-off before the account is flagged
+}
+
+// AuditConfig holds configuration of the hash-chained audit logs
+type AuditConfig struct {
+	CheckpointKeyFile  string // Ed25519 key that signs checkpoints; its public key is saved next to it with a .pub suffix
+	CheckpointInterval int    // Entries between automatic checkpoints of a chain (0 disables them)
+	DisclosureKeyFile  string // Ed25519 key that signs legal disclosure bundles; its public key is saved next to it with a .pub suffix
+}
+
+// PrivacyConfig holds configuration of how client addresses and user agents
+// are reduced before they are stored
+type PrivacyConfig struct {
+	Backend     string        // memory for a single node, postgres for hashing keys shared between nodes
+	MatchWindow time.Duration // How long hashed addresses can be matched; daily keys are deleted after it
+}
+
 // PasswordValidationConfig holds password validation rules
 type PasswordValidationConfig struct {
 	MinLength          int  // Minimum password length
@@ -127,6 +269,15 @@ func Load() (*Config, error) {
 			MasterKeyPath: getEnv("IBE_MASTER_KEY_PATH", "./keys/master.key"),
 			KeyVersion:    getEnvAsInt("IBE_KEY_VERSION", 1),
 			Salt:          getEnv("IBE_SALT", "fingerprint_salt_v1"),
+			SharesDir:     getEnv("IBE_MASTER_SHARES_DIR", ""),
+
+			KeyProvider:          getEnv("IBE_KEY_PROVIDER", "dir"),
+			KeystorePath:         getEnv("IBE_KEYSTORE_PATH", "./keys/ibe.keystore"),
+			KeystorePassphraseFD: getEnvAsInt("IBE_KEYSTORE_PASSPHRASE_FD", 0),
+			KMSURL:               getEnv("IBE_KMS_URL", "unix:///run/hashpost/kms.sock"),
+			KMSKeyID:             getEnv("IBE_KMS_KEY_ID", "hashpost-ibe"),
+			KMSToken:             getEnv("IBE_KMS_TOKEN", ""),
+
 			KeyRotation: struct {
 				Enabled     bool
 				Interval    time.Duration
@@ -138,12 +289,68 @@ func Load() (*Config, error) {
 			},
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "your-jwt-secret-key-change-in-production"),
-			Expiration:  getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
-			Development: getEnvAsBool("JWT_DEVELOPMENT", true),
+			KeysDir:          getEnv("JWT_KEYS_DIR", "./keys/jwt"),
+			SigningAlgorithm: getEnv("JWT_SIGNING_ALG", "EdDSA"),
+			Expiration:       getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
+			Development:      getEnvAsBool("JWT_DEVELOPMENT", true),
 		},
 		Security: SecurityConfig{
-			EnableMFA: getEnvAsBool("SECURITY_ENABLE_MFA", false),
+			EnableMFA:            getEnvAsBool("SECURITY_ENABLE_MFA", false),
+			MFAIssuer:            getEnv("SECURITY_MFA_ISSUER", "HashPost"),
+			MFAStepUpWindow:      getEnvAsDuration("SECURITY_MFA_STEP_UP_WINDOW", 15*time.Minute),
+			MFAKeyFile:           getEnv("SECURITY_MFA_KEY_FILE", "./keys/mfa-secret.key"),
+			EmailVerificationTTL: getEnvAsDuration("SECURITY_EMAIL_VERIFICATION_TTL", 48*time.Hour),
+			PasswordResetTTL:     getEnvAsDuration("SECURITY_PASSWORD_RESET_TTL", time.Hour),
+			UnverifiedRestrictedActions: getEnvAsSlice("SECURITY_UNVERIFIED_RESTRICTED_ACTIONS",
+				[]string{"create_post", "create_comment", "vote", "create_subforum"}),
+			AccountLockout: getLoginLockoutPolicy("SECURITY_LOGIN_LOCKOUT", LoginLockoutPolicy{
+				FreeFailures:    3,
+				BaseDelay:       time.Second,
+				MaxDelay:        time.Minute,
+				MaxFailures:     10,
+				LockoutDuration: 15 * time.Minute,
+				MaxLockout:      24 * time.Hour,
+				FailureWindow:   24 * time.Hour,
+			}),
+			AdminLockout: getLoginLockoutPolicy("SECURITY_ADMIN_LOGIN_LOCKOUT", LoginLockoutPolicy{
+				FreeFailures:    1,
+				BaseDelay:       5 * time.Second,
+				MaxDelay:        5 * time.Minute,
+				MaxFailures:     5,
+				LockoutDuration: time.Hour,
+				MaxLockout:      7 * 24 * time.Hour,
+				FailureWindow:   7 * 24 * time.Hour,
+			}),
+			IPLockout: getLoginLockoutPolicy("SECURITY_IP_LOGIN_LOCKOUT", LoginLockoutPolicy{
+				FreeFailures:    20,
+				BaseDelay:       time.Second,
+				MaxDelay:        time.Minute,
+				MaxFailures:     100,
+				LockoutDuration: 15 * time.Minute,
+				MaxLockout:      24 * time.Hour,
+				FailureWindow:   time.Hour,
+			}),
+			CorrelationApproval: CorrelationApprovalPolicy{
+				ApproverRoles: getEnvAsSlice("SECURITY_CORRELATION_APPROVER_ROLES", []string{"legal_team", "platform_admin"}),
+				RequestTTL:    getEnvAsDuration("SECURITY_CORRELATION_REQUEST_TTL", 72*time.Hour),
+				ApprovalTTL:   getEnvAsDuration("SECURITY_CORRELATION_APPROVAL_TTL", 24*time.Hour),
+			},
+			DisclosureEmbargo: getEnvAsDuration("SECURITY_DISCLOSURE_EMBARGO", 90*24*time.Hour),
+			CorrelationAnomaly: CorrelationAnomalyPolicy{
+				Interval:     getEnvAsDuration("SECURITY_CORRELATION_ANOMALY_INTERVAL", 5*time.Minute),
+				Burst:        getEnvAsRateLimit("SECURITY_CORRELATION_BURST", RateLimit{Requests: 10, Period: time.Hour}),
+				Repeat:       getEnvAsRateLimit("SECURITY_CORRELATION_REPEAT", RateLimit{Requests: 3, Period: 7 * 24 * time.Hour}),
+				WorkdayStart: getEnvAsInt("SECURITY_CORRELATION_WORKDAY_START", 8),
+				WorkdayEnd:   getEnvAsInt("SECURITY_CORRELATION_WORKDAY_END", 18),
+				WorkingDays:  getEnvAsSlice("SECURITY_CORRELATION_WORKING_DAYS", []string{"mon", "tue", "wed", "thu", "fri"}),
+				Timezone:     getEnv("SECURITY_CORRELATION_TIMEZONE", "UTC"),
+			},
+			BreakGlass: BreakGlassPolicy{
+				Roles:         getEnvAsSlice("SECURITY_BREAK_GLASS_ROLES", []string{"trust_safety"}),
+				KeyTTL:        getEnvAsDuration("SECURITY_BREAK_GLASS_KEY_TTL", 15*time.Minute),
+				ReviewerRoles: getEnvAsSlice("SECURITY_BREAK_GLASS_REVIEWER_ROLES", []string{"legal_team"}),
+				ReviewWindow:  getEnvAsDuration("SECURITY_BREAK_GLASS_REVIEW_WINDOW", 24*time.Hour),
+			},
 			PasswordValidation: PasswordValidationConfig{
 				MinLength:          getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
 				RequireUppercase:   getEnvAsBool("PASSWORD_REQUIRE_UPPERCASE", true),
@@ -160,6 +367,55 @@ func Load() (*Config, error) {
 			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
 			MaxAge:           getEnvAsInt("CORS_MAX_AGE", 300),
 		},
+		RateLimit: RateLimitConfig{
+			Enabled:   getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			Backend:   getEnv("RATE_LIMIT_BACKEND", "memory"),
+			KeySecret: getEnv("RATE_LIMIT_KEY_SECRET", ""),
+			Read: getRateLimitBudgets("RATE_LIMIT_READ", RateLimitBudgets{
+				Anonymous: RateLimit{Requests: 120, Period: time.Minute},
+				User:      RateLimit{Requests: 300, Period: time.Minute},
+				APIKey:    RateLimit{Requests: 600, Period: time.Minute},
+			}),
+			Write: getRateLimitBudgets("RATE_LIMIT_WRITE", RateLimitBudgets{
+				Anonymous: RateLimit{Requests: 20, Period: time.Minute},
+				User:      RateLimit{Requests: 60, Period: time.Minute},
+				APIKey:    RateLimit{Requests: 120, Period: time.Minute},
+			}),
+			Vote: getRateLimitBudgets("RATE_LIMIT_VOTE", RateLimitBudgets{
+				Anonymous: RateLimit{Requests: 10, Period: time.Minute},
+				User:      RateLimit{Requests: 120, Period: time.Minute},
+				APIKey:    RateLimit{Requests: 120, Period: time.Minute},
+			}),
+			Report: getRateLimitBudgets("RATE_LIMIT_REPORT", RateLimitBudgets{
+				Anonymous: RateLimit{Requests: 5, Period: time.Hour},
+				User:      RateLimit{Requests: 20, Period: time.Hour},
+				APIKey:    RateLimit{Requests: 20, Period: time.Hour},
+			}),
+			Correlation: getRateLimitBudgets("RATE_LIMIT_CORRELATION", RateLimitBudgets{
+				Anonymous: RateLimit{Requests: 5, Period: time.Hour},
+				User:      RateLimit{Requests: 30, Period: time.Hour},
+				APIKey:    RateLimit{Requests: 10, Period: time.Hour},
+			}),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "file"),
+			From:         getEnv("MAIL_FROM", "HashPost <no-reply@hashpost.local>"),
+			SMTPHost:     getEnv("MAIL_SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvAsInt("MAIL_SMTP_PORT", 587),
+			SMTPUsername: getEnv("MAIL_SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("MAIL_SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "./tmp/mail"),
+			LinkBaseURL:  getEnv("MAIL_LINK_BASE_URL", "http://localhost:3000"),
+		},
+		Audit: AuditConfig{
+			CheckpointKeyFile:  getEnv("AUDIT_CHECKPOINT_KEY_FILE", "./keys/audit-checkpoint.pem"),
+			CheckpointInterval: getEnvAsInt("AUDIT_CHECKPOINT_INTERVAL", 100),
+			DisclosureKeyFile:  getEnv("AUDIT_DISCLOSURE_KEY_FILE", "./keys/disclosure-signing.pem"),
+		},
+		Privacy: PrivacyConfig{
+			Backend:     getEnv("PRIVACY_KEY_BACKEND", "postgres"),
+			MatchWindow: getEnvAsDuration("PRIVACY_MATCH_WINDOW", 7*24*time.Hour),
+		},
 	}
 
 	// If DATABASE_URL is provided, parse it to override individual settings
@@ -263,3 +519,54 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 	}
 	return defaultValue
 }
+
+// getLoginLockoutPolicy reads a lockout policy from environment variables
+// named after prefix, e.g. SECURITY_LOGIN_LOCKOUT_MAX_FAILURES
+func getLoginLockoutPolicy(prefix string, defaults LoginLockoutPolicy) LoginLockoutPolicy {
+	return LoginLockoutPolicy{
+		FreeFailures:    getEnvAsInt(prefix+"_FREE_FAILURES", defaults.FreeFailures),
+		BaseDelay:       getEnvAsDuration(prefix+"_BASE_DELAY", defaults.BaseDelay),
+		MaxDelay:        getEnvAsDuration(prefix+"_MAX_DELAY", defaults.MaxDelay),
+		MaxFailures:     getEnvAsInt(prefix+"_MAX_FAILURES", defaults.MaxFailures),
+		LockoutDuration: getEnvAsDuration(prefix+"_DURATION", defaults.LockoutDuration),
+		MaxLockout:      getEnvAsDuration(prefix+"_MAX_DURATION", defaults.MaxLockout),
+		FailureWindow:   getEnvAsDuration(prefix+"_WINDOW", defaults.FailureWindow),
+	}
+}
+
+// getRateLimitBudgets reads the budgets of a limit class from environment
+// variables named after prefix, e.g. RATE_LIMIT_READ_USER=300/1m
+func getRateLimitBudgets(prefix string, defaults RateLimitBudgets) RateLimitBudgets {
+	return RateLimitBudgets{
+		Anonymous: getEnvAsRateLimit(prefix+"_ANONYMOUS", defaults.Anonymous),
+		User:      getEnvAsRateLimit(prefix+"_USER", defaults.User),
+		APIKey:    getEnvAsRateLimit(prefix+"_API_KEY", defaults.APIKey),
+	}
+}
+
+// getEnvAsRateLimit gets an environment variable of the form <requests>/<period>, e.g. 60/1m
+func getEnvAsRateLimit(key string, defaultValue RateLimit) RateLimit {
+	if value := os.Getenv(key); value != "" {
+		if limit, err := parseRateLimit(value); err == nil {
+			return limit
+		}
+	}
+	return defaultValue
+}
+
+// parseRateLimit parses a rate limit of the form <requests>/<period>, e.g. 60/1m
+func parseRateLimit(value string) (RateLimit, error) {
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q must have the form <requests>/<period>", value)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", value)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period in rate limit %q", value)
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
@@ -0,0 +1,291 @@
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// AnomalyEventType is the system event recorded for unusual correlation
+// activity
+const AnomalyEventType = "correlation_anomaly"
+
+// CursorSetting is the system setting holding the position of the
+// correlation audit chain checked last
+const CursorSetting = "correlation_anomaly_cursor"
+
+// Kinds of unusual correlation activity
+const (
+	AnomalyBurst        = "burst"         // Many correlations by one person in a short time
+	AnomalyRepeatLookup = "repeat_lookup" // The same pseudonym looked up again and again
+	AnomalyOffHours     = "off_hours"     // A correlation outside working hours
+)
+
+// anomalyBatch is how many correlations one check reads
+const anomalyBatch = 500
+
+// Alert describes unusual correlation activity by a person
+type Alert struct {
+	Kind            string
+	UserID          int64
+	Role            string
+	AuditID         string
+	CorrelationType string
+	PseudonymID     string        // Pseudonym looked up; repeat lookups only
+	Count           int64         // Correlations within Period; bursts and repeat lookups only
+	Period          time.Duration // Bursts and repeat lookups only
+	Timestamp       time.Time
+}
+
+// Message describes the activity of an alert
+func (a *Alert) Message() string {
+	switch a.Kind {
+	case AnomalyBurst:
+		return fmt.Sprintf("User %d completed %d correlations within %s", a.UserID, a.Count, a.Period)
+	case AnomalyRepeatLookup:
+		return fmt.Sprintf("User %d looked up pseudonym %s %d times within %s", a.UserID, a.PseudonymID, a.Count, a.Period)
+	case AnomalyOffHours:
+		return fmt.Sprintf("User %d completed a %s correlation outside working hours", a.UserID, a.CorrelationType)
+	default:
+		return fmt.Sprintf("Unusual correlation activity by user %d", a.UserID)
+	}
+}
+
+// Notifier delivers alerts to the people supervising correlations
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Detector raises alerts for unusual activity in the correlation audit trail:
+// bursts of correlations, repeat lookups of the same pseudonym and
+// correlations outside working hours
+type Detector struct {
+	correlationAuditDAO *dao.CorrelationAuditDAO
+	systemSettingDAO    *dao.SystemSettingDAO
+	systemEventDAO      *dao.SystemEventDAO
+	policy              *config.CorrelationAnomalyPolicy
+	hours               *workingHours
+	notifier            Notifier
+}
+
+// NewDetector creates an anomaly detector that notifies alerts through notifier
+func NewDetector(db bob.Executor, policy *config.CorrelationAnomalyPolicy, notifier Notifier) (*Detector, error) {
+	hours, err := newWorkingHours(policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{
+		correlationAuditDAO: dao.NewCorrelationAuditDAO(db, nil),
+		systemSettingDAO:    dao.NewSystemSettingDAO(db),
+		systemEventDAO:      dao.NewSystemEventDAO(db),
+		policy:              policy,
+		hours:               hours,
+		notifier:            notifier,
+	}, nil
+}
+
+// Check raises the alerts of the correlations completed since the last check
+// and returns them. The correlations are claimed first, so each is checked
+// once even with several servers. An alert is a warning in the log and the
+// system event log, and a notification.
+func (d *Detector) Check(ctx context.Context) ([]Alert, error) {
+	setting, err := d.systemSettingDAO.GetSetting(ctx, CursorSetting)
+	if err != nil {
+		return nil, err
+	}
+	if setting == nil {
+		return nil, fmt.Errorf("system setting %s is missing", CursorSetting)
+	}
+	cursor, err := strconv.ParseInt(setting.SettingValue, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s setting: %w", CursorSetting, err)
+	}
+
+	entries, err := d.correlationAuditDAO.ListCompletedCorrelationsAfter(ctx, cursor, anomalyBatch)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	last := strconv.FormatInt(entries[len(entries)-1].ChainSeq.V, 10)
+	claimed, err := d.systemSettingDAO.CompareAndSetSetting(ctx, CursorSetting, setting.SettingValue, last)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	var alerts []Alert
+	for i := range entries {
+		found, err := d.inspect(ctx, &entries[i])
+		if err != nil {
+			return alerts, err
+		}
+		for _, alert := range found {
+			d.raise(ctx, alert)
+		}
+		alerts = append(alerts, found...)
+	}
+	return alerts, nil
+}
+
+// inspect returns the alerts of a completed correlation
+func (d *Detector) inspect(ctx context.Context, entry *dao.CorrelationAuditEntry) ([]Alert, error) {
+	at := entry.Timestamp.V
+	alert := func(kind string) Alert {
+		return Alert{
+			Kind:            kind,
+			UserID:          entry.UserID,
+			Role:            entry.RoleUsed,
+			AuditID:         entry.AuditID.String(),
+			CorrelationType: entry.CorrelationType,
+			Timestamp:       at,
+		}
+	}
+
+	var alerts []Alert
+	if !d.hours.contains(at) {
+		alerts = append(alerts, alert(AnomalyOffHours))
+	}
+
+	// Bursts and repeat lookups are raised by the correlation reaching their
+	// threshold, so an ongoing one is raised once
+	if burst := d.policy.Burst; burst.Requests > 0 {
+		count, err := d.correlationAuditDAO.CountCompletedCorrelations(ctx, entry.UserID, at.Add(-burst.Period), at)
+		if err != nil {
+			return nil, err
+		}
+		if count == int64(burst.Requests) {
+			a := alert(AnomalyBurst)
+			a.Count, a.Period = count, burst.Period
+			alerts = append(alerts, a)
+		}
+	}
+	if repeat := d.policy.Repeat; repeat.Requests > 0 && entry.RequestedPseudonym != "" {
+		count, err := d.correlationAuditDAO.CountCompletedCorrelationsOf(ctx, entry.UserID, entry.RequestedPseudonym, at.Add(-repeat.Period), at)
+		if err != nil {
+			return nil, err
+		}
+		if count == int64(repeat.Requests) {
+			a := alert(AnomalyRepeatLookup)
+			a.PseudonymID, a.Count, a.Period = entry.RequestedPseudonym, count, repeat.Period
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, nil
+}
+
+// raise logs, records and notifies an alert
+func (d *Detector) raise(ctx context.Context, alert Alert) {
+	log.Warn().
+		Str("kind", alert.Kind).
+		Int64("admin_id", alert.UserID).
+		Str("role", alert.Role).
+		Str("audit_id", alert.AuditID).
+		Str("pseudonym_id", alert.PseudonymID).
+		Int64("count", alert.Count).
+		Msg("Unusual correlation activity")
+
+	data := map[string]interface{}{
+		"kind":             alert.Kind,
+		"user_id":          alert.UserID,
+		"role":             alert.Role,
+		"audit_id":         alert.AuditID,
+		"correlation_type": alert.CorrelationType,
+		"timestamp":        alert.Timestamp.Format(time.RFC3339),
+	}
+	if alert.Kind != AnomalyOffHours {
+		data["count"] = alert.Count
+		data["period"] = alert.Period.String()
+	}
+	if alert.PseudonymID != "" {
+		data["pseudonym_id"] = alert.PseudonymID
+	}
+	if err := d.systemEventDAO.RecordEvent(ctx, AnomalyEventType, dao.SystemEventSeverityWarning,
+		alert.Message(), eventComponent, data); err != nil {
+		log.Error().Err(err).Str("audit_id", alert.AuditID).Msg("Failed to record correlation anomaly event")
+	}
+
+	if d.notifier != nil {
+		if err := d.notifier.Notify(ctx, alert); err != nil {
+			log.Error().Err(err).Str("audit_id", alert.AuditID).Msg("Failed to notify correlation anomaly")
+		}
+	}
+}
+
+// Schedule runs Check every interval until ctx is done
+func (d *Detector) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Check(ctx); err != nil {
+			log.Error().Err(err).Msg("Correlation anomaly check failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// weekdays maps the day names of the configuration to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// workingHours are the hours in which correlations are expected
+type workingHours struct {
+	start, end int
+	days       map[time.Weekday]bool
+	location   *time.Location
+}
+
+// newWorkingHours parses the working hours of a policy
+func newWorkingHours(policy *config.CorrelationAnomalyPolicy) (*workingHours, error) {
+	if policy.WorkdayStart < 0 || policy.WorkdayEnd > 24 || policy.WorkdayStart >= policy.WorkdayEnd {
+		return nil, fmt.Errorf("invalid working hours %d-%d", policy.WorkdayStart, policy.WorkdayEnd)
+	}
+	location, err := time.LoadLocation(policy.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working hours time zone: %w", err)
+	}
+
+	hours := &workingHours{
+		start:    policy.WorkdayStart,
+		end:      policy.WorkdayEnd,
+		days:     make(map[time.Weekday]bool),
+		location: location,
+	}
+	for _, name := range policy.WorkingDays {
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid working day %q", name)
+		}
+		hours.days[day] = true
+	}
+	return hours, nil
+}
+
+// contains reports whether a time is within working hours
+func (h *workingHours) contains(t time.Time) bool {
+	local := t.In(h.location)
+	return h.days[local.Weekday()] && local.Hour() >= h.start && local.Hour() < h.end
+}
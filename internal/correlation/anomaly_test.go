@@ -0,0 +1,49 @@
+package correlation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func TestWorkingHours(t *testing.T) {
+	hours, err := newWorkingHours(&config.CorrelationAnomalyPolicy{
+		WorkdayStart: 8,
+		WorkdayEnd:   18,
+		WorkingDays:  []string{"mon", "tue", "wed", "thu", "Fri"},
+		Timezone:     "America/New_York",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse working hours: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"WithinWorkday", time.Date(2025, 7, 2, 14, 0, 0, 0, time.UTC), true},
+		{"StartOfWorkday", time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC), true},
+		{"EndOfWorkday", time.Date(2025, 7, 2, 22, 0, 0, 0, time.UTC), false},
+		{"NightInTimezone", time.Date(2025, 7, 2, 3, 0, 0, 0, time.UTC), false},
+		{"Weekend", time.Date(2025, 7, 5, 14, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hours.contains(tt.at); got != tt.want {
+				t.Errorf("Expected %v for %s, got %v", tt.want, tt.at, got)
+			}
+		})
+	}
+
+	for _, policy := range []config.CorrelationAnomalyPolicy{
+		{WorkdayStart: 18, WorkdayEnd: 8, Timezone: "UTC"},
+		{WorkdayStart: 8, WorkdayEnd: 18, Timezone: "Nowhere/Special"},
+		{WorkdayStart: 8, WorkdayEnd: 18, Timezone: "UTC", WorkingDays: []string{"someday"}},
+	} {
+		if _, err := newWorkingHours(&policy); err == nil {
+			t.Errorf("Expected working hours %+v to be invalid", policy)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package correlation
+
+import (
+	"context"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// Reason codes of break-glass correlations
+const (
+	ReasonThreatOfViolence        = "threat_of_violence"
+	ReasonSelfHarm                = "self_harm"
+	ReasonChildSafety             = "child_safety"
+	ReasonTerrorism               = "terrorism"
+	ReasonLawEnforcementEmergency = "law_enforcement_emergency"
+)
+
+// BreakGlassKeyScope is the scope of the role keys granted for break-glass
+// correlations
+const BreakGlassKeyScope = "break_glass"
+
+// System events of break-glass correlations
+const (
+	// BreakGlassEventType is recorded for every break-glass correlation
+	BreakGlassEventType = "correlation_break_glass"
+	// BreakGlassFlaggedEventType is recorded when a break-glass correlation
+	// flags its requester's account
+	BreakGlassFlaggedEventType = "break_glass_account_flagged"
+)
+
+// BreakGlassNotifier delivers break-glass correlations to their reviewers
+type BreakGlassNotifier interface {
+	// NotifyBreakGlass asks the reviewers to review a break-glass correlation
+	NotifyBreakGlass(ctx context.Context, review *dao.BreakGlassReview) error
+	// NotifyFlagged tells the reviewers and platform admins that a
+	// break-glass correlation flagged its requester's account
+	NotifyFlagged(ctx context.Context, review *dao.BreakGlassReview) error
+}
+
+// BreakGlass raises the alerts of break-glass correlations: each use, and
+// each use that flags its requester's account because it was rejected or not
+// reviewed in time. An alert is a warning in the log and the system event
+// log, and a notification.
+type BreakGlass struct {
+	reviewDAO      *dao.BreakGlassReviewDAO
+	systemEventDAO *dao.SystemEventDAO
+	notifier       BreakGlassNotifier
+}
+
+// NewBreakGlass creates the break-glass alerts, notified through notifier
+func NewBreakGlass(db bob.Executor, notifier BreakGlassNotifier) *BreakGlass {
+	return &BreakGlass{
+		reviewDAO:      dao.NewBreakGlassReviewDAO(db),
+		systemEventDAO: dao.NewSystemEventDAO(db),
+		notifier:       notifier,
+	}
+}
+
+// Opened raises the alert of a break-glass correlation awaiting review
+func (b *BreakGlass) Opened(ctx context.Context, review *dao.BreakGlassReview) {
+	log.Warn().
+		Str("review_id", review.ReviewID.String()).
+		Int64("admin_id", review.RequesterID).
+		Str("role", review.RequesterRole).
+		Str("reason_code", review.ReasonCode).
+		Str("incident_id", review.IncidentID).
+		Time("review_due_at", review.ReviewDueAt).
+		Msg("Break-glass identity correlation")
+
+	b.record(ctx, review, BreakGlassEventType, dao.SystemEventSeverityWarning, "Identity correlated without prior approval")
+	if b.notifier != nil {
+		if err := b.notifier.NotifyBreakGlass(ctx, review); err != nil {
+			log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to notify break-glass reviewers")
+		}
+	}
+}
+
+// Flagged raises the alert of a break-glass correlation that flagged its
+// requester's account
+func (b *BreakGlass) Flagged(ctx context.Context, review *dao.BreakGlassReview) {
+	log.Warn().
+		Str("review_id", review.ReviewID.String()).
+		Int64("admin_id", review.RequesterID).
+		Str("status", review.Status).
+		Msg("Break-glass correlation flagged its requester")
+
+	message := "Break-glass correlation was rejected by its reviewer"
+	if review.Status == dao.BreakGlassReviewOverdue {
+		message = "Break-glass correlation was not reviewed in time"
+	}
+	b.record(ctx, review, BreakGlassFlaggedEventType, dao.SystemEventSeverityCritical, message)
+	if b.notifier != nil {
+		if err := b.notifier.NotifyFlagged(ctx, review); err != nil {
+			log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to notify flagged break-glass correlation")
+		}
+	}
+}
+
+// record records a system event of a break-glass correlation
+func (b *BreakGlass) record(ctx context.Context, review *dao.BreakGlassReview, eventType, severity, message string) {
+	data := map[string]interface{}{
+		"review_id":     review.ReviewID.String(),
+		"user_id":       review.RequesterID,
+		"role":          review.RequesterRole,
+		"reason_code":   review.ReasonCode,
+		"incident_id":   review.IncidentID,
+		"status":        review.Status,
+		"review_due_at": review.ReviewDueAt.Format(time.RFC3339),
+	}
+	if review.AuditID.Valid {
+		data["audit_id"] = review.AuditID.V.String()
+	}
+	if err := b.systemEventDAO.RecordEvent(ctx, eventType, severity, message, eventComponent, data); err != nil {
+		log.Error().Err(err).Str("review_id", review.ReviewID.String()).Msg("Failed to record break-glass event")
+	}
+}
+
+// CheckOverdue flags the requesters of break-glass correlations whose review
+// passed its deadline since the last check, raises their alerts and returns
+// the reviews
+func (b *BreakGlass) CheckOverdue(ctx context.Context) ([]dao.BreakGlassReview, error) {
+	overdue, err := b.reviewDAO.ClaimOverdueReviews(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range overdue {
+		b.Flagged(ctx, &overdue[i])
+	}
+	return overdue, nil
+}
+
+// Schedule runs CheckOverdue every interval until ctx is done
+func (b *BreakGlass) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := b.CheckOverdue(ctx); err != nil {
+			log.Error().Err(err).Msg("Break-glass review check failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package correlation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/stephenafamo/bob"
+)
+
+// supervisorRole is the role whose holders are notified of alerts
+const supervisorRole = "platform_admin"
+
+// MailNotifier emails alerts to every active platform admin, and break-glass
+// correlations to every active holder of a reviewer role
+type MailNotifier struct {
+	userDAO       *dao.UserDAO
+	mail          mailer.Mailer
+	reviewerRoles []string
+}
+
+// NewMailNotifier creates a notifier that sends alerts through mail, and
+// break-glass correlations to the holders of reviewerRoles
+func NewMailNotifier(db bob.Executor, mail mailer.Mailer, reviewerRoles []string) *MailNotifier {
+	return &MailNotifier{
+		userDAO:       dao.NewUserDAO(db),
+		mail:          mail,
+		reviewerRoles: reviewerRoles,
+	}
+}
+
+// Notify emails an alert to every active platform admin. Every admin is
+// tried; the errors of those that failed are returned together.
+func (n *MailNotifier) Notify(ctx context.Context, alert Alert) error {
+	return n.send(ctx, []string{supervisorRole}, mailer.Message{
+		Subject: fmt.Sprintf("HashPost correlation alert: %s", alert.Kind),
+		Body: fmt.Sprintf("%s.\n\nUser: %d\nRole: %s\nAudit entry: %s\nTime: %s\n\n"+
+			"Review the correlation audit trail of this user.\n",
+			alert.Message(), alert.UserID, alert.Role, alert.AuditID, alert.Timestamp.UTC().Format(time.RFC3339)),
+	})
+}
+
+// NotifyBreakGlass emails a break-glass correlation to every active
+// reviewer, asking them to review it before its deadline
+func (n *MailNotifier) NotifyBreakGlass(ctx context.Context, review *dao.BreakGlassReview) error {
+	return n.send(ctx, n.reviewerRoles, mailer.Message{
+		Subject: fmt.Sprintf("HashPost break-glass correlation: %s", review.ReasonCode),
+		Body: fmt.Sprintf("An identity was correlated without prior approval.\n\n%s\n"+
+			"Sign off or reject this correlation before %s, or the requester's account will be flagged.\n",
+			breakGlassDetails(review), review.ReviewDueAt.UTC().Format(time.RFC3339)),
+	})
+}
+
+// NotifyFlagged emails a break-glass correlation that flagged its requester's
+// account to every active reviewer and platform admin
+func (n *MailNotifier) NotifyFlagged(ctx context.Context, review *dao.BreakGlassReview) error {
+	reason := "was rejected by its reviewer"
+	if review.Status == dao.BreakGlassReviewOverdue {
+		reason = "was not reviewed in time"
+	}
+	return n.send(ctx, append([]string{supervisorRole}, n.reviewerRoles...), mailer.Message{
+		Subject: "HashPost break-glass correlation flagged",
+		Body: fmt.Sprintf("A break-glass correlation %s and its requester's account was flagged.\n\n%s\n"+
+			"The requester cannot break the glass again until the flag is lifted.\n",
+			reason, breakGlassDetails(review)),
+	})
+}
+
+// breakGlassDetails describes a break-glass correlation in an email
+func breakGlassDetails(review *dao.BreakGlassReview) string {
+	return fmt.Sprintf("Review: %s\nUser: %d\nRole: %s\nReason: %s\nIncident: %s\nTime: %s\n",
+		review.ReviewID, review.RequesterID, review.RequesterRole, review.ReasonCode,
+		review.IncidentID, review.CreatedAt.UTC().Format(time.RFC3339))
+}
+
+// send emails msg to every active holder of roles, once per user. Every user
+// is tried; the errors of those that failed are returned together.
+func (n *MailNotifier) send(ctx context.Context, roles []string, msg mailer.Message) error {
+	var errs []error
+	sent := make(map[int64]bool)
+	for _, role := range roles {
+		users, err := n.userDAO.ListActiveUsersByRole(ctx, role)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, user := range users {
+			if sent[user.UserID] {
+				continue
+			}
+			sent[user.UserID] = true
+			msg.To = user.Email
+			if err := n.mail.Send(ctx, msg); err != nil {
+				errs = append(errs, fmt.Errorf("failed to notify user %d: %w", user.UserID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,268 @@
+// Package correlation decides which correlations an admin may perform. What
+// a role may correlate is read from its role definition: the kind of
+// correlation it allows, the scope it applies to and how far back in time
+// it reaches. Quotas limit how many correlations a person performs, a
+// detector alerts platform admins to unusual correlation activity, and
+// break-glass correlations made without approval are flagged unless they are
+// reviewed in time.
+package correlation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/stephenafamo/bob"
+)
+
+// Correlation access of role_definitions.correlation_access. Identity access
+// includes fingerprint access.
+const (
+	AccessFingerprint = "fingerprint"
+	AccessIdentity    = "identity"
+)
+
+// Scopes of role_definitions.scope
+const (
+	ScopeSubforum = "subforum_specific"
+	ScopePlatform = "platform_wide"
+)
+
+// ErrNotAllowed is returned when none of an admin's roles allows a
+// correlation
+var ErrNotAllowed = errors.New("correlation not allowed")
+
+// Grant is the role a correlation is performed under and the limits its
+// definition puts on the correlation
+type Grant struct {
+	Role       string
+	Access     string
+	Scope      string
+	TimeWindow string         // As defined, such as "30_days"
+	Window     ibe.TimeWindow // TimeWindow parsed
+	SubforumID int32          // Subforum the correlation is limited to; subforum-specific scopes only
+	Since      time.Time      // Earliest activity the correlation covers; zero for unlimited windows
+}
+
+// SubforumSpecific reports whether the correlation is limited to the
+// activity in one subforum
+func (g *Grant) SubforumSpecific() bool {
+	return g.Scope == ScopeSubforum
+}
+
+// Policy grants correlations from the role definitions of an admin's roles.
+// Platform-wide roles may correlate any pseudonym active within the role's
+// time window. Subforum-specific roles may only correlate pseudonyms active
+// in a subforum the admin moderates within the role's time window, and only
+// see the pseudonyms active there.
+// Quotas limit how many correlations a person completes.
+type Policy struct {
+	roleDefinitionDAO   *dao.RoleDefinitionDAO
+	permissionDAO       *dao.PermissionDAO
+	postDAO             *dao.PostDAO
+	commentDAO          *dao.CommentDAO
+	correlationAuditDAO *dao.CorrelationAuditDAO
+	systemSettingDAO    *dao.SystemSettingDAO
+	systemEventDAO      *dao.SystemEventDAO
+	now                 func() time.Time
+}
+
+// NewPolicy creates a correlation policy
+func NewPolicy(db bob.Executor) *Policy {
+	return &Policy{
+		roleDefinitionDAO:   dao.NewRoleDefinitionDAO(db),
+		permissionDAO:       dao.NewPermissionDAO(db),
+		postDAO:             dao.NewPostDAO(db),
+		commentDAO:          dao.NewCommentDAO(db),
+		correlationAuditDAO: dao.NewCorrelationAuditDAO(db, nil),
+		systemSettingDAO:    dao.NewSystemSettingDAO(db),
+		systemEventDAO:      dao.NewSystemEventDAO(db),
+		now:                 time.Now,
+	}
+}
+
+// AuthorizeFingerprint grants a fingerprint correlation of a pseudonym in a
+// subforum. A platform-wide role is preferred; otherwise the admin must
+// moderate the subforum and the pseudonym must have been active there within
+// the widest time window of the admin's subforum-specific roles.
+func (p *Policy) AuthorizeFingerprint(ctx context.Context, userID int64, roles []string, subforumID int32, pseudonymID string) (*Grant, error) {
+	grants, err := p.grants(ctx, roles, AccessFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if grant := find(grants, ScopePlatform); grant != nil {
+		return p.reach(ctx, grant, pseudonymID)
+	}
+
+	grant := find(grants, ScopeSubforum)
+	if grant == nil {
+		return nil, fmt.Errorf("%w: no role allows fingerprint correlation", ErrNotAllowed)
+	}
+	moderator, err := p.permissionDAO.IsSubforumModerator(ctx, userID, subforumID)
+	if err != nil {
+		return nil, err
+	}
+	if !moderator {
+		return nil, fmt.Errorf("%w: role %s only correlates in subforums you moderate", ErrNotAllowed, grant.Role)
+	}
+
+	grant.SubforumID = subforumID
+	return p.reach(ctx, grant, pseudonymID)
+}
+
+// AuthorizeIdentity grants an identity correlation of a pseudonym in the
+// given scope
+func (p *Policy) AuthorizeIdentity(ctx context.Context, roles []string, scope, pseudonymID string) (*Grant, error) {
+	grants, err := p.grants(ctx, roles, AccessIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if scope != ScopePlatform {
+		return nil, fmt.Errorf("%w: identity correlation is only available platform-wide", ErrNotAllowed)
+	}
+	grant := find(grants, ScopePlatform)
+	if grant == nil {
+		return nil, fmt.Errorf("%w: no role allows platform-wide identity correlation", ErrNotAllowed)
+	}
+	return p.reach(ctx, grant, pseudonymID)
+}
+
+// reach starts the time window of a grant now and checks that the pseudonym
+// is within its reach
+func (p *Policy) reach(ctx context.Context, grant *Grant, pseudonymID string) (*Grant, error) {
+	if !grant.Window.Unlimited {
+		grant.Since = p.now().Add(-grant.Window.Lookback)
+	}
+	active, err := p.Active(ctx, grant, pseudonymID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		where := "on the platform"
+		if grant.SubforumSpecific() {
+			where = "in the subforum"
+		}
+		return nil, fmt.Errorf("%w: pseudonym has no activity %s within the %s window of role %s", ErrNotAllowed, where, grant.TimeWindow, grant.Role)
+	}
+	return grant, nil
+}
+
+// Active reports whether a pseudonym is within the reach of a grant: whether
+// it has posts or comments since the start of the grant's time window, in the
+// grant's subforum for subforum-specific grants. Time windows bound activity,
+// not when the pseudonym or its identity mapping was created, so an old
+// pseudonym that is still active can be correlated. Platform-wide grants with
+// an unlimited window reach every pseudonym.
+func (p *Policy) Active(ctx context.Context, grant *Grant, pseudonymID string) (bool, error) {
+	if !grant.SubforumSpecific() {
+		if grant.Window.Unlimited {
+			return true, nil
+		}
+		posts, err := p.postDAO.CountPostsByPseudonymSince(ctx, pseudonymID, grant.Since)
+		if err != nil {
+			return false, err
+		}
+		if posts > 0 {
+			return true, nil
+		}
+		comments, err := p.commentDAO.CountCommentsByPseudonymSince(ctx, pseudonymID, grant.Since)
+		if err != nil {
+			return false, err
+		}
+		return comments > 0, nil
+	}
+
+	posts, err := p.postDAO.CountPostsByPseudonymInSubforumSince(ctx, pseudonymID, grant.SubforumID, grant.Since)
+	if err != nil {
+		return false, err
+	}
+	if posts > 0 {
+		return true, nil
+	}
+	comments, err := p.commentDAO.CountCommentsByPseudonymInSubforumSince(ctx, pseudonymID, grant.SubforumID, grant.Since)
+	if err != nil {
+		return false, err
+	}
+	return comments > 0, nil
+}
+
+// grants returns the grants of the roles whose definitions allow a kind of
+// correlation
+func (p *Policy) grants(ctx context.Context, roles []string, access string) ([]Grant, error) {
+	definitions, err := p.roleDefinitionDAO.GetRoleDefinitions(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	return grantsFor(definitions, access)
+}
+
+// grantsFor returns a grant for each definition that allows a kind of
+// correlation in a known scope
+func grantsFor(definitions []*models.RoleDefinition, access string) ([]Grant, error) {
+	var grants []Grant
+	for _, definition := range definitions {
+		if !allows(definition.CorrelationAccess.V, access) {
+			continue
+		}
+		scope := definition.Scope.V
+		if scope != ScopePlatform && scope != ScopeSubforum {
+			continue
+		}
+		window, err := ibe.ParseTimeWindow(definition.TimeWindow.V)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window for role=%s: %w", definition.RoleName, err)
+		}
+		grants = append(grants, Grant{
+			Role:       definition.RoleName,
+			Access:     definition.CorrelationAccess.V,
+			Scope:      scope,
+			TimeWindow: definition.TimeWindow.V,
+			Window:     window,
+		})
+	}
+	return grants, nil
+}
+
+// allows reports whether a role's correlation access covers a kind of
+// correlation
+func allows(roleAccess, access string) bool {
+	switch roleAccess {
+	case AccessIdentity:
+		return access == AccessIdentity || access == AccessFingerprint
+	case AccessFingerprint:
+		return access == AccessFingerprint
+	default:
+		return false
+	}
+}
+
+// find returns the grant of a scope with the widest time window, or nil
+func find(grants []Grant, scope string) *Grant {
+	var widest *Grant
+	for i := range grants {
+		grant := &grants[i]
+		if grant.Scope != scope {
+			continue
+		}
+		if widest == nil || wider(grant.Window, widest.Window) {
+			widest = grant
+		}
+	}
+	if widest == nil {
+		return nil
+	}
+	found := *widest
+	return &found
+}
+
+// wider reports whether time window a reaches further back than b
+func wider(a, b ibe.TimeWindow) bool {
+	if a.Unlimited != b.Unlimited {
+		return a.Unlimited
+	}
+	return a.Lookback > b.Lookback
+}
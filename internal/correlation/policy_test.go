@@ -0,0 +1,76 @@
+package correlation
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/models"
+)
+
+// definition builds a role definition with its correlation settings
+func definition(role, access, scope, window string) *models.RoleDefinition {
+	return &models.RoleDefinition{
+		RoleName:          role,
+		CorrelationAccess: sql.Null[string]{V: access, Valid: true},
+		Scope:             sql.Null[string]{V: scope, Valid: true},
+		TimeWindow:        sql.Null[string]{V: window, Valid: true},
+	}
+}
+
+// defaultDefinitions are the correlating roles of the initial schema
+var defaultDefinitions = []*models.RoleDefinition{
+	definition("user", "none", "none", "none"),
+	definition("moderator", AccessFingerprint, ScopeSubforum, "30_days"),
+	definition("subforum_owner", AccessFingerprint, ScopeSubforum, "90_days"),
+	definition("trust_safety", AccessIdentity, ScopePlatform, "unlimited"),
+}
+
+func TestGrantsFor(t *testing.T) {
+	fingerprint, err := grantsFor(defaultDefinitions, AccessFingerprint)
+	if err != nil {
+		t.Fatalf("Failed to build grants: %v", err)
+	}
+	var roles []string
+	for _, grant := range fingerprint {
+		roles = append(roles, grant.Role)
+	}
+	if len(roles) != 3 || roles[0] != "moderator" || roles[2] != "trust_safety" {
+		t.Errorf("Expected moderator, subforum_owner and trust_safety to correlate fingerprints, got %v", roles)
+	}
+	if fingerprint[0].Window.Lookback != 30*24*time.Hour {
+		t.Errorf("Expected a 30 day window for moderators, got %v", fingerprint[0].Window)
+	}
+
+	identity, err := grantsFor(defaultDefinitions, AccessIdentity)
+	if err != nil {
+		t.Fatalf("Failed to build grants: %v", err)
+	}
+	if len(identity) != 1 || identity[0].Role != "trust_safety" || !identity[0].Window.Unlimited {
+		t.Errorf("Expected only trust_safety to correlate identities, got %+v", identity)
+	}
+
+	if _, err := grantsFor([]*models.RoleDefinition{definition("moderator", AccessFingerprint, ScopeSubforum, "forever")}, AccessFingerprint); err == nil {
+		t.Error("Expected an invalid time window to be an error")
+	}
+}
+
+func TestFind(t *testing.T) {
+	grants, _ := grantsFor(defaultDefinitions, AccessFingerprint)
+
+	subforum := find(grants, ScopeSubforum)
+	if subforum == nil || subforum.Role != "subforum_owner" {
+		t.Fatalf("Expected the widest subforum-specific window to win, got %+v", subforum)
+	}
+	if !subforum.SubforumSpecific() {
+		t.Error("Expected a subforum-specific grant")
+	}
+	subforum.SubforumID = 7
+	if grants[1].SubforumID != 0 {
+		t.Error("Expected find to return a copy of the grant")
+	}
+
+	if platform := find(grants[:2], ScopePlatform); platform != nil {
+		t.Errorf("Expected no platform-wide grant for moderators, got %+v", platform)
+	}
+}
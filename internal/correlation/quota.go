@@ -0,0 +1,123 @@
+package correlation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+)
+
+// QuotaSetting is the system setting holding the correlation quotas
+const QuotaSetting = "correlation_quotas"
+
+// QuotaEventType is the system event recorded when a correlation is refused
+// for exceeding a quota
+const QuotaEventType = "correlation_quota_exceeded"
+
+// eventComponent is the source component of the system events recorded here
+const eventComponent = "correlation"
+
+// Periods of the quotas
+const (
+	quotaDay   = 24 * time.Hour
+	quotaMonth = 30 * 24 * time.Hour
+)
+
+// ErrQuotaExceeded is returned when a person has used up a correlation quota
+var ErrQuotaExceeded = errors.New("correlation quota exceeded")
+
+// QuotaLimits are the correlations a person may complete in the last day and
+// the last 30 days. Zero is unlimited.
+type QuotaLimits struct {
+	Daily   int `json:"daily,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+}
+
+// Quotas are the correlation limits by role and by user ID. The limits of a
+// role apply to each holder of the role; a person's own limits replace those
+// of their role.
+type Quotas struct {
+	Roles map[string]QuotaLimits `json:"roles"`
+	Users map[string]QuotaLimits `json:"users"`
+}
+
+// ParseQuotas parses the value of the quota setting
+func ParseQuotas(value string) (*Quotas, error) {
+	var quotas Quotas
+	if err := json.Unmarshal([]byte(value), &quotas); err != nil {
+		return nil, fmt.Errorf("invalid %s setting: %w", QuotaSetting, err)
+	}
+	return &quotas, nil
+}
+
+// Limits returns the limits of a person correlating under a role
+func (q *Quotas) Limits(userID int64, role string) QuotaLimits {
+	if limits, ok := q.Users[strconv.FormatInt(userID, 10)]; ok {
+		return limits
+	}
+	return q.Roles[role]
+}
+
+// CheckQuota refuses a correlation under a role with ErrQuotaExceeded when
+// the person has already completed as many correlations as their daily or
+// monthly limit. A refusal is a warning in the log and the system event log.
+func (p *Policy) CheckQuota(ctx context.Context, userID int64, role string) error {
+	setting, err := p.systemSettingDAO.GetSetting(ctx, QuotaSetting)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		return nil
+	}
+	quotas, err := ParseQuotas(setting.SettingValue)
+	if err != nil {
+		return err
+	}
+	limits := quotas.Limits(userID, role)
+
+	now := p.now()
+	for _, quota := range []struct {
+		name   string
+		limit  int
+		period time.Duration
+	}{
+		{"daily", limits.Daily, quotaDay},
+		{"monthly", limits.Monthly, quotaMonth},
+	} {
+		if quota.limit <= 0 {
+			continue
+		}
+		count, err := p.correlationAuditDAO.CountCompletedCorrelations(ctx, userID, now.Add(-quota.period), now)
+		if err != nil {
+			return err
+		}
+		if count < int64(quota.limit) {
+			continue
+		}
+
+		log.Warn().
+			Int64("admin_id", userID).
+			Str("role", role).
+			Str("quota", quota.name).
+			Int("limit", quota.limit).
+			Int64("count", count).
+			Msg("Correlation quota exceeded")
+		if err := p.systemEventDAO.RecordEvent(ctx, QuotaEventType, dao.SystemEventSeverityWarning,
+			"Correlation refused for exceeding a quota", eventComponent, map[string]interface{}{
+				"user_id": userID,
+				"role":    role,
+				"quota":   quota.name,
+				"limit":   quota.limit,
+				"count":   count,
+			}); err != nil {
+			log.Error().Err(err).Int64("admin_id", userID).Msg("Failed to record correlation quota event")
+		}
+		return fmt.Errorf("%w: %d of %d %s correlations used", ErrQuotaExceeded, count, quota.limit, quota.name)
+	}
+	return nil
+}
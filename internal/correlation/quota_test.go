@@ -0,0 +1,36 @@
+package correlation
+
+import "testing"
+
+func TestQuotasLimits(t *testing.T) {
+	quotas, err := ParseQuotas(`{
+		"roles": {"moderator": {"daily": 20, "monthly": 200}, "trust_safety": {"monthly": 500}},
+		"users": {"42": {"daily": 5}}
+	}`)
+	if err != nil {
+		t.Fatalf("Failed to parse quotas: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID int64
+		role   string
+		want   QuotaLimits
+	}{
+		{"RoleLimits", 7, "moderator", QuotaLimits{Daily: 20, Monthly: 200}},
+		{"MissingLimitIsUnlimited", 7, "trust_safety", QuotaLimits{Monthly: 500}},
+		{"RoleWithoutQuota", 7, "legal_team", QuotaLimits{}},
+		{"UserLimitsReplaceRoleLimits", 42, "moderator", QuotaLimits{Daily: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotas.Limits(tt.userID, tt.role); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := ParseQuotas(`{"roles": []}`); err == nil {
+		t.Error("Expected an invalid setting to be an error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -31,6 +32,8 @@ func NewAPIKeyDAO(db bob.Executor) *APIKeyDAO {
 type APIKeyPermissions struct {
 	Roles        []string `json:"roles"`
 	Capabilities []string `json:"capabilities"`
+	// Scopes lists the operations the key may call, such as posts:write
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // CreateAPIKey creates a new API key
@@ -164,25 +167,48 @@ func (dao *APIKeyDAO) ValidateAPIKey(ctx context.Context, rawKey string) (*APIKe
 	}
 
 	// Parse permissions
+	permissions, err := ParseAPIKeyPermissions(apiKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.Debug().
+		Int64("key_id", apiKey.KeyID).
+		Str("key_name", apiKey.KeyName).
+		Str("pseudonym_id", apiKey.PseudonymID.V).
+		Msg("API key validated successfully")
+
+	return permissions, apiKey.PseudonymID.V, nil
+}
+
+// GetAPIKeyOwner returns the user owning the pseudonym an API key acts for,
+// from the pseudonym's identity mapping
+func (dao *APIKeyDAO) GetAPIKeyOwner(ctx context.Context, pseudonymID string) (int64, error) {
+	mapping, err := NewIdentityMappingDAO(dao.db).GetIdentityMappingByPseudonymID(ctx, pseudonymID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("API key pseudonym has no owner")
+		}
+		return 0, fmt.Errorf("failed to get API key owner: %w", err)
+	}
+	return mapping.UserID, nil
+}
+
+// ParseAPIKeyPermissions returns the permissions stored with an API key. A key
+// without stored permissions has none.
+func ParseAPIKeyPermissions(apiKey *models.APIKey) (*APIKeyPermissions, error) {
 	var permissions APIKeyPermissions
 	if apiKey.Permissions.Valid {
 		rawValue, err := apiKey.Permissions.V.Value()
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to get API key permissions value: %w", err)
+			return nil, fmt.Errorf("failed to get API key permissions value: %w", err)
 		}
 		err = json.Unmarshal(rawValue.([]byte), &permissions)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to parse API key permissions: %w", err)
+			return nil, fmt.Errorf("failed to parse API key permissions: %w", err)
 		}
 	}
-
-	log.Debug().
-		Int64("key_id", apiKey.KeyID).
-		Str("key_name", apiKey.KeyName).
-		Str("pseudonym_id", apiKey.PseudonymID.V).
-		Msg("API key validated successfully")
-
-	return &permissions, apiKey.PseudonymID.V, nil
+	return &permissions, nil
 }
 
 // UpdateAPIKey updates an API key
@@ -257,6 +283,18 @@ func (dao *APIKeyDAO) ActivateAPIKey(ctx context.Context, keyID int64) error {
 	return dao.UpdateAPIKey(ctx, keyID, updates)
 }
 
+// RotateAPIKey replaces the secret of an API key. The previous secret stops
+// working immediately; name, pseudonym, permissions and expiry are kept.
+func (dao *APIKeyDAO) RotateAPIKey(ctx context.Context, keyID int64, rawKey string) error {
+	keyHash := hashAPIKey(rawKey)
+
+	updates := &models.APIKeySetter{
+		KeyHash: &keyHash,
+	}
+
+	return dao.UpdateAPIKey(ctx, keyID, updates)
+}
+
 // UpdateLastUsed updates the last used timestamp for an API key
 func (dao *APIKeyDAO) UpdateLastUsed(ctx context.Context, keyID int64) error {
 	now := sql.Null[time.Time]{}
@@ -0,0 +1,330 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Hash-chained audit logs, named after their tables
+const (
+	CorrelationAuditChain = "correlation_audit"
+	KeyUsageAuditChain    = "key_usage_audit"
+)
+
+// AuditChains lists every hash-chained audit log
+var AuditChains = []string{CorrelationAuditChain, KeyUsageAuditChain}
+
+// maxAppendAttempts bounds how often an append that lost the race for the
+// chain head is retried
+const maxAppendAttempts = 20
+
+// auditChainPageSize is the number of entries read at a time when walking a chain
+const auditChainPageSize = 500
+
+// AuditChainHead is the newest entry of an audit chain
+type AuditChainHead struct {
+	Chain         string    `db:"chain"`
+	Seq           int64     `db:"seq"`
+	HeadHash      []byte    `db:"head_hash"`
+	UnchainedRows int64     `db:"unchained_rows"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+// auditCheckpoint is a stored checkpoint
+type auditCheckpoint struct {
+	Chain     string    `db:"chain"`
+	Seq       int64     `db:"seq"`
+	HeadHash  []byte    `db:"head_hash"`
+	KeyID     string    `db:"key_id"`
+	Signature []byte    `db:"signature"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AuditChainDAO appends entries to the hash-chained audit logs and signs
+// checkpoints of their heads
+type AuditChainDAO struct {
+	db                 bob.Executor
+	signer             *auditchain.Signer
+	checkpointInterval int64
+}
+
+// NewAuditChainDAO creates a new audit chain DAO. Every checkpointInterval
+// entries, the head of a chain is checkpointed with signer. A nil signer or
+// an interval of 0 disables automatic checkpoints.
+func NewAuditChainDAO(db bob.Executor, signer *auditchain.Signer, checkpointInterval int) *AuditChainDAO {
+	return &AuditChainDAO{
+		db:                 db,
+		signer:             signer,
+		checkpointInterval: int64(checkpointInterval),
+	}
+}
+
+// GetHead returns the head of a chain, or nil if the chain does not exist
+func (dao *AuditChainDAO) GetHead(ctx context.Context, chain string) (*AuditChainHead, error) {
+	head, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("chain", "seq", "head_hash", "unchained_rows", "updated_at"),
+		sm.From("audit_chains"),
+		sm.Where(psql.Quote("chain").EQ(psql.Arg(chain))),
+	), scan.StructMapper[AuditChainHead]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get audit chain head: %w", err)
+	}
+	return &head, nil
+}
+
+// appendEntry inserts an entry into table at the head of its chain. values
+// are the SQL values of columns, and fields the same values as hashed. The
+// insert only happens together with the update that moves the head from the
+// entry it was hashed against, so concurrent writers cannot fork the chain;
+// a writer that loses the race hashes against the new head and tries again.
+func (dao *AuditChainDAO) appendEntry(ctx context.Context, chain, table string, columns []string, values []any, fields []auditchain.Field) error {
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		head, err := dao.GetHead(ctx, chain)
+		if err != nil {
+			return err
+		}
+		if head == nil {
+			return fmt.Errorf("audit chain %s does not exist", chain)
+		}
+
+		seq := head.Seq + 1
+		hash := auditchain.Hash(chain, seq, head.HeadHash, fields...)
+
+		result, err := bob.Exec(ctx, dao.db, psql.Insert(
+			im.With("head").As(psql.Update(
+				um.Table("audit_chains"),
+				um.SetCol("seq").ToArg(seq),
+				um.SetCol("head_hash").ToArg(hash),
+				um.SetCol("updated_at").To(psql.Raw("NOW()")),
+				um.Where(psql.Quote("chain").EQ(psql.Arg(chain))),
+				um.Where(psql.Quote("seq").EQ(psql.Arg(head.Seq))),
+				um.Returning("seq"),
+			)),
+			im.Into(table, slices.Concat(columns, []string{"chain_seq", "prev_hash", "entry_hash"})...),
+			im.Query(psql.Select(
+				sm.Columns(slices.Concat(values, []any{
+					psql.Quote("head", "seq"),
+					psql.Cast(psql.Arg(head.HeadHash), "BYTEA"),
+					psql.Cast(psql.Arg(hash), "BYTEA"),
+				})...),
+				sm.From("head"),
+			)),
+		))
+		if err != nil {
+			return fmt.Errorf("failed to append to audit chain %s: %w", chain, err)
+		}
+		if appended, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to append to audit chain %s: %w", chain, err)
+		} else if appended == 0 {
+			continue
+		}
+
+		if dao.signer != nil && dao.checkpointInterval > 0 && seq%dao.checkpointInterval == 0 {
+			// The entry is recorded; a missing checkpoint is caught up by
+			// the next one
+			if _, err := dao.writeCheckpoint(ctx, chain, seq, hash); err != nil {
+				log.Error().Err(err).Str("chain", chain).Int64("seq", seq).Msg("Failed to write audit checkpoint")
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to append to audit chain %s: too many concurrent writers", chain)
+}
+
+// Checkpoint signs and stores a checkpoint of a chain at an entry. An
+// existing checkpoint of the entry is kept.
+func (dao *AuditChainDAO) Checkpoint(ctx context.Context, chain string, seq int64, hash []byte) (*auditchain.Checkpoint, error) {
+	if dao.signer == nil {
+		return nil, fmt.Errorf("no audit checkpoint key")
+	}
+	return dao.writeCheckpoint(ctx, chain, seq, hash)
+}
+
+// writeCheckpoint signs and stores a checkpoint of a chain at an entry
+func (dao *AuditChainDAO) writeCheckpoint(ctx context.Context, chain string, seq int64, hash []byte) (*auditchain.Checkpoint, error) {
+	checkpoint := dao.signer.Sign(chain, seq, hash, time.Now())
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("audit_checkpoints", "chain", "seq", "head_hash", "key_id", "signature", "created_at"),
+		im.Values(
+			psql.Arg(checkpoint.Chain), psql.Arg(checkpoint.Seq), psql.Arg(checkpoint.Hash),
+			psql.Arg(checkpoint.KeyID), psql.Arg(checkpoint.Signature), psql.Arg(checkpoint.CreatedAt),
+		),
+		im.OnConflict("chain", "seq").DoNothing(),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write audit checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// ListCheckpoints returns the checkpoints of a chain, oldest first
+func (dao *AuditChainDAO) ListCheckpoints(ctx context.Context, chain string) ([]auditchain.Checkpoint, error) {
+	stored, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns("chain", "seq", "head_hash", "key_id", "signature", "created_at"),
+		sm.From("audit_checkpoints"),
+		sm.Where(psql.Quote("chain").EQ(psql.Arg(chain))),
+		sm.OrderBy(psql.Quote("seq")),
+	), scan.StructMapper[auditCheckpoint]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+
+	checkpoints := make([]auditchain.Checkpoint, 0, len(stored))
+	for _, checkpoint := range stored {
+		checkpoints = append(checkpoints, auditchain.Checkpoint{
+			Chain:     checkpoint.Chain,
+			Seq:       checkpoint.Seq,
+			Hash:      checkpoint.HeadHash,
+			CreatedAt: checkpoint.CreatedAt,
+			KeyID:     checkpoint.KeyID,
+			Signature: checkpoint.Signature,
+		})
+	}
+	return checkpoints, nil
+}
+
+// CountUnchainedEntries returns the number of entries of a chain's table
+// that are not part of the chain
+func (dao *AuditChainDAO) CountUnchainedEntries(ctx context.Context, chain string) (int64, error) {
+	count, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From(chain),
+		sm.Where(psql.Quote("chain_seq").IsNull()),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unchained entries of %s: %w", chain, err)
+	}
+	return count, nil
+}
+
+// WalkChain calls fn with every entry of a chain, in sequence order, until
+// fn returns an error
+func (dao *AuditChainDAO) WalkChain(ctx context.Context, chain string, fn func(id string, link auditchain.Link, fields []auditchain.Field) error) error {
+	switch chain {
+	case CorrelationAuditChain:
+		return walkChain(ctx, dao.db, chain, correlationAuditColumns, func(entry CorrelationAuditEntry) error {
+			return fn(entry.AuditID.String(), entry.Link(), entry.chainFields())
+		})
+	case KeyUsageAuditChain:
+		return walkChain(ctx, dao.db, chain, keyUsageAuditColumns, func(entry KeyUsageAuditEntry) error {
+			return fn(entry.UsageID.String(), entry.Link(), entry.chainFields())
+		})
+	default:
+		return fmt.Errorf("unknown audit chain %s", chain)
+	}
+}
+
+// walkChain reads the chained entries of a table page by page
+func walkChain[T interface{ Link() auditchain.Link }](ctx context.Context, db bob.Executor, table string, columns []any, fn func(T) error) error {
+	var after int64
+	for {
+		entries, err := bob.All(ctx, db, psql.Select(
+			sm.Columns(columns...),
+			sm.From(table),
+			sm.Where(psql.Quote("chain_seq").GT(psql.Arg(after))),
+			sm.OrderBy(psql.Quote("chain_seq")),
+			sm.Limit(auditChainPageSize),
+		), scan.StructMapper[T]())
+		if err != nil {
+			return fmt.Errorf("failed to read audit chain %s: %w", table, err)
+		}
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+			after = entry.Link().Seq
+		}
+		if len(entries) < auditChainPageSize {
+			return nil
+		}
+	}
+}
+
+// AuditChainLink holds the chain columns stored with an audit entry. They
+// are unset on entries written before the chain existed.
+type AuditChainLink struct {
+	ChainSeq  sql.Null[int64] `db:"chain_seq"`
+	PrevHash  []byte          `db:"prev_hash"`
+	EntryHash []byte          `db:"entry_hash"`
+}
+
+// Link returns the position of the entry in its chain
+func (l AuditChainLink) Link() auditchain.Link {
+	return auditchain.Link{Seq: l.ChainSeq.V, PrevHash: l.PrevHash, Hash: l.EntryHash}
+}
+
+// nullableField returns the hashed form of a nullable column
+func nullableField[T any](name string, value sql.Null[T], field func(string, T) auditchain.Field) auditchain.Field {
+	if !value.Valid {
+		return auditchain.Null(name)
+	}
+	return field(name, value.V)
+}
+
+// AuditClient holds the network data stored with an audit entry: a keyed
+// hash of the client's network, its coarse prefix and the browser family of
+// its user agent. Entries written before these columns existed have none.
+type AuditClient struct {
+	IPHash          sql.Null[string] `db:"ip_hash"`
+	IPPrefix        sql.Null[string] `db:"ip_prefix"`
+	UserAgentFamily sql.Null[string] `db:"user_agent_family"`
+}
+
+// NewAuditClient creates the client data of an audit entry. Empty values are
+// stored as NULL.
+func NewAuditClient(ipHash, ipPrefix, userAgentFamily string) AuditClient {
+	return AuditClient{
+		IPHash:          nullIfEmpty(ipHash),
+		IPPrefix:        nullIfEmpty(ipPrefix),
+		UserAgentFamily: nullIfEmpty(userAgentFamily),
+	}
+}
+
+// auditClientColumns are the columns of AuditClient
+var auditClientColumns = []string{"ip_hash", "ip_prefix", "user_agent_family"}
+
+// values returns the values of the client columns
+func (c AuditClient) values() []any {
+	return []any{
+		psql.Cast(psql.Arg(c.IPHash), "VARCHAR"),
+		psql.Cast(psql.Arg(c.IPPrefix), "VARCHAR"),
+		psql.Cast(psql.Arg(c.UserAgentFamily), "VARCHAR"),
+	}
+}
+
+// chainFields returns the client columns that are set, as hashed into the
+// audit chain. Unset columns are left out so that entries written before
+// the columns existed keep their hashes.
+func (c AuditClient) chainFields() []auditchain.Field {
+	var fields []auditchain.Field
+	for _, column := range []struct {
+		name  string
+		value sql.Null[string]
+	}{
+		{"ip_hash", c.IPHash},
+		{"ip_prefix", c.IPPrefix},
+		{"user_agent_family", c.UserAgentFamily},
+	} {
+		if column.value.Valid {
+			fields = append(fields, auditchain.String(column.name, column.value.V))
+		}
+	}
+	return fields
+}
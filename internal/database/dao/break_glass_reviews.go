@@ -0,0 +1,221 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Statuses of break-glass reviews
+const (
+	// BreakGlassReviewPending means the use waits for sign-off
+	BreakGlassReviewPending = "pending"
+	// BreakGlassReviewSignedOff means a reviewer found the use justified
+	BreakGlassReviewSignedOff = "signed_off"
+	// BreakGlassReviewRejected means a reviewer found the use unjustified; the requester is flagged
+	BreakGlassReviewRejected = "rejected"
+	// BreakGlassReviewOverdue means the use was not reviewed in time; the requester is flagged
+	BreakGlassReviewOverdue = "overdue"
+)
+
+// RequestSourceBreakGlass is the request source that marks break-glass
+// correlations in the correlation audit trail
+const RequestSourceBreakGlass = "break_glass"
+
+// BreakGlassReview is an emergency identity correlation made without prior
+// approval, which a different admin must review after the fact
+type BreakGlassReview struct {
+	ReviewID           uuid.UUID           `db:"review_id"`
+	RequesterID        int64               `db:"requester_id"`
+	RequesterRole      string              `db:"requester_role"`
+	RequestedPseudonym string              `db:"requested_pseudonym"`
+	ReasonCode         string              `db:"reason_code"`
+	Justification      string              `db:"justification"`
+	IncidentID         string              `db:"incident_id"`
+	KeyID              sql.Null[uuid.UUID] `db:"key_id"`
+	AuditID            sql.Null[uuid.UUID] `db:"audit_id"`
+	Status             string              `db:"status"`
+	ReviewDueAt        time.Time           `db:"review_due_at"`
+	ReviewerID         sql.Null[int64]     `db:"reviewer_id"`
+	ReviewerRole       sql.Null[string]    `db:"reviewer_role"`
+	ReviewComment      sql.Null[string]    `db:"review_comment"`
+	ReviewedAt         sql.Null[time.Time] `db:"reviewed_at"`
+	FlaggedAt          sql.Null[time.Time] `db:"flagged_at"`
+	CreatedAt          time.Time           `db:"created_at"`
+}
+
+// BreakGlassReviewDAO provides database operations for break-glass reviews
+type BreakGlassReviewDAO struct {
+	db bob.Executor
+}
+
+// NewBreakGlassReviewDAO creates a new break-glass review DAO
+func NewBreakGlassReviewDAO(db bob.Executor) *BreakGlassReviewDAO {
+	return &BreakGlassReviewDAO{
+		db: db,
+	}
+}
+
+// breakGlassReviewColumns are the columns of break_glass_reviews, in BreakGlassReview order
+var breakGlassReviewColumns = []any{
+	"review_id", "requester_id", "requester_role", "requested_pseudonym", "reason_code",
+	"justification", "incident_id", "key_id", "audit_id", "status", "review_due_at",
+	"reviewer_id", "reviewer_role", "review_comment", "reviewed_at", "flagged_at", "created_at",
+}
+
+// CreateReview records a break-glass use that must be reviewed before reviewDueAt
+func (dao *BreakGlassReviewDAO) CreateReview(ctx context.Context, review *BreakGlassReview, reviewDueAt time.Time) (*BreakGlassReview, error) {
+	created, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("break_glass_reviews",
+			"requester_id", "requester_role", "requested_pseudonym", "reason_code", "justification",
+			"incident_id", "status", "review_due_at"),
+		im.Values(
+			psql.Arg(review.RequesterID), psql.Arg(review.RequesterRole), psql.Arg(review.RequestedPseudonym),
+			psql.Arg(review.ReasonCode), psql.Arg(review.Justification), psql.Arg(review.IncidentID),
+			psql.Arg(BreakGlassReviewPending), psql.Arg(reviewDueAt),
+		),
+		im.Returning(breakGlassReviewColumns...),
+	), scan.StructMapper[BreakGlassReview]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create break-glass review: %w", err)
+	}
+	return &created, nil
+}
+
+// SetKey records the role key granted for a break-glass use
+func (dao *BreakGlassReviewDAO) SetKey(ctx context.Context, reviewID, keyID uuid.UUID) error {
+	return dao.set(ctx, reviewID, "key_id", keyID)
+}
+
+// SetAuditEntry records the correlation audit entry of a completed break-glass use
+func (dao *BreakGlassReviewDAO) SetAuditEntry(ctx context.Context, reviewID, auditID uuid.UUID) error {
+	return dao.set(ctx, reviewID, "audit_id", auditID)
+}
+
+// set changes a column of a review
+func (dao *BreakGlassReviewDAO) set(ctx context.Context, reviewID uuid.UUID, column string, value any) error {
+	if _, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("break_glass_reviews"),
+		um.SetCol(column).ToArg(value),
+		um.Where(psql.Quote("review_id").EQ(psql.Arg(reviewID))),
+	)); err != nil {
+		return fmt.Errorf("failed to update break-glass review: %w", err)
+	}
+	return nil
+}
+
+// GetReview returns a review, or nil if it does not exist
+func (dao *BreakGlassReviewDAO) GetReview(ctx context.Context, reviewID uuid.UUID) (*BreakGlassReview, error) {
+	review, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(breakGlassReviewColumns...),
+		sm.From("break_glass_reviews"),
+		sm.Where(psql.Quote("review_id").EQ(psql.Arg(reviewID))),
+	), scan.StructMapper[BreakGlassReview]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get break-glass review: %w", err)
+	}
+	return &review, nil
+}
+
+// ListReviews returns reviews with a status, or every review if status is
+// empty, newest first
+func (dao *BreakGlassReviewDAO) ListReviews(ctx context.Context, status string) ([]BreakGlassReview, error) {
+	query := psql.Select(
+		sm.Columns(breakGlassReviewColumns...),
+		sm.From("break_glass_reviews"),
+		sm.OrderBy(psql.Quote("created_at")).Desc(),
+	)
+	if status != "" {
+		query.Apply(sm.Where(psql.Quote("status").EQ(psql.Arg(status))))
+	}
+
+	reviews, err := bob.All(ctx, dao.db, query, scan.StructMapper[BreakGlassReview]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list break-glass reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// IsFlagged reports whether a user has an overdue or rejected break-glass use
+func (dao *BreakGlassReviewDAO) IsFlagged(ctx context.Context, userID int64) (bool, error) {
+	flagged, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(psql.Raw("EXISTS (SELECT 1 FROM break_glass_reviews WHERE requester_id = ? AND status IN (?, ?))",
+			userID, BreakGlassReviewOverdue, BreakGlassReviewRejected)),
+	), scan.SingleColumnMapper[bool])
+	if err != nil {
+		return false, fmt.Errorf("failed to check break-glass flags: %w", err)
+	}
+	return flagged, nil
+}
+
+// SignOffReview signs off a pending or overdue use of another user, lifting
+// the flag of an overdue one. It returns nil if no such review exists, so a
+// use is reviewed only once even by concurrent reviewers.
+func (dao *BreakGlassReviewDAO) SignOffReview(ctx context.Context, reviewID uuid.UUID, reviewerID int64, reviewerRole, comment string) (*BreakGlassReview, error) {
+	return dao.review(ctx, reviewID, reviewerID, reviewerRole, comment, BreakGlassReviewSignedOff)
+}
+
+// RejectReview rejects a pending or overdue use of another user and flags
+// the requester. It returns nil if no such review exists.
+func (dao *BreakGlassReviewDAO) RejectReview(ctx context.Context, reviewID uuid.UUID, reviewerID int64, reviewerRole, comment string) (*BreakGlassReview, error) {
+	return dao.review(ctx, reviewID, reviewerID, reviewerRole, comment, BreakGlassReviewRejected)
+}
+
+// review moves a pending or overdue review to the status chosen by its reviewer
+func (dao *BreakGlassReviewDAO) review(ctx context.Context, reviewID uuid.UUID, reviewerID int64, reviewerRole, comment, status string) (*BreakGlassReview, error) {
+	query := psql.Update(
+		um.Table("break_glass_reviews"),
+		um.SetCol("status").ToArg(status),
+		um.SetCol("reviewer_id").ToArg(reviewerID),
+		um.SetCol("reviewer_role").ToArg(reviewerRole),
+		um.SetCol("review_comment").ToArg(nullIfEmpty(comment)),
+		um.SetCol("reviewed_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("review_id").EQ(psql.Arg(reviewID))),
+		um.Where(psql.Quote("status").In(psql.Arg(BreakGlassReviewPending), psql.Arg(BreakGlassReviewOverdue))),
+		um.Where(psql.Quote("requester_id").NE(psql.Arg(reviewerID))),
+		um.Returning(breakGlassReviewColumns...),
+	)
+	if status == BreakGlassReviewRejected {
+		query.Apply(um.SetCol("flagged_at").To(psql.Raw("COALESCE(flagged_at, NOW())")))
+	}
+
+	review, err := bob.One(ctx, dao.db, query, scan.StructMapper[BreakGlassReview]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to review break-glass use: %w", err)
+	}
+	return &review, nil
+}
+
+// ClaimOverdueReviews marks pending reviews past their deadline as overdue,
+// flagging their requesters, and returns them. Each review is returned only
+// once, even to concurrent callers.
+func (dao *BreakGlassReviewDAO) ClaimOverdueReviews(ctx context.Context) ([]BreakGlassReview, error) {
+	reviews, err := bob.All(ctx, dao.db, psql.Update(
+		um.Table("break_glass_reviews"),
+		um.SetCol("status").ToArg(BreakGlassReviewOverdue),
+		um.SetCol("flagged_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("status").EQ(psql.Arg(BreakGlassReviewPending))),
+		um.Where(psql.Quote("review_due_at").LTE(psql.Raw("NOW()"))),
+		um.Returning(breakGlassReviewColumns...),
+	), scan.StructMapper[BreakGlassReview]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim overdue break-glass reviews: %w", err)
+	}
+	return reviews, nil
+}
@@ -211,6 +211,20 @@ func (dao *CommentDAO) CountCommentsByPseudonym(ctx context.Context, pseudonymID
 	return count, nil
 }
 
+// GetCommentsByPseudonym retrieves every comment by a pseudonym, removed
+// comments included, oldest first
+func (dao *CommentDAO) GetCommentsByPseudonym(ctx context.Context, pseudonymID string) ([]*models.Comment, error) {
+	comments, err := models.Comments.Query(
+		models.SelectWhere.Comments.PseudonymID.EQ(pseudonymID),
+		sm.OrderBy("created_at ASC, comment_id ASC"),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments by pseudonym: %w", err)
+	}
+
+	return comments, nil
+}
+
 // CountCommentsByPseudonymInSubforum counts comments by a pseudonym in a specific subforum
 func (dao *CommentDAO) CountCommentsByPseudonymInSubforum(ctx context.Context, pseudonymID string, subforumID int32) (int64, error) {
 	// Get all comments by the pseudonym
@@ -237,6 +251,37 @@ func (dao *CommentDAO) CountCommentsByPseudonymInSubforum(ctx context.Context, p
 	return count, nil
 }
 
+// CountCommentsByPseudonymSince counts the comments, removed comments
+// included, a pseudonym made anywhere at or after a time
+func (dao *CommentDAO) CountCommentsByPseudonymSince(ctx context.Context, pseudonymID string, since time.Time) (int64, error) {
+	count, err := models.Comments.Query(
+		models.SelectWhere.Comments.PseudonymID.EQ(pseudonymID),
+		models.SelectWhere.Comments.CreatedAt.GTE(since),
+	).Count(ctx, dao.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments by pseudonym: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountCommentsByPseudonymInSubforumSince counts the comments, removed
+// comments included, a pseudonym made on posts of a subforum at or after a
+// time
+func (dao *CommentDAO) CountCommentsByPseudonymInSubforumSince(ctx context.Context, pseudonymID string, subforumID int32, since time.Time) (int64, error) {
+	count, err := models.Comments.Query(
+		sm.InnerJoin("posts").As("p").OnEQ(psql.Quote("p", "post_id"), psql.Quote("comments", "post_id")),
+		models.SelectWhere.Comments.PseudonymID.EQ(pseudonymID),
+		models.SelectWhere.Comments.CreatedAt.GTE(since),
+		sm.Where(psql.Quote("p", "subforum_id").EQ(psql.Arg(subforumID))),
+	).Count(ctx, dao.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments by pseudonym in subforum: %w", err)
+	}
+
+	return count, nil
+}
+
 // GetSubforumsByPseudonymComments gets all subforums where a pseudonym has commented
 func (dao *CommentDAO) GetSubforumsByPseudonymComments(ctx context.Context, pseudonymID string) ([]int32, error) {
 	// Get all comments by the pseudonym
@@ -0,0 +1,366 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Types of compliance reports
+const (
+	ComplianceReportCourtOrder     = "court_order"
+	ComplianceReportSubpoena       = "subpoena"
+	ComplianceReportLawEnforcement = "law_enforcement"
+	ComplianceReportInternalAudit  = "internal_audit"
+)
+
+// Statuses of compliance reports
+const (
+	// ComplianceReportPending means the case was opened and work has not started
+	ComplianceReportPending = "pending"
+	// ComplianceReportInProgress means the legal team is working on the case
+	ComplianceReportInProgress = "in_progress"
+	// ComplianceReportCompleted means the request was answered
+	ComplianceReportCompleted = "completed"
+	// ComplianceReportRejected means the request was refused
+	ComplianceReportRejected = "rejected"
+)
+
+// Types of compliance report events
+const (
+	ComplianceEventOpened              = "opened"
+	ComplianceEventAssigned            = "assigned"
+	ComplianceEventStatusChanged       = "status_changed"
+	ComplianceEventCorrelationAttached = "correlation_attached"
+	ComplianceEventOverdue             = "overdue"
+	ComplianceEventBundleExported      = "bundle_exported"
+)
+
+// complianceReportTransitions are the statuses a report can move to from
+// each status. Completed and rejected reports are closed.
+var complianceReportTransitions = map[string][]string{
+	ComplianceReportPending:    {ComplianceReportInProgress, ComplianceReportRejected},
+	ComplianceReportInProgress: {ComplianceReportCompleted, ComplianceReportRejected},
+}
+
+// CanTransitionComplianceReport reports whether a report can move from one
+// status to another
+func CanTransitionComplianceReport(from, to string) bool {
+	return slices.Contains(complianceReportTransitions[from], to)
+}
+
+// ComplianceReport is a case of the legal team: a legal request for user
+// data, with its deadline, assignee and status
+type ComplianceReport struct {
+	ReportID            uuid.UUID           `db:"report_id"`
+	ReportType          string              `db:"report_type"`
+	RequestingAuthority sql.Null[string]    `db:"requesting_authority"`
+	RequestID           sql.Null[string]    `db:"request_id"`
+	RequestDate         time.Time           `db:"request_date"`
+	DueDate             sql.Null[time.Time] `db:"due_date"`
+	Status              string              `db:"status"`
+	ScopeDescription    string              `db:"scope_description"`
+	LegalBasis          sql.Null[string]    `db:"legal_basis"`
+	AssignedUserID      sql.Null[int64]     `db:"assigned_user_id"`
+	CreatedBy           sql.Null[int64]     `db:"created_by"`
+	CreatedAt           time.Time           `db:"created_at"`
+	UpdatedAt           time.Time           `db:"updated_at"`
+	CompletedAt         sql.Null[time.Time] `db:"completed_at"`
+	Notes               sql.Null[string]    `db:"notes"`
+	GagOrder            bool                `db:"gag_order"`
+	OverdueAlertedAt    sql.Null[time.Time] `db:"overdue_alerted_at"`
+}
+
+// IsOpen reports whether the report still awaits an answer
+func (r *ComplianceReport) IsOpen() bool {
+	return r.Status == ComplianceReportPending || r.Status == ComplianceReportInProgress
+}
+
+// Overdue reports whether the report is open after the end of its due date,
+// in UTC
+func (r *ComplianceReport) Overdue(now time.Time) bool {
+	if !r.IsOpen() || !r.DueDate.Valid {
+		return false
+	}
+	dueDate := r.DueDate.V
+	endOfDueDate := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	return !now.Before(endOfDueDate)
+}
+
+// complianceReportOverdue is true when an open report is past its due date
+const complianceReportOverdue = `status IN ('pending', 'in_progress') AND due_date < (NOW() AT TIME ZONE 'UTC')::date`
+
+// complianceReportColumns are the columns of compliance_reports, in ComplianceReport order
+var complianceReportColumns = []any{
+	"report_id", "report_type", "requesting_authority", "request_id", "request_date",
+	"due_date", "status", "scope_description", "legal_basis", "assigned_user_id",
+	"created_by", "created_at", "updated_at", "completed_at", "notes", "gag_order",
+	"overdue_alerted_at",
+}
+
+// ComplianceReportFilter selects the reports listed
+type ComplianceReportFilter struct {
+	Status         string // Only reports with this status, if set
+	AssignedUserID int64  // Only reports assigned to this user, if set
+	Overdue        bool   // Only open reports past their due date
+}
+
+// ComplianceCorrelation is a correlation linked to a compliance report,
+// joined to its audit entry
+type ComplianceCorrelation struct {
+	CorrelationID      uuid.UUID           `db:"correlation_id"`
+	ReportID           uuid.UUID           `db:"report_id"`
+	AuditID            uuid.UUID           `db:"audit_id"`
+	CorrelationScope   string              `db:"correlation_scope"`
+	LinkedAt           time.Time           `db:"linked_at"`
+	CorrelationType    string              `db:"correlation_type"`
+	RequestedPseudonym string              `db:"requested_pseudonym"`
+	CorrelatedBy       int64               `db:"correlated_by"`
+	RoleUsed           string              `db:"role_used"`
+	CorrelatedAt       sql.Null[time.Time] `db:"correlated_at"`
+	RequestID          sql.Null[uuid.UUID] `db:"request_id"`
+}
+
+// ComplianceReportEvent is an entry of the history of a compliance report
+type ComplianceReportEvent struct {
+	EventID        uuid.UUID           `db:"event_id"`
+	ReportID       uuid.UUID           `db:"report_id"`
+	ActorID        sql.Null[int64]     `db:"actor_id"`
+	EventType      string              `db:"event_type"`
+	Status         sql.Null[string]    `db:"status"`
+	AssignedUserID sql.Null[int64]     `db:"assigned_user_id"`
+	AuditID        sql.Null[uuid.UUID] `db:"audit_id"`
+	Note           sql.Null[string]    `db:"note"`
+	CreatedAt      time.Time           `db:"created_at"`
+}
+
+// complianceReportEventColumns are the columns of compliance_report_events, in ComplianceReportEvent order
+var complianceReportEventColumns = []any{
+	"event_id", "report_id", "actor_id", "event_type", "status",
+	"assigned_user_id", "audit_id", "note", "created_at",
+}
+
+// ComplianceReportDAO provides database operations for compliance reports,
+// the correlations linked to them and their history
+type ComplianceReportDAO struct {
+	db bob.Executor
+}
+
+// NewComplianceReportDAO creates a new compliance report DAO
+func NewComplianceReportDAO(db bob.Executor) *ComplianceReportDAO {
+	return &ComplianceReportDAO{
+		db: db,
+	}
+}
+
+// CreateReport opens a pending report
+func (dao *ComplianceReportDAO) CreateReport(ctx context.Context, report *ComplianceReport) (*ComplianceReport, error) {
+	created, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("compliance_reports",
+			"report_type", "requesting_authority", "request_id", "request_date", "due_date", "status",
+			"scope_description", "legal_basis", "assigned_user_id", "created_by", "notes", "gag_order"),
+		im.Values(
+			psql.Arg(report.ReportType), psql.Arg(report.RequestingAuthority), psql.Arg(report.RequestID),
+			psql.Arg(report.RequestDate), psql.Arg(report.DueDate), psql.Arg(ComplianceReportPending),
+			psql.Arg(report.ScopeDescription), psql.Arg(report.LegalBasis), psql.Arg(report.AssignedUserID),
+			psql.Arg(report.CreatedBy), psql.Arg(report.Notes), psql.Arg(report.GagOrder),
+		),
+		im.Returning(complianceReportColumns...),
+	), scan.StructMapper[ComplianceReport]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compliance report: %w", err)
+	}
+	return &created, nil
+}
+
+// GetReport returns a report, or nil if it does not exist
+func (dao *ComplianceReportDAO) GetReport(ctx context.Context, reportID uuid.UUID) (*ComplianceReport, error) {
+	report, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(complianceReportColumns...),
+		sm.From("compliance_reports"),
+		sm.Where(psql.Quote("report_id").EQ(psql.Arg(reportID))),
+	), scan.StructMapper[ComplianceReport]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get compliance report: %w", err)
+	}
+	return &report, nil
+}
+
+// ListReports returns the reports selected by filter, earliest due date
+// first and reports without one last
+func (dao *ComplianceReportDAO) ListReports(ctx context.Context, filter ComplianceReportFilter) ([]ComplianceReport, error) {
+	query := psql.Select(
+		sm.Columns(complianceReportColumns...),
+		sm.From("compliance_reports"),
+		sm.OrderBy(psql.Quote("due_date")).Asc().NullsLast(),
+		sm.OrderBy(psql.Quote("created_at")).Desc(),
+	)
+	if filter.Status != "" {
+		query.Apply(sm.Where(psql.Quote("status").EQ(psql.Arg(filter.Status))))
+	}
+	if filter.AssignedUserID != 0 {
+		query.Apply(sm.Where(psql.Quote("assigned_user_id").EQ(psql.Arg(filter.AssignedUserID))))
+	}
+	if filter.Overdue {
+		query.Apply(sm.Where(psql.Raw(complianceReportOverdue)))
+	}
+
+	reports, err := bob.All(ctx, dao.db, query, scan.StructMapper[ComplianceReport]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compliance reports: %w", err)
+	}
+	return reports, nil
+}
+
+// AssignReport assigns an open report to a user. It returns nil if no open
+// report exists.
+func (dao *ComplianceReportDAO) AssignReport(ctx context.Context, reportID uuid.UUID, userID int64) (*ComplianceReport, error) {
+	return dao.update(ctx, reportID,
+		um.SetCol("assigned_user_id").ToArg(userID),
+		um.Where(psql.Quote("status").In(psql.Arg(ComplianceReportPending), psql.Arg(ComplianceReportInProgress))),
+	)
+}
+
+// SetStatus moves a report from one status to another. Closing a report
+// records when it was closed. It returns nil if the report does not have the
+// status from, so concurrent changes do not overwrite each other.
+func (dao *ComplianceReportDAO) SetStatus(ctx context.Context, reportID uuid.UUID, from, to string) (*ComplianceReport, error) {
+	mods := []bob.Mod[*dialect.UpdateQuery]{
+		um.SetCol("status").ToArg(to),
+		um.Where(psql.Quote("status").EQ(psql.Arg(from))),
+	}
+	if to == ComplianceReportCompleted || to == ComplianceReportRejected {
+		mods = append(mods, um.SetCol("completed_at").To(psql.Raw("NOW()")))
+	}
+	return dao.update(ctx, reportID, mods...)
+}
+
+// update applies mods to a report and returns it, or nil if no report matched
+func (dao *ComplianceReportDAO) update(ctx context.Context, reportID uuid.UUID, mods ...bob.Mod[*dialect.UpdateQuery]) (*ComplianceReport, error) {
+	query := psql.Update(
+		um.Table("compliance_reports"),
+		um.SetCol("updated_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("report_id").EQ(psql.Arg(reportID))),
+		um.Returning(complianceReportColumns...),
+	)
+	query.Apply(mods...)
+
+	report, err := bob.One(ctx, dao.db, query, scan.StructMapper[ComplianceReport]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update compliance report: %w", err)
+	}
+	return &report, nil
+}
+
+// CanHandleReports reports whether a user is an active member of staff with
+// the legal_compliance capability, to whom reports can be assigned
+func (dao *ComplianceReportDAO) CanHandleReports(ctx context.Context, userID int64) (bool, error) {
+	exists, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(psql.Raw(`EXISTS (SELECT 1 FROM users WHERE user_id = ? AND COALESCE(is_active, TRUE)
+			AND NOT COALESCE(is_suspended, FALSE) AND capabilities @> '["legal_compliance"]'::jsonb)`, userID)),
+	), scan.SingleColumnMapper[bool])
+	if err != nil {
+		return false, fmt.Errorf("failed to check compliance staff: %w", err)
+	}
+	return exists, nil
+}
+
+// AttachCorrelation links a correlation audit entry to a report. It returns
+// false if the entry was already linked to the report.
+func (dao *ComplianceReportDAO) AttachCorrelation(ctx context.Context, reportID, auditID uuid.UUID, scope string) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("compliance_correlations", "report_id", "audit_id", "correlation_scope"),
+		im.Values(psql.Arg(reportID), psql.Arg(auditID), psql.Arg(scope)),
+		im.OnConflict("report_id", "audit_id").DoNothing(),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to attach correlation to compliance report: %w", err)
+	}
+	attached, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to attach correlation to compliance report: %w", err)
+	}
+	return attached > 0, nil
+}
+
+// ListCorrelations returns the correlations linked to a report, oldest first
+func (dao *ComplianceReportDAO) ListCorrelations(ctx context.Context, reportID uuid.UUID) ([]ComplianceCorrelation, error) {
+	correlations, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns(
+			"cc.correlation_id", "cc.report_id", "cc.audit_id", "cc.correlation_scope",
+			"COALESCE(cc.created_at, NOW()) AS linked_at", "a.correlation_type", "a.requested_pseudonym",
+			"a.user_id AS correlated_by", "a.role_used", "a.timestamp AS correlated_at", "a.request_id",
+		),
+		sm.From("compliance_correlations").As("cc"),
+		sm.InnerJoin("correlation_audit").As("a").OnEQ(psql.Quote("a", "audit_id"), psql.Quote("cc", "audit_id")),
+		sm.Where(psql.Quote("cc", "report_id").EQ(psql.Arg(reportID))),
+		sm.OrderBy(psql.Quote("linked_at")),
+	), scan.StructMapper[ComplianceCorrelation]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compliance report correlations: %w", err)
+	}
+	return correlations, nil
+}
+
+// RecordEvent appends an event to the history of a report
+func (dao *ComplianceReportDAO) RecordEvent(ctx context.Context, event *ComplianceReportEvent) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("compliance_report_events", "report_id", "actor_id", "event_type", "status", "assigned_user_id", "audit_id", "note"),
+		im.Values(
+			psql.Arg(event.ReportID), psql.Arg(event.ActorID), psql.Arg(event.EventType), psql.Arg(event.Status),
+			psql.Arg(event.AssignedUserID), psql.Arg(event.AuditID), psql.Arg(event.Note),
+		),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to record compliance report event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns the history of a report, oldest first
+func (dao *ComplianceReportDAO) ListEvents(ctx context.Context, reportID uuid.UUID) ([]ComplianceReportEvent, error) {
+	events, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns(complianceReportEventColumns...),
+		sm.From("compliance_report_events"),
+		sm.Where(psql.Quote("report_id").EQ(psql.Arg(reportID))),
+		sm.OrderBy(psql.Quote("created_at")),
+	), scan.StructMapper[ComplianceReportEvent]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compliance report events: %w", err)
+	}
+	return events, nil
+}
+
+// ClaimOverdueReports marks open reports past their due date whose overdue
+// alert was not raised yet and returns them, so each alert is raised once
+func (dao *ComplianceReportDAO) ClaimOverdueReports(ctx context.Context) ([]ComplianceReport, error) {
+	reports, err := bob.All(ctx, dao.db, psql.Update(
+		um.Table("compliance_reports"),
+		um.SetCol("overdue_alerted_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Raw(complianceReportOverdue)),
+		um.Where(psql.Quote("overdue_alerted_at").IsNull()),
+		um.Returning(complianceReportColumns...),
+	), scan.StructMapper[ComplianceReport]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim overdue compliance reports: %w", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,52 @@
+package dao
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionComplianceReport(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{ComplianceReportPending, ComplianceReportInProgress, true},
+		{ComplianceReportPending, ComplianceReportRejected, true},
+		{ComplianceReportPending, ComplianceReportCompleted, false},
+		{ComplianceReportInProgress, ComplianceReportCompleted, true},
+		{ComplianceReportInProgress, ComplianceReportRejected, true},
+		{ComplianceReportInProgress, ComplianceReportPending, false},
+		{ComplianceReportCompleted, ComplianceReportInProgress, false},
+		{ComplianceReportRejected, ComplianceReportPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"_to_"+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransitionComplianceReport(tt.from, tt.to))
+		})
+	}
+}
+
+func TestComplianceReport_Overdue(t *testing.T) {
+	dueDate := sql.Null[time.Time]{V: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	tests := []struct {
+		name   string
+		report ComplianceReport
+		now    time.Time
+		want   bool
+	}{
+		{"on the due date", ComplianceReport{Status: ComplianceReportPending, DueDate: dueDate}, time.Date(2025, 7, 1, 23, 59, 0, 0, time.UTC), false},
+		{"after the due date", ComplianceReport{Status: ComplianceReportInProgress, DueDate: dueDate}, time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC), true},
+		{"closed after the due date", ComplianceReport{Status: ComplianceReportCompleted, DueDate: dueDate}, time.Date(2025, 7, 5, 0, 0, 0, 0, time.UTC), false},
+		{"without a due date", ComplianceReport{Status: ComplianceReportPending}, time.Date(2025, 7, 5, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.report.Overdue(tt.now))
+		})
+	}
+}
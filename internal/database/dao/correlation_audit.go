@@ -0,0 +1,255 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/types"
+	"github.com/stephenafamo/scan"
+)
+
+// CorrelationAuditEntry is a record of the correlation audit trail: a
+// correlation that was performed, or a transition of a correlation request.
+// AuditClient describes the client the admin made the request from.
+type CorrelationAuditEntry struct {
+	AuditID              uuid.UUID                             `db:"audit_id"`
+	UserID               int64                                 `db:"user_id"`
+	PseudonymID          string                                `db:"pseudonym_id"`
+	AdminUsername        string                                `db:"admin_username"`
+	RoleUsed             string                                `db:"role_used"`
+	RequestedPseudonym   string                                `db:"requested_pseudonym"`
+	RequestedFingerprint sql.Null[string]                      `db:"requested_fingerprint"`
+	Justification        string                                `db:"justification"`
+	CorrelationType      string                                `db:"correlation_type"`
+	CorrelationResult    sql.Null[types.JSON[json.RawMessage]] `db:"correlation_result"`
+	Timestamp            sql.Null[time.Time]                   `db:"timestamp"`
+	LegalBasis           sql.Null[string]                      `db:"legal_basis"`
+	IncidentID           sql.Null[string]                      `db:"incident_id"`
+	RequestSource        sql.Null[string]                      `db:"request_source"`
+	RequestID            sql.Null[uuid.UUID]                   `db:"request_id"`
+	RequestStatus        sql.Null[string]                      `db:"request_status"`
+	AuditClient
+	AuditChainLink
+}
+
+// chainFields returns the columns of the entry as hashed into the audit chain
+func (e *CorrelationAuditEntry) chainFields() []auditchain.Field {
+	fields := []auditchain.Field{
+		auditchain.String("audit_id", e.AuditID.String()),
+		auditchain.Int("user_id", e.UserID),
+		auditchain.String("pseudonym_id", e.PseudonymID),
+		auditchain.String("admin_username", e.AdminUsername),
+		auditchain.String("role_used", e.RoleUsed),
+		auditchain.String("requested_pseudonym", e.RequestedPseudonym),
+		nullableField("requested_fingerprint", e.RequestedFingerprint, auditchain.String),
+		auditchain.String("justification", e.Justification),
+		auditchain.String("correlation_type", e.CorrelationType),
+		nullableField("correlation_result", e.CorrelationResult, func(name string, result types.JSON[json.RawMessage]) auditchain.Field {
+			return auditchain.JSON(name, result.Val)
+		}),
+		nullableField("timestamp", e.Timestamp, auditchain.Time),
+		nullableField("legal_basis", e.LegalBasis, auditchain.String),
+		nullableField("incident_id", e.IncidentID, auditchain.String),
+		nullableField("request_source", e.RequestSource, auditchain.String),
+		nullableField("request_id", e.RequestID, func(name string, id uuid.UUID) auditchain.Field {
+			return auditchain.String(name, id.String())
+		}),
+		nullableField("request_status", e.RequestStatus, auditchain.String),
+	}
+	return append(fields, e.AuditClient.chainFields()...)
+}
+
+// CorrelationAuditDAO provides database operations for the correlation audit trail
+type CorrelationAuditDAO struct {
+	db    bob.Executor
+	chain *AuditChainDAO
+}
+
+// NewCorrelationAuditDAO creates a new correlation audit DAO. Entries are
+// appended to the correlation audit chain through chain.
+func NewCorrelationAuditDAO(db bob.Executor, chain *AuditChainDAO) *CorrelationAuditDAO {
+	return &CorrelationAuditDAO{
+		db:    db,
+		chain: chain,
+	}
+}
+
+// correlationAuditColumns are the columns of correlation_audit, in CorrelationAuditEntry order
+var correlationAuditColumns = []any{
+	"audit_id", "user_id", "pseudonym_id", "admin_username", "role_used",
+	"requested_pseudonym", "requested_fingerprint", "justification", "correlation_type",
+	"correlation_result", "timestamp", "legal_basis", "incident_id", "request_source",
+	"request_id", "request_status", "ip_hash", "ip_prefix", "user_agent_family",
+	"chain_seq", "prev_hash", "entry_hash",
+}
+
+// RecordEntry appends an entry to the audit trail. A zero AuditID is
+// generated and an unset Timestamp is the current time.
+func (dao *CorrelationAuditDAO) RecordEntry(ctx context.Context, entry *CorrelationAuditEntry) error {
+	if entry.AuditID == uuid.Nil {
+		entry.AuditID = uuid.Must(uuid.NewV4())
+	}
+	if !entry.Timestamp.Valid {
+		entry.Timestamp = sql.Null[time.Time]{V: time.Now(), Valid: true}
+	}
+	// Store the timestamp exactly as it is hashed
+	entry.Timestamp.V = entry.Timestamp.V.UTC().Truncate(time.Microsecond)
+
+	err := dao.chain.appendEntry(ctx, CorrelationAuditChain, "correlation_audit",
+		append([]string{
+			"audit_id", "user_id", "pseudonym_id", "admin_username", "role_used",
+			"requested_pseudonym", "requested_fingerprint", "justification", "correlation_type",
+			"correlation_result", "timestamp", "legal_basis", "incident_id", "request_source",
+			"request_id", "request_status",
+		}, auditClientColumns...),
+		append([]any{
+			psql.Cast(psql.Arg(entry.AuditID), "UUID"), psql.Cast(psql.Arg(entry.UserID), "BIGINT"),
+			psql.Cast(psql.Arg(entry.PseudonymID), "VARCHAR"), psql.Cast(psql.Arg(entry.AdminUsername), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.RoleUsed), "VARCHAR"), psql.Cast(psql.Arg(entry.RequestedPseudonym), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.RequestedFingerprint), "VARCHAR"), psql.Cast(psql.Arg(entry.Justification), "TEXT"),
+			psql.Cast(psql.Arg(entry.CorrelationType), "VARCHAR"), psql.Cast(psql.Arg(entry.CorrelationResult), "JSONB"),
+			psql.Cast(psql.Arg(entry.Timestamp), "TIMESTAMPTZ"), psql.Cast(psql.Arg(entry.LegalBasis), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.IncidentID), "VARCHAR"), psql.Cast(psql.Arg(entry.RequestSource), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.RequestID), "UUID"), psql.Cast(psql.Arg(entry.RequestStatus), "VARCHAR"),
+		}, entry.AuditClient.values()...),
+		entry.chainFields(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record correlation audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetEntry returns an audit entry, or nil if it does not exist
+func (dao *CorrelationAuditDAO) GetEntry(ctx context.Context, auditID uuid.UUID) (*CorrelationAuditEntry, error) {
+	entry, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(correlationAuditColumns...),
+		sm.From("correlation_audit"),
+		sm.Where(psql.Quote("audit_id").EQ(psql.Arg(auditID))),
+	), scan.StructMapper[CorrelationAuditEntry]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get correlation audit entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// ListEntries returns audit entries of a correlation type, or of every type
+// if correlationType is empty, newest first
+func (dao *CorrelationAuditDAO) ListEntries(ctx context.Context, correlationType string) ([]CorrelationAuditEntry, error) {
+	query := psql.Select(
+		sm.Columns(correlationAuditColumns...),
+		sm.From("correlation_audit"),
+		sm.OrderBy(psql.Quote("timestamp")).Desc(),
+	)
+	if correlationType != "" {
+		query.Apply(sm.Where(psql.Quote("correlation_type").EQ(psql.Arg(correlationType))))
+	}
+
+	entries, err := bob.All(ctx, dao.db, query, scan.StructMapper[CorrelationAuditEntry]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list correlation audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// ListRequestEntries returns the audit entries of a correlation request,
+// oldest first
+func (dao *CorrelationAuditDAO) ListRequestEntries(ctx context.Context, requestID uuid.UUID) ([]CorrelationAuditEntry, error) {
+	entries, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns(correlationAuditColumns...),
+		sm.From("correlation_audit"),
+		sm.Where(psql.Quote("request_id").EQ(psql.Arg(requestID))),
+		sm.OrderBy(psql.Quote("timestamp")),
+	), scan.StructMapper[CorrelationAuditEntry]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list correlation request audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// completedCorrelations selects the entries of completed correlations:
+// fingerprint correlations, executed identity correlation requests and
+// break-glass correlations
+func completedCorrelations() []bob.Mod[*dialect.SelectQuery] {
+	return []bob.Mod[*dialect.SelectQuery]{
+		sm.Where(psql.Quote("correlation_type").In(psql.Arg("fingerprint"), psql.Arg("identity"))),
+		sm.Where(psql.Or(
+			psql.Quote("request_id").IsNull(),
+			psql.Quote("request_status").EQ(psql.Arg(CorrelationRequestExecuted)),
+		)),
+	}
+}
+
+// CountCompletedCorrelations returns the number of correlations a user
+// completed after from and up to to
+func (dao *CorrelationAuditDAO) CountCompletedCorrelations(ctx context.Context, userID int64, from, to time.Time) (int64, error) {
+	count, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From("correlation_audit"),
+		sm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		sm.Where(psql.Quote("timestamp").GT(psql.Arg(from))),
+		sm.Where(psql.Quote("timestamp").LTE(psql.Arg(to))),
+		bob.Mods[*dialect.SelectQuery](completedCorrelations()),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count completed correlations: %w", err)
+	}
+	return count, nil
+}
+
+// CountCompletedCorrelationsOf returns the number of correlations a user
+// completed after from and up to to that requested a pseudonym or found it
+// among their results, which are lookups of the same fingerprint
+func (dao *CorrelationAuditDAO) CountCompletedCorrelationsOf(ctx context.Context, userID int64, pseudonymID string, from, to time.Time) (int64, error) {
+	found, err := json.Marshal([]map[string]string{{"pseudonym_id": pseudonymID}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode pseudonym: %w", err)
+	}
+
+	count, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From("correlation_audit"),
+		sm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		sm.Where(psql.Quote("timestamp").GT(psql.Arg(from))),
+		sm.Where(psql.Quote("timestamp").LTE(psql.Arg(to))),
+		sm.Where(psql.Or(
+			psql.Quote("requested_pseudonym").EQ(psql.Arg(pseudonymID)),
+			psql.Raw("correlation_result @> ?::jsonb", string(found)),
+		)),
+		bob.Mods[*dialect.SelectQuery](completedCorrelations()),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count completed correlations of pseudonym: %w", err)
+	}
+	return count, nil
+}
+
+// ListCompletedCorrelationsAfter returns up to limit completed correlations
+// after a position of the audit chain, in chain order
+func (dao *CorrelationAuditDAO) ListCompletedCorrelationsAfter(ctx context.Context, afterSeq int64, limit int) ([]CorrelationAuditEntry, error) {
+	entries, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns(correlationAuditColumns...),
+		sm.From("correlation_audit"),
+		sm.Where(psql.Quote("chain_seq").GT(psql.Arg(afterSeq))),
+		bob.Mods[*dialect.SelectQuery](completedCorrelations()),
+		sm.OrderBy(psql.Quote("chain_seq")),
+		sm.Limit(limit),
+	), scan.StructMapper[CorrelationAuditEntry]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list completed correlations: %w", err)
+	}
+	return entries, nil
+}
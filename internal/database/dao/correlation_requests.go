@@ -0,0 +1,216 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Statuses of correlation requests
+const (
+	// CorrelationRequestPending means the request waits for review
+	CorrelationRequestPending = "pending"
+	// CorrelationRequestApproved means a second admin approved the request; it can be executed
+	CorrelationRequestApproved = "approved"
+	// CorrelationRequestRejected means a second admin rejected the request
+	CorrelationRequestRejected = "rejected"
+	// CorrelationRequestExecuted means the correlation was performed
+	CorrelationRequestExecuted = "executed"
+	// CorrelationRequestExpired means the request was not reviewed or executed in time
+	CorrelationRequestExpired = "expired"
+)
+
+// CorrelationRequest is a request to de-anonymize an identity, which a
+// second admin must approve before it is executed. A request made for a
+// compliance report is linked to the report once executed.
+type CorrelationRequest struct {
+	RequestID            uuid.UUID           `db:"request_id"`
+	CorrelationType      string              `db:"correlation_type"`
+	RequesterID          int64               `db:"requester_id"`
+	RequesterUsername    string              `db:"requester_username"`
+	RequesterRole        string              `db:"requester_role"`
+	RequestedPseudonym   string              `db:"requested_pseudonym"`
+	RequestedFingerprint sql.Null[string]    `db:"requested_fingerprint"`
+	Scope                string              `db:"scope"`
+	Justification        string              `db:"justification"`
+	LegalBasis           string              `db:"legal_basis"`
+	IncidentID           string              `db:"incident_id"`
+	Status               string              `db:"status"`
+	ReviewerID           sql.Null[int64]     `db:"reviewer_id"`
+	ReviewerRole         sql.Null[string]    `db:"reviewer_role"`
+	ReviewComment        sql.Null[string]    `db:"review_comment"`
+	CreatedAt            time.Time           `db:"created_at"`
+	ExpiresAt            time.Time           `db:"expires_at"`
+	ReviewedAt           sql.Null[time.Time] `db:"reviewed_at"`
+	ExecutedAt           sql.Null[time.Time] `db:"executed_at"`
+	ComplianceReportID   sql.Null[uuid.UUID] `db:"compliance_report_id"`
+}
+
+// CorrelationRequestDAO provides database operations for correlation requests
+type CorrelationRequestDAO struct {
+	db bob.Executor
+}
+
+// NewCorrelationRequestDAO creates a new correlation request DAO
+func NewCorrelationRequestDAO(db bob.Executor) *CorrelationRequestDAO {
+	return &CorrelationRequestDAO{
+		db: db,
+	}
+}
+
+// correlationRequestColumns are the columns of correlation_requests, in CorrelationRequest order
+var correlationRequestColumns = []any{
+	"request_id", "correlation_type", "requester_id", "requester_username", "requester_role",
+	"requested_pseudonym", "requested_fingerprint", "scope", "justification", "legal_basis",
+	"incident_id", "status", "reviewer_id", "reviewer_role", "review_comment",
+	"created_at", "expires_at", "reviewed_at", "executed_at", "compliance_report_id",
+}
+
+// CreateRequest records a pending request that must be reviewed before expiresAt
+func (dao *CorrelationRequestDAO) CreateRequest(ctx context.Context, request *CorrelationRequest, expiresAt time.Time) (*CorrelationRequest, error) {
+	created, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("correlation_requests",
+			"correlation_type", "requester_id", "requester_username", "requester_role", "requested_pseudonym",
+			"requested_fingerprint", "scope", "justification", "legal_basis", "incident_id", "status", "expires_at",
+			"compliance_report_id"),
+		im.Values(
+			psql.Arg(request.CorrelationType), psql.Arg(request.RequesterID), psql.Arg(request.RequesterUsername),
+			psql.Arg(request.RequesterRole), psql.Arg(request.RequestedPseudonym), psql.Arg(request.RequestedFingerprint),
+			psql.Arg(request.Scope), psql.Arg(request.Justification), psql.Arg(request.LegalBasis),
+			psql.Arg(request.IncidentID), psql.Arg(CorrelationRequestPending), psql.Arg(expiresAt),
+			psql.Arg(request.ComplianceReportID),
+		),
+		im.Returning(correlationRequestColumns...),
+	), scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create correlation request: %w", err)
+	}
+	return &created, nil
+}
+
+// GetRequest returns a request, or nil if it does not exist
+func (dao *CorrelationRequestDAO) GetRequest(ctx context.Context, requestID uuid.UUID) (*CorrelationRequest, error) {
+	request, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(correlationRequestColumns...),
+		sm.From("correlation_requests"),
+		sm.Where(psql.Quote("request_id").EQ(psql.Arg(requestID))),
+	), scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get correlation request: %w", err)
+	}
+	return &request, nil
+}
+
+// ListRequests returns requests with a status, or every request if status is
+// empty, newest first
+func (dao *CorrelationRequestDAO) ListRequests(ctx context.Context, status string) ([]CorrelationRequest, error) {
+	query := psql.Select(
+		sm.Columns(correlationRequestColumns...),
+		sm.From("correlation_requests"),
+		sm.OrderBy(psql.Quote("created_at")).Desc(),
+	)
+	if status != "" {
+		query.Apply(sm.Where(psql.Quote("status").EQ(psql.Arg(status))))
+	}
+
+	requests, err := bob.All(ctx, dao.db, query, scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list correlation requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ApproveRequest approves a pending, unexpired request made by another user.
+// The approval can be executed until approvalExpiresAt. It returns nil if no
+// such request exists, so a request is reviewed only once even by concurrent
+// reviewers.
+func (dao *CorrelationRequestDAO) ApproveRequest(ctx context.Context, requestID uuid.UUID, reviewerID int64, reviewerRole, comment string, approvalExpiresAt time.Time) (*CorrelationRequest, error) {
+	return dao.review(ctx, requestID, reviewerID, reviewerRole, comment, CorrelationRequestApproved,
+		um.SetCol("expires_at").ToArg(approvalExpiresAt))
+}
+
+// RejectRequest rejects a pending, unexpired request made by another user. It
+// returns nil if no such request exists.
+func (dao *CorrelationRequestDAO) RejectRequest(ctx context.Context, requestID uuid.UUID, reviewerID int64, reviewerRole, comment string) (*CorrelationRequest, error) {
+	return dao.review(ctx, requestID, reviewerID, reviewerRole, comment, CorrelationRequestRejected)
+}
+
+// review moves a pending request to the status chosen by its reviewer
+func (dao *CorrelationRequestDAO) review(ctx context.Context, requestID uuid.UUID, reviewerID int64, reviewerRole, comment, status string, mods ...bob.Mod[*dialect.UpdateQuery]) (*CorrelationRequest, error) {
+	query := psql.Update(
+		um.Table("correlation_requests"),
+		um.SetCol("status").ToArg(status),
+		um.SetCol("reviewer_id").ToArg(reviewerID),
+		um.SetCol("reviewer_role").ToArg(reviewerRole),
+		um.SetCol("review_comment").ToArg(nullIfEmpty(comment)),
+		um.SetCol("reviewed_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("request_id").EQ(psql.Arg(requestID))),
+		um.Where(psql.Quote("status").EQ(psql.Arg(CorrelationRequestPending))),
+		um.Where(psql.Quote("expires_at").GT(psql.Raw("NOW()"))),
+		um.Where(psql.Quote("requester_id").NE(psql.Arg(reviewerID))),
+		um.Returning(correlationRequestColumns...),
+	)
+	query.Apply(mods...)
+
+	request, err := bob.One(ctx, dao.db, query, scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to review correlation request: %w", err)
+	}
+	return &request, nil
+}
+
+// ExecuteRequest marks an approved, unexpired request of the requester as
+// executed. It returns nil if no such request exists, so an approval is
+// executed only once.
+func (dao *CorrelationRequestDAO) ExecuteRequest(ctx context.Context, requestID uuid.UUID, requesterID int64) (*CorrelationRequest, error) {
+	request, err := bob.One(ctx, dao.db, psql.Update(
+		um.Table("correlation_requests"),
+		um.SetCol("status").ToArg(CorrelationRequestExecuted),
+		um.SetCol("executed_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("request_id").EQ(psql.Arg(requestID))),
+		um.Where(psql.Quote("status").EQ(psql.Arg(CorrelationRequestApproved))),
+		um.Where(psql.Quote("expires_at").GT(psql.Raw("NOW()"))),
+		um.Where(psql.Quote("requester_id").EQ(psql.Arg(requesterID))),
+		um.Returning(correlationRequestColumns...),
+	), scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to execute correlation request: %w", err)
+	}
+	return &request, nil
+}
+
+// ExpireRequests marks pending and approved requests past their deadline as
+// expired and returns them
+func (dao *CorrelationRequestDAO) ExpireRequests(ctx context.Context) ([]CorrelationRequest, error) {
+	requests, err := bob.All(ctx, dao.db, psql.Update(
+		um.Table("correlation_requests"),
+		um.SetCol("status").ToArg(CorrelationRequestExpired),
+		um.Where(psql.Quote("status").In(psql.Arg(CorrelationRequestPending), psql.Arg(CorrelationRequestApproved))),
+		um.Where(psql.Quote("expires_at").LTE(psql.Raw("NOW()"))),
+		um.Returning(correlationRequestColumns...),
+	), scan.StructMapper[CorrelationRequest]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire correlation requests: %w", err)
+	}
+	return requests, nil
+}
@@ -0,0 +1,226 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Statuses of disclosure notices
+const (
+	// DisclosureNoticeEmbargoed means the notice waits for its embargo to pass
+	DisclosureNoticeEmbargoed = "embargoed"
+	// DisclosureNoticeGagged means a linked compliance report forbids the notice
+	DisclosureNoticeGagged = "gagged"
+	// DisclosureNoticeAvailable means the notice is shown the next time its owner looks
+	DisclosureNoticeAvailable = "available"
+	// DisclosureNoticeDelivered means the owner of the pseudonym was shown the notice
+	DisclosureNoticeDelivered = "delivered"
+)
+
+// Categories of the legal basis of a correlation shown in disclosure
+// notices. Correlations linked to a compliance report use its report type.
+const (
+	LegalBasisModeration    = "moderation"
+	LegalBasisInvestigation = "platform_investigation"
+)
+
+// DisclosureNotice tells the owner of a pseudonym that it was correlated. It
+// carries what the notice may reveal, and never the other pseudonyms found.
+type DisclosureNotice struct {
+	NoticeID        uuid.UUID           `db:"notice_id"`
+	AuditID         uuid.UUID           `db:"audit_id"`
+	PseudonymID     string              `db:"pseudonym_id"`
+	EmbargoUntil    time.Time           `db:"embargo_until"`
+	CreatedAt       time.Time           `db:"created_at"`
+	DeliveredAt     sql.Null[time.Time] `db:"delivered_at"`
+	CorrelatedAt    time.Time           `db:"correlated_at"`
+	CorrelationType string              `db:"correlation_type"`
+	RoleUsed        string              `db:"role_used"`
+	ReportType      sql.Null[string]    `db:"report_type"`
+	Gagged          bool                `db:"gagged"`
+}
+
+// Status returns the status of the notice at now
+func (n *DisclosureNotice) Status(now time.Time) string {
+	switch {
+	case n.DeliveredAt.Valid:
+		return DisclosureNoticeDelivered
+	case n.Gagged:
+		return DisclosureNoticeGagged
+	case n.EmbargoUntil.After(now):
+		return DisclosureNoticeEmbargoed
+	default:
+		return DisclosureNoticeAvailable
+	}
+}
+
+// LegalBasisCategory returns the category of the legal basis of the
+// correlation: the type of a linked compliance report, or the kind of
+// correlation otherwise
+func (n *DisclosureNotice) LegalBasisCategory() string {
+	if n.ReportType.Valid {
+		return n.ReportType.V
+	}
+	if n.CorrelationType == "fingerprint" {
+		return LegalBasisModeration
+	}
+	return LegalBasisInvestigation
+}
+
+// disclosureNoticeGagged is true when a compliance report linked to the
+// correlation of notice n is under a gag order
+const disclosureNoticeGagged = `EXISTS (SELECT 1 FROM compliance_correlations cc JOIN compliance_reports r ON r.report_id = cc.report_id WHERE cc.audit_id = n.audit_id AND r.gag_order)`
+
+// disclosureNoticeColumns are the columns of a notice n joined to its audit
+// entry a, in DisclosureNotice order
+var disclosureNoticeColumns = []any{
+	"n.notice_id", "n.audit_id", "n.pseudonym_id", "n.embargo_until", "n.created_at", "n.delivered_at",
+	"COALESCE(a.timestamp, n.created_at) AS correlated_at", "a.correlation_type", "a.role_used",
+	`(SELECT r.report_type FROM compliance_correlations cc JOIN compliance_reports r ON r.report_id = cc.report_id
+		WHERE cc.audit_id = n.audit_id ORDER BY cc.created_at LIMIT 1) AS report_type`,
+	disclosureNoticeGagged + " AS gagged",
+}
+
+// DisclosureNoticeDAO provides database operations for disclosure notices
+type DisclosureNoticeDAO struct {
+	db bob.Executor
+}
+
+// NewDisclosureNoticeDAO creates a new disclosure notice DAO
+func NewDisclosureNoticeDAO(db bob.Executor) *DisclosureNoticeDAO {
+	return &DisclosureNoticeDAO{
+		db: db,
+	}
+}
+
+// CreateNotices creates a notice for every completed correlation in the
+// correlation audit trail that has none yet: fingerprint correlations,
+// executed identity correlation requests and break-glass correlations. Each notice is embargoed until
+// embargo after its correlation. It returns the number of notices created.
+func (dao *DisclosureNoticeDAO) CreateNotices(ctx context.Context, embargo time.Duration) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("disclosure_notices", "audit_id", "pseudonym_id", "embargo_until"),
+		im.Query(psql.Select(
+			sm.Columns(
+				"a.audit_id", "a.requested_pseudonym",
+				psql.Raw("COALESCE(a.timestamp, NOW()) + make_interval(secs => ?)", embargo.Seconds()),
+			),
+			sm.From("correlation_audit").As("a"),
+			sm.Where(psql.Quote("a", "correlation_type").In(psql.Arg("fingerprint"), psql.Arg("identity"))),
+			sm.Where(psql.Or(
+				psql.Quote("a", "request_id").IsNull(),
+				psql.Quote("a", "request_status").EQ(psql.Arg(CorrelationRequestExecuted)),
+			)),
+			sm.Where(psql.Raw("NOT EXISTS (SELECT 1 FROM disclosure_notices n WHERE n.audit_id = a.audit_id)")),
+		)),
+		im.OnConflict("audit_id").DoNothing(),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create disclosure notices: %w", err)
+	}
+	created, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create disclosure notices: %w", err)
+	}
+	return created, nil
+}
+
+// GetNotice returns a notice, or nil if it does not exist
+func (dao *DisclosureNoticeDAO) GetNotice(ctx context.Context, noticeID uuid.UUID) (*DisclosureNotice, error) {
+	notice, err := bob.One(ctx, dao.db, selectDisclosureNotices(
+		sm.Where(psql.Quote("n", "notice_id").EQ(psql.Arg(noticeID))),
+	), scan.StructMapper[DisclosureNotice]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get disclosure notice: %w", err)
+	}
+	return &notice, nil
+}
+
+// ListNotices returns the notices of a pseudonym, or every notice if
+// pseudonymID is empty, newest first
+func (dao *DisclosureNoticeDAO) ListNotices(ctx context.Context, pseudonymID string) ([]DisclosureNotice, error) {
+	query := selectDisclosureNotices()
+	if pseudonymID != "" {
+		query.Apply(sm.Where(psql.Quote("n", "pseudonym_id").EQ(psql.Arg(pseudonymID))))
+	}
+
+	notices, err := bob.All(ctx, dao.db, query, scan.StructMapper[DisclosureNotice]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disclosure notices: %w", err)
+	}
+	return notices, nil
+}
+
+// DeliverNotices marks the notices of a pseudonym whose embargo has passed
+// and that are not gagged as delivered, and returns every delivered notice of
+// the pseudonym, newest first
+func (dao *DisclosureNoticeDAO) DeliverNotices(ctx context.Context, pseudonymID string) ([]DisclosureNotice, error) {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.TableAs("disclosure_notices", "n"),
+		um.SetCol("delivered_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("n", "pseudonym_id").EQ(psql.Arg(pseudonymID))),
+		um.Where(psql.Quote("n", "delivered_at").IsNull()),
+		um.Where(psql.Quote("n", "embargo_until").LTE(psql.Raw("NOW()"))),
+		um.Where(psql.Raw("NOT "+disclosureNoticeGagged)),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to deliver disclosure notices: %w", err)
+	}
+
+	notices, err := bob.All(ctx, dao.db, selectDisclosureNotices(
+		sm.Where(psql.Quote("n", "pseudonym_id").EQ(psql.Arg(pseudonymID))),
+		sm.Where(psql.Quote("n", "delivered_at").IsNotNull()),
+	), scan.StructMapper[DisclosureNotice]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delivered disclosure notices: %w", err)
+	}
+	return notices, nil
+}
+
+// SetEmbargo moves the embargo of an undelivered notice to embargoUntil. It
+// returns nil if no such notice exists, so a notice that was delivered
+// meanwhile keeps its embargo.
+func (dao *DisclosureNoticeDAO) SetEmbargo(ctx context.Context, noticeID uuid.UUID, embargoUntil time.Time) (*DisclosureNotice, error) {
+	_, err := bob.One(ctx, dao.db, psql.Update(
+		um.Table("disclosure_notices"),
+		um.SetCol("embargo_until").ToArg(embargoUntil),
+		um.Where(psql.Quote("notice_id").EQ(psql.Arg(noticeID))),
+		um.Where(psql.Quote("delivered_at").IsNull()),
+		um.Returning("notice_id"),
+	), scan.SingleColumnMapper[uuid.UUID])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to set disclosure notice embargo: %w", err)
+	}
+	return dao.GetNotice(ctx, noticeID)
+}
+
+// selectDisclosureNotices selects notices joined to their audit entries,
+// newest correlation first
+func selectDisclosureNotices(mods ...bob.Mod[*dialect.SelectQuery]) bob.BaseQuery[*dialect.SelectQuery] {
+	query := psql.Select(
+		sm.Columns(disclosureNoticeColumns...),
+		sm.From("disclosure_notices").As("n"),
+		sm.InnerJoin("correlation_audit").As("a").OnEQ(psql.Quote("a", "audit_id"), psql.Quote("n", "audit_id")),
+		sm.OrderBy(psql.Quote("correlated_at")).Desc(),
+	)
+	query.Apply(mods...)
+	return query
+}
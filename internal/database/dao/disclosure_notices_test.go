@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisclosureNotice_Status(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		notice DisclosureNotice
+		want   string
+	}{
+		{"embargoed", DisclosureNotice{EmbargoUntil: now.Add(time.Hour)}, DisclosureNoticeEmbargoed},
+		{"embargo passed", DisclosureNotice{EmbargoUntil: now.Add(-time.Hour)}, DisclosureNoticeAvailable},
+		{"gag outlasts the embargo", DisclosureNotice{EmbargoUntil: now.Add(-time.Hour), Gagged: true}, DisclosureNoticeGagged},
+		{"gag during the embargo", DisclosureNotice{EmbargoUntil: now.Add(time.Hour), Gagged: true}, DisclosureNoticeGagged},
+		{"delivered", DisclosureNotice{
+			EmbargoUntil: now.Add(-time.Hour),
+			DeliveredAt:  sql.Null[time.Time]{V: now, Valid: true},
+		}, DisclosureNoticeDelivered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.notice.Status(now))
+		})
+	}
+}
+
+func TestDisclosureNotice_LegalBasisCategory(t *testing.T) {
+	fingerprint := DisclosureNotice{CorrelationType: "fingerprint"}
+	assert.Equal(t, LegalBasisModeration, fingerprint.LegalBasisCategory())
+
+	identity := DisclosureNotice{CorrelationType: "identity"}
+	assert.Equal(t, LegalBasisInvestigation, identity.LegalBasisCategory())
+
+	// A linked compliance report names the basis, whatever the correlation
+	identity.ReportType = sql.Null[string]{V: "court_order", Valid: true}
+	assert.Equal(t, "court_order", identity.LegalBasisCategory())
+}
@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Purposes of tokens mailed to users
+const (
+	EmailTokenPurposeVerification  = "email_verification"
+	EmailTokenPurposePasswordReset = "password_reset"
+)
+
+// EmailTokenRecord is a consumed email token
+type EmailTokenRecord struct {
+	TokenID int64  `db:"token_id"`
+	UserID  int64  `db:"user_id"`
+	Purpose string `db:"purpose"`
+	Email   string `db:"email"`
+}
+
+// EmailTokenDAO provides database operations for email verification and
+// password reset tokens
+type EmailTokenDAO struct {
+	db bob.Executor
+}
+
+// NewEmailTokenDAO creates a new email token DAO
+func NewEmailTokenDAO(db bob.Executor) *EmailTokenDAO {
+	return &EmailTokenDAO{
+		db: db,
+	}
+}
+
+// StoreToken records the hash of a token mailed to a user at the given address
+func (dao *EmailTokenDAO) StoreToken(ctx context.Context, userID int64, purpose, email, tokenHash string, expiresAt time.Time) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("email_tokens", "user_id", "purpose", "email", "token_hash", "expires_at"),
+		im.Values(psql.Arg(userID), psql.Arg(purpose), psql.Arg(email), psql.Arg(tokenHash), psql.Arg(expiresAt)),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to store email token: %w", err)
+	}
+	return nil
+}
+
+// ConsumeToken marks an unused, unexpired token as used and returns it. It
+// returns nil if no such token exists, so a token can be consumed only once
+// even by concurrent requests.
+func (dao *EmailTokenDAO) ConsumeToken(ctx context.Context, purpose, tokenHash string) (*EmailTokenRecord, error) {
+	record, err := bob.One(ctx, dao.db, psql.Update(
+		um.Table("email_tokens"),
+		um.SetCol("used_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("token_hash").EQ(psql.Arg(tokenHash))),
+		um.Where(psql.Quote("purpose").EQ(psql.Arg(purpose))),
+		um.Where(psql.Quote("used_at").IsNull()),
+		um.Where(psql.Quote("expires_at").GT(psql.Raw("NOW()"))),
+		um.Returning("token_id", "user_id", "purpose", "email"),
+	), scan.StructMapper[EmailTokenRecord]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume email token: %w", err)
+	}
+	return &record, nil
+}
+
+// InvalidateUserTokens marks every unused token of a purpose for a user as used
+func (dao *EmailTokenDAO) InvalidateUserTokens(ctx context.Context, userID int64, purpose string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("email_tokens"),
+		um.SetCol("used_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Quote("purpose").EQ(psql.Arg(purpose))),
+		um.Where(psql.Quote("used_at").IsNull()),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to invalidate email tokens: %w", err)
+	}
+	return nil
+}
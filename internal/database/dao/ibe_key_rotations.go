@@ -0,0 +1,137 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Statuses of IBE key rotations
+const (
+	// IBEKeyRotationReencrypting means identity mappings are being re-encrypted under the new version
+	IBEKeyRotationReencrypting = "reencrypting"
+	// IBEKeyRotationGrace means every mapping uses the new version; the previous one is still kept
+	IBEKeyRotationGrace = "grace"
+	// IBEKeyRotationRetired means the previous version has been destroyed
+	IBEKeyRotationRetired = "retired"
+)
+
+// IBEKeyRotation is a rotation of the IBE domain masters to a new key version
+type IBEKeyRotation struct {
+	KeyVersion          int                 `db:"key_version"`
+	PreviousVersion     int                 `db:"previous_version"`
+	Status              string              `db:"status"`
+	MappingsReencrypted int64               `db:"mappings_reencrypted"`
+	MappingsFailed      int64               `db:"mappings_failed"`
+	StartedAt           time.Time           `db:"started_at"`
+	ReencryptedAt       sql.Null[time.Time] `db:"reencrypted_at"`
+	RetiredAt           sql.Null[time.Time] `db:"retired_at"`
+}
+
+// IBEKeyRotationDAO provides database operations for IBE key rotations
+type IBEKeyRotationDAO struct {
+	db bob.Executor
+}
+
+// NewIBEKeyRotationDAO creates a new IBE key rotation DAO
+func NewIBEKeyRotationDAO(db bob.Executor) *IBEKeyRotationDAO {
+	return &IBEKeyRotationDAO{
+		db: db,
+	}
+}
+
+// ibeKeyRotationColumns are the columns of ibe_key_rotations, in IBEKeyRotation order
+var ibeKeyRotationColumns = []any{
+	"key_version", "previous_version", "status", "mappings_reencrypted",
+	"mappings_failed", "started_at", "reencrypted_at", "retired_at",
+}
+
+// StartRotation records the start of a rotation to keyVersion. It returns
+// nil if a rotation to that version was already started, so that only one
+// caller ever generates the masters of a version.
+func (dao *IBEKeyRotationDAO) StartRotation(ctx context.Context, keyVersion, previousVersion int) (*IBEKeyRotation, error) {
+	rotation, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("ibe_key_rotations", "key_version", "previous_version", "status", "started_at"),
+		im.Values(psql.Arg(keyVersion), psql.Arg(previousVersion), psql.Arg(IBEKeyRotationReencrypting), psql.Raw("NOW()")),
+		im.OnConflict("key_version").DoNothing(),
+		im.Returning(ibeKeyRotationColumns...),
+	), scan.StructMapper[IBEKeyRotation]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to start IBE key rotation: %w", err)
+	}
+	return &rotation, nil
+}
+
+// DeleteRotation removes a rotation that could not be started
+func (dao *IBEKeyRotationDAO) DeleteRotation(ctx context.Context, keyVersion int) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("ibe_key_rotations"),
+		dm.Where(psql.Quote("key_version").EQ(psql.Arg(keyVersion))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to delete IBE key rotation: %w", err)
+	}
+	return nil
+}
+
+// ListRotations returns every rotation, oldest first
+func (dao *IBEKeyRotationDAO) ListRotations(ctx context.Context) ([]IBEKeyRotation, error) {
+	rotations, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns(ibeKeyRotationColumns...),
+		sm.From("ibe_key_rotations"),
+		sm.OrderBy(psql.Quote("key_version")),
+	), scan.StructMapper[IBEKeyRotation]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IBE key rotations: %w", err)
+	}
+	return rotations, nil
+}
+
+// RecordProgress adds re-encrypted mappings to a rotation's count and sets
+// the number of mappings the last run could not re-encrypt
+func (dao *IBEKeyRotationDAO) RecordProgress(ctx context.Context, keyVersion int, reencrypted, failed int64) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("ibe_key_rotations"),
+		um.SetCol("mappings_reencrypted").To(psql.Raw("mappings_reencrypted + ?", reencrypted)),
+		um.SetCol("mappings_failed").ToArg(failed),
+		um.Where(psql.Quote("key_version").EQ(psql.Arg(keyVersion))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to record IBE key rotation progress: %w", err)
+	}
+	return nil
+}
+
+// SetStatus moves a rotation to a status, stamping when it finished
+// re-encryption or retired the previous version
+func (dao *IBEKeyRotationDAO) SetStatus(ctx context.Context, keyVersion int, status string) error {
+	query := psql.Update(
+		um.Table("ibe_key_rotations"),
+		um.SetCol("status").ToArg(status),
+		um.Where(psql.Quote("key_version").EQ(psql.Arg(keyVersion))),
+	)
+	switch status {
+	case IBEKeyRotationGrace:
+		query.Apply(um.SetCol("reencrypted_at").To(psql.Raw("NOW()")))
+	case IBEKeyRotationRetired:
+		query.Apply(um.SetCol("retired_at").To(psql.Raw("NOW()")))
+	}
+
+	if _, err := bob.Exec(ctx, dao.db, query); err != nil {
+		return fmt.Errorf("failed to update IBE key rotation status: %w", err)
+	}
+	return nil
+}
@@ -3,10 +3,16 @@ package dao
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/matt0x6f/hashpost/internal/database/models"
 	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
 )
 
 // IdentityMappingDAO provides database operations for identity mappings
@@ -37,6 +43,25 @@ func (dao *IdentityMappingDAO) GetIdentityMappingsByPseudonymID(ctx context.Cont
 	).All(ctx, dao.db)
 }
 
+// GetRoleIdentityMapping retrieves the active identity mapping of a pseudonym
+// encrypted under the role key of a role and scope. It returns nil if there
+// is none.
+func (dao *IdentityMappingDAO) GetRoleIdentityMapping(ctx context.Context, pseudonymID, roleName, scope string) (*models.IdentityMapping, error) {
+	mapping, err := models.IdentityMappings.Query(
+		models.SelectWhere.IdentityMappings.PseudonymID.EQ(pseudonymID),
+		models.SelectWhere.IdentityMappings.RoleName.EQ(roleName),
+		models.SelectWhere.IdentityMappings.KeyScope.EQ(scope),
+		models.SelectWhere.IdentityMappings.IsActive.EQ(true),
+	).One(ctx, dao.db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity mapping for role=%s scope=%s: %w", roleName, scope, err)
+	}
+	return mapping, nil
+}
+
 // GetIdentityMappingsByFingerprint retrieves all identity mappings for a given fingerprint
 func (dao *IdentityMappingDAO) GetIdentityMappingsByFingerprint(ctx context.Context, fingerprint string) (models.IdentityMappingSlice, error) {
 	return models.IdentityMappings.Query(
@@ -110,3 +135,68 @@ func (dao *IdentityMappingDAO) GetCorrelationData(ctx context.Context, pseudonym
 
 	return requestedMapping, relatedMappings, nil
 }
+
+// ListMappingsNotAtKeyVersion returns up to limit mappings, active or not,
+// that are encrypted under a key version other than keyVersion. Mappings are
+// ordered by ID and start after the given ID, so that a caller can page
+// through them even when some cannot be re-encrypted.
+func (dao *IdentityMappingDAO) ListMappingsNotAtKeyVersion(ctx context.Context, keyVersion int32, after uuid.UUID, limit int) (models.IdentityMappingSlice, error) {
+	mappings, err := models.IdentityMappings.Query(
+		models.SelectWhere.IdentityMappings.KeyVersion.NE(keyVersion),
+		models.SelectWhere.IdentityMappings.MappingID.GT(after),
+		sm.OrderBy(models.IdentityMappingColumns.MappingID),
+		sm.Limit(limit),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identity mappings to re-encrypt: %w", err)
+	}
+	return mappings, nil
+}
+
+// ReencryptMapping replaces the ciphertexts of a mapping that is still under
+// fromVersion with ones under toVersion. It reports false if the mapping was
+// changed concurrently and was left alone.
+func (dao *IdentityMappingDAO) ReencryptMapping(ctx context.Context, mappingID uuid.UUID, fromVersion, toVersion int32, encryptedRealIdentity, encryptedPseudonymMapping []byte) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("identity_mappings"),
+		um.SetCol("encrypted_real_identity").ToArg(encryptedRealIdentity),
+		um.SetCol("encrypted_pseudonym_mapping").ToArg(encryptedPseudonymMapping),
+		um.SetCol("key_version").ToArg(toVersion),
+		um.SetCol("updated_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("mapping_id").EQ(psql.Arg(mappingID))),
+		um.Where(psql.Quote("key_version").EQ(psql.Arg(fromVersion))),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to re-encrypt identity mapping: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check re-encrypted identity mapping: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// keyVersionCount is the number of mappings under a key version
+type keyVersionCount struct {
+	KeyVersion int32 `db:"key_version"`
+	Count      int64 `db:"count"`
+}
+
+// CountMappingsByKeyVersion returns the number of mappings, active or not,
+// under each key version
+func (dao *IdentityMappingDAO) CountMappingsByKeyVersion(ctx context.Context) (map[int32]int64, error) {
+	rows, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns("key_version", psql.Raw("COUNT(*)").As("count")),
+		sm.From("identity_mappings"),
+		sm.GroupBy("key_version"),
+	), scan.StructMapper[keyVersionCount]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count identity mappings by key version: %w", err)
+	}
+
+	counts := make(map[int32]int64, len(rows))
+	for _, row := range rows {
+		counts[row.KeyVersion] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,106 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditChain_ConcurrentAppendsVerify(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	ctx := context.Background()
+	admin := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("audit_chain"), "password123", []string{"user", "trust_safety"})
+
+	signer, err := auditchain.GenerateSigner()
+	require.NoError(t, err)
+	chainDAO := dao.NewAuditChainDAO(suite.DB, signer, 0)
+	auditDAO := dao.NewCorrelationAuditDAO(suite.DB, chainDAO)
+
+	before, err := chainDAO.GetHead(ctx, dao.CorrelationAuditChain)
+	require.NoError(t, err)
+	require.NotNil(t, before)
+
+	// Concurrent writers must not fork the chain
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- auditDAO.RecordEntry(ctx, &dao.CorrelationAuditEntry{
+				UserID:             admin.UserID,
+				PseudonymID:        admin.PseudonymID,
+				AdminUsername:      admin.Email,
+				RoleUsed:           "trust_safety",
+				RequestedPseudonym: admin.PseudonymID,
+				Justification:      fmt.Sprintf("Concurrent audit entry %d", i),
+				CorrelationType:    "fingerprint",
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	after, err := chainDAO.GetHead(ctx, dao.CorrelationAuditChain)
+	require.NoError(t, err)
+	assert.Equal(t, before.Seq+writers, after.Seq)
+
+	verifier := auditchain.NewVerifier(dao.CorrelationAuditChain, nil)
+	err = chainDAO.WalkChain(ctx, dao.CorrelationAuditChain, func(id string, link auditchain.Link, fields []auditchain.Field) error {
+		return verifier.Next(id, link, fields...)
+	})
+	require.NoError(t, err)
+	require.NoError(t, verifier.Finish(after.Seq, after.HeadHash))
+
+	// A checkpoint of the head verifies against the signer's public key
+	checkpoint, err := chainDAO.Checkpoint(ctx, dao.CorrelationAuditChain, after.Seq, after.HeadHash)
+	require.NoError(t, err)
+	assert.NoError(t, auditchain.VerifyCheckpoint(signer.PublicKey(), checkpoint))
+}
+
+func TestAuditChain_AppendOnly(t *testing.T) {
+	suite := testutil.NewIntegrationTestSuite(t)
+	if suite == nil {
+		return
+	}
+	defer suite.Cleanup()
+
+	ctx := context.Background()
+	admin := suite.CreateTestUser(t, testutil.GenerateUniqueEmail("audit_append_only"), "password123", []string{"user", "trust_safety"})
+
+	auditDAO := dao.NewCorrelationAuditDAO(suite.DB, suite.AuditChainDAO)
+	entry := &dao.CorrelationAuditEntry{
+		UserID:             admin.UserID,
+		PseudonymID:        admin.PseudonymID,
+		AdminUsername:      admin.Email,
+		RoleUsed:           "trust_safety",
+		RequestedPseudonym: admin.PseudonymID,
+		Justification:      "Append-only audit entry",
+		CorrelationType:    "fingerprint",
+	}
+	require.NoError(t, auditDAO.RecordEntry(ctx, entry))
+
+	_, err := suite.DB.ExecContext(ctx, "UPDATE correlation_audit SET justification = 'changed' WHERE audit_id = $1", entry.AuditID)
+	assert.Error(t, err, "Audit entries should not be updatable")
+
+	_, err = suite.DB.ExecContext(ctx, "DELETE FROM correlation_audit WHERE audit_id = $1", entry.AuditID)
+	assert.Error(t, err, "Audit entries should not be deletable")
+}
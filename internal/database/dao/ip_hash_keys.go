@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/scan"
+)
+
+// IPHashKeyDAO provides database operations for the daily keys client
+// addresses are hashed with
+type IPHashKeyDAO struct {
+	db bob.Executor
+}
+
+// NewIPHashKeyDAO creates a new IP hash key DAO
+func NewIPHashKeyDAO(db bob.Executor) *IPHashKeyDAO {
+	return &IPHashKeyDAO{
+		db: db,
+	}
+}
+
+// GetOrCreateKey returns the key of a UTC day, storing candidate as the key
+// if the day has none yet. Concurrent callers all get the same key.
+func (dao *IPHashKeyDAO) GetOrCreateKey(ctx context.Context, day time.Time, candidate []byte) ([]byte, error) {
+	key, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("ip_hash_keys", "day", "secret"),
+		im.Values(psql.Cast(psql.Arg(day.Format(time.DateOnly)), "DATE"), psql.Arg(candidate)),
+		// A no-op update so that the existing key is returned
+		im.OnConflict("day").DoUpdate(im.SetCol("day").To(psql.Raw("EXCLUDED.day"))),
+		im.Returning("secret"),
+	), scan.SingleColumnMapper[[]byte])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IP hash key: %w", err)
+	}
+	return key, nil
+}
+
+// ListKeys returns the keys of the UTC days from since up to but excluding
+// before, newest first
+func (dao *IPHashKeyDAO) ListKeys(ctx context.Context, since, before time.Time) ([][]byte, error) {
+	keys, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns("secret"),
+		sm.From("ip_hash_keys"),
+		sm.Where(psql.Quote("day").GTE(psql.Cast(psql.Arg(since.Format(time.DateOnly)), "DATE"))),
+		sm.Where(psql.Quote("day").LT(psql.Cast(psql.Arg(before.Format(time.DateOnly)), "DATE"))),
+		sm.OrderBy(psql.Quote("day")).Desc(),
+	), scan.SingleColumnMapper[[]byte])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP hash keys: %w", err)
+	}
+	return keys, nil
+}
+
+// PurgeBefore deletes the keys of the UTC days before day. Hashes made with
+// them can no longer be matched.
+func (dao *IPHashKeyDAO) PurgeBefore(ctx context.Context, day time.Time) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("ip_hash_keys"),
+		dm.Where(psql.Quote("day").LT(psql.Cast(psql.Arg(day.Format(time.DateOnly)), "DATE"))),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge IP hash keys: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged IP hash keys: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/auditchain"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+)
+
+// Operations recorded in the key usage audit trail
+const (
+	KeyUsageCorrelation = "correlation"
+	KeyUsageDecryption  = "decryption"
+	KeyUsageKeyRotation = "key_rotation"
+)
+
+// KeyUsageAuditEntry is a record of a role key being used. AuditClient
+// describes the client the key was used for.
+type KeyUsageAuditEntry struct {
+	UsageID           uuid.UUID           `db:"usage_id"`
+	KeyID             uuid.UUID           `db:"key_id"`
+	UserID            int64               `db:"user_id"`
+	OperationType     string              `db:"operation_type"`
+	TargetFingerprint sql.Null[string]    `db:"target_fingerprint"`
+	TargetPseudonym   sql.Null[string]    `db:"target_pseudonym"`
+	Success           bool                `db:"success"`
+	ErrorMessage      sql.Null[string]    `db:"error_message"`
+	Timestamp         sql.Null[time.Time] `db:"timestamp"`
+	AuditClient
+	AuditChainLink
+}
+
+// chainFields returns the columns of the entry as hashed into the audit chain
+func (e *KeyUsageAuditEntry) chainFields() []auditchain.Field {
+	fields := []auditchain.Field{
+		auditchain.String("usage_id", e.UsageID.String()),
+		auditchain.String("key_id", e.KeyID.String()),
+		auditchain.Int("user_id", e.UserID),
+		auditchain.String("operation_type", e.OperationType),
+		nullableField("target_fingerprint", e.TargetFingerprint, auditchain.String),
+		nullableField("target_pseudonym", e.TargetPseudonym, auditchain.String),
+		auditchain.Bool("success", e.Success),
+		nullableField("error_message", e.ErrorMessage, auditchain.String),
+		nullableField("timestamp", e.Timestamp, auditchain.Time),
+	}
+	return append(fields, e.AuditClient.chainFields()...)
+}
+
+// keyUsageAuditColumns are the columns of key_usage_audit, in KeyUsageAuditEntry order
+var keyUsageAuditColumns = []any{
+	"usage_id", "key_id", "user_id", "operation_type", "target_fingerprint",
+	"target_pseudonym", "success", "error_message", "timestamp",
+	"ip_hash", "ip_prefix", "user_agent_family",
+	"chain_seq", "prev_hash", "entry_hash",
+}
+
+// KeyUsageAuditDAO provides database operations for the key usage audit trail
+type KeyUsageAuditDAO struct {
+	db    bob.Executor
+	chain *AuditChainDAO
+}
+
+// NewKeyUsageAuditDAO creates a new key usage audit DAO. Entries are
+// appended to the key usage audit chain through chain.
+func NewKeyUsageAuditDAO(db bob.Executor, chain *AuditChainDAO) *KeyUsageAuditDAO {
+	return &KeyUsageAuditDAO{
+		db:    db,
+		chain: chain,
+	}
+}
+
+// RecordEntry appends an entry to the audit trail. A zero UsageID is
+// generated and an unset Timestamp is the current time.
+func (dao *KeyUsageAuditDAO) RecordEntry(ctx context.Context, entry *KeyUsageAuditEntry) error {
+	if entry.UsageID == uuid.Nil {
+		entry.UsageID = uuid.Must(uuid.NewV4())
+	}
+	if !entry.Timestamp.Valid {
+		entry.Timestamp = sql.Null[time.Time]{V: time.Now(), Valid: true}
+	}
+	// Store the timestamp exactly as it is hashed
+	entry.Timestamp.V = entry.Timestamp.V.UTC().Truncate(time.Microsecond)
+
+	err := dao.chain.appendEntry(ctx, KeyUsageAuditChain, "key_usage_audit",
+		append([]string{
+			"usage_id", "key_id", "user_id", "operation_type", "target_fingerprint",
+			"target_pseudonym", "success", "error_message", "timestamp",
+		}, auditClientColumns...),
+		append([]any{
+			psql.Cast(psql.Arg(entry.UsageID), "UUID"), psql.Cast(psql.Arg(entry.KeyID), "UUID"),
+			psql.Cast(psql.Arg(entry.UserID), "BIGINT"), psql.Cast(psql.Arg(entry.OperationType), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.TargetFingerprint), "VARCHAR"), psql.Cast(psql.Arg(entry.TargetPseudonym), "VARCHAR"),
+			psql.Cast(psql.Arg(entry.Success), "BOOLEAN"), psql.Cast(psql.Arg(entry.ErrorMessage), "TEXT"),
+			psql.Cast(psql.Arg(entry.Timestamp), "TIMESTAMPTZ"),
+		}, entry.AuditClient.values()...),
+		entry.chainFields(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record key usage audit entry: %w", err)
+	}
+	return nil
+}
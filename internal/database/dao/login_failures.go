@@ -0,0 +1,125 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Scopes failed logins are counted in
+const (
+	LoginFailureScopeAccount = "account"
+	LoginFailureScopeIP      = "ip"
+)
+
+// LoginFailureDAO provides database operations for failed login counters
+type LoginFailureDAO struct {
+	db bob.Executor
+}
+
+// NewLoginFailureDAO creates a new login failure DAO
+func NewLoginFailureDAO(db bob.Executor) *LoginFailureDAO {
+	return &LoginFailureDAO{
+		db: db,
+	}
+}
+
+// BlockedUntil returns when a subject may attempt to log in again. It returns
+// the zero time if the subject is not blocked.
+func (dao *LoginFailureDAO) BlockedUntil(ctx context.Context, scope, subject string) (time.Time, error) {
+	blockedUntil, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("blocked_until"),
+		sm.From("login_failures"),
+		sm.Where(psql.Quote("scope").EQ(psql.Arg(scope))),
+		sm.Where(psql.Quote("subject").EQ(psql.Arg(subject))),
+		sm.Where(psql.Quote("blocked_until").GT(psql.Raw("NOW()"))),
+	), scan.SingleColumnMapper[time.Time])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to check login block: %w", err)
+	}
+	return blockedUntil, nil
+}
+
+// RecordFailure counts a failed login for a subject and returns the new
+// count. A count whose last failure is older than window starts over.
+func (dao *LoginFailureDAO) RecordFailure(ctx context.Context, scope, subject string, window time.Duration) (int, error) {
+	cutoff := time.Now().UTC().Add(-window)
+	count, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("login_failures", "scope", "subject", "failure_count", "first_failed_at", "last_failed_at"),
+		im.Values(psql.Arg(scope), psql.Arg(subject), psql.Arg(1), psql.Raw("NOW()"), psql.Raw("NOW()")),
+		im.OnConflict("scope", "subject").DoUpdate(
+			im.SetCol("failure_count").To(psql.Raw("CASE WHEN login_failures.last_failed_at < ? THEN 1 ELSE login_failures.failure_count + 1 END", cutoff)),
+			im.SetCol("first_failed_at").To(psql.Raw("CASE WHEN login_failures.last_failed_at < ? THEN NOW() ELSE login_failures.first_failed_at END", cutoff)),
+			im.SetCol("last_failed_at").To(psql.Raw("NOW()")),
+		),
+		im.Returning("failure_count"),
+	), scan.SingleColumnMapper[int])
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return count, nil
+}
+
+// Block refuses login attempts from a subject until the given time. A longer
+// block already in place is kept.
+func (dao *LoginFailureDAO) Block(ctx context.Context, scope, subject string, until time.Time) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("login_failures"),
+		um.SetCol("blocked_until").To(psql.Raw("GREATEST(COALESCE(blocked_until, ?), ?)", until.UTC(), until.UTC())),
+		um.Where(psql.Quote("scope").EQ(psql.Arg(scope))),
+		um.Where(psql.Quote("subject").EQ(psql.Arg(subject))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to block login attempts: %w", err)
+	}
+	return nil
+}
+
+// Clear forgets the failures of a subject and lifts any block. It reports
+// whether the subject was blocked.
+func (dao *LoginFailureDAO) Clear(ctx context.Context, scope, subject string) (bool, error) {
+	cleared, err := bob.All(ctx, dao.db, psql.Delete(
+		dm.From("login_failures"),
+		dm.Where(psql.Quote("scope").EQ(psql.Arg(scope))),
+		dm.Where(psql.Quote("subject").EQ(psql.Arg(subject))),
+		dm.Returning(psql.Raw("blocked_until > NOW()")),
+	), scan.SingleColumnMapper[sql.Null[bool]])
+	if err != nil {
+		return false, fmt.Errorf("failed to clear login failures: %w", err)
+	}
+	return len(cleared) > 0 && cleared[0].Valid && cleared[0].V, nil
+}
+
+// PurgeStale removes counters with no failure for longer than maxAge that are
+// not blocking anyone
+func (dao *LoginFailureDAO) PurgeStale(ctx context.Context, maxAge time.Duration) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("login_failures"),
+		dm.Where(psql.Quote("last_failed_at").LT(psql.Arg(time.Now().UTC().Add(-maxAge)))),
+		dm.Where(psql.Or(
+			psql.Quote("blocked_until").IsNull(),
+			psql.Quote("blocked_until").LT(psql.Raw("NOW()")),
+		)),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge login failures: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged login failures: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,185 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// MFADAO provides database operations for multi-factor authentication state
+type MFADAO struct {
+	db bob.Executor
+}
+
+// NewMFADAO creates a new MFA DAO
+func NewMFADAO(db bob.Executor) *MFADAO {
+	return &MFADAO{
+		db: db,
+	}
+}
+
+// SetPendingSecret stores an encrypted TOTP secret that has not been
+// confirmed yet. MFA stays disabled until the user proves possession with a
+// valid code.
+func (dao *MFADAO) SetPendingSecret(ctx context.Context, userID int64, secret string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("mfa_secret").ToArg(secret),
+		um.SetCol("mfa_enabled").ToArg(false),
+		um.SetCol("mfa_last_used_step").To(psql.Raw("NULL")),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to store pending MFA secret: %w", err)
+	}
+	return nil
+}
+
+// UpdateSecret replaces a user's stored TOTP secret, such as when a secret
+// stored in plaintext is encrypted
+func (dao *MFADAO) UpdateSecret(ctx context.Context, userID int64, secret string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("mfa_secret").ToArg(secret),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to update MFA secret: %w", err)
+	}
+	return nil
+}
+
+// EnableMFA marks MFA as enabled for a user after enrollment is confirmed
+func (dao *MFADAO) EnableMFA(ctx context.Context, userID int64) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("mfa_enabled").ToArg(true),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to enable MFA: %w", err)
+	}
+	return nil
+}
+
+// DisableMFA clears a user's TOTP secret and removes their recovery codes
+func (dao *MFADAO) DisableMFA(ctx context.Context, userID int64) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("mfa_secret").To(psql.Raw("NULL")),
+		um.SetCol("mfa_enabled").ToArg(false),
+		um.SetCol("mfa_last_used_step").To(psql.Raw("NULL")),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	if err := dao.deleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecordTOTPStep records the time step of an accepted TOTP code. It returns
+// false if the step is not newer than the last accepted one, which means the
+// code is being replayed.
+func (dao *MFADAO) RecordTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("mfa_last_used_step").ToArg(step),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Group(psql.Or(
+			psql.Quote("mfa_last_used_step").IsNull(),
+			psql.Quote("mfa_last_used_step").LT(psql.Arg(step)),
+		))),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check TOTP step update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// ReplaceRecoveryCodes deletes any existing recovery codes for a user and stores the given hashes
+func (dao *MFADAO) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	if err := dao.deleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	if len(codeHashes) == 0 {
+		return nil
+	}
+
+	rows := make([][]bob.Expression, len(codeHashes))
+	for i, hash := range codeHashes {
+		rows[i] = []bob.Expression{psql.Arg(userID), psql.Arg(hash)}
+	}
+
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("mfa_recovery_codes", "user_id", "code_hash"),
+		im.Rows(rows...),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks a recovery code as used. It returns false if the
+// code does not exist or has already been used.
+func (dao *MFADAO) ConsumeRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("mfa_recovery_codes"),
+		um.SetCol("used_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Quote("code_hash").EQ(psql.Arg(codeHash))),
+		um.Where(psql.Quote("used_at").IsNull()),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check recovery code update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// CountRemainingRecoveryCodes returns the number of unused recovery codes for a user
+func (dao *MFADAO) CountRemainingRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	count, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From("mfa_recovery_codes"),
+		sm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		sm.Where(psql.Quote("used_at").IsNull()),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// deleteRecoveryCodes removes all recovery codes for a user
+func (dao *MFADAO) deleteRecoveryCodes(ctx context.Context, userID int64) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("mfa_recovery_codes"),
+		dm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
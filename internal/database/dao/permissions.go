@@ -186,6 +186,19 @@ func (dao *PermissionDAO) HasSubforumCapability(ctx context.Context, userID int6
 	return false, nil
 }
 
+// IsSubforumModerator checks if a user is one of the moderators of a subforum
+func (dao *PermissionDAO) IsSubforumModerator(ctx context.Context, userID int64, subforumID int32) (bool, error) {
+	exists, err := models.SubforumModerators.Query(
+		models.SelectWhere.SubforumModerators.SubforumID.EQ(subforumID),
+		models.SelectWhere.SubforumModerators.UserID.EQ(userID),
+	).Exists(ctx, dao.db)
+	if err != nil {
+		return false, fmt.Errorf("failed to check subforum moderator: %w", err)
+	}
+
+	return exists, nil
+}
+
 // GetUserSubforumRoles returns the roles a user has for a specific subforum
 func (dao *PermissionDAO) GetUserSubforumRoles(ctx context.Context, userID int64, subforumID int32) ([]string, error) {
 	var roles []string
@@ -259,6 +259,20 @@ func (dao *PostDAO) CountPostsByPseudonym(ctx context.Context, pseudonymID strin
 	return count, nil
 }
 
+// GetPostsByPseudonym retrieves every post by a pseudonym, removed posts
+// included, oldest first
+func (dao *PostDAO) GetPostsByPseudonym(ctx context.Context, pseudonymID string) ([]*models.Post, error) {
+	posts, err := models.Posts.Query(
+		models.SelectWhere.Posts.PseudonymID.EQ(pseudonymID),
+		sm.OrderBy("created_at ASC, post_id ASC"),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts by pseudonym: %w", err)
+	}
+
+	return posts, nil
+}
+
 // CountPostsByPseudonymInSubforum counts posts by a pseudonym in a specific subforum
 func (dao *PostDAO) CountPostsByPseudonymInSubforum(ctx context.Context, pseudonymID string, subforumID int32) (int64, error) {
 	count, err := models.Posts.Query(
@@ -276,6 +290,35 @@ func (dao *PostDAO) CountPostsByPseudonymInSubforum(ctx context.Context, pseudon
 	return count, nil
 }
 
+// CountPostsByPseudonymSince counts the posts, removed posts included, a
+// pseudonym made anywhere at or after a time
+func (dao *PostDAO) CountPostsByPseudonymSince(ctx context.Context, pseudonymID string, since time.Time) (int64, error) {
+	count, err := models.Posts.Query(
+		models.SelectWhere.Posts.PseudonymID.EQ(pseudonymID),
+		models.SelectWhere.Posts.CreatedAt.GTE(since),
+	).Count(ctx, dao.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts by pseudonym: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountPostsByPseudonymInSubforumSince counts the posts, removed posts
+// included, a pseudonym made in a subforum at or after a time
+func (dao *PostDAO) CountPostsByPseudonymInSubforumSince(ctx context.Context, pseudonymID string, subforumID int32, since time.Time) (int64, error) {
+	count, err := models.Posts.Query(
+		models.SelectWhere.Posts.PseudonymID.EQ(pseudonymID),
+		models.SelectWhere.Posts.SubforumID.EQ(subforumID),
+		models.SelectWhere.Posts.CreatedAt.GTE(since),
+	).Count(ctx, dao.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts by pseudonym in subforum: %w", err)
+	}
+
+	return count, nil
+}
+
 // GetSubforumsByPseudonym gets all subforums where a pseudonym has posted
 func (dao *PostDAO) GetSubforumsByPseudonym(ctx context.Context, pseudonymID string) ([]int32, error) {
 	posts, err := models.Posts.Query(
@@ -6,8 +6,8 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/matt0x6f/hashpost/internal/database/models"
@@ -94,13 +94,13 @@ func (dao *SecurePseudonymDAO) VerifyPseudonymOwnership(ctx context.Context, pse
 		return false, fmt.Errorf("role key does not have permission to verify pseudonym ownership")
 	}
 
-	// Get the role key for this operation
-	keyData, err := dao.roleKeyDAO.GetKeyData(ctx, roleName, scope)
+	// Get the role keys for this operation, one per live key version
+	keyData, err := dao.roleKeyDAO.GetKeyDataVersions(ctx, roleName, scope)
 	if err != nil {
 		return false, fmt.Errorf("failed to get role key: %w", err)
 	}
 
-	// Use the key to verify ownership
+	// Use the keys to verify ownership
 	return dao.verifyPseudonymOwnershipWithKey(ctx, pseudonymID, userID, keyData)
 }
 
@@ -116,13 +116,13 @@ func (dao *SecurePseudonymDAO) GetRealIdentityByPseudonym(ctx context.Context, p
 		return "", fmt.Errorf("role key does not have permission for cross-user correlation")
 	}
 
-	// Get the role key for this operation
-	keyData, err := dao.roleKeyDAO.GetKeyData(ctx, roleName, scope)
+	// Get the role keys for this operation, one per live key version
+	keyData, err := dao.roleKeyDAO.GetKeyDataVersions(ctx, roleName, scope)
 	if err != nil {
 		return "", fmt.Errorf("failed to get role key: %w", err)
 	}
 
-	// Use the key to get real identity
+	// Use the keys to get real identity
 	return dao.getRealIdentityByPseudonymWithKey(ctx, pseudonymID, keyData)
 }
 
@@ -145,23 +145,17 @@ func (dao *SecurePseudonymDAO) getPseudonymsByUserIDWithKey(ctx context.Context,
 func (dao *SecurePseudonymDAO) getPseudonymsByRealIdentityWithKey(ctx context.Context, realIdentity string, keyData []byte) ([]*models.Pseudonym, error) {
 	// 1. Generate fingerprint from real identity
 	fingerprint := dao.ibeSystem.GenerateFingerprint(realIdentity)
-	log.Info().
-		Str("real_identity", realIdentity).
-		Str("fingerprint", fingerprint).
-		Msg("Generated fingerprint for real identity")
 
 	// 2. Get all identity mappings for this fingerprint
 	mappings, err := dao.identityMappingDAO.GetIdentityMappingsByFingerprint(ctx, fingerprint)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Str("fingerprint", fingerprint).
 			Msg("Failed to get identity mappings")
 		return nil, fmt.Errorf("failed to get identity mappings: %w", err)
 	}
 
 	log.Info().
-		Str("fingerprint", fingerprint).
 		Int("mapping_count", len(mappings)).
 		Msg("Found identity mappings for fingerprint")
 
@@ -197,14 +191,13 @@ func (dao *SecurePseudonymDAO) getPseudonymsByRealIdentityWithKey(ctx context.Co
 	}
 
 	log.Info().
-		Str("fingerprint", fingerprint).
 		Int("pseudonym_count", len(pseudonyms)).
 		Msg("Retrieved pseudonyms for real identity")
 
 	return pseudonyms, nil
 }
 
-func (dao *SecurePseudonymDAO) verifyPseudonymOwnershipWithKey(ctx context.Context, pseudonymID string, userID int64, keyData []byte) (bool, error) {
+func (dao *SecurePseudonymDAO) verifyPseudonymOwnershipWithKey(ctx context.Context, pseudonymID string, userID int64, keyData [][]byte) (bool, error) {
 	// 1. Get user's real identity
 	user, err := dao.userDAO.GetUserByID(ctx, userID)
 	if err != nil {
@@ -225,7 +218,7 @@ func (dao *SecurePseudonymDAO) verifyPseudonymOwnershipWithKey(ctx context.Conte
 	return pseudonymFingerprint == userFingerprint, nil
 }
 
-func (dao *SecurePseudonymDAO) getRealIdentityByPseudonymWithKey(ctx context.Context, pseudonymID string, keyData []byte) (string, error) {
+func (dao *SecurePseudonymDAO) getRealIdentityByPseudonymWithKey(ctx context.Context, pseudonymID string, keyData [][]byte) (string, error) {
 	// 1. Get identity mapping for pseudonym with the correct key scope
 	// For admin correlation, we need to get the correlation mapping
 	// For self-correlation, we need to get the self_correlation mapping
@@ -241,28 +234,19 @@ func (dao *SecurePseudonymDAO) getRealIdentityByPseudonymWithKey(ctx context.Con
 		return "", fmt.Errorf("no identity mappings found for pseudonym")
 	}
 
-	// Try to decrypt each mapping until we find one that works
-	var decryptedMapping string
+	// Try to decrypt each mapping until we find one that works. During an
+	// IBE key rotation a mapping may be under either key version.
 	for _, mapping := range mappings {
-		decrypted, _, err := dao.ibeSystem.DecryptIdentity(mapping.EncryptedRealIdentity, keyData)
-		if err == nil {
-			decryptedMapping = decrypted
-			break
+		for _, key := range keyData {
+			decrypted, err := dao.ibeSystem.DecryptIdentity(mapping.EncryptedRealIdentity, pseudonymID, int(mapping.KeyVersion), key)
+			if err == nil {
+				// Return the fingerprint (not the real identity for privacy)
+				return decrypted.Fingerprint, nil
+			}
 		}
 	}
 
-	if decryptedMapping == "" {
-		return "", fmt.Errorf("failed to decrypt any identity mapping with provided key")
-	}
-
-	// 3. Parse fingerprint from mapping
-	mappingParts := strings.Split(decryptedMapping, ":")
-	if len(mappingParts) != 2 {
-		return "", fmt.Errorf("invalid decrypted mapping format")
-	}
-
-	// Return the fingerprint (not the real identity for privacy)
-	return mappingParts[0], nil
+	return "", fmt.Errorf("failed to decrypt any identity mapping with provided key")
 }
 
 // Helper method to get pseudonym by ID (reused from original DAO)
@@ -329,12 +313,25 @@ func (dao *SecurePseudonymDAO) DeletePseudonym(ctx context.Context, pseudonymID
 		return fmt.Errorf("pseudonym not found")
 	}
 
+	// Look up the owner before the identity mapping goes away with the pseudonym
+	mapping, err := dao.identityMappingDAO.GetIdentityMappingByPseudonymID(ctx, pseudonymID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get identity mapping for deletion: %w", err)
+	}
+
 	// Use the generated Delete method
 	err = pseudonym.Delete(ctx, dao.db)
 	if err != nil {
 		return fmt.Errorf("failed to delete pseudonym: %w", err)
 	}
 
+	// Tokens may name the deleted pseudonym as the active one, so revoke them
+	if mapping != nil {
+		if err := NewTokenRevocationDAO(dao.db).RevokeUserTokens(ctx, mapping.UserID, TokenRevokeReasonPseudonymDelete); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -439,23 +436,28 @@ func (dao *SecurePseudonymDAO) CreatePseudonymWithIdentityMapping(ctx context.Co
 	// 4. Generate fingerprint for the real identity
 	fingerprint := dao.ibeSystem.GenerateFingerprint(user.Email)
 	log.Info().
-		Str("real_identity", user.Email).
-		Str("fingerprint", fingerprint).
+		Int64("user_id", user.UserID).
 		Str("user_role", userRoles[0]).
 		Msg("Generated fingerprint during pseudonym creation")
 
 	// 5. Create identity mappings using IBE
-	// Create two identity mappings: one for self-correlation and one for admin correlation
+	// Create one identity mapping for self-correlation and one for each role
+	// that correlates pseudonyms
 	userRole := userRoles[0] // Use the first role for consistency
 
-	// Get actual role keys from the database
-	selfCorrelationKeyData, err := dao.roleKeyDAO.GetKeyData(ctx, userRole, "self_correlation")
+	// Get actual role keys from the database. Mappings record the key version
+	// of the role key that encrypted them, which during an IBE key rotation
+	// may be newer than the version this node was started with.
+	selfCorrelationKey, err := dao.roleKeyDAO.GetRoleKey(ctx, userRole, "self_correlation")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get self-correlation role key: %w", err)
 	}
 
+	// Mappings are encrypted under the key epoch they are created in
+	epoch := ibe.CurrentEpoch()
+
 	// Create self-correlation mapping (for user self-verification)
-	selfCorrelationFingerprint, err := dao.ibeSystem.EncryptIdentity(user.Email, pseudonym.PseudonymID, selfCorrelationKeyData)
+	selfCorrelationFingerprint, err := dao.ibeSystem.EncryptIdentityWithRoleKey(user.Email, pseudonym.PseudonymID, selfCorrelationKey.RoleName, int(selfCorrelationKey.KeyVersion), epoch, selfCorrelationKey.KeyData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt self-correlation identity mapping: %w", err)
 	}
@@ -466,9 +468,10 @@ func (dao *SecurePseudonymDAO) CreatePseudonymWithIdentityMapping(ctx context.Co
 		PseudonymID:               &pseudonym.PseudonymID,
 		EncryptedRealIdentity:     &selfCorrelationFingerprint,
 		EncryptedPseudonymMapping: &selfCorrelationFingerprint,
-		KeyVersion:                &[]int32{int32(dao.ibeSystem.GetKeyVersion())}[0],
+		KeyVersion:                &selfCorrelationKey.KeyVersion,
 		UserID:                    &userID,
 		KeyScope:                  &[]string{"self_correlation"}[0],
+		RoleName:                  &selfCorrelationKey.RoleName,
 	}
 
 	_, err = models.IdentityMappings.Insert(selfCorrelationMapping).One(ctx, dao.db)
@@ -476,27 +479,18 @@ func (dao *SecurePseudonymDAO) CreatePseudonymWithIdentityMapping(ctx context.Co
 		return nil, fmt.Errorf("failed to create self-correlation identity mapping: %w", err)
 	}
 
-	// Only create correlation mapping for admin roles (they have correlation keys)
-	adminRoles := []string{"platform_admin", "trust_safety", "legal_team"}
-	isAdminRole := false
-	for _, adminRole := range adminRoles {
-		if userRole == adminRole {
-			isAdminRole = true
-			break
-		}
+	// Create a correlation mapping for every role with a correlation key, so
+	// that each role decrypts the mapping written for it with its own key.
+	// Roles whose correlation key is created later cannot correlate the
+	// pseudonym.
+	correlationKeys, err := dao.roleKeyDAO.ListCorrelationKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get correlation role keys: %w", err)
 	}
-
-	if isAdminRole {
-		// Get correlation key for admin role
-		correlationKeyData, err := dao.roleKeyDAO.GetKeyData(ctx, userRole, "correlation")
-		if err != nil {
-			return nil, fmt.Errorf("failed to get correlation role key: %w", err)
-		}
-
-		// Create correlation mapping (for admin correlation)
-		correlationFingerprint, err := dao.ibeSystem.EncryptIdentity(user.Email, pseudonym.PseudonymID, correlationKeyData)
+	for _, correlationKey := range correlationKeys {
+		correlationFingerprint, err := dao.ibeSystem.EncryptIdentityWithRoleKey(user.Email, pseudonym.PseudonymID, correlationKey.RoleName, int(correlationKey.KeyVersion), epoch, correlationKey.KeyData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt correlation identity mapping: %w", err)
+			return nil, fmt.Errorf("failed to encrypt correlation identity mapping for role %s: %w", correlationKey.RoleName, err)
 		}
 
 		// Create correlation identity mapping using Bob ORM
@@ -505,14 +499,15 @@ func (dao *SecurePseudonymDAO) CreatePseudonymWithIdentityMapping(ctx context.Co
 			PseudonymID:               &pseudonym.PseudonymID,
 			EncryptedRealIdentity:     &correlationFingerprint,
 			EncryptedPseudonymMapping: &correlationFingerprint,
-			KeyVersion:                &[]int32{int32(dao.ibeSystem.GetKeyVersion())}[0],
+			KeyVersion:                &correlationKey.KeyVersion,
 			UserID:                    &userID,
-			KeyScope:                  &[]string{"correlation"}[0],
+			KeyScope:                  &correlationKey.Scope,
+			RoleName:                  &correlationKey.RoleName,
 		}
 
 		_, err = models.IdentityMappings.Insert(correlationMapping).One(ctx, dao.db)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create correlation identity mapping: %w", err)
+			return nil, fmt.Errorf("failed to create correlation identity mapping for role %s: %w", correlationKey.RoleName, err)
 		}
 	}
 
@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/scan"
+)
+
+// refilledTokens is the token count of an existing bucket refilled for the
+// time since it was last updated, capped at the bucket capacity. It takes the
+// capacity and the refill rate per second as arguments.
+const refilledTokens = "LEAST(?::DOUBLE PRECISION, rate_limit_buckets.tokens + ?::DOUBLE PRECISION * GREATEST(EXTRACT(EPOCH FROM (NOW() - rate_limit_buckets.updated_at))::DOUBLE PRECISION, 0))"
+
+// RateLimitDAO provides database operations for rate limit token buckets
+type RateLimitDAO struct {
+	db bob.Executor
+}
+
+// NewRateLimitDAO creates a new rate limit DAO
+func NewRateLimitDAO(db bob.Executor) *RateLimitDAO {
+	return &RateLimitDAO{
+		db: db,
+	}
+}
+
+// bucketState is a bucket as returned by TakeToken
+type bucketState struct {
+	Tokens  float64 `db:"tokens"`
+	Allowed bool    `db:"allowed"`
+}
+
+// TakeToken refills a bucket and takes a token from it in one statement, so
+// concurrent requests on any node cannot overdraw it. A new bucket starts
+// with capacity tokens. It returns the tokens left and whether a token was
+// taken.
+func (dao *RateLimitDAO) TakeToken(ctx context.Context, key string, capacity, ratePerSecond float64) (float64, bool, error) {
+	state, err := bob.One(ctx, dao.db, psql.Insert(
+		im.Into("rate_limit_buckets", "bucket_key", "tokens", "allowed", "updated_at"),
+		im.Values(psql.Arg(key), psql.Arg(capacity-1), psql.Arg(capacity >= 1), psql.Raw("NOW()")),
+		im.OnConflict("bucket_key").DoUpdate(
+			im.SetCol("tokens").To(psql.Raw(
+				"CASE WHEN "+refilledTokens+" >= 1 THEN "+refilledTokens+" - 1 ELSE "+refilledTokens+" END",
+				capacity, ratePerSecond, capacity, ratePerSecond, capacity, ratePerSecond,
+			)),
+			im.SetCol("allowed").To(psql.Raw(refilledTokens+" >= 1", capacity, ratePerSecond)),
+			im.SetCol("updated_at").To(psql.Raw("NOW()")),
+		),
+		im.Returning("tokens", "allowed"),
+	), scan.StructMapper[bucketState]())
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return state.Tokens, state.Allowed, nil
+}
+
+// PurgeIdle removes buckets unused for longer than maxAge. Such buckets are
+// full again, so removing them does not change any limit.
+func (dao *RateLimitDAO) PurgeIdle(ctx context.Context, maxAge time.Duration) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("rate_limit_buckets"),
+		dm.Where(psql.Quote("updated_at").LT(psql.Arg(time.Now().UTC().Add(-maxAge)))),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge rate limit buckets: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged rate limit buckets: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Reasons recorded when a refresh token family is revoked
+const (
+	RefreshRevokeReasonLogout    = "logout"
+	RefreshRevokeReasonLogoutAll = "logout_all"
+	RefreshRevokeReasonReuse     = "reuse_detected"
+)
+
+// RefreshTokenRecord is a stored refresh token together with the state of its family
+type RefreshTokenRecord struct {
+	TokenID         int64               `db:"token_id"`
+	FamilyID        string              `db:"family_id"`
+	UserID          int64               `db:"user_id"`
+	ExpiresAt       time.Time           `db:"expires_at"`
+	UsedAt          sql.Null[time.Time] `db:"used_at"`
+	FamilyRevokedAt sql.Null[time.Time] `db:"family_revoked_at"`
+}
+
+// RefreshTokenDAO provides database operations for refresh token families
+type RefreshTokenDAO struct {
+	db bob.Executor
+}
+
+// NewRefreshTokenDAO creates a new refresh token DAO
+func NewRefreshTokenDAO(db bob.Executor) *RefreshTokenDAO {
+	return &RefreshTokenDAO{
+		db: db,
+	}
+}
+
+// StoreToken records the hash of a newly issued refresh token in a family
+func (dao *RefreshTokenDAO) StoreToken(ctx context.Context, familyID string, userID int64, tokenHash string, expiresAt time.Time) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("refresh_tokens", "family_id", "user_id", "token_hash", "expires_at"),
+		im.Values(psql.Arg(familyID), psql.Arg(userID), psql.Arg(tokenHash), psql.Arg(expiresAt)),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetTokenByHash looks up a refresh token by its hash. It returns nil if the token is unknown.
+func (dao *RefreshTokenDAO) GetTokenByHash(ctx context.Context, tokenHash string) (*RefreshTokenRecord, error) {
+	record, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(
+			"t.token_id",
+			"t.family_id",
+			"t.user_id",
+			"t.expires_at",
+			"t.used_at",
+			psql.Quote("f", "revoked_at").As("family_revoked_at"),
+		),
+		sm.From("refresh_tokens").As("t"),
+		sm.InnerJoin("refresh_token_families").As("f").OnEQ(psql.Quote("f", "family_id"), psql.Quote("t", "family_id")),
+		sm.Where(psql.Quote("t", "token_hash").EQ(psql.Arg(tokenHash))),
+	), scan.StructMapper[RefreshTokenRecord]())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &record, nil
+}
+
+// MarkTokenUsed marks a refresh token as rotated. It returns false if the
+// token was already used, which means it is being replayed.
+func (dao *RefreshTokenDAO) MarkTokenUsed(ctx context.Context, tokenID int64) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("refresh_tokens"),
+		um.SetCol("used_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("token_id").EQ(psql.Arg(tokenID))),
+		um.Where(psql.Quote("used_at").IsNull()),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check refresh token update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// RevokeUserFamilies revokes every active refresh token family for a user and
+// returns the number of families revoked
+func (dao *RefreshTokenDAO) RevokeUserFamilies(ctx context.Context, userID int64, reason string) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("refresh_token_families"),
+		um.SetCol("revoked_at").To(psql.Raw("NOW()")),
+		um.SetCol("revoked_reason").ToArg(reason),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Quote("revoked_at").IsNull()),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh token families: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check refresh token family update: %w", err)
+	}
+	return rows, nil
+}
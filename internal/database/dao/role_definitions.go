@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+)
+
+// RoleDefinitionDAO provides data access operations for role definitions
+type RoleDefinitionDAO struct {
+	db bob.Executor
+}
+
+// NewRoleDefinitionDAO creates a new RoleDefinitionDAO
+func NewRoleDefinitionDAO(db bob.Executor) *RoleDefinitionDAO {
+	return &RoleDefinitionDAO{
+		db: db,
+	}
+}
+
+// GetRoleDefinitions retrieves the definitions of the named roles in the
+// order they were defined. Roles without a definition are left out.
+func (dao *RoleDefinitionDAO) GetRoleDefinitions(ctx context.Context, roleNames []string) ([]*models.RoleDefinition, error) {
+	if len(roleNames) == 0 {
+		return nil, nil
+	}
+
+	definitions, err := models.RoleDefinitions.Query(
+		models.SelectWhere.RoleDefinitions.RoleName.In(roleNames...),
+		sm.OrderBy(models.RoleDefinitionColumns.RoleID),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role definitions: %w", err)
+	}
+
+	return definitions, nil
+}
@@ -13,9 +13,17 @@ import (
 	"github.com/matt0x6f/hashpost/internal/ibe"
 	"github.com/rs/zerolog/log"
 	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
 	"github.com/stephenafamo/bob/types"
 )
 
+// KeyScopeCorrelation is the scope of the role keys that correlate
+// pseudonyms. Every pseudonym has an identity mapping encrypted for each role
+// with a correlation key when the pseudonym is created.
+const KeyScopeCorrelation = "correlation"
+
 // RoleKeyDAO handles database operations for role keys
 type RoleKeyDAO struct {
 	db bob.Executor
@@ -28,8 +36,14 @@ func NewRoleKeyDAO(db bob.Executor) *RoleKeyDAO {
 	}
 }
 
-// CreateRoleKey creates a new role key in the database
+// CreateRoleKey creates a new role key in the database at key version 1
 func (dao *RoleKeyDAO) CreateRoleKey(ctx context.Context, roleName, scope string, keyData []byte, capabilities []string, expiresAt time.Time, createdBy int64) (*models.RoleKey, error) {
+	return dao.CreateRoleKeyVersion(ctx, roleName, scope, keyData, 1, capabilities, expiresAt, createdBy)
+}
+
+// CreateRoleKeyVersion creates a new role key derived from the IBE domain
+// masters of the given key version
+func (dao *RoleKeyDAO) CreateRoleKeyVersion(ctx context.Context, roleName, scope string, keyData []byte, keyVersion int32, capabilities []string, expiresAt time.Time, createdBy int64) (*models.RoleKey, error) {
 	// Convert capabilities to JSON
 	capabilitiesJSON, err := json.Marshal(capabilities)
 	if err != nil {
@@ -37,7 +51,6 @@ func (dao *RoleKeyDAO) CreateRoleKey(ctx context.Context, roleName, scope string
 	}
 
 	now := time.Now()
-	keyVersion := int32(1) // Start with version 1
 	isActive := sql.Null[bool]{}
 	isActive.Scan(true)
 
@@ -70,13 +83,15 @@ func (dao *RoleKeyDAO) CreateRoleKey(ctx context.Context, roleName, scope string
 	return roleKey, nil
 }
 
-// GetRoleKey retrieves a role key by role name and scope (global keys)
+// GetRoleKey retrieves a role key by role name and scope (global keys). While
+// an IBE key rotation is under way, the key of the newest version is returned.
 func (dao *RoleKeyDAO) GetRoleKey(ctx context.Context, roleName, scope string) (*models.RoleKey, error) {
 	roleKey, err := models.RoleKeys.Query(
 		models.SelectWhere.RoleKeys.RoleName.EQ(roleName),
 		models.SelectWhere.RoleKeys.Scope.EQ(scope),
 		models.SelectWhere.RoleKeys.IsActive.EQ(true),
 		models.SelectWhere.RoleKeys.ExpiresAt.GT(time.Now()),
+		sm.OrderBy(models.RoleKeyColumns.KeyVersion).Desc(),
 	).One(ctx, dao.db)
 
 	if err != nil {
@@ -133,6 +148,30 @@ func (dao *RoleKeyDAO) ListRoleKeys(ctx context.Context) ([]*models.RoleKey, err
 	return roleKeys, nil
 }
 
+// ListCorrelationKeys retrieves the active correlation key of every role
+// that has one, of the newest key version
+func (dao *RoleKeyDAO) ListCorrelationKeys(ctx context.Context) ([]*models.RoleKey, error) {
+	roleKeys, err := models.RoleKeys.Query(
+		models.SelectWhere.RoleKeys.Scope.EQ(KeyScopeCorrelation),
+		models.SelectWhere.RoleKeys.IsActive.EQ(true),
+		models.SelectWhere.RoleKeys.ExpiresAt.GT(time.Now()),
+		sm.OrderBy(models.RoleKeyColumns.RoleName),
+		sm.OrderBy(models.RoleKeyColumns.KeyVersion).Desc(),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list correlation keys: %w", err)
+	}
+
+	newest := make([]*models.RoleKey, 0, len(roleKeys))
+	for _, roleKey := range roleKeys {
+		if len(newest) > 0 && newest[len(newest)-1].RoleName == roleKey.RoleName {
+			continue
+		}
+		newest = append(newest, roleKey)
+	}
+	return newest, nil
+}
+
 // ListRoleKeysByRole retrieves all active role keys for a specific role
 func (dao *RoleKeyDAO) ListRoleKeysByRole(ctx context.Context, roleName string) ([]*models.RoleKey, error) {
 	roleKeys, err := models.RoleKeys.Query(
@@ -148,6 +187,21 @@ func (dao *RoleKeyDAO) ListRoleKeysByRole(ctx context.Context, roleName string)
 	return roleKeys, nil
 }
 
+// DestroyRoleKey deactivates a role key and destroys its key material. The
+// row is kept because key usage audits refer to it.
+func (dao *RoleKeyDAO) DestroyRoleKey(ctx context.Context, keyID uuid.UUID) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("role_keys"),
+		um.SetCol("is_active").ToArg(false),
+		um.SetCol("key_data").ToArg([]byte{}),
+		um.Where(psql.Quote("key_id").EQ(psql.Arg(keyID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to destroy role key %s: %w", keyID, err)
+	}
+	return nil
+}
+
 // DeactivateRoleKey deactivates a role key
 func (dao *RoleKeyDAO) DeactivateRoleKey(ctx context.Context, keyID string) error {
 	// Convert string to UUID
@@ -213,6 +267,61 @@ func (dao *RoleKeyDAO) GetKeyData(ctx context.Context, roleName, scope string) (
 	return roleKey.KeyData, nil
 }
 
+// GetKeyDataVersions retrieves the key data of every active key for a role
+// and scope, newest key version first. Identity mappings are encrypted under
+// the key of one version, and until an IBE key rotation retires the previous
+// version, mappings under either version must stay readable.
+func (dao *RoleKeyDAO) GetKeyDataVersions(ctx context.Context, roleName, scope string) ([][]byte, error) {
+	roleKeys, err := dao.GetRoleKeyVersions(ctx, roleName, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData := make([][]byte, 0, len(roleKeys))
+	for _, roleKey := range roleKeys {
+		keyData = append(keyData, roleKey.KeyData)
+	}
+	return keyData, nil
+}
+
+// GetRoleKeyVersions retrieves every active key for a role and scope, newest
+// key version first (see GetKeyDataVersions)
+func (dao *RoleKeyDAO) GetRoleKeyVersions(ctx context.Context, roleName, scope string) ([]*models.RoleKey, error) {
+	roleKeys, err := models.RoleKeys.Query(
+		models.SelectWhere.RoleKeys.RoleName.EQ(roleName),
+		models.SelectWhere.RoleKeys.Scope.EQ(scope),
+		models.SelectWhere.RoleKeys.IsActive.EQ(true),
+		models.SelectWhere.RoleKeys.ExpiresAt.GT(time.Now()),
+		sm.OrderBy(models.RoleKeyColumns.KeyVersion).Desc(),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role keys for role=%s scope=%s: %w", roleName, scope, err)
+	}
+	if len(roleKeys) == 0 {
+		return nil, fmt.Errorf("failed to get role keys for role=%s scope=%s: %w", roleName, scope, sql.ErrNoRows)
+	}
+	return roleKeys, nil
+}
+
+// RetireKeyVersion deactivates every role key of a key version and destroys
+// its key material. Rows are kept because key usage audits refer to them.
+func (dao *RoleKeyDAO) RetireKeyVersion(ctx context.Context, keyVersion int32) (int64, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("role_keys"),
+		um.SetCol("is_active").ToArg(false),
+		um.SetCol("key_data").ToArg([]byte{}),
+		um.Where(psql.Quote("key_version").EQ(psql.Arg(keyVersion))),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to retire role keys of version %d: %w", keyVersion, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check retired role keys: %w", err)
+	}
+	return rows, nil
+}
+
 // EnsureDefaultKeys creates default role keys if they don't exist
 func (dao *RoleKeyDAO) EnsureDefaultKeys(ctx context.Context, ibeSystem interface{}, userID int64) error {
 	// Type assert to get the IBE system
@@ -328,10 +437,15 @@ func (dao *RoleKeyDAO) EnsureDefaultKeys(ctx context.Context, ibeSystem interfac
 
 			// Generate key data using the actual role name and scope
 			keyData := ibe.GenerateTestRoleKey(keyDef.roleName, keyDef.scope)
+			if keyData == nil {
+				// The role's domain master is split into shares and locked
+				log.Warn().Str("role", keyDef.roleName).Str("scope", keyDef.scope).Msg("Cannot derive default role key while its IBE domain is locked, skipping")
+				continue
+			}
 			fmt.Printf("[DEBUG] EnsureDefaultKeys: generated IBE key data for role=%s scope=%s, length=%d\n", keyDef.roleName, keyDef.scope, len(keyData))
 			log.Debug().Str("role", keyDef.roleName).Str("scope", keyDef.scope).Int("key_data_length", len(keyData)).Msg("Generated IBE key data")
 
-			createdKey, err := dao.CreateRoleKey(ctx, keyDef.roleName, keyDef.scope, keyData, keyDef.capabilities, expiresAt, userID)
+			createdKey, err := dao.CreateRoleKeyVersion(ctx, keyDef.roleName, keyDef.scope, keyData, int32(ibe.GetKeyVersion()), keyDef.capabilities, expiresAt, userID)
 			if err != nil {
 				fmt.Printf("[DEBUG] EnsureDefaultKeys: FAILED to create role key role=%s scope=%s: %v\n", keyDef.roleName, keyDef.scope, err)
 				log.Error().Str("role", keyDef.roleName).Str("scope", keyDef.scope).Err(err).Msg("Failed to create role key")
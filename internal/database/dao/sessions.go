@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Session is a login session. Each session is a refresh token family, keyed
+// to the real user rather than any pseudonym.
+type Session struct {
+	SessionID       string           `db:"family_id"`
+	UserID          int64            `db:"user_id"`
+	UserAgentFamily sql.Null[string] `db:"user_agent_family"`
+	IPPrefix        sql.Null[string] `db:"ip_prefix"`
+	CreatedAt       time.Time        `db:"created_at"`
+	LastSeenAt      time.Time        `db:"last_seen_at"`
+}
+
+// SessionDAO provides database operations for login sessions
+type SessionDAO struct {
+	db bob.Executor
+}
+
+// NewSessionDAO creates a new session DAO
+func NewSessionDAO(db bob.Executor) *SessionDAO {
+	return &SessionDAO{
+		db: db,
+	}
+}
+
+// CreateSession starts a new session, which is also a new refresh token family.
+// The client is described by the browser family of its user agent and its
+// coarse network prefix, never by the raw values.
+func (dao *SessionDAO) CreateSession(ctx context.Context, sessionID string, userID int64, userAgentFamily, ipPrefix string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("refresh_token_families", "family_id", "user_id", "user_agent_family", "ip_prefix"),
+		im.Values(psql.Arg(sessionID), psql.Arg(userID), psql.Arg(nullIfEmpty(userAgentFamily)), psql.Arg(nullIfEmpty(ipPrefix))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// TouchSession records that a session was just used
+func (dao *SessionDAO) TouchSession(ctx context.Context, sessionID string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("refresh_token_families"),
+		um.SetCol("last_seen_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("family_id").EQ(psql.Arg(sessionID))),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to update session last seen: %w", err)
+	}
+	return nil
+}
+
+// ListActiveSessions returns a user's sessions that are not revoked and still
+// hold an unused, unexpired refresh token, most recently used first
+func (dao *SessionDAO) ListActiveSessions(ctx context.Context, userID int64) ([]Session, error) {
+	sessions, err := bob.All(ctx, dao.db, psql.Select(
+		sm.Columns("f.family_id", "f.user_id", "f.user_agent_family", "f.ip_prefix", "f.created_at", "f.last_seen_at"),
+		sm.From("refresh_token_families").As("f"),
+		sm.Where(psql.Quote("f", "user_id").EQ(psql.Arg(userID))),
+		sm.Where(psql.Quote("f", "revoked_at").IsNull()),
+		sm.Where(psql.Raw("EXISTS (SELECT 1 FROM refresh_tokens t WHERE t.family_id = f.family_id AND t.used_at IS NULL AND t.expires_at > NOW())")),
+		sm.OrderBy(psql.Quote("f", "last_seen_at")).Desc(),
+	), scan.StructMapper[Session]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(s string) sql.Null[string] {
+	return sql.Null[string]{V: s, Valid: s != ""}
+}
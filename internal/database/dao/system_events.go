@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/types"
+)
+
+// Severities of system events
+const (
+	SystemEventSeverityInfo     = "info"
+	SystemEventSeverityWarning  = "warning"
+	SystemEventSeverityError    = "error"
+	SystemEventSeverityCritical = "critical"
+)
+
+// SystemEventDAO provides database operations for the system event log
+type SystemEventDAO struct {
+	db bob.Executor
+}
+
+// NewSystemEventDAO creates a new system event DAO
+func NewSystemEventDAO(db bob.Executor) *SystemEventDAO {
+	return &SystemEventDAO{
+		db: db,
+	}
+}
+
+// RecordEvent appends an event to the system event log. data is stored as
+// JSON and may be nil.
+func (dao *SystemEventDAO) RecordEvent(ctx context.Context, eventType, severity, message, component string, data map[string]interface{}) error {
+	setter := &models.SystemEventSetter{
+		EventType:     &eventType,
+		EventSeverity: &severity,
+		EventMessage:  &message,
+	}
+
+	if data != nil {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event data: %w", err)
+		}
+		eventData := sql.Null[types.JSON[json.RawMessage]]{}
+		if err := eventData.Scan(dataJSON); err != nil {
+			return fmt.Errorf("failed to encode event data: %w", err)
+		}
+		setter.EventData = &eventData
+	}
+
+	if component != "" {
+		sourceComponent := sql.Null[string]{V: component, Valid: true}
+		setter.SourceComponent = &sourceComponent
+	}
+
+	if _, err := models.SystemEvents.Insert(setter).Exec(ctx, dao.db); err != nil {
+		return fmt.Errorf("failed to record system event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+)
+
+// SystemSettingDAO provides data access operations for system settings
+type SystemSettingDAO struct {
+	db bob.Executor
+}
+
+// NewSystemSettingDAO creates a new SystemSettingDAO
+func NewSystemSettingDAO(db bob.Executor) *SystemSettingDAO {
+	return &SystemSettingDAO{
+		db: db,
+	}
+}
+
+// GetSetting retrieves a setting, or nil if it is not set
+func (dao *SystemSettingDAO) GetSetting(ctx context.Context, key string) (*models.SystemSetting, error) {
+	setting, err := models.FindSystemSetting(ctx, dao.db, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get system setting %s: %w", key, err)
+	}
+
+	return setting, nil
+}
+
+// CompareAndSetSetting changes the value of a setting only if it still has
+// the old value, and reports whether it did
+func (dao *SystemSettingDAO) CompareAndSetSetting(ctx context.Context, key, oldValue, newValue string) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("system_settings"),
+		um.SetCol("setting_value").ToArg(newValue),
+		um.SetCol("updated_at").To(psql.Raw("NOW()")),
+		um.Where(psql.Quote("setting_key").EQ(psql.Arg(key))),
+		um.Where(psql.Quote("setting_value").EQ(psql.Arg(oldValue))),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to update system setting %s: %w", key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check updated system setting %s: %w", key, err)
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,378 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/scan"
+)
+
+// Reasons recorded when access tokens are revoked
+const (
+	TokenRevokeReasonLogout          = "logout"
+	TokenRevokeReasonSuspended       = "user_suspended"
+	TokenRevokeReasonRoleRemoved     = "role_removed"
+	TokenRevokeReasonPseudonymDelete = "pseudonym_deleted"
+	TokenRevokeReasonSessionRevoked  = "session_revoked"
+	TokenRevokeReasonAdminRevoked    = "admin_revoked"
+	TokenRevokeReasonPasswordReset   = "password_reset"
+)
+
+// revocationCacheTTL is how long a revocation lookup is trusted before the
+// database is consulted again. Revocations made in this process update the
+// cache immediately; other processes see them within this window.
+const revocationCacheTTL = 30 * time.Second
+
+// TokenRevocationDAO stores revoked access tokens and per-user revocation
+// cutoffs. Lookups go through an in-process cache shared by all instances.
+type TokenRevocationDAO struct {
+	db    bob.Executor
+	cache *revocationCache
+}
+
+// NewTokenRevocationDAO creates a new token revocation DAO
+func NewTokenRevocationDAO(db bob.Executor) *TokenRevocationDAO {
+	return &TokenRevocationDAO{
+		db:    db,
+		cache: sharedRevocationCache,
+	}
+}
+
+// RevokeToken revokes a single access token by its jti until the token expires
+func (dao *TokenRevocationDAO) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time, reason string) error {
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("revoked_tokens", "jti", "user_id", "reason", "expires_at"),
+		im.Values(psql.Arg(jti), psql.Arg(userID), psql.Arg(reason), psql.Arg(expiresAt)),
+		im.OnConflict("jti").DoNothing(),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	dao.cache.setToken(jti, true)
+	return nil
+}
+
+// RevokeUserTokens rejects every token issued to a user up to now, including
+// refresh tokens, so that role and status changes take effect immediately
+func (dao *TokenRevocationDAO) RevokeUserTokens(ctx context.Context, userID int64, reason string) error {
+	revokedBefore := time.Now().UTC()
+
+	_, err := bob.Exec(ctx, dao.db, psql.Insert(
+		im.Into("user_token_revocations", "user_id", "revoked_before", "reason"),
+		im.Values(psql.Arg(userID), psql.Arg(revokedBefore), psql.Arg(reason)),
+		im.OnConflict("user_id").DoUpdate(
+			im.SetExcluded("revoked_before", "reason"),
+		),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	dao.cache.setUserCutoff(userID, revokedBefore)
+
+	if _, err := NewRefreshTokenDAO(dao.db).RevokeUserFamilies(ctx, userID, reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeSession revokes one of a user's sessions: its refresh token family and
+// every access token issued for it. It returns false if the user has no such
+// active session.
+func (dao *TokenRevocationDAO) RevokeSession(ctx context.Context, userID int64, sessionID, reason string) (bool, error) {
+	revoked, err := dao.revokeSessions(ctx, reason,
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Quote("family_id").EQ(psql.Arg(sessionID))),
+	)
+	if err != nil {
+		return false, err
+	}
+	return len(revoked) == 1, nil
+}
+
+// RevokeOtherSessions revokes all of a user's sessions except keepSessionID and
+// returns the number revoked. An empty keepSessionID revokes every session.
+func (dao *TokenRevocationDAO) RevokeOtherSessions(ctx context.Context, userID int64, keepSessionID, reason string) (int, error) {
+	mods := []bob.Mod[*dialect.UpdateQuery]{
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	}
+	if keepSessionID != "" {
+		mods = append(mods, um.Where(psql.Quote("family_id").NE(psql.Arg(keepSessionID))))
+	}
+
+	revoked, err := dao.revokeSessions(ctx, reason, mods...)
+	if err != nil {
+		return 0, err
+	}
+	return len(revoked), nil
+}
+
+// IsTokenRevoked reports whether an access token has been revoked, either
+// individually by jti, through its session, or by a revocation cutoff for its user
+func (dao *TokenRevocationDAO) IsTokenRevoked(ctx context.Context, jti, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		revoked, err := dao.isJTIRevoked(ctx, jti)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	if sessionID != "" {
+		revoked, err := dao.isSessionRevoked(ctx, sessionID)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	cutoff, err := dao.userCutoff(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	// Token timestamps have second precision, so a token issued in the same
+	// second as the cutoff is treated as issued before it
+	return !cutoff.IsZero() && issuedAt.Unix() <= cutoff.Unix(), nil
+}
+
+// PurgeExpired removes revocations that no longer matter: revoked tokens past
+// their expiry and user cutoffs older than the longest token lifetime
+func (dao *TokenRevocationDAO) PurgeExpired(ctx context.Context, maxTokenLifetime time.Duration) (int64, error) {
+	tokens, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("revoked_tokens"),
+		dm.Where(psql.Quote("expires_at").LT(psql.Raw("NOW()"))),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+
+	cutoffs, err := bob.Exec(ctx, dao.db, psql.Delete(
+		dm.From("user_token_revocations"),
+		dm.Where(psql.Quote("revoked_before").LT(psql.Arg(time.Now().UTC().Add(-maxTokenLifetime)))),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge user token revocations: %w", err)
+	}
+
+	tokenRows, err := tokens.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged revoked tokens: %w", err)
+	}
+	cutoffRows, err := cutoffs.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged user token revocations: %w", err)
+	}
+	return tokenRows + cutoffRows, nil
+}
+
+// isJTIRevoked checks the cache, then the database, for a revoked jti
+func (dao *TokenRevocationDAO) isJTIRevoked(ctx context.Context, jti string) (bool, error) {
+	if revoked, ok := dao.cache.token(jti); ok {
+		return revoked, nil
+	}
+
+	count, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From("revoked_tokens"),
+		sm.Where(psql.Quote("jti").EQ(psql.Arg(jti))),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	dao.cache.setToken(jti, count > 0)
+	return count > 0, nil
+}
+
+// revokeSessions revokes the active refresh token families matching the given
+// filters and marks them revoked in the cache
+func (dao *TokenRevocationDAO) revokeSessions(ctx context.Context, reason string, filters ...bob.Mod[*dialect.UpdateQuery]) ([]string, error) {
+	mods := append([]bob.Mod[*dialect.UpdateQuery]{
+		um.Table("refresh_token_families"),
+		um.SetCol("revoked_at").To(psql.Raw("NOW()")),
+		um.SetCol("revoked_reason").ToArg(reason),
+		um.Where(psql.Quote("revoked_at").IsNull()),
+		um.Returning("family_id"),
+	}, filters...)
+
+	revoked, err := bob.All(ctx, dao.db, psql.Update(mods...), scan.SingleColumnMapper[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	for _, sessionID := range revoked {
+		dao.cache.setSession(sessionID, true)
+	}
+	return revoked, nil
+}
+
+// isSessionRevoked checks the cache, then the database, for a revoked session.
+// A session that does not exist is treated as revoked.
+func (dao *TokenRevocationDAO) isSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if revoked, ok := dao.cache.session(sessionID); ok {
+		return revoked, nil
+	}
+
+	active, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("COUNT(*)"),
+		sm.From("refresh_token_families"),
+		sm.Where(psql.Quote("family_id").EQ(psql.Arg(sessionID))),
+		sm.Where(psql.Quote("revoked_at").IsNull()),
+	), scan.SingleColumnMapper[int64])
+	if err != nil {
+		return false, fmt.Errorf("failed to check session revocation: %w", err)
+	}
+
+	dao.cache.setSession(sessionID, active == 0)
+	return active == 0, nil
+}
+
+// userCutoff checks the cache, then the database, for a user's revocation
+// cutoff. It returns the zero time if the user has none.
+func (dao *TokenRevocationDAO) userCutoff(ctx context.Context, userID int64) (time.Time, error) {
+	if cutoff, ok := dao.cache.userCutoff(userID); ok {
+		return cutoff, nil
+	}
+
+	cutoff, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns("revoked_before"),
+		sm.From("user_token_revocations"),
+		sm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	), scan.SingleColumnMapper[time.Time])
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to check user token revocation: %w", err)
+	}
+
+	dao.cache.setUserCutoff(userID, cutoff)
+	return cutoff, nil
+}
+
+// sharedRevocationCache is shared by every TokenRevocationDAO in the process so
+// that a revocation made through one DAO is seen by all of them
+var sharedRevocationCache = newRevocationCache(revocationCacheTTL)
+
+type revocationCacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// revocationCache is a small TTL cache for revocation lookups, including
+// negative results, so that token validation does not hit the database on
+// every request
+type revocationCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	lastSweep time.Time
+	tokens    map[string]revocationCacheEntry[bool]
+	sessions  map[string]revocationCacheEntry[bool]
+	cutoffs   map[int64]revocationCacheEntry[time.Time]
+}
+
+func newRevocationCache(ttl time.Duration) *revocationCache {
+	return &revocationCache{
+		ttl:      ttl,
+		tokens:   make(map[string]revocationCacheEntry[bool]),
+		sessions: make(map[string]revocationCacheEntry[bool]),
+		cutoffs:  make(map[int64]revocationCacheEntry[time.Time]),
+	}
+}
+
+func (c *revocationCache) token(jti string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.tokens[jti]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.tokens, jti)
+		return false, false
+	}
+	return entry.value, true
+}
+
+func (c *revocationCache) setToken(jti string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired()
+	c.tokens[jti] = revocationCacheEntry[bool]{value: revoked, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *revocationCache) session(sessionID string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.sessions[sessionID]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.sessions, sessionID)
+		return false, false
+	}
+	return entry.value, true
+}
+
+func (c *revocationCache) setSession(sessionID string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired()
+	c.sessions[sessionID] = revocationCacheEntry[bool]{value: revoked, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *revocationCache) userCutoff(userID int64) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cutoffs[userID]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.cutoffs, userID)
+		return time.Time{}, false
+	}
+	return entry.value, true
+}
+
+func (c *revocationCache) setUserCutoff(userID int64, cutoff time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired()
+	c.cutoffs[userID] = revocationCacheEntry[time.Time]{value: cutoff, expires: time.Now().Add(c.ttl)}
+}
+
+// evictExpired drops stale entries, at most once per TTL. The caller must hold the lock.
+func (c *revocationCache) evictExpired() {
+	now := time.Now()
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+
+	for jti, entry := range c.tokens {
+		if now.After(entry.expires) {
+			delete(c.tokens, jti)
+		}
+	}
+	for sessionID, entry := range c.sessions {
+		if now.After(entry.expires) {
+			delete(c.sessions, sessionID)
+		}
+	}
+	for userID, entry := range c.cutoffs {
+		if now.After(entry.expires) {
+			delete(c.cutoffs, userID)
+		}
+	}
+}
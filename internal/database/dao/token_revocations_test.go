@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationCache_Expiry(t *testing.T) {
+	cache := newRevocationCache(50 * time.Millisecond)
+
+	cache.setToken("jti-1", true)
+	revoked, ok := cache.token("jti-1")
+	require.True(t, ok)
+	assert.True(t, revoked)
+
+	cutoff := time.Now()
+	cache.setUserCutoff(42, cutoff)
+	cached, ok := cache.userCutoff(42)
+	require.True(t, ok)
+	assert.True(t, cached.Equal(cutoff))
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, ok = cache.token("jti-1")
+	assert.False(t, ok, "expired token entry should be a cache miss")
+	_, ok = cache.userCutoff(42)
+	assert.False(t, ok, "expired cutoff entry should be a cache miss")
+}
+
+func TestTokenRevocationDAO_IsTokenRevokedFromCache(t *testing.T) {
+	// Every lookup here is answered by the cache, so no database is needed
+	dao := &TokenRevocationDAO{cache: newRevocationCache(time.Minute)}
+	ctx := context.Background()
+
+	cutoff := time.Now().Add(-time.Hour)
+	dao.cache.setUserCutoff(42, cutoff)
+	dao.cache.setToken("revoked-jti", true)
+	dao.cache.setToken("old-jti", false)
+	dao.cache.setToken("same-second-jti", false)
+	dao.cache.setToken("new-jti", false)
+
+	revoked, err := dao.IsTokenRevoked(ctx, "revoked-jti", "", 42, time.Now())
+	require.NoError(t, err)
+	assert.True(t, revoked, "individually revoked token should be rejected")
+
+	revoked, err = dao.IsTokenRevoked(ctx, "old-jti", "", 42, cutoff.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, revoked, "token issued before the user cutoff should be rejected")
+
+	revoked, err = dao.IsTokenRevoked(ctx, "same-second-jti", "", 42, cutoff.Truncate(time.Second))
+	require.NoError(t, err)
+	assert.True(t, revoked, "token issued in the cutoff second should be rejected")
+
+	revoked, err = dao.IsTokenRevoked(ctx, "new-jti", "", 42, cutoff.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked, "token issued after the user cutoff should be accepted")
+
+	dao.cache.setSession("active-session", false)
+	dao.cache.setSession("revoked-session", true)
+
+	revoked, err = dao.IsTokenRevoked(ctx, "new-jti", "active-session", 42, cutoff.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked, "token from an active session should be accepted")
+
+	revoked, err = dao.IsTokenRevoked(ctx, "new-jti", "revoked-session", 42, cutoff.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, revoked, "token from a revoked session should be rejected")
+}
@@ -3,12 +3,19 @@ package dao
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/matt0x6f/hashpost/internal/database/models"
 	"github.com/rs/zerolog/log"
 	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+	"github.com/stephenafamo/bob/types"
+	"github.com/stephenafamo/scan"
 )
 
 // UserDAO provides data access operations for users
@@ -25,9 +32,7 @@ func NewUserDAO(db bob.Executor) *UserDAO {
 
 // CreateUser creates a new user
 func (dao *UserDAO) CreateUser(ctx context.Context, email, passwordHash string) (*models.User, error) {
-	log.Debug().
-		Str("email", email).
-		Msg("Creating user")
+	log.Debug().Msg("Creating user")
 
 	// Create a null time for now
 	now := sql.Null[time.Time]{}
@@ -189,7 +194,59 @@ func (dao *UserDAO) SuspendUser(ctx context.Context, userID int64, reason string
 		updates.SuspensionExpiresAt = &suspensionExpiresAt
 	}
 
-	return dao.UpdateUser(ctx, userID, updates)
+	if err := dao.UpdateUser(ctx, userID, updates); err != nil {
+		return err
+	}
+
+	// A suspended user must not keep using tokens issued before the suspension
+	return NewTokenRevocationDAO(dao.db).RevokeUserTokens(ctx, userID, TokenRevokeReasonSuspended)
+}
+
+// RemoveRole removes a role from a user and revokes the user's outstanding
+// tokens so the role's capabilities are not kept until they expire. Capabilities
+// stored on the user are not changed.
+func (dao *UserDAO) RemoveRole(ctx context.Context, userID int64, role string) error {
+	user, err := dao.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user for role removal: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	var roles []string
+	if user.Roles.Valid {
+		rawValue, err := user.Roles.V.Value()
+		if err != nil {
+			return fmt.Errorf("failed to get user roles value: %w", err)
+		}
+		if err := json.Unmarshal(rawValue.([]byte), &roles); err != nil {
+			return fmt.Errorf("failed to parse user roles: %w", err)
+		}
+	}
+
+	remaining := make([]string, 0, len(roles))
+	for _, r := range roles {
+		if r != role {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == len(roles) {
+		return nil
+	}
+
+	rolesJSON, err := json.Marshal(remaining)
+	if err != nil {
+		return fmt.Errorf("failed to marshal roles: %w", err)
+	}
+	rolesNull := sql.Null[types.JSON[json.RawMessage]]{}
+	rolesNull.Scan(rolesJSON)
+
+	if err := dao.UpdateUser(ctx, userID, &models.UserSetter{Roles: &rolesNull}); err != nil {
+		return err
+	}
+
+	return NewTokenRevocationDAO(dao.db).RevokeUserTokens(ctx, userID, TokenRevokeReasonRoleRemoved)
 }
 
 // UnsuspendUser removes suspension from a user
@@ -205,3 +262,58 @@ func (dao *UserDAO) UnsuspendUser(ctx context.Context, userID int64) error {
 
 	return dao.UpdateUser(ctx, userID, updates)
 }
+
+// MarkEmailVerified records that a user proved control of the given address.
+// It returns false if the user's address has changed since the proof was sent.
+func (dao *UserDAO) MarkEmailVerified(ctx context.Context, userID int64, email string) (bool, error) {
+	result, err := bob.Exec(ctx, dao.db, psql.Update(
+		um.Table("users"),
+		um.SetCol("email_verified_at").To(psql.Raw("COALESCE(email_verified_at, NOW())")),
+		um.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+		um.Where(psql.Quote("email").EQ(psql.Arg(email))),
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check email verification update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// IsEmailVerified reports whether a user has verified their email address
+func (dao *UserDAO) IsEmailVerified(ctx context.Context, userID int64) (bool, error) {
+	verified, err := bob.One(ctx, dao.db, psql.Select(
+		sm.Columns(psql.Quote("email_verified_at").IsNotNull()),
+		sm.From("users"),
+		sm.Where(psql.Quote("user_id").EQ(psql.Arg(userID))),
+	), scan.SingleColumnMapper[bool])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check email verification: %w", err)
+	}
+	return verified, nil
+}
+
+// ListActiveUsersByRole retrieves the active, unsuspended users holding a role
+func (dao *UserDAO) ListActiveUsersByRole(ctx context.Context, role string) ([]*models.User, error) {
+	roleJSON, err := json.Marshal([]string{role})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode role: %w", err)
+	}
+
+	users, err := models.Users.Query(
+		sm.Where(psql.Raw("roles @> ?::jsonb", string(roleJSON))),
+		sm.Where(psql.Raw("COALESCE(is_active, TRUE)")),
+		sm.Where(psql.Raw("NOT COALESCE(is_suspended, FALSE)")),
+		sm.OrderBy(psql.Quote("user_id")),
+	).All(ctx, dao.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users with role %s: %w", role, err)
+	}
+	return users, nil
+}
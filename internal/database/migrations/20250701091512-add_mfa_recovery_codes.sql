@@ -0,0 +1,22 @@
+-- +migrate Up
+
+-- Single-use recovery codes for TOTP multi-factor authentication.
+-- Only a SHA-256 hash of each code is stored; the plaintext is shown once at enrollment.
+CREATE TABLE mfa_recovery_codes (
+    code_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+CREATE UNIQUE INDEX idx_mfa_recovery_codes_user_hash ON mfa_recovery_codes(user_id, code_hash);
+
+-- Track the last accepted TOTP time step so a code cannot be replayed within its validity window
+ALTER TABLE users ADD COLUMN mfa_last_used_step BIGINT;
+
+-- +migrate Down
+
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_last_used_step;
+DROP TABLE IF EXISTS mfa_recovery_codes;
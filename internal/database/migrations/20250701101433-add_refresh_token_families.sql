@@ -0,0 +1,34 @@
+-- +migrate Up
+
+-- A refresh token family starts at login or registration and is continued by
+-- every rotation. Revoking a family invalidates all of its refresh tokens.
+CREATE TABLE refresh_token_families (
+    family_id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    revoked_reason VARCHAR(50)
+);
+
+CREATE INDEX idx_refresh_token_families_user ON refresh_token_families(user_id);
+
+-- Individual refresh tokens. Only a SHA-256 hash of each token is stored.
+-- A token is single-use: used_at is set when it is rotated, and presenting it
+-- again revokes the whole family.
+CREATE TABLE refresh_tokens (
+    token_id BIGSERIAL PRIMARY KEY,
+    family_id VARCHAR(64) NOT NULL REFERENCES refresh_token_families(family_id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS refresh_token_families;
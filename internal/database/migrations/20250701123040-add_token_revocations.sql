@@ -0,0 +1,27 @@
+-- +migrate Up
+
+-- Individually revoked access tokens, keyed by their jti claim. Rows can be
+-- purged once expires_at has passed because the token is then invalid anyway.
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT REFERENCES users(user_id) ON DELETE CASCADE,
+    reason VARCHAR(50),
+    revoked_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+-- Per-user revocation cutoff: every token issued to the user at or before
+-- revoked_before is rejected. Used when a user is suspended, loses a role or
+-- deletes a pseudonym.
+CREATE TABLE user_token_revocations (
+    user_id BIGINT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP NOT NULL,
+    reason VARCHAR(50)
+);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS user_token_revocations;
+DROP TABLE IF EXISTS revoked_tokens;
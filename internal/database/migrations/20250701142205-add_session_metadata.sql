@@ -0,0 +1,14 @@
+-- +migrate Up
+
+-- Each refresh token family is a login session. These columns describe the
+-- device for the session management API. They are keyed to the real user, never
+-- a pseudonym, and the IP address is only stored as a coarse network prefix.
+ALTER TABLE refresh_token_families ADD COLUMN user_agent VARCHAR(255);
+ALTER TABLE refresh_token_families ADD COLUMN ip_prefix VARCHAR(64);
+ALTER TABLE refresh_token_families ADD COLUMN last_seen_at TIMESTAMP DEFAULT NOW();
+
+-- +migrate Down
+
+ALTER TABLE refresh_token_families DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE refresh_token_families DROP COLUMN IF EXISTS ip_prefix;
+ALTER TABLE refresh_token_families DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,27 @@
+-- +migrate Up
+
+-- When the user proved control of their email address. Accounts that existed
+-- before verification was introduced are treated as verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+
+-- Single-use tokens mailed to users for email verification and password
+-- reset. Only a SHA-256 hash of each token is stored. A token is bound to the
+-- address it was sent to, so changing the address invalidates it.
+CREATE TABLE email_tokens (
+    token_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_email_tokens_user_purpose ON email_tokens(user_id, purpose);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS email_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
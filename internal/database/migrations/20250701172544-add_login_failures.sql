@@ -0,0 +1,21 @@
+-- +migrate Up
+
+-- Failed login counters for brute-force protection. Counters are kept per
+-- account and per client address; subjects are SHA-256 hashes so that neither
+-- attempted email addresses nor client IPs are stored.
+CREATE TABLE login_failures (
+    scope VARCHAR(16) NOT NULL CHECK (scope IN ('account', 'ip')),
+    subject VARCHAR(64) NOT NULL,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    first_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    blocked_until TIMESTAMP,
+    PRIMARY KEY (scope, subject)
+);
+
+-- Lets the cleanup of stale counters find them without a full scan
+CREATE INDEX idx_login_failures_last_failed_at ON login_failures(last_failed_at);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS login_failures;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- Token buckets for request rate limiting when the postgres backend is used.
+-- Bucket keys are HMACs of the limit class and the client identity, so that
+-- neither user IDs nor client addresses are stored, and buckets of different
+-- pseudonyms of one user cannot be linked.
+CREATE TABLE rate_limit_buckets (
+    bucket_key VARCHAR(64) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Lets the cleanup of idle buckets find them without a full scan
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS rate_limit_buckets;
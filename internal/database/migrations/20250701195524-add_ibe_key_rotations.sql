@@ -0,0 +1,24 @@
+-- +migrate Up
+
+-- IBE master-key rotations. A rotation generates new domain masters under a
+-- new key version, re-encrypts every identity mapping under role keys derived
+-- from them, and retires the previous version once its grace period is over
+-- and no mapping references it.
+CREATE TABLE ibe_key_rotations (
+    key_version INTEGER PRIMARY KEY,
+    previous_version INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'reencrypting' CHECK (status IN ('reencrypting', 'grace', 'retired')),
+    mappings_reencrypted BIGINT NOT NULL DEFAULT 0,
+    mappings_failed BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reencrypted_at TIMESTAMP,
+    retired_at TIMESTAMP
+);
+
+-- Lets re-encryption find the mappings still under an older key version
+CREATE INDEX idx_identity_mappings_key_version ON identity_mappings(key_version);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_identity_mappings_key_version;
+DROP TABLE IF EXISTS ibe_key_rotations;
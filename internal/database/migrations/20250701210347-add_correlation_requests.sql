@@ -0,0 +1,45 @@
+-- +migrate Up
+
+-- Identity correlation requests. A request records why an identity is to be
+-- de-anonymized and waits for a second, different admin to approve or reject
+-- it. Only an approved request can be executed, once, before it expires.
+-- expires_at is the review deadline while pending and the execution deadline
+-- once approved.
+CREATE TABLE correlation_requests (
+    request_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    correlation_type VARCHAR(20) NOT NULL DEFAULT 'identity',
+    requester_id BIGINT NOT NULL REFERENCES users(user_id),
+    requester_username VARCHAR(100) NOT NULL,
+    requester_role VARCHAR(50) NOT NULL,
+    requested_pseudonym VARCHAR(64) NOT NULL,
+    requested_fingerprint VARCHAR(32),
+    scope VARCHAR(50) NOT NULL,
+    justification TEXT NOT NULL,
+    legal_basis VARCHAR(100) NOT NULL,
+    incident_id VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'executed', 'expired')),
+    reviewer_id BIGINT REFERENCES users(user_id),
+    reviewer_role VARCHAR(50),
+    review_comment TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    executed_at TIMESTAMP,
+
+    CONSTRAINT correlation_requests_dual_control CHECK (reviewer_id IS NULL OR reviewer_id <> requester_id)
+);
+
+CREATE INDEX idx_correlation_requests_status ON correlation_requests(status, expires_at);
+
+-- Each transition of a request is written to the correlation audit trail
+ALTER TABLE correlation_audit ADD COLUMN request_id UUID REFERENCES correlation_requests(request_id);
+ALTER TABLE correlation_audit ADD COLUMN request_status VARCHAR(20);
+
+CREATE INDEX idx_audit_request ON correlation_audit(request_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_audit_request;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS request_status;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS request_id;
+DROP TABLE IF EXISTS correlation_requests;
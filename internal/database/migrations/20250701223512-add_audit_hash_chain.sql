@@ -0,0 +1,93 @@
+-- +migrate Up
+
+-- Heads of the hash-chained audit logs. Each audit entry stores its position
+-- in the chain, the hash of the entry before it and its own hash. An entry is
+-- only inserted together with the update that moves the head to it, so
+-- concurrent writers cannot fork a chain. unchained_rows counts the entries
+-- written before the chain existed; they are not covered by it.
+CREATE TABLE audit_chains (
+    chain VARCHAR(50) PRIMARY KEY,
+    seq BIGINT NOT NULL DEFAULT 0,
+    head_hash BYTEA NOT NULL,
+    unchained_rows BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO audit_chains (chain, head_hash, unchained_rows)
+SELECT 'correlation_audit', decode(repeat('00', 32), 'hex'), COUNT(*) FROM correlation_audit;
+
+INSERT INTO audit_chains (chain, head_hash, unchained_rows)
+SELECT 'key_usage_audit', decode(repeat('00', 32), 'hex'), COUNT(*) FROM key_usage_audit;
+
+ALTER TABLE correlation_audit ADD COLUMN chain_seq BIGINT UNIQUE;
+ALTER TABLE correlation_audit ADD COLUMN prev_hash BYTEA;
+ALTER TABLE correlation_audit ADD COLUMN entry_hash BYTEA;
+
+ALTER TABLE key_usage_audit ADD COLUMN chain_seq BIGINT UNIQUE;
+ALTER TABLE key_usage_audit ADD COLUMN prev_hash BYTEA;
+ALTER TABLE key_usage_audit ADD COLUMN entry_hash BYTEA;
+
+-- Signed checkpoints of the chain heads
+CREATE TABLE audit_checkpoints (
+    checkpoint_id BIGSERIAL PRIMARY KEY,
+    chain VARCHAR(50) NOT NULL REFERENCES audit_chains(chain),
+    seq BIGINT NOT NULL,
+    head_hash BYTEA NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    UNIQUE (chain, seq)
+);
+
+-- Audit logs and checkpoints are append-only
+-- +migrate StatementBegin
+CREATE OR REPLACE FUNCTION reject_audit_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER correlation_audit_append_only
+    BEFORE UPDATE OR DELETE ON correlation_audit
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_change();
+CREATE TRIGGER correlation_audit_no_truncate
+    BEFORE TRUNCATE ON correlation_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_change();
+
+CREATE TRIGGER key_usage_audit_append_only
+    BEFORE UPDATE OR DELETE ON key_usage_audit
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_change();
+CREATE TRIGGER key_usage_audit_no_truncate
+    BEFORE TRUNCATE ON key_usage_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_change();
+
+CREATE TRIGGER audit_checkpoints_append_only
+    BEFORE UPDATE OR DELETE ON audit_checkpoints
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_change();
+CREATE TRIGGER audit_checkpoints_no_truncate
+    BEFORE TRUNCATE ON audit_checkpoints
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_change();
+
+-- +migrate Down
+
+DROP TRIGGER IF EXISTS audit_checkpoints_no_truncate ON audit_checkpoints;
+DROP TRIGGER IF EXISTS audit_checkpoints_append_only ON audit_checkpoints;
+DROP TRIGGER IF EXISTS key_usage_audit_no_truncate ON key_usage_audit;
+DROP TRIGGER IF EXISTS key_usage_audit_append_only ON key_usage_audit;
+DROP TRIGGER IF EXISTS correlation_audit_no_truncate ON correlation_audit;
+DROP TRIGGER IF EXISTS correlation_audit_append_only ON correlation_audit;
+DROP FUNCTION IF EXISTS reject_audit_change();
+
+DROP TABLE IF EXISTS audit_checkpoints;
+
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS entry_hash;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS chain_seq;
+
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS entry_hash;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS chain_seq;
+
+DROP TABLE IF EXISTS audit_chains;
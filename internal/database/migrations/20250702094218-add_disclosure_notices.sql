@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- A compliance report under a gag order forbids telling the affected users
+-- about the correlations linked to it
+ALTER TABLE compliance_reports ADD COLUMN gag_order BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Notices telling a pseudonym that it was correlated. A notice is created for
+-- every completed correlation in correlation_audit and shown to the owner of
+-- the correlated pseudonym once embargo_until has passed, unless a compliance
+-- report linked to the correlation is under a gag order. delivered_at is set
+-- when the owner is first shown the notice; its embargo cannot change after.
+CREATE TABLE disclosure_notices (
+    notice_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    audit_id UUID NOT NULL UNIQUE REFERENCES correlation_audit(audit_id),
+    pseudonym_id VARCHAR(64) NOT NULL,
+    embargo_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_disclosure_notices_pseudonym ON disclosure_notices(pseudonym_id);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS disclosure_notices;
+ALTER TABLE compliance_reports DROP COLUMN IF EXISTS gag_order;
@@ -0,0 +1,51 @@
+-- +migrate Up
+
+-- Daily keys client addresses are hashed with. A key is created on the first
+-- request of each UTC day and deleted once the day leaves the matching window,
+-- after which the hashes made with it can no longer be linked to an address.
+CREATE TABLE ip_hash_keys (
+    day DATE PRIMARY KEY,
+    secret BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Audit entries keep a keyed hash of the client address, its coarse prefix
+-- and the browser family instead of the raw address and user agent
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE correlation_audit ADD COLUMN ip_hash VARCHAR(64);
+ALTER TABLE correlation_audit ADD COLUMN ip_prefix VARCHAR(64);
+ALTER TABLE correlation_audit ADD COLUMN user_agent_family VARCHAR(64);
+CREATE INDEX idx_correlation_audit_ip_hash ON correlation_audit(ip_hash);
+
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE key_usage_audit ADD COLUMN ip_hash VARCHAR(64);
+ALTER TABLE key_usage_audit ADD COLUMN ip_prefix VARCHAR(64);
+ALTER TABLE key_usage_audit ADD COLUMN user_agent_family VARCHAR(64);
+CREATE INDEX idx_key_usage_audit_ip_hash ON key_usage_audit(ip_hash);
+
+-- Sessions keep the browser family instead of the raw user agent
+ALTER TABLE refresh_token_families DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE refresh_token_families ADD COLUMN user_agent_family VARCHAR(64);
+
+-- +migrate Down
+
+ALTER TABLE refresh_token_families DROP COLUMN IF EXISTS user_agent_family;
+ALTER TABLE refresh_token_families ADD COLUMN user_agent VARCHAR(255);
+
+DROP INDEX IF EXISTS idx_key_usage_audit_ip_hash;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS user_agent_family;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS ip_prefix;
+ALTER TABLE key_usage_audit DROP COLUMN IF EXISTS ip_hash;
+ALTER TABLE key_usage_audit ADD COLUMN user_agent TEXT;
+ALTER TABLE key_usage_audit ADD COLUMN ip_address INET;
+
+DROP INDEX IF EXISTS idx_correlation_audit_ip_hash;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS user_agent_family;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS ip_prefix;
+ALTER TABLE correlation_audit DROP COLUMN IF EXISTS ip_hash;
+ALTER TABLE correlation_audit ADD COLUMN user_agent TEXT;
+ALTER TABLE correlation_audit ADD COLUMN ip_address INET;
+
+DROP TABLE IF EXISTS ip_hash_keys;
@@ -0,0 +1,55 @@
+-- +migrate Up
+
+-- Compliance reports are the cases of the legal team: a court order,
+-- subpoena or similar request, assigned to a staff member and moved from
+-- pending through in_progress to completed or rejected.
+UPDATE compliance_reports SET status = 'pending' WHERE status IS NULL;
+UPDATE compliance_reports SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE compliance_reports ALTER COLUMN status SET NOT NULL;
+ALTER TABLE compliance_reports ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE compliance_reports ADD CONSTRAINT compliance_reports_status_check
+    CHECK (status IN ('pending', 'in_progress', 'completed', 'rejected'));
+ALTER TABLE compliance_reports ADD CONSTRAINT compliance_reports_type_check
+    CHECK (report_type IN ('court_order', 'subpoena', 'law_enforcement', 'internal_audit'));
+
+ALTER TABLE compliance_reports ADD COLUMN created_by BIGINT REFERENCES users(user_id);
+ALTER TABLE compliance_reports ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+-- Set when the alert that an open case is past its due date is raised, so
+-- that it is raised once
+ALTER TABLE compliance_reports ADD COLUMN overdue_alerted_at TIMESTAMP WITH TIME ZONE;
+
+-- A correlation is linked to a case at most once
+CREATE UNIQUE INDEX idx_compliance_corr_unique ON compliance_correlations(report_id, audit_id);
+
+-- History of a case: opening, assignments, status changes, linked
+-- correlations and overdue alerts. actor_id is NULL for events raised by the
+-- server.
+CREATE TABLE compliance_report_events (
+    event_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES compliance_reports(report_id),
+    actor_id BIGINT REFERENCES users(user_id),
+    event_type VARCHAR(30) NOT NULL, -- 'opened', 'assigned', 'status_changed', 'correlation_attached', 'overdue'
+    status VARCHAR(20),
+    assigned_user_id BIGINT REFERENCES users(user_id),
+    audit_id UUID REFERENCES correlation_audit(audit_id),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_compliance_events_report ON compliance_report_events(report_id, created_at);
+
+-- An identity correlation request made for a case is linked to it once executed
+ALTER TABLE correlation_requests ADD COLUMN compliance_report_id UUID REFERENCES compliance_reports(report_id);
+
+-- +migrate Down
+
+ALTER TABLE correlation_requests DROP COLUMN IF EXISTS compliance_report_id;
+DROP TABLE IF EXISTS compliance_report_events;
+DROP INDEX IF EXISTS idx_compliance_corr_unique;
+ALTER TABLE compliance_reports DROP COLUMN IF EXISTS overdue_alerted_at;
+ALTER TABLE compliance_reports DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE compliance_reports DROP COLUMN IF EXISTS created_by;
+ALTER TABLE compliance_reports DROP CONSTRAINT IF EXISTS compliance_reports_type_check;
+ALTER TABLE compliance_reports DROP CONSTRAINT IF EXISTS compliance_reports_status_check;
+ALTER TABLE compliance_reports ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE compliance_reports ALTER COLUMN status DROP NOT NULL;
@@ -0,0 +1,28 @@
+-- +migrate Up
+
+-- Limits on the correlations a person may perform. Role limits apply to
+-- each holder of the role; a person's own limits replace those of their
+-- role. daily counts the last 24 hours, monthly the last 30 days; a missing
+-- or zero limit is unlimited.
+INSERT INTO system_settings (setting_key, setting_value, setting_type, description) VALUES
+('correlation_quotas', '{
+  "roles": {
+    "moderator": {"daily": 20, "monthly": 200},
+    "subforum_owner": {"daily": 20, "monthly": 200},
+    "trust_safety": {"daily": 50, "monthly": 500},
+    "legal_team": {"daily": 50, "monthly": 500},
+    "platform_admin": {"daily": 50, "monthly": 500}
+  },
+  "users": {}
+}', 'json', 'Daily and monthly correlation limits per role and per user ID'),
+-- Anomaly detection starts with the correlations after this migration
+('correlation_anomaly_cursor', (SELECT COALESCE(MAX(chain_seq), 0) FROM correlation_audit)::text, 'integer', 'Last correlation audit chain position checked for anomalies')
+ON CONFLICT (setting_key) DO NOTHING;
+
+-- Correlations of a person over time, for quotas and anomaly detection
+CREATE INDEX idx_audit_user_timestamp ON correlation_audit(user_id, timestamp);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_audit_user_timestamp;
+DELETE FROM system_settings WHERE setting_key IN ('correlation_quotas', 'correlation_anomaly_cursor');
@@ -0,0 +1,37 @@
+-- +migrate Up
+
+-- Emergency identity correlations made without prior approval. Each use
+-- records its reason code and the short-lived role key it was granted, and
+-- waits for a different admin to sign it off or reject it before
+-- review_due_at. A pending review past its deadline becomes overdue; overdue
+-- and rejected uses flag the account of the requester (flagged_at), which
+-- cannot break the glass again while flagged. An overdue use can still be
+-- signed off, which lifts its flag.
+CREATE TABLE break_glass_reviews (
+    review_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    requester_id BIGINT NOT NULL REFERENCES users(user_id),
+    requester_role VARCHAR(50) NOT NULL,
+    requested_pseudonym VARCHAR(64) NOT NULL,
+    reason_code VARCHAR(50) NOT NULL CHECK (reason_code IN ('threat_of_violence', 'self_harm', 'child_safety', 'terrorism', 'law_enforcement_emergency')),
+    justification TEXT NOT NULL,
+    incident_id VARCHAR(100) NOT NULL,
+    key_id UUID REFERENCES role_keys(key_id),
+    audit_id UUID REFERENCES correlation_audit(audit_id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'signed_off', 'rejected', 'overdue')),
+    review_due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reviewer_id BIGINT REFERENCES users(user_id),
+    reviewer_role VARCHAR(50),
+    review_comment TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    flagged_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT break_glass_reviews_dual_control CHECK (reviewer_id IS NULL OR reviewer_id <> requester_id)
+);
+
+CREATE INDEX idx_break_glass_reviews_status ON break_glass_reviews(status, review_due_at);
+CREATE INDEX idx_break_glass_reviews_requester ON break_glass_reviews(requester_id, status);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS break_glass_reviews;
@@ -0,0 +1,32 @@
+-- +migrate Up
+
+-- Identity mappings record the role whose role key encrypted them. A
+-- pseudonym has one correlation mapping for each role that correlates, so a
+-- role decrypts the mapping written for it instead of guessing.
+ALTER TABLE identity_mappings ADD COLUMN role_name VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Existing mappings were encrypted under the key of their user's first role
+UPDATE identity_mappings m
+SET role_name = COALESCE(u.roles->>0, 'user')
+FROM users u
+WHERE u.user_id = m.user_id;
+
+ALTER TABLE identity_mappings DROP CONSTRAINT IF EXISTS unique_fingerprint_pseudonym_scope;
+ALTER TABLE identity_mappings DROP CONSTRAINT IF EXISTS unique_fingerprint_pseudonym_key_scope;
+ALTER TABLE identity_mappings ADD CONSTRAINT unique_fingerprint_pseudonym_scope_role
+    UNIQUE (fingerprint, pseudonym_id, key_scope, role_name);
+
+CREATE INDEX idx_mappings_pseudonym_scope_role ON identity_mappings(pseudonym_id, key_scope, role_name);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_mappings_pseudonym_scope_role;
+ALTER TABLE identity_mappings DROP CONSTRAINT IF EXISTS unique_fingerprint_pseudonym_scope_role;
+DELETE FROM identity_mappings m
+USING users u
+WHERE u.user_id = m.user_id
+  AND m.key_scope IN ('correlation', 'subforum_correlation')
+  AND m.role_name <> COALESCE(u.roles->>0, 'user');
+ALTER TABLE identity_mappings ADD CONSTRAINT unique_fingerprint_pseudonym_scope
+    UNIQUE (fingerprint, pseudonym_id, key_scope);
+ALTER TABLE identity_mappings DROP COLUMN role_name;
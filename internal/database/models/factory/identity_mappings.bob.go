@@ -47,6 +47,7 @@ type IdentityMappingTemplate struct {
 	IsActive                  func() sql.Null[bool]
 	UserID                    func() int64
 	KeyScope                  func() string
+	RoleName                  func() string
 
 	r identityMappingR
 	f *Factory
@@ -127,6 +128,10 @@ func (o IdentityMappingTemplate) BuildSetter() *models.IdentityMappingSetter {
 		val := o.KeyScope()
 		m.KeyScope = &val
 	}
+	if o.RoleName != nil {
+		val := o.RoleName()
+		m.RoleName = &val
+	}
 
 	return m
 }
@@ -182,6 +187,9 @@ func (o IdentityMappingTemplate) Build() *models.IdentityMapping {
 	if o.KeyScope != nil {
 		m.KeyScope = o.KeyScope()
 	}
+	if o.RoleName != nil {
+		m.RoleName = o.RoleName()
+	}
 
 	o.setModelRels(m)
 
@@ -364,6 +372,7 @@ func (m identityMappingMods) RandomizeAllColumns(f *faker.Faker) IdentityMapping
 		IdentityMappingMods.RandomIsActive(f),
 		IdentityMappingMods.RandomUserID(f),
 		IdentityMappingMods.RandomKeyScope(f),
+		IdentityMappingMods.RandomRoleName(f),
 	}
 }
 
@@ -774,6 +783,37 @@ func (m identityMappingMods) RandomKeyScope(f *faker.Faker) IdentityMappingMod {
 	})
 }
 
+// Set the model columns to this value
+func (m identityMappingMods) RoleName(val string) IdentityMappingMod {
+	return IdentityMappingModFunc(func(_ context.Context, o *IdentityMappingTemplate) {
+		o.RoleName = func() string { return val }
+	})
+}
+
+// Set the Column from the function
+func (m identityMappingMods) RoleNameFunc(f func() string) IdentityMappingMod {
+	return IdentityMappingModFunc(func(_ context.Context, o *IdentityMappingTemplate) {
+		o.RoleName = f
+	})
+}
+
+// Clear any values for the column
+func (m identityMappingMods) UnsetRoleName() IdentityMappingMod {
+	return IdentityMappingModFunc(func(_ context.Context, o *IdentityMappingTemplate) {
+		o.RoleName = nil
+	})
+}
+
+// Generates a random value for the column using the given faker
+// if faker is nil, a default faker is used
+func (m identityMappingMods) RandomRoleName(f *faker.Faker) IdentityMappingMod {
+	return IdentityMappingModFunc(func(_ context.Context, o *IdentityMappingTemplate) {
+		o.RoleName = func() string {
+			return random_string(f, "100")
+		}
+	})
+}
+
 func (m identityMappingMods) WithParentsCascading() IdentityMappingMod {
 	return IdentityMappingModFunc(func(ctx context.Context, o *IdentityMappingTemplate) {
 		if isDone, _ := identityMappingWithParentsCascadingCtx.Value(ctx); isDone {
@@ -36,6 +36,7 @@ type IdentityMapping struct {
 	IsActive                  sql.Null[bool]      `db:"is_active" scan:"is_active" json:"is_active"`
 	UserID                    int64               `db:"user_id" scan:"user_id" json:"user_id"`
 	KeyScope                  string              `db:"key_scope" scan:"key_scope" json:"key_scope"`
+	RoleName                  string              `db:"role_name" scan:"role_name" json:"role_name"`
 
 	R identityMappingR `db:"-" scan:"rel" json:"rel"`
 }
@@ -67,6 +68,7 @@ type identityMappingColumnNames struct {
 	IsActive                  string
 	UserID                    string
 	KeyScope                  string
+	RoleName                  string
 }
 
 var IdentityMappingColumns = buildIdentityMappingColumns("identity_mappings")
@@ -84,6 +86,7 @@ type identityMappingColumns struct {
 	IsActive                  psql.Expression
 	UserID                    psql.Expression
 	KeyScope                  psql.Expression
+	RoleName                  psql.Expression
 }
 
 func (c identityMappingColumns) Alias() string {
@@ -108,6 +111,7 @@ func buildIdentityMappingColumns(alias string) identityMappingColumns {
 		IsActive:                  psql.Quote(alias, "is_active"),
 		UserID:                    psql.Quote(alias, "user_id"),
 		KeyScope:                  psql.Quote(alias, "key_scope"),
+		RoleName:                  psql.Quote(alias, "role_name"),
 	}
 }
 
@@ -123,6 +127,7 @@ type identityMappingWhere[Q psql.Filterable] struct {
 	IsActive                  psql.WhereNullMod[Q, bool]
 	UserID                    psql.WhereMod[Q, int64]
 	KeyScope                  psql.WhereMod[Q, string]
+	RoleName                  psql.WhereMod[Q, string]
 }
 
 func (identityMappingWhere[Q]) AliasedAs(alias string) identityMappingWhere[Q] {
@@ -142,6 +147,7 @@ func buildIdentityMappingWhere[Q psql.Filterable](cols identityMappingColumns) i
 		IsActive:                  psql.WhereNull[Q, bool](cols.IsActive),
 		UserID:                    psql.Where[Q, int64](cols.UserID),
 		KeyScope:                  psql.Where[Q, string](cols.KeyScope),
+		RoleName:                  psql.Where[Q, string](cols.RoleName),
 	}
 }
 
@@ -153,18 +159,18 @@ var IdentityMappingErrors = &identityMappingErrors{
 		s:       "identity_mappings_pkey",
 	},
 
-	ErrUniqueUniqueFingerprintPseudonymScope: &UniqueConstraintError{
+	ErrUniqueUniqueFingerprintPseudonymScopeRole: &UniqueConstraintError{
 		schema:  "",
 		table:   "identity_mappings",
-		columns: []string{"fingerprint", "pseudonym_id", "key_scope"},
-		s:       "unique_fingerprint_pseudonym_scope",
+		columns: []string{"fingerprint", "pseudonym_id", "key_scope", "role_name"},
+		s:       "unique_fingerprint_pseudonym_scope_role",
 	},
 }
 
 type identityMappingErrors struct {
 	ErrUniqueIdentityMappingsPkey *UniqueConstraintError
 
-	ErrUniqueUniqueFingerprintPseudonymScope *UniqueConstraintError
+	ErrUniqueUniqueFingerprintPseudonymScopeRole *UniqueConstraintError
 }
 
 // IdentityMappingSetter is used for insert/upsert/update operations
@@ -182,10 +188,11 @@ type IdentityMappingSetter struct {
 	IsActive                  *sql.Null[bool]      `db:"is_active" scan:"is_active" json:"is_active"`
 	UserID                    *int64               `db:"user_id" scan:"user_id" json:"user_id"`
 	KeyScope                  *string              `db:"key_scope" scan:"key_scope" json:"key_scope"`
+	RoleName                  *string              `db:"role_name" scan:"role_name" json:"role_name"`
 }
 
 func (s IdentityMappingSetter) SetColumns() []string {
-	vals := make([]string, 0, 11)
+	vals := make([]string, 0, 12)
 	if s.MappingID != nil {
 		vals = append(vals, "mapping_id")
 	}
@@ -230,6 +237,10 @@ func (s IdentityMappingSetter) SetColumns() []string {
 		vals = append(vals, "key_scope")
 	}
 
+	if s.RoleName != nil {
+		vals = append(vals, "role_name")
+	}
+
 	return vals
 }
 
@@ -267,6 +278,9 @@ func (s IdentityMappingSetter) Overwrite(t *IdentityMapping) {
 	if s.KeyScope != nil {
 		t.KeyScope = *s.KeyScope
 	}
+	if s.RoleName != nil {
+		t.RoleName = *s.RoleName
+	}
 }
 
 func (s *IdentityMappingSetter) Apply(q *dialect.InsertQuery) {
@@ -275,7 +289,7 @@ func (s *IdentityMappingSetter) Apply(q *dialect.InsertQuery) {
 	})
 
 	q.AppendValues(bob.ExpressionFunc(func(ctx context.Context, w io.Writer, d bob.Dialect, start int) ([]any, error) {
-		vals := make([]bob.Expression, 11)
+		vals := make([]bob.Expression, 12)
 		if s.MappingID != nil {
 			vals[0] = psql.Arg(*s.MappingID)
 		} else {
@@ -342,6 +356,12 @@ func (s *IdentityMappingSetter) Apply(q *dialect.InsertQuery) {
 			vals[10] = psql.Raw("DEFAULT")
 		}
 
+		if s.RoleName != nil {
+			vals[11] = psql.Arg(*s.RoleName)
+		} else {
+			vals[11] = psql.Raw("DEFAULT")
+		}
+
 		return bob.ExpressSlice(ctx, w, d, start, vals, "", ", ", "")
 	}))
 }
@@ -351,7 +371,7 @@ func (s IdentityMappingSetter) UpdateMod() bob.Mod[*dialect.UpdateQuery] {
 }
 
 func (s IdentityMappingSetter) Expressions(prefix ...string) []bob.Expression {
-	exprs := make([]bob.Expression, 0, 11)
+	exprs := make([]bob.Expression, 0, 12)
 
 	if s.MappingID != nil {
 		exprs = append(exprs, expr.Join{Sep: " = ", Exprs: []bob.Expression{
@@ -430,6 +450,13 @@ func (s IdentityMappingSetter) Expressions(prefix ...string) []bob.Expression {
 		}})
 	}
 
+	if s.RoleName != nil {
+		exprs = append(exprs, expr.Join{Sep: " = ", Exprs: []bob.Expression{
+			psql.Quote(append(prefix, "role_name")...),
+			psql.Arg(s.RoleName),
+		}})
+	}
+
 	return exprs
 }
 
@@ -43,11 +43,11 @@ func TestIdentityMappingUniqueConstraintErrors(t *testing.T) {
 			},
 		},
 		{
-			name:        "ErrUniqueUniqueFingerprintPseudonymScope",
-			expectedErr: models.IdentityMappingErrors.ErrUniqueUniqueFingerprintPseudonymScope,
+			name:        "ErrUniqueUniqueFingerprintPseudonymScopeRole",
+			expectedErr: models.IdentityMappingErrors.ErrUniqueUniqueFingerprintPseudonymScopeRole,
 			conflictMods: func(ctx context.Context, exec bob.Executor, obj *models.IdentityMapping) factory.IdentityMappingModSlice {
 				shouldUpdate := false
-				updateMods := make(factory.IdentityMappingModSlice, 0, 3)
+				updateMods := make(factory.IdentityMappingModSlice, 0, 4)
 
 				if shouldUpdate {
 					if err := obj.Update(ctx, exec, f.NewIdentityMapping(ctx, updateMods...).BuildSetter()); err != nil {
@@ -59,6 +59,7 @@ func TestIdentityMappingUniqueConstraintErrors(t *testing.T) {
 					factory.IdentityMappingMods.Fingerprint(obj.Fingerprint),
 					factory.IdentityMappingMods.PseudonymID(obj.PseudonymID),
 					factory.IdentityMappingMods.KeyScope(obj.KeyScope),
+					factory.IdentityMappingMods.RoleName(obj.RoleName),
 				}
 			},
 		},
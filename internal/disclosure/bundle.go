@@ -0,0 +1,246 @@
+// Package disclosure builds the signed evidence bundles that answer the
+// legal requests tracked as compliance reports, and verifies received
+// bundles.
+//
+// A bundle is a gzipped tar archive. Its manifest.json lists every other
+// file with its size and SHA-256 hash, and manifest.sig holds the hex
+// Ed25519 signature of the manifest by the platform's disclosure key. A
+// bundle can be encrypted to the X25519 key of its recipient.
+package disclosure
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format identifies the layout of a bundle
+const Format = "hashpost-disclosure-bundle-v1"
+
+// signatureDomain separates manifest signatures from other signatures
+const signatureDomain = "hashpost-disclosure-manifest-v1"
+
+// Names of the files that describe a bundle
+const (
+	ManifestName  = "manifest.json"
+	SignatureName = "manifest.sig"
+)
+
+// maxFileSize bounds the size of a file read from a bundle
+const maxFileSize = 256 << 20
+
+// ErrInvalidBundle is returned when a bundle does not verify
+var ErrInvalidBundle = errors.New("invalid disclosure bundle")
+
+// Manifest describes a bundle and the files in it
+type Manifest struct {
+	Format     string         `json:"format"`
+	BundleID   string         `json:"bundle_id"`
+	ReportID   string         `json:"report_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	KeyID      string         `json:"key_id"`
+	Pseudonyms []string       `json:"pseudonyms"`
+	Files      []ManifestFile `json:"files"`
+}
+
+// ManifestFile is the entry of a file in a manifest
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// File is a file of a bundle
+type File struct {
+	Path string
+	Data []byte
+}
+
+// signedPayload returns the bytes signed for a manifest
+func signedPayload(manifest []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(signatureDomain))
+	h.Write([]byte{0})
+	h.Write(manifest)
+	return h.Sum(nil)
+}
+
+// Seal lists files in the manifest, signs it and writes the archive. It
+// returns the archive and the SHA-256 hash of the signed manifest.
+func Seal(signer *Signer, manifest *Manifest, files []File) ([]byte, []byte, error) {
+	manifest.Format = Format
+	manifest.KeyID = signer.KeyID()
+	manifest.CreatedAt = manifest.CreatedAt.UTC().Truncate(time.Second)
+	manifest.Files = make([]ManifestFile, 0, len(files))
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !validPath(file.Path) || seen[file.Path] || file.Path == ManifestName || file.Path == SignatureName {
+			return nil, nil, fmt.Errorf("invalid bundle file path %q", file.Path)
+		}
+		seen[file.Path] = true
+
+		sum := sha256.Sum256(file.Data)
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:   file.Path,
+			Size:   int64(len(file.Data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	signature := signer.sign(manifestData)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	entries := append([]File{
+		{Path: ManifestName, Data: manifestData},
+		{Path: SignatureName, Data: []byte(hex.EncodeToString(signature) + "\n")},
+	}, files...)
+	for _, entry := range entries {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Path,
+			Size:     int64(len(entry.Data)),
+			Mode:     0644,
+			ModTime:  manifest.CreatedAt,
+			Format:   tar.FormatPAX,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to write bundle file %s: %w", entry.Path, err)
+		}
+		if _, err := tw.Write(entry.Data); err != nil {
+			return nil, nil, fmt.Errorf("failed to write bundle file %s: %w", entry.Path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	manifestSum := sha256.Sum256(manifestData)
+	return buf.Bytes(), manifestSum[:], nil
+}
+
+// Open reads an unencrypted archive and checks that its manifest was signed
+// with the key and that its files are exactly the ones the manifest lists.
+// It returns the manifest and the listed files in manifest order.
+func Open(public ed25519.PublicKey, archive []byte) (*Manifest, []File, error) {
+	contents, err := readArchive(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifestData, ok := contents[ManifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrInvalidBundle, ManifestName)
+	}
+	signatureHex, ok := contents[SignatureName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrInvalidBundle, SignatureName)
+	}
+	signature, err := hex.DecodeString(string(bytes.TrimSpace(signatureHex)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed signature", ErrInvalidBundle)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest.Format != Format {
+		return nil, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidBundle, manifest.Format)
+	}
+	if manifest.KeyID != KeyID(public) {
+		return nil, nil, fmt.Errorf("%w: manifest was signed by key %s", ErrInvalidBundle, manifest.KeyID)
+	}
+	if !ed25519.Verify(public, signedPayload(manifestData), signature) {
+		return nil, nil, fmt.Errorf("%w: bad manifest signature", ErrInvalidBundle)
+	}
+
+	files := make([]File, 0, len(manifest.Files))
+	for _, listed := range manifest.Files {
+		data, ok := contents[listed.Path]
+		if !ok || listed.Path == ManifestName || listed.Path == SignatureName {
+			return nil, nil, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, listed.Path)
+		}
+		delete(contents, listed.Path)
+
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != listed.Size || hex.EncodeToString(sum[:]) != listed.SHA256 {
+			return nil, nil, fmt.Errorf("%w: %s does not match its hash", ErrInvalidBundle, listed.Path)
+		}
+		files = append(files, File{Path: listed.Path, Data: data})
+	}
+
+	delete(contents, ManifestName)
+	delete(contents, SignatureName)
+	if len(contents) > 0 {
+		extra := make([]string, 0, len(contents))
+		for name := range contents {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return nil, nil, fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBundle, extra[0])
+	}
+	return &manifest, files, nil
+}
+
+// readArchive reads the regular files of a gzipped tar archive
+func readArchive(archive []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a gzipped archive", ErrInvalidBundle)
+	}
+	defer gz.Close()
+
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return contents, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if header.Typeflag != tar.TypeReg || !validPath(header.Name) {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBundle, header.Name)
+		}
+		if _, ok := contents[header.Name]; ok {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidBundle, header.Name)
+		}
+		if header.Size > maxFileSize {
+			return nil, fmt.Errorf("%w: %s is too large", ErrInvalidBundle, header.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		contents[header.Name] = data
+	}
+}
+
+// validPath reports whether a path is a clean relative path, so that
+// extracting a bundle cannot write outside its directory
+func validPath(name string) bool {
+	return name != "" && path.Clean(name) == name && !path.IsAbs(name) &&
+		name != "." && name != ".." && !strings.HasPrefix(name, "../")
+}
@@ -0,0 +1,228 @@
+package disclosure
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testFiles are the files of the test bundles
+func testFiles() []File {
+	return []File{
+		{Path: "case.json", Data: []byte(`{"report_id": "r1"}`)},
+		{Path: "content/abc123/posts.json", Data: []byte(`[{"post_id": 1}]`)},
+	}
+}
+
+// sealTestBundle seals the test files with a new key
+func sealTestBundle(t *testing.T) (*Signer, []byte) {
+	t.Helper()
+	signer, err := GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	archive, _, err := Seal(signer, &Manifest{BundleID: "b1", ReportID: "r1", CreatedAt: time.Now()}, testFiles())
+	if err != nil {
+		t.Fatalf("Failed to seal bundle: %v", err)
+	}
+	return signer, archive
+}
+
+// rewriteArchive rewrites the entries of an archive through change, which
+// returns the new data of an entry or nil to drop it, and appends extra
+func rewriteArchive(t *testing.T, archive []byte, change func(name string, data []byte) []byte, extra ...File) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var buf bytes.Buffer
+	out := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(out)
+	write := func(name string, data []byte) {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(data)), Mode: 0644})
+		tw.Write(data)
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		if data = change(header.Name, data); data != nil {
+			write(header.Name, data)
+		}
+	}
+	for _, file := range extra {
+		write(file.Path, file.Data)
+	}
+	tw.Close()
+	out.Close()
+	return buf.Bytes()
+}
+
+func TestSealOpen_RoundTrip(t *testing.T) {
+	signer, archive := sealTestBundle(t)
+
+	manifest, files, err := Open(signer.PublicKey(), archive)
+	if err != nil {
+		t.Fatalf("Expected sealed bundle to verify, got %v", err)
+	}
+	if manifest.Format != Format || manifest.KeyID != signer.KeyID() || manifest.ReportID != "r1" {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if len(files) != 2 || files[1].Path != "content/abc123/posts.json" || string(files[1].Data) != `[{"post_id": 1}]` {
+		t.Errorf("Unexpected files: %+v", files)
+	}
+}
+
+func TestOpen_RejectsTampering(t *testing.T) {
+	signer, archive := sealTestBundle(t)
+
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"ChangedFile", rewriteArchive(t, archive, func(name string, data []byte) []byte {
+			if name == "case.json" {
+				return []byte(`{"report_id": "r2"}`)
+			}
+			return data
+		})},
+		{"RemovedFile", rewriteArchive(t, archive, func(name string, data []byte) []byte {
+			if name == "content/abc123/posts.json" {
+				return nil
+			}
+			return data
+		})},
+		{"AddedFile", rewriteArchive(t, archive, func(name string, data []byte) []byte { return data },
+			File{Path: "content/abc123/comments.json", Data: []byte(`[]`)})},
+		{"ChangedManifest", rewriteArchive(t, archive, func(name string, data []byte) []byte {
+			if name == ManifestName {
+				return bytes.Replace(data, []byte(`"r1"`), []byte(`"r2"`), 1)
+			}
+			return data
+		})},
+		{"PathTraversal", rewriteArchive(t, archive, func(name string, data []byte) []byte { return data },
+			File{Path: "../escape", Data: []byte(`x`)})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Open(signer.PublicKey(), tt.archive); !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("Expected ErrInvalidBundle, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOpen_RejectsOtherKey(t *testing.T) {
+	_, archive := sealTestBundle(t)
+	other, _ := GenerateSigner()
+
+	if _, _, err := Open(other.PublicKey(), archive); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for another key, got %v", err)
+	}
+}
+
+func TestSeal_RejectsReservedPaths(t *testing.T) {
+	signer, _ := GenerateSigner()
+	for _, name := range []string{ManifestName, "../case.json", "/case.json", "content//a.json"} {
+		if _, _, err := Seal(signer, &Manifest{}, []File{{Path: name}}); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	_, archive := sealTestBundle(t)
+	recipient, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate recipient key: %v", err)
+	}
+
+	encrypted, err := Encrypt(recipient.PublicKey(), archive)
+	if err != nil {
+		t.Fatalf("Failed to encrypt bundle: %v", err)
+	}
+	if !IsEncrypted(encrypted) || IsEncrypted(archive) {
+		t.Fatal("Expected only the encrypted bundle to be recognized as encrypted")
+	}
+
+	decrypted, err := Decrypt(recipient, encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt bundle: %v", err)
+	}
+	if !bytes.Equal(decrypted, archive) {
+		t.Error("Expected decryption to return the archive")
+	}
+
+	other, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	if _, err := Decrypt(other, encrypted); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle decrypting with another key, got %v", err)
+	}
+	encrypted[len(encrypted)-1] ^= 1
+	if _, err := Decrypt(recipient, encrypted); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for an altered bundle, got %v", err)
+	}
+}
+
+func TestKeys_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	signer, _ := GenerateSigner()
+	path := filepath.Join(dir, "disclosure.pem")
+	if err := signer.Save(path); err != nil {
+		t.Fatalf("Failed to save key: %v", err)
+	}
+	if err := signer.Save(path); err == nil {
+		t.Error("Expected an existing key not to be overwritten")
+	}
+
+	loaded, err := LoadSigner(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	public, err := LoadPublicKey(PublicKeyPath(path))
+	if err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+	if loaded.KeyID() != signer.KeyID() || KeyID(public) != signer.KeyID() {
+		t.Error("Expected loaded keys to match the saved key")
+	}
+	if _, err := LoadSigner(filepath.Join(dir, "missing.pem")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist for a missing key, got %v", err)
+	}
+
+	// Recipient keys are the PKCS #8 and PKIX encodings openssl writes
+	recipient, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	private, _ := x509.MarshalPKCS8PrivateKey(recipient)
+	public25519, _ := x509.MarshalPKIXPublicKey(recipient.PublicKey())
+	privatePath := filepath.Join(dir, "recipient.pem")
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0600)
+
+	loadedPrivate, err := LoadRecipientPrivateKey(privatePath)
+	if err != nil || !loadedPrivate.Equal(recipient) {
+		t.Errorf("Failed to load recipient private key: %v", err)
+	}
+	loadedPublic, err := ParseRecipientKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public25519}))
+	if err != nil || !loadedPublic.Equal(recipient.PublicKey()) {
+		t.Errorf("Failed to parse recipient key: %v", err)
+	}
+	if _, err := LoadRecipientPrivateKey(path); err == nil {
+		t.Error("Expected an Ed25519 key to be rejected as a recipient key")
+	}
+}
@@ -0,0 +1,101 @@
+package disclosure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+// encryptedMagic starts every encrypted bundle
+const encryptedMagic = "HPDBENC1"
+
+// encryptionInfo binds the derived key to its use
+const encryptionInfo = "hashpost-disclosure-bundle-encryption-v1"
+
+// encryptedHeaderSize is the size of the magic and the ephemeral public key
+const encryptedHeaderSize = len(encryptedMagic) + 32
+
+// Encrypt encrypts an archive so that only the holder of the recipient's
+// private key can read it. The archive key is derived with HKDF-SHA256 from
+// an X25519 exchange with a one-time key, and the archive is sealed with
+// AES-256-GCM. The output is the magic, the one-time public key, the nonce
+// and the ciphertext; the first two are authenticated with the archive.
+func Encrypt(recipient *ecdh.PublicKey, archive []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate bundle encryption key: %w", err)
+	}
+	aead, err := archiveCipher(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(encryptedMagic), ephemeral.PublicKey().Bytes()...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate bundle nonce: %w", err)
+	}
+
+	out := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(out, nonce, archive, header), nil
+}
+
+// Decrypt decrypts an archive encrypted to the recipient's key
+func Decrypt(recipient *ecdh.PrivateKey, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) || len(data) < encryptedHeaderSize {
+		return nil, fmt.Errorf("%w: not an encrypted bundle", ErrInvalidBundle)
+	}
+	header := data[:encryptedHeaderSize]
+	ephemeral, err := ecdh.X25519().NewPublicKey(header[len(encryptedMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed encryption header", ErrInvalidBundle)
+	}
+	aead, err := archiveCipher(recipient, ephemeral, ephemeral, recipient.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[encryptedHeaderSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated encrypted bundle", ErrInvalidBundle)
+	}
+	archive, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bundle was not encrypted to this key or was altered", ErrInvalidBundle)
+	}
+	return archive, nil
+}
+
+// IsEncrypted reports whether a bundle is encrypted
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// archiveCipher derives the archive cipher from the exchange of private with
+// peer. Both public keys of the exchange are mixed into the key.
+func archiveCipher(private *ecdh.PrivateKey, peer, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive bundle key: %w", err)
+	}
+	salt := append(append([]byte{}, ephemeral.Bytes()...), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, encryptionInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive bundle key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle cipher: %w", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,466 @@
+package disclosure
+
+import (
+	"context"
+	"crypto/ecdh"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/stephenafamo/bob"
+)
+
+// ErrNoCorrelations is returned when a compliance report has no linked
+// correlations to disclose
+var ErrNoCorrelations = errors.New("compliance report has no linked correlations")
+
+// Bundle is a sealed bundle
+type Bundle struct {
+	Manifest *Manifest
+	// ManifestSHA256 is the hash of the signed manifest, by which the
+	// bundle is recorded in the history of its report
+	ManifestSHA256 []byte
+	// Data is the archive, encrypted if Encrypted is set
+	Data      []byte
+	Encrypted bool
+}
+
+// Exporter collects the evidence of a compliance report into a bundle: the
+// report, the correlations linked to it with their results, their entries in
+// the correlation audit trail, and the posts and comments of the pseudonyms
+// they concern
+type Exporter struct {
+	complianceReportDAO *dao.ComplianceReportDAO
+	correlationAuditDAO *dao.CorrelationAuditDAO
+	postDAO             *dao.PostDAO
+	commentDAO          *dao.CommentDAO
+	signer              *Signer
+}
+
+// NewExporter creates an exporter signing bundles with signer
+func NewExporter(db bob.Executor, auditChainDAO *dao.AuditChainDAO, signer *Signer) *Exporter {
+	return &Exporter{
+		complianceReportDAO: dao.NewComplianceReportDAO(db),
+		correlationAuditDAO: dao.NewCorrelationAuditDAO(db, auditChainDAO),
+		postDAO:             dao.NewPostDAO(db),
+		commentDAO:          dao.NewCommentDAO(db),
+		signer:              signer,
+	}
+}
+
+// Export seals the bundle of a report, encrypted to recipient unless it is
+// nil, and records the export in the history of the report. actorID is
+// unset for exports from the command line.
+func (e *Exporter) Export(ctx context.Context, report *dao.ComplianceReport, recipient *ecdh.PublicKey, actorID sql.Null[int64]) (*Bundle, error) {
+	correlations, err := e.complianceReportDAO.ListCorrelations(ctx, report.ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if len(correlations) == 0 {
+		return nil, ErrNoCorrelations
+	}
+
+	auditEntries, err := e.auditEntries(ctx, correlations)
+	if err != nil {
+		return nil, err
+	}
+	correlationRecords, pseudonyms, err := newCorrelationRecords(correlations, auditEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []File{}
+	add := func(path string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		files = append(files, File{Path: path, Data: data})
+		return nil
+	}
+	if err := add("case.json", newCaseRecord(report)); err != nil {
+		return nil, err
+	}
+	if err := add("correlations.json", correlationRecords); err != nil {
+		return nil, err
+	}
+	if err := add("audit/correlation_audit.json", newAuditRecords(auditEntries)); err != nil {
+		return nil, err
+	}
+	for _, pseudonymID := range pseudonyms {
+		posts, err := e.postDAO.GetPostsByPseudonym(ctx, pseudonymID)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := e.commentDAO.GetCommentsByPseudonym(ctx, pseudonymID)
+		if err != nil {
+			return nil, err
+		}
+		if err := add("content/"+pseudonymID+"/posts.json", newPostRecords(posts)); err != nil {
+			return nil, err
+		}
+		if err := add("content/"+pseudonymID+"/comments.json", newCommentRecords(comments)); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := &Manifest{
+		BundleID:   uuid.Must(uuid.NewV4()).String(),
+		ReportID:   report.ReportID.String(),
+		CreatedAt:  time.Now(),
+		Pseudonyms: pseudonyms,
+	}
+	archive, manifestSum, err := Seal(e.signer, manifest, files)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{Manifest: manifest, ManifestSHA256: manifestSum, Data: archive}
+	if recipient != nil {
+		bundle.Data, err = Encrypt(recipient, archive)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Encrypted = true
+	}
+
+	note := fmt.Sprintf("Bundle %s with manifest SHA-256 %x", manifest.BundleID, manifestSum)
+	if bundle.Encrypted {
+		note += ", encrypted to the recipient's key"
+	}
+	if err := e.complianceReportDAO.RecordEvent(ctx, &dao.ComplianceReportEvent{
+		ReportID:  report.ReportID,
+		ActorID:   actorID,
+		EventType: dao.ComplianceEventBundleExported,
+		Status:    sql.Null[string]{V: report.Status, Valid: true},
+		Note:      sql.Null[string]{V: note, Valid: true},
+	}); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// FileName returns the name a bundle is saved under
+func FileName(bundle *Bundle) string {
+	name := fmt.Sprintf("disclosure-%s-%s.tar.gz", bundle.Manifest.ReportID, bundle.Manifest.BundleID)
+	if bundle.Encrypted {
+		name += ".enc"
+	}
+	return name
+}
+
+// auditEntries returns the audit entries of the linked correlations and of
+// the transitions of their correlation requests, in chain order
+func (e *Exporter) auditEntries(ctx context.Context, correlations []dao.ComplianceCorrelation) ([]dao.CorrelationAuditEntry, error) {
+	entries := []dao.CorrelationAuditEntry{}
+	seen := make(map[uuid.UUID]bool)
+	addEntry := func(entry dao.CorrelationAuditEntry) {
+		if !seen[entry.AuditID] {
+			seen[entry.AuditID] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	for i := range correlations {
+		if correlations[i].RequestID.Valid {
+			requestEntries, err := e.correlationAuditDAO.ListRequestEntries(ctx, correlations[i].RequestID.V)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range requestEntries {
+				addEntry(entry)
+			}
+		}
+
+		entry, err := e.correlationAuditDAO.GetEntry(ctx, correlations[i].AuditID)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("correlation audit entry %s does not exist", correlations[i].AuditID)
+		}
+		addEntry(*entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.ChainSeq.Valid != b.ChainSeq.Valid {
+			return !a.ChainSeq.Valid
+		}
+		if a.ChainSeq.Valid {
+			return a.ChainSeq.V < b.ChainSeq.V
+		}
+		return a.Timestamp.V.Before(b.Timestamp.V)
+	})
+	return entries, nil
+}
+
+// caseRecord is the compliance report in case.json
+type caseRecord struct {
+	ReportID            string  `json:"report_id"`
+	ReportType          string  `json:"report_type"`
+	RequestingAuthority string  `json:"requesting_authority,omitempty"`
+	RequestID           string  `json:"request_id,omitempty"`
+	RequestDate         string  `json:"request_date"`
+	DueDate             string  `json:"due_date,omitempty"`
+	Status              string  `json:"status"`
+	ScopeDescription    string  `json:"scope_description"`
+	LegalBasis          string  `json:"legal_basis,omitempty"`
+	GagOrder            bool    `json:"gag_order"`
+	CompletedAt         *string `json:"completed_at,omitempty"`
+}
+
+func newCaseRecord(report *dao.ComplianceReport) caseRecord {
+	record := caseRecord{
+		ReportID:            report.ReportID.String(),
+		ReportType:          report.ReportType,
+		RequestingAuthority: report.RequestingAuthority.V,
+		RequestID:           report.RequestID.V,
+		RequestDate:         report.RequestDate.Format(time.DateOnly),
+		Status:              report.Status,
+		ScopeDescription:    report.ScopeDescription,
+		LegalBasis:          report.LegalBasis.V,
+		GagOrder:            report.GagOrder,
+	}
+	if report.DueDate.Valid {
+		record.DueDate = report.DueDate.V.Format(time.DateOnly)
+	}
+	record.CompletedAt = formatTime(report.CompletedAt)
+	return record
+}
+
+// correlationRecord is a linked correlation with its results in
+// correlations.json
+type correlationRecord struct {
+	AuditID            string          `json:"audit_id"`
+	RequestID          string          `json:"request_id,omitempty"`
+	CorrelationType    string          `json:"correlation_type"`
+	CorrelationScope   string          `json:"correlation_scope"`
+	RequestedPseudonym string          `json:"requested_pseudonym"`
+	CorrelatedBy       int64           `json:"correlated_by"`
+	RoleUsed           string          `json:"role_used"`
+	CorrelatedAt       *string         `json:"correlated_at,omitempty"`
+	Justification      string          `json:"justification"`
+	LegalBasis         string          `json:"legal_basis,omitempty"`
+	Results            json.RawMessage `json:"results"`
+}
+
+// newCorrelationRecords converts the linked correlations and returns them
+// with the pseudonyms they concern: the requested pseudonyms and every
+// pseudonym in their results, sorted
+func newCorrelationRecords(correlations []dao.ComplianceCorrelation, entries []dao.CorrelationAuditEntry) ([]correlationRecord, []string, error) {
+	byID := make(map[uuid.UUID]*dao.CorrelationAuditEntry, len(entries))
+	for i := range entries {
+		byID[entries[i].AuditID] = &entries[i]
+	}
+
+	records := make([]correlationRecord, 0, len(correlations))
+	pseudonymSet := make(map[string]bool)
+	for i := range correlations {
+		correlation := &correlations[i]
+		entry := byID[correlation.AuditID]
+		results := json.RawMessage("null")
+		if entry.CorrelationResult.Valid {
+			results = entry.CorrelationResult.V.Val
+		}
+
+		pseudonymSet[correlation.RequestedPseudonym] = true
+		var found []struct {
+			PseudonymID string `json:"pseudonym_id"`
+		}
+		if err := json.Unmarshal(results, &found); err != nil {
+			return nil, nil, fmt.Errorf("failed to read results of correlation %s: %w", correlation.AuditID, err)
+		}
+		for _, result := range found {
+			if result.PseudonymID != "" {
+				pseudonymSet[result.PseudonymID] = true
+			}
+		}
+
+		record := correlationRecord{
+			AuditID:            correlation.AuditID.String(),
+			CorrelationType:    correlation.CorrelationType,
+			CorrelationScope:   correlation.CorrelationScope,
+			RequestedPseudonym: correlation.RequestedPseudonym,
+			CorrelatedBy:       correlation.CorrelatedBy,
+			RoleUsed:           correlation.RoleUsed,
+			CorrelatedAt:       formatTime(correlation.CorrelatedAt),
+			Justification:      entry.Justification,
+			LegalBasis:         entry.LegalBasis.V,
+			Results:            results,
+		}
+		if correlation.RequestID.Valid {
+			record.RequestID = correlation.RequestID.V.String()
+		}
+		records = append(records, record)
+	}
+
+	pseudonyms := make([]string, 0, len(pseudonymSet))
+	for pseudonymID := range pseudonymSet {
+		// Pseudonym IDs name directories of the bundle
+		if strings.ContainsAny(pseudonymID, `/\`) || !validPath(pseudonymID) {
+			return nil, nil, fmt.Errorf("correlation results name an invalid pseudonym %q", pseudonymID)
+		}
+		pseudonyms = append(pseudonyms, pseudonymID)
+	}
+	sort.Strings(pseudonyms)
+	return records, pseudonyms, nil
+}
+
+// auditRecord is an entry of the correlation audit trail in
+// audit/correlation_audit.json, with its link in the audit chain so that it
+// can be checked against the platform's signed checkpoints
+type auditRecord struct {
+	AuditID              string          `json:"audit_id"`
+	UserID               int64           `json:"user_id"`
+	PseudonymID          string          `json:"pseudonym_id"`
+	AdminUsername        string          `json:"admin_username"`
+	RoleUsed             string          `json:"role_used"`
+	RequestedPseudonym   string          `json:"requested_pseudonym"`
+	RequestedFingerprint *string         `json:"requested_fingerprint"`
+	Justification        string          `json:"justification"`
+	CorrelationType      string          `json:"correlation_type"`
+	CorrelationResult    json.RawMessage `json:"correlation_result"`
+	Timestamp            *string         `json:"timestamp"`
+	LegalBasis           *string         `json:"legal_basis"`
+	IncidentID           *string         `json:"incident_id"`
+	RequestSource        *string         `json:"request_source"`
+	RequestID            *string         `json:"request_id"`
+	RequestStatus        *string         `json:"request_status"`
+	IPHash               *string         `json:"ip_hash"`
+	IPPrefix             *string         `json:"ip_prefix"`
+	UserAgentFamily      *string         `json:"user_agent_family"`
+	ChainSeq             *int64          `json:"chain_seq"`
+	PrevHash             string          `json:"prev_hash,omitempty"`
+	EntryHash            string          `json:"entry_hash,omitempty"`
+}
+
+func newAuditRecords(entries []dao.CorrelationAuditEntry) []auditRecord {
+	records := make([]auditRecord, 0, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		record := auditRecord{
+			AuditID:              entry.AuditID.String(),
+			UserID:               entry.UserID,
+			PseudonymID:          entry.PseudonymID,
+			AdminUsername:        entry.AdminUsername,
+			RoleUsed:             entry.RoleUsed,
+			RequestedPseudonym:   entry.RequestedPseudonym,
+			RequestedFingerprint: nullable(entry.RequestedFingerprint),
+			Justification:        entry.Justification,
+			CorrelationType:      entry.CorrelationType,
+			CorrelationResult:    json.RawMessage("null"),
+			Timestamp:            formatTime(entry.Timestamp),
+			LegalBasis:           nullable(entry.LegalBasis),
+			IncidentID:           nullable(entry.IncidentID),
+			RequestSource:        nullable(entry.RequestSource),
+			RequestStatus:        nullable(entry.RequestStatus),
+			IPHash:               nullable(entry.IPHash),
+			IPPrefix:             nullable(entry.IPPrefix),
+			UserAgentFamily:      nullable(entry.UserAgentFamily),
+			ChainSeq:             nullable(entry.ChainSeq),
+			PrevHash:             hex.EncodeToString(entry.PrevHash),
+			EntryHash:            hex.EncodeToString(entry.EntryHash),
+		}
+		if entry.CorrelationResult.Valid {
+			record.CorrelationResult = entry.CorrelationResult.V.Val
+		}
+		if entry.RequestID.Valid {
+			requestID := entry.RequestID.V.String()
+			record.RequestID = &requestID
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// postRecord is a post in content/<pseudonym_id>/posts.json
+type postRecord struct {
+	PostID        int64   `json:"post_id"`
+	SubforumID    int32   `json:"subforum_id"`
+	Title         string  `json:"title"`
+	Content       *string `json:"content"`
+	URL           *string `json:"url"`
+	PostType      string  `json:"post_type"`
+	CreatedAt     *string `json:"created_at"`
+	UpdatedAt     *string `json:"updated_at"`
+	IsRemoved     bool    `json:"is_removed"`
+	RemovalReason *string `json:"removal_reason,omitempty"`
+	RemovedAt     *string `json:"removed_at,omitempty"`
+}
+
+func newPostRecords(posts []*models.Post) []postRecord {
+	records := make([]postRecord, 0, len(posts))
+	for _, post := range posts {
+		records = append(records, postRecord{
+			PostID:        post.PostID,
+			SubforumID:    post.SubforumID,
+			Title:         post.Title,
+			Content:       nullable(post.Content),
+			URL:           nullable(post.URL),
+			PostType:      post.PostType,
+			CreatedAt:     formatTime(post.CreatedAt),
+			UpdatedAt:     formatTime(post.UpdatedAt),
+			IsRemoved:     post.IsRemoved.V,
+			RemovalReason: nullable(post.RemovalReason),
+			RemovedAt:     formatTime(post.RemovedAt),
+		})
+	}
+	return records
+}
+
+// commentRecord is a comment in content/<pseudonym_id>/comments.json
+type commentRecord struct {
+	CommentID       int64   `json:"comment_id"`
+	PostID          int64   `json:"post_id"`
+	ParentCommentID *int64  `json:"parent_comment_id"`
+	Content         string  `json:"content"`
+	CreatedAt       *string `json:"created_at"`
+	EditedAt        *string `json:"edited_at,omitempty"`
+	IsRemoved       bool    `json:"is_removed"`
+	RemovalReason   *string `json:"removal_reason,omitempty"`
+	RemovedAt       *string `json:"removed_at,omitempty"`
+}
+
+func newCommentRecords(comments []*models.Comment) []commentRecord {
+	records := make([]commentRecord, 0, len(comments))
+	for _, comment := range comments {
+		records = append(records, commentRecord{
+			CommentID:       comment.CommentID,
+			PostID:          comment.PostID,
+			ParentCommentID: nullable(comment.ParentCommentID),
+			Content:         comment.Content,
+			CreatedAt:       formatTime(comment.CreatedAt),
+			EditedAt:        formatTime(comment.EditedAt),
+			IsRemoved:       comment.IsRemoved.V,
+			RemovalReason:   nullable(comment.RemovalReason),
+			RemovedAt:       formatTime(comment.RemovedAt),
+		})
+	}
+	return records
+}
+
+// nullable returns a pointer to the value of a set column, nil otherwise
+func nullable[T any](v sql.Null[T]) *T {
+	if !v.Valid {
+		return nil
+	}
+	return &v.V
+}
+
+// formatTime formats a set timestamp in UTC RFC 3339, nil otherwise
+func formatTime(t sql.Null[time.Time]) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.V.UTC().Format(time.RFC3339Nano)
+	return &formatted
+}
@@ -0,0 +1,204 @@
+package disclosure
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// Signer signs bundle manifests with the platform's Ed25519 disclosure key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// GenerateSigner creates a signer with a new key
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate disclosure signing key: %w", err)
+	}
+	return newSigner(key), nil
+}
+
+func newSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		key:   key,
+		keyID: KeyID(key.Public().(ed25519.PublicKey)),
+	}
+}
+
+// LoadSigner reads a PKCS #8 PEM disclosure signing key. The returned error
+// wraps os.ErrNotExist if the file does not exist.
+func LoadSigner(path string) (*Signer, error) {
+	parsed, err := readPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("disclosure signing key %s is not an Ed25519 key", path)
+	}
+	return newSigner(key), nil
+}
+
+// Save writes the private key to path, readable only by the owner, and the
+// public key recipients verify bundles with to path.pub. An existing key is
+// never overwritten.
+func (s *Signer) Save(path string) error {
+	private, err := x509.MarshalPKCS8PrivateKey(s.key)
+	if err != nil {
+		return fmt.Errorf("failed to encode disclosure signing key: %w", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(s.PublicKey())
+	if err != nil {
+		return fmt.Errorf("failed to encode disclosure public key: %w", err)
+	}
+
+	if err := writePEM(path, "PRIVATE KEY", private, 0600); err != nil {
+		return err
+	}
+	return writePEM(PublicKeyPath(path), "PUBLIC KEY", public, 0644)
+}
+
+// PublicKey returns the public key bundles are verified with
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID returns the ID of the signer's key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// sign signs a manifest
+func (s *Signer) sign(manifest []byte) []byte {
+	return ed25519.Sign(s.key, signedPayload(manifest))
+}
+
+// PublicKeyPath returns the path of the public key saved with a disclosure
+// signing key
+func PublicKeyPath(path string) string {
+	return path + ".pub"
+}
+
+// LoadPublicKey reads a PEM disclosure public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	parsed, err := readPublicKey(path)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("disclosure public key %s is not an Ed25519 key", path)
+	}
+	return public, nil
+}
+
+// KeyID returns the ID of a disclosure public key: the first 8 bytes of its
+// SHA-256 hash, in hex
+func KeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseRecipientKey parses the PEM X25519 public key of a bundle recipient,
+// such as one written by "openssl pkey -pubout"
+func ParseRecipientKey(data []byte) (*ecdh.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no public key in recipient key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recipient key: %w", err)
+	}
+	public, ok := parsed.(*ecdh.PublicKey)
+	if !ok || public.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("recipient key is not an X25519 key")
+	}
+	return public, nil
+}
+
+// LoadRecipientKey reads the PEM X25519 public key of a bundle recipient
+func LoadRecipientKey(path string) (*ecdh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipient key: %w", err)
+	}
+	return ParseRecipientKey(data)
+}
+
+// LoadRecipientPrivateKey reads the PKCS #8 PEM X25519 private key a
+// recipient decrypts bundles with, such as one written by
+// "openssl genpkey -algorithm X25519"
+func LoadRecipientPrivateKey(path string) (*ecdh.PrivateKey, error) {
+	parsed, err := readPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdh.PrivateKey)
+	if !ok || key.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("recipient private key %s is not an X25519 key", path)
+	}
+	return key, nil
+}
+
+// readPrivateKey reads a PKCS #8 PEM private key
+func readPrivateKey(path string) (any, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// readPublicKey reads a PEM public key
+func readPublicKey(path string) (any, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// readPEM reads the first PEM block of a file, which must be of blockType
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("no %s in %s", blockType, path)
+	}
+	return block, nil
+}
+
+// writePEM writes a PEM block to a new file
+func writePEM(path, blockType string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: data}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
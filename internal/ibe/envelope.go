@@ -0,0 +1,284 @@
+package ibe
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Identity mapping envelopes
+//
+// An encrypted identity mapping is a binary envelope:
+//
+//	offset  size  field
+//	0       4     magic "HPIM"
+//	4       1     format version
+//	5       1     algorithm ID
+//	6       4     key version (big endian)
+//	10      1     domain ID
+//	11      8     key epoch (big endian; see EpochDuration)
+//	19      12    nonce
+//	31      ...   ciphertext and tag
+//
+// The plaintext is the identity fingerprint. The header and the pseudonym ID
+// are authenticated as additional data, so a ciphertext only decrypts for the
+// pseudonym and key version it was written for. The AES key is derived from
+// the role key for the key epoch.
+//
+// Mappings written before envelopes were introduced are bare
+// nonce||ciphertext of "fingerprint:pseudonymID"; they still decrypt, and are
+// converted to envelopes when re-encrypted by a key rotation.
+
+// Envelope format versions
+const (
+	EnvelopeFormatV1 byte = 1
+)
+
+// Envelope algorithm IDs
+const (
+	// AlgorithmAES256GCM is AES-256-GCM under the SHA-256 of the role key.
+	// It records no key epoch; mappings are no longer written with it.
+	AlgorithmAES256GCM byte = 1
+	// AlgorithmAES256GCMEpoch is AES-256-GCM under the HKDF-SHA256 epoch key
+	// of the role key
+	AlgorithmAES256GCMEpoch byte = 2
+)
+
+const (
+	envelopeMagic      = "HPIM"
+	envelopeHeaderSize = 19
+	gcmNonceSize       = 12
+)
+
+var (
+	// ErrMalformedEnvelope is returned for a ciphertext that is not a valid envelope
+	ErrMalformedEnvelope = errors.New("malformed identity mapping envelope")
+	// ErrMappingMismatch is returned when a mapping was written for another pseudonym or key version
+	ErrMappingMismatch = errors.New("identity mapping does not belong to this pseudonym and key version")
+)
+
+// domainIDs are the envelope IDs of the cryptographic domains. IDs are
+// persisted, so existing ones must never change.
+var domainIDs = map[string]byte{
+	DOMAIN_USER_PSEUDONYMS:   1,
+	DOMAIN_USER_CORRELATION:  2,
+	DOMAIN_MOD_CORRELATION:   3,
+	DOMAIN_ADMIN_CORRELATION: 4,
+	DOMAIN_LEGAL_CORRELATION: 5,
+}
+
+// IdentityMapping is a decrypted identity mapping
+type IdentityMapping struct {
+	Fingerprint string
+	PseudonymID string
+	KeyVersion  int    // Zero for legacy mappings, which do not record it
+	Domain      string // Empty for legacy mappings
+	KeyEpoch    int64
+	HasEpoch    bool // False for mappings written before key epochs
+	Legacy      bool // Written before envelopes; not authenticated against its key version
+}
+
+// envelopeHeader is the authenticated header of an envelope
+type envelopeHeader struct {
+	format     byte
+	algorithm  byte
+	keyVersion uint32
+	domainID   byte
+	keyEpoch   int64
+}
+
+// newEnvelopeHeader returns the header for a mapping written now
+func newEnvelopeHeader(keyVersion int, domain string, keyEpoch int64) (envelopeHeader, error) {
+	domainID, ok := domainIDs[domain]
+	if !ok {
+		return envelopeHeader{}, fmt.Errorf("unknown domain: %s", domain)
+	}
+	if keyVersion <= 0 {
+		return envelopeHeader{}, fmt.Errorf("invalid key version: %d", keyVersion)
+	}
+	return envelopeHeader{
+		format:     EnvelopeFormatV1,
+		algorithm:  AlgorithmAES256GCMEpoch,
+		keyVersion: uint32(keyVersion),
+		domainID:   domainID,
+		keyEpoch:   keyEpoch,
+	}, nil
+}
+
+// marshal encodes the header
+func (h envelopeHeader) marshal() []byte {
+	buf := make([]byte, envelopeHeaderSize)
+	copy(buf, envelopeMagic)
+	buf[4] = h.format
+	buf[5] = h.algorithm
+	binary.BigEndian.PutUint32(buf[6:10], h.keyVersion)
+	buf[10] = h.domainID
+	binary.BigEndian.PutUint64(buf[11:19], uint64(h.keyEpoch))
+	return buf
+}
+
+// domain returns the name of the header's domain
+func (h envelopeHeader) domain() string {
+	for domain, id := range domainIDs {
+		if id == h.domainID {
+			return domain
+		}
+	}
+	return ""
+}
+
+// parseEnvelopeHeader decodes the header of an envelope
+func parseEnvelopeHeader(envelope []byte) (envelopeHeader, error) {
+	if len(envelope) < envelopeHeaderSize || !bytes.HasPrefix(envelope, []byte(envelopeMagic)) {
+		return envelopeHeader{}, ErrMalformedEnvelope
+	}
+	h := envelopeHeader{
+		format:     envelope[4],
+		algorithm:  envelope[5],
+		keyVersion: binary.BigEndian.Uint32(envelope[6:10]),
+		domainID:   envelope[10],
+		keyEpoch:   int64(binary.BigEndian.Uint64(envelope[11:19])),
+	}
+	if h.format != EnvelopeFormatV1 {
+		return envelopeHeader{}, fmt.Errorf("%w: unsupported format version %d", ErrMalformedEnvelope, h.format)
+	}
+	if h.algorithm != AlgorithmAES256GCM && h.algorithm != AlgorithmAES256GCMEpoch {
+		return envelopeHeader{}, fmt.Errorf("%w: unsupported algorithm %d", ErrMalformedEnvelope, h.algorithm)
+	}
+	if h.domain() == "" {
+		return envelopeHeader{}, fmt.Errorf("%w: unknown domain %d", ErrMalformedEnvelope, h.domainID)
+	}
+	return h, nil
+}
+
+// mappingAAD returns the additional data authenticated with a mapping
+func mappingAAD(header []byte, pseudonymID string) []byte {
+	aad := make([]byte, 0, len(header)+len(pseudonymID))
+	aad = append(aad, header...)
+	return append(aad, pseudonymID...)
+}
+
+// hasEpoch reports whether the header's algorithm uses key epochs
+func (h envelopeHeader) hasEpoch() bool {
+	return h.algorithm == AlgorithmAES256GCMEpoch
+}
+
+// cipher returns the AEAD for the header's algorithm and a role key
+func (h envelopeHeader) cipher(roleKey []byte) (cipher.AEAD, error) {
+	if !h.hasEpoch() {
+		return mappingCipher(roleKey)
+	}
+	key, err := epochMappingKey(roleKey, h.keyEpoch)
+	if err != nil {
+		return nil, err
+	}
+	return gcmCipher(key)
+}
+
+// mappingCipher returns the AEAD for mappings written without key epochs
+func mappingCipher(roleKey []byte) (cipher.AEAD, error) {
+	key := sha256.Sum256(roleKey)
+	return gcmCipher(key[:])
+}
+
+// gcmCipher returns AES-GCM under a 32-byte key
+func gcmCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealMapping encrypts a fingerprint into an envelope bound to a pseudonym
+func sealMapping(header envelopeHeader, fingerprint, pseudonymID string, roleKey []byte) ([]byte, error) {
+	gcm, err := header.cipher(roleKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	envelope := header.marshal()
+	aad := mappingAAD(envelope, pseudonymID)
+	envelope = append(envelope, nonce...)
+	return gcm.Seal(envelope, nonce, []byte(fingerprint), aad), nil
+}
+
+// openMapping decrypts an envelope written for a pseudonym and key version
+func openMapping(envelope []byte, pseudonymID string, keyVersion int, roleKey []byte) (*IdentityMapping, error) {
+	header, err := parseEnvelopeHeader(envelope)
+	if err != nil {
+		return nil, err
+	}
+	if int64(header.keyVersion) != int64(keyVersion) {
+		return nil, ErrMappingMismatch
+	}
+	if len(envelope) < envelopeHeaderSize+gcmNonceSize {
+		return nil, ErrMalformedEnvelope
+	}
+
+	gcm, err := header.cipher(roleKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := envelope[envelopeHeaderSize : envelopeHeaderSize+gcmNonceSize]
+	ciphertext := envelope[envelopeHeaderSize+gcmNonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, mappingAAD(envelope[:envelopeHeaderSize], pseudonymID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdentityMapping{
+		Fingerprint: string(plaintext),
+		PseudonymID: pseudonymID,
+		KeyVersion:  int(header.keyVersion),
+		Domain:      header.domain(),
+		KeyEpoch:    header.keyEpoch,
+		HasEpoch:    header.hasEpoch(),
+	}, nil
+}
+
+// openLegacyMapping decrypts a mapping written before envelopes. Its
+// plaintext names the pseudonym, which must be the expected one.
+func openLegacyMapping(encryptedMapping []byte, pseudonymID string, roleKey []byte) (*IdentityMapping, error) {
+	gcm, err := mappingCipher(roleKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(encryptedMapping) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := encryptedMapping[:nonceSize], encryptedMapping[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, mappedPseudonymID, ok := strings.Cut(string(plaintext), ":")
+	if !ok || fingerprint == "" {
+		return nil, ErrMalformedEnvelope
+	}
+	if mappedPseudonymID != pseudonymID {
+		return nil, ErrMappingMismatch
+	}
+
+	return &IdentityMapping{
+		Fingerprint: fingerprint,
+		PseudonymID: pseudonymID,
+		Legacy:      true,
+	}, nil
+}
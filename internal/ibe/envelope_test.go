@@ -0,0 +1,126 @@
+package ibe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnvelope_BindsPseudonymAndKeyVersion(t *testing.T) {
+	ibe := NewIBESystem()
+	roleKey := []byte("test_role_key")
+
+	epoch := CurrentEpoch()
+	encrypted, err := ibe.EncryptIdentityWithRoleKey("alice@example.com", "pseudonym_1", "moderator", 3, epoch, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt identity: %v", err)
+	}
+
+	mapping, err := ibe.DecryptIdentity(encrypted, "pseudonym_1", 3, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt identity: %v", err)
+	}
+	want := IdentityMapping{
+		Fingerprint: ibe.GenerateFingerprint("alice@example.com"),
+		PseudonymID: "pseudonym_1",
+		KeyVersion:  3,
+		Domain:      DOMAIN_MOD_CORRELATION,
+		KeyEpoch:    epoch,
+		HasEpoch:    true,
+	}
+	if *mapping != want {
+		t.Errorf("Decrypted mapping mismatch: got %+v, want %+v", *mapping, want)
+	}
+
+	// A ciphertext copied onto another pseudonym's row does not decrypt
+	if _, err := ibe.DecryptIdentity(encrypted, "pseudonym_2", 3, roleKey); err == nil {
+		t.Error("Mapping should not decrypt for another pseudonym")
+	}
+
+	// Nor does one recorded under another key version
+	if _, err := ibe.DecryptIdentity(encrypted, "pseudonym_1", 2, roleKey); !errors.Is(err, ErrMappingMismatch) {
+		t.Errorf("Expected ErrMappingMismatch for another key version, got %v", err)
+	}
+}
+
+func TestEnvelope_RejectsTamperedHeader(t *testing.T) {
+	ibe := NewIBESystem()
+	roleKey := []byte("test_role_key")
+
+	encrypted, err := ibe.EncryptIdentityWithRoleKey("alice@example.com", "pseudonym_1", "user", 1, CurrentEpoch(), roleKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt identity: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		value  byte
+	}{
+		{"format version", 4, 2},
+		{"algorithm", 5, AlgorithmAES256GCM},
+		{"domain", 10, domainIDs[DOMAIN_ADMIN_CORRELATION]},
+		{"key epoch", 18, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := append([]byte(nil), encrypted...)
+			tampered[tt.offset] = tt.value
+			if _, err := ibe.DecryptIdentity(tampered, "pseudonym_1", 1, roleKey); err == nil {
+				t.Errorf("Mapping with tampered %s should not decrypt", tt.name)
+			}
+		})
+	}
+}
+
+func TestEnvelope_LegacyMapping(t *testing.T) {
+	ibe := NewIBESystem()
+	roleKey := []byte("test_role_key")
+	fingerprint := ibe.GenerateFingerprint("alice@example.com")
+
+	// Mappings written before envelopes are bare nonce||ciphertext
+	key := sha256.Sum256(roleKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Failed to create GCM: %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatalf("Failed to generate nonce: %v", err)
+	}
+	legacy := gcm.Seal(nonce, nonce, []byte(fingerprint+":pseudonym_1"), nil)
+
+	mapping, err := ibe.DecryptIdentity(legacy, "pseudonym_1", 1, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt legacy mapping: %v", err)
+	}
+	if !mapping.Legacy || mapping.Fingerprint != fingerprint || mapping.PseudonymID != "pseudonym_1" {
+		t.Errorf("Unexpected legacy mapping: %+v", mapping)
+	}
+
+	if _, err := ibe.DecryptIdentity(legacy, "pseudonym_2", 1, roleKey); !errors.Is(err, ErrMappingMismatch) {
+		t.Errorf("Expected ErrMappingMismatch for another pseudonym, got %v", err)
+	}
+
+	// Re-encryption converts it to an envelope in the epoch it was written
+	writtenAt := time.Now().AddDate(0, -2, 0)
+	reencrypted, err := ibe.ReencryptIdentity(legacy, "pseudonym_1", 1, roleKey, 2, roleKey, writtenAt)
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt legacy mapping: %v", err)
+	}
+	mapping, err = ibe.DecryptIdentity(reencrypted, "pseudonym_1", 2, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt re-encrypted mapping: %v", err)
+	}
+	if mapping.Legacy || mapping.Fingerprint != fingerprint || mapping.KeyVersion != 2 || mapping.KeyEpoch != EpochAt(writtenAt, EpochDuration) {
+		t.Errorf("Unexpected re-encrypted mapping: %+v", mapping)
+	}
+}
@@ -0,0 +1,92 @@
+package ibe
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key epochs
+//
+// Time-bounded keys are addressed by an epoch: the index of a fixed-length
+// window since the Unix epoch. Keys for an epoch are derived with HKDF from
+// the domain master or role key, so any epoch can be re-derived later.
+//
+// Identity mappings are encrypted under the epoch key of the day they were
+// written, and record that epoch in their envelope. Epochs separate the keys
+// of mappings written on different days; they do not limit which mappings a
+// role may read. A role key derives the mapping key of every epoch, and a
+// mapping is written once, when its pseudonym is created, so its epoch says
+// nothing about how recently the pseudonym was active. A role's time window
+// bounds the activity a correlation reaches instead; see TimeWindow.
+
+// EpochDuration is the length of the epochs identity mappings are encrypted in
+const EpochDuration = 24 * time.Hour
+
+// hkdfLabel prefixes the info of every key derived by the IBE system
+const hkdfLabel = "hashpost/ibe/v1/"
+
+// EpochAt returns the epoch of the given window length that contains t
+func EpochAt(t time.Time, window time.Duration) int64 {
+	return t.Unix() / int64(window/time.Second)
+}
+
+// EpochStart returns when an epoch of the given window length begins
+func EpochStart(epoch int64, window time.Duration) time.Time {
+	return time.Unix(epoch*int64(window/time.Second), 0).UTC()
+}
+
+// CurrentEpoch returns the epoch new identity mappings are encrypted in
+func CurrentEpoch() int64 {
+	return EpochAt(time.Now(), EpochDuration)
+}
+
+// TimeWindow is how far back a role's correlations reach. It is parsed from
+// role_definitions.time_window and enforced by the correlation policy against
+// the activity of a pseudonym, not against the epoch of its mapping.
+type TimeWindow struct {
+	Unlimited bool          // Any activity
+	Lookback  time.Duration // Activity in the period before now; zero for none
+}
+
+// ParseTimeWindow parses a role time window: "unlimited", "<N>_days", or
+// "none" (or empty) for no lookback
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	switch s {
+	case "unlimited":
+		return TimeWindow{Unlimited: true}, nil
+	case "", "none":
+		return TimeWindow{}, nil
+	}
+
+	days, ok := strings.CutSuffix(s, "_days")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s", s)
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s", s)
+	}
+	return TimeWindow{Lookback: time.Duration(n) * 24 * time.Hour}, nil
+}
+
+// deriveKey derives a 32-byte key from a secret with HKDF-SHA256. The info
+// is a label and length-prefixed fields, so distinct inputs never collide.
+func deriveKey(secret []byte, label string, fields ...string) ([]byte, error) {
+	info := []byte(hkdfLabel + label)
+	for _, field := range fields {
+		info = binary.AppendUvarint(info, uint64(len(field)))
+		info = append(info, field...)
+	}
+	return hkdf.Key(sha256.New, secret, nil, string(info), 32)
+}
+
+// epochMappingKey derives the key identity mappings of an epoch are
+// encrypted under from a role key
+func epochMappingKey(roleKey []byte, epoch int64) ([]byte, error) {
+	return deriveKey(roleKey, "mapping-key", strconv.FormatInt(epoch, 10))
+}
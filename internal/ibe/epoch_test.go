@@ -0,0 +1,82 @@
+package ibe
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TimeWindow
+		wantErr bool
+	}{
+		{"unlimited", TimeWindow{Unlimited: true}, false},
+		{"none", TimeWindow{}, false},
+		{"", TimeWindow{}, false},
+		{"30_days", TimeWindow{Lookback: 30 * 24 * time.Hour}, false},
+		{"90_days", TimeWindow{Lookback: 90 * 24 * time.Hour}, false},
+		{"0_days", TimeWindow{}, true},
+		{"30_weeks", TimeWindow{}, true},
+		{"forever", TimeWindow{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeWindow(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimeWindow(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTimeWindow(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIBESystem_KeysAreEpochAddressable(t *testing.T) {
+	ibe := NewIBESystem()
+
+	// Role keys depend on their expiration, not on when they are generated
+	expiration := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if bytes.Equal(ibe.GenerateRoleKey("moderator", "correlation", expiration), ibe.GenerateRoleKey("moderator", "correlation", expiration.Add(time.Hour))) {
+		t.Error("Role keys with different expirations should differ")
+	}
+
+	// Correlation keys of an explicit epoch can be derived at any time
+	epoch := EpochAt(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 30*24*time.Hour)
+	key1 := ibe.GenerateEpochKey("moderator", "correlation", 30*24*time.Hour, epoch)
+	key2 := ibe.GenerateEpochKey("moderator", "correlation", 30*24*time.Hour, epoch)
+	if len(key1) != 32 || !bytes.Equal(key1, key2) {
+		t.Error("The same epoch should always derive the same key")
+	}
+	if bytes.Equal(key1, ibe.GenerateEpochKey("moderator", "correlation", 30*24*time.Hour, epoch+1)) {
+		t.Error("Different epochs should derive different keys")
+	}
+	if bytes.Equal(key1, ibe.GenerateEpochKey("moderator", "correlation", 7*24*time.Hour, epoch)) {
+		t.Error("Different window lengths should derive different keys")
+	}
+}
+
+func TestIBESystem_DecryptIdentityOfPastEpoch(t *testing.T) {
+	ibe := NewIBESystem()
+	roleKey := []byte("test_role_key")
+
+	// A mapping written 45 days ago still decrypts with the role key; time
+	// windows are enforced on activity by the correlation policy
+	epoch := EpochAt(time.Now().AddDate(0, 0, -45), EpochDuration)
+	encrypted, err := ibe.EncryptIdentityWithRoleKey("alice@example.com", "pseudonym_1", "moderator", 1, epoch, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt identity: %v", err)
+	}
+
+	mapping, err := ibe.DecryptIdentity(encrypted, "pseudonym_1", 1, roleKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt identity: %v", err)
+	}
+	if mapping.KeyEpoch != epoch || !mapping.HasEpoch {
+		t.Errorf("Expected key epoch %d, got %+v", epoch, mapping)
+	}
+	if mapping.Fingerprint != ibe.GenerateFingerprint("alice@example.com") {
+		t.Errorf("Expected the fingerprint of the identity, got %q", mapping.Fingerprint)
+	}
+}
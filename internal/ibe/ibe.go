@@ -1,17 +1,20 @@
 package ibe
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
+	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/rs/zerolog/log"
 )
 
@@ -55,6 +58,9 @@ func NewSeparatedIBESystem(domainMasters map[string][]byte, keyVersion int, salt
 // getDomainMaster returns the master key for a specific domain
 func (ibe *SeparatedIBESystem) getDomainMaster(domain string) ([]byte, error) {
 	master, exists := ibe.domainMasters[domain]
+	if !exists && IsSplittableDomain(domain) {
+		return nil, fmt.Errorf("%w: %s", ErrDomainLocked, domain)
+	}
 	if !exists {
 		return nil, fmt.Errorf("no master key found for domain: %s", domain)
 	}
@@ -90,8 +96,10 @@ func (ibe *SeparatedIBESystem) GeneratePseudonym(userID int64, context string, v
 	}
 }
 
-// GenerateCorrelationKey creates a time-bounded correlation key for a specific role and scope
-func (ibe *SeparatedIBESystem) GenerateCorrelationKey(role, scope string, timeWindow time.Duration) []byte {
+// GenerateCorrelationKey derives the correlation key of a role and scope for
+// an epoch of timeWindow-long windows (see EpochAt). The same epoch always
+// yields the same key, whenever it is derived.
+func (ibe *SeparatedIBESystem) GenerateCorrelationKey(role, scope string, timeWindow time.Duration, epoch int64) []byte {
 	// Select appropriate domain based on role
 	domain := selectDomain(role)
 	domainMaster, err := ibe.getDomainMaster(domain)
@@ -100,17 +108,32 @@ func (ibe *SeparatedIBESystem) GenerateCorrelationKey(role, scope string, timeWi
 		return nil
 	}
 
-	// Include time epoch in key derivation for forward secrecy
-	epoch := time.Now().Truncate(timeWindow).Unix()
+	// The window length is part of the derivation so that different windows
+	// produce different keys for overlapping epochs
+	key, err := deriveKey(domainMaster, "correlation-key", role, scope,
+		strconv.FormatInt(int64(timeWindow), 10), strconv.FormatInt(epoch, 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to derive correlation key")
+		return nil
+	}
+	return key
+}
 
-	combined := append(domainMaster, []byte(role)...)
-	combined = append(combined, []byte(scope)...)
-	combined = append(combined, []byte(fmt.Sprintf("%d", epoch))...)
-	// Include time window in key derivation to ensure different windows produce different keys
-	combined = append(combined, []byte(fmt.Sprintf("%d", timeWindow.Nanoseconds()))...)
+// GenerateRoleKey derives the key of a role and scope that expires at the
+// given time
+func (ibe *SeparatedIBESystem) GenerateRoleKey(role, scope string, expiration time.Time) []byte {
+	domainMaster, err := ibe.getDomainMaster(selectDomain(role))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get domain master")
+		return nil
+	}
 
-	hash := sha256.Sum256(combined)
-	return hash[:]
+	key, err := deriveKey(domainMaster, "role-key", role, scope, strconv.FormatInt(expiration.Unix(), 10))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to derive role key")
+		return nil
+	}
+	return key
 }
 
 // selectDomain maps roles to appropriate cryptographic domains
@@ -131,32 +154,19 @@ func selectDomain(role string) string {
 
 // EncryptIdentityWithDomain encrypts identity mapping using domain-specific keys
 func (ibe *SeparatedIBESystem) EncryptIdentityWithDomain(realIdentity, pseudonymID string, domain string, adminKey []byte) ([]byte, error) {
-	// Create the mapping data with fingerprint instead of real identity
-	fingerprint := ibe.GenerateFingerprint(realIdentity)
-	mapping := fmt.Sprintf("%s:%s", fingerprint, pseudonymID)
-
-	// Use admin key to derive encryption key
-	key := sha256.Sum256(adminKey)
-
-	// Create AES cipher
-	block, err := aes.NewCipher(key[:])
-	if err != nil {
-		return nil, err
-	}
+	return ibe.EncryptIdentityWithKeyVersion(realIdentity, pseudonymID, domain, ibe.keyVersion, CurrentEpoch(), adminKey)
+}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+// EncryptIdentityWithKeyVersion encrypts an identity mapping under the epoch
+// key of a role key of a domain and key version. The mapping stores the
+// fingerprint instead of the real identity, and is bound to the pseudonym and
+// key version.
+func (ibe *SeparatedIBESystem) EncryptIdentityWithKeyVersion(realIdentity, pseudonymID string, domain string, keyVersion int, epoch int64, adminKey []byte) ([]byte, error) {
+	header, err := newEnvelopeHeader(keyVersion, domain, epoch)
 	if err != nil {
 		return nil, err
 	}
-
-	// Generate nonce
-	nonce := make([]byte, gcm.NonceSize())
-	rand.Read(nonce)
-
-	// Encrypt
-	ciphertext := gcm.Seal(nonce, nonce, []byte(mapping), nil)
-	return ciphertext, nil
+	return sealMapping(header, ibe.GenerateFingerprint(realIdentity), pseudonymID, adminKey)
 }
 
 // GenerateFingerprint creates a deterministic fingerprint from a real identity
@@ -164,18 +174,7 @@ func (ibe *SeparatedIBESystem) GenerateFingerprint(realIdentity string) string {
 	// Combine real identity with the configurable salt for fingerprint generation
 	combined := append([]byte(realIdentity), ibe.salt...)
 	hash := sha256.Sum256(combined)
-	fingerprint := hex.EncodeToString(hash[:16]) // Use first 16 bytes for fingerprint
-
-	// Debug logging
-	log.Info().
-		Str("real_identity", realIdentity).
-		Str("salt", string(ibe.salt)).
-		Str("salt_hex", hex.EncodeToString(ibe.salt)).
-		Str("combined_hex", hex.EncodeToString(combined)).
-		Str("fingerprint", fingerprint).
-		Msg("Generated fingerprint")
-
-	return fingerprint
+	return hex.EncodeToString(hash[:16]) // Use first 16 bytes for fingerprint
 }
 
 // NewIBESystem creates a new IBE system with backward compatibility
@@ -234,13 +233,15 @@ func NewIBESystemWithOptions(opts IBEOptions) *IBESystem {
 // GetMasterSecret returns a copy of the master secret (for backward compatibility)
 // Note: This is deprecated - use GetDomainMasters instead
 func (ibe *IBESystem) GetMasterSecret() []byte {
-	// For backward compatibility, return the first domain master
-	for _, master := range ibe.separated.domainMasters {
-		secret := make([]byte, len(master))
-		copy(secret, master)
-		return secret
+	// For backward compatibility, return the user pseudonyms master. It must
+	// never be a correlation master, least of all one split into shares.
+	master, ok := ibe.separated.domainMasters[DOMAIN_USER_PSEUDONYMS]
+	if !ok {
+		return nil
 	}
-	return nil
+	secret := make([]byte, len(master))
+	copy(secret, master)
+	return secret
 }
 
 // SetMasterSecret sets the master secret (for backward compatibility)
@@ -331,48 +332,38 @@ func (ibe *IBESystem) EncryptIdentity(realIdentity, pseudonymID string, adminKey
 	return ibe.separated.EncryptIdentityWithDomain(realIdentity, pseudonymID, domain, adminKey)
 }
 
-// DecryptIdentity decrypts the mapping using admin key
-func (ibe *IBESystem) DecryptIdentity(encryptedMapping []byte, adminKey []byte) (string, string, error) {
-	// Use admin key to derive decryption key
-	key := sha256.Sum256(adminKey)
-
-	// Create AES cipher
-	block, err := aes.NewCipher(key[:])
-	if err != nil {
-		return "", "", err
-	}
-
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", "", err
-	}
+// EncryptIdentityWithRoleKey encrypts the mapping between real identity and
+// pseudonym under the epoch key of a role key of the given role and key
+// version. epoch is usually CurrentEpoch().
+func (ibe *IBESystem) EncryptIdentityWithRoleKey(realIdentity, pseudonymID, role string, keyVersion int, epoch int64, roleKey []byte) ([]byte, error) {
+	return ibe.separated.EncryptIdentityWithKeyVersion(realIdentity, pseudonymID, selectDomain(role), keyVersion, epoch, roleKey)
+}
 
-	// Extract nonce
-	nonceSize := gcm.NonceSize()
-	if len(encryptedMapping) < nonceSize {
-		return "", "", fmt.Errorf("ciphertext too short")
+// DecryptIdentity decrypts the mapping of a pseudonym using admin key,
+// whatever its key epoch. keyVersion is the key version recorded with the
+// mapping; a mapping written for another pseudonym or key version does not
+// decrypt.
+func (ibe *IBESystem) DecryptIdentity(encryptedMapping []byte, pseudonymID string, keyVersion int, adminKey []byte) (*IdentityMapping, error) {
+	if !bytes.HasPrefix(encryptedMapping, []byte(envelopeMagic)) {
+		return openLegacyMapping(encryptedMapping, pseudonymID, adminKey)
 	}
 
-	nonce, ciphertext := encryptedMapping[:nonceSize], encryptedMapping[nonceSize:]
-
-	// Decrypt
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	mapping, err := openMapping(encryptedMapping, pseudonymID, keyVersion, adminKey)
 	if err != nil {
-		return "", "", err
+		// A legacy mapping whose nonce happens to start with the magic
+		if legacy, legacyErr := openLegacyMapping(encryptedMapping, pseudonymID, adminKey); legacyErr == nil {
+			return legacy, nil
+		}
+		return nil, err
 	}
-
-	// Parse mapping
-	mapping := string(plaintext)
-	// In a real implementation, you'd parse this more carefully
-	return mapping, "", nil
+	return mapping, nil
 }
 
-// GenerateRoleKey creates a role-based key for administrative access (backward compatible)
+// GenerateRoleKey creates a role-based key for administrative access. The
+// expiration is part of the derivation, so the key is the same whenever it
+// is generated.
 func (ibe *IBESystem) GenerateRoleKey(role string, scope string, expiration time.Time) []byte {
-	// Route to appropriate domain with time-bounded derivation
-	timeWindow := time.Hour * 24 * 30 // 30-day windows for backward compatibility
-	return ibe.separated.GenerateCorrelationKey(role, scope, timeWindow)
+	return ibe.separated.GenerateRoleKey(role, scope, expiration)
 }
 
 // GenerateTestRoleKey creates a role-based key with a fixed expiration time for testing
@@ -388,15 +379,7 @@ func (ibe *IBESystem) ValidateRoleKey(roleKey []byte, role string, scope string,
 	if !time.Now().Before(expiration) {
 		return false
 	}
-	if len(roleKey) != len(expectedKey) {
-		return false
-	}
-	for i := range roleKey {
-		if roleKey[i] != expectedKey[i] {
-			return false
-		}
-	}
-	return true
+	return expectedKey != nil && subtle.ConstantTimeCompare(roleKey, expectedKey) == 1
 }
 
 // Enhanced API methods for new functionality
@@ -406,9 +389,16 @@ func (ibe *IBESystem) CreateEnhancedPseudonym(userID int64, context string) stri
 	return ibe.separated.GeneratePseudonym(userID, context, 2) // Enhanced version
 }
 
-// GenerateTimeBoundedKey creates a time-bounded correlation key
+// GenerateTimeBoundedKey creates the correlation key of the current window
+// of the given duration
 func (ibe *IBESystem) GenerateTimeBoundedKey(role, scope string, duration time.Duration) []byte {
-	return ibe.separated.GenerateCorrelationKey(role, scope, duration)
+	return ibe.GenerateEpochKey(role, scope, duration, EpochAt(time.Now(), duration))
+}
+
+// GenerateEpochKey creates the correlation key of an epoch of windows of the
+// given duration
+func (ibe *IBESystem) GenerateEpochKey(role, scope string, duration time.Duration, epoch int64) []byte {
+	return ibe.separated.GenerateCorrelationKey(role, scope, duration, epoch)
 }
 
 // NewIBESystemFromConfig creates a new IBE system from configuration
@@ -418,19 +408,18 @@ func NewIBESystemFromConfig(domainKeysDir string, keyVersion int, salt string) (
 		Salt:       salt,
 	}
 
-	// Try to load domain masters from directory if provided
+	// Load domain masters from directory if provided. After a key rotation
+	// the directory holds several versions; use the newest.
 	if domainKeysDir != "" {
-		domainMasters, err := LoadDomainMastersFromDir(domainKeysDir)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load domain masters from %s: %w", domainKeysDir, err)
-		}
-		opts.DomainMasters = domainMasters
+		return NewIBESystemFromProvider(context.Background(), NewDirProvider(domainKeysDir, keyVersion), salt)
 	}
 
 	return NewIBESystemWithOptions(opts), nil
 }
 
-// LoadDomainMastersFromDir loads domain masters from a directory
+// LoadDomainMastersFromDir loads domain masters from a directory. The masters
+// of splittable domains may be missing once they are split into shares; those
+// domains are left locked (see UnlockDomainMasters).
 func LoadDomainMastersFromDir(dir string) (map[string][]byte, error) {
 	domainMasters := make(map[string][]byte)
 
@@ -445,6 +434,10 @@ func LoadDomainMastersFromDir(dir string) (map[string][]byte, error) {
 	for _, domain := range domains {
 		keyPath := filepath.Join(dir, fmt.Sprintf("%s.key", domain))
 		master, err := LoadMasterSecretFromFile(keyPath)
+		if errors.Is(err, os.ErrNotExist) && IsSplittableDomain(domain) {
+			log.Info().Str("domain", domain).Msg("Domain master not on disk; domain is locked until it is unlocked from shares")
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to load domain master for %s: %w", domain, err)
 		}
@@ -460,7 +453,11 @@ func LoadMasterSecretFromFile(path string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseMaster(data)
+}
 
+// parseMaster parses a hex-encoded master secret
+func parseMaster(data []byte) ([]byte, error) {
 	// Expect hex-encoded 32-byte secret
 	if len(data) != 64 { // 32 bytes = 64 hex chars
 		return nil, fmt.Errorf("master secret file must contain exactly 64 hex characters")
@@ -477,12 +474,13 @@ func LoadMasterSecretFromFile(path string) ([]byte, error) {
 // SaveMasterSecretToFile saves the master secret to a file (backward compatibility)
 // Note: This is deprecated - use SaveDomainMastersToDir instead
 func (ibe *IBESystem) SaveMasterSecretToFile(path string) error {
-	// For backward compatibility, save the first domain master
-	for _, master := range ibe.separated.domainMasters {
-		hexSecret := hex.EncodeToString(master)
-		return os.WriteFile(path, []byte(hexSecret), 0600)
+	// For backward compatibility, save the user pseudonyms master
+	master, ok := ibe.separated.domainMasters[DOMAIN_USER_PSEUDONYMS]
+	if !ok {
+		return fmt.Errorf("no domain masters available")
 	}
-	return fmt.Errorf("no domain masters available")
+	hexSecret := hex.EncodeToString(master)
+	return os.WriteFile(path, []byte(hexSecret), 0600)
 }
 
 // SaveDomainMastersToDir saves all domain masters to a directory
@@ -502,23 +500,18 @@ func (ibe *IBESystem) SaveDomainMastersToDir(dir string) error {
 	return nil
 }
 
-// NewIBESystemFromEnv creates a new IBE system from environment variables
+// NewIBESystemFromEnv creates a new IBE system from the key provider
+// configured in environment variables
 func NewIBESystemFromEnv() *IBESystem {
-	masterKeyPath := os.Getenv("IBE_MASTER_KEY_PATH")
-	if masterKeyPath == "" {
-		masterKeyPath = "./keys/master.key"
-	}
-	keyVersion := 1
-	if v := os.Getenv("IBE_KEY_VERSION"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil {
-			keyVersion = parsed
-		}
+	cfg, err := config.Load()
+	if err != nil {
+		panic("Failed to create IBE system from environment: " + err.Error())
 	}
-	salt := os.Getenv("IBE_SALT")
-	if salt == "" {
-		salt = "fingerprint_salt_v1"
+	provider, err := NewKeyProviderFromConfig(&cfg.IBE)
+	if err != nil {
+		panic("Failed to create IBE system from environment: " + err.Error())
 	}
-	ibeSystem, err := NewIBESystemFromConfig(masterKeyPath, keyVersion, salt)
+	ibeSystem, err := NewIBESystemFromProvider(context.Background(), provider, cfg.IBE.Salt)
 	if err != nil {
 		panic("Failed to create IBE system from environment: " + err.Error())
 	}
@@ -2,8 +2,6 @@ package ibe
 
 import (
 	"bytes"
-	"fmt"
-	"strings"
 	"testing"
 	"time"
 )
@@ -46,15 +44,14 @@ func TestIBESystem_EncryptDecryptIdentity(t *testing.T) {
 	}
 
 	// Decrypt the mapping
-	decrypted, _, err := ibe.DecryptIdentity(encrypted, adminKey)
+	decrypted, err := ibe.DecryptIdentity(encrypted, pseudonymID, 1, adminKey)
 	if err != nil {
 		t.Fatalf("Failed to decrypt identity: %v", err)
 	}
 
 	expectedFingerprint := ibe.GenerateFingerprint(realIdentity)
-	expected := expectedFingerprint + ":" + pseudonymID
-	if decrypted != expected {
-		t.Errorf("Decrypted result doesn't match expected: got %s, want %s", decrypted, expected)
+	if decrypted.Fingerprint != expectedFingerprint || decrypted.PseudonymID != pseudonymID {
+		t.Errorf("Decrypted result doesn't match expected: got %+v, want %s for %s", decrypted, expectedFingerprint, pseudonymID)
 	}
 }
 
@@ -144,20 +141,18 @@ func TestIBESystem_MultiplePseudonymsPerUser(t *testing.T) {
 	}
 
 	// Decrypt and check that both map to the same fingerprint
-	mapping1, _, err1 := ibe.DecryptIdentity(enc1, adminKey)
-	mapping2, _, err2 := ibe.DecryptIdentity(enc2, adminKey)
+	mapping1, err1 := ibe.DecryptIdentity(enc1, pseudonym1, 1, adminKey)
+	mapping2, err2 := ibe.DecryptIdentity(enc2, pseudonym2, 1, adminKey)
 	if err1 != nil || err2 != nil {
 		t.Fatalf("Failed to decrypt identity mappings: %v, %v", err1, err2)
 	}
 
 	expectedFingerprint := ibe.GenerateFingerprint(realIdentity)
-	expected1 := expectedFingerprint + ":" + pseudonym1
-	expected2 := expectedFingerprint + ":" + pseudonym2
-	if mapping1 != expected1 {
-		t.Errorf("Decrypted mapping1 incorrect: got %s, want %s", mapping1, expected1)
+	if mapping1.Fingerprint != expectedFingerprint || mapping1.PseudonymID != pseudonym1 {
+		t.Errorf("Decrypted mapping1 incorrect: got %+v, want %s for %s", mapping1, expectedFingerprint, pseudonym1)
 	}
-	if mapping2 != expected2 {
-		t.Errorf("Decrypted mapping2 incorrect: got %s, want %s", mapping2, expected2)
+	if mapping2.Fingerprint != expectedFingerprint || mapping2.PseudonymID != pseudonym2 {
+		t.Errorf("Decrypted mapping2 incorrect: got %+v, want %s for %s", mapping2, expectedFingerprint, pseudonym2)
 	}
 }
 
@@ -206,26 +201,23 @@ func TestIBESystem_FingerprintBasedCorrelation(t *testing.T) {
 	}
 
 	// Decrypt mappings
-	mapping1, _, err1 := ibe.DecryptIdentity(enc1, adminKey)
-	mapping2, _, err2 := ibe.DecryptIdentity(enc2, adminKey)
+	mapping1, err1 := ibe.DecryptIdentity(enc1, pseudonym1, 1, adminKey)
+	mapping2, err2 := ibe.DecryptIdentity(enc2, pseudonym2, 1, adminKey)
 	if err1 != nil || err2 != nil {
 		t.Fatalf("Failed to decrypt identity mappings: %v, %v", err1, err2)
 	}
 
 	// Extract fingerprints from mappings
 	expectedFingerprint := ibe.GenerateFingerprint(realIdentity)
-	expected1 := expectedFingerprint + ":" + pseudonym1
-	expected2 := expectedFingerprint + ":" + pseudonym2
-
-	if mapping1 != expected1 {
-		t.Errorf("Decrypted mapping1 incorrect: got %s, want %s", mapping1, expected1)
+	if mapping1.PseudonymID != pseudonym1 {
+		t.Errorf("Decrypted mapping1 incorrect: got %+v, want pseudonym %s", mapping1, pseudonym1)
 	}
-	if mapping2 != expected2 {
-		t.Errorf("Decrypted mapping2 incorrect: got %s, want %s", mapping2, expected2)
+	if mapping2.PseudonymID != pseudonym2 {
+		t.Errorf("Decrypted mapping2 incorrect: got %+v, want pseudonym %s", mapping2, pseudonym2)
 	}
 
 	// Both mappings should contain the same fingerprint
-	if !strings.Contains(mapping1, expectedFingerprint) || !strings.Contains(mapping2, expectedFingerprint) {
+	if mapping1.Fingerprint != expectedFingerprint || mapping2.Fingerprint != expectedFingerprint {
 		t.Errorf("Both mappings should contain the same fingerprint: %s", expectedFingerprint)
 	}
 }
@@ -269,21 +261,20 @@ func TestIBESystem_TestConfiguration(t *testing.T) {
 		t.Fatalf("Failed to encrypt identity: %v", err)
 	}
 
-	decrypted, _, err := ibeSystem.DecryptIdentity(encrypted, roleKey1)
+	decrypted, err := ibeSystem.DecryptIdentity(encrypted, pseudonymID, 1, roleKey1)
 	if err != nil {
 		t.Fatalf("Failed to decrypt identity: %v", err)
 	}
 
-	expectedMapping := fmt.Sprintf("%s:%s", fingerprint1, pseudonymID)
-	if decrypted != expectedMapping {
-		t.Errorf("Decrypted mapping mismatch: expected %s, got %s", expectedMapping, decrypted)
+	if decrypted.Fingerprint != fingerprint1 {
+		t.Errorf("Decrypted mapping mismatch: expected %s, got %s", fingerprint1, decrypted.Fingerprint)
 	}
 
 	t.Logf("Test IBE system configuration verified:")
 	t.Logf("  Email: %s", email)
 	t.Logf("  Fingerprint: %s", fingerprint1)
 	t.Logf("  Role key length: %d", len(roleKey1))
-	t.Logf("  Decrypted mapping: %s", decrypted)
+	t.Logf("  Decrypted mapping: %+v", decrypted)
 }
 
 func TestIBESystem_DomainSeparation(t *testing.T) {
@@ -426,15 +417,16 @@ func TestIBESystem_DomainIsolation(t *testing.T) {
 	pseudonym := ibeSystem.separated.GeneratePseudonym(userID, "default", 1)
 
 	// Generate correlation keys for different roles
-	userCorrKey := ibeSystem.separated.GenerateCorrelationKey("user", "correlation", time.Hour)
-	modCorrKey := ibeSystem.separated.GenerateCorrelationKey("moderator", "correlation", time.Hour)
-	adminCorrKey := ibeSystem.separated.GenerateCorrelationKey("platform_admin", "correlation", time.Hour)
+	epoch := EpochAt(time.Now(), time.Hour)
+	userCorrKey := ibeSystem.separated.GenerateCorrelationKey("user", "correlation", time.Hour, epoch)
+	modCorrKey := ibeSystem.separated.GenerateCorrelationKey("moderator", "correlation", time.Hour, epoch)
+	adminCorrKey := ibeSystem.separated.GenerateCorrelationKey("platform_admin", "correlation", time.Hour, epoch)
 
 	// Test that pseudonym generation doesn't interfere with correlation keys
 	pseudonym2 := ibeSystem.separated.GeneratePseudonym(userID, "default", 1)
-	userCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("user", "correlation", time.Hour)
-	modCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("moderator", "correlation", time.Hour)
-	adminCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("platform_admin", "correlation", time.Hour)
+	userCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("user", "correlation", time.Hour, epoch)
+	modCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("moderator", "correlation", time.Hour, epoch)
+	adminCorrKey2 := ibeSystem.separated.GenerateCorrelationKey("platform_admin", "correlation", time.Hour, epoch)
 
 	// Pseudonyms should be consistent
 	if pseudonym != pseudonym2 {
@@ -496,12 +488,12 @@ func TestIBESystem_BackwardCompatibility(t *testing.T) {
 		t.Fatalf("Encryption failed: %v", err)
 	}
 
-	decrypted, _, err := ibeSystem.DecryptIdentity(encrypted, roleKey)
+	decrypted, err := ibeSystem.DecryptIdentity(encrypted, pseudonym, 1, roleKey)
 	if err != nil {
 		t.Fatalf("Decryption failed: %v", err)
 	}
 
-	if !strings.Contains(decrypted, fingerprint) {
+	if decrypted.Fingerprint != fingerprint {
 		t.Error("Decrypted data should contain the fingerprint")
 	}
 }
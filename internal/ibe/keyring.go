@@ -0,0 +1,168 @@
+package ibe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Domains returns every cryptographic domain that has its own master key
+func Domains() []string {
+	return []string{
+		DOMAIN_USER_PSEUDONYMS,
+		DOMAIN_USER_CORRELATION,
+		DOMAIN_MOD_CORRELATION,
+		DOMAIN_ADMIN_CORRELATION,
+		DOMAIN_LEGAL_CORRELATION,
+	}
+}
+
+// Key versions on disk
+//
+// The domain keys directory holds the masters of the version a deployment
+// started with directly, as <domain>.key files; their version is the
+// configured IBE_KEY_VERSION, called the base version here. Every rotation
+// writes the masters of its new version to a v<N> subdirectory. The highest
+// version present is the current one.
+
+// KeyVersionDir returns the directory holding the domain masters of a key version
+func KeyVersionDir(dir string, baseVersion, version int) string {
+	if version == baseVersion {
+		return dir
+	}
+	return filepath.Join(dir, fmt.Sprintf("v%d", version))
+}
+
+// ListKeyVersions returns the key versions whose domain masters are in dir,
+// oldest first
+func ListKeyVersions(dir string, baseVersion int) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domain keys directory: %w", err)
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "v") {
+			if version, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v")); err == nil && version != baseVersion {
+				versions = append(versions, version)
+			}
+			continue
+		}
+		if entry.Name() == DOMAIN_USER_PSEUDONYMS+".key" {
+			versions = append(versions, baseVersion)
+		}
+	}
+
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// LoadKeyVersion loads the domain masters of a key version
+func LoadKeyVersion(dir string, baseVersion, version int) (map[string][]byte, error) {
+	return LoadDomainMastersFromDir(KeyVersionDir(dir, baseVersion, version))
+}
+
+// SaveKeyVersion writes the domain masters of a new key version. It fails if
+// the version already exists, so that two rotations can never write
+// different masters for the same version.
+func SaveKeyVersion(dir string, baseVersion, version int, domainMasters map[string][]byte) error {
+	if version == baseVersion {
+		return fmt.Errorf("key version %d is the base version", version)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create domain keys directory: %w", err)
+	}
+
+	versionDir := KeyVersionDir(dir, baseVersion, version)
+	if err := os.Mkdir(versionDir, 0700); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %d", ErrKeyVersionExists, version)
+		}
+		return fmt.Errorf("failed to create key version directory: %w", err)
+	}
+
+	return saveDomainMasters(versionDir, domainMasters)
+}
+
+// RemoveKeyVersion deletes the domain masters of a key version
+func RemoveKeyVersion(dir string, baseVersion, version int) error {
+	if version != baseVersion {
+		if err := os.RemoveAll(KeyVersionDir(dir, baseVersion, version)); err != nil {
+			return fmt.Errorf("failed to remove key version %d: %w", version, err)
+		}
+		return nil
+	}
+
+	for _, domain := range Domains() {
+		keyPath := filepath.Join(dir, fmt.Sprintf("%s.key", domain))
+		if err := os.Remove(keyPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove domain master for %s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// GenerateDomainMasters generates a new master key for every domain
+func GenerateDomainMasters() (map[string][]byte, error) {
+	domainMasters := make(map[string][]byte)
+	for _, domain := range Domains() {
+		master := make([]byte, 32)
+		if _, err := rand.Read(master); err != nil {
+			return nil, fmt.Errorf("failed to generate domain master for %s: %w", domain, err)
+		}
+		domainMasters[domain] = master
+	}
+	return domainMasters, nil
+}
+
+// saveDomainMasters writes domain masters as hex files to a directory. Each
+// file is written under a temporary name first, so that a reader never sees
+// a partial key.
+func saveDomainMasters(dir string, domainMasters map[string][]byte) error {
+	for domain, master := range domainMasters {
+		keyPath := filepath.Join(dir, fmt.Sprintf("%s.key", domain))
+		tmpPath := keyPath + ".tmp"
+		if err := os.WriteFile(tmpPath, []byte(hex.EncodeToString(master)), 0600); err != nil {
+			return fmt.Errorf("failed to save domain master for %s: %w", domain, err)
+		}
+		if err := os.Rename(tmpPath, keyPath); err != nil {
+			return fmt.Errorf("failed to save domain master for %s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// ReencryptIdentity decrypts an identity mapping with one role key and
+// encrypts the same mapping with another of a new key version. The
+// fingerprint and key epoch are unchanged, so correlations stay the same.
+// Mappings without a key epoch are converted to the current envelope in the
+// epoch of writtenAt, when the mapping was created.
+func (ibe *IBESystem) ReencryptIdentity(encryptedMapping []byte, pseudonymID string, keyVersion int, oldKey []byte, newVersion int, newKey []byte, writtenAt time.Time) ([]byte, error) {
+	mapping, err := ibe.DecryptIdentity(encryptedMapping, pseudonymID, keyVersion, oldKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt identity mapping: %w", err)
+	}
+
+	// Legacy mappings were all written by EncryptIdentity
+	domain := mapping.Domain
+	if mapping.Legacy {
+		domain = DOMAIN_ADMIN_CORRELATION
+	}
+	epoch := mapping.KeyEpoch
+	if !mapping.HasEpoch {
+		epoch = EpochAt(writtenAt, EpochDuration)
+	}
+	header, err := newEnvelopeHeader(newVersion, domain, epoch)
+	if err != nil {
+		return nil, err
+	}
+	return sealMapping(header, mapping.Fingerprint, pseudonymID, newKey)
+}
@@ -0,0 +1,127 @@
+package ibe
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKeyVersions_SaveListLoadRemove(t *testing.T) {
+	dir := t.TempDir()
+
+	base := NewIBESystem()
+	if err := base.SaveDomainMastersToDir(dir); err != nil {
+		t.Fatalf("Failed to save base version: %v", err)
+	}
+
+	versions, err := ListKeyVersions(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to list key versions: %v", err)
+	}
+	if len(versions) != 1 || versions[0] != 1 {
+		t.Fatalf("Expected only the base version, got %v", versions)
+	}
+
+	masters, err := GenerateDomainMasters()
+	if err != nil {
+		t.Fatalf("Failed to generate domain masters: %v", err)
+	}
+	if err := SaveKeyVersion(dir, 1, 2, masters); err != nil {
+		t.Fatalf("Failed to save key version 2: %v", err)
+	}
+	if err := SaveKeyVersion(dir, 1, 2, masters); err == nil {
+		t.Error("Saving an existing key version should fail")
+	}
+
+	versions, err = ListKeyVersions(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to list key versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Fatalf("Expected versions [1 2], got %v", versions)
+	}
+
+	loaded, err := LoadKeyVersion(dir, 1, 2)
+	if err != nil {
+		t.Fatalf("Failed to load key version 2: %v", err)
+	}
+	for _, domain := range Domains() {
+		if !bytes.Equal(loaded[domain], masters[domain]) {
+			t.Errorf("Domain master for %s was not loaded as saved", domain)
+		}
+	}
+
+	// The IBE system uses the newest version on disk
+	system, err := NewIBESystemFromConfig(dir, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create IBE system: %v", err)
+	}
+	if system.GetKeyVersion() != 2 {
+		t.Errorf("Expected key version 2, got %d", system.GetKeyVersion())
+	}
+	if !bytes.Equal(system.GetDomainMasters()[DOMAIN_ADMIN_CORRELATION], masters[DOMAIN_ADMIN_CORRELATION]) {
+		t.Error("IBE system should use the domain masters of the newest version")
+	}
+
+	// Removing the base version leaves only the rotated one
+	if err := RemoveKeyVersion(dir, 1, 1); err != nil {
+		t.Fatalf("Failed to remove base version: %v", err)
+	}
+	versions, err = ListKeyVersions(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to list key versions: %v", err)
+	}
+	if len(versions) != 1 || versions[0] != 2 {
+		t.Fatalf("Expected versions [2], got %v", versions)
+	}
+
+	if err := RemoveKeyVersion(dir, 1, 2); err != nil {
+		t.Fatalf("Failed to remove key version 2: %v", err)
+	}
+	if _, err := os.Stat(KeyVersionDir(dir, 1, 2)); !os.IsNotExist(err) {
+		t.Error("Key version 2 directory should be removed")
+	}
+}
+
+func TestIBESystem_ReencryptIdentity(t *testing.T) {
+	ibe := NewIBESystem()
+
+	oldKey := []byte("old_role_key")
+	newKey := []byte("new_role_key")
+
+	encrypted, err := ibe.EncryptIdentity("alice@example.com", "pseudonym_1", oldKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt identity: %v", err)
+	}
+	original, err := ibe.DecryptIdentity(encrypted, "pseudonym_1", 1, oldKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt identity: %v", err)
+	}
+
+	reencrypted, err := ibe.ReencryptIdentity(encrypted, "pseudonym_1", 1, oldKey, 2, newKey, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt identity: %v", err)
+	}
+
+	if _, err := ibe.DecryptIdentity(reencrypted, "pseudonym_1", 2, oldKey); err == nil {
+		t.Error("Re-encrypted mapping should not decrypt with the old key")
+	}
+	if _, err := ibe.DecryptIdentity(reencrypted, "pseudonym_1", 1, newKey); err == nil {
+		t.Error("Re-encrypted mapping should not decrypt as the old key version")
+	}
+	mapping, err := ibe.DecryptIdentity(reencrypted, "pseudonym_1", 2, newKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt re-encrypted mapping: %v", err)
+	}
+	if mapping.Fingerprint != original.Fingerprint || mapping.Domain != original.Domain || mapping.KeyEpoch != original.KeyEpoch {
+		t.Errorf("Re-encryption changed the mapping: %+v != %+v", mapping, original)
+	}
+	if mapping.KeyVersion != 2 {
+		t.Errorf("Expected key version 2, got %d", mapping.KeyVersion)
+	}
+
+	if _, err := ibe.ReencryptIdentity(encrypted, "pseudonym_1", 1, []byte("wrong_key"), 2, newKey, time.Now()); err == nil {
+		t.Error("Re-encryption with the wrong key should fail")
+	}
+}
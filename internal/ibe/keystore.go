@@ -0,0 +1,395 @@
+package ibe
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/matt0x6f/hashpost/internal/password"
+	"golang.org/x/crypto/argon2"
+)
+
+// Keystore files
+//
+// A keystore file holds the domain masters of every key version, each one
+// wrapped (encrypted) with AES-256-GCM under a key encryption key. The KEK
+// is derived from a passphrase with argon2id, or held by a KMS that wraps
+// and unwraps on request. The key version and domain of each master are
+// authenticated with it, so wrapped masters cannot be swapped. A check value
+// wrapped under the same KEK detects a wrong passphrase or KMS key before
+// any master is used.
+
+// KeystoreFormatV1 is the format of keystore files
+const KeystoreFormatV1 = 1
+
+// Keystore wrapping types
+const (
+	keystoreWrappingPassphrase = "passphrase"
+	keystoreWrappingKMS        = "kms"
+)
+
+// keystoreCheck is the plaintext of the check value of a keystore
+const keystoreCheck = "hashpost ibe keystore"
+
+// PassphraseKDF describes the derivation of a key from a passphrase
+type PassphraseKDF struct {
+	KDF         string `json:"kdf"`
+	Salt        string `json:"salt"`
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// newPassphraseKDF returns argon2id parameters with a new salt
+func newPassphraseKDF() (PassphraseKDF, error) {
+	params := password.DefaultParams
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return PassphraseKDF{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return PassphraseKDF{
+		KDF:         shareKDF,
+		Salt:        hex.EncodeToString(salt),
+		Memory:      params.Memory,
+		Iterations:  params.Iterations,
+		Parallelism: params.Parallelism,
+	}, nil
+}
+
+// cipher derives an AES-256-GCM cipher from a passphrase
+func (k *PassphraseKDF) cipher(passphrase string) (cipher.AEAD, error) {
+	if k.KDF != shareKDF {
+		return nil, fmt.Errorf("unsupported key derivation %q", k.KDF)
+	}
+	salt, err := hex.DecodeString(k.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid key derivation salt")
+	}
+	if k.Memory == 0 || k.Iterations == 0 || k.Parallelism == 0 {
+		return nil, fmt.Errorf("invalid key derivation parameters")
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, k.Iterations, k.Memory, k.Parallelism, 32)
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// keystoreFile is the on-disk form of a keystore
+type keystoreFile struct {
+	Format   int                          `json:"format"`
+	Wrapping keystoreWrapping             `json:"wrapping"`
+	Check    string                       `json:"check"`
+	Versions map[string]map[string]string `json:"versions"` // Key version -> domain -> wrapped master
+}
+
+// keystoreWrapping describes the key encryption key of a keystore
+type keystoreWrapping struct {
+	Type     string         `json:"type"`
+	KDF      *PassphraseKDF `json:"kdf,omitempty"`
+	KMSKeyID string         `json:"kms_key_id,omitempty"`
+}
+
+// keyWrapper wraps and unwraps domain masters under a key encryption key
+type keyWrapper interface {
+	wrap(ctx context.Context, plaintext, aad []byte) (string, error)
+	unwrap(ctx context.Context, wrapped string, aad []byte) ([]byte, error)
+}
+
+// passphraseWrapper wraps with a key derived from a passphrase
+type passphraseWrapper struct {
+	gcm cipher.AEAD
+}
+
+func (w *passphraseWrapper) wrap(ctx context.Context, plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, w.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(w.gcm.Seal(nonce, nonce, plaintext, aad)), nil
+}
+
+func (w *passphraseWrapper) unwrap(ctx context.Context, wrapped string, aad []byte) ([]byte, error) {
+	data, err := hex.DecodeString(wrapped)
+	if err != nil || len(data) < w.gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid wrapped key")
+	}
+	nonce, ciphertext := data[:w.gcm.NonceSize()], data[w.gcm.NonceSize():]
+	plaintext, err := w.gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// KeystoreProvider keeps the domain masters of every key version in a
+// keystore file
+type KeystoreProvider struct {
+	path    string
+	wrapper keyWrapper
+	header  keystoreFile // Wrapping and check value, for a keystore not written yet
+
+	mu sync.Mutex
+}
+
+// OpenPassphraseKeystore opens the keystore file at path, or prepares a new
+// one, with the passphrase returned by passphrase
+func OpenPassphraseKeystore(path string, passphrase func() (string, error)) (*KeystoreProvider, error) {
+	file, err := readKeystore(path)
+	if errors.Is(err, os.ErrNotExist) {
+		kdf, err := newPassphraseKDF()
+		if err != nil {
+			return nil, err
+		}
+		file = &keystoreFile{Wrapping: keystoreWrapping{Type: keystoreWrappingPassphrase, KDF: &kdf}}
+	} else if err != nil {
+		return nil, err
+	}
+	if file.Wrapping.Type != keystoreWrappingPassphrase || file.Wrapping.KDF == nil {
+		return nil, fmt.Errorf("keystore %s is not protected by a passphrase", path)
+	}
+
+	secret, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("keystore passphrase must not be empty")
+	}
+	gcm, err := file.Wrapping.KDF.cipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return openKeystore(context.Background(), path, file, &passphraseWrapper{gcm: gcm})
+}
+
+// OpenKMSKeystore opens the keystore file at path, or prepares a new one,
+// wrapped by a key of a KMS
+func OpenKMSKeystore(ctx context.Context, path string, client *KMSClient) (*KeystoreProvider, error) {
+	file, err := readKeystore(path)
+	if errors.Is(err, os.ErrNotExist) {
+		file = &keystoreFile{Wrapping: keystoreWrapping{Type: keystoreWrappingKMS, KMSKeyID: client.KeyID()}}
+	} else if err != nil {
+		return nil, err
+	}
+	if file.Wrapping.Type != keystoreWrappingKMS {
+		return nil, fmt.Errorf("keystore %s is not wrapped by a KMS", path)
+	}
+	if file.Wrapping.KMSKeyID != client.KeyID() {
+		return nil, fmt.Errorf("keystore %s is wrapped by KMS key %q, not %q", path, file.Wrapping.KMSKeyID, client.KeyID())
+	}
+	return openKeystore(ctx, path, file, &kmsWrapper{client: client})
+}
+
+// openKeystore verifies the check value of an existing keystore, or creates
+// the check value of a new one
+func openKeystore(ctx context.Context, path string, file *keystoreFile, wrapper keyWrapper) (*KeystoreProvider, error) {
+	if file.Check == "" {
+		check, err := wrapper.wrap(ctx, []byte(keystoreCheck), []byte("check"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create keystore check value: %w", err)
+		}
+		file.Format = KeystoreFormatV1
+		file.Check = check
+	} else {
+		check, err := wrapper.unwrap(ctx, file.Check, []byte("check"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unlock keystore %s: %w", path, err)
+		}
+		if string(check) != keystoreCheck {
+			return nil, fmt.Errorf("failed to unlock keystore %s: invalid check value", path)
+		}
+	}
+
+	header := *file
+	header.Versions = nil
+	return &KeystoreProvider{path: path, wrapper: wrapper, header: header}, nil
+}
+
+// readKeystore reads a keystore file
+func readKeystore(path string) (*keystoreFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode keystore %s: %w", path, err)
+	}
+	if file.Format != KeystoreFormatV1 {
+		return nil, fmt.Errorf("unsupported keystore format %d in %s", file.Format, path)
+	}
+	return &file, nil
+}
+
+// Name describes the provider
+func (p *KeystoreProvider) Name() string {
+	return "keystore " + p.path
+}
+
+// Versions returns the key versions in the keystore
+func (p *KeystoreProvider) Versions(ctx context.Context) ([]int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for key := range file.Versions {
+		version, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key version %q in keystore %s", key, p.path)
+		}
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// Load unwraps the domain masters of a key version
+func (p *KeystoreProvider) Load(ctx context.Context, version int) (map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, ok := file.Versions[strconv.Itoa(version)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrKeyVersionNotFound, version)
+	}
+
+	domainMasters := make(map[string][]byte)
+	for _, domain := range Domains() {
+		master, ok := wrapped[domain]
+		if !ok {
+			if IsSplittableDomain(domain) {
+				continue
+			}
+			return nil, fmt.Errorf("keystore %s has no domain master for %s in key version %d", p.path, domain, version)
+		}
+		unwrapped, err := p.wrapper.unwrap(ctx, master, keystoreAAD(version, domain))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap domain master for %s: %w", domain, err)
+		}
+		domainMasters[domain] = unwrapped
+	}
+	return domainMasters, nil
+}
+
+// Save wraps the domain masters of a new key version
+func (p *KeystoreProvider) Save(ctx context.Context, version int, domainMasters map[string][]byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+	key := strconv.Itoa(version)
+	if _, ok := file.Versions[key]; ok {
+		return fmt.Errorf("%w: %d", ErrKeyVersionExists, version)
+	}
+
+	wrapped := make(map[string]string)
+	for domain, master := range domainMasters {
+		if wrapped[domain], err = p.wrapper.wrap(ctx, master, keystoreAAD(version, domain)); err != nil {
+			return fmt.Errorf("failed to wrap domain master for %s: %w", domain, err)
+		}
+	}
+	file.Versions[key] = wrapped
+	return p.write(file)
+}
+
+// Remove deletes the domain masters of a key version
+func (p *KeystoreProvider) Remove(ctx context.Context, version int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+	delete(file.Versions, strconv.Itoa(version))
+	return p.write(file)
+}
+
+// RemoveDomain deletes the master of one domain of a key version
+func (p *KeystoreProvider) RemoveDomain(ctx context.Context, version int, domain string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+	if wrapped, ok := file.Versions[strconv.Itoa(version)]; ok {
+		delete(wrapped, domain)
+	}
+	return p.write(file)
+}
+
+// read reads the keystore file, or the header of a keystore not written yet.
+// The file must still be wrapped under the key the provider was opened with.
+func (p *KeystoreProvider) read() (*keystoreFile, error) {
+	file, err := readKeystore(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		header := p.header
+		file, err = &header, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.Check != p.header.Check {
+		return nil, fmt.Errorf("keystore %s was replaced since it was opened", p.path)
+	}
+	if file.Versions == nil {
+		file.Versions = make(map[string]map[string]string)
+	}
+	return file, nil
+}
+
+// write writes the keystore file under a temporary name first, so that a
+// reader never sees a partial keystore
+func (p *KeystoreProvider) write(file *keystoreFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keystore: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+		return fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+	tmpPath := p.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Rename(tmpPath, p.path); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	return nil
+}
+
+// keystoreAAD binds a wrapped master to its key version and domain
+func keystoreAAD(version int, domain string) []byte {
+	return []byte(fmt.Sprintf("hashpost/ibe/keystore/v%d/%d/%s", KeystoreFormatV1, version, domain))
+}
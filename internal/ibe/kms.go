@@ -0,0 +1,249 @@
+package ibe
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KMS protocol
+//
+// The KMS provider keeps wrapped domain masters in a keystore file and asks a
+// key management service to wrap and unwrap them, so that the key encryption
+// key never leaves the service. It speaks a small JSON protocol over HTTP or
+// a unix socket:
+//
+//	POST /v1/keys/{key_id}/encrypt  {"plaintext": b64, "aad": b64} -> {"ciphertext": string}
+//	POST /v1/keys/{key_id}/decrypt  {"ciphertext": string, "aad": b64} -> {"plaintext": b64}
+//
+// Errors are returned as {"error": message} with a 4xx or 5xx status. A
+// production deployment puts an adapter for its KMS behind this protocol;
+// KMSStandIn implements it locally for development and tests.
+
+// ErrKMSRequest is returned when the KMS rejects a request
+var ErrKMSRequest = errors.New("KMS request failed")
+
+// kmsRequest is the body of an encrypt or decrypt request
+type kmsRequest struct {
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+	AAD        []byte `json:"aad,omitempty"`
+}
+
+// kmsResponse is the body of an encrypt or decrypt response
+type kmsResponse struct {
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// KMSClient wraps and unwraps keys with a key held by a KMS
+type KMSClient struct {
+	baseURL string
+	keyID   string
+	token   string
+	client  *http.Client
+}
+
+// NewKMSClient creates a client for a KMS at an http(s):// URL or a
+// unix:///path/to/socket
+func NewKMSClient(rawURL, keyID, token string) (*KMSClient, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("KMS key ID is required")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS URL: %w", err)
+	}
+
+	client := &KMSClient{keyID: keyID, token: token}
+	switch parsed.Scheme {
+	case "http", "https":
+		client.baseURL = strings.TrimSuffix(rawURL, "/")
+		client.client = &http.Client{Timeout: 10 * time.Second}
+	case "unix":
+		socket := parsed.Path
+		if socket == "" {
+			return nil, fmt.Errorf("KMS unix socket path is required")
+		}
+		client.baseURL = "http://kms"
+		client.client = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported KMS URL %q: use http://, https:// or unix://", rawURL)
+	}
+	return client, nil
+}
+
+// KeyID returns the ID of the KMS key the client uses
+func (c *KMSClient) KeyID() string {
+	return c.keyID
+}
+
+// Encrypt wraps plaintext with the KMS key
+func (c *KMSClient) Encrypt(ctx context.Context, plaintext, aad []byte) (string, error) {
+	resp, err := c.do(ctx, "encrypt", &kmsRequest{Plaintext: plaintext, AAD: aad})
+	if err != nil {
+		return "", err
+	}
+	return resp.Ciphertext, nil
+}
+
+// Decrypt unwraps ciphertext with the KMS key
+func (c *KMSClient) Decrypt(ctx context.Context, ciphertext string, aad []byte) ([]byte, error) {
+	resp, err := c.do(ctx, "decrypt", &kmsRequest{Ciphertext: ciphertext, AAD: aad})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// do sends a request to the KMS
+func (c *KMSClient) do(ctx context.Context, operation string, body *kmsRequest) (*kmsResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode KMS request: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/v1/keys/%s/%s", c.baseURL, url.PathEscape(c.keyID), operation)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KMS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpResp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach KMS: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	var resp kmsResponse
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 1<<20)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode KMS response (status %d): %w", httpResp.StatusCode, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrKMSRequest, operation, httpResp.Status, resp.Error)
+	}
+	return &resp, nil
+}
+
+// kmsWrapper wraps domain masters with a KMS key
+type kmsWrapper struct {
+	client *KMSClient
+}
+
+func (w *kmsWrapper) wrap(ctx context.Context, plaintext, aad []byte) (string, error) {
+	return w.client.Encrypt(ctx, plaintext, aad)
+}
+
+func (w *kmsWrapper) unwrap(ctx context.Context, wrapped string, aad []byte) ([]byte, error) {
+	return w.client.Decrypt(ctx, wrapped, aad)
+}
+
+// KMSStandIn is a local KMS speaking the KMS protocol, for development and
+// tests. It holds its keys in memory and encrypts with AES-256-GCM.
+type KMSStandIn struct {
+	keys  map[string]cipher.AEAD
+	token string
+	mux   *http.ServeMux
+}
+
+// NewKMSStandIn creates a stand-in KMS with 32-byte keys by ID. Requests must
+// carry token as a bearer token if it is set.
+func NewKMSStandIn(keys map[string][]byte, token string) (*KMSStandIn, error) {
+	s := &KMSStandIn{keys: make(map[string]cipher.AEAD), token: token, mux: http.NewServeMux()}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KMS key %q: %w", id, err)
+		}
+		if s.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("invalid KMS key %q: %w", id, err)
+		}
+	}
+	s.mux.HandleFunc("POST /v1/keys/{key_id}/encrypt", s.handle(s.encrypt))
+	s.mux.HandleFunc("POST /v1/keys/{key_id}/decrypt", s.handle(s.decrypt))
+	return s, nil
+}
+
+// ServeHTTP serves the KMS protocol
+func (s *KMSStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle authenticates a request, finds its key and runs an operation on it
+func (s *KMSStandIn) handle(operation func(gcm cipher.AEAD, req *kmsRequest) (*kmsResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+			writeKMSResponse(w, http.StatusUnauthorized, &kmsResponse{Error: "invalid token"})
+			return
+		}
+		gcm, ok := s.keys[r.PathValue("key_id")]
+		if !ok {
+			writeKMSResponse(w, http.StatusNotFound, &kmsResponse{Error: "unknown key"})
+			return
+		}
+		var req kmsRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeKMSResponse(w, http.StatusBadRequest, &kmsResponse{Error: "invalid request body"})
+			return
+		}
+		resp, err := operation(gcm, &req)
+		if err != nil {
+			writeKMSResponse(w, http.StatusBadRequest, &kmsResponse{Error: err.Error()})
+			return
+		}
+		writeKMSResponse(w, http.StatusOK, resp)
+	}
+}
+
+func (s *KMSStandIn) encrypt(gcm cipher.AEAD, req *kmsRequest) (*kmsResponse, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce")
+	}
+	ciphertext := gcm.Seal(nonce, nonce, req.Plaintext, req.AAD)
+	return &kmsResponse{Ciphertext: base64.StdEncoding.EncodeToString(ciphertext)}, nil
+}
+
+func (s *KMSStandIn) decrypt(gcm cipher.AEAD, req *kmsRequest) (*kmsResponse, error) {
+	data, err := base64.StdEncoding.DecodeString(req.Ciphertext)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], req.AAD)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed")
+	}
+	return &kmsResponse{Plaintext: plaintext}, nil
+}
+
+// writeKMSResponse writes a JSON response
+func writeKMSResponse(w http.ResponseWriter, status int, resp *kmsResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,273 @@
+package ibe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"golang.org/x/term"
+)
+
+// Key providers
+//
+// A KeyProvider stores the domain masters of every key version. The dir
+// provider keeps them as plain hex files (see keyring.go), the env provider
+// reads them from environment variables for development, the keystore
+// provider keeps them in a single file wrapped by a key derived from a
+// passphrase and the KMS provider keeps them in a keystore file wrapped by a
+// remote key management service.
+
+// Key provider types, as set in IBE_KEY_PROVIDER
+const (
+	KeyProviderDir      = "dir"
+	KeyProviderEnv      = "env"
+	KeyProviderKeystore = "keystore"
+	KeyProviderKMS      = "kms"
+)
+
+var (
+	// ErrKeyVersionExists is returned when saving a key version a provider
+	// already holds
+	ErrKeyVersionExists = errors.New("key version already exists")
+	// ErrKeyVersionNotFound is returned when loading a key version a provider
+	// does not hold
+	ErrKeyVersionNotFound = errors.New("key version not found")
+	// ErrReadOnlyProvider is returned when removing key versions from a
+	// provider that cannot change them
+	ErrReadOnlyProvider = errors.New("key provider is read-only")
+)
+
+// KeyProvider stores the domain masters of IBE key versions
+type KeyProvider interface {
+	// Name describes the provider and its location for logs and errors
+	Name() string
+	// Versions returns the key versions the provider holds, oldest first
+	Versions(ctx context.Context) ([]int, error)
+	// Load returns the domain masters of a key version. The masters of
+	// splittable domains may be missing; those domains are locked.
+	Load(ctx context.Context, version int) (map[string][]byte, error)
+	// Save stores the domain masters of a new key version. It fails with
+	// ErrKeyVersionExists if the version already exists.
+	Save(ctx context.Context, version int, domainMasters map[string][]byte) error
+	// Remove deletes the domain masters of a key version
+	Remove(ctx context.Context, version int) error
+	// RemoveDomain deletes the master of one domain of a key version, once it
+	// is split into shares
+	RemoveDomain(ctx context.Context, version int, domain string) error
+}
+
+// NewIBESystemFromProvider creates an IBE system from the newest key version
+// of a provider
+func NewIBESystemFromProvider(ctx context.Context, provider KeyProvider, salt string) (*IBESystem, error) {
+	versions, err := provider.Versions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key versions in %s: %w", provider.Name(), err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no domain masters found in %s", provider.Name())
+	}
+
+	version := versions[len(versions)-1]
+	domainMasters, err := provider.Load(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load domain masters of key version %d from %s: %w", version, provider.Name(), err)
+	}
+
+	return NewIBESystemWithOptions(IBEOptions{
+		DomainMasters: domainMasters,
+		KeyVersion:    version,
+		Salt:          salt,
+	}), nil
+}
+
+// NewKeyProviderFromConfig creates the key provider configured in IBEConfig.
+// A keystore passphrase is read from IBEConfig.KeystorePassphraseFD, or
+// prompted for if that is a terminal, when the keystore is opened.
+func NewKeyProviderFromConfig(cfg *config.IBEConfig) (KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case "", KeyProviderDir:
+		return NewDirProvider(cfg.MasterKeyPath, cfg.KeyVersion), nil
+	case KeyProviderEnv:
+		return NewEnvProvider(cfg.KeyVersion, os.Getenv, os.Stdout), nil
+	case KeyProviderKeystore:
+		return OpenPassphraseKeystore(cfg.KeystorePath, func() (string, error) {
+			return ReadPassphrase(cfg.KeystorePassphraseFD, "IBE keystore passphrase: ")
+		})
+	case KeyProviderKMS:
+		client, err := NewKMSClient(cfg.KMSURL, cfg.KMSKeyID, cfg.KMSToken)
+		if err != nil {
+			return nil, err
+		}
+		return OpenKMSKeystore(context.Background(), cfg.KeystorePath, client)
+	default:
+		return nil, fmt.Errorf("unknown IBE key provider %q", cfg.KeyProvider)
+	}
+}
+
+// ReadPassphrase reads a passphrase from a file descriptor. A terminal is
+// prompted without echo; anything else is read up to the first newline.
+func ReadPassphrase(fd int, prompt string) (string, error) {
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return strings.TrimSpace(string(passphrase)), nil
+	}
+
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if file == nil {
+		return "", fmt.Errorf("invalid passphrase file descriptor %d", fd)
+	}
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("failed to read passphrase from file descriptor %d: %w", fd, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// DirProvider keeps domain masters as hex files in a directory, the base
+// version directly and later versions in v<N> subdirectories
+type DirProvider struct {
+	dir         string
+	baseVersion int
+}
+
+// NewDirProvider creates a provider for a domain keys directory whose files
+// are of baseVersion
+func NewDirProvider(dir string, baseVersion int) *DirProvider {
+	return &DirProvider{dir: dir, baseVersion: baseVersion}
+}
+
+// Name describes the provider
+func (p *DirProvider) Name() string {
+	return "domain keys directory " + p.dir
+}
+
+// Versions returns the key versions in the directory
+func (p *DirProvider) Versions(ctx context.Context) ([]int, error) {
+	return ListKeyVersions(p.dir, p.baseVersion)
+}
+
+// Load reads the domain masters of a key version
+func (p *DirProvider) Load(ctx context.Context, version int) (map[string][]byte, error) {
+	return LoadKeyVersion(p.dir, p.baseVersion, version)
+}
+
+// Save writes the domain masters of a new key version
+func (p *DirProvider) Save(ctx context.Context, version int, domainMasters map[string][]byte) error {
+	if version != p.baseVersion {
+		return SaveKeyVersion(p.dir, p.baseVersion, version, domainMasters)
+	}
+
+	if _, err := os.Stat(filepath.Join(p.dir, DOMAIN_USER_PSEUDONYMS+".key")); err == nil {
+		return fmt.Errorf("%w: %d", ErrKeyVersionExists, version)
+	}
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create domain keys directory: %w", err)
+	}
+	return saveDomainMasters(p.dir, domainMasters)
+}
+
+// Remove deletes the domain masters of a key version
+func (p *DirProvider) Remove(ctx context.Context, version int) error {
+	return RemoveKeyVersion(p.dir, p.baseVersion, version)
+}
+
+// RemoveDomain deletes the master file of one domain of a key version
+func (p *DirProvider) RemoveDomain(ctx context.Context, version int, domain string) error {
+	keyPath := filepath.Join(KeyVersionDir(p.dir, p.baseVersion, version), fmt.Sprintf("%s.key", domain))
+	if err := os.Remove(keyPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove domain master for %s: %w", domain, err)
+	}
+	return nil
+}
+
+// EnvProvider reads the domain masters of a single key version from
+// IBE_DOMAIN_MASTER_<DOMAIN> environment variables, for development. Saving
+// writes the variables as shell exports.
+type EnvProvider struct {
+	version int
+	getenv  func(string) string
+	out     io.Writer
+}
+
+// NewEnvProvider creates a provider reading environment variables with getenv
+// and writing exports to out
+func NewEnvProvider(version int, getenv func(string) string, out io.Writer) *EnvProvider {
+	return &EnvProvider{version: version, getenv: getenv, out: out}
+}
+
+// EnvVar returns the environment variable holding the master of a domain
+func EnvVar(domain string) string {
+	return "IBE_DOMAIN_MASTER_" + strings.ToUpper(domain)
+}
+
+// Name describes the provider
+func (p *EnvProvider) Name() string {
+	return "environment variables IBE_DOMAIN_MASTER_*"
+}
+
+// Versions returns the configured key version if its masters are set
+func (p *EnvProvider) Versions(ctx context.Context) ([]int, error) {
+	if p.getenv(EnvVar(DOMAIN_USER_PSEUDONYMS)) == "" {
+		return nil, nil
+	}
+	return []int{p.version}, nil
+}
+
+// Load parses the domain masters from the environment
+func (p *EnvProvider) Load(ctx context.Context, version int) (map[string][]byte, error) {
+	if version != p.version {
+		return nil, fmt.Errorf("%w: %d", ErrKeyVersionNotFound, version)
+	}
+
+	domainMasters := make(map[string][]byte)
+	for _, domain := range Domains() {
+		value := p.getenv(EnvVar(domain))
+		if value == "" && IsSplittableDomain(domain) {
+			continue
+		}
+		master, err := parseMaster([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvVar(domain), err)
+		}
+		domainMasters[domain] = master
+	}
+	return domainMasters, nil
+}
+
+// Save writes the domain masters as shell exports
+func (p *EnvProvider) Save(ctx context.Context, version int, domainMasters map[string][]byte) error {
+	if version != p.version {
+		return fmt.Errorf("the environment holds only key version %d", p.version)
+	}
+	for _, domain := range Domains() {
+		master, ok := domainMasters[domain]
+		if !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(p.out, "export %s=%x\n", EnvVar(domain), master); err != nil {
+			return fmt.Errorf("failed to write domain master for %s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// Remove cannot change the environment
+func (p *EnvProvider) Remove(ctx context.Context, version int) error {
+	return ErrReadOnlyProvider
+}
+
+// RemoveDomain cannot change the environment
+func (p *EnvProvider) RemoveDomain(ctx context.Context, version int, domain string) error {
+	return ErrReadOnlyProvider
+}
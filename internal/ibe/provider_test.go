@@ -0,0 +1,196 @@
+package ibe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeystoreProvider_Passphrase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ibe.keystore")
+	passphrase := func(secret string) func() (string, error) {
+		return func() (string, error) { return secret, nil }
+	}
+
+	provider, err := OpenPassphraseKeystore(path, passphrase("correct horse"))
+	if err != nil {
+		t.Fatalf("Failed to open keystore: %v", err)
+	}
+	testKeyProvider(t, provider)
+
+	// The keystore opens again only with the same passphrase
+	if _, err := OpenPassphraseKeystore(path, passphrase("wrong horse")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	reopened, err := OpenPassphraseKeystore(path, passphrase("correct horse"))
+	if err != nil {
+		t.Fatalf("Failed to reopen keystore: %v", err)
+	}
+	if versions, err := reopened.Versions(ctx); err != nil || len(versions) != 1 || versions[0] != 2 {
+		t.Errorf("Expected key version 2 after reopening, got %v (%v)", versions, err)
+	}
+}
+
+func TestKeystoreProvider_KMS(t *testing.T) {
+	ctx := context.Background()
+	standIn, err := NewKMSStandIn(map[string][]byte{"hashpost-ibe": bytes.Repeat([]byte{0x01}, 32)}, "secret")
+	if err != nil {
+		t.Fatalf("Failed to create KMS stand-in: %v", err)
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "ibe.keystore")
+
+	client, err := NewKMSClient(server.URL, "hashpost-ibe", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create KMS client: %v", err)
+	}
+	provider, err := OpenKMSKeystore(ctx, path, client)
+	if err != nil {
+		t.Fatalf("Failed to open keystore: %v", err)
+	}
+	testKeyProvider(t, provider)
+
+	// Requests without the token are rejected
+	unauthorized, err := NewKMSClient(server.URL, "hashpost-ibe", "")
+	if err != nil {
+		t.Fatalf("Failed to create KMS client: %v", err)
+	}
+	if _, err := OpenKMSKeystore(ctx, path, unauthorized); !errors.Is(err, ErrKMSRequest) {
+		t.Errorf("Expected ErrKMSRequest without a token, got %v", err)
+	}
+
+	// Nor does the keystore open with another KMS key
+	other, err := NewKMSClient(server.URL, "other", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create KMS client: %v", err)
+	}
+	if _, err := OpenKMSKeystore(ctx, path, other); err == nil {
+		t.Error("Expected an error opening the keystore with another KMS key")
+	}
+}
+
+func TestDirProvider(t *testing.T) {
+	testKeyProvider(t, NewDirProvider(t.TempDir(), 1))
+}
+
+func TestEnvProvider(t *testing.T) {
+	ctx := context.Background()
+	masters, err := GenerateDomainMasters()
+	if err != nil {
+		t.Fatalf("Failed to generate domain masters: %v", err)
+	}
+
+	// Saving prints exports that load back
+	var out bytes.Buffer
+	if err := NewEnvProvider(3, nil, &out).Save(ctx, 3, masters); err != nil {
+		t.Fatalf("Failed to save domain masters: %v", err)
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		name, value, _ := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		env[name] = value
+	}
+	delete(env, EnvVar(DOMAIN_LEGAL_CORRELATION))
+	provider := NewEnvProvider(3, func(name string) string { return env[name] }, nil)
+
+	if versions, err := provider.Versions(ctx); err != nil || len(versions) != 1 || versions[0] != 3 {
+		t.Fatalf("Expected key version 3, got %v (%v)", versions, err)
+	}
+	loaded, err := provider.Load(ctx, 3)
+	if err != nil {
+		t.Fatalf("Failed to load domain masters: %v", err)
+	}
+	if _, ok := loaded[DOMAIN_LEGAL_CORRELATION]; ok || len(loaded) != len(masters)-1 {
+		t.Errorf("Expected every domain master except the unset legal master, got %d", len(loaded))
+	}
+	if !bytes.Equal(loaded[DOMAIN_USER_PSEUDONYMS], masters[DOMAIN_USER_PSEUDONYMS]) {
+		t.Error("Loaded domain master does not match the saved one")
+	}
+	if err := provider.Remove(ctx, 3); !errors.Is(err, ErrReadOnlyProvider) {
+		t.Errorf("Expected ErrReadOnlyProvider, got %v", err)
+	}
+
+	// A missing master of a domain that cannot be split is an error
+	delete(env, EnvVar(DOMAIN_MOD_CORRELATION))
+	if _, err := provider.Load(ctx, 3); err == nil {
+		t.Error("Expected an error for a missing moderator domain master")
+	}
+}
+
+// testKeyProvider saves, loads and removes key versions 1 and 2
+func testKeyProvider(t *testing.T, provider KeyProvider) {
+	t.Helper()
+	ctx := context.Background()
+
+	if versions, err := provider.Versions(ctx); err != nil || len(versions) != 0 {
+		t.Fatalf("Expected no key versions in a new provider, got %v (%v)", versions, err)
+	}
+	if _, err := NewIBESystemFromProvider(ctx, provider, ""); err == nil {
+		t.Error("Expected an error creating an IBE system from an empty provider")
+	}
+
+	v1, err := GenerateDomainMasters()
+	if err != nil {
+		t.Fatalf("Failed to generate domain masters: %v", err)
+	}
+	v2, err := GenerateDomainMasters()
+	if err != nil {
+		t.Fatalf("Failed to generate domain masters: %v", err)
+	}
+	if err := provider.Save(ctx, 1, v1); err != nil {
+		t.Fatalf("Failed to save key version 1: %v", err)
+	}
+	if err := provider.Save(ctx, 2, v2); err != nil {
+		t.Fatalf("Failed to save key version 2: %v", err)
+	}
+	if err := provider.Save(ctx, 1, v2); !errors.Is(err, ErrKeyVersionExists) {
+		t.Errorf("Expected ErrKeyVersionExists, got %v", err)
+	}
+
+	versions, err := provider.Versions(ctx)
+	if err != nil || len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Fatalf("Expected key versions [1 2], got %v (%v)", versions, err)
+	}
+	loaded, err := provider.Load(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to load key version 1: %v", err)
+	}
+	for domain, master := range v1 {
+		if !bytes.Equal(loaded[domain], master) {
+			t.Errorf("Loaded domain master for %s does not match the saved one", domain)
+		}
+	}
+
+	// The newest version is used
+	ibe, err := NewIBESystemFromProvider(ctx, provider, "")
+	if err != nil {
+		t.Fatalf("Failed to create IBE system: %v", err)
+	}
+	if ibe.GetKeyVersion() != 2 || !bytes.Equal(ibe.GetMasterSecret(), v2[DOMAIN_USER_PSEUDONYMS]) {
+		t.Error("IBE system should use the newest key version")
+	}
+
+	// Removing a split domain locks it
+	if err := provider.RemoveDomain(ctx, 2, DOMAIN_LEGAL_CORRELATION); err != nil {
+		t.Fatalf("Failed to remove domain master: %v", err)
+	}
+	if loaded, err = provider.Load(ctx, 2); err != nil {
+		t.Fatalf("Failed to load key version 2: %v", err)
+	}
+	if _, ok := loaded[DOMAIN_LEGAL_CORRELATION]; ok || len(loaded) != len(v2)-1 {
+		t.Error("Removed domain master should not be loaded")
+	}
+
+	if err := provider.Remove(ctx, 1); err != nil {
+		t.Fatalf("Failed to remove key version 1: %v", err)
+	}
+	if versions, err := provider.Versions(ctx); err != nil || len(versions) != 1 || versions[0] != 2 {
+		t.Errorf("Expected key version 2 after removing version 1, got %v (%v)", versions, err)
+	}
+}
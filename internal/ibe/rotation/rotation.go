@@ -0,0 +1,584 @@
+// Package rotation rotates the IBE domain masters.
+//
+// Identity mappings are encrypted under role keys, and role keys are derived
+// from the domain masters. A rotation goes through three phases:
+//
+//  1. Start generates new domain masters under the next key version and
+//     writes them next to the current ones.
+//  2. Resume derives a role key of the new version for every active role
+//     key, then re-encrypts identity mappings in batches under the new role
+//     keys. It can be interrupted and run again at any time; mappings already
+//     re-encrypted are skipped. New mappings use the new version as soon as
+//     its role keys exist.
+//  3. Once every mapping uses the new version, the rotation enters its grace
+//     period. Role keys of both versions stay active, so both still decrypt.
+//     Retire destroys the previous version's role keys and domain masters
+//     when the grace period is over and no mapping refers to it any more.
+//
+// Every phase, and the progress of every batch, is recorded in the system
+// event log.
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// System events recorded by rotations
+const (
+	EventStarted     = "ibe_key_rotation_started"
+	EventProgress    = "ibe_key_rotation_progress"
+	EventReencrypted = "ibe_key_rotation_reencrypted"
+	EventRetired     = "ibe_key_rotation_retired"
+)
+
+// DefaultBatchSize is the number of identity mappings re-encrypted per batch
+const DefaultBatchSize = 500
+
+// roleKeyLifetime is how long role keys derived by a rotation are valid,
+// matching the keys created by setup-roles
+const roleKeyLifetime = 365 * 24 * time.Hour
+
+// eventComponent is the source component of the system events
+const eventComponent = "ibe_key_rotation"
+
+// ErrRotationInProgress is returned when a rotation is started while
+// another one is still re-encrypting
+var ErrRotationInProgress = errors.New("an IBE key rotation is already in progress")
+
+// Rotator rotates the domain masters kept by a key provider and re-encrypts
+// the identity mappings in the database
+type Rotator struct {
+	cfg                *config.IBEConfig
+	provider           ibe.KeyProvider
+	roleKeyDAO         *dao.RoleKeyDAO
+	identityMappingDAO *dao.IdentityMappingDAO
+	rotationDAO        *dao.IBEKeyRotationDAO
+	systemEventDAO     *dao.SystemEventDAO
+	batchSize          int
+	now                func() time.Time
+}
+
+// NewRotator creates a rotator for the domain masters of a key provider
+func NewRotator(db bob.Executor, cfg *config.IBEConfig, provider ibe.KeyProvider) *Rotator {
+	return &Rotator{
+		cfg:                cfg,
+		provider:           provider,
+		roleKeyDAO:         dao.NewRoleKeyDAO(db),
+		identityMappingDAO: dao.NewIdentityMappingDAO(db),
+		rotationDAO:        dao.NewIBEKeyRotationDAO(db),
+		systemEventDAO:     dao.NewSystemEventDAO(db),
+		batchSize:          DefaultBatchSize,
+		now:                time.Now,
+	}
+}
+
+// SetBatchSize sets the number of identity mappings re-encrypted per batch
+func (r *Rotator) SetBatchSize(batchSize int) {
+	if batchSize > 0 {
+		r.batchSize = batchSize
+	}
+}
+
+// Status describes the key versions and rotations
+type Status struct {
+	Provider       string               // Key provider holding the domain masters
+	CurrentVersion int                  // Newest key version of the key provider
+	Versions       []int                // Key versions of the key provider, oldest first
+	Rotations      []dao.IBEKeyRotation // Every rotation, oldest first
+	Mappings       map[int32]int64      // Identity mappings per key version
+}
+
+// Progress describes a run of re-encryption
+type Progress struct {
+	KeyVersion  int
+	Reencrypted int64 // Mappings re-encrypted by this run
+	Failed      int64 // Mappings no active role key could decrypt
+	Complete    bool  // Every mapping now uses KeyVersion
+}
+
+// Status returns the key versions of the key provider, the rotations and the number of
+// identity mappings under each key version
+func (r *Rotator) Status(ctx context.Context) (*Status, error) {
+	versions, err := r.provider.Versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no domain masters found in %s", r.provider.Name())
+	}
+
+	rotations, err := r.rotationDAO.ListRotations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings, err := r.identityMappingDAO.CountMappingsByKeyVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		Provider:       r.provider.Name(),
+		CurrentVersion: versions[len(versions)-1],
+		Versions:       versions,
+		Rotations:      rotations,
+		Mappings:       mappings,
+	}, nil
+}
+
+// Start generates the domain masters of the next key version. It fails with
+// ErrRotationInProgress while a previous rotation is still re-encrypting.
+func (r *Rotator) Start(ctx context.Context) (*dao.IBEKeyRotation, error) {
+	status, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next := status.CurrentVersion + 1
+	for _, rotation := range status.Rotations {
+		if rotation.Status == dao.IBEKeyRotationReencrypting {
+			return nil, ErrRotationInProgress
+		}
+		if rotation.KeyVersion >= next {
+			next = rotation.KeyVersion + 1
+		}
+	}
+
+	// Claim the version before writing its masters, so that two nodes can
+	// never write different masters for it
+	rotation, err := r.rotationDAO.StartRotation(ctx, next, status.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	if rotation == nil {
+		return nil, ErrRotationInProgress
+	}
+
+	domainMasters, err := ibe.GenerateDomainMasters()
+	if err == nil {
+		err = r.provider.Save(ctx, next, domainMasters)
+	}
+	if err != nil {
+		if deleteErr := r.rotationDAO.DeleteRotation(ctx, next); deleteErr != nil {
+			log.Error().Err(deleteErr).Int("key_version", next).Msg("Failed to release IBE key rotation")
+		}
+		return nil, fmt.Errorf("failed to create domain masters for key version %d: %w", next, err)
+	}
+
+	r.recordEvent(ctx, EventStarted, dao.SystemEventSeverityInfo, "IBE key rotation started", map[string]interface{}{
+		"key_version":      next,
+		"previous_version": status.CurrentVersion,
+	})
+	log.Info().Int("key_version", next).Int("previous_version", status.CurrentVersion).Msg("Started IBE key rotation")
+	return rotation, nil
+}
+
+// Resume re-encrypts the identity mappings of the rotation in progress. It
+// returns nil if no rotation is re-encrypting.
+func (r *Rotator) Resume(ctx context.Context) (*Progress, error) {
+	rotations, err := r.rotationDAO.ListRotations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rotation *dao.IBEKeyRotation
+	for i := range rotations {
+		if rotations[i].Status == dao.IBEKeyRotationReencrypting {
+			rotation = &rotations[i]
+		}
+	}
+	if rotation == nil {
+		return nil, nil
+	}
+
+	domainMasters, err := r.provider.Load(ctx, rotation.KeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load domain masters of key version %d: %w", rotation.KeyVersion, err)
+	}
+	ibeSystem := ibe.NewIBESystemWithOptions(ibe.IBEOptions{
+		DomainMasters: domainMasters,
+		KeyVersion:    rotation.KeyVersion,
+		Salt:          r.cfg.Salt,
+	})
+
+	keys, err := r.deriveRoleKeys(ctx, ibeSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &Progress{KeyVersion: rotation.KeyVersion}
+	target := int32(rotation.KeyVersion)
+	after := uuid.Nil
+	for batch := 1; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		mappings, err := r.identityMappingDAO.ListMappingsNotAtKeyVersion(ctx, target, after, r.batchSize)
+		if err != nil {
+			return progress, err
+		}
+		if len(mappings) == 0 {
+			break
+		}
+		after = mappings[len(mappings)-1].MappingID
+
+		var reencrypted, failed int64
+		for _, mapping := range mappings {
+			decrypted, updated, err := r.reencrypt(ctx, ibeSystem, keys, mapping, target)
+			if err != nil {
+				return progress, err
+			}
+			if !decrypted {
+				failed++
+			} else if updated {
+				reencrypted++
+			}
+		}
+
+		progress.Reencrypted += reencrypted
+		progress.Failed += failed
+		if err := r.rotationDAO.RecordProgress(ctx, rotation.KeyVersion, reencrypted, progress.Failed); err != nil {
+			return progress, err
+		}
+		r.recordEvent(ctx, EventProgress, dao.SystemEventSeverityInfo, "IBE key rotation batch re-encrypted", map[string]interface{}{
+			"key_version": rotation.KeyVersion,
+			"batch":       batch,
+			"reencrypted": progress.Reencrypted,
+			"failed":      progress.Failed,
+		})
+		log.Debug().
+			Int("key_version", rotation.KeyVersion).
+			Int("batch", batch).
+			Int64("reencrypted", progress.Reencrypted).
+			Int64("failed", progress.Failed).
+			Msg("Re-encrypted identity mapping batch")
+	}
+
+	if progress.Failed > 0 {
+		r.recordEvent(ctx, EventProgress, dao.SystemEventSeverityWarning, "IBE key rotation could not re-encrypt some identity mappings", map[string]interface{}{
+			"key_version": rotation.KeyVersion,
+			"reencrypted": progress.Reencrypted,
+			"failed":      progress.Failed,
+		})
+		log.Warn().Int("key_version", rotation.KeyVersion).Int64("failed", progress.Failed).Msg("Some identity mappings could not be re-encrypted")
+		return progress, nil
+	}
+
+	if err := r.rotationDAO.SetStatus(ctx, rotation.KeyVersion, dao.IBEKeyRotationGrace); err != nil {
+		return progress, err
+	}
+	progress.Complete = true
+	r.recordEvent(ctx, EventReencrypted, dao.SystemEventSeverityInfo, "IBE key rotation re-encrypted every identity mapping", map[string]interface{}{
+		"key_version":      rotation.KeyVersion,
+		"previous_version": rotation.PreviousVersion,
+		"reencrypted":      progress.Reencrypted,
+		"grace_period":     r.cfg.KeyRotation.GracePeriod.String(),
+	})
+	log.Info().Int("key_version", rotation.KeyVersion).Int64("reencrypted", progress.Reencrypted).Msg("IBE key rotation re-encryption complete")
+	return progress, nil
+}
+
+// Retire destroys the previous key version of every rotation whose grace
+// period is over, unless identity mappings still refer to it. It returns the
+// retired versions.
+func (r *Rotator) Retire(ctx context.Context) ([]int, error) {
+	rotations, err := r.rotationDAO.ListRotations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings map[int32]int64
+	var retired []int
+	for _, rotation := range rotations {
+		if rotation.Status != dao.IBEKeyRotationGrace || !rotation.ReencryptedAt.Valid {
+			continue
+		}
+		if r.now().Before(rotation.ReencryptedAt.V.Add(r.cfg.KeyRotation.GracePeriod)) {
+			continue
+		}
+
+		if mappings == nil {
+			if mappings, err = r.identityMappingDAO.CountMappingsByKeyVersion(ctx); err != nil {
+				return retired, err
+			}
+		}
+
+		// Mappings written under the previous version after re-encryption
+		// finished, by a node that had not seen the new role keys yet, still
+		// need it. Re-encrypt them before retiring.
+		if remaining := mappings[int32(rotation.PreviousVersion)]; remaining > 0 {
+			log.Warn().
+				Int("key_version", rotation.KeyVersion).
+				Int64("remaining", remaining).
+				Msg("Identity mappings still use the previous key version, resuming re-encryption")
+			if err := r.rotationDAO.SetStatus(ctx, rotation.KeyVersion, dao.IBEKeyRotationReencrypting); err != nil {
+				return retired, err
+			}
+			continue
+		}
+
+		roleKeys, err := r.roleKeyDAO.RetireKeyVersion(ctx, int32(rotation.PreviousVersion))
+		if err != nil {
+			return retired, err
+		}
+		if err := r.provider.Remove(ctx, rotation.PreviousVersion); err != nil {
+			return retired, err
+		}
+		if err := r.rotationDAO.SetStatus(ctx, rotation.KeyVersion, dao.IBEKeyRotationRetired); err != nil {
+			return retired, err
+		}
+
+		retired = append(retired, rotation.PreviousVersion)
+		r.recordEvent(ctx, EventRetired, dao.SystemEventSeverityInfo, "IBE key version retired", map[string]interface{}{
+			"key_version":       rotation.KeyVersion,
+			"retired_version":   rotation.PreviousVersion,
+			"role_keys_retired": roleKeys,
+		})
+		log.Info().Int("retired_version", rotation.PreviousVersion).Int64("role_keys", roleKeys).Msg("Retired IBE key version")
+	}
+
+	return retired, nil
+}
+
+// Tick does whatever scheduled rotation is due: it starts a rotation when
+// the current key version is older than the rotation interval, continues
+// re-encryption and retires versions past their grace period.
+func (r *Rotator) Tick(ctx context.Context) error {
+	due, err := r.due(ctx)
+	if err != nil {
+		return err
+	}
+	if due {
+		if _, err := r.Start(ctx); err != nil && !errors.Is(err, ErrRotationInProgress) {
+			return err
+		}
+	}
+
+	if _, err := r.Resume(ctx); err != nil {
+		return err
+	}
+	_, err = r.Retire(ctx)
+	return err
+}
+
+// Schedule runs Tick every interval until ctx is done
+func (r *Rotator) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Tick(ctx); err != nil {
+			log.Error().Err(err).Msg("Scheduled IBE key rotation failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// due reports whether the current key version is older than the rotation
+// interval. A version's age is counted from the start of the rotation that
+// created it, or for the base version from its oldest role key.
+func (r *Rotator) due(ctx context.Context) (bool, error) {
+	if r.cfg.KeyRotation.Interval <= 0 {
+		return false, nil
+	}
+
+	status, err := r.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var since time.Time
+	for _, rotation := range status.Rotations {
+		if rotation.Status == dao.IBEKeyRotationReencrypting {
+			return false, nil
+		}
+		if rotation.KeyVersion == status.CurrentVersion {
+			since = rotation.StartedAt
+		}
+	}
+
+	if since.IsZero() {
+		roleKeys, err := r.roleKeyDAO.ListRoleKeys(ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, roleKey := range roleKeys {
+			if int(roleKey.KeyVersion) != status.CurrentVersion || !roleKey.CreatedAt.Valid {
+				continue
+			}
+			if since.IsZero() || roleKey.CreatedAt.V.Before(since) {
+				since = roleKey.CreatedAt.V
+			}
+		}
+		if since.IsZero() {
+			return false, nil
+		}
+	}
+
+	return !r.now().Before(since.Add(r.cfg.KeyRotation.Interval)), nil
+}
+
+// roleKeyID identifies a role key across key versions
+type roleKeyID struct {
+	roleName  string
+	scope     string
+	createdBy int64
+}
+
+// rotationKeys are the active role keys during re-encryption
+type rotationKeys struct {
+	target int32
+	all    []*models.RoleKey             // Every active role key, of any version
+	new    map[roleKeyID]*models.RoleKey // Role keys of the new version
+	any    map[[2]string]*models.RoleKey // A role key of the new version for each role and scope
+}
+
+// deriveRoleKeys makes sure every active role key has a counterpart of the
+// new key version, derived from the new domain masters
+func (r *Rotator) deriveRoleKeys(ctx context.Context, ibeSystem *ibe.IBESystem) (*rotationKeys, error) {
+	roleKeys, err := r.roleKeyDAO.ListRoleKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := int32(ibeSystem.GetKeyVersion())
+	keys := &rotationKeys{
+		target: target,
+		new:    make(map[roleKeyID]*models.RoleKey),
+		any:    make(map[[2]string]*models.RoleKey),
+	}
+	for _, roleKey := range roleKeys {
+		if roleKey.KeyVersion == target {
+			keys.add(roleKey)
+		}
+	}
+
+	for _, roleKey := range roleKeys {
+		if roleKey.KeyVersion == target {
+			continue
+		}
+		keys.all = append(keys.all, roleKey)
+		if _, ok := keys.new[newRoleKeyID(roleKey)]; ok {
+			continue
+		}
+
+		capabilities, err := roleKeyCapabilities(roleKey)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := r.now().Add(roleKeyLifetime)
+		keyData := ibeSystem.GenerateRoleKey(roleKey.RoleName, roleKey.Scope, expiresAt)
+		if keyData == nil {
+			return nil, fmt.Errorf("failed to derive role key for role=%s scope=%s: domain master of key version %d unavailable", roleKey.RoleName, roleKey.Scope, target)
+		}
+
+		created, err := r.roleKeyDAO.CreateRoleKeyVersion(ctx, roleKey.RoleName, roleKey.Scope, keyData, target, capabilities, expiresAt, roleKey.CreatedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive role key for role=%s scope=%s: %w", roleKey.RoleName, roleKey.Scope, err)
+		}
+		keys.add(created)
+		log.Debug().Str("role", roleKey.RoleName).Str("scope", roleKey.Scope).Int32("key_version", target).Msg("Derived role key for new IBE key version")
+	}
+
+	return keys, nil
+}
+
+// newRoleKeyID returns the identity of a role key
+func newRoleKeyID(roleKey *models.RoleKey) roleKeyID {
+	return roleKeyID{roleName: roleKey.RoleName, scope: roleKey.Scope, createdBy: roleKey.CreatedBy}
+}
+
+// add adds a role key of the new version
+func (k *rotationKeys) add(roleKey *models.RoleKey) {
+	k.all = append(k.all, roleKey)
+	k.new[newRoleKeyID(roleKey)] = roleKey
+	k.any[[2]string{roleKey.RoleName, roleKey.Scope}] = roleKey
+}
+
+// counterpart returns the role key of the new version that replaces a role key
+func (k *rotationKeys) counterpart(roleKey *models.RoleKey) *models.RoleKey {
+	if roleKey.KeyVersion == k.target {
+		return roleKey
+	}
+	if newKey, ok := k.new[newRoleKeyID(roleKey)]; ok {
+		return newKey
+	}
+	return k.any[[2]string{roleKey.RoleName, roleKey.Scope}]
+}
+
+// reencrypt re-encrypts a mapping under the new version of the role key that
+// decrypts it. It reports whether an active role key decrypts the mapping,
+// and whether the mapping was updated; one re-encrypted concurrently by
+// another node is left alone.
+func (r *Rotator) reencrypt(ctx context.Context, ibeSystem *ibe.IBESystem, keys *rotationKeys, mapping *models.IdentityMapping, target int32) (bool, bool, error) {
+	// Mappings written before key epochs are placed in the epoch they were created in
+	writtenAt := r.now()
+	if mapping.CreatedAt.Valid {
+		writtenAt = mapping.CreatedAt.V
+	}
+
+	for _, roleKey := range keys.all {
+		newKey := keys.counterpart(roleKey)
+		if newKey == nil {
+			continue
+		}
+
+		encryptedRealIdentity, err := ibeSystem.ReencryptIdentity(mapping.EncryptedRealIdentity, mapping.PseudonymID, int(mapping.KeyVersion), roleKey.KeyData, int(target), newKey.KeyData, writtenAt)
+		if err != nil {
+			continue
+		}
+		encryptedPseudonymMapping := encryptedRealIdentity
+		if !bytes.Equal(mapping.EncryptedPseudonymMapping, mapping.EncryptedRealIdentity) {
+			if encryptedPseudonymMapping, err = ibeSystem.ReencryptIdentity(mapping.EncryptedPseudonymMapping, mapping.PseudonymID, int(mapping.KeyVersion), roleKey.KeyData, int(target), newKey.KeyData, writtenAt); err != nil {
+				continue
+			}
+		}
+
+		updated, err := r.identityMappingDAO.ReencryptMapping(ctx, mapping.MappingID, mapping.KeyVersion, target, encryptedRealIdentity, encryptedPseudonymMapping)
+		return true, updated, err
+	}
+
+	log.Warn().Str("mapping_id", mapping.MappingID.String()).Int32("key_version", mapping.KeyVersion).Msg("No active role key decrypts identity mapping")
+	return false, false, nil
+}
+
+// recordEvent records a system event, logging rather than failing the
+// rotation if the event log cannot be written
+func (r *Rotator) recordEvent(ctx context.Context, eventType, severity, message string, data map[string]interface{}) {
+	if err := r.systemEventDAO.RecordEvent(ctx, eventType, severity, message, eventComponent, data); err != nil {
+		log.Error().Err(err).Str("event_type", eventType).Msg("Failed to record IBE key rotation event")
+	}
+}
+
+// roleKeyCapabilities returns the capabilities of a role key
+func roleKeyCapabilities(roleKey *models.RoleKey) ([]string, error) {
+	capabilitiesBytes, err := roleKey.Capabilities.Value()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get capabilities value: %w", err)
+	}
+
+	var capabilities []string
+	if err := json.Unmarshal(capabilitiesBytes.([]byte), &capabilities); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal capabilities: %w", err)
+	}
+	return capabilities, nil
+}
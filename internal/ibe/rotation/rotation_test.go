@@ -0,0 +1,43 @@
+package rotation
+
+import (
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/database/models"
+)
+
+func TestRotationKeys_Counterpart(t *testing.T) {
+	keys := &rotationKeys{
+		target: 2,
+		new:    make(map[roleKeyID]*models.RoleKey),
+		any:    make(map[[2]string]*models.RoleKey),
+	}
+
+	adminNew := &models.RoleKey{RoleName: "platform_admin", Scope: "correlation", CreatedBy: 1, KeyVersion: 2}
+	userNew := &models.RoleKey{RoleName: "user", Scope: "self_correlation", CreatedBy: 1, KeyVersion: 2}
+	keys.add(adminNew)
+	keys.add(userNew)
+
+	// A key of the new version is its own counterpart
+	if got := keys.counterpart(adminNew); got != adminNew {
+		t.Errorf("Expected a new key to be its own counterpart, got %+v", got)
+	}
+
+	// An old key is replaced by the new key with the same role, scope and creator
+	adminOld := &models.RoleKey{RoleName: "platform_admin", Scope: "correlation", CreatedBy: 1, KeyVersion: 1}
+	if got := keys.counterpart(adminOld); got != adminNew {
+		t.Errorf("Expected the new key of the same creator, got %+v", got)
+	}
+
+	// Without a key of the same creator, any new key of the role and scope is used
+	userOld := &models.RoleKey{RoleName: "user", Scope: "self_correlation", CreatedBy: 7, KeyVersion: 1}
+	if got := keys.counterpart(userOld); got != userNew {
+		t.Errorf("Expected a new key of the same role and scope, got %+v", got)
+	}
+
+	// There is no counterpart for a role and scope without a new key
+	modOld := &models.RoleKey{RoleName: "moderator", Scope: "correlation", CreatedBy: 1, KeyVersion: 1}
+	if got := keys.counterpart(modOld); got != nil {
+		t.Errorf("Expected no counterpart, got %+v", got)
+	}
+}
@@ -0,0 +1,407 @@
+package ibe
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/matt0x6f/hashpost/internal/shamir"
+)
+
+// Master shares
+//
+// The masters of the admin and legal correlation domains can be split into
+// k-of-n Shamir shares, so that no single operator holds them. Each share is
+// a JSON file, optionally encrypted with a passphrase of its holder. Once a
+// domain is split its <domain>.key file is removed: the domain is locked,
+// and keys of its roles cannot be derived until a quorum of share holders
+// reconstructs the master in memory (see UnlockDomainMasters).
+
+// ShareFormatV1 is the format of share files
+const ShareFormatV1 = 1
+
+// shareKDF is the passphrase key derivation of encrypted shares and keystores
+const shareKDF = "argon2id"
+
+var (
+	// ErrDomainNotSplittable is returned when splitting a domain whose master
+	// has to be on disk
+	ErrDomainNotSplittable = errors.New("domain master cannot be split into shares")
+	// ErrDomainLocked is returned when deriving keys of a domain whose master
+	// is split into shares and has not been unlocked
+	ErrDomainLocked = errors.New("domain master is locked; unlock it from its shares")
+	// ErrShareEncrypted is returned when combining a share that has not been
+	// decrypted
+	ErrShareEncrypted = errors.New("share is encrypted")
+	// ErrWrongPassphrase is returned when a share does not decrypt
+	ErrWrongPassphrase = errors.New("wrong passphrase for share")
+	// ErrSharesMismatch is returned when shares do not belong to the same split
+	// or do not reconstruct the master they were split from
+	ErrSharesMismatch = errors.New("shares do not reconstruct the domain master")
+)
+
+// SplittableDomains returns the domains whose masters can be split into shares
+func SplittableDomains() []string {
+	return []string{
+		DOMAIN_ADMIN_CORRELATION,
+		DOMAIN_LEGAL_CORRELATION,
+	}
+}
+
+// IsSplittableDomain reports whether the master of a domain can be split into
+// shares
+func IsSplittableDomain(domain string) bool {
+	for _, splittable := range SplittableDomains() {
+		if domain == splittable {
+			return true
+		}
+	}
+	return false
+}
+
+// MasterShare is one share of a domain master
+type MasterShare struct {
+	Format     int    `json:"format"`
+	Domain     string `json:"domain"`
+	KeyVersion int    `json:"key_version"`
+	Index      int    `json:"index"`     // 1-based position of the share in its split
+	Shares     int    `json:"shares"`    // Number of shares in the split
+	Threshold  int    `json:"threshold"` // Number of shares needed to reconstruct the master
+	Check      string `json:"check"`     // Check value of the master, to verify a reconstruction
+
+	Share     string           `json:"share,omitempty"` // Hex-encoded share; empty while encrypted
+	Encrypted *ShareEncryption `json:"encrypted,omitempty"`
+}
+
+// ShareEncryption holds a share encrypted with a key derived from a passphrase
+type ShareEncryption struct {
+	PassphraseKDF
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// SplitDomainMaster splits the master of a domain into n shares, any
+// threshold of which reconstruct it
+func SplitDomainMaster(domain string, keyVersion int, master []byte, n, threshold int) ([]*MasterShare, error) {
+	if !IsSplittableDomain(domain) {
+		return nil, fmt.Errorf("%w: %s", ErrDomainNotSplittable, domain)
+	}
+
+	parts, err := shamir.Split(master, n, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split domain master for %s: %w", domain, err)
+	}
+	check, err := masterCheck(master)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]*MasterShare, n)
+	for i, part := range parts {
+		shares[i] = &MasterShare{
+			Format:     ShareFormatV1,
+			Domain:     domain,
+			KeyVersion: keyVersion,
+			Index:      i + 1,
+			Shares:     n,
+			Threshold:  threshold,
+			Check:      check,
+			Share:      hex.EncodeToString(part),
+		}
+		clear(part)
+	}
+	return shares, nil
+}
+
+// CombineDomainMaster reconstructs a domain master from shares of the same
+// split. It fails unless the shares are decrypted, reach the threshold and
+// reconstruct the master they were split from.
+func CombineDomainMaster(shares []*MasterShare) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, shamir.ErrTooFewShares
+	}
+
+	first := shares[0]
+	parts := make([][]byte, 0, len(shares))
+	defer func() {
+		for _, part := range parts {
+			clear(part)
+		}
+	}()
+	for _, share := range shares {
+		if share.Format != ShareFormatV1 {
+			return nil, fmt.Errorf("unsupported share format %d", share.Format)
+		}
+		if share.Domain != first.Domain || share.KeyVersion != first.KeyVersion ||
+			share.Threshold != first.Threshold || share.Shares != first.Shares || share.Check != first.Check {
+			return nil, fmt.Errorf("%w: share %d is of another split", ErrSharesMismatch, share.Index)
+		}
+		if share.Encrypted != nil {
+			return nil, fmt.Errorf("%w: share %d", ErrShareEncrypted, share.Index)
+		}
+		part, err := hex.DecodeString(share.Share)
+		if err != nil || len(part) == 0 || int(part[len(part)-1]) != share.Index {
+			return nil, fmt.Errorf("%w: share %d", shamir.ErrInvalidShare, share.Index)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) < first.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d shares needed for %s", shamir.ErrTooFewShares, len(parts), first.Threshold, first.Domain)
+	}
+
+	master, err := shamir.Combine(parts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine shares for %s: %w", first.Domain, err)
+	}
+	check, err := masterCheck(master)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(check), []byte(first.Check)) != 1 {
+		clear(master)
+		return nil, fmt.Errorf("%w for %s", ErrSharesMismatch, first.Domain)
+	}
+	return master, nil
+}
+
+// masterCheck returns the check value of a domain master. It identifies the
+// master without revealing it.
+func masterCheck(master []byte) (string, error) {
+	check, err := deriveKey(master, "master-check")
+	if err != nil {
+		return "", fmt.Errorf("failed to derive master check value: %w", err)
+	}
+	return hex.EncodeToString(check[:8]), nil
+}
+
+// Encrypt encrypts the share with a key derived from a passphrase
+func (s *MasterShare) Encrypt(passphrase string) error {
+	if s.Encrypted != nil {
+		return fmt.Errorf("share %d is already encrypted", s.Index)
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+	part, err := hex.DecodeString(s.Share)
+	if err != nil {
+		return fmt.Errorf("%w: share %d", shamir.ErrInvalidShare, s.Index)
+	}
+	defer clear(part)
+
+	kdf, err := newPassphraseKDF()
+	if err != nil {
+		return err
+	}
+	encrypted := &ShareEncryption{PassphraseKDF: kdf}
+	gcm, err := encrypted.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	encrypted.Nonce = hex.EncodeToString(nonce)
+	encrypted.Ciphertext = hex.EncodeToString(gcm.Seal(nil, nonce, part, s.aad()))
+
+	s.Encrypted = encrypted
+	s.Share = ""
+	return nil
+}
+
+// Decrypt decrypts the share with a passphrase. Shares that are not
+// encrypted are left as they are.
+func (s *MasterShare) Decrypt(passphrase string) error {
+	if s.Encrypted == nil {
+		return nil
+	}
+	gcm, err := s.Encrypted.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	nonce, err := hex.DecodeString(s.Encrypted.Nonce)
+	if err != nil || len(nonce) != gcm.NonceSize() {
+		return fmt.Errorf("%w: share %d has an invalid nonce", shamir.ErrInvalidShare, s.Index)
+	}
+	ciphertext, err := hex.DecodeString(s.Encrypted.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("%w: share %d has an invalid ciphertext", shamir.ErrInvalidShare, s.Index)
+	}
+	part, err := gcm.Open(nil, nonce, ciphertext, s.aad())
+	if err != nil {
+		return fmt.Errorf("%w %d", ErrWrongPassphrase, s.Index)
+	}
+	defer clear(part)
+
+	s.Share = hex.EncodeToString(part)
+	s.Encrypted = nil
+	return nil
+}
+
+// aad binds an encrypted share to its split, so that the metadata of a share
+// file cannot be changed without the passphrase
+func (s *MasterShare) aad() []byte {
+	return []byte(fmt.Sprintf("hashpost/ibe/share/v%d/%s/%d/%d/%d/%d/%s",
+		s.Format, s.Domain, s.KeyVersion, s.Index, s.Shares, s.Threshold, s.Check))
+}
+
+// ShareFileName returns the name of the file holding a share
+func ShareFileName(domain string, keyVersion, index int) string {
+	return fmt.Sprintf("%s.v%d.share%d.json", domain, keyVersion, index)
+}
+
+// WriteShare writes a share to a directory. It never overwrites an existing
+// share file.
+func WriteShare(dir string, share *MasterShare) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create shares directory: %w", err)
+	}
+	data, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode share: %w", err)
+	}
+
+	path := filepath.Join(dir, ShareFileName(share.Domain, share.KeyVersion, share.Index))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create share file: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write share file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write share file: %w", err)
+	}
+	return path, nil
+}
+
+// ReadShare reads a share file
+func ReadShare(path string) (*MasterShare, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read share file: %w", err)
+	}
+	var share MasterShare
+	if err := json.Unmarshal(data, &share); err != nil {
+		return nil, fmt.Errorf("failed to decode share file %s: %w", path, err)
+	}
+	if share.Format != ShareFormatV1 {
+		return nil, fmt.Errorf("unsupported share format %d in %s", share.Format, path)
+	}
+	return &share, nil
+}
+
+// FindShares returns the share files of a domain master in a directory,
+// ordered by share index
+func FindShares(dir, domain string, keyVersion int) ([]string, error) {
+	prefix := fmt.Sprintf("%s.v%d.share", domain, keyVersion)
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	index := func(path string) int {
+		i, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".json"))
+		return i
+	}
+	sort.Slice(paths, func(i, j int) bool { return index(paths[i]) < index(paths[j]) })
+	return paths, nil
+}
+
+// PassphraseFunc returns the passphrase of an encrypted share
+type PassphraseFunc func(share *MasterShare) (string, error)
+
+// LockedDomains returns the domains whose masters are not loaded
+func (ibe *IBESystem) LockedDomains() []string {
+	var locked []string
+	for _, domain := range Domains() {
+		if _, ok := ibe.separated.domainMasters[domain]; !ok {
+			locked = append(locked, domain)
+		}
+	}
+	return locked
+}
+
+// UnlockDomainMasters reconstructs the masters of the system's locked domains
+// from the shares of its key version in sharesDir, decrypting encrypted
+// shares with the passphrases returned by passphrase. Domains without shares
+// stay locked. It must be called before the system is used, and returns the
+// unlocked domains.
+func (ibe *IBESystem) UnlockDomainMasters(sharesDir string, passphrase PassphraseFunc) ([]string, error) {
+	var unlocked []string
+	for _, domain := range ibe.LockedDomains() {
+		if !IsSplittableDomain(domain) {
+			continue
+		}
+		master, err := UnlockDomainMaster(sharesDir, domain, ibe.GetKeyVersion(), passphrase)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return unlocked, err
+		}
+		ibe.separated.domainMasters[domain] = master
+		unlocked = append(unlocked, domain)
+	}
+	return unlocked, nil
+}
+
+// UnlockDomainMaster reconstructs a domain master from its shares in
+// sharesDir. Shares are read in order until the threshold is reached. It
+// returns an error wrapping os.ErrNotExist if there are no shares.
+func UnlockDomainMaster(sharesDir, domain string, keyVersion int, passphrase PassphraseFunc) ([]byte, error) {
+	paths, err := FindShares(sharesDir, domain, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no shares of key version %d for %s in %s: %w", keyVersion, domain, sharesDir, os.ErrNotExist)
+	}
+
+	var shares []*MasterShare
+	for _, path := range paths {
+		share, err := ReadShare(path)
+		if err != nil {
+			return nil, err
+		}
+		if share.Domain != domain || share.KeyVersion != keyVersion {
+			return nil, fmt.Errorf("%w: %s is a share of %s key version %d", ErrSharesMismatch, path, share.Domain, share.KeyVersion)
+		}
+		if err := decryptShare(share, passphrase); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+		if len(shares) == share.Threshold {
+			break
+		}
+	}
+	return CombineDomainMaster(shares)
+}
+
+// decryptShare decrypts a share with the passphrase of its holder
+func decryptShare(share *MasterShare, passphrase PassphraseFunc) error {
+	if share.Encrypted == nil {
+		return nil
+	}
+	if passphrase == nil {
+		return fmt.Errorf("%w: share %d of %s needs a passphrase", ErrShareEncrypted, share.Index, share.Domain)
+	}
+	secret, err := passphrase(share)
+	if err != nil {
+		return fmt.Errorf("failed to get passphrase for share %d of %s: %w", share.Index, share.Domain, err)
+	}
+	return share.Decrypt(secret)
+}
+
+// Label describes the share for prompts and reports
+func (s *MasterShare) Label() string {
+	return fmt.Sprintf("%s key version %d, share %d of %d", s.Domain, s.KeyVersion, s.Index, s.Shares)
+}
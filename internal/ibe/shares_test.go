@@ -0,0 +1,173 @@
+package ibe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/shamir"
+)
+
+func TestSplitDomainMaster(t *testing.T) {
+	master := bytes.Repeat([]byte{0x42}, 32)
+
+	if _, err := SplitDomainMaster(DOMAIN_USER_PSEUDONYMS, 1, master, 5, 3); !errors.Is(err, ErrDomainNotSplittable) {
+		t.Errorf("Expected ErrDomainNotSplittable, got %v", err)
+	}
+
+	shares, err := SplitDomainMaster(DOMAIN_LEGAL_CORRELATION, 1, master, 5, 3)
+	if err != nil {
+		t.Fatalf("Failed to split domain master: %v", err)
+	}
+
+	combined, err := CombineDomainMaster([]*MasterShare{shares[4], shares[1], shares[2]})
+	if err != nil {
+		t.Fatalf("Failed to combine shares: %v", err)
+	}
+	if !bytes.Equal(combined, master) {
+		t.Error("Shares did not reconstruct the domain master")
+	}
+
+	if _, err := CombineDomainMaster(shares[:2]); !errors.Is(err, shamir.ErrTooFewShares) {
+		t.Errorf("Expected ErrTooFewShares below the threshold, got %v", err)
+	}
+
+	// A share of another split of the same master does not combine
+	other, err := SplitDomainMaster(DOMAIN_LEGAL_CORRELATION, 1, master, 5, 3)
+	if err != nil {
+		t.Fatalf("Failed to split domain master: %v", err)
+	}
+	if _, err := CombineDomainMaster([]*MasterShare{shares[0], shares[1], other[2]}); !errors.Is(err, ErrSharesMismatch) {
+		t.Errorf("Expected ErrSharesMismatch for shares of different splits, got %v", err)
+	}
+
+	// Nor does a corrupted share
+	corrupted := *shares[2]
+	part, _ := hex.DecodeString(corrupted.Share)
+	part[0] ^= 1
+	corrupted.Share = hex.EncodeToString(part)
+	if _, err := CombineDomainMaster([]*MasterShare{shares[0], shares[1], &corrupted}); !errors.Is(err, ErrSharesMismatch) {
+		t.Errorf("Expected ErrSharesMismatch for a corrupted share, got %v", err)
+	}
+}
+
+func TestMasterShare_Encryption(t *testing.T) {
+	shares, err := SplitDomainMaster(DOMAIN_ADMIN_CORRELATION, 2, bytes.Repeat([]byte{0x17}, 32), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split domain master: %v", err)
+	}
+	share := shares[0]
+	plain := share.Share
+
+	if err := share.Encrypt("correct horse"); err != nil {
+		t.Fatalf("Failed to encrypt share: %v", err)
+	}
+	if share.Share != "" || share.Encrypted == nil {
+		t.Fatal("Encrypted share should not hold the plain share")
+	}
+	if _, err := CombineDomainMaster(shares[:2]); !errors.Is(err, ErrShareEncrypted) {
+		t.Errorf("Expected ErrShareEncrypted, got %v", err)
+	}
+
+	// The share is bound to its metadata
+	moved := *share
+	moved.Index = 3
+	if err := moved.Decrypt("correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase for changed metadata, got %v", err)
+	}
+
+	if err := share.Decrypt("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := share.Decrypt("correct horse"); err != nil {
+		t.Fatalf("Failed to decrypt share: %v", err)
+	}
+	if share.Share != plain || share.Encrypted != nil {
+		t.Error("Decrypted share does not match the original")
+	}
+}
+
+func TestIBESystem_UnlockDomainMasters(t *testing.T) {
+	keysDir := t.TempDir()
+	sharesDir := t.TempDir()
+
+	masters, err := GenerateDomainMasters()
+	if err != nil {
+		t.Fatalf("Failed to generate domain masters: %v", err)
+	}
+	if err := saveDomainMasters(keysDir, masters); err != nil {
+		t.Fatalf("Failed to save domain masters: %v", err)
+	}
+
+	// Split the legal master, encrypting one share, and remove its file
+	shares, err := SplitDomainMaster(DOMAIN_LEGAL_CORRELATION, 1, masters[DOMAIN_LEGAL_CORRELATION], 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split domain master: %v", err)
+	}
+	if err := shares[0].Encrypt("passphrase"); err != nil {
+		t.Fatalf("Failed to encrypt share: %v", err)
+	}
+	for _, share := range shares {
+		if _, err := WriteShare(sharesDir, share); err != nil {
+			t.Fatalf("Failed to write share: %v", err)
+		}
+	}
+	if _, err := WriteShare(sharesDir, shares[0]); err == nil {
+		t.Error("Writing a share should never overwrite an existing one")
+	}
+	if err := os.Remove(filepath.Join(keysDir, DOMAIN_LEGAL_CORRELATION+".key")); err != nil {
+		t.Fatalf("Failed to remove domain master: %v", err)
+	}
+
+	// The legal domain is locked, and its role keys cannot be derived
+	ibe, err := NewIBESystemFromConfig(keysDir, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to load domain masters: %v", err)
+	}
+	if locked := ibe.LockedDomains(); len(locked) != 1 || locked[0] != DOMAIN_LEGAL_CORRELATION {
+		t.Fatalf("Expected only the legal domain to be locked, got %v", locked)
+	}
+	expiration := time.Now().Add(time.Hour)
+	if ibe.GenerateRoleKey("legal_team", "correlation", expiration) != nil {
+		t.Error("Role keys of a locked domain should not be derived")
+	}
+	if ibe.ValidateRoleKey(nil, "legal_team", "correlation", expiration) {
+		t.Error("No role key should validate in a locked domain")
+	}
+	if bytes.Equal(ibe.GetMasterSecret(), masters[DOMAIN_LEGAL_CORRELATION]) {
+		t.Error("GetMasterSecret should never return a correlation master")
+	}
+
+	// A missing master of a domain that cannot be split is an error
+	if err := os.Remove(filepath.Join(keysDir, DOMAIN_MOD_CORRELATION+".key")); err != nil {
+		t.Fatalf("Failed to remove domain master: %v", err)
+	}
+	if _, err := LoadDomainMastersFromDir(keysDir); err == nil {
+		t.Error("Expected an error for a missing moderator domain master")
+	}
+
+	// Share holders unlock it with the passphrase of the encrypted share
+	var prompted []int
+	unlocked, err := ibe.UnlockDomainMasters(sharesDir, func(share *MasterShare) (string, error) {
+		prompted = append(prompted, share.Index)
+		return "passphrase", nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to unlock domain masters: %v", err)
+	}
+	if len(unlocked) != 1 || unlocked[0] != DOMAIN_LEGAL_CORRELATION || len(prompted) != 1 || prompted[0] != 1 {
+		t.Errorf("Unexpected unlock: unlocked %v, prompted for shares %v", unlocked, prompted)
+	}
+	if len(ibe.LockedDomains()) != 0 {
+		t.Errorf("Expected no locked domains, got %v", ibe.LockedDomains())
+	}
+
+	full := NewIBESystemWithOptions(IBEOptions{DomainMasters: masters})
+	if !bytes.Equal(ibe.GenerateRoleKey("legal_team", "correlation", expiration), full.GenerateRoleKey("legal_team", "correlation", expiration)) {
+		t.Error("Unlocked domain should derive the same role keys as the original master")
+	}
+}
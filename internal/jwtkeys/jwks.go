@@ -0,0 +1,155 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK is the public half of a signing key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verifiers should accept at the given
+// time. Keys that have not started signing yet are included so verifiers can
+// cache them ahead of activation.
+func (ks *KeySet) JWKS(now time.Time) JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if !key.publishedAt(now) {
+			continue
+		}
+		jwk, err := key.JWK()
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWK returns the key's public half as a JWK
+func (k *Key) JWK() (JWK, error) {
+	jwk, err := publicJWK(k.PublicKey())
+	if err != nil {
+		return JWK{}, err
+	}
+	jwk.KeyID = k.ID
+	jwk.Algorithm = k.Algorithm
+	jwk.Use = "sig"
+	return jwk, nil
+}
+
+// PublicKey parses the public key from a JWK. The result can be used to
+// verify tokens signed with the key's algorithm.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK x coordinate: %w", err)
+	}
+
+	switch {
+	case j.KeyType == "OKP" && j.Curve == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	case j.KeyType == "EC" && j.Curve == "P-256":
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK y coordinate: %w", err)
+		}
+		// Validate the point before building the key
+		point := append([]byte{4}, append(x, y...)...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("invalid P-256 public key: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s crv %s", ErrUnsupportedAlgorithm, j.KeyType, j.Curve)
+	}
+}
+
+// thumbprint computes the key's RFC 7638 JWK thumbprint, used as its kid
+func (k *Key) thumbprint() (string, error) {
+	jwk, err := publicJWK(k.PublicKey())
+	if err != nil {
+		return "", err
+	}
+
+	// RFC 7638 hashes the required members in lexicographic order with no whitespace
+	var members any
+	switch jwk.KeyType {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	}
+
+	encoded, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key thumbprint: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// publicJWK encodes the key material of a public key, without kid, alg or use
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	switch pub := public.(type) {
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("%w: ECDSA keys must use P-256", ErrUnsupportedAlgorithm)
+		}
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, fmt.Errorf("invalid ECDSA public key: %w", err)
+		}
+		// Uncompressed point encoding: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		return JWK{
+			KeyType: "EC",
+			Curve:   "P-256",
+			X:       base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+			Y:       base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, public)
+	}
+}
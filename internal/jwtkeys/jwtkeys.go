@@ -0,0 +1,240 @@
+// Package jwtkeys manages the asymmetric keys that sign JWTs. Every key is
+// identified by a kid, and several keys can be published at once so that the
+// signing key can be rotated while tokens signed by the previous key are
+// still in circulation.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+)
+
+var (
+	// ErrNoSigningKey is returned when no key in the set may sign at this time
+	ErrNoSigningKey = errors.New("no active signing key")
+	// ErrUnknownKey is returned when a token names a kid that is not published
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrUnsupportedAlgorithm is returned for algorithms other than EdDSA and ES256
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+// Key is a signing key. A key is published, and accepted for verification,
+// until RetiresAt. It is used for signing from ActivatesAt until a newer key
+// activates.
+type Key struct {
+	ID          string
+	Algorithm   string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiresAt   time.Time // zero until the key is superseded
+
+	private crypto.Signer
+}
+
+// GenerateKey creates a new key for the given algorithm that starts signing at activatesAt
+func GenerateKey(alg string, activatesAt time.Time) (*Key, error) {
+	var private crypto.Signer
+	switch alg {
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		private = key
+	case AlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate P-256 key: %w", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+
+	return newKey(alg, private, time.Now().UTC(), activatesAt.UTC(), time.Time{})
+}
+
+// newKey wraps a private key, deriving its kid from the public key
+func newKey(alg string, private crypto.Signer, createdAt, activatesAt, retiresAt time.Time) (*Key, error) {
+	key := &Key{
+		Algorithm:   alg,
+		CreatedAt:   createdAt,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		private:     private,
+	}
+
+	id, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+	return key, nil
+}
+
+// PublicKey returns the key's public half
+func (k *Key) PublicKey() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// publishedAt reports whether the key is still accepted for verification
+func (k *Key) publishedAt(now time.Time) bool {
+	return k.RetiresAt.IsZero() || now.Before(k.RetiresAt)
+}
+
+// activeAt reports whether the key may sign at the given time
+func (k *Key) activeAt(now time.Time) bool {
+	return !now.Before(k.ActivatesAt) && k.publishedAt(now)
+}
+
+// signingMethod returns the JWT signing method for the key's algorithm
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgES256 {
+		return jwt.SigningMethodES256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// KeySet is the set of keys a server signs and verifies tokens with. A key
+// set is not modified while it is in use; rotation happens on disk and takes
+// effect when servers load the new set.
+type KeySet struct {
+	keys []*Key
+}
+
+// NewKeySet creates a key set from the given keys
+func NewKeySet(keys ...*Key) *KeySet {
+	ks := &KeySet{keys: append([]*Key(nil), keys...)}
+	ks.sort()
+	return ks
+}
+
+// GenerateKeySet creates a key set holding a single new key that is active immediately
+func GenerateKeySet(alg string) (*KeySet, error) {
+	key, err := GenerateKey(alg, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key), nil
+}
+
+// Keys returns every key in the set, oldest activation first
+func (ks *KeySet) Keys() []*Key {
+	return append([]*Key(nil), ks.keys...)
+}
+
+// SigningKey returns the most recently activated key that may sign at the given time
+func (ks *KeySet) SigningKey(now time.Time) (*Key, error) {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		if ks.keys[i].activeAt(now) {
+			return ks.keys[i], nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Sign signs the claims with the current signing key and names it in the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header. It
+// is meant to be passed to the jwt parse functions together with
+// jwt.WithValidMethods(ks.Algorithms()).
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("%w: token has no kid", ErrUnknownKey)
+	}
+
+	now := time.Now()
+	for _, key := range ks.keys {
+		if key.ID != kid || !key.publishedAt(now) {
+			continue
+		}
+		// A key only verifies tokens signed with its own algorithm
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+		}
+		return key.PublicKey(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+}
+
+// Algorithms returns the signing algorithms used by keys in the set
+func (ks *KeySet) Algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range ks.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	return algs
+}
+
+// Rotate adds a new key that starts signing activateIn from now. Keys that
+// are not yet retiring are retired overlap after the new key activates, which
+// should be at least the lifetime of the longest-lived token they signed.
+func (ks *KeySet) Rotate(alg string, activateIn, overlap time.Duration, now time.Time) (*Key, error) {
+	activatesAt := now.Add(activateIn).UTC()
+	key, err := GenerateKey(alg, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, existing := range ks.keys {
+		if existing.RetiresAt.IsZero() {
+			existing.RetiresAt = activatesAt.Add(overlap)
+		}
+	}
+
+	ks.keys = append(ks.keys, key)
+	ks.sort()
+	return key, nil
+}
+
+// Prune removes keys that have retired and returns them
+func (ks *KeySet) Prune(now time.Time) []*Key {
+	var kept, removed []*Key
+	for _, key := range ks.keys {
+		if key.publishedAt(now) {
+			kept = append(kept, key)
+		} else {
+			removed = append(removed, key)
+		}
+	}
+	ks.keys = kept
+	return removed
+}
+
+// sort orders keys by activation time so the newest active key is found last
+func (ks *KeySet) sort() {
+	sort.SliceStable(ks.keys, func(i, j int) bool {
+		return ks.keys[i].ActivatesAt.Before(ks.keys[j].ActivatesAt)
+	})
+}
@@ -0,0 +1,204 @@
+package jwtkeys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func parse(ks *KeySet, token string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, ks.Keyfunc, jwt.WithValidMethods(ks.Algorithms()))
+	return claims, err
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgES256} {
+		t.Run(alg, func(t *testing.T) {
+			ks, err := GenerateKeySet(alg)
+			if err != nil {
+				t.Fatalf("Failed to generate key set: %v", err)
+			}
+
+			token, err := ks.Sign(&jwt.RegisteredClaims{Subject: "42"})
+			if err != nil {
+				t.Fatalf("Failed to sign token: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("Failed to decode token: %v", err)
+			}
+			if parsed.Header["kid"] != ks.Keys()[0].ID || parsed.Header["alg"] != alg {
+				t.Errorf("Unexpected header %v", parsed.Header)
+			}
+
+			claims, err := parse(ks, token)
+			if err != nil {
+				t.Fatalf("Failed to verify token: %v", err)
+			}
+			if claims.Subject != "42" {
+				t.Errorf("Expected subject 42, got %q", claims.Subject)
+			}
+
+			other, _ := GenerateKeySet(alg)
+			if _, err := parse(other, token); err == nil {
+				t.Error("Expected token to be rejected by an unrelated key set")
+			}
+		})
+	}
+}
+
+func TestKeyfunc_RejectsHMACAndMissingKid(t *testing.T) {
+	ks, err := GenerateKeySet(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key set: %v", err)
+	}
+
+	// An HMAC token naming a published kid must not verify, whatever its secret
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{Subject: "42"})
+	hmacToken.Header["kid"] = ks.Keys()[0].ID
+	signed, err := hmacToken.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign HMAC token: %v", err)
+	}
+	if _, err := parse(ks, signed); err == nil {
+		t.Error("Expected HMAC token to be rejected")
+	}
+
+	key := ks.Keys()[0]
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &jwt.RegisteredClaims{}).SignedString(key.private)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := parse(ks, unnamed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for token without kid, got %v", err)
+	}
+}
+
+func TestRotate_OverlapAndActivation(t *testing.T) {
+	ks, err := GenerateKeySet(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key set: %v", err)
+	}
+	oldKey := ks.Keys()[0]
+	oldToken, err := ks.Sign(&jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	now := time.Now()
+	newKey, err := ks.Rotate(AlgES256, time.Hour, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+
+	// The new key is published right away but does not sign until it activates
+	if signing, _ := ks.SigningKey(now); signing.ID != oldKey.ID {
+		t.Errorf("Expected old key to keep signing before activation, got %s", signing.ID)
+	}
+	if signing, _ := ks.SigningKey(now.Add(2 * time.Hour)); signing.ID != newKey.ID {
+		t.Errorf("Expected new key to sign after activation, got %s", signing.ID)
+	}
+	if got := len(ks.JWKS(now).Keys); got != 2 {
+		t.Errorf("Expected both keys to be published, got %d", got)
+	}
+
+	// Tokens from the old key verify until it retires
+	if _, err := parse(ks, oldToken); err != nil {
+		t.Errorf("Expected old token to verify during overlap: %v", err)
+	}
+	if want := newKey.ActivatesAt.Add(24 * time.Hour); !oldKey.RetiresAt.Equal(want) {
+		t.Errorf("Expected old key to retire at %v, got %v", want, oldKey.RetiresAt)
+	}
+	if got := len(ks.JWKS(oldKey.RetiresAt).Keys); got != 1 {
+		t.Errorf("Expected only the new key to be published after retirement, got %d", got)
+	}
+
+	removed := ks.Prune(oldKey.RetiresAt)
+	if len(removed) != 1 || removed[0].ID != oldKey.ID {
+		t.Errorf("Expected old key to be pruned, got %v", removed)
+	}
+	if _, err := parse(ks, oldToken); err == nil {
+		t.Error("Expected old token to be rejected after its key was pruned")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := LoadKeySet(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist for empty directory, got %v", err)
+	}
+
+	ks, err := GenerateKeySet(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key set: %v", err)
+	}
+	if _, err := ks.Rotate(AlgES256, 0, time.Hour, time.Now()); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	if err := ks.Save(dir); err != nil {
+		t.Fatalf("Failed to save key set: %v", err)
+	}
+
+	loaded, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+	if len(loaded.Keys()) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(loaded.Keys()))
+	}
+	for i, key := range loaded.Keys() {
+		original := ks.Keys()[i]
+		if key.ID != original.ID || key.Algorithm != original.Algorithm || !key.RetiresAt.Equal(original.RetiresAt) {
+			t.Errorf("Key %d did not round trip: %+v vs %+v", i, key, original)
+		}
+	}
+
+	token, err := ks.Sign(&jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := parse(loaded, token); err != nil {
+		t.Errorf("Expected loaded key set to verify tokens: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, keyFileName(ks.Keys()[0].ID)))
+	if err != nil {
+		t.Fatalf("Failed to stat key file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestJWK_PublicKeyRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgES256} {
+		t.Run(alg, func(t *testing.T) {
+			ks, err := GenerateKeySet(alg)
+			if err != nil {
+				t.Fatalf("Failed to generate key set: %v", err)
+			}
+			token, err := ks.Sign(&jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("Failed to sign token: %v", err)
+			}
+
+			// A verifier holding only the published JWK can check the token
+			jwk := ks.JWKS(time.Now()).Keys[0]
+			public, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatalf("Failed to parse JWK: %v", err)
+			}
+			_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public, nil }, jwt.WithValidMethods([]string{jwk.Algorithm}))
+			if err != nil {
+				t.Errorf("Expected token to verify with the published JWK: %v", err)
+			}
+		})
+	}
+}
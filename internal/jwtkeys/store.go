@@ -0,0 +1,196 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestFileName is the file in the keys directory that lists the key set.
+// Each key's private half is stored next to it in <kid>.pem.
+const manifestFileName = "keyset.json"
+
+// manifest is the on-disk description of a key set
+type manifest struct {
+	Keys []manifestKey `json:"keys"`
+}
+
+// manifestKey is the on-disk description of a single key
+type manifestKey struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+}
+
+// LoadKeySet reads a key set from a keys directory. The returned error wraps
+// os.ErrNotExist if the directory holds no key set.
+func LoadKeySet(dir string) (*KeySet, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key set: %w", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse key set: %w", err)
+	}
+	if len(m.Keys) == 0 {
+		return nil, fmt.Errorf("key set in %s has no keys", dir)
+	}
+
+	keys := make([]*Key, 0, len(m.Keys))
+	for _, entry := range m.Keys {
+		private, err := readPrivateKey(filepath.Join(dir, keyFileName(entry.ID)))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkAlgorithm(entry.Algorithm, private); err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", entry.ID, err)
+		}
+
+		var retiresAt time.Time
+		if entry.RetiresAt != nil {
+			retiresAt = *entry.RetiresAt
+		}
+		key, err := newKey(entry.Algorithm, private, entry.CreatedAt, entry.ActivatesAt, retiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", entry.ID, err)
+		}
+		if key.ID != entry.ID {
+			return nil, fmt.Errorf("key file for %s holds key %s", entry.ID, key.ID)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys...), nil
+}
+
+// Save writes the key set to a keys directory. Private keys are written only
+// if their file does not exist yet; the manifest is replaced atomically.
+func (ks *KeySet) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create keys directory: %w", err)
+	}
+
+	m := manifest{Keys: make([]manifestKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		if err := writePrivateKey(filepath.Join(dir, keyFileName(key.ID)), key.private); err != nil {
+			return err
+		}
+
+		entry := manifestKey{
+			ID:          key.ID,
+			Algorithm:   key.Algorithm,
+			CreatedAt:   key.CreatedAt,
+			ActivatesAt: key.ActivatesAt,
+		}
+		if !key.RetiresAt.IsZero() {
+			retiresAt := key.RetiresAt
+			entry.RetiresAt = &retiresAt
+		}
+		m.Keys = append(m.Keys, entry)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key set: %w", err)
+	}
+
+	tmp := filepath.Join(dir, manifestFileName+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key set: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, manifestFileName)); err != nil {
+		return fmt.Errorf("failed to write key set: %w", err)
+	}
+	return nil
+}
+
+// RemoveKeyFile deletes the private key file of a key that has been pruned
+func RemoveKeyFile(dir, kid string) error {
+	if err := os.Remove(filepath.Join(dir, keyFileName(kid))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove key %s: %w", kid, err)
+	}
+	return nil
+}
+
+// keyFileName returns the file name of a key's private half. Thumbprint kids
+// are base64url, so they are safe to use as file names.
+func keyFileName(kid string) string {
+	return kid + ".pem"
+}
+
+// readPrivateKey reads a PKCS #8 PEM private key
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no PKCS #8 private key in %s", path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, parsed)
+	}
+	return signer, nil
+}
+
+// writePrivateKey writes a PKCS #8 PEM private key readable only by the owner
+func writePrivateKey(path string, private crypto.Signer) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create private key file: %w", err)
+	}
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	return nil
+}
+
+// checkAlgorithm verifies that a private key matches the algorithm recorded for it
+func checkAlgorithm(alg string, private crypto.Signer) error {
+	switch private.(type) {
+	case ed25519.PrivateKey:
+		if alg == AlgEdDSA {
+			return nil
+		}
+	case *ecdsa.PrivateKey:
+		if alg == AlgES256 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %T key for %s", ErrUnsupportedAlgorithm, private, alg)
+}
@@ -0,0 +1,73 @@
+// Package lockout computes the backoff and lockout that follow failed logins.
+//
+// Failures are counted separately per account and per client address. Each
+// count maps to a period during which further attempts are refused: nothing
+// for the first few failures, then an exponentially growing delay, and once
+// the policy's threshold is reached a lockout that keeps doubling with every
+// further failure. Counting and storage are left to the caller.
+package lockout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+// Block is the consequence of a failed login
+type Block struct {
+	Duration time.Duration // How long further attempts are refused; zero if they are not
+	Locked   bool          // Whether the failure reached the lockout threshold
+}
+
+// ForFailures returns the block that follows the failure bringing the count to failures
+func ForFailures(policy *config.LoginLockoutPolicy, failures int) Block {
+	if policy.MaxFailures > 0 && failures >= policy.MaxFailures {
+		return Block{
+			Duration: doubled(policy.LockoutDuration, failures-policy.MaxFailures, policy.MaxLockout),
+			Locked:   true,
+		}
+	}
+	if failures <= policy.FreeFailures {
+		return Block{}
+	}
+	return Block{Duration: doubled(policy.BaseDelay, failures-policy.FreeFailures-1, policy.MaxDelay)}
+}
+
+// doubled returns base doubled n times, capped at limit if limit is set
+func doubled(base time.Duration, n int, limit time.Duration) time.Duration {
+	d := base
+	for i := 0; i < n; i++ {
+		if limit > 0 && d >= limit {
+			break
+		}
+		d *= 2
+	}
+	if limit > 0 && d > limit {
+		return limit
+	}
+	return d
+}
+
+// AccountSubject returns the key failures against an email address are counted
+// under. Addresses are counted whether or not they belong to an account, so
+// the response to a throttled login does not reveal which ones do.
+func AccountSubject(email string) string {
+	return subject("account:" + strings.ToLower(strings.TrimSpace(email)))
+}
+
+// IPSubject returns the key failures from a client network are counted
+// under. The network is identified by the keyed hash of its address made by
+// the netprivacy package, so failures from one client are counted under a
+// new subject every day.
+func IPSubject(ipHash string) string {
+	return subject("ip:" + ipHash)
+}
+
+// subject hashes a key so that neither email addresses nor address hashes are stored
+func subject(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func TestForFailures(t *testing.T) {
+	policy := &config.LoginLockoutPolicy{
+		FreeFailures:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		MaxFailures:     6,
+		LockoutDuration: 10 * time.Minute,
+		MaxLockout:      30 * time.Minute,
+	}
+
+	tests := []struct {
+		failures int
+		expected Block
+	}{
+		{1, Block{}},
+		{2, Block{}},
+		{3, Block{Duration: time.Second}},
+		{4, Block{Duration: 2 * time.Second}},
+		{5, Block{Duration: 4 * time.Second}},
+		{6, Block{Duration: 10 * time.Minute, Locked: true}},
+		{7, Block{Duration: 20 * time.Minute, Locked: true}},
+		{8, Block{Duration: 30 * time.Minute, Locked: true}},
+		{50, Block{Duration: 30 * time.Minute, Locked: true}},
+	}
+
+	for _, tt := range tests {
+		if got := ForFailures(policy, tt.failures); got != tt.expected {
+			t.Errorf("ForFailures(%d) = %+v, expected %+v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestForFailures_DelayCappedBelowThreshold(t *testing.T) {
+	policy := &config.LoginLockoutPolicy{
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		MaxFailures: 1000,
+	}
+
+	if got := ForFailures(policy, 500); got.Duration != time.Minute || got.Locked {
+		t.Errorf("Expected delay capped at 1m without a lockout, got %+v", got)
+	}
+}
+
+func TestSubjects(t *testing.T) {
+	if AccountSubject("User@Example.com ") != AccountSubject("user@example.com") {
+		t.Error("Expected account subjects to ignore case and surrounding space")
+	}
+	if AccountSubject("a@example.com") == AccountSubject("b@example.com") {
+		t.Error("Expected different addresses to have different subjects")
+	}
+
+	if IPSubject("a1b2") == IPSubject("c3d4") {
+		t.Error("Expected different address hashes to have different subjects")
+	}
+	if IPSubject("a1b2") == AccountSubject("a1b2") {
+		t.Error("Expected address and account subjects not to collide")
+	}
+	if len(IPSubject("a1b2")) != 64 {
+		t.Error("Expected subjects to be hex SHA-256 hashes")
+	}
+}
@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func TestMessage_RejectsHeaderInjection(t *testing.T) {
+	tests := []Message{
+		{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi", Body: "Body"},
+		{To: "user@example.com", Subject: "Hi\r\nBcc: victim@example.com", Body: "Body"},
+		{To: "not an address", Subject: "Hi", Body: "Body"},
+	}
+
+	for _, msg := range tests {
+		if _, err := msg.format("HashPost <no-reply@example.com>"); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Expected ErrInvalidMessage for %+v, got %v", msg, err)
+		}
+	}
+}
+
+func TestFileMailer_WritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFileMailer(dir, "HashPost <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("Failed to create file mailer: %v", err)
+	}
+
+	msg := Message{To: "user@example.com", Subject: "Verify your email", Body: "Line one\nLine two\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v (%v)", files, err)
+	}
+
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat message: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected message file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"From: HashPost <no-reply@example.com>\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"\r\n\r\nLine one\r\nLine two\r\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected message to contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestMemoryMailer_LastMessageTo(t *testing.T) {
+	m := NewMemoryMailer()
+	ctx := context.Background()
+
+	if _, ok := m.LastMessageTo("user@example.com"); ok {
+		t.Error("Expected no message before sending")
+	}
+
+	_ = m.Send(ctx, Message{To: "user@example.com", Subject: "First", Body: "1"})
+	_ = m.Send(ctx, Message{To: "other@example.com", Subject: "Other", Body: "2"})
+	_ = m.Send(ctx, Message{To: "user@example.com", Subject: "Second", Body: "3"})
+
+	msg, ok := m.LastMessageTo("user@example.com")
+	if !ok || msg.Subject != "Second" {
+		t.Errorf("Expected the latest message to user@example.com, got %+v", msg)
+	}
+	if got := len(m.Messages()); got != 3 {
+		t.Errorf("Expected 3 messages, got %d", got)
+	}
+}
+
+func TestNew_UnknownDriver(t *testing.T) {
+	if _, err := New(&config.MailConfig{Driver: "carrier-pigeon"}); err == nil {
+		t.Error("Expected an error for an unknown mail driver")
+	}
+}
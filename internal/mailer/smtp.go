@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer sends email through an SMTP server. The connection is upgraded
+// with STARTTLS when the server supports it; credentials are only sent over
+// TLS or to localhost.
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	from   string
+	sender string
+}
+
+// NewSMTPMailer creates a mailer for the given SMTP server. Username may be
+// empty for servers that do not require authentication.
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	m := &SMTPMailer{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		from:   from,
+		sender: sender.Address,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send delivers a message
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := msg.format(m.from)
+	if err != nil {
+		return err
+	}
+
+	recipient, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: invalid recipient: %v", ErrInvalidMessage, err)
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.sender, []string{recipient.Address}, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sealedPrefix marks a TOTP secret encrypted by a SecretBox. Secrets stored
+// before they were encrypted have no prefix; base32 never contains a colon.
+const sealedPrefix = "v1:"
+
+// secretKeyLength is the size of the key TOTP secrets are encrypted with
+const secretKeyLength = 32
+
+// SecretBox encrypts TOTP secrets at rest with AES-256-GCM. The user ID is
+// authenticated as additional data, so a secret copied to another account
+// does not decrypt.
+type SecretBox struct {
+	key []byte
+	gcm cipher.AEAD
+}
+
+// GenerateSecretBox creates a secret box with a new key
+func GenerateSecretBox() (*SecretBox, error) {
+	key := make([]byte, secretKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate MFA secret key: %w", err)
+	}
+	return NewSecretBox(key)
+}
+
+// NewSecretBox creates a secret box with a 32-byte key
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != secretKeyLength {
+		return nil, fmt.Errorf("MFA secret key must be %d bytes, got %d", secretKeyLength, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MFA secret cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MFA secret cipher: %w", err)
+	}
+	return &SecretBox{key: key, gcm: gcm}, nil
+}
+
+// LoadSecretBox reads a hex-encoded MFA secret key. The returned error wraps
+// os.ErrNotExist if the file does not exist.
+func LoadSecretBox(path string) (*SecretBox, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MFA secret key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode MFA secret key %s: %w", path, err)
+	}
+	return NewSecretBox(key)
+}
+
+// Save writes the key to path, readable only by the owner. An existing key is
+// never overwritten.
+func (b *SecretBox) Save(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(hex.EncodeToString(b.key) + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Seal encrypts the TOTP secret of a user for storage
+func (b *SecretBox) Seal(userID int64, secret string) (string, error) {
+	nonce := make([]byte, b.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := b.gcm.Seal(nonce, nonce, []byte(secret), additionalData(userID))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a stored TOTP secret of a user. Secrets stored before they
+// were encrypted are returned as they are; see IsSealed.
+func (b *SecretBox) Open(userID int64, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode TOTP secret: %w", err)
+	}
+	if len(sealed) < b.gcm.NonceSize() {
+		return "", fmt.Errorf("TOTP secret is too short")
+	}
+	nonce, ciphertext := sealed[:b.gcm.NonceSize()], sealed[b.gcm.NonceSize():]
+	secret, err := b.gcm.Open(nil, nonce, ciphertext, additionalData(userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// IsSealed reports whether a stored TOTP secret is encrypted
+func IsSealed(stored string) bool {
+	return strings.HasPrefix(stored, sealedPrefix)
+}
+
+// additionalData binds a sealed secret to its user
+func additionalData(userID int64) []byte {
+	return []byte("hashpost/mfa/v1/" + strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,106 @@
+package mfa
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretBox_SealAndOpen(t *testing.T) {
+	box, err := GenerateSecretBox()
+	if err != nil {
+		t.Fatalf("Failed to generate secret box: %v", err)
+	}
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	sealed, err := box.Seal(42, secret)
+	if err != nil {
+		t.Fatalf("Failed to seal secret: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, secret) {
+		t.Fatalf("Expected an encrypted secret, got %q", sealed)
+	}
+	if len(sealed) > 255 {
+		t.Errorf("Sealed secret of %d characters does not fit users.mfa_secret", len(sealed))
+	}
+
+	opened, err := box.Open(42, sealed)
+	if err != nil {
+		t.Fatalf("Failed to open secret: %v", err)
+	}
+	if opened != secret {
+		t.Errorf("Expected %q, got %q", secret, opened)
+	}
+
+	// A secret copied to another account does not decrypt
+	if _, err := box.Open(43, sealed); err == nil {
+		t.Error("Expected the secret of another user to fail to decrypt")
+	}
+
+	other, err := GenerateSecretBox()
+	if err != nil {
+		t.Fatalf("Failed to generate secret box: %v", err)
+	}
+	if _, err := other.Open(42, sealed); err == nil {
+		t.Error("Expected the secret to fail to decrypt under another key")
+	}
+}
+
+func TestSecretBox_OpenLegacySecret(t *testing.T) {
+	box, err := GenerateSecretBox()
+	if err != nil {
+		t.Fatalf("Failed to generate secret box: %v", err)
+	}
+
+	legacy := "JBSWY3DPEHPK3PXP"
+	if IsSealed(legacy) {
+		t.Fatal("Expected a plaintext secret not to be sealed")
+	}
+	opened, err := box.Open(42, legacy)
+	if err != nil || opened != legacy {
+		t.Errorf("Expected the plaintext secret back, got %q (%v)", opened, err)
+	}
+}
+
+func TestSecretBox_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mfa-secret.key")
+	if _, err := LoadSecretBox(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a missing key to wrap os.ErrNotExist, got %v", err)
+	}
+
+	box, err := GenerateSecretBox()
+	if err != nil {
+		t.Fatalf("Failed to generate secret box: %v", err)
+	}
+	if err := box.Save(path); err != nil {
+		t.Fatalf("Failed to save key: %v", err)
+	}
+	if err := box.Save(path); err == nil {
+		t.Error("Expected an existing key not to be overwritten")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat key: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	loaded, err := LoadSecretBox(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	sealed, err := box.Seal(7, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Failed to seal secret: %v", err)
+	}
+	if opened, err := loaded.Open(7, sealed); err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected the loaded key to open the secret, got %q (%v)", opened, err)
+	}
+}
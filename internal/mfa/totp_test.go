@@ -0,0 +1,107 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed from RFC 6238 Appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode_RFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := GenerateCode(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Failed to generate code: %v", err)
+		}
+		if code != tt.expected {
+			t.Errorf("GenerateCode(T=%d) = %s, expected %s", tt.unix, code, tt.expected)
+		}
+	}
+}
+
+func TestValidate_Skew(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateCode(secret, now)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	step, ok := Validate(secret, code, now)
+	if !ok {
+		t.Fatal("Expected current code to validate")
+	}
+	if step != Step(now) {
+		t.Errorf("Expected step %d, got %d", Step(now), step)
+	}
+
+	if _, ok := Validate(secret, code, now.Add(Period)); !ok {
+		t.Error("Expected code from previous step to validate")
+	}
+
+	if _, ok := Validate(secret, code, now.Add(3*Period)); ok {
+		t.Error("Expected code outside skew window to be rejected")
+	}
+
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("Expected short code to be rejected")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("HashPost", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/HashPost:user@example.com?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=HashPost", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("Expected URI to contain %s, got %s", part, uri)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatalf("Failed to generate recovery codes: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("Expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Unexpected recovery code format: %s", code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate recovery code: %s", code)
+		}
+		seen[code] = true
+	}
+
+	code := codes[0]
+	compact := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+	if HashRecoveryCode(code) != HashRecoveryCode(compact) {
+		t.Error("Expected recovery code hash to ignore dashes and case")
+	}
+}
@@ -0,0 +1,69 @@
+package netprivacy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryKeyStore keeps the daily keys in process. Hashes only match on the
+// node that made them, and not across restarts.
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[time.Time][]byte
+}
+
+// NewMemoryKeyStore creates an in-memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[time.Time][]byte)}
+}
+
+// DayKey returns the key of a day, creating it if needed
+func (s *MemoryKeyStore) DayKey(ctx context.Context, day time.Time) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[day]; ok {
+		return key, nil
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	s.keys[day] = key
+	return key, nil
+}
+
+// PastKeys returns the keys of the days from since up to but excluding before
+func (s *MemoryKeyStore) PastKeys(ctx context.Context, since, before time.Time) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days := make([]time.Time, 0, len(s.keys))
+	for day := range s.keys {
+		if !day.Before(since) && day.Before(before) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].After(days[j]) })
+
+	keys := make([][]byte, len(days))
+	for i, day := range days {
+		keys[i] = s.keys[day]
+	}
+	return keys, nil
+}
+
+// PurgeBefore deletes the keys of the days before day
+func (s *MemoryKeyStore) PurgeBefore(ctx context.Context, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for d := range s.keys {
+		if d.Before(day) {
+			delete(s.keys, d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package netprivacy reduces client network data to forms that can be
+// stored. Client addresses are never kept: they are replaced by a keyed hash
+// and a coarse network prefix, and user agents by their browser family.
+//
+// Addresses are hashed with HMAC-SHA256 under a random key that changes every
+// UTC day. The same client hashes the same way for the whole day, and a
+// client's address can be hashed under every key still kept, so abuse can be
+// matched across the last few days. Keys older than the matching window are
+// deleted; from then on the hashes made with them cannot be linked to an
+// address or to each other by anyone, including the operators.
+//
+// State that must not reset at midnight, such as rate limit buckets, uses
+// ProcessHash instead: a hash under a random key that lives as long as the
+// process and is never stored.
+package netprivacy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// keySize is the size of a daily key
+const keySize = 32
+
+// KeyStore keeps the daily hashing keys. Days are UTC midnights.
+type KeyStore interface {
+	// DayKey returns the key of a day, creating a random one if the day has none
+	DayKey(ctx context.Context, day time.Time) ([]byte, error)
+	// PastKeys returns the keys of the days from since up to but excluding
+	// before, newest first. Days without a key are skipped.
+	PastKeys(ctx context.Context, since, before time.Time) ([][]byte, error)
+	// PurgeBefore deletes the keys of the days before day
+	PurgeBefore(ctx context.Context, day time.Time) error
+}
+
+// Hasher hashes client addresses under the daily keys
+type Hasher struct {
+	store KeyStore
+	days  int // Days whose keys are kept, including the current one
+	now   func() time.Time
+
+	// processKey is the key of ProcessHash, kept for the life of the process
+	processKey []byte
+
+	mu   sync.Mutex
+	day  time.Time // Day the cached keys were loaded on
+	keys [][]byte  // Keys of the window, current day first
+}
+
+// New creates a hasher with the key store selected by the configuration
+func New(cfg *config.PrivacyConfig, db bob.Executor) (*Hasher, error) {
+	switch cfg.Backend {
+	case "memory":
+		return NewHasher(NewMemoryKeyStore(), cfg.MatchWindow), nil
+	case "postgres":
+		return NewHasher(NewPostgresKeyStore(dao.NewIPHashKeyDAO(db)), cfg.MatchWindow), nil
+	default:
+		return nil, fmt.Errorf("unknown privacy key backend %q", cfg.Backend)
+	}
+}
+
+// NewHasher creates a hasher whose keys are kept for window, rounded up to
+// whole days. Addresses can be matched across that window.
+func NewHasher(store KeyStore, window time.Duration) *Hasher {
+	days := int((window + 24*time.Hour - 1) / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+	// crypto/rand.Read never fails as of Go 1.24
+	processKey := make([]byte, keySize)
+	rand.Read(processKey)
+	return &Hasher{store: store, days: days, now: time.Now, processKey: processKey}
+}
+
+// Hash returns the hash of a client address under the current day's key
+func (h *Hasher) Hash(ctx context.Context, ip string) (string, error) {
+	keys, err := h.windowKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	return hashWith(keys[0], ip), nil
+}
+
+// ProcessHash returns the hash of a client address under the process key.
+// It does not change at midnight, but differs between processes; it must
+// not be stored.
+func (h *Hasher) ProcessHash(ip string) string {
+	return hashWith(h.processKey, ip)
+}
+
+// Hashes returns the hashes of a client address under every key in the
+// matching window, current day first. A stored hash matches the address if
+// it is one of them.
+func (h *Hasher) Hashes(ctx context.Context, ip string) ([]string, error) {
+	keys, err := h.windowKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(keys))
+	for i, key := range keys {
+		hashes[i] = hashWith(key, ip)
+	}
+	return hashes, nil
+}
+
+// windowKeys returns the keys of the matching window, loading them and
+// purging expired keys once a day
+func (h *Hasher) windowKeys(ctx context.Context) ([][]byte, error) {
+	today := Day(h.now())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.keys != nil && h.day.Equal(today) {
+		return h.keys, nil
+	}
+
+	current, err := h.store.DayKey(ctx, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get address hashing key: %w", err)
+	}
+	oldest := today.AddDate(0, 0, 1-h.days)
+	past, err := h.store.PastKeys(ctx, oldest, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get past address hashing keys: %w", err)
+	}
+	if err := h.store.PurgeBefore(ctx, oldest); err != nil {
+		// Expired keys are purged again tomorrow
+		log.Error().Err(err).Msg("Failed to purge expired address hashing keys")
+	}
+
+	h.day = today
+	h.keys = append([][]byte{current}, past...)
+	return h.keys, nil
+}
+
+// hashWith hashes the network of a client address under a key
+func hashWith(key []byte, ip string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ClientNetwork(ip)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Day returns the UTC day a time falls on
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// newKey generates a random daily key
+func newKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate address hashing key: %w", err)
+	}
+	return key, nil
+}
+
+// ClientNetwork returns the network a client address is counted as. IPv6
+// clients usually control a whole /64, so they are counted per /64. Anything
+// that is not an IP address is returned unchanged.
+func ClientNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String()
+}
+
+// CoarsePrefix reduces an IP address to its network: /24 for IPv4 and /48
+// for IPv6. It returns an empty string for anything that is not an IP address.
+func CoarsePrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		network := &net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+		return network.String()
+	}
+	network := &net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}
+	return network.String()
+}
@@ -0,0 +1,196 @@
+package netprivacy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestHasher creates a hasher on a memory store whose clock the test controls
+func newTestHasher(window time.Duration, now *time.Time) (*Hasher, *MemoryKeyStore) {
+	store := NewMemoryKeyStore()
+	hasher := NewHasher(store, window)
+	hasher.now = func() time.Time { return *now }
+	return hasher, store
+}
+
+func TestHasher_SameDayMatches(t *testing.T) {
+	now := time.Date(2025, 7, 2, 9, 0, 0, 0, time.UTC)
+	hasher, _ := newTestHasher(7*24*time.Hour, &now)
+	ctx := context.Background()
+
+	first, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if len(first) != 64 {
+		t.Errorf("Expected a hex SHA-256 hash, got %q", first)
+	}
+	if first == "192.0.2.1" {
+		t.Error("Expected the address not to be stored as is")
+	}
+
+	now = now.Add(10 * time.Hour)
+	second, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if first != second {
+		t.Error("Expected an address to hash the same way for the whole day")
+	}
+
+	other, err := hasher.Hash(ctx, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if other == first {
+		t.Error("Expected different addresses to have different hashes")
+	}
+}
+
+func TestHasher_KeyRotatesDaily(t *testing.T) {
+	now := time.Date(2025, 7, 2, 23, 0, 0, 0, time.UTC)
+	hasher, _ := newTestHasher(7*24*time.Hour, &now)
+	ctx := context.Background()
+
+	yesterday, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	hashes, err := hasher.Hashes(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if hashes[0] == yesterday {
+		t.Error("Expected a new key after midnight UTC")
+	}
+	if len(hashes) != 2 || hashes[1] != yesterday {
+		t.Errorf("Expected yesterday's hash to still match, got %v", hashes)
+	}
+}
+
+func TestHasher_ExpiredKeysArePurged(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	hasher, store := newTestHasher(3*24*time.Hour, &now)
+	ctx := context.Background()
+
+	first, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+
+	// Within the window the first day's hash still matches
+	now = now.AddDate(0, 0, 2)
+	hashes, err := hasher.Hashes(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if hashes[len(hashes)-1] != first {
+		t.Errorf("Expected the first day's hash to match within the window, got %v", hashes)
+	}
+
+	// Once the first day leaves the window its key is gone
+	now = now.AddDate(0, 0, 1)
+	hashes, err = hasher.Hashes(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	for _, hash := range hashes {
+		if hash == first {
+			t.Error("Expected the first day's hash not to match after the window")
+		}
+	}
+	if _, ok := store.keys[time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)]; ok {
+		t.Error("Expected the first day's key to be purged")
+	}
+}
+
+func TestHasher_StoresShareKeys(t *testing.T) {
+	store := NewMemoryKeyStore()
+	a := NewHasher(store, 24*time.Hour)
+	b := NewHasher(store, 24*time.Hour)
+	ctx := context.Background()
+
+	hashA, err := a.Hash(ctx, "2001:db8::1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	hashB, err := b.Hash(ctx, "2001:db8::1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if hashA != hashB {
+		t.Error("Expected hashers on one store to hash alike")
+	}
+}
+
+func TestHasher_ProcessHashSurvivesMidnight(t *testing.T) {
+	now := time.Date(2025, 7, 2, 23, 59, 0, 0, time.UTC)
+	hasher, _ := newTestHasher(7*24*time.Hour, &now)
+	ctx := context.Background()
+
+	daily, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	process := hasher.ProcessHash("192.0.2.1")
+	if process == daily || process == "192.0.2.1" {
+		t.Errorf("Expected a process hash apart from the daily hash and the address, got %q", process)
+	}
+
+	now = now.Add(2 * time.Minute)
+	nextDaily, err := hasher.Hash(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to hash address: %v", err)
+	}
+	if nextDaily == daily {
+		t.Error("Expected the daily hash to change at midnight")
+	}
+	if hasher.ProcessHash("192.0.2.1") != process {
+		t.Error("Expected the process hash not to change at midnight")
+	}
+	if hasher.ProcessHash("192.0.2.2") == process {
+		t.Error("Expected different addresses to hash differently")
+	}
+
+	other := NewHasher(NewMemoryKeyStore(), 24*time.Hour)
+	if other.ProcessHash("192.0.2.1") == process {
+		t.Error("Expected hashers of different processes to hash differently")
+	}
+}
+
+func TestClientNetwork(t *testing.T) {
+	if ClientNetwork("2001:db8:1:2:3:4:5:6") != ClientNetwork("2001:db8:1:2:ffff::1") {
+		t.Error("Expected IPv6 addresses in one /64 to share a network")
+	}
+	if ClientNetwork("2001:db8:1:2::1") == ClientNetwork("2001:db8:1:3::1") {
+		t.Error("Expected different IPv6 /64 networks to differ")
+	}
+	if ClientNetwork("192.0.2.1") == ClientNetwork("192.0.2.2") {
+		t.Error("Expected IPv4 addresses to be counted separately")
+	}
+	if ClientNetwork("::ffff:192.0.2.1") != ClientNetwork("192.0.2.1") {
+		t.Error("Expected IPv4-mapped addresses to count as IPv4")
+	}
+}
+
+func TestCoarsePrefix(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.57", "203.0.113.0/24"},
+		{"::ffff:203.0.113.57", "203.0.113.0/24"},
+		{"2001:db8:abcd:12::1", "2001:db8:abcd::/48"},
+		{"not-an-ip", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := CoarsePrefix(tt.ip); got != tt.want {
+			t.Errorf("CoarsePrefix(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
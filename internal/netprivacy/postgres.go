@@ -0,0 +1,39 @@
+package netprivacy
+
+import (
+	"context"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+)
+
+// PostgresKeyStore keeps the daily keys in the database so that every node
+// hashes with the same key
+type PostgresKeyStore struct {
+	ipHashKeyDAO *dao.IPHashKeyDAO
+}
+
+// NewPostgresKeyStore creates a database-backed key store
+func NewPostgresKeyStore(ipHashKeyDAO *dao.IPHashKeyDAO) *PostgresKeyStore {
+	return &PostgresKeyStore{ipHashKeyDAO: ipHashKeyDAO}
+}
+
+// DayKey returns the key of a day. The first node to ask creates it.
+func (s *PostgresKeyStore) DayKey(ctx context.Context, day time.Time) ([]byte, error) {
+	candidate, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	return s.ipHashKeyDAO.GetOrCreateKey(ctx, day, candidate)
+}
+
+// PastKeys returns the keys of the days from since up to but excluding before
+func (s *PostgresKeyStore) PastKeys(ctx context.Context, since, before time.Time) ([][]byte, error) {
+	return s.ipHashKeyDAO.ListKeys(ctx, since, before)
+}
+
+// PurgeBefore deletes the keys of the days before day
+func (s *PostgresKeyStore) PurgeBefore(ctx context.Context, day time.Time) error {
+	_, err := s.ipHashKeyDAO.PurgeBefore(ctx, day)
+	return err
+}
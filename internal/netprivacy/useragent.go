@@ -0,0 +1,66 @@
+package netprivacy
+
+import "strings"
+
+// Browser families. Clients that are not recognized are FamilyOther.
+const (
+	FamilyBot     = "Bot"
+	FamilyOther   = "Other"
+	FamilyUnknown = ""
+)
+
+// browserTokens maps user agent tokens to browser families. Browsers built
+// on another one name it too, so they are listed before it: Edge, Opera and
+// Samsung Internet before Chrome, and Chrome before Safari.
+var browserTokens = []struct {
+	token  string
+	family string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"Edge/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chromium/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"Trident/", "Internet Explorer"},
+	{"MSIE ", "Internet Explorer"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"python-requests/", "Python"},
+	{"Python-urllib/", "Python"},
+	{"Go-http-client/", "Go"},
+	{"okhttp/", "OkHttp"},
+	{"PostmanRuntime/", "Postman"},
+}
+
+// botTokens mark crawlers, matched case-insensitively
+var botTokens = []string{"bot", "crawler", "spider", "slurp"}
+
+// BrowserFamily reduces a user agent to the family of the browser or client
+// that sent it, dropping versions, platform and device details. An empty
+// user agent has an empty family.
+func BrowserFamily(userAgent string) string {
+	if strings.TrimSpace(userAgent) == "" {
+		return FamilyUnknown
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			return FamilyBot
+		}
+	}
+	for _, t := range browserTokens {
+		if strings.Contains(userAgent, t.token) {
+			return t.family
+		}
+	}
+	return FamilyOther
+}
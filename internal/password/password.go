@@ -0,0 +1,156 @@
+// Package password provides password hashing and verification shared by the
+// API handlers, the server CLI and the test harness.
+//
+// Hashes are stored in a self-describing PHC-style string so that the
+// algorithm, its parameters and the salt travel with the hash:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<base64 salt>$<base64 key>
+//
+// Legacy unsalted SHA-256 hex digests are still accepted by Verify so that
+// existing accounts can log in; NeedsRehash reports them so callers can
+// upgrade the stored hash after a successful login.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// AlgorithmArgon2id is the algorithm identifier used in encoded hashes
+const AlgorithmArgon2id = "argon2id"
+
+// Params controls the cost of argon2id hashing
+type Params struct {
+	Memory      uint32 // memory in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follows the OWASP recommendation for argon2id
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var (
+	// ErrInvalidHash is returned when an encoded hash cannot be parsed
+	ErrInvalidHash = errors.New("invalid password hash format")
+	// ErrIncompatibleVersion is returned for argon2 versions we do not support
+	ErrIncompatibleVersion = errors.New("incompatible argon2 version")
+)
+
+// Hash hashes a password with argon2id using DefaultParams
+func Hash(password string) (string, error) {
+	return HashWithParams(password, DefaultParams)
+}
+
+// HashWithParams hashes a password with argon2id using the given parameters
+func HashWithParams(password string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id,
+		argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches the encoded hash. Both argon2id
+// hashes and legacy SHA-256 hex digests are supported.
+func Verify(password, encoded string) (bool, error) {
+	if isLegacySHA256(encoded) {
+		sum := sha256.Sum256([]byte(password))
+		candidate := hex.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(candidate), []byte(strings.ToLower(encoded))) == 1, nil
+	}
+
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+// NeedsRehash reports whether the encoded hash should be replaced with a
+// fresh hash using DefaultParams, either because it uses a legacy algorithm
+// or because its parameters are weaker than the current defaults.
+func NeedsRehash(encoded string) bool {
+	if isLegacySHA256(encoded) {
+		return true
+	}
+
+	p, _, _, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	return p.Memory < DefaultParams.Memory ||
+		p.Iterations < DefaultParams.Iterations ||
+		p.Parallelism < DefaultParams.Parallelism ||
+		p.KeyLength < DefaultParams.KeyLength
+}
+
+// isLegacySHA256 detects unsalted SHA-256 hex digests stored by earlier versions
+func isLegacySHA256(encoded string) bool {
+	if len(encoded) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+// decode parses an encoded argon2id hash into its parameters, salt and key
+func decode(encoded string) (Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return Params{}, nil, nil, ErrIncompatibleVersion
+	}
+
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
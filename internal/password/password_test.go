@@ -0,0 +1,100 @@
+package password
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// testParams keeps the tests fast while exercising the same code paths
+var testParams = Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashAndVerify(t *testing.T) {
+	encoded, err := HashWithParams("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Unexpected encoded hash format: %s", encoded)
+	}
+
+	ok, err := Verify("correct horse battery staple", encoded)
+	if err != nil {
+		t.Fatalf("Failed to verify password: %v", err)
+	}
+	if !ok {
+		t.Error("Expected password to verify")
+	}
+
+	ok, err = Verify("wrong password", encoded)
+	if err != nil {
+		t.Fatalf("Failed to verify password: %v", err)
+	}
+	if ok {
+		t.Error("Expected wrong password to be rejected")
+	}
+}
+
+func TestHashIsSalted(t *testing.T) {
+	first, err := HashWithParams("password", testParams)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	second, err := HashWithParams("password", testParams)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if first == second {
+		t.Error("Expected different hashes for the same password")
+	}
+}
+
+func TestVerifyLegacySHA256(t *testing.T) {
+	sum := sha256.Sum256([]byte("legacy-password"))
+	legacy := hex.EncodeToString(sum[:])
+
+	ok, err := Verify("legacy-password", legacy)
+	if err != nil {
+		t.Fatalf("Failed to verify legacy hash: %v", err)
+	}
+	if !ok {
+		t.Error("Expected legacy hash to verify")
+	}
+
+	ok, _ = Verify("other-password", legacy)
+	if ok {
+		t.Error("Expected wrong password to be rejected for legacy hash")
+	}
+
+	if !NeedsRehash(legacy) {
+		t.Error("Expected legacy hash to need rehash")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak, err := HashWithParams("password", testParams)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !NeedsRehash(weak) {
+		t.Error("Expected hash with weak parameters to need rehash")
+	}
+
+	current, err := Hash("password")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if NeedsRehash(current) {
+		t.Error("Expected hash with default parameters not to need rehash")
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	for _, encoded := range []string{"", "plaintext", "$argon2id$v=19$m=1,t=1$abc$def", "$bcrypt$v=19$m=1,t=1,p=1$abc$def"} {
+		if _, err := Verify("password", encoded); err == nil {
+			t.Errorf("Expected error for invalid hash %q", encoded)
+		}
+	}
+}
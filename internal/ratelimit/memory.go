@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+// MemoryStore keeps buckets in process. Limits are per node.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// memoryBucket is the state of a bucket
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryStore creates an in-memory store. Buckets unused for idle are
+// forgotten; idle must be at least the longest budget period.
+func NewMemoryStore(idle time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*memoryBucket),
+		idle:    idle,
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from a bucket
+func (s *MemoryStore) Take(ctx context.Context, key string, limit config.RateLimit) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Requests), updated: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = refill(bucket.tokens, now.Sub(bucket.updated), limit)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return bucket.tokens, false, nil
+	}
+	bucket.tokens--
+	return bucket.tokens, true, nil
+}
+
+// sweep forgets idle buckets, at most once per idle period
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.idle {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= s.idle {
+			delete(s.buckets, key)
+		}
+	}
+}
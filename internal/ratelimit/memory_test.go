@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func TestMemoryStore_Refill(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	limit := config.RateLimit{Requests: 2, Period: 10 * time.Second}
+
+	for i := 0; i < 2; i++ {
+		if _, allowed, _ := store.Take(ctx, "key", limit); !allowed {
+			t.Fatalf("Expected take %d to be allowed", i+1)
+		}
+	}
+	if _, allowed, _ := store.Take(ctx, "key", limit); allowed {
+		t.Fatal("Expected an empty bucket to refuse")
+	}
+
+	// One token refills every 5 seconds
+	now = now.Add(5 * time.Second)
+	tokens, allowed, _ := store.Take(ctx, "key", limit)
+	if !allowed || tokens != 0 {
+		t.Errorf("Expected one refilled token to be taken, got %v tokens, allowed %v", tokens, allowed)
+	}
+
+	// Buckets never hold more than their capacity
+	now = now.Add(time.Hour - time.Second)
+	if tokens, _, _ := store.Take(ctx, "key", limit); tokens != 1 {
+		t.Errorf("Expected a full bucket to be capped at capacity, got %v tokens left", tokens)
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	limit := config.RateLimit{Requests: 5, Period: time.Minute}
+
+	store.Take(ctx, "idle", limit)
+	now = now.Add(2 * time.Minute)
+	store.Take(ctx, "active", limit)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("Expected the idle bucket to be swept")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Error("Expected the active bucket to be kept")
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+)
+
+// PostgresStore keeps buckets in the database so that every node shares the
+// same limits. Buckets are updated atomically by a single statement.
+type PostgresStore struct {
+	rateLimitDAO *dao.RateLimitDAO
+	idle         time.Duration
+	lastPurge    atomic.Int64
+}
+
+// NewPostgresStore creates a database-backed store. Buckets unused for idle
+// are purged; idle must be at least the longest budget period.
+func NewPostgresStore(rateLimitDAO *dao.RateLimitDAO, idle time.Duration) *PostgresStore {
+	return &PostgresStore{rateLimitDAO: rateLimitDAO, idle: idle}
+}
+
+// Take takes a token from a bucket
+func (s *PostgresStore) Take(ctx context.Context, key string, limit config.RateLimit) (float64, bool, error) {
+	s.maybePurge()
+	return s.rateLimitDAO.TakeToken(ctx, key, float64(limit.Requests), refillRate(limit))
+}
+
+// maybePurge removes idle buckets in the background, at most once per idle period
+func (s *PostgresStore) maybePurge() {
+	now := time.Now().Unix()
+	last := s.lastPurge.Load()
+	if now-last < int64(s.idle.Seconds()) || !s.lastPurge.CompareAndSwap(last, now) {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		purged, err := s.rateLimitDAO.PurgeIdle(ctx, s.idle)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to purge idle rate limit buckets")
+			return
+		}
+		log.Debug().Int64("purged", purged).Msg("Purged idle rate limit buckets")
+	}()
+}
@@ -0,0 +1,223 @@
+// Package ratelimit limits request rates with token buckets. Every client
+// gets one bucket per limit class. A bucket holds up to a budget's Requests
+// tokens and refills continuously over its Period; each request takes a token.
+//
+// Buckets are kept by a Store: in memory for a single node, or in Postgres so
+// that several nodes share the same limits. Bucket keys are HMACs of the
+// client's identity, so a store never holds user IDs or client addresses, and
+// nothing in it reveals which pseudonyms belong to the same user.
+package ratelimit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+	"github.com/matt0x6f/hashpost/internal/database/dao"
+	"github.com/rs/zerolog/log"
+	"github.com/stephenafamo/bob"
+)
+
+// ClassMetadataKey is the operation metadata key that assigns an operation
+// to a limit class. Operations without it are read or write by method.
+const ClassMetadataKey = "rate_limit_class"
+
+// Limit classes
+const (
+	ClassRead        = "read"
+	ClassWrite       = "write"
+	ClassVote        = "vote"
+	ClassReport      = "report"
+	ClassCorrelation = "correlation"
+	// ClassExempt marks operations that are never limited, such as health checks
+	ClassExempt = "exempt"
+)
+
+// Kinds of client, each with its own budgets
+const (
+	KindAnonymous = "anonymous"
+	KindUser      = "user"
+	KindAPIKey    = "api_key"
+)
+
+// Principal identifies the client a request is counted against
+type Principal struct {
+	Kind string
+	ID   string
+}
+
+// UserPrincipal counts requests against a user. Requests made under any of
+// the user's pseudonyms share the user's buckets.
+func UserPrincipal(userID int64) Principal {
+	return Principal{Kind: KindUser, ID: strconv.FormatInt(userID, 10)}
+}
+
+// APIKeyPrincipal counts requests against the user owning the pseudonym an
+// API key acts for, so that creating more keys, for the same pseudonym or for
+// others of the user, does not add to the user's budget
+func APIKeyPrincipal(ownerID int64) Principal {
+	return Principal{Kind: KindAPIKey, ID: strconv.FormatInt(ownerID, 10)}
+}
+
+// AnonymousPrincipal counts requests against a client network, identified by
+// the keyed hash of its address made by the netprivacy package
+func AnonymousPrincipal(ipHash string) Principal {
+	return Principal{Kind: KindAnonymous, ID: ipHash}
+}
+
+// Result is the outcome of counting a request
+type Result struct {
+	Allowed    bool
+	Limit      config.RateLimit
+	Remaining  int           // Whole requests left in the bucket
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed; zero if this one was
+}
+
+// Store keeps token buckets. Take refills the bucket under key for the time
+// since it was last used, takes a token if one is available, and returns the
+// tokens left and whether a token was taken. Unknown keys start full.
+type Store interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (tokens float64, allowed bool, err error)
+}
+
+// Limiter applies the configured budgets to requests
+type Limiter struct {
+	cfg    *config.RateLimitConfig
+	store  Store
+	secret []byte
+}
+
+// New creates a limiter with the store selected by the configuration
+func New(cfg *config.RateLimitConfig, db bob.Executor) (*Limiter, error) {
+	idle := longestPeriod(cfg)
+	switch cfg.Backend {
+	case "memory":
+		return NewLimiter(cfg, NewMemoryStore(idle))
+	case "postgres":
+		if cfg.KeySecret == "" {
+			log.Warn().Msg("RATE_LIMIT_KEY_SECRET is not set; nodes will not share rate limit buckets")
+		}
+		return NewLimiter(cfg, NewPostgresStore(dao.NewRateLimitDAO(db), idle))
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.Backend)
+	}
+}
+
+// NewLimiter creates a limiter backed by the given store. Without a
+// configured key secret, a random one is generated for this process.
+func NewLimiter(cfg *config.RateLimitConfig, store Store) (*Limiter, error) {
+	secret := []byte(cfg.KeySecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate rate limit key secret: %w", err)
+		}
+	}
+	return &Limiter{cfg: cfg, store: store, secret: secret}, nil
+}
+
+// Allow counts a request of a class against a principal
+func (l *Limiter) Allow(ctx context.Context, class string, principal Principal) (Result, error) {
+	limit := l.Budget(class, principal.Kind)
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+
+	tokens, allowed, err := l.store.Take(ctx, l.key(class, principal), limit)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, tokens, allowed), nil
+}
+
+// Budget returns the limit for a class and kind of client. Unknown classes
+// are limited as writes.
+func (l *Limiter) Budget(class, kind string) config.RateLimit {
+	var budgets config.RateLimitBudgets
+	switch class {
+	case ClassRead:
+		budgets = l.cfg.Read
+	case ClassVote:
+		budgets = l.cfg.Vote
+	case ClassReport:
+		budgets = l.cfg.Report
+	case ClassCorrelation:
+		budgets = l.cfg.Correlation
+	default:
+		budgets = l.cfg.Write
+	}
+
+	switch kind {
+	case KindUser:
+		return budgets.User
+	case KindAPIKey:
+		return budgets.APIKey
+	default:
+		return budgets.Anonymous
+	}
+}
+
+// key derives the bucket key of a principal for a class
+func (l *Limiter) key(class string, principal Principal) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(class + ":" + principal.Kind + ":" + principal.ID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// refill returns the tokens in a bucket that held tokens elapsed ago
+func refill(tokens float64, elapsed time.Duration, limit config.RateLimit) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * refillRate(limit)
+	}
+	return math.Min(tokens, float64(limit.Requests))
+}
+
+// refillRate returns the tokens a bucket gains per second
+func refillRate(limit config.RateLimit) float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+// newResult describes a bucket holding tokens after a request
+func newResult(limit config.RateLimit, tokens float64, allowed bool) Result {
+	rate := refillRate(limit)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return result
+}
+
+// secondsToDuration converts a non-negative number of seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// longestPeriod returns the longest period of any budget. A bucket unused for
+// that long is full again, so stores can forget it.
+func longestPeriod(cfg *config.RateLimitConfig) time.Duration {
+	longest := time.Minute
+	for _, budgets := range []config.RateLimitBudgets{cfg.Read, cfg.Write, cfg.Vote, cfg.Report, cfg.Correlation} {
+		for _, limit := range []config.RateLimit{budgets.Anonymous, budgets.User, budgets.APIKey} {
+			if limit.Period > longest {
+				longest = limit.Period
+			}
+		}
+	}
+	return longest
+}
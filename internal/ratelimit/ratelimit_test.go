@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matt0x6f/hashpost/internal/config"
+)
+
+func testConfig() *config.RateLimitConfig {
+	return &config.RateLimitConfig{
+		Enabled:   true,
+		Backend:   "memory",
+		KeySecret: "test-secret",
+		Read: config.RateLimitBudgets{
+			Anonymous: config.RateLimit{Requests: 2, Period: time.Minute},
+			User:      config.RateLimit{Requests: 4, Period: time.Minute},
+			APIKey:    config.RateLimit{Requests: 8, Period: time.Minute},
+		},
+		Write: config.RateLimitBudgets{
+			User: config.RateLimit{Requests: 1, Period: time.Minute},
+		},
+		Vote: config.RateLimitBudgets{
+			User: config.RateLimit{Requests: 3, Period: time.Hour},
+		},
+	}
+}
+
+func TestLimiter_Budget(t *testing.T) {
+	limiter, err := NewLimiter(testConfig(), NewMemoryStore(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	tests := []struct {
+		class, kind string
+		expected    int
+	}{
+		{ClassRead, KindAnonymous, 2},
+		{ClassRead, KindUser, 4},
+		{ClassRead, KindAPIKey, 8},
+		{ClassWrite, KindUser, 1},
+		{ClassVote, KindUser, 3},
+		{"unknown", KindUser, 1},
+	}
+
+	for _, tt := range tests {
+		if got := limiter.Budget(tt.class, tt.kind); got.Requests != tt.expected {
+			t.Errorf("Budget(%q, %q) = %d requests, expected %d", tt.class, tt.kind, got.Requests, tt.expected)
+		}
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter, err := NewLimiter(testConfig(), NewMemoryStore(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	ctx := context.Background()
+	user := UserPrincipal(42)
+
+	for i := 0; i < 4; i++ {
+		result, err := limiter.Allow(ctx, ClassRead, user)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+		if result.Remaining != 3-i {
+			t.Errorf("Expected %d remaining after request %d, got %d", 3-i, i+1, result.Remaining)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, ClassRead, user)
+	if err != nil {
+		t.Fatalf("Allow failed: %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("Expected the fifth request to be refused")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 15*time.Second {
+		t.Errorf("Expected a retry after of at most 15s, got %v", result.RetryAfter)
+	}
+
+	// Classes and clients have separate buckets
+	if result, _ := limiter.Allow(ctx, ClassVote, user); !result.Allowed {
+		t.Error("Expected a vote to be allowed after reads are exhausted")
+	}
+	if result, _ := limiter.Allow(ctx, ClassRead, UserPrincipal(43)); !result.Allowed {
+		t.Error("Expected another user's read to be allowed")
+	}
+}
+
+func TestLimiter_AllowUnlimited(t *testing.T) {
+	limiter, err := NewLimiter(testConfig(), NewMemoryStore(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	// The write budget for anonymous clients is zero, which means no limit
+	for i := 0; i < 10; i++ {
+		result, err := limiter.Allow(context.Background(), ClassWrite, AnonymousPrincipal("192.0.2.1"))
+		if err != nil || !result.Allowed {
+			t.Fatalf("Expected unlimited requests to be allowed, got %+v, %v", result, err)
+		}
+	}
+}
+
+func TestLimiter_KeysDoNotRevealIdentity(t *testing.T) {
+	cfg := testConfig()
+	limiter, err := NewLimiter(cfg, NewMemoryStore(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	key := limiter.key(ClassRead, UserPrincipal(42))
+	if len(key) != 64 {
+		t.Errorf("Expected a 64 character key, got %q", key)
+	}
+	if key == limiter.key(ClassWrite, UserPrincipal(42)) {
+		t.Error("Expected classes to have different keys")
+	}
+	if key == limiter.key(ClassRead, APIKeyPrincipal(42)) {
+		t.Error("Expected kinds of client to have different keys")
+	}
+
+	cfg2 := testConfig()
+	cfg2.KeySecret = "other-secret"
+	other, err := NewLimiter(cfg2, NewMemoryStore(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	if key == other.key(ClassRead, UserPrincipal(42)) {
+		t.Error("Expected keys to depend on the key secret")
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	limit := config.RateLimit{Requests: 60, Period: time.Minute}
+
+	result := newResult(limit, 59, true)
+	if !result.Allowed || result.Remaining != 59 || result.Reset != time.Second || result.RetryAfter != 0 {
+		t.Errorf("Unexpected result for an allowed request: %+v", result)
+	}
+
+	result = newResult(limit, 0.5, false)
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Unexpected result for a refused request: %+v", result)
+	}
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	cfg := testConfig()
+	cfg.Backend = "redis"
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
@@ -0,0 +1,155 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// A secret is split into n shares, any k of which reconstruct it; fewer than
+// k reveal nothing about it. Each byte of the secret is the constant term of
+// a random polynomial of degree k-1, and share i holds the polynomials
+// evaluated at x = i. A share is the evaluations followed by its x
+// coordinate, so it is one byte longer than the secret.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the largest number of shares a secret can be split into
+const MaxShares = 255
+
+var (
+	// ErrTooFewShares is returned when combining fewer than two shares
+	ErrTooFewShares = errors.New("at least two shares are required")
+	// ErrInvalidShare is returned for shares of different lengths, with a
+	// zero x coordinate or with duplicate x coordinates
+	ErrInvalidShare = errors.New("invalid share")
+)
+
+// Split splits a secret into n shares, any threshold of which reconstruct it
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+	if threshold < 2 {
+		return nil, fmt.Errorf("threshold must be at least 2, got %d", threshold)
+	}
+	if n < threshold || n > MaxShares {
+		return nil, fmt.Errorf("number of shares must be between the threshold (%d) and %d, got %d", threshold, MaxShares, n)
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		coefficients[0] = value
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate polynomial: %w", err)
+		}
+		for i := range shares {
+			shares[i][b] = evaluate(coefficients, byte(i+1))
+		}
+	}
+	clear(coefficients)
+
+	return shares, nil
+}
+
+// Combine reconstructs a secret from shares. It cannot tell whether enough
+// shares were given: fewer than the threshold yield a wrong secret, so
+// callers should verify the result.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrTooFewShares
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, ErrInvalidShare
+	}
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, fmt.Errorf("%w: shares have different lengths", ErrInvalidShare)
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("%w: duplicate or zero x coordinate %d", ErrInvalidShare, x)
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(shares))
+	for b := range secret {
+		for i, share := range shares {
+			ys[i] = share[b]
+		}
+		secret[b] = interpolateAtZero(xs, ys)
+	}
+	return secret, nil
+}
+
+// evaluate evaluates a polynomial at x using Horner's method
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// interpolateAtZero returns the value at zero of the polynomial through the
+// points, using Lagrange interpolation
+func interpolateAtZero(xs, ys []byte) byte {
+	var result byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			// x_j / (x_j - x_i); subtraction is addition in GF(2^8)
+			basis = mul(basis, div(xs[j], add(xs[j], xs[i])))
+		}
+		result = add(result, mul(ys[i], basis))
+	}
+	return result
+}
+
+// add adds two elements of GF(2^8)
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+// mul multiplies two elements of GF(2^8) modulo x^8 + x^4 + x^3 + x + 1,
+// without branching on secret values
+func mul(a, b byte) byte {
+	var result byte
+	for i := 0; i < 8; i++ {
+		result ^= -(b & 1) & a
+		carry := -(a >> 7)
+		a = (a << 1) ^ (carry & 0x1b)
+		b >>= 1
+	}
+	return result
+}
+
+// inverse returns the multiplicative inverse of a non-zero element, a^254
+func inverse(a byte) byte {
+	result := a
+	for i := 0; i < 6; i++ {
+		a = mul(a, a)
+		result = mul(result, a)
+	}
+	return mul(result, result)
+}
+
+// div divides two elements of GF(2^8); b must not be zero
+func div(a, b byte) byte {
+	return mul(a, inverse(b))
+}
@@ -0,0 +1,101 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Expected 5 shares, got %d", len(shares))
+	}
+
+	// Every combination of three shares reconstructs the secret
+	for i := 0; i < 5; i++ {
+		for j := i + 1; j < 5; j++ {
+			for k := j + 1; k < 5; k++ {
+				combined, err := Combine([][]byte{shares[i], shares[j], shares[k]})
+				if err != nil {
+					t.Fatalf("Failed to combine shares %d,%d,%d: %v", i, j, k, err)
+				}
+				if !bytes.Equal(combined, secret) {
+					t.Errorf("Shares %d,%d,%d did not reconstruct the secret", i, j, k)
+				}
+			}
+		}
+	}
+
+	// So do all of them, in any order
+	combined, err := Combine([][]byte{shares[4], shares[2], shares[0], shares[3], shares[1]})
+	if err != nil {
+		t.Fatalf("Failed to combine all shares: %v", err)
+	}
+	if !bytes.Equal(combined, secret) {
+		t.Error("All shares did not reconstruct the secret")
+	}
+
+	// Two shares are not enough
+	combined, err = Combine([][]byte{shares[0], shares[1]})
+	if err != nil {
+		t.Fatalf("Failed to combine two shares: %v", err)
+	}
+	if bytes.Equal(combined, secret) {
+		t.Error("Fewer shares than the threshold should not reconstruct the secret")
+	}
+}
+
+func TestSplit_InvalidParameters(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		name      string
+		secret    []byte
+		n         int
+		threshold int
+	}{
+		{"empty secret", nil, 3, 2},
+		{"threshold of one", secret, 3, 1},
+		{"fewer shares than threshold", secret, 2, 3},
+		{"too many shares", secret, 256, 2},
+	}
+	for _, tt := range tests {
+		if _, err := Split(tt.secret, tt.n, tt.threshold); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestCombine_InvalidShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+
+	if _, err := Combine(shares[:1]); !errors.Is(err, ErrTooFewShares) {
+		t.Errorf("Expected ErrTooFewShares, got %v", err)
+	}
+	if _, err := Combine([][]byte{shares[0], shares[0]}); !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("Expected ErrInvalidShare for duplicate shares, got %v", err)
+	}
+	if _, err := Combine([][]byte{shares[0], shares[1][1:]}); !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("Expected ErrInvalidShare for shares of different lengths, got %v", err)
+	}
+}
+
+func TestField_Inverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := mul(byte(a), inverse(byte(a))); got != 1 {
+			t.Fatalf("%d * inverse(%d) = %d, want 1", a, a, got)
+		}
+	}
+}
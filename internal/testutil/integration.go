@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,11 +25,19 @@ import (
 	"github.com/matt0x6f/hashpost/internal/api/logger"
 	"github.com/matt0x6f/hashpost/internal/api/middleware"
 	"github.com/matt0x6f/hashpost/internal/api/routes"
+	"github.com/matt0x6f/hashpost/internal/auditchain"
 	"github.com/matt0x6f/hashpost/internal/config"
 	"github.com/matt0x6f/hashpost/internal/database"
 	"github.com/matt0x6f/hashpost/internal/database/dao"
 	dbmodels "github.com/matt0x6f/hashpost/internal/database/models"
+	"github.com/matt0x6f/hashpost/internal/disclosure"
 	"github.com/matt0x6f/hashpost/internal/ibe"
+	"github.com/matt0x6f/hashpost/internal/jwtkeys"
+	"github.com/matt0x6f/hashpost/internal/mailer"
+	"github.com/matt0x6f/hashpost/internal/mfa"
+	"github.com/matt0x6f/hashpost/internal/netprivacy"
+	"github.com/matt0x6f/hashpost/internal/password"
+	"github.com/matt0x6f/hashpost/internal/ratelimit"
 	"github.com/rs/zerolog/log"
 	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/types"
@@ -250,7 +256,7 @@ func (t *TestEntityTracker) Cleanup(ctx context.Context, db bob.DB) error {
 	// 9. API Keys
 	for apiKeyID := range t.apiKeys {
 		log.Info().Str("api_key_id", apiKeyID).Msg("[TestEntityTracker] Deleting API key")
-		if _, err := db.ExecContext(ctx, "DELETE FROM api_keys WHERE api_key_id = $1", apiKeyID); err != nil {
+		if _, err := db.ExecContext(ctx, "DELETE FROM api_keys WHERE key_id = $1", apiKeyID); err != nil {
 			return fmt.Errorf("failed to cleanup API key %s: %w", apiKeyID, err)
 		}
 	}
@@ -315,9 +321,16 @@ type IntegrationTestSuite struct {
 	UserBlockDAO       *dao.UserBlocksDAO
 	UserPrefDAO        *dao.UserPreferencesDAO
 	IdentityMappingDAO *dao.IdentityMappingDAO
+	AuditChainDAO      *dao.AuditChainDAO
+	DisclosureSigner   *disclosure.Signer
+	MFASecrets         *mfa.SecretBox
+	Hasher             *netprivacy.Hasher
 	Tracker            *TestEntityTracker
 	Cleanup            func()
 	IBESystem          *ibe.IBESystem
+	SigningKeys        *jwtkeys.KeySet
+	// Mailer captures verification and password reset emails
+	Mailer *mailer.MemoryMailer
 }
 
 // GetIBESystem returns the IBE system instance for this test suite
@@ -517,6 +530,9 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 	// Initialize logger with config level
 	logger.InitWithLevel(cfg.Logging.Level)
 
+	// Fail on sensitive fields reaching the logs instead of redacting them
+	logger.SetStrict(true)
+
 	// Parse the database URL to override database configuration
 	if databaseURL != "" {
 		dbConfig, err := parseDSN(databaseURL)
@@ -526,6 +542,10 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 		cfg.Database = *dbConfig
 	}
 
+	// Tests send many requests from one address; rate limit tests enable it
+	// on the suite's config before creating their server
+	cfg.RateLimit.Enabled = false
+
 	// For test databases, check if we need to drop and recreate
 	// Only do this if the database doesn't exist or has no tables
 	if strings.Contains(cfg.Database.Database, "test") {
@@ -605,6 +625,39 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 		Salt:       "test_fingerprint_salt_v1",
 	})
 
+	// Create in-memory JWT signing keys for the test run
+	signingKeys, err := jwtkeys.GenerateKeySet(jwtkeys.AlgEdDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT signing keys: %v", err)
+	}
+
+	// Sign audit checkpoints with an in-memory key
+	checkpointSigner, err := auditchain.GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate audit checkpoint key: %v", err)
+	}
+
+	// Sign disclosure bundles with an in-memory key
+	disclosureSigner, err := disclosure.GenerateSigner()
+	if err != nil {
+		t.Fatalf("Failed to generate disclosure signing key: %v", err)
+	}
+
+	// Encrypt TOTP secrets with an in-memory key
+	mfaSecrets, err := mfa.GenerateSecretBox()
+	if err != nil {
+		t.Fatalf("Failed to generate MFA secret key: %v", err)
+	}
+
+	// Capture outgoing email in memory so tests can follow mailed links
+	mail := mailer.NewMemoryMailer()
+
+	// Hash client addresses with the daily keys kept in the test database
+	hasher, err := netprivacy.New(&cfg.Privacy, db)
+	if err != nil {
+		t.Fatalf("Failed to create address hasher: %v", err)
+	}
+
 	// Create DAOs
 	userDAO := dao.NewUserDAO(db)
 	identityMappingDAO := dao.NewIdentityMappingDAO(db)
@@ -615,11 +668,14 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 	commentDAO := dao.NewCommentDAO(db)
 	userPreferencesDAO := dao.NewUserPreferencesDAO(db)
 	subforumDAO := dao.NewSubforumDAO(db)
+	auditChainDAO := dao.NewAuditChainDAO(db, checkpointSigner, cfg.Audit.CheckpointInterval)
 	voteDAO := dao.NewVoteDAO(db)
 	apiKeyDAO := dao.NewAPIKeyDAO(db)
 
 	// Create auth middleware with test configuration
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.Secret, apiKeyDAO, &cfg.JWT, &cfg.Security)
+	authMiddleware := middleware.NewAuthMiddleware(signingKeys, apiKeyDAO, &cfg.JWT, &cfg.Security)
+	authMiddleware.SetTokenRevocationDAO(dao.NewTokenRevocationDAO(db))
+	authMiddleware.SetUserDAO(userDAO)
 
 	// Set the global auth middleware for Huma functions
 	middleware.SetGlobalAuthMiddleware(authMiddleware)
@@ -634,24 +690,32 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 	humaAPI := humago.New(mux, config)
 
 	// Add router-agnostic middleware
+	humaAPI.UseMiddleware(middleware.ClientInfoMiddleware(hasher))
 	humaAPI.UseMiddleware(middleware.LoggingMiddleware)
 	humaAPI.UseMiddleware(middleware.CORSMiddleware(&cfg.CORS))
 
 	// Add authentication middleware to extract user context
 	humaAPI.UseMiddleware(middleware.AuthenticateUserHuma)
-	log.Info().Str("jwt_secret_length", fmt.Sprintf("%d", len(cfg.JWT.Secret))).Msg("JWT configuration loaded")
+	humaAPI.UseMiddleware(middleware.APIKeyScopeMiddleware(humaAPI))
+	useRateLimit(humaAPI, cfg, db)
+	log.Info().Int("jwt_signing_keys", len(signingKeys.Keys())).Msg("JWT configuration loaded")
 
 	// Register routes with test configuration
 	routes.RegisterHealthRoutes(humaAPI)
 	routes.RegisterHelloRoutes(humaAPI)
-	routes.RegisterAuthRoutes(humaAPI, cfg, db, rawDB, ibeSystem)
+	routes.RegisterAuthRoutes(humaAPI, cfg, db, rawDB, ibeSystem, signingKeys, mfaSecrets, mail)
+	routes.RegisterJWKSRoutes(humaAPI, signingKeys)
+	routes.RegisterSessionRoutes(humaAPI, db)
+	routes.RegisterAPIKeyRoutes(humaAPI, db, securePseudonymDAO)
 	routes.RegisterUserRoutes(humaAPI, userDAO, securePseudonymDAO, userPreferencesDAO, userBlocksDAO, postDAO, commentDAO, ibeSystem)
 	routes.RegisterSubforumRoutes(humaAPI, db)
 	routes.RegisterMessagesRoutes(humaAPI)
 	routes.RegisterSearchRoutes(humaAPI)
 	routes.RegisterModerationRoutes(humaAPI)
 	routes.RegisterContentRoutes(humaAPI, db, rawDB, ibeSystem, identityMappingDAO, userDAO)
-	routes.RegisterCorrelationRoutes(humaAPI, db, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO)
+	routes.RegisterCorrelationRoutes(humaAPI, cfg, db, auditChainDAO, ibeSystem, securePseudonymDAO, identityMappingDAO, postDAO, commentDAO, subforumDAO, mail)
+	routes.RegisterDisclosureRoutes(humaAPI, cfg, db, auditChainDAO, securePseudonymDAO)
+	routes.RegisterComplianceRoutes(humaAPI, db, auditChainDAO, disclosureSigner)
 
 	server := &api.Server{
 		API:       humaAPI,
@@ -685,14 +749,28 @@ func NewIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 		UserBlockDAO:       userBlocksDAO,
 		UserPrefDAO:        userPreferencesDAO,
 		IdentityMappingDAO: identityMappingDAO,
+		AuditChainDAO:      auditChainDAO,
+		DisclosureSigner:   disclosureSigner,
+		MFASecrets:         mfaSecrets,
+		Hasher:             hasher,
 		Tracker:            tracker,
 		IBESystem:          ibeSystem,
+		SigningKeys:        signingKeys,
+		Mailer:             mail,
 		Cleanup: func() {
 			// Clean up test data
 			ctx := context.Background()
 			if err := tracker.Cleanup(ctx, db); err != nil {
 				t.Logf("Warning: failed to cleanup test data: %v", err)
 			}
+			// Every test logs in from the same address, so failed logins
+			// must not carry over into the next test's lockout counters
+			if _, err := db.ExecContext(ctx, "DELETE FROM login_failures"); err != nil {
+				t.Logf("Warning: failed to cleanup login failures: %v", err)
+			}
+			if _, err := db.ExecContext(ctx, "DELETE FROM rate_limit_buckets"); err != nil {
+				t.Logf("Warning: failed to cleanup rate limit buckets: %v", err)
+			}
 		},
 	}
 
@@ -715,6 +793,11 @@ func (ts *IntegrationTestSuite) CreateTestUser(t *testing.T, email, password str
 	// Track for cleanup
 	ts.Tracker.TrackUser(user.UserID)
 
+	// Test users start verified; tests of the verification flow register through the API
+	if _, err := ts.UserDAO.MarkEmailVerified(ctx, user.UserID, email); err != nil {
+		t.Fatalf("Failed to verify test user email: %v", err)
+	}
+
 	// Set roles and capabilities if provided
 	if len(roles) > 0 {
 		rolesJSON, _ := json.Marshal(roles)
@@ -910,6 +993,7 @@ func (ts *IntegrationTestSuite) CreateTestAPIKey(t *testing.T, userID int64, pse
 	// Convert permissions map to APIKeyPermissions struct
 	var roles []string
 	var capabilities []string
+	var scopes []string
 
 	if rolesVal, ok := permissions["roles"].([]string); ok {
 		roles = rolesVal
@@ -917,10 +1001,14 @@ func (ts *IntegrationTestSuite) CreateTestAPIKey(t *testing.T, userID int64, pse
 	if capsVal, ok := permissions["capabilities"].([]string); ok {
 		capabilities = capsVal
 	}
+	if scopesVal, ok := permissions["scopes"].([]string); ok {
+		scopes = scopesVal
+	}
 
 	apiKeyPermissions := &dao.APIKeyPermissions{
 		Roles:        roles,
 		Capabilities: capabilities,
+		Scopes:       scopes,
 	}
 
 	// Generate a random API key
@@ -958,6 +1046,18 @@ func (ts *IntegrationTestSuite) CreateTestPseudonym(t *testing.T, userID int64,
 	return pseudonym
 }
 
+// useRateLimit adds the rate limiting middleware if it is enabled
+func useRateLimit(humaAPI huma.API, cfg *config.Config, db bob.Executor) {
+	if !cfg.RateLimit.Enabled {
+		return
+	}
+	limiter, err := ratelimit.New(&cfg.RateLimit, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create rate limiter")
+	}
+	humaAPI.UseMiddleware(middleware.RateLimitMiddleware(humaAPI, limiter))
+}
+
 // CreateTestServer creates an HTTP test server for integration tests
 func (ts *IntegrationTestSuite) CreateTestServer() *httptest.Server {
 	// Create a new server with the test database configuration
@@ -979,7 +1079,9 @@ func (ts *IntegrationTestSuite) createTestServer() *api.Server {
 	apiKeyDAO := ts.APIKeyDAO
 
 	// Create auth middleware with test configuration
-	authMiddleware := middleware.NewAuthMiddleware(ts.Config.JWT.Secret, apiKeyDAO, &ts.Config.JWT, &ts.Config.Security)
+	authMiddleware := middleware.NewAuthMiddleware(ts.SigningKeys, apiKeyDAO, &ts.Config.JWT, &ts.Config.Security)
+	authMiddleware.SetTokenRevocationDAO(dao.NewTokenRevocationDAO(ts.DB))
+	authMiddleware.SetUserDAO(userDAO)
 
 	// Set the global auth middleware for Huma functions
 	middleware.SetGlobalAuthMiddleware(authMiddleware)
@@ -994,24 +1096,32 @@ func (ts *IntegrationTestSuite) createTestServer() *api.Server {
 	humaAPI := humago.New(mux, config)
 
 	// Add router-agnostic middleware
+	humaAPI.UseMiddleware(middleware.ClientInfoMiddleware(ts.Hasher))
 	humaAPI.UseMiddleware(middleware.LoggingMiddleware)
 	humaAPI.UseMiddleware(middleware.CORSMiddleware(&ts.Config.CORS))
 
 	// Add authentication middleware to extract user context
 	humaAPI.UseMiddleware(middleware.AuthenticateUserHuma)
-	log.Info().Str("jwt_secret_length", fmt.Sprintf("%d", len(ts.Config.JWT.Secret))).Msg("JWT configuration loaded")
+	humaAPI.UseMiddleware(middleware.APIKeyScopeMiddleware(humaAPI))
+	useRateLimit(humaAPI, ts.Config, ts.DB)
+	log.Info().Int("jwt_signing_keys", len(ts.SigningKeys.Keys())).Msg("JWT configuration loaded")
 
 	// Register routes with test configuration
 	routes.RegisterHealthRoutes(humaAPI)
 	routes.RegisterHelloRoutes(humaAPI)
-	routes.RegisterAuthRoutes(humaAPI, ts.Config, ts.DB, ts.DB.DB, ibeSystem)
+	routes.RegisterAuthRoutes(humaAPI, ts.Config, ts.DB, ts.DB.DB, ibeSystem, ts.SigningKeys, ts.MFASecrets, ts.Mailer)
+	routes.RegisterJWKSRoutes(humaAPI, ts.SigningKeys)
+	routes.RegisterSessionRoutes(humaAPI, ts.DB)
+	routes.RegisterAPIKeyRoutes(humaAPI, ts.DB, pseudonymDAO)
 	routes.RegisterUserRoutes(humaAPI, userDAO, pseudonymDAO, userPreferencesDAO, userBlocksDAO, postDAO, commentDAO, ibeSystem)
 	routes.RegisterSubforumRoutes(humaAPI, ts.DB)
 	routes.RegisterMessagesRoutes(humaAPI)
 	routes.RegisterSearchRoutes(humaAPI)
 	routes.RegisterModerationRoutes(humaAPI)
 	routes.RegisterContentRoutes(humaAPI, ts.DB, ts.DB.DB, ibeSystem, identityMappingDAO, userDAO)
-	routes.RegisterCorrelationRoutes(humaAPI, ts.DB, ibeSystem, pseudonymDAO, identityMappingDAO, postDAO, commentDAO, ts.SubforumDAO)
+	routes.RegisterCorrelationRoutes(humaAPI, ts.Config, ts.DB, ts.AuditChainDAO, ibeSystem, pseudonymDAO, identityMappingDAO, postDAO, commentDAO, ts.SubforumDAO, ts.Mailer)
+	routes.RegisterDisclosureRoutes(humaAPI, ts.Config, ts.DB, ts.AuditChainDAO, pseudonymDAO)
+	routes.RegisterComplianceRoutes(humaAPI, ts.DB, ts.AuditChainDAO, ts.DisclosureSigner)
 
 	return &api.Server{
 		API:       humaAPI,
@@ -1159,9 +1269,12 @@ func (ts *IntegrationTestSuite) ParseResponse(t *testing.T, resp *http.Response,
 
 // Helper functions
 
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
+func hashPassword(plaintext string) string {
+	hash, err := password.Hash(plaintext)
+	if err != nil {
+		panic(fmt.Sprintf("failed to hash password: %v", err))
+	}
+	return hash
 }
 
 func getCapabilitiesForRoles(roles []string) []string {
@@ -1172,7 +1285,7 @@ func getCapabilitiesForRoles(roles []string) []string {
 		case "user":
 			capabilities = append(capabilities, "create_content", "vote", "message", "report", "create_subforum")
 		case "platform_admin":
-			capabilities = append(capabilities, "create_content", "vote", "message", "report", "create_subforum", "moderation", "compliance", "legal_requests")
+			capabilities = append(capabilities, "create_content", "vote", "message", "report", "create_subforum", "moderation", "compliance", "legal_requests", "system_admin", "user_management")
 		case "trust_safety":
 			capabilities = append(capabilities, "create_content", "vote", "message", "report", "moderation", "compliance")
 		case "legal_team":